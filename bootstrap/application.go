@@ -4,13 +4,12 @@ package bootstrap
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
-	"time"
 
 	"github.com/najoast/sngo/config"
 	"github.com/najoast/sngo/core"
@@ -37,14 +36,30 @@ type DefaultApplication struct {
 	// network server for TCP connections
 	networkServer network.Server
 
+	// admission holds back network traffic during warm-up
+	admission *network.AdmissionController
+
+	// errorSink collects errors of background goroutines
+	errorSink *ErrorSink
+
+	// logFile is the log output opened by the last configuration, nil
+	// when logging to stdout or stderr
+	logFile io.Closer
+
 	// mutex protects concurrent access
 	mutex sync.RWMutex
 
 	// running indicates if the application is running
 	running bool
 
-	// shutdownChan for graceful shutdown
+	// shutdownChan receives programmatic shutdown requests
 	shutdownChan chan os.Signal
+
+	// signalConfig maps OS signals to application actions
+	signalConfig SignalConfig
+
+	// profile holds the defaults of the deployment environment
+	profile TuningProfile
 }
 
 // NewApplication creates a new SNGO application
@@ -56,7 +71,11 @@ func NewApplication() Application {
 		container:        container,
 		lifecycleManager: lifecycleManager,
 		shutdownChan:     make(chan os.Signal, 1),
-		configLoader:     config.NewLoader(),
+		signalConfig:     DefaultSignalConfig(),
+		configLoader:     NewProfileLoader(),
+		admission:        network.NewAdmissionController(),
+		errorSink:        NewErrorSink(DefaultErrorSinkConfig(), nil),
+		profile:          ProfileFor(config.DefaultConfig().App.Environment),
 	}
 
 	// Register core services
@@ -75,9 +94,75 @@ func (app *DefaultApplication) Configure(cfg interface{}) error {
 	}
 
 	app.config = cfg
+	if env, ok := configEnvironment(cfg); ok {
+		app.profile = ProfileFor(env)
+	}
+	if err := app.configureServiceValues(cfg); err != nil {
+		return err
+	}
 	return app.configureCoreServices(cfg)
 }
 
+// Profile returns the tuning profile of the configured environment
+func (app *DefaultApplication) Profile() TuningProfile {
+	app.mutex.RLock()
+	defer app.mutex.RUnlock()
+
+	return app.profile
+}
+
+// configureServiceValues makes services find their logger and configuration
+// section in their context: sections are read from Custom of a
+// *config.Config, or from the top level of a configuration map. The log
+// file of the previous configuration is closed once replaced.
+func (app *DefaultApplication) configureServiceValues(cfg interface{}) error {
+	lm, ok := app.lifecycleManager.(*DefaultLifecycleManager)
+	if !ok {
+		return nil
+	}
+
+	var logFile io.Closer
+	switch c := cfg.(type) {
+	case *config.Config:
+		logger, closer, err := NewLogger(c.Log)
+		if err != nil {
+			return err
+		}
+		logFile = closer
+		lm.SetServiceValues(logger, func(service string) interface{} {
+			return c.Custom[service]
+		})
+	case map[string]interface{}:
+		lm.SetServiceValues(nil, func(service string) interface{} {
+			return c[service]
+		})
+	default:
+		return nil
+	}
+
+	if app.logFile != nil {
+		app.logFile.Close()
+	}
+	app.logFile = logFile
+	return nil
+}
+
+// configEnvironment returns the deployment environment a configuration selects
+func configEnvironment(cfg interface{}) (config.Environment, bool) {
+	switch c := cfg.(type) {
+	case *config.Config:
+		return c.App.Environment, c.App.Environment != ""
+	case map[string]interface{}:
+		switch env := c["environment"].(type) {
+		case config.Environment:
+			return env, env != ""
+		case string:
+			return config.Environment(env), env != ""
+		}
+	}
+	return "", false
+}
+
 // Run runs the application until shutdown
 func (app *DefaultApplication) Run(ctx context.Context) error {
 	app.mutex.Lock()
@@ -86,25 +171,87 @@ func (app *DefaultApplication) Run(ctx context.Context) error {
 		return fmt.Errorf("application is already running")
 	}
 	app.running = true
+	signalConfig := app.signalConfig
+	profile := app.profile
+	errorSink := app.errorSink
 	app.mutex.Unlock()
 
-	// Setup signal handling for graceful shutdown
-	signal.Notify(app.shutdownChan, os.Interrupt, syscall.SIGTERM)
+	// Collect background errors of all modules while running
+	errorSink.Install()
+	defer core.SetErrorReporter(nil)
+
+	// Setup signal handling for shutdown, reload and diagnostic dumps
+	signalChan := make(chan os.Signal, 1)
+	if signals := signalConfig.signals(); len(signals) > 0 {
+		signal.Notify(signalChan, signals...)
+		defer signal.Stop(signalChan)
+	}
+
+	// Admit health checks only until services passed their smoke checks,
+	// and trickle traffic while they warm up
+	lm, _ := app.lifecycleManager.(*DefaultLifecycleManager)
+	smokeTest := lm != nil && lm.hasSmokeChecks()
+	if smokeTest || profile.WarmUpTimeout > 0 {
+		app.admission.SetMode(network.AdmitHealthChecks)
+	}
 
 	// Start all services
 	if err := app.lifecycleManager.Start(ctx); err != nil {
+		app.admission.SetMode(network.AdmitAll)
 		app.mutex.Lock()
 		app.running = false
 		app.mutex.Unlock()
 		return fmt.Errorf("failed to start services: %w", err)
 	}
 
-	// Wait for shutdown signal or context cancellation
-	select {
-	case <-app.shutdownChan:
-		fmt.Println("Received shutdown signal, starting graceful shutdown...")
-	case <-ctx.Done():
-		fmt.Println("Context cancelled, starting graceful shutdown...")
+	// Probe the started services; a failure stops them again before any
+	// traffic was admitted
+	if smokeTest {
+		if _, err := lm.SmokeTest(ctx, profile.SmokeTimeout); err != nil {
+			if stopErr := app.lifecycleManager.Stop(context.Background()); stopErr != nil {
+				core.ReportError("bootstrap", "smoke_test", core.SeverityError, fmt.Errorf("failed to stop services: %w", stopErr))
+			}
+			app.admission.SetMode(network.AdmitAll)
+			app.mutex.Lock()
+			app.running = false
+			app.mutex.Unlock()
+			return fmt.Errorf("failed to start services: %w", err)
+		}
+	}
+
+	// Warm up services, then admit all traffic
+	if profile.WarmUpTimeout > 0 {
+		app.admission.SetTrickleRate(profile.WarmUpTrickle)
+		if profile.WarmUpTrickle > 0 {
+			app.admission.SetMode(network.AdmitTrickle)
+		}
+		if lm != nil {
+			if err := lm.WarmUp(ctx, profile.WarmUpTimeout); err != nil {
+				core.ReportError("bootstrap", "warm_up", core.SeverityWarning, err)
+			}
+		}
+	}
+	if smokeTest || profile.WarmUpTimeout > 0 {
+		app.admission.SetMode(network.AdmitAll)
+	}
+
+	// Complete the upgrade handshake when running under a supervisor
+	if err := NotifyReady(); err != nil {
+		core.ReportError("bootstrap", "supervisor", core.SeverityError, fmt.Errorf("failed to notify supervisor: %w", err))
+	}
+
+	// Handle signals until shutdown is requested or the context is cancelled
+	for shutdown := false; !shutdown; {
+		select {
+		case sig := <-signalChan:
+			shutdown = app.handleSignal(ctx, signalConfig, sig)
+		case <-app.shutdownChan:
+			fmt.Println("Shutdown requested, starting graceful shutdown...")
+			shutdown = true
+		case <-ctx.Done():
+			fmt.Println("Context cancelled, starting graceful shutdown...")
+			shutdown = true
+		}
 	}
 
 	// Shutdown gracefully
@@ -122,7 +269,7 @@ func (app *DefaultApplication) Shutdown(ctx context.Context) error {
 	app.mutex.Unlock()
 
 	// Create shutdown context with timeout
-	shutdownCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(ctx, app.profile.ShutdownTimeout)
 	defer cancel()
 
 	// Stop all services
@@ -133,6 +280,20 @@ func (app *DefaultApplication) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// Admission returns the controller admitting network traffic, to set the
+// health check predicate used during warm-up
+func (app *DefaultApplication) Admission() *network.AdmissionController {
+	return app.admission
+}
+
+// ErrorSink returns the sink collecting the errors of background
+// goroutines while the application runs
+func (app *DefaultApplication) ErrorSink() *ErrorSink {
+	app.mutex.RLock()
+	defer app.mutex.RUnlock()
+	return app.errorSink
+}
+
 // Container returns the dependency injection container
 func (app *DefaultApplication) Container() Container {
 	return app.container
@@ -165,11 +326,8 @@ func (app *DefaultApplication) configureCoreServices(cfg interface{}) error {
 	if configMap, ok := cfg.(map[string]interface{}); ok {
 		if networkConfig, exists := configMap["network"]; exists {
 			if netCfg, ok := networkConfig.(map[string]interface{}); ok {
-				networkConfig := &network.NetworkConfig{
-					Protocol: network.ProtocolTCP,
-					Address:  "localhost",
-					Port:     8080,
-				}
+				networkConfig := app.profile.NetworkConfig()
+				networkConfig.Address = "localhost"
 
 				// Configure server if address is provided
 				if addr, exists := netCfg["address"]; exists {
@@ -192,6 +350,10 @@ func (app *DefaultApplication) configureCoreServices(cfg interface{}) error {
 					return fmt.Errorf("failed to create network server: %w", err)
 				}
 
+				if controllable, ok := server.(network.AdmissionControllable); ok {
+					controllable.SetAdmissionController(app.admission)
+				}
+
 				app.networkServer = server
 				app.container.RegisterInstance("network-server", server)
 			}
@@ -327,6 +489,12 @@ func (b *ApplicationBuilder) WithServiceFactory(name string, factory ServiceFact
 	return b
 }
 
+// WithEnvironment selects the deployment environment and its tuning profile
+func (b *ApplicationBuilder) WithEnvironment(env config.Environment) *ApplicationBuilder {
+	b.config["environment"] = env
+	return b
+}
+
 // WithActorSystemConfig configures the actor system
 func (b *ApplicationBuilder) WithActorSystemConfig() *ApplicationBuilder {
 	b.config["actor_system"] = map[string]interface{}{
@@ -343,6 +511,21 @@ func (b *ApplicationBuilder) WithNetworkConfig(address string) *ApplicationBuild
 	return b
 }
 
+// WithSmokeCheck adds a smoke check to a registered service
+func (b *ApplicationBuilder) WithSmokeCheck(service string, check SmokeCheck) *ApplicationBuilder {
+	if lm, ok := b.app.lifecycleManager.(*DefaultLifecycleManager); ok {
+		lm.RegisterSmokeCheck(service, check)
+	}
+	return b
+}
+
+// WithErrorSink configures how background errors are logged and forwarded
+func (b *ApplicationBuilder) WithErrorSink(config ErrorSinkConfig) *ApplicationBuilder {
+	b.app.errorSink.Close()
+	b.app.errorSink = NewErrorSink(config, nil)
+	return b
+}
+
 // Build builds the configured application
 func (b *ApplicationBuilder) Build() (Application, error) {
 	if len(b.config) > 0 {
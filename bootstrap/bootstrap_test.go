@@ -3,8 +3,26 @@ package bootstrap
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/najoast/sngo/config"
+	"github.com/najoast/sngo/core"
 )
 
 func TestContainer(t *testing.T) {
@@ -193,6 +211,257 @@ func TestScopedContainer(t *testing.T) {
 	}
 }
 
+func TestLifecycleManagerStartRetry(t *testing.T) {
+	lm := NewLifecycleManager(NewContainer()).(*DefaultLifecycleManager)
+
+	flaky := &FlakyService{TestService: TestService{name: "flaky"}, failures: 2}
+	if err := lm.Register("flaky", flaky); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	lm.SetStartPolicy("flaky", StartPolicy{Attempts: 3, Backoff: time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := lm.Start(ctx); err != nil {
+		t.Fatalf("Expected start to succeed after retries: %v", err)
+	}
+
+	if flaky.attempts != 3 {
+		t.Errorf("Expected 3 start attempts, got %d", flaky.attempts)
+	}
+	if !flaky.started {
+		t.Error("Flaky service should be started")
+	}
+}
+
+func TestLifecycleManagerStartRollback(t *testing.T) {
+	lm := NewLifecycleManager(NewContainer()).(*DefaultLifecycleManager)
+
+	first := &TestService{name: "first"}
+	second := &TestService{name: "second"}
+	broken := &FlakyService{TestService: TestService{name: "broken"}, failures: 10}
+
+	lm.Register("first", first)
+	lm.Register("second", second, "first")
+	lm.Register("broken", broken, "second")
+	lm.SetStartPolicy("broken", StartPolicy{Attempts: 2})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := lm.Start(ctx); err == nil {
+		t.Fatal("Expected start to fail")
+	}
+
+	if broken.attempts != 2 {
+		t.Errorf("Expected 2 start attempts, got %d", broken.attempts)
+	}
+	if !first.stopped || !second.stopped {
+		t.Error("Already-started services should be rolled back")
+	}
+	if lm.IsStarted() {
+		t.Error("Lifecycle manager should not be started after rollback")
+	}
+
+	var rolledBack []string
+	for {
+		select {
+		case event := <-lm.Events():
+			if event.Type == "service.rolled_back" {
+				rolledBack = append(rolledBack, event.Service)
+			}
+			continue
+		default:
+		}
+		break
+	}
+
+	if len(rolledBack) != 2 || rolledBack[0] != "second" || rolledBack[1] != "first" {
+		t.Errorf("Expected rollback order [second first], got %v", rolledBack)
+	}
+}
+
+func TestApplicationSignalTriggers(t *testing.T) {
+	reloadable := &ReloadableService{TestService: TestService{name: "reloadable"}}
+	app, err := NewApplicationBuilder().WithService("reloadable", reloadable).Build()
+	if err != nil {
+		t.Fatalf("Failed to build application: %v", err)
+	}
+
+	da := app.(*DefaultApplication)
+	config := da.SignalConfig()
+	config.DumpDir = t.TempDir()
+	da.SetSignalConfig(config)
+
+	if err := da.TriggerReload(context.Background()); err == nil {
+		t.Error("Expected reload before start to fail")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- app.Run(context.Background()) }()
+
+	lm := da.LifecycleManager().(*DefaultLifecycleManager)
+	deadline := time.Now().Add(5 * time.Second)
+	for !lm.IsStarted() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := da.TriggerReload(context.Background()); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	if reloadable.reloads != 1 {
+		t.Errorf("Expected 1 reload, got %d", reloadable.reloads)
+	}
+
+	path, err := da.TriggerDump()
+	if err != nil {
+		t.Fatalf("Failed to dump diagnostics: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read diagnostics: %v", err)
+	}
+	for _, section := range []string{"== Services ==", "reloadable", "== Goroutines =="} {
+		if !strings.Contains(string(data), section) {
+			t.Errorf("Diagnostics missing %q", section)
+		}
+	}
+
+	da.TriggerShutdown()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Application did not shut down")
+	}
+	if !reloadable.stopped {
+		t.Error("Reloadable service should be stopped")
+	}
+}
+
+func TestLifecycleManagerSLO(t *testing.T) {
+	lm := NewLifecycleManager(NewContainer()).(*DefaultLifecycleManager)
+
+	service := &TestService{name: "db"}
+	if err := lm.Register("db", service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	lm.SetSLOPolicy("db", SLOPolicy{Target: 0.9, Windows: []time.Duration{time.Hour}})
+
+	events := make(chan LifecycleEvent, 10)
+	lm.AddListener(func(event LifecycleEvent) {
+		if strings.HasPrefix(event.Type, "service.slo_") {
+			events <- event
+		}
+	})
+
+	now := time.Now()
+	lm.slo.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if err := lm.Start(ctx); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+
+	// 50 healthy minutes followed by 10 unhealthy ones
+	lm.Health(ctx)
+	now = now.Add(50 * time.Minute)
+	service.stopped = true
+	lm.Health(ctx)
+	now = now.Add(10 * time.Minute)
+	health, _ := lm.Health(ctx)
+
+	availability := health["db"].Data["availability"].(map[string]float64)["1h"]
+	if availability < 0.83 || availability > 0.84 {
+		t.Errorf("Expected 1h availability of 5/6, got %v", availability)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != "service.slo_breached" || event.Service != "db" {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected SLO breach event")
+	}
+
+	// Recovering for long enough lifts the breach
+	service.stopped = false
+	lm.Health(ctx)
+	now = now.Add(time.Hour)
+	lm.Health(ctx)
+
+	select {
+	case event := <-events:
+		if event.Type != "service.slo_recovered" {
+			t.Errorf("Expected recovery event, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected SLO recovery event")
+	}
+
+	report := lm.SLOReport()
+	if len(report) != 1 || report[0].Breached || report[0].Windows[0].Availability != 1 {
+		t.Errorf("Unexpected SLO report: %+v", report)
+	}
+}
+
+// ReloadableService counts reloads
+type ReloadableService struct {
+	TestService
+	reloads int
+}
+
+func (s *ReloadableService) Reload(ctx context.Context) error {
+	s.reloads++
+	return nil
+}
+
+// FlakyService fails to start a fixed number of times before succeeding
+type FlakyService struct {
+	TestService
+	failures int
+	attempts int
+}
+
+func (s *FlakyService) Start(ctx context.Context) error {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return fmt.Errorf("transient failure %d", s.attempts)
+	}
+	return s.TestService.Start(ctx)
+}
+
+func TestLifecycleManagerLeaderOnlyValidation(t *testing.T) {
+	lm := NewLifecycleManager(NewContainer()).(*DefaultLifecycleManager)
+	if err := lm.RegisterLeaderOnly("scheduler", &TestService{name: "scheduler"}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	if err := lm.Start(context.Background()); err == nil {
+		t.Fatal("Expected leader-only services refused without a leadership")
+	}
+
+	lm = NewLifecycleManager(NewContainer()).(*DefaultLifecycleManager)
+	lm.SetLeadership(noLeadership{})
+	lm.RegisterLeaderOnly("scheduler", &TestService{name: "scheduler"})
+	lm.Register("api", &TestService{name: "api"}, "scheduler")
+	if err := lm.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "leader-only") {
+		t.Errorf("Expected a regular service refused to depend on a leader-only one, got %v", err)
+	}
+}
+
+// noLeadership is a Leadership that never elects the local node
+type noLeadership struct{}
+
+func (noLeadership) RunWhileLeader(name string, lead func(ctx context.Context) error, stopTimeout time.Duration) error {
+	return nil
+}
+
+func (noLeadership) StopRunningWhileLeader(name string) {}
+
 // TestService is a simple service implementation for testing
 type TestService struct {
 	name    string
@@ -226,3 +495,506 @@ func (s *TestService) Health(ctx context.Context) (HealthStatus, error) {
 		Message: "Service is not running",
 	}, nil
 }
+
+// TestSupervisorChild is the child process started by the supervisor tests
+func TestSupervisorChild(t *testing.T) {
+	switch os.Getenv("SNGO_TEST_CHILD") {
+	case "crash":
+		fmt.Fprintln(os.Stderr, "boom")
+		os.Exit(2)
+	case "serve":
+		listener, ok, err := InheritedListener("test")
+		if !ok || err != nil {
+			fmt.Fprintf(os.Stderr, "no inherited listener: %v\n", err)
+			os.Exit(3)
+		}
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGTERM)
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(conn, "%d", Generation())
+				conn.Close()
+			}
+		}()
+		NotifyReady()
+		<-stop
+		os.Exit(0)
+	}
+}
+
+// supervisorTestConfig runs TestSupervisorChild in the given mode
+func supervisorTestConfig(t *testing.T, mode string) SupervisorConfig {
+	config := DefaultSupervisorConfig()
+	config.Command = os.Args[0]
+	config.Args = []string{"-test.run=^TestSupervisorChild$"}
+	config.Env = []string{"SNGO_TEST_CHILD=" + mode}
+	config.Stdout = io.Discard
+	config.Stderr = io.Discard
+	config.MinBackoff = 10 * time.Millisecond
+	config.CrashDir = t.TempDir()
+	config.ShutdownTimeout = 5 * time.Second
+	config.ReadyTimeout = 10 * time.Second
+	return config
+}
+
+func TestSupervisorRestartsAndCrashReports(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor tests need Unix signals")
+	}
+
+	config := supervisorTestConfig(t, "crash")
+	config.MaxRestarts = 3
+	config.MaxCrashReports = 2
+
+	supervisor := NewSupervisor(config)
+	err := supervisor.Run(context.Background())
+	if !errors.Is(err, ErrTooManyRestarts) {
+		t.Fatalf("Expected ErrTooManyRestarts, got %v", err)
+	}
+	if stats := supervisor.Stats(); stats.Restarts != 3 || stats.Generation != 4 {
+		t.Errorf("Expected 3 restarts over 4 generations, got %+v", stats)
+	}
+
+	reports, _ := filepath.Glob(filepath.Join(config.CrashDir, "crash-*.txt"))
+	if len(reports) != 2 {
+		t.Fatalf("Expected crash reports rotated down to 2, got %d", len(reports))
+	}
+	data, _ := os.ReadFile(reports[1])
+	if !strings.Contains(string(data), "boom") || !strings.Contains(string(data), "generation: 4") {
+		t.Errorf("Crash report missing stderr tail or generation:\n%s", data)
+	}
+}
+
+func TestSupervisorUpgradeWithListenerHandoff(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor tests need Unix signals")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	supervisor := NewSupervisor(supervisorTestConfig(t, "serve"))
+	if err := supervisor.AddListener("test", listener); err != nil {
+		t.Fatalf("Failed to add listener: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- supervisor.Run(ctx) }()
+
+	generation := func() string {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		return string(data)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !supervisor.Stats().Ready && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := generation(); got != "1" {
+		t.Fatalf("Expected generation 1 to serve, got %q", got)
+	}
+
+	if err := supervisor.Upgrade(context.Background()); err != nil {
+		t.Fatalf("Upgrade failed: %v", err)
+	}
+	if got := generation(); got != "2" {
+		t.Errorf("Expected generation 2 to serve after upgrade, got %q", got)
+	}
+	if stats := supervisor.Stats(); stats.Upgrades != 1 || stats.Restarts != 0 {
+		t.Errorf("Unexpected stats after upgrade: %+v", stats)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Supervisor did not stop")
+	}
+}
+
+func TestTuningProfiles(t *testing.T) {
+	dev, prod := ProfileFor(config.EnvDevelopment), ProfileFor(config.EnvProduction)
+	if !dev.Debug || dev.MailboxSize >= prod.MailboxSize || dev.ShutdownTimeout != prod.ShutdownTimeout {
+		t.Errorf("Unexpected development profile: %+v", dev)
+	}
+	if prod.Profiling || !prod.Metrics || prod.CallTimeout >= dev.CallTimeout {
+		t.Errorf("Unexpected production profile: %+v", prod)
+	}
+	if ProfileFor("unknown").Environment != config.EnvProduction {
+		t.Error("Expected unknown environments to get the production profile")
+	}
+
+	// Explicit configuration wins over the profile
+	path := filepath.Join(t.TempDir(), "sngo.yaml")
+	data := "app:\n  name: game\n  environment: production\nlog:\n  level: info\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := NewProfileLoader().LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Log.Level != config.LogLevelInfo {
+		t.Errorf("Expected explicit log level, got %s", cfg.Log.Level)
+	}
+	if cfg.Actor.MaxActors != prod.MaxActors || cfg.Monitor.Profiling.Enabled {
+		t.Errorf("Expected production defaults, got %+v %+v", cfg.Actor, cfg.Monitor)
+	}
+
+	// Explicit false and zero values win too, not only non-zero ones
+	data = "app:\n  name: game\n  environment: development\n  debug: false\nnetwork:\n  timeouts:\n    read: 7s\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err = NewProfileLoader().LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.App.Debug || cfg.Network.Timeouts.Read != 7*time.Second {
+		t.Errorf("Expected explicit values over the development profile, got debug=%v read=%v", cfg.App.Debug, cfg.Network.Timeouts.Read)
+	}
+	if cfg.Actor.DefaultMailboxSize != dev.MailboxSize {
+		t.Errorf("Expected development defaults for unset fields, got mailbox %d", cfg.Actor.DefaultMailboxSize)
+	}
+
+	app, err := NewApplicationBuilder().WithEnvironment(config.EnvStaging).Build()
+	if err != nil {
+		t.Fatalf("Failed to build application: %v", err)
+	}
+	if profile := app.(*DefaultApplication).Profile(); profile.Environment != config.EnvStaging {
+		t.Errorf("Expected staging profile, got %s", profile.Environment)
+	}
+}
+
+// ConfiguredService reads its values from the Start context
+type ConfiguredService struct {
+	TestService
+	config chatConfig
+	err    error
+}
+
+type chatConfig struct {
+	MaxRooms int    `json:"max_rooms"`
+	Motd     string `json:"motd"`
+}
+
+func (s *ConfiguredService) Start(ctx context.Context) error {
+	s.err = ServiceConfig(ctx, &s.config)
+	LoggerFrom(ctx).Debug("starting")
+	MetricsFrom(ctx).Add("rooms", 2)
+	return s.TestService.Start(ctx)
+}
+
+func TestServiceValues(t *testing.T) {
+	var logs strings.Builder
+	app := NewApplication().(*DefaultApplication)
+	lm := app.LifecycleManager().(*DefaultLifecycleManager)
+
+	cfg := config.DefaultConfig()
+	cfg.Custom["chat"] = map[string]interface{}{"max_rooms": 12, "motd": "hello"}
+	if err := app.Configure(cfg); err != nil {
+		t.Fatalf("Failed to configure: %v", err)
+	}
+	lm.SetServiceValues(slog.New(slog.NewTextHandler(&logs, nil)), func(service string) interface{} {
+		return cfg.Custom[service]
+	})
+
+	chat := &ConfiguredService{TestService: TestService{name: "chat"}}
+	other := &ConfiguredService{TestService: TestService{name: "other"}}
+	lm.Register("chat", chat)
+	lm.Register("other", other)
+
+	if err := lm.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer lm.Stop(context.Background())
+
+	if chat.err != nil || chat.config.MaxRooms != 12 || chat.config.Motd != "hello" {
+		t.Errorf("Expected typed config, got %+v (%v)", chat.config, chat.err)
+	}
+	if !errors.Is(other.err, ErrNoServiceConfig) {
+		t.Errorf("Expected ErrNoServiceConfig, got %v", other.err)
+	}
+	if got := lm.Metrics().Snapshot()["chat.rooms"]; got != 2 {
+		t.Errorf("Expected namespaced metric, got %v", lm.Metrics().Snapshot())
+	}
+
+	lm.scopes.scope("chat").Logger.Info("joined")
+	if !strings.Contains(logs.String(), "service=chat") {
+		t.Errorf("Expected logger tagged with the service, got %q", logs.String())
+	}
+}
+
+func TestConfigureLogFile(t *testing.T) {
+	app := NewApplication().(*DefaultApplication)
+	dir := t.TempDir()
+
+	cfg := config.DefaultConfig()
+	cfg.Log.Output = filepath.Join(dir, "first.log")
+	if err := app.Configure(cfg); err != nil {
+		t.Fatalf("Failed to configure: %v", err)
+	}
+	first := app.logFile.(*os.File)
+
+	// Reconfiguring closes the file of the previous configuration
+	cfg.Log.Output = filepath.Join(dir, "second.log")
+	if err := app.Configure(cfg); err != nil {
+		t.Fatalf("Failed to reconfigure: %v", err)
+	}
+	defer app.logFile.Close()
+	if _, err := first.Write([]byte("late")); err == nil {
+		t.Error("Expected the previous log file closed")
+	}
+
+	cfg.Log.Output = filepath.Join(dir, "missing", "app.log")
+	if err := app.Configure(cfg); err == nil {
+		t.Error("Expected an unopenable log output to fail the configuration")
+	}
+}
+
+// WarmingService warms up after a delay, or until cancelled if it hangs
+type WarmingService struct {
+	TestService
+	delay time.Duration
+	hang  bool
+	warm  bool
+}
+
+func (s *WarmingService) WarmUp(ctx context.Context) error {
+	if s.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	time.Sleep(s.delay)
+	s.warm = true
+	return nil
+}
+
+func TestLifecycleManagerWarmUp(t *testing.T) {
+	lm := NewLifecycleManager(NewContainer()).(*DefaultLifecycleManager)
+
+	var mu sync.Mutex
+	var events []LifecycleEvent
+	lm.AddListener(func(event LifecycleEvent) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	})
+
+	cache := &WarmingService{TestService: TestService{name: "cache"}, delay: 10 * time.Millisecond}
+	lm.Register("cache", cache)
+	lm.Register("plain", &TestService{name: "plain"})
+
+	if err := lm.WarmUp(context.Background(), time.Second); err == nil {
+		t.Error("Expected warm-up before start to fail")
+	}
+	if err := lm.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer lm.Stop(context.Background())
+
+	if err := lm.WarmUp(context.Background(), time.Second); err != nil {
+		t.Fatalf("Failed to warm up: %v", err)
+	}
+	if !cache.warm {
+		t.Error("Expected the cache to be warm")
+	}
+
+	// A hanging warm-up is cut off by the timeout
+	stuck := &WarmingService{TestService: TestService{name: "stuck"}, hang: true}
+	lm.services["stuck"] = stuck
+	lm.startOrder = append(lm.startOrder, "stuck")
+	start := time.Now()
+	if err := lm.WarmUp(context.Background(), 50*time.Millisecond); err != nil {
+		t.Fatalf("Expected a timed out warm-up not to fail: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected warm-up to end at the timeout, took %v", elapsed)
+	}
+
+	// Listeners are called asynchronously
+	var warmed []bool
+	for deadline := time.Now().Add(time.Second); len(warmed) < 2 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		warmed = nil
+		mu.Lock()
+		for _, event := range events {
+			if event.Type == "lifecycle.warmed" {
+				warmed = append(warmed, event.Data["timed_out"].(bool))
+			}
+		}
+		mu.Unlock()
+	}
+	if len(warmed) != 2 || warmed[0] || !warmed[1] {
+		t.Errorf("Expected one ready and one timed out warm-up, got %v", warmed)
+	}
+}
+
+// KVService is a store whose smoke check writes and reads back one key
+type KVService struct {
+	TestService
+	broken bool
+}
+
+func (s *KVService) SmokeChecks() []SmokeCheck {
+	return []SmokeCheck{{Name: "write-read", Check: func(ctx context.Context) error {
+		if s.broken {
+			return errors.New("read back a different value")
+		}
+		return nil
+	}}}
+}
+
+func TestLifecycleManagerSmokeTest(t *testing.T) {
+	lm := NewLifecycleManager(NewContainer()).(*DefaultLifecycleManager)
+	lm.Register("kv", &KVService{TestService: TestService{name: "kv"}})
+	lm.Register("gate", &TestService{name: "gate"}, "kv")
+
+	if err := lm.RegisterSmokeCheck("missing", SmokeCheck{Name: "x", Check: func(context.Context) error { return nil }}); err == nil {
+		t.Error("Expected a check of an unknown service to be rejected")
+	}
+	lm.RegisterSmokeCheck("gate", SmokeCheck{Name: "accept", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	lm.RegisterSmokeCheck("gate", SmokeCheck{Name: "panic", Check: func(context.Context) error { panic("boom") }})
+
+	if err := lm.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer lm.Stop(context.Background())
+
+	report, err := lm.SmokeTest(context.Background(), 50*time.Millisecond)
+	var smokeErr *SmokeTestError
+	if !errors.Is(err, ErrSmokeCheckFailed) || !errors.As(err, &smokeErr) {
+		t.Fatalf("Expected a smoke test error, got %v", err)
+	}
+	if len(report.Results) != 3 || report.Results[0].Service != "kv" || report.Results[0].Error != "" {
+		t.Fatalf("Expected kv to pass first, got %+v", report.Results)
+	}
+	failed := report.Failed()
+	if len(failed) != 2 || !strings.Contains(failed[0].Error, "deadline") || !strings.Contains(failed[1].Error, "panicked") {
+		t.Errorf("Expected the hanging and panicking checks to fail, got %+v", failed)
+	}
+	if !strings.Contains(report.String(), "gate/accept: FAILED") {
+		t.Errorf("Expected the report to name the failed check, got %s", report)
+	}
+}
+
+func TestApplicationSmokeCheckRollback(t *testing.T) {
+	kv := &KVService{TestService: TestService{name: "kv"}, broken: true}
+	app, err := NewApplicationBuilder().WithService("kv", kv).Build()
+	if err != nil {
+		t.Fatalf("Failed to build application: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- app.Run(context.Background()) }()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the application to abort its start")
+	}
+	if !errors.Is(err, ErrSmokeCheckFailed) || !strings.Contains(err.Error(), "kv/write-read") {
+		t.Errorf("Expected the failed smoke check reported, got %v", err)
+	}
+	if !kv.stopped {
+		t.Error("Expected the started services to be stopped")
+	}
+	if app.(*DefaultApplication).LifecycleManager().(*DefaultLifecycleManager).IsStarted() {
+		t.Error("Expected the lifecycle manager stopped")
+	}
+}
+
+func TestErrorSink(t *testing.T) {
+	var logs strings.Builder
+	var logMu sync.Mutex
+	logger := slog.New(slog.NewTextHandler(writerFunc(func(p []byte) (int, error) {
+		logMu.Lock()
+		defer logMu.Unlock()
+		return logs.Write(p)
+	}), nil))
+
+	posted := make(chan map[string]interface{}, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		posted <- payload
+	}))
+	defer webhook.Close()
+
+	cfg := DefaultErrorSinkConfig()
+	cfg.LogBurst = 2
+	cfg.Buffer = 3
+	cfg.WebhookURL = webhook.URL
+	sink := NewErrorSink(cfg, logger)
+	defer sink.Close()
+
+	var handled int
+	unregister := sink.Handle(func(core.AsyncError) { handled++ })
+
+	sink.Install()
+	defer core.SetErrorReporter(nil)
+	for i := 0; i < 4; i++ {
+		core.ReportError("network", "accept", core.SeverityWarning, fmt.Errorf("accept %d failed", i))
+	}
+	core.ReportError("network", "send", core.SeverityCritical, errors.New("sendLoop panic"))
+	unregister()
+	core.ReportError("core", "fsm", core.SeverityError, errors.New("timer failed"))
+
+	// Rate limiting is per source
+	logMu.Lock()
+	if n := strings.Count(logs.String(), "source=accept"); n != 2 {
+		t.Errorf("Expected 2 logged accept errors, got %d", n)
+	}
+	if !strings.Contains(logs.String(), "source=send") {
+		t.Error("Expected another source to be logged despite the accept burst")
+	}
+	logMu.Unlock()
+
+	if handled != 5 {
+		t.Errorf("Expected 5 handled errors, got %d", handled)
+	}
+	if first := <-sink.Errors(); first.Module != "network" || first.Source != "accept" {
+		t.Errorf("Unexpected first error %+v", first)
+	}
+
+	// Only errors of at least SeverityError reach the webhook
+	for _, want := range []string{"critical", "error"} {
+		select {
+		case payload := <-posted:
+			if payload["severity"] != want {
+				t.Errorf("Expected a %s webhook post, got %v", want, payload)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for the %s webhook post", want)
+		}
+	}
+
+	stats := sink.Statistics()
+	if stats.Reported["warning"] != 4 || stats.Suppressed != 2 || stats.Dropped != 3 {
+		t.Errorf("Unexpected statistics %+v", stats)
+	}
+}
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
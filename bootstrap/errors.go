@@ -0,0 +1,299 @@
+// Package bootstrap provides the application-wide sink of background errors
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/najoast/sngo/core"
+)
+
+// ErrorSinkConfig configures how background errors are logged and forwarded
+type ErrorSinkConfig struct {
+	// Buffer is the capacity of the Errors channel; errors nobody reads in
+	// time are dropped from it, never blocking the reporting goroutine
+	Buffer int `yaml:"buffer" json:"buffer"`
+
+	// LogBurst errors are logged per source and LogInterval; the rest are
+	// counted and summarized when the interval ends
+	LogBurst    int           `yaml:"log_burst" json:"log_burst"`
+	LogInterval time.Duration `yaml:"log_interval" json:"log_interval"`
+
+	// WebhookURL receives errors of at least WebhookSeverity as JSON posts
+	WebhookURL      string        `yaml:"webhook_url" json:"webhook_url"`
+	WebhookSeverity core.Severity `yaml:"webhook_severity" json:"webhook_severity"`
+	WebhookTimeout  time.Duration `yaml:"webhook_timeout" json:"webhook_timeout"`
+}
+
+// DefaultErrorSinkConfig returns the default error sink settings
+func DefaultErrorSinkConfig() ErrorSinkConfig {
+	return ErrorSinkConfig{
+		Buffer:          256,
+		LogBurst:        10,
+		LogInterval:     time.Minute,
+		WebhookSeverity: core.SeverityError,
+		WebhookTimeout:  5 * time.Second,
+	}
+}
+
+// ErrorSinkStatistics counts the errors that went through a sink
+type ErrorSinkStatistics struct {
+	Reported      map[string]int64 `json:"reported"`
+	Suppressed    int64            `json:"suppressed"`
+	Dropped       int64            `json:"dropped"`
+	WebhookSent   int64            `json:"webhook_sent"`
+	WebhookFailed int64            `json:"webhook_failed"`
+}
+
+// errorWindow rate-limits the log lines of one source
+type errorWindow struct {
+	start      time.Time
+	logged     int
+	suppressed int
+}
+
+// webhookPayload is the JSON posted to the webhook
+type webhookPayload struct {
+	Time     time.Time `json:"time"`
+	Module   string    `json:"module"`
+	Source   string    `json:"source"`
+	Severity string    `json:"severity"`
+	Error    string    `json:"error"`
+}
+
+// ErrorSink collects the errors background goroutines of all modules
+// report through core.ReportError. Each error is logged, subject to a
+// per-source rate limit, offered on the Errors channel, passed to the
+// registered handlers and optionally posted to a webhook.
+type ErrorSink struct {
+	config ErrorSinkConfig
+	logger *slog.Logger
+	errors chan core.AsyncError
+	client *http.Client
+
+	mu         sync.Mutex
+	handlers   map[int]func(core.AsyncError)
+	nextID     int
+	windows    map[string]*errorWindow
+	stats      ErrorSinkStatistics
+	webhook    chan core.AsyncError
+	cancelHook context.CancelFunc
+}
+
+// NewErrorSink creates an error sink logging to logger
+func NewErrorSink(config ErrorSinkConfig, logger *slog.Logger) *ErrorSink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if config.Buffer < 0 {
+		config.Buffer = 0
+	}
+	s := &ErrorSink{
+		config:   config,
+		logger:   logger,
+		errors:   make(chan core.AsyncError, config.Buffer),
+		client:   &http.Client{Timeout: config.WebhookTimeout},
+		handlers: make(map[int]func(core.AsyncError)),
+		windows:  make(map[string]*errorWindow),
+		stats:    ErrorSinkStatistics{Reported: make(map[string]int64)},
+	}
+	if config.WebhookURL != "" {
+		s.webhook = make(chan core.AsyncError, config.Buffer)
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancelHook = cancel
+		go s.forward(ctx)
+	}
+	return s
+}
+
+// Install makes the sink receive the errors of all modules
+func (s *ErrorSink) Install() {
+	core.SetErrorReporter(s.Report)
+}
+
+// Close stops forwarding to the webhook; it does not uninstall the sink
+func (s *ErrorSink) Close() {
+	if s.cancelHook != nil {
+		s.cancelHook()
+	}
+}
+
+// Errors returns the channel receiving reported errors
+func (s *ErrorSink) Errors() <-chan core.AsyncError {
+	return s.errors
+}
+
+// Handle registers a handler called with each reported error, on the
+// goroutine reporting it, so it must not block; the returned function
+// unregisters it
+func (s *ErrorSink) Handle(handler func(core.AsyncError)) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.handlers[id] = handler
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.handlers, id)
+	}
+}
+
+// Report takes an error; it is the core.ErrorReporter of the sink
+func (s *ErrorSink) Report(report core.AsyncError) {
+	if report.Time.IsZero() {
+		report.Time = time.Now()
+	}
+
+	s.mu.Lock()
+	s.stats.Reported[report.Severity.String()]++
+	log, suppressed := s.admitLogLocked(report)
+	handlers := make([]func(core.AsyncError), 0, len(s.handlers))
+	for _, handler := range s.handlers {
+		handlers = append(handlers, handler)
+	}
+	s.mu.Unlock()
+
+	if suppressed > 0 {
+		s.logger.Warn("background errors suppressed",
+			"module", report.Module, "source", report.Source, "count", suppressed)
+	}
+	if log {
+		s.logger.Log(context.Background(), severityLevel(report.Severity), "background error",
+			"module", report.Module, "source", report.Source,
+			"severity", report.Severity.String(), "error", report.Err)
+	}
+
+	select {
+	case s.errors <- report:
+	default:
+		s.mu.Lock()
+		s.stats.Dropped++
+		s.mu.Unlock()
+	}
+
+	for _, handler := range handlers {
+		handler(report)
+	}
+
+	if s.webhook != nil && report.Severity >= s.config.WebhookSeverity {
+		select {
+		case s.webhook <- report:
+		default:
+			s.mu.Lock()
+			s.stats.WebhookFailed++
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Statistics returns the sink counters
+func (s *ErrorSink) Statistics() ErrorSinkStatistics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.Reported = make(map[string]int64, len(s.stats.Reported))
+	for severity, count := range s.stats.Reported {
+		stats.Reported[severity] = count
+	}
+	return stats
+}
+
+// admitLogLocked decides whether to log report under the rate limit of
+// its source, returning the count suppressed in the window just ended;
+// callers hold mu
+func (s *ErrorSink) admitLogLocked(report core.AsyncError) (bool, int) {
+	if s.config.LogBurst <= 0 || s.config.LogInterval <= 0 {
+		return true, 0
+	}
+
+	key := report.Module + "/" + report.Source
+	window, exists := s.windows[key]
+	if !exists {
+		window = &errorWindow{start: report.Time}
+		s.windows[key] = window
+	}
+
+	suppressed := 0
+	if report.Time.Sub(window.start) >= s.config.LogInterval {
+		suppressed = window.suppressed
+		*window = errorWindow{start: report.Time}
+	}
+	if window.logged < s.config.LogBurst {
+		window.logged++
+		return true, suppressed
+	}
+	window.suppressed++
+	s.stats.Suppressed++
+	return false, suppressed
+}
+
+// forward posts queued errors to the webhook until ctx is done
+func (s *ErrorSink) forward(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case report := <-s.webhook:
+			err := s.post(ctx, report)
+			s.mu.Lock()
+			if err != nil {
+				s.stats.WebhookFailed++
+			} else {
+				s.stats.WebhookSent++
+			}
+			s.mu.Unlock()
+			if err != nil {
+				s.logger.Warn("error webhook failed", "error", err)
+			}
+		}
+	}
+}
+
+// post sends one error to the webhook
+func (s *ErrorSink) post(ctx context.Context, report core.AsyncError) error {
+	body, err := json.Marshal(webhookPayload{
+		Time:     report.Time,
+		Module:   report.Module,
+		Source:   report.Source,
+		Severity: report.Severity.String(),
+		Error:    fmt.Sprint(report.Err),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// severityLevel maps a severity to a log level
+func severityLevel(severity core.Severity) slog.Level {
+	switch {
+	case severity >= core.SeverityError:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
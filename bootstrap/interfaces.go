@@ -22,6 +22,13 @@ type Service interface {
 	Name() string
 }
 
+// Reloadable is implemented by services that can apply new configuration
+// without a restart
+type Reloadable interface {
+	// Reload re-reads configuration and applies it to the running service
+	Reload(ctx context.Context) error
+}
+
 // HealthStatus represents the health status of a service
 type HealthStatus struct {
 	// State indicates whether the service is healthy
@@ -101,6 +108,9 @@ type LifecycleManager interface {
 	// Health returns the health status of all services
 	Health(ctx context.Context) (map[string]HealthStatus, error)
 
+	// Reload reloads all Reloadable services in dependency order
+	Reload(ctx context.Context) error
+
 	// Services returns all registered service names
 	Services() []string
 
@@ -0,0 +1,190 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Leadership runs work only while the local node is cluster leader. The
+// cluster package provides it on top of its leader duties.
+type Leadership interface {
+	// RunWhileLeader calls lead whenever the local node becomes leader.
+	// ctx carries the fencing token of the term and is cancelled when
+	// leadership is lost; losing it waits up to stopTimeout for lead to
+	// return.
+	RunWhileLeader(name string, lead func(ctx context.Context) error, stopTimeout time.Duration) error
+
+	// StopRunningWhileLeader stops lead if running and forgets it
+	StopRunningWhileLeader(name string)
+}
+
+// leaderOnlyServices tracks the leader-only services of a lifecycle
+// manager and which of them currently run
+type leaderOnlyServices struct {
+	mu         sync.Mutex
+	leadership Leadership
+	services   map[string]bool
+	leading    map[string]bool
+}
+
+// SetLeadership sets the leadership leader-only services follow. It must
+// be set before starting when any service is leader-only.
+func (lm *DefaultLifecycleManager) SetLeadership(leadership Leadership) {
+	lm.leaderOnly.mu.Lock()
+	defer lm.leaderOnly.mu.Unlock()
+
+	lm.leaderOnly.leadership = leadership
+}
+
+// RegisterLeaderOnly registers a service that runs only while the local
+// node is cluster leader: starting the lifecycle manager hands it to the
+// leadership, which starts it on election and stops it when leadership is
+// lost. Its start context carries the fencing token of the term.
+// Regular services cannot depend on a leader-only service.
+func (lm *DefaultLifecycleManager) RegisterLeaderOnly(name string, service Service, deps ...string) error {
+	if err := lm.Register(name, service, deps...); err != nil {
+		return err
+	}
+
+	lm.leaderOnly.mu.Lock()
+	defer lm.leaderOnly.mu.Unlock()
+
+	if lm.leaderOnly.services == nil {
+		lm.leaderOnly.services = make(map[string]bool)
+		lm.leaderOnly.leading = make(map[string]bool)
+	}
+	lm.leaderOnly.services[name] = true
+	return nil
+}
+
+// IsLeaderOnly reports whether a service runs only on the leader
+func (lm *DefaultLifecycleManager) IsLeaderOnly(name string) bool {
+	lm.leaderOnly.mu.Lock()
+	defer lm.leaderOnly.mu.Unlock()
+
+	return lm.leaderOnly.services[name]
+}
+
+// IsLeading reports whether a leader-only service is currently running
+func (lm *DefaultLifecycleManager) IsLeading(name string) bool {
+	lm.leaderOnly.mu.Lock()
+	defer lm.leaderOnly.mu.Unlock()
+
+	return lm.leaderOnly.leading[name]
+}
+
+// inStandby reports whether a leader-only service waits for leadership
+func (lm *DefaultLifecycleManager) inStandby(name string) bool {
+	lm.leaderOnly.mu.Lock()
+	defer lm.leaderOnly.mu.Unlock()
+
+	return lm.leaderOnly.services[name] && !lm.leaderOnly.leading[name]
+}
+
+// checkLeaderOnly validates leader-only services before starting; callers
+// hold lm.mutex
+func (lm *DefaultLifecycleManager) checkLeaderOnly() error {
+	lm.leaderOnly.mu.Lock()
+	defer lm.leaderOnly.mu.Unlock()
+
+	if len(lm.leaderOnly.services) == 0 {
+		return nil
+	}
+	if lm.leaderOnly.leadership == nil {
+		return fmt.Errorf("leader-only services need a leadership, see SetLeadership")
+	}
+	for name, deps := range lm.dependencies {
+		if lm.leaderOnly.services[name] {
+			continue
+		}
+		for _, dep := range deps {
+			if lm.leaderOnly.services[dep] {
+				return fmt.Errorf("service %s cannot depend on leader-only service %s", name, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// followLeadership hands a leader-only service to the leadership; callers
+// hold lm.mutex
+func (lm *DefaultLifecycleManager) followLeadership(name string) error {
+	lm.leaderOnly.mu.Lock()
+	leadership := lm.leaderOnly.leadership
+	lm.leaderOnly.mu.Unlock()
+
+	return leadership.RunWhileLeader(name, lm.leadService(name, lm.services[name], lm.timeout), lm.timeout)
+}
+
+// leadService returns the function starting a leader-only service for a
+// term and stopping it once the term is over
+func (lm *DefaultLifecycleManager) leadService(name string, service Service, timeout time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := lm.startService(ctx, name); err != nil {
+			return err
+		}
+		lm.setLeading(name, true)
+		lm.broadcastEvent(LifecycleEvent{
+			Type:      "service.leader_started",
+			Service:   name,
+			Timestamp: time.Now(),
+		})
+
+		<-ctx.Done()
+
+		// Stop with the values of the term but without its cancellation
+		stopCtx, cancel := context.WithTimeout(lm.serviceContext(context.WithoutCancel(ctx), name), timeout)
+		err := service.Stop(stopCtx)
+		cancel()
+
+		lm.setLeading(name, false)
+		lm.broadcastEvent(LifecycleEvent{
+			Type:      "service.leader_stopped",
+			Service:   name,
+			Timestamp: time.Now(),
+			Error:     err,
+		})
+		return err
+	}
+}
+
+// setLeading records whether a leader-only service runs
+func (lm *DefaultLifecycleManager) setLeading(name string, leading bool) {
+	lm.leaderOnly.mu.Lock()
+	defer lm.leaderOnly.mu.Unlock()
+
+	lm.leaderOnly.leading[name] = leading
+}
+
+// stopService stops a started service; leader-only services are taken
+// back from the leadership, which stops them if running
+func (lm *DefaultLifecycleManager) stopService(ctx context.Context, name string) error {
+	lm.leaderOnly.mu.Lock()
+	leaderOnly, leadership := lm.leaderOnly.services[name], lm.leaderOnly.leadership
+	lm.leaderOnly.mu.Unlock()
+
+	if leaderOnly {
+		leadership.StopRunningWhileLeader(name)
+		return nil
+	}
+	return lm.services[name].Stop(ctx)
+}
+
+// standbyHealth returns the health of a leader-only service waiting for
+// leadership, and false for services that are running
+func (lm *DefaultLifecycleManager) standbyHealth(name string) (HealthStatus, bool) {
+	lm.leaderOnly.mu.Lock()
+	defer lm.leaderOnly.mu.Unlock()
+
+	if !lm.leaderOnly.services[name] || lm.leaderOnly.leading[name] {
+		return HealthStatus{}, false
+	}
+	return HealthStatus{
+		State:     HealthHealthy,
+		Message:   "standby: local node is not leader",
+		LastCheck: time.Now(),
+		Data:      map[string]interface{}{"leader_only": true, "leading": false},
+	}, true
+}
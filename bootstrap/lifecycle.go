@@ -4,6 +4,7 @@ package bootstrap
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"sort"
 	"sync"
 	"time"
@@ -40,6 +41,54 @@ type DefaultLifecycleManager struct {
 
 	// timeout for service operations
 	timeout time.Duration
+
+	// startPolicies holds per-service start retry policies
+	startPolicies map[string]StartPolicy
+
+	// defaultStartPolicy applies to services without an explicit policy
+	defaultStartPolicy StartPolicy
+
+	// slo tracks health check history and rolling availability
+	slo *sloTracker
+
+	// scopes builds the per-service values injected into service contexts
+	scopes *serviceValuesConfig
+
+	// smokeChecks holds the smoke checks registered per service
+	smokeChecks map[string][]SmokeCheck
+
+	// leaderOnly holds the services run only on the cluster leader
+	leaderOnly leaderOnlyServices
+}
+
+// StartPolicy controls how a service start is retried on transient failures
+type StartPolicy struct {
+	// Attempts is the total number of start attempts (values below 1 mean 1)
+	Attempts int
+
+	// Backoff is the delay before the first retry
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between retries (0 means no cap)
+	MaxBackoff time.Duration
+
+	// Multiplier grows the delay after each retry (values below 1 mean constant backoff)
+	Multiplier float64
+}
+
+// delay returns the backoff to wait before the given retry (1-based)
+func (p StartPolicy) delay(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry && p.Multiplier > 1; i++ {
+		d = time.Duration(float64(d) * p.Multiplier)
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
 }
 
 // NewLifecycleManager creates a new lifecycle manager
@@ -50,6 +99,12 @@ func NewLifecycleManager(container Container) LifecycleManager {
 		container:    container,
 		eventChan:    make(chan LifecycleEvent, 100),
 		timeout:      30 * time.Second,
+
+		startPolicies:      make(map[string]StartPolicy),
+		defaultStartPolicy: StartPolicy{Attempts: 1},
+
+		slo:    newSLOTracker(),
+		scopes: newServiceValuesConfig(),
 	}
 }
 
@@ -100,6 +155,9 @@ func (lm *DefaultLifecycleManager) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to calculate start order: %w", err)
 	}
+	if err := lm.checkLeaderOnly(); err != nil {
+		return err
+	}
 
 	lm.broadcastEvent(LifecycleEvent{
 		Type:      "lifecycle.starting",
@@ -109,47 +167,151 @@ func (lm *DefaultLifecycleManager) Start(ctx context.Context) error {
 
 	// Start services in order
 	for _, serviceName := range startOrder {
-		service := lm.services[serviceName]
+		start := lm.startService
+		if lm.IsLeaderOnly(serviceName) {
+			start = func(ctx context.Context, name string) error { return lm.followLeadership(name) }
+		}
+		if err := start(ctx, serviceName); err != nil {
+			lm.rollbackStart(ctx, serviceName, err)
+			return fmt.Errorf("failed to start service %s: %w", serviceName, err)
+		}
+
+		lm.startOrder = append(lm.startOrder, serviceName)
+
+		lm.broadcastEvent(LifecycleEvent{
+			Type:      "service.started",
+			Service:   serviceName,
+			Timestamp: time.Now(),
+		})
+	}
+
+	lm.started = true
 
+	lm.broadcastEvent(LifecycleEvent{
+		Type:      "lifecycle.started",
+		Timestamp: time.Now(),
+	})
+
+	return nil
+}
+
+// startService starts a single service, retrying according to its start policy
+func (lm *DefaultLifecycleManager) startService(ctx context.Context, serviceName string) error {
+	service := lm.services[serviceName]
+	policy := lm.startPolicyFor(serviceName)
+
+	attempts := policy.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
 		lm.broadcastEvent(LifecycleEvent{
 			Type:      "service.starting",
 			Service:   serviceName,
 			Timestamp: time.Now(),
+			Data:      map[string]interface{}{"attempt": attempt},
 		})
 
 		// Create context with timeout
-		startCtx, cancel := context.WithTimeout(ctx, lm.timeout)
+		startCtx, cancel := context.WithTimeout(lm.serviceContext(ctx, serviceName), lm.timeout)
+		err = service.Start(startCtx)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
 
-		err := service.Start(startCtx)
+		lm.broadcastEvent(LifecycleEvent{
+			Type:      "service.start_failed",
+			Service:   serviceName,
+			Timestamp: time.Now(),
+			Error:     err,
+			Data:      map[string]interface{}{"attempt": attempt, "attempts": attempts},
+		})
+
+		if attempt == attempts {
+			break
+		}
+
+		delay := policy.delay(attempt)
+		lm.broadcastEvent(LifecycleEvent{
+			Type:      "service.start_retry",
+			Service:   serviceName,
+			Timestamp: time.Now(),
+			Error:     err,
+			Data:      map[string]interface{}{"attempt": attempt + 1, "backoff": delay},
+		})
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return fmt.Errorf("%w (retry aborted: %v)", err, ctx.Err())
+			}
+		}
+	}
+
+	return err
+}
+
+// rollbackStart stops already-started services in reverse order after a failed startup
+func (lm *DefaultLifecycleManager) rollbackStart(ctx context.Context, failed string, cause error) {
+	rollback := make([]string, 0, len(lm.startOrder))
+	for i := len(lm.startOrder) - 1; i >= 0; i-- {
+		rollback = append(rollback, lm.startOrder[i])
+	}
+
+	lm.broadcastEvent(LifecycleEvent{
+		Type:      "lifecycle.rollback_starting",
+		Service:   failed,
+		Timestamp: time.Now(),
+		Error:     cause,
+		Data:      map[string]interface{}{"order": rollback},
+	})
+
+	// Use a fresh context so rollback still runs if the start context expired
+	rollbackCtx := context.Background()
+	if ctx.Err() == nil {
+		rollbackCtx = ctx
+	}
+
+	var failures []string
+	for _, serviceName := range rollback {
+		stopCtx, cancel := context.WithTimeout(lm.serviceContext(rollbackCtx, serviceName), lm.timeout)
+		err := lm.stopService(stopCtx, serviceName)
 		cancel()
 
 		if err != nil {
+			failures = append(failures, serviceName)
 			lm.broadcastEvent(LifecycleEvent{
-				Type:      "service.start_failed",
+				Type:      "service.rollback_failed",
 				Service:   serviceName,
 				Timestamp: time.Now(),
 				Error:     err,
 			})
-			return fmt.Errorf("failed to start service %s: %w", serviceName, err)
+			continue
 		}
 
-		lm.startOrder = append(lm.startOrder, serviceName)
-
 		lm.broadcastEvent(LifecycleEvent{
-			Type:      "service.started",
+			Type:      "service.rolled_back",
 			Service:   serviceName,
 			Timestamp: time.Now(),
 		})
 	}
 
-	lm.started = true
+	lm.startOrder = nil
 
 	lm.broadcastEvent(LifecycleEvent{
-		Type:      "lifecycle.started",
+		Type:      "lifecycle.rolled_back",
+		Service:   failed,
 		Timestamp: time.Now(),
+		Error:     cause,
+		Data:      map[string]interface{}{"stopped": len(rollback) - len(failures), "failed": failures},
 	})
-
-	return nil
 }
 
 // Stop stops all services in reverse dependency order
@@ -185,8 +347,6 @@ func (lm *DefaultLifecycleManager) Stop(ctx context.Context) error {
 	var lastError error
 
 	for _, serviceName := range stopOrder {
-		service := lm.services[serviceName]
-
 		lm.broadcastEvent(LifecycleEvent{
 			Type:      "service.stopping",
 			Service:   serviceName,
@@ -194,9 +354,9 @@ func (lm *DefaultLifecycleManager) Stop(ctx context.Context) error {
 		})
 
 		// Create context with timeout
-		stopCtx, cancel := context.WithTimeout(ctx, lm.timeout)
+		stopCtx, cancel := context.WithTimeout(lm.serviceContext(ctx, serviceName), lm.timeout)
 
-		err := service.Stop(stopCtx)
+		err := lm.stopService(stopCtx, serviceName)
 		cancel()
 
 		if err != nil {
@@ -236,6 +396,11 @@ func (lm *DefaultLifecycleManager) Health(ctx context.Context) (map[string]Healt
 	health := make(map[string]HealthStatus)
 
 	for name, service := range lm.services {
+		if status, standby := lm.standbyHealth(name); standby {
+			health[name] = lm.recordHealth(name, status)
+			continue
+		}
+
 		// Create context with timeout
 		healthCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 
@@ -243,18 +408,67 @@ func (lm *DefaultLifecycleManager) Health(ctx context.Context) (map[string]Healt
 		cancel()
 
 		if err != nil {
-			health[name] = HealthStatus{
+			status = HealthStatus{
 				State:   HealthUnhealthy,
 				Message: err.Error(),
 			}
-		} else {
-			health[name] = status
 		}
+		health[name] = lm.recordHealth(name, status)
 	}
 
 	return health, nil
 }
 
+// Reload reloads all Reloadable services in start order. A failing service
+// does not prevent the others from reloading; the last error is returned
+func (lm *DefaultLifecycleManager) Reload(ctx context.Context) error {
+	lm.mutex.RLock()
+	if !lm.started {
+		lm.mutex.RUnlock()
+		return fmt.Errorf("lifecycle manager not started")
+	}
+	order := append([]string(nil), lm.startOrder...)
+	services := make(map[string]Service, len(order))
+	for _, name := range order {
+		if !lm.inStandby(name) {
+			services[name] = lm.services[name]
+		}
+	}
+	timeout := lm.timeout
+	lm.mutex.RUnlock()
+
+	var lastError error
+	for _, name := range order {
+		reloadable, ok := services[name].(Reloadable)
+		if !ok {
+			continue
+		}
+
+		reloadCtx, cancel := context.WithTimeout(lm.serviceContext(ctx, name), timeout)
+		err := reloadable.Reload(reloadCtx)
+		cancel()
+
+		if err != nil {
+			lastError = fmt.Errorf("failed to reload service %s: %w", name, err)
+			lm.broadcastEvent(LifecycleEvent{
+				Type:      "service.reload_failed",
+				Service:   name,
+				Timestamp: time.Now(),
+				Error:     err,
+			})
+			continue
+		}
+
+		lm.broadcastEvent(LifecycleEvent{
+			Type:      "service.reloaded",
+			Service:   name,
+			Timestamp: time.Now(),
+		})
+	}
+
+	return lastError
+}
+
 // Services returns all registered service names
 func (lm *DefaultLifecycleManager) Services() []string {
 	lm.mutex.RLock()
@@ -368,6 +582,30 @@ func (lm *DefaultLifecycleManager) SetTimeout(timeout time.Duration) {
 	lm.timeout = timeout
 }
 
+// SetStartPolicy sets the start retry policy for a single service
+func (lm *DefaultLifecycleManager) SetStartPolicy(name string, policy StartPolicy) {
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+
+	lm.startPolicies[name] = policy
+}
+
+// SetDefaultStartPolicy sets the start retry policy used by services without their own
+func (lm *DefaultLifecycleManager) SetDefaultStartPolicy(policy StartPolicy) {
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+
+	lm.defaultStartPolicy = policy
+}
+
+// startPolicyFor returns the effective start policy for a service
+func (lm *DefaultLifecycleManager) startPolicyFor(name string) StartPolicy {
+	if policy, exists := lm.startPolicies[name]; exists {
+		return policy
+	}
+	return lm.defaultStartPolicy
+}
+
 // IsStarted returns true if the lifecycle manager has been started
 func (lm *DefaultLifecycleManager) IsStarted() bool {
 	lm.mutex.RLock()
@@ -408,3 +646,20 @@ func (lm *DefaultLifecycleManager) GetDependencies(name string) ([]string, bool)
 	copy(result, deps)
 	return result, true
 }
+
+// SetServiceValues sets the base logger and the configuration source of
+// the values injected into service contexts; configFor returns the raw
+// configuration section of a service, nil if it has none
+func (lm *DefaultLifecycleManager) SetServiceValues(logger *slog.Logger, configFor func(service string) interface{}) {
+	lm.scopes.set(logger, configFor)
+}
+
+// Metrics returns the registry holding the metrics of all services
+func (lm *DefaultLifecycleManager) Metrics() *MetricsRegistry {
+	return lm.scopes.metrics
+}
+
+// serviceContext returns ctx carrying the values of a service
+func (lm *DefaultLifecycleManager) serviceContext(ctx context.Context, name string) context.Context {
+	return withServiceValues(ctx, lm.scopes.scope(name))
+}
@@ -0,0 +1,192 @@
+// Package bootstrap provides per-environment tuning profiles
+package bootstrap
+
+import (
+	"time"
+
+	"github.com/najoast/sngo/config"
+	"github.com/najoast/sngo/core"
+	"github.com/najoast/sngo/network"
+)
+
+// TuningProfile is a preset of defaults across modules for one deployment
+// environment, so an application behaves sensibly before any knob is
+// tuned. Explicit configuration always wins over the profile.
+type TuningProfile struct {
+	Environment config.Environment
+
+	// Logging
+	LogLevel config.LogLevel
+	Debug    bool
+
+	// Actor system
+	MaxActors      int
+	MailboxSize    int
+	ProcessTimeout time.Duration
+	CallTimeout    time.Duration
+
+	// Network
+	MaxConnections int
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+
+	// ShutdownTimeout bounds a graceful application stop; every profile
+	// keeps the 30s default
+	ShutdownTimeout time.Duration
+
+	// WarmUpTimeout bounds the warm-up after start, during which the
+	// network server admits health checks and WarmUpTrickle messages per
+	// second only; 0 skips warm-up
+	WarmUpTimeout time.Duration
+	WarmUpTrickle float64
+
+	// SmokeTimeout bounds each smoke check run after start; a failed
+	// check aborts the start
+	SmokeTimeout time.Duration
+
+	// Monitoring
+	Metrics   bool
+	Profiling bool
+}
+
+// ProfileFor returns the built-in profile of an environment; unknown
+// environments get the production profile, the safest one
+func ProfileFor(env config.Environment) TuningProfile {
+	switch env {
+	case config.EnvDevelopment:
+		// Verbose and small, so leaks and overloads show up early
+		return TuningProfile{
+			Environment:     env,
+			LogLevel:        config.LogLevelDebug,
+			Debug:           true,
+			MaxActors:       1000,
+			MailboxSize:     100,
+			ProcessTimeout:  30 * time.Second,
+			CallTimeout:     30 * time.Second,
+			MaxConnections:  100,
+			ReadTimeout:     5 * time.Minute,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     30 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+			WarmUpTimeout:   0,
+			SmokeTimeout:    5 * time.Second,
+			Metrics:         true,
+			Profiling:       true,
+		}
+	case config.EnvTesting:
+		// Short timeouts keep failing tests fast
+		return TuningProfile{
+			Environment:     env,
+			LogLevel:        config.LogLevelInfo,
+			Debug:           true,
+			MaxActors:       1000,
+			MailboxSize:     100,
+			ProcessTimeout:  5 * time.Second,
+			CallTimeout:     5 * time.Second,
+			MaxConnections:  100,
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+			WarmUpTimeout:   0,
+			SmokeTimeout:    5 * time.Second,
+			Metrics:         false,
+			Profiling:       false,
+		}
+	case config.EnvStaging:
+		// Production sizing, with the diagnostics to rehearse failures
+		return TuningProfile{
+			Environment:     env,
+			LogLevel:        config.LogLevelInfo,
+			Debug:           false,
+			MaxActors:       10000,
+			MailboxSize:     1000,
+			ProcessTimeout:  10 * time.Second,
+			CallTimeout:     10 * time.Second,
+			MaxConnections:  10000,
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     5 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+			WarmUpTimeout:   30 * time.Second,
+			SmokeTimeout:    10 * time.Second,
+			Metrics:         true,
+			Profiling:       true,
+		}
+	default:
+		// Conservative timeouts, metrics on, no debug surface
+		return TuningProfile{
+			Environment:     config.EnvProduction,
+			LogLevel:        config.LogLevelWarn,
+			Debug:           false,
+			MaxActors:       100000,
+			MailboxSize:     1000,
+			ProcessTimeout:  5 * time.Second,
+			CallTimeout:     5 * time.Second,
+			MaxConnections:  10000,
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    5 * time.Second,
+			IdleTimeout:     5 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+			WarmUpTimeout:   30 * time.Second,
+			SmokeTimeout:    10 * time.Second,
+			Metrics:         true,
+			Profiling:       false,
+		}
+	}
+}
+
+// Defaults returns the default configuration adjusted by the profile
+func (p TuningProfile) Defaults() *config.Config {
+	cfg := config.DefaultConfig()
+
+	cfg.App.Environment = p.Environment
+	cfg.App.Debug = p.Debug
+	cfg.Log.Level = p.LogLevel
+	if !p.Debug {
+		cfg.Log.Format = "json"
+		cfg.Log.Color = false
+	}
+
+	cfg.Actor.MaxActors = p.MaxActors
+	cfg.Actor.DefaultMailboxSize = p.MailboxSize
+	cfg.Actor.Timeouts.Call = p.CallTimeout
+
+	cfg.Network.Limits.MaxConnections = p.MaxConnections
+	cfg.Network.Timeouts.Read = p.ReadTimeout
+	cfg.Network.Timeouts.Write = p.WriteTimeout
+	cfg.Network.Timeouts.Idle = p.IdleTimeout
+
+	cfg.Monitor.Enabled = p.Metrics
+	cfg.Monitor.HTTP.Enabled = p.Metrics
+	cfg.Monitor.Profiling.Enabled = p.Profiling
+	cfg.Monitor.Profiling.CPU = p.Profiling
+	cfg.Monitor.Profiling.Memory = p.Profiling
+	return cfg
+}
+
+// ActorOptions returns the default actor options of the profile
+func (p TuningProfile) ActorOptions() core.ActorOptions {
+	opts := core.DefaultActorOptions()
+	opts.MailboxSize = p.MailboxSize
+	opts.ProcessTimeout = p.ProcessTimeout
+	return opts
+}
+
+// NetworkConfig returns the default network configuration of the profile
+func (p TuningProfile) NetworkConfig() *network.NetworkConfig {
+	cfg := network.DefaultNetworkConfig()
+	cfg.MaxConnections = p.MaxConnections
+	cfg.ReadTimeout = p.ReadTimeout
+	cfg.WriteTimeout = p.WriteTimeout
+	return cfg
+}
+
+// NewProfileLoader returns a configuration loader filling what a
+// configuration file leaves unset from the profile of its environment
+func NewProfileLoader() *config.Loader {
+	return config.NewLoader().SetProfiles(func(env config.Environment) *config.Config {
+		return ProfileFor(env).Defaults()
+	})
+}
@@ -0,0 +1,300 @@
+// Package bootstrap provides per-service context values
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/najoast/sngo/config"
+)
+
+// ErrNoServiceConfig is returned by ServiceConfig when the configuration
+// has no section for the service
+var ErrNoServiceConfig = errors.New("no configuration for service")
+
+// ServiceValues holds the values injected into the contexts a service's
+// Start, Stop and Reload are called with
+type ServiceValues struct {
+	// Name is the name the service was registered under
+	Name string
+
+	// Logger is pre-tagged with the service name
+	Logger *slog.Logger
+
+	// Config is the raw configuration section of the service, nil if none
+	Config interface{}
+
+	// Metrics is the metrics namespace of the service
+	Metrics *Metrics
+}
+
+// valuesKey is the context key of the service values
+type valuesKey struct{}
+
+// withServiceValues returns ctx carrying the values of a service
+func withServiceValues(ctx context.Context, scope *ServiceValues) context.Context {
+	if scope == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, valuesKey{}, scope)
+}
+
+// ValuesFrom returns the service values carried by ctx
+func ValuesFrom(ctx context.Context) (*ServiceValues, bool) {
+	scope, ok := ctx.Value(valuesKey{}).(*ServiceValues)
+	return scope, ok
+}
+
+// LoggerFrom returns the logger of the service ctx belongs to, or the
+// default logger outside a service
+func LoggerFrom(ctx context.Context) *slog.Logger {
+	if scope, ok := ValuesFrom(ctx); ok && scope.Logger != nil {
+		return scope.Logger
+	}
+	return slog.Default()
+}
+
+// MetricsFrom returns the metrics namespace of the service ctx belongs
+// to; outside a service it returns a namespace that is not exported
+func MetricsFrom(ctx context.Context) *Metrics {
+	if scope, ok := ValuesFrom(ctx); ok && scope.Metrics != nil {
+		return scope.Metrics
+	}
+	return NewMetricsRegistry().Namespace("")
+}
+
+// ServiceConfig decodes the configuration section of the service ctx
+// belongs to into target, a pointer to the typed configuration of the
+// service. It returns ErrNoServiceConfig when there is none, so services
+// can fall back to their defaults.
+func ServiceConfig(ctx context.Context, target interface{}) error {
+	scope, ok := ValuesFrom(ctx)
+	if !ok || scope.Config == nil {
+		return ErrNoServiceConfig
+	}
+
+	// Sections are decoded from YAML or JSON into generic maps, so they
+	// are converted through JSON rather than asserted
+	data, err := json.Marshal(scope.Config)
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration of service %s: %w", scope.Name, err)
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to decode configuration of service %s: %w", scope.Name, err)
+	}
+	return nil
+}
+
+// serviceValuesConfig builds the values of the services of a lifecycle manager
+type serviceValuesConfig struct {
+	mu        sync.RWMutex
+	logger    *slog.Logger
+	configFor func(service string) interface{}
+	metrics   *MetricsRegistry
+	scopes    map[string]*ServiceValues
+}
+
+func newServiceValuesConfig() *serviceValuesConfig {
+	return &serviceValuesConfig{
+		metrics: NewMetricsRegistry(),
+		scopes:  make(map[string]*ServiceValues),
+	}
+}
+
+// scope returns the values of a service, created on first use
+func (sc *serviceValuesConfig) scope(name string) *ServiceValues {
+	sc.mu.RLock()
+	scope, ok := sc.scopes[name]
+	sc.mu.RUnlock()
+	if ok {
+		return scope
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if scope, ok := sc.scopes[name]; ok {
+		return scope
+	}
+	logger := sc.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	scope = &ServiceValues{
+		Name:    name,
+		Logger:  logger.With("service", name),
+		Metrics: sc.metrics.Namespace(name),
+	}
+	if sc.configFor != nil {
+		scope.Config = sc.configFor(name)
+	}
+	sc.scopes[name] = scope
+	return scope
+}
+
+// set replaces the base logger and configuration source; values are
+// rebuilt on next use, so a reload picks up the new configuration
+func (sc *serviceValuesConfig) set(logger *slog.Logger, configFor func(service string) interface{}) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.logger = logger
+	sc.configFor = configFor
+	sc.scopes = make(map[string]*ServiceValues)
+}
+
+// NewLogger builds a structured logger from the log configuration. When
+// it logs to a file, the returned closer closes that file; it is nil for
+// stdout and stderr.
+func NewLogger(cfg config.LogConfig) (*slog.Logger, io.Closer, error) {
+	var out io.Writer = os.Stdout
+	var closer io.Closer
+	switch cfg.Output {
+	case "", "stdout":
+	case "stderr":
+		out = os.Stderr
+	default:
+		f, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log output: %w", err)
+		}
+		out, closer = f, f
+	}
+
+	opts := &slog.HandlerOptions{Level: slogLevel(cfg.Level)}
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+
+	logger := slog.New(handler)
+	if len(cfg.Fields) > 0 {
+		keys := make([]string, 0, len(cfg.Fields))
+		for key := range cfg.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		args := make([]any, 0, 2*len(keys))
+		for _, key := range keys {
+			args = append(args, key, cfg.Fields[key])
+		}
+		logger = logger.With(args...)
+	}
+	return logger, closer, nil
+}
+
+// slogLevel maps a configured log level; trace is logged as debug and
+// fatal as error
+func slogLevel(level config.LogLevel) slog.Level {
+	switch level {
+	case config.LogLevelTrace, config.LogLevelDebug:
+		return slog.LevelDebug
+	case config.LogLevelWarn:
+		return slog.LevelWarn
+	case config.LogLevelError, config.LogLevelFatal:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// MetricsRegistry holds the metrics of all service namespaces
+type MetricsRegistry struct {
+	mu     sync.RWMutex
+	values map[string]*uint64 // float64 bits, atomic
+}
+
+// NewMetricsRegistry creates an empty registry
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{values: make(map[string]*uint64)}
+}
+
+// Namespace returns the handle of a namespace; metric names are prefixed
+// with "<namespace>."
+func (r *MetricsRegistry) Namespace(namespace string) *Metrics {
+	return &Metrics{namespace: namespace, registry: r}
+}
+
+// Snapshot returns the current value of every metric by full name
+func (r *MetricsRegistry) Snapshot() map[string]float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make(map[string]float64, len(r.values))
+	for name, bits := range r.values {
+		out[name] = math.Float64frombits(atomic.LoadUint64(bits))
+	}
+	return out
+}
+
+// value returns the storage of a metric, created on first use
+func (r *MetricsRegistry) value(name string) *uint64 {
+	r.mu.RLock()
+	bits, ok := r.values[name]
+	r.mu.RUnlock()
+	if ok {
+		return bits
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if bits, ok := r.values[name]; ok {
+		return bits
+	}
+	bits = new(uint64)
+	r.values[name] = bits
+	return bits
+}
+
+// Metrics is the metrics handle of one namespace
+type Metrics struct {
+	namespace string
+	registry  *MetricsRegistry
+}
+
+// Namespace returns the namespace of the handle
+func (m *Metrics) Namespace() string {
+	return m.namespace
+}
+
+// Add adds delta to a counter
+func (m *Metrics) Add(name string, delta float64) {
+	bits := m.registry.value(m.fullName(name))
+	for {
+		old := atomic.LoadUint64(bits)
+		sum := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(bits, old, sum) {
+			return
+		}
+	}
+}
+
+// Set sets a gauge
+func (m *Metrics) Set(name string, value float64) {
+	atomic.StoreUint64(m.registry.value(m.fullName(name)), math.Float64bits(value))
+}
+
+// Get returns the current value of a metric
+func (m *Metrics) Get(name string) float64 {
+	return math.Float64frombits(atomic.LoadUint64(m.registry.value(m.fullName(name))))
+}
+
+func (m *Metrics) fullName(name string) string {
+	if m.namespace == "" {
+		return name
+	}
+	return strings.Join([]string{m.namespace, name}, ".")
+}
@@ -0,0 +1,187 @@
+package bootstrap
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/najoast/sngo/core"
+)
+
+// SignalConfig maps OS signals to application actions. Every action is also
+// available programmatically (TriggerShutdown, TriggerReload, TriggerDump) so
+// platforms without SIGHUP/SIGUSR1 can reach the same behavior
+type SignalConfig struct {
+	// ShutdownSignals start a graceful shutdown
+	ShutdownSignals []os.Signal
+
+	// ReloadSignals reload all Reloadable services
+	ReloadSignals []os.Signal
+
+	// DumpSignals write a diagnostic bundle to DumpDir
+	DumpSignals []os.Signal
+
+	// DumpDir is the directory diagnostic bundles are written to
+	DumpDir string
+}
+
+// DefaultSignalConfig returns the default signal mapping: SIGINT/SIGTERM shut
+// down, and on Unix SIGHUP reloads and SIGUSR1 dumps diagnostics
+func DefaultSignalConfig() SignalConfig {
+	return SignalConfig{
+		ShutdownSignals: []os.Signal{os.Interrupt, syscall.SIGTERM},
+		ReloadSignals:   defaultReloadSignals(),
+		DumpSignals:     defaultDumpSignals(),
+		DumpDir:         os.TempDir(),
+	}
+}
+
+// signals returns every signal the config listens for
+func (c SignalConfig) signals() []os.Signal {
+	all := make([]os.Signal, 0, len(c.ShutdownSignals)+len(c.ReloadSignals)+len(c.DumpSignals))
+	all = append(all, c.ShutdownSignals...)
+	all = append(all, c.ReloadSignals...)
+	return append(all, c.DumpSignals...)
+}
+
+// containsSignal returns true if sig is in signals
+func containsSignal(signals []os.Signal, sig os.Signal) bool {
+	for _, s := range signals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSignalConfig replaces the signal mapping; it takes effect on the next Run
+func (app *DefaultApplication) SetSignalConfig(config SignalConfig) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+
+	app.signalConfig = config
+}
+
+// SignalConfig returns the current signal mapping
+func (app *DefaultApplication) SignalConfig() SignalConfig {
+	app.mutex.RLock()
+	defer app.mutex.RUnlock()
+
+	return app.signalConfig
+}
+
+// handleSignal performs the action mapped to sig and reports whether the
+// application should shut down
+func (app *DefaultApplication) handleSignal(ctx context.Context, config SignalConfig, sig os.Signal) bool {
+	switch {
+	case containsSignal(config.ReloadSignals, sig):
+		fmt.Printf("Received %v, reloading configuration...\n", sig)
+		if err := app.TriggerReload(ctx); err != nil {
+			core.ReportError("bootstrap", "signals", core.SeverityError, fmt.Errorf("reload failed: %w", err))
+		}
+		return false
+	case containsSignal(config.DumpSignals, sig):
+		path, err := app.TriggerDump()
+		if err != nil {
+			core.ReportError("bootstrap", "signals", core.SeverityError, fmt.Errorf("diagnostic dump failed: %w", err))
+		} else {
+			fmt.Printf("Received %v, wrote diagnostics to %s\n", sig, path)
+		}
+		return false
+	default:
+		fmt.Printf("Received %v, starting graceful shutdown...\n", sig)
+		return true
+	}
+}
+
+// TriggerShutdown starts a graceful shutdown of a running application, as if
+// a shutdown signal had been received
+func (app *DefaultApplication) TriggerShutdown() {
+	select {
+	case app.shutdownChan <- syscall.SIGTERM:
+	default:
+		// A shutdown is already pending
+	}
+}
+
+// TriggerReload reloads all Reloadable services
+func (app *DefaultApplication) TriggerReload(ctx context.Context) error {
+	return app.lifecycleManager.Reload(ctx)
+}
+
+// TriggerDump writes a diagnostic bundle to the configured dump directory and
+// returns its path
+func (app *DefaultApplication) TriggerDump() (string, error) {
+	dir := app.SignalConfig().DumpDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	name := fmt.Sprintf("sngo-diag-%d-%s.txt", os.Getpid(), time.Now().Format("20060102-150405.000"))
+	path := filepath.Join(dir, name)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create diagnostic file: %w", err)
+	}
+	defer file.Close()
+
+	if err := app.WriteDiagnostics(file); err != nil {
+		return "", err
+	}
+	return path, file.Close()
+}
+
+// WriteDiagnostics writes service health, actor stats, active connections
+// and all goroutine stacks to w
+func (app *DefaultApplication) WriteDiagnostics(w io.Writer) error {
+	app.mutex.RLock()
+	actorSystem := app.actorSystem
+	networkServer := app.networkServer
+	app.mutex.RUnlock()
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "SNGO diagnostics %s pid=%d goroutines=%d\n",
+		time.Now().Format(time.RFC3339), os.Getpid(), runtime.NumGoroutine())
+
+	fmt.Fprintf(out, "\n== Services ==\n")
+	health, _ := app.lifecycleManager.Health(context.Background())
+	names := make([]string, 0, len(health))
+	for name := range health {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "%s\t%s\t%s\n", name, health[name].State, health[name].Message)
+	}
+
+	fmt.Fprintf(out, "\n== Actors ==\n")
+	if actorSystem != nil {
+		for _, stats := range actorSystem.Stats() {
+			fmt.Fprintf(out, "%v\t%s\tstate=%v\tprocessed=%d\tmailbox=%d\n",
+				stats.ID, stats.Name, stats.State, stats.MessagesProcessed, stats.MailboxSize)
+		}
+	}
+
+	fmt.Fprintf(out, "\n== Connections ==\n")
+	if networkServer != nil {
+		for _, conn := range networkServer.GetActiveConnections() {
+			fmt.Fprintln(out, conn.GetStatistics().String())
+		}
+	}
+
+	fmt.Fprintf(out, "\n== Goroutines ==\n")
+	if err := pprof.Lookup("goroutine").WriteTo(out, 1); err != nil {
+		return fmt.Errorf("failed to write goroutine profile: %w", err)
+	}
+
+	return out.Flush()
+}
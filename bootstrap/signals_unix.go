@@ -0,0 +1,29 @@
+//go:build !windows
+
+package bootstrap
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultReloadSignals returns the platform reload signals
+func defaultReloadSignals() []os.Signal {
+	return []os.Signal{syscall.SIGHUP}
+}
+
+// defaultDumpSignals returns the platform diagnostic dump signals
+func defaultDumpSignals() []os.Signal {
+	return []os.Signal{syscall.SIGUSR1}
+}
+
+// defaultUpgradeSignals returns the signals that make a supervisor upgrade
+// its child
+func defaultUpgradeSignals() []os.Signal {
+	return []os.Signal{syscall.SIGUSR2}
+}
+
+// terminateProcess asks a process to shut down gracefully
+func terminateProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
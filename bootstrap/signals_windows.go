@@ -0,0 +1,27 @@
+//go:build windows
+
+package bootstrap
+
+import "os"
+
+// defaultReloadSignals returns nil: Windows has no SIGHUP, use TriggerReload
+func defaultReloadSignals() []os.Signal {
+	return nil
+}
+
+// defaultDumpSignals returns nil: Windows has no SIGUSR1, use TriggerDump
+func defaultDumpSignals() []os.Signal {
+	return nil
+}
+
+// defaultUpgradeSignals returns nil: Windows has no SIGUSR2, use
+// Supervisor.Upgrade
+func defaultUpgradeSignals() []os.Signal {
+	return nil
+}
+
+// terminateProcess returns an error: Windows cannot deliver SIGTERM, so the
+// supervisor kills the child instead
+func terminateProcess(process *os.Process) error {
+	return os.ErrInvalid
+}
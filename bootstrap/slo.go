@@ -0,0 +1,285 @@
+// Package bootstrap provides service availability tracking
+package bootstrap
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxSLOSegments bounds the health history kept per service; a flapping
+// service drops its oldest transitions first
+const maxSLOSegments = 10000
+
+// SLOPolicy sets the availability objective of a service
+type SLOPolicy struct {
+	// Target is the availability objective, e.g. 0.999 (0 disables breach events)
+	Target float64
+
+	// Windows are the rolling windows availability is computed over
+	Windows []time.Duration
+}
+
+// DefaultSLOPolicy returns the default policy: 1h and 24h windows without a target
+func DefaultSLOPolicy() SLOPolicy {
+	return SLOPolicy{Windows: []time.Duration{time.Hour, 24 * time.Hour}}
+}
+
+// WindowAvailability is the availability of a service over one window
+type WindowAvailability struct {
+	Window time.Duration `json:"window"`
+
+	// Availability is the healthy fraction of the observed time (1 when
+	// nothing was observed yet)
+	Availability float64 `json:"availability"`
+
+	HealthyTime  time.Duration `json:"healthy_time"`
+	ObservedTime time.Duration `json:"observed_time"`
+}
+
+// SLOStatus reports a service's availability against its objective
+type SLOStatus struct {
+	Service  string               `json:"service"`
+	Target   float64              `json:"target,omitempty"`
+	Windows  []WindowAvailability `json:"windows"`
+	Breached bool                 `json:"breached"`
+}
+
+// healthSegment is a period during which a service kept the same health
+type healthSegment struct {
+	start    time.Time
+	healthy  bool
+	observed bool
+}
+
+// serviceHistory is the health history of one service
+type serviceHistory struct {
+	segments []healthSegment
+	breached bool
+}
+
+// sloTracker records health check results and computes availability
+type sloTracker struct {
+	mu            sync.Mutex
+	histories     map[string]*serviceHistory
+	policies      map[string]SLOPolicy
+	defaultPolicy SLOPolicy
+	now           func() time.Time
+}
+
+// newSLOTracker creates an empty tracker using the default policy
+func newSLOTracker() *sloTracker {
+	return &sloTracker{
+		histories:     make(map[string]*serviceHistory),
+		policies:      make(map[string]SLOPolicy),
+		defaultPolicy: DefaultSLOPolicy(),
+		now:           time.Now,
+	}
+}
+
+// policyFor returns the effective policy of a service; mu must be held
+func (t *sloTracker) policyFor(name string) SLOPolicy {
+	if policy, exists := t.policies[name]; exists {
+		return policy
+	}
+	return t.defaultPolicy
+}
+
+// observe records a health check result and returns the service's status
+// and whether it started or stopped breaching its target
+func (t *sloTracker) observe(name string, state HealthState) (SLOStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	history, exists := t.histories[name]
+	if !exists {
+		history = &serviceHistory{}
+		t.histories[name] = history
+	}
+
+	healthy, observed := state == HealthHealthy, false
+	switch state {
+	case HealthHealthy, HealthUnhealthy, HealthCritical:
+		observed = true
+	}
+
+	if n := len(history.segments); n == 0 ||
+		history.segments[n-1].healthy != healthy || history.segments[n-1].observed != observed {
+		history.segments = append(history.segments, healthSegment{start: now, healthy: healthy, observed: observed})
+	}
+
+	policy := t.policyFor(name)
+	t.prune(history, now, policy)
+
+	status := t.status(name, history, now, policy)
+	changed := status.Breached != history.breached
+	history.breached = status.Breached
+	return status, changed
+}
+
+// prune drops segments that ended before the longest window
+func (t *sloTracker) prune(history *serviceHistory, now time.Time, policy SLOPolicy) {
+	var longest time.Duration
+	for _, window := range policy.Windows {
+		if window > longest {
+			longest = window
+		}
+	}
+	cutoff := now.Add(-longest)
+
+	drop := 0
+	for drop+1 < len(history.segments) && !history.segments[drop+1].start.After(cutoff) {
+		drop++
+	}
+	if excess := len(history.segments) - drop - maxSLOSegments; excess > 0 {
+		drop += excess
+	}
+	if drop > 0 {
+		history.segments = append(history.segments[:0], history.segments[drop:]...)
+	}
+}
+
+// status computes the availability of a service over each window
+func (t *sloTracker) status(name string, history *serviceHistory, now time.Time, policy SLOPolicy) SLOStatus {
+	status := SLOStatus{Service: name, Target: policy.Target}
+
+	for _, window := range policy.Windows {
+		from := now.Add(-window)
+		wa := WindowAvailability{Window: window, Availability: 1}
+
+		for i, segment := range history.segments {
+			if !segment.observed {
+				continue
+			}
+			end := now
+			if i+1 < len(history.segments) {
+				end = history.segments[i+1].start
+			}
+			start := segment.start
+			if start.Before(from) {
+				start = from
+			}
+			if !end.After(start) {
+				continue
+			}
+			wa.ObservedTime += end.Sub(start)
+			if segment.healthy {
+				wa.HealthyTime += end.Sub(start)
+			}
+		}
+
+		if wa.ObservedTime > 0 {
+			wa.Availability = float64(wa.HealthyTime) / float64(wa.ObservedTime)
+		}
+		if policy.Target > 0 && wa.Availability < policy.Target {
+			status.Breached = true
+		}
+		status.Windows = append(status.Windows, wa)
+	}
+
+	return status
+}
+
+// report returns the current status of every tracked service
+func (t *sloTracker) report() map[string]SLOStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	report := make(map[string]SLOStatus, len(t.histories))
+	for name, history := range t.histories {
+		report[name] = t.status(name, history, now, t.policyFor(name))
+	}
+	return report
+}
+
+// availabilityData renders a status for HealthStatus.Data, keyed by window
+func availabilityData(status SLOStatus) map[string]interface{} {
+	windows := make(map[string]float64, len(status.Windows))
+	for _, wa := range status.Windows {
+		windows[formatWindow(wa.Window)] = wa.Availability
+	}
+
+	data := map[string]interface{}{
+		"availability": windows,
+		"slo_breached": status.Breached,
+	}
+	if status.Target > 0 {
+		data["slo_target"] = status.Target
+	}
+	return data
+}
+
+// formatWindow renders a window as "1h", "24h" or "30m"
+func formatWindow(window time.Duration) string {
+	if window%time.Hour == 0 {
+		return fmt.Sprintf("%dh", window/time.Hour)
+	}
+	if window%time.Minute == 0 {
+		return fmt.Sprintf("%dm", window/time.Minute)
+	}
+	return window.String()
+}
+
+// SetSLOPolicy sets the availability objective of a single service
+func (lm *DefaultLifecycleManager) SetSLOPolicy(name string, policy SLOPolicy) {
+	lm.slo.mu.Lock()
+	defer lm.slo.mu.Unlock()
+
+	lm.slo.policies[name] = policy
+}
+
+// SetDefaultSLOPolicy sets the availability objective of services without their own
+func (lm *DefaultLifecycleManager) SetDefaultSLOPolicy(policy SLOPolicy) {
+	lm.slo.mu.Lock()
+	defer lm.slo.mu.Unlock()
+
+	lm.slo.defaultPolicy = policy
+}
+
+// SLOReport returns the rolling availability of every service whose health
+// has been checked, sorted by service name
+func (lm *DefaultLifecycleManager) SLOReport() []SLOStatus {
+	report := lm.slo.report()
+
+	statuses := make([]SLOStatus, 0, len(report))
+	for _, status := range report {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Service < statuses[j].Service
+	})
+	return statuses
+}
+
+// recordHealth feeds a health check result to the SLO tracker, annotates
+// the status with the service's availability and announces breaches
+func (lm *DefaultLifecycleManager) recordHealth(name string, status HealthStatus) HealthStatus {
+	slo, changed := lm.slo.observe(name, status.State)
+
+	data := make(map[string]interface{}, len(status.Data)+3)
+	for k, v := range status.Data {
+		data[k] = v
+	}
+	for k, v := range availabilityData(slo) {
+		data[k] = v
+	}
+	status.Data = data
+
+	if changed {
+		eventType := "service.slo_recovered"
+		if slo.Breached {
+			eventType = "service.slo_breached"
+		}
+		lm.broadcastEvent(LifecycleEvent{
+			Type:      eventType,
+			Service:   name,
+			Timestamp: time.Now(),
+			Data:      map[string]interface{}{"slo": slo},
+		})
+	}
+
+	return status
+}
@@ -0,0 +1,220 @@
+// Package bootstrap provides the smoke checks run right after services start
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSmokeCheckFailed is wrapped by the error returned when a smoke check
+// fails
+var ErrSmokeCheckFailed = errors.New("smoke check failed")
+
+// SmokeCheck is a quick functional probe of a started service, such as
+// writing and reading back one key, or accepting one test connection
+type SmokeCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// SmokeTester is implemented by services with smoke checks to run after
+// every service started
+type SmokeTester interface {
+	// SmokeChecks returns the probes of the service
+	SmokeChecks() []SmokeCheck
+}
+
+// SmokeResult is the outcome of one smoke check
+type SmokeResult struct {
+	Service  string        `json:"service"`
+	Check    string        `json:"check"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// SmokeReport is the outcome of every smoke check, in start order
+type SmokeReport struct {
+	Results  []SmokeResult `json:"results"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Failed returns the results of the checks that failed
+func (r SmokeReport) Failed() []SmokeResult {
+	var failed []SmokeResult
+	for _, result := range r.Results {
+		if result.Error != "" {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// String formats the report, one line per check
+func (r SmokeReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d smoke checks, %d failed, in %s", len(r.Results), len(r.Failed()), r.Duration.Round(time.Millisecond))
+	for _, result := range r.Results {
+		status := "ok"
+		if result.Error != "" {
+			status = "FAILED: " + result.Error
+		}
+		fmt.Fprintf(&b, "\n  %s/%s: %s (%s)", result.Service, result.Check, status, result.Duration.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// SmokeTestError is returned when smoke checks fail; it carries the report
+type SmokeTestError struct {
+	Report SmokeReport
+}
+
+// Error lists the failed checks
+func (e *SmokeTestError) Error() string {
+	failed := e.Report.Failed()
+	names := make([]string, len(failed))
+	for i, result := range failed {
+		names[i] = fmt.Sprintf("%s/%s: %s", result.Service, result.Check, result.Error)
+	}
+	return fmt.Sprintf("%d of %d smoke checks failed: %s", len(failed), len(e.Report.Results), strings.Join(names, "; "))
+}
+
+// Unwrap returns ErrSmokeCheckFailed
+func (e *SmokeTestError) Unwrap() error {
+	return ErrSmokeCheckFailed
+}
+
+// RegisterSmokeCheck adds a smoke check to a service, on top of those it
+// returns as a SmokeTester
+func (lm *DefaultLifecycleManager) RegisterSmokeCheck(service string, check SmokeCheck) error {
+	if check.Name == "" || check.Check == nil {
+		return fmt.Errorf("smoke check needs a name and a check function")
+	}
+
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+
+	if _, exists := lm.services[service]; !exists {
+		return fmt.Errorf("service %s not registered", service)
+	}
+	if lm.smokeChecks == nil {
+		lm.smokeChecks = make(map[string][]SmokeCheck)
+	}
+	lm.smokeChecks[service] = append(lm.smokeChecks[service], check)
+	return nil
+}
+
+// hasSmokeChecks reports whether any registered service has smoke checks
+func (lm *DefaultLifecycleManager) hasSmokeChecks() bool {
+	lm.mutex.RLock()
+	defer lm.mutex.RUnlock()
+
+	if len(lm.smokeChecks) > 0 {
+		return true
+	}
+	for _, service := range lm.services {
+		if tester, ok := service.(SmokeTester); ok && len(tester.SmokeChecks()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SmokeTest runs the smoke checks of every started service concurrently,
+// each bounded by timeout (0 waits for ctx only). It returns a
+// *SmokeTestError carrying the report if any check failed.
+func (lm *DefaultLifecycleManager) SmokeTest(ctx context.Context, timeout time.Duration) (SmokeReport, error) {
+	lm.mutex.RLock()
+	if !lm.started {
+		lm.mutex.RUnlock()
+		return SmokeReport{}, fmt.Errorf("lifecycle manager not started")
+	}
+	var results []SmokeResult
+	var checks []SmokeCheck
+	for _, name := range lm.startOrder {
+		if lm.inStandby(name) {
+			continue
+		}
+		var serviceChecks []SmokeCheck
+		if tester, ok := lm.services[name].(SmokeTester); ok {
+			serviceChecks = append(serviceChecks, tester.SmokeChecks()...)
+		}
+		serviceChecks = append(serviceChecks, lm.smokeChecks[name]...)
+		for _, check := range serviceChecks {
+			results = append(results, SmokeResult{Service: name, Check: check.Name})
+			checks = append(checks, check)
+		}
+	}
+	lm.mutex.RUnlock()
+
+	start := time.Now()
+	lm.broadcastEvent(LifecycleEvent{
+		Type:      "lifecycle.smoke_testing",
+		Timestamp: start,
+		Data:      map[string]interface{}{"checks": len(checks)},
+	})
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(result *SmokeResult, check SmokeCheck) {
+			defer wg.Done()
+			checkCtx, cancel := lm.serviceContext(ctx, result.Service), context.CancelFunc(func() {})
+			if timeout > 0 {
+				checkCtx, cancel = context.WithTimeout(checkCtx, timeout)
+			}
+			defer cancel()
+
+			checkStart := time.Now()
+			err := runSmokeCheck(checkCtx, check)
+			result.Duration = time.Since(checkStart)
+			if err != nil {
+				result.Error = err.Error()
+				lm.broadcastEvent(LifecycleEvent{
+					Type:      "service.smoke_check_failed",
+					Service:   result.Service,
+					Timestamp: time.Now(),
+					Error:     err,
+					Data:      map[string]interface{}{"check": check.Name},
+				})
+			}
+		}(&results[i], checks[i])
+	}
+	wg.Wait()
+
+	report := SmokeReport{Results: results, Duration: time.Since(start)}
+	failed := len(report.Failed())
+	lm.broadcastEvent(LifecycleEvent{
+		Type:      "lifecycle.smoke_tested",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"duration": report.Duration, "checks": len(checks), "failed": failed},
+	})
+	if failed > 0 {
+		return report, &SmokeTestError{Report: report}
+	}
+	return report, nil
+}
+
+// runSmokeCheck runs a check until it returns or ctx is done, turning a
+// panic into an error
+func runSmokeCheck(ctx context.Context, check SmokeCheck) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("smoke check panicked: %v", r)
+			}
+		}()
+		done <- check.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("smoke check did not finish: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,673 @@
+package bootstrap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables passed to supervised children
+const (
+	// EnvSupervised is set to "1" in processes started by a Supervisor
+	EnvSupervised = "SNGO_SUPERVISED"
+
+	// EnvGeneration numbers the children started by a Supervisor
+	EnvGeneration = "SNGO_GENERATION"
+
+	// EnvReadyFD is the descriptor a child writes to once it is ready
+	EnvReadyFD = "SNGO_READY_FD"
+
+	// EnvListenFDs lists inherited listeners as name=fd pairs
+	EnvListenFDs = "SNGO_LISTEN_FDS"
+)
+
+// ErrTooManyRestarts is returned by Supervisor.Run when the child keeps
+// crashing before it becomes stable
+var ErrTooManyRestarts = errors.New("child restarted too many times")
+
+// readyMessage is written to the ready descriptor by NotifyReady
+const readyMessage = "ready\n"
+
+// SupervisorConfig configures the supervisor process
+type SupervisorConfig struct {
+	// Command and Args start the child; the command is resolved again on
+	// every start, so replacing the binary and upgrading runs the new one
+	Command string
+	Args    []string
+
+	// Env is added to the supervisor's environment for the child
+	Env []string
+
+	// Dir is the working directory of the child
+	Dir string
+
+	// Stdout and Stderr receive the child's output
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// MinBackoff and MaxBackoff bound the delay before restarting a crashed
+	// child; the delay doubles with every crash in a row
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// StableAfter is how long a child must run for its crash not to count
+	// towards MaxRestarts and the backoff
+	StableAfter time.Duration
+
+	// MaxRestarts is the number of crashes in a row after which the
+	// supervisor gives up (0 restarts forever)
+	MaxRestarts int
+
+	// CrashDir receives crash reports ("" disables them)
+	CrashDir string
+
+	// MaxCrashReports is the number of crash reports kept in CrashDir
+	MaxCrashReports int
+
+	// CrashTailBytes is how much of the child's stderr a report includes
+	CrashTailBytes int
+
+	// ShutdownTimeout is how long a child may take to exit after being
+	// asked to before it is killed
+	ShutdownTimeout time.Duration
+
+	// ReadyTimeout is how long an upgraded child may take to call
+	// NotifyReady before the upgrade is abandoned
+	ReadyTimeout time.Duration
+}
+
+// DefaultSupervisorConfig returns a configuration that re-executes the
+// current binary with the same arguments
+func DefaultSupervisorConfig() SupervisorConfig {
+	command, err := os.Executable()
+	if err != nil {
+		command = os.Args[0]
+	}
+
+	return SupervisorConfig{
+		Command:         command,
+		Args:            os.Args[1:],
+		Stdout:          os.Stdout,
+		Stderr:          os.Stderr,
+		MinBackoff:      time.Second,
+		MaxBackoff:      30 * time.Second,
+		StableAfter:     30 * time.Second,
+		CrashDir:        filepath.Join(os.TempDir(), "sngo-crashes"),
+		MaxCrashReports: 10,
+		CrashTailBytes:  64 * 1024,
+		ShutdownTimeout: 30 * time.Second,
+		ReadyTimeout:    30 * time.Second,
+	}
+}
+
+// SupervisorStats reports the state of a supervisor
+type SupervisorStats struct {
+	Generation int
+	PID        int
+	Ready      bool
+	Restarts   int
+	Upgrades   int
+	StartedAt  time.Time
+}
+
+// inheritedListener is a listener handed to every child
+type inheritedListener struct {
+	name string
+	file *os.File
+}
+
+// supervisedChild is one started child process
+type supervisedChild struct {
+	cmd        *exec.Cmd
+	generation int
+	startedAt  time.Time
+	stderr     *tailBuffer
+
+	readyOnce sync.Once
+	ready     chan struct{}
+	done      chan struct{}
+	err       error
+}
+
+// isReady returns true once the child called NotifyReady
+func (c *supervisedChild) isReady() bool {
+	select {
+	case <-c.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// upgradeRequest asks the Run loop to replace the child
+type upgradeRequest struct {
+	result chan error
+}
+
+// Supervisor runs the application as a child process, restarts it with
+// backoff when it crashes, keeps crash reports, and replaces it without
+// dropping listeners when asked to upgrade
+type Supervisor struct {
+	config SupervisorConfig
+
+	mu         sync.Mutex
+	listeners  []inheritedListener
+	current    *supervisedChild
+	generation int
+	restarts   int
+	upgrades   int
+	running    bool
+
+	exited   chan *supervisedChild
+	upgrade  chan upgradeRequest
+	finished chan struct{}
+}
+
+// NewSupervisor creates a supervisor
+func NewSupervisor(config SupervisorConfig) *Supervisor {
+	if config.Stdout == nil {
+		config.Stdout = os.Stdout
+	}
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = time.Second
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+
+	return &Supervisor{
+		config:   config,
+		exited:   make(chan *supervisedChild),
+		upgrade:  make(chan upgradeRequest),
+		finished: make(chan struct{}),
+	}
+}
+
+// AddListener hands listener to every child under name; children get it
+// back with InheritedListener, so connections queue up in the kernel while
+// a child restarts or upgrades instead of being refused
+func (s *Supervisor) AddListener(name string, listener net.Listener) error {
+	filer, ok := listener.(interface{ File() (*os.File, error) })
+	if !ok {
+		return fmt.Errorf("listener %s cannot be inherited", name)
+	}
+	file, err := filer.File()
+	if err != nil {
+		return fmt.Errorf("failed to get descriptor of listener %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, inheritedListener{name: name, file: file})
+	return nil
+}
+
+// Stats returns the state of the supervisor
+func (s *Supervisor) Stats() SupervisorStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := SupervisorStats{
+		Generation: s.generation,
+		Restarts:   s.restarts,
+		Upgrades:   s.upgrades,
+	}
+	if s.current != nil {
+		stats.PID = s.current.cmd.Process.Pid
+		stats.Ready = s.current.isReady()
+		stats.StartedAt = s.current.startedAt
+	}
+	return stats
+}
+
+// Run starts the child and supervises it until ctx is cancelled, the child
+// exits cleanly, or it crashes MaxRestarts times in a row
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return fmt.Errorf("supervisor is already running")
+	}
+	s.running = true
+	s.mu.Unlock()
+	defer close(s.finished)
+
+	child, err := s.start()
+	if err != nil {
+		return err
+	}
+
+	crashes := 0
+	for {
+		select {
+		case <-ctx.Done():
+			s.stop(child)
+			return nil
+
+		case req := <-s.upgrade:
+			next, err := s.replace(ctx, child)
+			if err == nil {
+				child = next
+			}
+			req.result <- err
+
+		case exited := <-s.exited:
+			if exited != child {
+				// A generation retired by an upgrade
+				continue
+			}
+			if exited.err == nil {
+				fmt.Fprintf(s.config.Stderr, "supervisor: child %d exited cleanly\n", exited.cmd.Process.Pid)
+				return nil
+			}
+
+			s.reportCrash(exited)
+			if time.Since(exited.startedAt) >= s.config.StableAfter {
+				crashes = 0
+			}
+			crashes++
+			if s.config.MaxRestarts > 0 && crashes > s.config.MaxRestarts {
+				return fmt.Errorf("%w: %v", ErrTooManyRestarts, exited.err)
+			}
+
+			delay := s.backoff(crashes)
+			fmt.Fprintf(s.config.Stderr, "supervisor: child %d crashed (%v), restarting in %v\n",
+				exited.cmd.Process.Pid, exited.err, delay)
+
+			// A failed start is retried like a crash
+			for {
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return nil
+				}
+
+				child, err = s.start()
+				if err == nil {
+					break
+				}
+				crashes++
+				if s.config.MaxRestarts > 0 && crashes > s.config.MaxRestarts {
+					return fmt.Errorf("%w: %v", ErrTooManyRestarts, err)
+				}
+				delay = s.backoff(crashes)
+			}
+
+			s.mu.Lock()
+			s.restarts++
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Upgrade re-executes the command, waits for the new child to call
+// NotifyReady, then gracefully stops the old one; if the new child fails to
+// become ready it is killed and the old one keeps running
+func (s *Supervisor) Upgrade(ctx context.Context) error {
+	req := upgradeRequest{result: make(chan error, 1)}
+
+	select {
+	case s.upgrade <- req:
+	case <-s.finished:
+		return fmt.Errorf("supervisor is not running")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return <-req.result
+}
+
+// replace starts a new generation next to old and retires old once the new
+// one is ready
+func (s *Supervisor) replace(ctx context.Context, old *supervisedChild) (*supervisedChild, error) {
+	next, err := s.start()
+	if err != nil {
+		s.setCurrent(old)
+		return nil, err
+	}
+
+	timeout := s.config.ReadyTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var failure error
+	select {
+	case <-next.ready:
+	case <-next.done:
+		failure = fmt.Errorf("upgraded child exited before it was ready: %v", next.err)
+	case <-timer.C:
+		failure = fmt.Errorf("upgraded child was not ready within %v", timeout)
+	case <-ctx.Done():
+		failure = ctx.Err()
+	}
+
+	if failure != nil {
+		s.kill(next)
+		s.setCurrent(old)
+		return nil, failure
+	}
+
+	s.stop(old)
+
+	s.mu.Lock()
+	s.upgrades++
+	s.mu.Unlock()
+	return next, nil
+}
+
+// start starts a new child generation and makes it current
+func (s *Supervisor) start() (*supervisedChild, error) {
+	s.mu.Lock()
+	listeners := append([]inheritedListener(nil), s.listeners...)
+	generation := s.generation + 1
+	s.mu.Unlock()
+
+	readyRead, readyWrite, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ready pipe: %w", err)
+	}
+	defer readyWrite.Close()
+
+	child := &supervisedChild{
+		generation: generation,
+		stderr:     newTailBuffer(s.config.CrashTailBytes),
+		ready:      make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	// Inherited descriptors start at 3, after stdin, stdout and stderr
+	cmd := exec.Command(s.config.Command, s.config.Args...)
+	cmd.Dir = s.config.Dir
+	cmd.Stdout = s.config.Stdout
+	cmd.Stderr = io.MultiWriter(s.config.Stderr, child.stderr)
+
+	fds := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, l.file)
+		fds = append(fds, fmt.Sprintf("%s=%d", l.name, 2+len(cmd.ExtraFiles)))
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, readyWrite)
+
+	cmd.Env = append(os.Environ(), s.config.Env...)
+	cmd.Env = append(cmd.Env,
+		EnvSupervised+"=1",
+		EnvGeneration+"="+strconv.Itoa(generation),
+		EnvReadyFD+"="+strconv.Itoa(2+len(cmd.ExtraFiles)),
+		EnvListenFDs+"="+strings.Join(fds, ","),
+	)
+
+	if err := cmd.Start(); err != nil {
+		readyRead.Close()
+		return nil, fmt.Errorf("failed to start %s: %w", s.config.Command, err)
+	}
+	child.cmd = cmd
+	child.startedAt = time.Now()
+
+	go func() {
+		defer readyRead.Close()
+		line, _ := bufio.NewReader(readyRead).ReadString('\n')
+		if line == readyMessage {
+			child.readyOnce.Do(func() { close(child.ready) })
+		}
+	}()
+
+	go func() {
+		child.err = cmd.Wait()
+		close(child.done)
+		select {
+		case s.exited <- child:
+		case <-s.finished:
+		}
+	}()
+
+	s.mu.Lock()
+	s.generation = generation
+	s.current = child
+	s.mu.Unlock()
+	return child, nil
+}
+
+// setCurrent records the child considered current
+func (s *Supervisor) setCurrent(child *supervisedChild) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = child
+}
+
+// stop asks a child to exit and kills it after ShutdownTimeout
+func (s *Supervisor) stop(child *supervisedChild) {
+	if err := terminateProcess(child.cmd.Process); err != nil {
+		s.kill(child)
+		return
+	}
+
+	timeout := s.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	select {
+	case <-child.done:
+	case <-time.After(timeout):
+		s.kill(child)
+	}
+}
+
+// kill kills a child and waits for it to exit
+func (s *Supervisor) kill(child *supervisedChild) {
+	child.cmd.Process.Kill()
+	<-child.done
+}
+
+// backoff returns the restart delay after crashes crashes in a row
+func (s *Supervisor) backoff(crashes int) time.Duration {
+	delay := s.config.MinBackoff
+	for i := 1; i < crashes && delay < s.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.config.MaxBackoff {
+		delay = s.config.MaxBackoff
+	}
+	return delay
+}
+
+// reportCrash writes a crash report and removes the oldest ones beyond
+// MaxCrashReports
+func (s *Supervisor) reportCrash(child *supervisedChild) {
+	if s.config.CrashDir == "" {
+		return
+	}
+	if err := os.MkdirAll(s.config.CrashDir, 0755); err != nil {
+		fmt.Fprintf(s.config.Stderr, "supervisor: failed to create crash dir: %v\n", err)
+		return
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("crash-%s-%d.txt", now.Format("20060102-150405.000000"), child.cmd.Process.Pid)
+	report := fmt.Sprintf("command: %s %s\ngeneration: %d\npid: %d\nstarted: %s\ncrashed: %s\nuptime: %v\nexit: %v\n\n== stderr (tail) ==\n%s",
+		s.config.Command, strings.Join(s.config.Args, " "), child.generation, child.cmd.Process.Pid,
+		child.startedAt.Format(time.RFC3339Nano), now.Format(time.RFC3339Nano),
+		now.Sub(child.startedAt), child.err, child.stderr.Bytes())
+
+	if err := os.WriteFile(filepath.Join(s.config.CrashDir, name), []byte(report), 0644); err != nil {
+		fmt.Fprintf(s.config.Stderr, "supervisor: failed to write crash report: %v\n", err)
+		return
+	}
+
+	if s.config.MaxCrashReports <= 0 {
+		return
+	}
+	reports, _ := filepath.Glob(filepath.Join(s.config.CrashDir, "crash-*.txt"))
+	sort.Strings(reports)
+	for len(reports) > s.config.MaxCrashReports {
+		os.Remove(reports[0])
+		reports = reports[1:]
+	}
+}
+
+// tailBuffer keeps the last bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	data  []byte
+}
+
+// newTailBuffer creates a buffer keeping the last limit bytes
+func newTailBuffer(limit int) *tailBuffer {
+	return &tailBuffer{limit: limit}
+}
+
+// Write appends p, dropping the oldest bytes beyond the limit
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 {
+		return len(p), nil
+	}
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = append(b.data[:0], b.data[len(b.data)-b.limit:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns a copy of the buffered bytes
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.data...)
+}
+
+// IsSupervised returns true if the process was started by a Supervisor
+func IsSupervised() bool {
+	return os.Getenv(EnvSupervised) == "1"
+}
+
+// Generation returns the child generation number (0 if not supervised)
+func Generation() int {
+	generation, _ := strconv.Atoi(os.Getenv(EnvGeneration))
+	return generation
+}
+
+// NotifyReady tells the supervisor the child is serving, completing an
+// upgrade handshake; it does nothing if the process is not supervised
+func NotifyReady() error {
+	fd, err := strconv.Atoi(os.Getenv(EnvReadyFD))
+	if err != nil {
+		return nil
+	}
+	os.Unsetenv(EnvReadyFD)
+
+	file := os.NewFile(uintptr(fd), "sngo-ready")
+	if file == nil {
+		return fmt.Errorf("invalid ready descriptor %d", fd)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(readyMessage); err != nil {
+		return fmt.Errorf("failed to notify supervisor: %w", err)
+	}
+	return nil
+}
+
+// InheritedListener returns the listener the supervisor handed over under
+// name; ok is false if there is none
+func InheritedListener(name string) (net.Listener, bool, error) {
+	for _, pair := range strings.Split(os.Getenv(EnvListenFDs), ",") {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key != name {
+			continue
+		}
+
+		fd, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid descriptor for listener %s: %q", name, value)
+		}
+		file := os.NewFile(uintptr(fd), name)
+		defer file.Close()
+
+		listener, err := net.FileListener(file)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to inherit listener %s: %w", name, err)
+		}
+		return listener, true, nil
+	}
+	return nil, false, nil
+}
+
+// Supervise turns the process into a supervisor of itself. In the child it
+// returns true at once and the caller goes on to run the application; in
+// the parent it supervises children until a shutdown signal and returns
+// false, with upgrade signals triggering Upgrade and reload signals being
+// forwarded to the child
+func Supervise(config SupervisorConfig) (bool, error) {
+	if IsSupervised() {
+		return true, nil
+	}
+
+	supervisor := NewSupervisor(config)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signalChan := make(chan os.Signal, 1)
+	shutdown := DefaultSignalConfig().ShutdownSignals
+	upgrade := defaultUpgradeSignals()
+	forward := defaultReloadSignals()
+	signal.Notify(signalChan, append(append(append([]os.Signal(nil), shutdown...), upgrade...), forward...)...)
+	defer signal.Stop(signalChan)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signalChan:
+				switch {
+				case containsSignal(upgrade, sig):
+					go func() {
+						if err := supervisor.Upgrade(ctx); err != nil {
+							fmt.Fprintf(supervisor.config.Stderr, "supervisor: upgrade failed: %v\n", err)
+						}
+					}()
+				case containsSignal(forward, sig):
+					supervisor.signalChild(sig)
+				default:
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return false, supervisor.Run(ctx)
+}
+
+// signalChild forwards sig to the current child
+func (s *Supervisor) signalChild(sig os.Signal) {
+	s.mu.Lock()
+	child := s.current
+	s.mu.Unlock()
+
+	if child != nil {
+		child.cmd.Process.Signal(sig)
+	}
+}
@@ -0,0 +1,101 @@
+// Package bootstrap provides the warm-up phase run after services start
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WarmUpper is implemented by services that prime caches and exercise hot
+// paths after every service started, before full traffic is admitted
+type WarmUpper interface {
+	// WarmUp prepares the service for traffic, returning when it is ready
+	// or ctx is done
+	WarmUp(ctx context.Context) error
+}
+
+// WarmUp runs the warm-up of every WarmUpper service concurrently. It ends
+// when all of them are ready or timeout passes (0 waits for ctx only);
+// neither a timeout nor a failed warm-up is an error, since a cold
+// service can still serve.
+func (lm *DefaultLifecycleManager) WarmUp(ctx context.Context, timeout time.Duration) error {
+	lm.mutex.RLock()
+	if !lm.started {
+		lm.mutex.RUnlock()
+		return fmt.Errorf("lifecycle manager not started")
+	}
+	warmers := make(map[string]WarmUpper)
+	names := make([]string, 0, len(lm.startOrder))
+	for _, name := range lm.startOrder {
+		if warmer, ok := lm.services[name].(WarmUpper); ok && !lm.inStandby(name) {
+			warmers[name] = warmer
+			names = append(names, name)
+		}
+	}
+	lm.mutex.RUnlock()
+
+	start := time.Now()
+	lm.broadcastEvent(LifecycleEvent{
+		Type:      "lifecycle.warming",
+		Timestamp: start,
+		Data:      map[string]interface{}{"services": names, "timeout": timeout},
+	})
+
+	warmCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		warmCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string, warmer WarmUpper) {
+			defer wg.Done()
+			lm.warmUpService(lm.serviceContext(warmCtx, name), name, warmer)
+		}(name, warmers[name])
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timedOut := false
+	select {
+	case <-done:
+	case <-warmCtx.Done():
+		timedOut = true
+	}
+
+	lm.broadcastEvent(LifecycleEvent{
+		Type:      "lifecycle.warmed",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"duration": time.Since(start), "timed_out": timedOut},
+	})
+	return nil
+}
+
+// warmUpService warms up a single service, reporting the outcome
+func (lm *DefaultLifecycleManager) warmUpService(ctx context.Context, name string, warmer WarmUpper) {
+	start := time.Now()
+	if err := warmer.WarmUp(ctx); err != nil {
+		lm.broadcastEvent(LifecycleEvent{
+			Type:      "service.warm_up_failed",
+			Service:   name,
+			Timestamp: time.Now(),
+			Error:     err,
+		})
+		return
+	}
+
+	lm.broadcastEvent(LifecycleEvent{
+		Type:      "service.warmed",
+		Service:   name,
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"duration": time.Since(start)},
+	})
+}
@@ -0,0 +1,386 @@
+package cluster
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Header keys used to carry remote call authentication data
+const (
+	HeaderAuthTimestamp = "auth_ts"
+	HeaderAuthNonce     = "auth_nonce"
+	HeaderAuthSignature = "auth_sig"
+)
+
+// DefaultAuthMaxSkew is the age past which signatures are rejected when
+// no maximum skew is given
+const DefaultAuthMaxSkew = 30 * time.Second
+
+// Authentication and authorization errors
+var (
+	ErrUnauthenticated = errors.New("remote call not authenticated")
+	ErrAccessDenied    = errors.New("remote call access denied")
+)
+
+// NodeIdentity is the authenticated identity of a calling node
+type NodeIdentity struct {
+	NodeID NodeID   `json:"node_id"`
+	Roles  []string `json:"roles,omitempty"`
+}
+
+// HasRole returns true if the identity carries the given role
+func (id *NodeIdentity) HasRole(role string) bool {
+	for _, r := range id.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator signs outgoing cluster messages and authenticates incoming ones
+type Authenticator interface {
+	// Sign attaches credentials to an outgoing message
+	Sign(message *ClusterMessage) error
+
+	// Verify authenticates an incoming message and returns the caller identity
+	Verify(message *ClusterMessage) (*NodeIdentity, error)
+}
+
+// HMACAuthenticator authenticates messages with per-node HMAC-SHA256 keys.
+// Roles are taken from local configuration, never from the wire, so a node
+// holding its own key cannot claim additional privileges. Every signature
+// carries a nonce, and a signature is accepted once: replays are rejected
+// until they are too old to pass the skew check anyway.
+type HMACAuthenticator struct {
+	localID NodeID
+	maxSkew time.Duration
+
+	mu    sync.RWMutex
+	keys  map[NodeID][]byte
+	roles map[NodeID][]string
+
+	nonceMu sync.Mutex
+	nonces  map[string]time.Time // sender and nonce to expiry
+	pruned  time.Time
+}
+
+// NewHMACAuthenticator creates an authenticator that signs as localID.
+// Signatures older than maxSkew are rejected; 0 uses DefaultAuthMaxSkew.
+func NewHMACAuthenticator(localID NodeID, maxSkew time.Duration) *HMACAuthenticator {
+	if maxSkew <= 0 {
+		maxSkew = DefaultAuthMaxSkew
+	}
+	return &HMACAuthenticator{
+		localID: localID,
+		maxSkew: maxSkew,
+		keys:    make(map[NodeID][]byte),
+		roles:   make(map[NodeID][]string),
+		nonces:  make(map[string]time.Time),
+	}
+}
+
+// AddNode registers the shared key and roles of a node
+func (a *HMACAuthenticator) AddNode(nodeID NodeID, key []byte, roles ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.keys[nodeID] = append([]byte(nil), key...)
+	a.roles[nodeID] = append([]string(nil), roles...)
+}
+
+// RemoveNode revokes the key of a node
+func (a *HMACAuthenticator) RemoveNode(nodeID NodeID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.keys, nodeID)
+	delete(a.roles, nodeID)
+}
+
+// Sign attaches a timestamp, nonce and HMAC signature to the message headers
+func (a *HMACAuthenticator) Sign(message *ClusterMessage) error {
+	a.mu.RLock()
+	key, exists := a.keys[a.localID]
+	a.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("no signing key for local node %s", a.localID)
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	if message.Headers == nil {
+		message.Headers = make(map[string]string)
+	}
+
+	ts := strconv.FormatInt(time.Now().UnixNano(), 10)
+	message.Headers[HeaderAuthTimestamp] = ts
+	message.Headers[HeaderAuthNonce] = hex.EncodeToString(nonce[:])
+	message.Headers[HeaderAuthSignature] = hex.EncodeToString(signMessage(key, a.localID, message, ts))
+
+	return nil
+}
+
+// Verify checks the message signature against the sender's key
+func (a *HMACAuthenticator) Verify(message *ClusterMessage) (*NodeIdentity, error) {
+	a.mu.RLock()
+	key, exists := a.keys[message.From]
+	roles := a.roles[message.From]
+	a.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: unknown node %s", ErrUnauthenticated, message.From)
+	}
+
+	ts := message.Headers[HeaderAuthTimestamp]
+	nonce := message.Headers[HeaderAuthNonce]
+	sig, err := hex.DecodeString(message.Headers[HeaderAuthSignature])
+	if ts == "" || nonce == "" || err != nil || len(sig) == 0 {
+		return nil, fmt.Errorf("%w: missing or malformed signature", ErrUnauthenticated)
+	}
+
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed timestamp", ErrUnauthenticated)
+	}
+	signedAt := time.Unix(0, nanos)
+	skew := time.Since(signedAt)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > a.maxSkew {
+		return nil, fmt.Errorf("%w: signature expired", ErrUnauthenticated)
+	}
+
+	if !hmac.Equal(sig, signMessage(key, message.From, message, ts)) {
+		return nil, fmt.Errorf("%w: bad signature from %s", ErrUnauthenticated, message.From)
+	}
+
+	if !a.claimNonce(message.From, nonce, signedAt.Add(a.maxSkew)) {
+		return nil, fmt.Errorf("%w: replayed signature from %s", ErrUnauthenticated, message.From)
+	}
+
+	return &NodeIdentity{NodeID: message.From, Roles: append([]string(nil), roles...)}, nil
+}
+
+// claimNonce records the nonce of a sender until expiry, returning false
+// if it was already seen. Nonces past their expiry are dropped at most
+// once per skew window, as their signatures fail the skew check anyway.
+func (a *HMACAuthenticator) claimNonce(from NodeID, nonce string, expiry time.Time) bool {
+	a.nonceMu.Lock()
+	defer a.nonceMu.Unlock()
+
+	now := time.Now()
+	if now.Sub(a.pruned) > a.maxSkew {
+		for seen, until := range a.nonces {
+			if now.After(until) {
+				delete(a.nonces, seen)
+			}
+		}
+		a.pruned = now
+	}
+
+	seen := string(from) + "/" + nonce
+	if _, replayed := a.nonces[seen]; replayed {
+		return false
+	}
+	a.nonces[seen] = expiry
+	return true
+}
+
+// signMessage computes the HMAC over the fields that identify a call
+func signMessage(key []byte, from NodeID, message *ClusterMessage, ts string) []byte {
+	mac := hmac.New(sha256.New, key)
+
+	writeField := func(b []byte) {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(len(b)))
+		mac.Write(n[:])
+		mac.Write(b)
+	}
+
+	writeField([]byte(message.ID))
+	writeField([]byte(message.Type))
+	writeField([]byte(from))
+	writeField([]byte(message.To))
+	writeField([]byte(ts))
+	writeField([]byte(message.Headers[HeaderAuthNonce]))
+	writeField([]byte(message.Headers["target_actor"]))
+	writeField([]byte(message.Headers["fire_forget"]))
+	writeField(message.Payload)
+
+	return mac.Sum(nil)
+}
+
+// NodeIdentityFromCertificate derives a node identity from an mTLS peer
+// certificate: the subject common name is the node ID and the subject
+// organizational units are its roles.
+func NodeIdentityFromCertificate(cert *x509.Certificate) (*NodeIdentity, error) {
+	if cert == nil || cert.Subject.CommonName == "" {
+		return nil, fmt.Errorf("%w: certificate has no common name", ErrUnauthenticated)
+	}
+
+	return &NodeIdentity{
+		NodeID: NodeID(cert.Subject.CommonName),
+		Roles:  append([]string(nil), cert.Subject.OrganizationalUnit...),
+	}, nil
+}
+
+// TLSAuthenticator authenticates messages using the verified peer
+// certificate of the mTLS link they arrived on. Messages need no signature;
+// the transport supplies the certificate through the lookup function.
+type TLSAuthenticator struct {
+	peerCertificate func(nodeID NodeID) (*x509.Certificate, bool)
+}
+
+// NewTLSAuthenticator creates an authenticator backed by mTLS peer certificates
+func NewTLSAuthenticator(peerCertificate func(nodeID NodeID) (*x509.Certificate, bool)) *TLSAuthenticator {
+	return &TLSAuthenticator{peerCertificate: peerCertificate}
+}
+
+// Sign is a no-op: the link itself carries the credentials
+func (a *TLSAuthenticator) Sign(message *ClusterMessage) error {
+	return nil
+}
+
+// Verify checks that the sender matches the certificate of its link
+func (a *TLSAuthenticator) Verify(message *ClusterMessage) (*NodeIdentity, error) {
+	cert, ok := a.peerCertificate(message.From)
+	if !ok {
+		return nil, fmt.Errorf("%w: no peer certificate for %s", ErrUnauthenticated, message.From)
+	}
+
+	identity, err := NodeIdentityFromCertificate(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity.NodeID != message.From {
+		return nil, fmt.Errorf("%w: certificate for %s used by %s", ErrUnauthenticated, identity.NodeID, message.From)
+	}
+
+	return identity, nil
+}
+
+// ACLRule lists the nodes and roles allowed to call a service
+type ACLRule struct {
+	AllowNodes []NodeID `yaml:"allow_nodes" json:"allow_nodes,omitempty"`
+	AllowRoles []string `yaml:"allow_roles" json:"allow_roles,omitempty"`
+}
+
+// allows returns true if the identity matches the rule
+func (r ACLRule) allows(identity *NodeIdentity) bool {
+	for _, nodeID := range r.AllowNodes {
+		if nodeID == identity.NodeID || nodeID == "*" {
+			return true
+		}
+	}
+	for _, role := range r.AllowRoles {
+		if identity.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditEvent records a denied remote call
+type AuditEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Caller    NodeID    `json:"caller"`
+	Roles     []string  `json:"roles,omitempty"`
+	ServiceID string    `json:"service_id"`
+	Reason    string    `json:"reason"`
+}
+
+// AccessControl holds per-service ACLs for inbound remote calls
+type AccessControl struct {
+	mu           sync.RWMutex
+	rules        map[string]ACLRule
+	defaultAllow bool
+}
+
+// NewAccessControl creates an ACL set; services without a rule are allowed
+// only if defaultAllow is true.
+func NewAccessControl(defaultAllow bool) *AccessControl {
+	return &AccessControl{
+		rules:        make(map[string]ACLRule),
+		defaultAllow: defaultAllow,
+	}
+}
+
+// SetRule sets the rule for a service
+func (ac *AccessControl) SetRule(serviceID string, rule ACLRule) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.rules[serviceID] = rule
+}
+
+// RemoveRule removes the rule for a service
+func (ac *AccessControl) RemoveRule(serviceID string) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	delete(ac.rules, serviceID)
+}
+
+// Check returns nil if identity may call serviceID
+func (ac *AccessControl) Check(identity *NodeIdentity, serviceID string) error {
+	ac.mu.RLock()
+	rule, exists := ac.rules[serviceID]
+	defaultAllow := ac.defaultAllow
+	ac.mu.RUnlock()
+
+	if !exists {
+		if defaultAllow {
+			return nil
+		}
+		return fmt.Errorf("%w: no rule for service %s", ErrAccessDenied, serviceID)
+	}
+
+	if identity == nil || !rule.allows(identity) {
+		caller := NodeID("")
+		if identity != nil {
+			caller = identity.NodeID
+		}
+		return fmt.Errorf("%w: node %s may not call %s", ErrAccessDenied, caller, serviceID)
+	}
+
+	return nil
+}
+
+// signClusterMessage signs a message of the cluster protocol with the
+// authenticator of remote calls, if one is set
+func (cm *clusterManager) signClusterMessage(message *ClusterMessage) error {
+	if rs, ok := cm.service.(*remoteService); ok {
+		return rs.sign(message)
+	}
+	return nil
+}
+
+// verifyClusterMessage authenticates a message of the cluster protocol
+// received from a peer, auditing denials under the message type
+func (cm *clusterManager) verifyClusterMessage(from NodeID, message *ClusterMessage) error {
+	rs, ok := cm.service.(*remoteService)
+	if !ok {
+		return nil
+	}
+	if _, err := rs.verify(from, message); err != nil {
+		rs.audit(from, nil, string(message.Type), err)
+		return err
+	}
+	return nil
+}
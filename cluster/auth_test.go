@@ -0,0 +1,131 @@
+package cluster
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestHMACAuthenticator tests signing and verification of cluster messages
+func TestHMACAuthenticator(t *testing.T) {
+	edge := NewHMACAuthenticator("edge-1", time.Minute)
+	edge.AddNode("edge-1", []byte("edge-key"))
+
+	admin := NewHMACAuthenticator("admin-1", time.Minute)
+	admin.AddNode("edge-1", []byte("edge-key"), "edge")
+
+	msg := &ClusterMessage{
+		ID:      "msg-1",
+		Type:    MessageTypeActorCall,
+		From:    "edge-1",
+		To:      "admin-1",
+		Payload: []byte(`{"service_id":"players"}`),
+	}
+
+	if err := edge.Sign(msg); err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+
+	identity, err := admin.Verify(msg)
+	if err != nil {
+		t.Fatalf("Failed to verify message: %v", err)
+	}
+	if identity.NodeID != "edge-1" || !identity.HasRole("edge") {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+
+	// A signature is accepted once
+	if _, err := admin.Verify(msg); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for replayed message, got %v", err)
+	}
+
+	// Without a maximum skew, signatures still expire
+	lax := NewHMACAuthenticator("admin-1", 0)
+	lax.AddNode("edge-1", []byte("edge-key"))
+	stale := &ClusterMessage{ID: "msg-0", Type: MessageTypeActorCall, From: "edge-1"}
+	edge.Sign(stale)
+	stale.Headers[HeaderAuthTimestamp] = strconv.FormatInt(time.Now().Add(-time.Hour).UnixNano(), 10)
+	if _, err := lax.Verify(stale); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for an hour old signature, got %v", err)
+	}
+
+	// Tampering with the payload must invalidate the signature
+	msg.Payload = []byte(`{"service_id":"admin"}`)
+	if _, err := admin.Verify(msg); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for tampered payload, got %v", err)
+	}
+
+	// Unknown senders are rejected
+	msg.From = "rogue"
+	if _, err := admin.Verify(msg); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for unknown node, got %v", err)
+	}
+}
+
+// TestTLSAuthenticator tests identity extraction from peer certificates
+func TestTLSAuthenticator(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "node-a", OrganizationalUnit: []string{"admin"}}}
+	auth := NewTLSAuthenticator(func(nodeID NodeID) (*x509.Certificate, bool) {
+		return cert, nodeID == "node-a" || nodeID == "node-b"
+	})
+
+	identity, err := auth.Verify(&ClusterMessage{From: "node-a"})
+	if err != nil || !identity.HasRole("admin") {
+		t.Fatalf("Expected admin identity, got %+v (%v)", identity, err)
+	}
+
+	if _, err := auth.Verify(&ClusterMessage{From: "node-b"}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected certificate mismatch to fail, got %v", err)
+	}
+}
+
+// TestRemoteServiceAuthorize tests ACL enforcement and auditing of denials
+func TestRemoteServiceAuthorize(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "admin-1"
+
+	rs := NewRemoteService(NewClusterManager(config)).(*remoteService)
+
+	auth := NewHMACAuthenticator("admin-1", time.Minute)
+	auth.AddNode("edge-1", []byte("edge-key"), "edge")
+	auth.AddNode("ops-1", []byte("ops-key"), "ops")
+	rs.SetAuthenticator(auth)
+
+	acl := NewAccessControl(true)
+	acl.SetRule("admin", ACLRule{AllowRoles: []string{"ops"}})
+	rs.SetAccessControl(acl)
+
+	var audits []AuditEvent
+	rs.SetAuditHandler(func(event AuditEvent) { audits = append(audits, event) })
+
+	signedBy := func(nodeID NodeID, key string) *ClusterMessage {
+		signer := NewHMACAuthenticator(nodeID, time.Minute)
+		signer.AddNode(nodeID, []byte(key))
+		msg := &ClusterMessage{ID: "m", Type: MessageTypeActorCall, From: nodeID}
+		signer.Sign(msg)
+		return msg
+	}
+
+	if err := rs.authorize("ops-1", signedBy("ops-1", "ops-key"), "admin"); err != nil {
+		t.Errorf("Ops node should be allowed to call admin: %v", err)
+	}
+	if err := rs.authorize("edge-1", signedBy("edge-1", "edge-key"), "players"); err != nil {
+		t.Errorf("Edge node should be allowed to call unrestricted service: %v", err)
+	}
+	if err := rs.authorize("edge-1", signedBy("edge-1", "edge-key"), "admin"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected ErrAccessDenied for edge node, got %v", err)
+	}
+	if err := rs.authorize("edge-1", signedBy("ops-1", "ops-key"), "admin"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for spoofed sender, got %v", err)
+	}
+
+	if len(audits) != 2 {
+		t.Fatalf("Expected 2 audit events, got %d", len(audits))
+	}
+	if audits[0].Caller != "edge-1" || audits[0].ServiceID != "admin" {
+		t.Errorf("Unexpected audit event: %+v", audits[0])
+	}
+}
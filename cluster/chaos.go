@@ -0,0 +1,384 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrChaosDisabled is returned when a fault is injected into a cluster
+// whose configuration does not allow chaos commands
+var ErrChaosDisabled = errors.New("chaos commands are disabled")
+
+// Chaos events, published when a fault starts and when it is reverted
+const (
+	EventChaosInjected ClusterEventType = "chaos_injected"
+	EventChaosReverted ClusterEventType = "chaos_reverted"
+)
+
+// ChaosConfig guards the chaos commands. Enable it in staging only
+type ChaosConfig struct {
+	// Enabled allows faults to be injected
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// MaxDuration caps how long a fault lasts before it is reverted
+	MaxDuration time.Duration `yaml:"max_duration" json:"max_duration"`
+}
+
+// DefaultChaosConfig returns chaos commands disabled, capped at 15 minutes
+func DefaultChaosConfig() ChaosConfig {
+	return ChaosConfig{MaxDuration: 15 * time.Minute}
+}
+
+// ChaosKind is the kind of fault injected into the cluster
+type ChaosKind string
+
+const (
+	// ChaosSuspectNode marks a node suspected as if it missed heartbeats
+	ChaosSuspectNode ChaosKind = "suspect_node"
+
+	// ChaosDropLink drops every message exchanged with a node
+	ChaosDropLink ChaosKind = "drop_link"
+
+	// ChaosDelayGossip delays heartbeats and sync messages, to one node or
+	// to all of them when NodeID is empty
+	ChaosDelayGossip ChaosKind = "delay_gossip"
+)
+
+// ChaosRequest asks for a fault; it is reverted automatically after Duration
+type ChaosRequest struct {
+	Kind     ChaosKind     `json:"kind"`
+	NodeID   NodeID        `json:"node_id,omitempty"`
+	Delay    time.Duration `json:"delay,omitempty"`
+	Duration time.Duration `json:"duration"`
+	By       string        `json:"by"`
+	Reason   string        `json:"reason,omitempty"`
+}
+
+// ChaosFault is an active fault
+type ChaosFault struct {
+	ID string `json:"id"`
+	ChaosRequest
+	StartedAt time.Time `json:"started_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ChaosController injects and reverts faults; the cluster manager
+// implements it
+type ChaosController interface {
+	// InjectFault starts a fault and schedules its reversal
+	InjectFault(req ChaosRequest) (ChaosFault, error)
+
+	// RevertFault ends a fault before it expires
+	RevertFault(id string) error
+
+	// ActiveFaults returns the faults in effect, oldest first
+	ActiveFaults() []ChaosFault
+}
+
+// activeFault is a fault with its reversal timer
+type activeFault struct {
+	fault ChaosFault
+	timer *time.Timer
+}
+
+// chaosState holds the faults of a cluster manager
+type chaosState struct {
+	mu      sync.RWMutex
+	faults  map[string]*activeFault
+	counter int
+}
+
+// gossipMessages are the message types a gossip delay applies to
+var gossipMessages = map[MessageType]bool{
+	MessageTypeHeartbeat: true,
+	MessageTypeSync:      true,
+}
+
+// InjectFault starts a fault and schedules its reversal
+func (cm *clusterManager) InjectFault(req ChaosRequest) (ChaosFault, error) {
+	config := cm.config.Chaos
+	if !config.Enabled {
+		return ChaosFault{}, ErrChaosDisabled
+	}
+	if req.By == "" {
+		return ChaosFault{}, fmt.Errorf("chaos requests must name the operator")
+	}
+	if req.Duration <= 0 {
+		return ChaosFault{}, fmt.Errorf("chaos requests need a duration")
+	}
+	if config.MaxDuration > 0 && req.Duration > config.MaxDuration {
+		req.Duration = config.MaxDuration
+	}
+
+	switch req.Kind {
+	case ChaosSuspectNode, ChaosDropLink:
+		if req.NodeID == "" {
+			return ChaosFault{}, fmt.Errorf("%s needs a node", req.Kind)
+		}
+		if req.NodeID == cm.localNode.ID() {
+			return ChaosFault{}, fmt.Errorf("%s cannot target the local node", req.Kind)
+		}
+	case ChaosDelayGossip:
+		if req.Delay <= 0 {
+			return ChaosFault{}, fmt.Errorf("%s needs a delay", req.Kind)
+		}
+	default:
+		return ChaosFault{}, fmt.Errorf("unknown chaos kind %q", req.Kind)
+	}
+
+	var node Node
+	if req.NodeID != "" {
+		var exists bool
+		if node, exists = cm.GetNode(req.NodeID); !exists {
+			return ChaosFault{}, fmt.Errorf("node %s not found", req.NodeID)
+		}
+	}
+
+	now := time.Now()
+	cm.chaos.mu.Lock()
+	if cm.chaos.faults == nil {
+		cm.chaos.faults = make(map[string]*activeFault)
+	}
+	cm.chaos.counter++
+	fault := ChaosFault{
+		ID:           fmt.Sprintf("chaos-%d", cm.chaos.counter),
+		ChaosRequest: req,
+		StartedAt:    now,
+		ExpiresAt:    now.Add(req.Duration),
+	}
+	active := &activeFault{fault: fault}
+	cm.chaos.faults[fault.ID] = active
+	active.timer = time.AfterFunc(req.Duration, func() { cm.RevertFault(fault.ID) })
+	cm.chaos.mu.Unlock()
+
+	if req.Kind == ChaosSuspectNode && node.Info().State == NodeStateActive {
+		node.UpdateState(NodeStateSuspected)
+	}
+
+	cm.publishEvent(ClusterEvent{
+		Type:      EventChaosInjected,
+		NodeID:    req.NodeID,
+		Timestamp: now,
+		Payload:   ChaosChanged{Fault: fault},
+		Data:      map[string]interface{}{"fault": fault},
+	})
+	return fault, nil
+}
+
+// RevertFault ends a fault before it expires
+func (cm *clusterManager) RevertFault(id string) error {
+	cm.chaos.mu.Lock()
+	active, exists := cm.chaos.faults[id]
+	if exists {
+		delete(cm.chaos.faults, id)
+		active.timer.Stop()
+	}
+	cm.chaos.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("chaos fault %s not found", id)
+	}
+
+	fault := active.fault
+	if fault.Kind == ChaosSuspectNode && !cm.chaosSuspects(fault.NodeID) {
+		if node, exists := cm.GetNode(fault.NodeID); exists && node.Info().State == NodeStateSuspected {
+			node.UpdateState(NodeStateActive)
+		}
+	}
+
+	cm.publishEvent(ClusterEvent{
+		Type:      EventChaosReverted,
+		NodeID:    fault.NodeID,
+		Timestamp: time.Now(),
+		Payload:   ChaosChanged{Fault: fault},
+		Data:      map[string]interface{}{"fault": fault},
+	})
+	return nil
+}
+
+// ActiveFaults returns the faults in effect, oldest first
+func (cm *clusterManager) ActiveFaults() []ChaosFault {
+	cm.chaos.mu.RLock()
+	defer cm.chaos.mu.RUnlock()
+
+	faults := make([]ChaosFault, 0, len(cm.chaos.faults))
+	for _, active := range cm.chaos.faults {
+		faults = append(faults, active.fault)
+	}
+	sort.Slice(faults, func(i, j int) bool {
+		return faults[i].StartedAt.Before(faults[j].StartedAt) ||
+			faults[i].StartedAt.Equal(faults[j].StartedAt) && faults[i].ID < faults[j].ID
+	})
+	return faults
+}
+
+// revertAllFaults ends every fault, e.g. when the manager stops
+func (cm *clusterManager) revertAllFaults() {
+	for _, fault := range cm.ActiveFaults() {
+		cm.RevertFault(fault.ID)
+	}
+}
+
+// chaosSuspects reports whether a fault keeps a node suspected
+func (cm *clusterManager) chaosSuspects(nodeID NodeID) bool {
+	return cm.chaosMatches(func(f ChaosFault) bool {
+		return f.Kind == ChaosSuspectNode && f.NodeID == nodeID
+	})
+}
+
+// chaosDrops reports whether the link to a node is severed
+func (cm *clusterManager) chaosDrops(nodeID NodeID) bool {
+	return cm.chaosMatches(func(f ChaosFault) bool {
+		return f.Kind == ChaosDropLink && f.NodeID == nodeID
+	})
+}
+
+// chaosGossipDelay returns how long gossip to a node is held back
+func (cm *clusterManager) chaosGossipDelay(nodeID NodeID) time.Duration {
+	cm.chaos.mu.RLock()
+	defer cm.chaos.mu.RUnlock()
+
+	var delay time.Duration
+	for _, active := range cm.chaos.faults {
+		f := active.fault
+		if f.Kind == ChaosDelayGossip && (f.NodeID == "" || f.NodeID == nodeID) && f.Delay > delay {
+			delay = f.Delay
+		}
+	}
+	return delay
+}
+
+// chaosMatches reports whether an active fault matches
+func (cm *clusterManager) chaosMatches(match func(ChaosFault) bool) bool {
+	cm.chaos.mu.RLock()
+	defer cm.chaos.mu.RUnlock()
+
+	for _, active := range cm.chaos.faults {
+		if match(active.fault) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFaults reports whether any fault is active
+func (cm *clusterManager) hasFaults() bool {
+	cm.chaos.mu.RLock()
+	defer cm.chaos.mu.RUnlock()
+	return len(cm.chaos.faults) > 0
+}
+
+// chaosTransport applies link and gossip faults to outgoing messages;
+// incoming messages from a severed node are dropped by the manager
+type chaosTransport struct {
+	MessageTransport
+	manager *clusterManager
+}
+
+// Send drops or delays a message according to the active faults
+func (ct *chaosTransport) Send(ctx context.Context, nodeID NodeID, message *ClusterMessage) error {
+	cm := ct.manager
+	if cm.chaosDrops(nodeID) {
+		return nil
+	}
+	if gossipMessages[message.Type] {
+		if delay := cm.chaosGossipDelay(nodeID); delay > 0 {
+			time.AfterFunc(delay, func() {
+				ct.MessageTransport.Send(cm.ctx, nodeID, message)
+			})
+			return nil
+		}
+	}
+	return ct.MessageTransport.Send(ctx, nodeID, message)
+}
+
+// Broadcast sends to each peer separately while faults are active
+func (ct *chaosTransport) Broadcast(ctx context.Context, message *ClusterMessage) error {
+	cm := ct.manager
+	if !cm.hasFaults() {
+		return ct.MessageTransport.Broadcast(ctx, message)
+	}
+
+	var failed []string
+	for _, node := range cm.GetAllNodes() {
+		if node.ID() == cm.localNode.ID() {
+			continue
+		}
+		copied := *message
+		if err := ct.Send(ctx, node.ID(), &copied); err != nil {
+			failed = append(failed, string(node.ID()))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("broadcast failed to %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Traffic exposes the traffic accounting of the wrapped transport
+func (ct *chaosTransport) Traffic() *TrafficAccounting {
+	if t, ok := ct.MessageTransport.(interface{ Traffic() *TrafficAccounting }); ok {
+		return t.Traffic()
+	}
+	return nil
+}
+
+// Reconnects exposes the reconnect manager of the wrapped transport
+func (ct *chaosTransport) Reconnects() *ReconnectManager {
+	if r, ok := ct.MessageTransport.(ReconnectProvider); ok {
+		return r.Reconnects()
+	}
+	return nil
+}
+
+// ChaosAdminHandler exposes chaos commands over HTTP for the admin API.
+// GET lists the active faults, POST injects a fault described by a
+// ChaosRequest body and DELETE ?id=chaos-1 reverts one early.
+func ChaosAdminHandler(chaos ChaosController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req ChaosRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+				return
+			}
+			fault, err := chaos.InjectFault(req)
+			if errors.Is(err, ErrChaosDisabled) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(fault)
+			return
+		case http.MethodDelete:
+			if err := chaos.RevertFault(r.URL.Query().Get("id")); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Faults []ChaosFault `json:"faults"`
+		}{
+			Faults: chaos.ActiveFaults(),
+		})
+	})
+}
@@ -0,0 +1,166 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingTransport records the messages sent through it
+type recordingTransport struct {
+	MessageTransport
+	mu   sync.Mutex
+	sent []NodeID
+}
+
+func (rt *recordingTransport) Send(ctx context.Context, nodeID NodeID, message *ClusterMessage) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.sent = append(rt.sent, nodeID)
+	return nil
+}
+
+func (rt *recordingTransport) Sent() []NodeID {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]NodeID(nil), rt.sent...)
+}
+
+func newChaosManager(enabled bool) *clusterManager {
+	config := DefaultClusterConfig()
+	config.NodeID = "local"
+	config.Chaos.Enabled = enabled
+	manager := NewClusterManager(config).(*clusterManager)
+	manager.addNode(manager.localNode)
+	manager.addNode(NewRemoteNode(&NodeInfo{ID: "node-a", State: NodeStateActive}))
+	manager.addNode(NewRemoteNode(&NodeInfo{ID: "node-b", State: NodeStateActive}))
+	return manager
+}
+
+// TestChaosSuspectNode tests that a suspected node recovers when the fault expires
+func TestChaosSuspectNode(t *testing.T) {
+	if _, err := newChaosManager(false).InjectFault(ChaosRequest{
+		Kind: ChaosSuspectNode, NodeID: "node-a", Duration: time.Second, By: "alice",
+	}); !errors.Is(err, ErrChaosDisabled) {
+		t.Fatalf("Expected ErrChaosDisabled, got %v", err)
+	}
+
+	manager := newChaosManager(true)
+	if _, err := manager.InjectFault(ChaosRequest{Kind: ChaosSuspectNode, NodeID: "local", Duration: time.Second, By: "alice"}); err == nil {
+		t.Error("Expected the local node to be refused")
+	}
+
+	fault, err := manager.InjectFault(ChaosRequest{
+		Kind: ChaosSuspectNode, NodeID: "node-a", Duration: 50 * time.Millisecond, By: "alice", Reason: "failover drill",
+	})
+	if err != nil {
+		t.Fatalf("Failed to inject fault: %v", err)
+	}
+
+	node, _ := manager.GetNode("node-a")
+	if node.Info().State != NodeStateSuspected {
+		t.Errorf("Expected node to be suspected, got %s", node.Info().State)
+	}
+
+	// A reconnect does not clear an artificial suspicion
+	manager.HandleConnectionEstablished("node-a")
+	if node.Info().State != NodeStateSuspected {
+		t.Errorf("Expected node to stay suspected, got %s", node.Info().State)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for node.Info().State != NodeStateActive && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if node.Info().State != NodeStateActive {
+		t.Errorf("Expected node to recover after the fault expired, got %s", node.Info().State)
+	}
+	if len(manager.ActiveFaults()) != 0 {
+		t.Error("Expected no active faults")
+	}
+	if err := manager.RevertFault(fault.ID); err == nil {
+		t.Error("Expected reverting an expired fault to fail")
+	}
+}
+
+// TestChaosTransport tests dropped links and delayed gossip
+func TestChaosTransport(t *testing.T) {
+	manager := newChaosManager(true)
+	recorder := &recordingTransport{}
+	transport := &chaosTransport{MessageTransport: recorder, manager: manager}
+	ctx := context.Background()
+
+	drop, err := manager.InjectFault(ChaosRequest{Kind: ChaosDropLink, NodeID: "node-a", Duration: time.Minute, By: "alice"})
+	if err != nil {
+		t.Fatalf("Failed to inject fault: %v", err)
+	}
+	if _, err := manager.InjectFault(ChaosRequest{
+		Kind: ChaosDelayGossip, Delay: 30 * time.Millisecond, Duration: time.Minute, By: "alice",
+	}); err != nil {
+		t.Fatalf("Failed to inject fault: %v", err)
+	}
+
+	transport.Send(ctx, "node-a", &ClusterMessage{Type: MessageTypeActorCall})
+	transport.Send(ctx, "node-b", &ClusterMessage{Type: MessageTypeActorCall})
+	transport.Send(ctx, "node-b", &ClusterMessage{Type: MessageTypeHeartbeat})
+	if sent := recorder.Sent(); len(sent) != 1 || sent[0] != "node-b" {
+		t.Errorf("Expected only the call to node-b to go out at once, got %v", sent)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if sent := recorder.Sent(); len(sent) != 2 {
+		t.Errorf("Expected the delayed heartbeat to go out, got %v", sent)
+	}
+
+	// Messages from a severed node are dropped on arrival too
+	if err := manager.HandleMessage(ctx, "node-a", &ClusterMessage{Type: MessageTypeReadOnly, Payload: []byte("{")}); err != nil {
+		t.Errorf("Expected message from severed node to be dropped, got %v", err)
+	}
+
+	if err := manager.RevertFault(drop.ID); err != nil {
+		t.Fatalf("Failed to revert fault: %v", err)
+	}
+	transport.Send(ctx, "node-a", &ClusterMessage{Type: MessageTypeActorCall})
+	if sent := recorder.Sent(); len(sent) != 3 || sent[2] != "node-a" {
+		t.Errorf("Expected link to be restored, got %v", sent)
+	}
+	manager.revertAllFaults()
+}
+
+// TestChaosAdminHandler tests injecting and reverting faults over HTTP
+func TestChaosAdminHandler(t *testing.T) {
+	manager := newChaosManager(true)
+	handler := ChaosAdminHandler(manager)
+	defer manager.revertAllFaults()
+
+	body := strings.NewReader(`{"kind":"drop_link","node_id":"node-b","duration":60000000000,"by":"alice"}`)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/chaos", body))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var fault ChaosFault
+	if err := json.NewDecoder(rec.Body).Decode(&fault); err != nil || fault.Kind != ChaosDropLink {
+		t.Fatalf("Unexpected fault: %+v (%v)", fault, err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/chaos?id="+fault.ID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	disabled := ChaosAdminHandler(newChaosManager(false))
+	rec = httptest.NewRecorder()
+	body = strings.NewReader(`{"kind":"drop_link","node_id":"node-b","duration":60000000000,"by":"alice"}`)
+	disabled.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/chaos", body))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 with chaos disabled, got %d", rec.Code)
+	}
+}
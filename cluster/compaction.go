@@ -0,0 +1,295 @@
+package cluster
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCallAbandoned fails a pending call compaction found long past its deadline
+var ErrCallAbandoned = errors.New("remote call abandoned")
+
+// CompactionConfig sets how long bookkeeping that outlived its use is kept.
+// Long-running clusters otherwise accumulate forgotten nodes, abandoned
+// calls, cancelled listeners and idle per-caller state without bound
+type CompactionConfig struct {
+	// Interval between compaction passes; 0 disables background compaction
+	Interval time.Duration `yaml:"interval" json:"interval"`
+
+	// PendingCallGrace is how long a pending call is kept past its deadline
+	// before it is considered abandoned
+	PendingCallGrace time.Duration `yaml:"pending_call_grace" json:"pending_call_grace"`
+
+	// DeadNodeTTL is how long failed and departed nodes stay in the member
+	// list; it is never shorter than the quarantine period
+	DeadNodeTTL time.Duration `yaml:"dead_node_ttl" json:"dead_node_ttl"`
+
+	// IdleBucketTTL drops rate limiter buckets of callers idle for longer
+	IdleBucketTTL time.Duration `yaml:"idle_bucket_ttl" json:"idle_bucket_ttl"`
+}
+
+// DefaultCompactionConfig returns the default compaction settings
+func DefaultCompactionConfig() CompactionConfig {
+	return CompactionConfig{
+		Interval:         time.Minute,
+		PendingCallGrace: 30 * time.Second,
+		DeadNodeTTL:      time.Hour,
+		IdleBucketTTL:    10 * time.Minute,
+	}
+}
+
+// CompactionStats reports the size of the cluster's bookkeeping maps and
+// how many entries compaction expired
+type CompactionStats struct {
+	Runs    int64     `json:"runs"`
+	LastRun time.Time `json:"last_run"`
+
+	// Gauges
+	Nodes           int `json:"nodes"`
+	Listeners       int `json:"listeners"`
+	Subscriptions   int `json:"subscriptions"`
+	PendingCalls    int `json:"pending_calls"`
+	ActorWatches    int `json:"actor_watches"`
+	SweepEpochs     int `json:"sweep_epochs"`
+	ServiceWatchers int `json:"service_watchers"`
+	RateBuckets     int `json:"rate_buckets"`
+
+	// Expired entries since start
+	NodesExpired        int64 `json:"nodes_expired"`
+	ListenersExpired    int64 `json:"listeners_expired"`
+	PendingCallsExpired int64 `json:"pending_calls_expired"`
+	SweepEntriesExpired int64 `json:"sweep_entries_expired"`
+	BucketsExpired      int64 `json:"buckets_expired"`
+}
+
+// compactionCounters holds the compaction counters of a cluster manager
+type compactionCounters struct {
+	runs         int64 // atomic
+	lastRun      int64 // atomic, Unix nanoseconds
+	nodes        int64 // atomic
+	listeners    int64 // atomic
+	pendingCalls int64 // atomic
+	sweepEntries int64 // atomic
+	buckets      int64 // atomic
+}
+
+// eventListener is a registered cluster event listener
+type eventListener struct {
+	handle func(ClusterEvent)
+	done   <-chan struct{}
+}
+
+// expired reports whether the listener's context is done
+func (l eventListener) expired() bool {
+	if l.done == nil {
+		return false
+	}
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// compactionLoop runs compaction passes until the manager stops
+func (cm *clusterManager) compactionLoop() {
+	defer cm.wg.Done()
+
+	if cm.config.Compaction.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cm.config.Compaction.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cm.ctx.Done():
+			return
+		case <-ticker.C:
+			cm.Compact()
+		}
+	}
+}
+
+// Compact runs a compaction pass now and returns the updated stats
+func (cm *clusterManager) Compact() CompactionStats {
+	now := time.Now()
+	counters := &cm.compaction
+
+	atomic.AddInt64(&counters.listeners, int64(cm.compactListeners()))
+	atomic.AddInt64(&counters.nodes, int64(cm.compactNodes(now)))
+
+	if rs, ok := cm.service.(*remoteService); ok {
+		atomic.AddInt64(&counters.pendingCalls, int64(rs.compactPendingCalls(now, cm.config.Compaction.PendingCallGrace)))
+		atomic.AddInt64(&counters.sweepEntries, int64(rs.compactSweeper(cm.isMember)))
+
+		rs.securityMu.RLock()
+		limiter := rs.limiter
+		rs.securityMu.RUnlock()
+		if limiter != nil && cm.config.Compaction.IdleBucketTTL > 0 {
+			atomic.AddInt64(&counters.buckets, int64(limiter.Prune(cm.config.Compaction.IdleBucketTTL)))
+		}
+	}
+
+	atomic.AddInt64(&counters.runs, 1)
+	atomic.StoreInt64(&counters.lastRun, now.UnixNano())
+	return cm.CompactionStats()
+}
+
+// CompactionStats returns the bookkeeping map sizes and expiry counters
+func (cm *clusterManager) CompactionStats() CompactionStats {
+	counters := &cm.compaction
+	stats := CompactionStats{
+		Runs:                atomic.LoadInt64(&counters.runs),
+		NodesExpired:        atomic.LoadInt64(&counters.nodes),
+		ListenersExpired:    atomic.LoadInt64(&counters.listeners),
+		PendingCallsExpired: atomic.LoadInt64(&counters.pendingCalls),
+		SweepEntriesExpired: atomic.LoadInt64(&counters.sweepEntries),
+		BucketsExpired:      atomic.LoadInt64(&counters.buckets),
+	}
+	if lastRun := atomic.LoadInt64(&counters.lastRun); lastRun != 0 {
+		stats.LastRun = time.Unix(0, lastRun)
+	}
+
+	cm.nodesMu.RLock()
+	stats.Nodes = len(cm.nodes)
+	cm.nodesMu.RUnlock()
+
+	cm.listenersMu.RLock()
+	stats.Listeners = len(cm.listeners)
+	cm.listenersMu.RUnlock()
+	stats.Subscriptions = cm.subscriptions.count()
+
+	if rs, ok := cm.service.(*remoteService); ok {
+		rs.callsMu.RLock()
+		stats.PendingCalls = len(rs.pendingCalls)
+		rs.callsMu.RUnlock()
+
+		rs.sweeper.mu.Lock()
+		stats.SweepEpochs = len(rs.sweeper.epochs)
+		for _, watches := range rs.sweeper.watches {
+			stats.ActorWatches += len(watches)
+		}
+		rs.sweeper.mu.Unlock()
+
+		rs.securityMu.RLock()
+		if rs.limiter != nil {
+			stats.RateBuckets = rs.limiter.bucketCount()
+		}
+		rs.securityMu.RUnlock()
+	}
+
+	if sr, ok := cm.registry.(*serviceRegistry); ok {
+		sr.watchersMu.RLock()
+		for _, watchers := range sr.watchers {
+			stats.ServiceWatchers += len(watchers)
+		}
+		sr.watchersMu.RUnlock()
+	}
+
+	return stats
+}
+
+// compactListeners drops listeners whose context is done
+func (cm *clusterManager) compactListeners() int {
+	cm.listenersMu.Lock()
+	defer cm.listenersMu.Unlock()
+
+	kept := cm.listeners[:0]
+	for _, listener := range cm.listeners {
+		if !listener.expired() {
+			kept = append(kept, listener)
+		}
+	}
+	removed := len(cm.listeners) - len(kept)
+	for i := len(kept); i < len(cm.listeners); i++ {
+		cm.listeners[i] = eventListener{}
+	}
+	cm.listeners = kept
+	return removed
+}
+
+// compactNodes forgets nodes that failed or left longer than DeadNodeTTL ago
+func (cm *clusterManager) compactNodes(now time.Time) int {
+	ttl := cm.config.Compaction.DeadNodeTTL
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl < cm.config.QuarantinePeriod {
+		// A quarantined node must stay known to be refused
+		ttl = cm.config.QuarantinePeriod
+	}
+
+	cm.nodesMu.Lock()
+	defer cm.nodesMu.Unlock()
+
+	removed := 0
+	for id, node := range cm.nodes {
+		if id == cm.localNode.ID() {
+			continue
+		}
+		info := node.Info()
+		if info.State != NodeStateFailed && info.State != NodeStateLeft {
+			continue
+		}
+		if now.Sub(info.StateChange) > ttl {
+			delete(cm.nodes, id)
+			cm.forgetPeer(id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// isMember reports whether a node is still in the member list
+func (cm *clusterManager) isMember(nodeID NodeID) bool {
+	_, exists := cm.GetNode(nodeID)
+	return exists
+}
+
+// compactPendingCalls drops calls abandoned for longer than grace past
+// their deadline, failing them in case a caller is still waiting
+func (rs *remoteService) compactPendingCalls(now time.Time, grace time.Duration) int {
+	rs.callsMu.Lock()
+	defer rs.callsMu.Unlock()
+
+	removed := 0
+	for id, pending := range rs.pendingCalls {
+		if now.Sub(pending.timeout) <= grace {
+			continue
+		}
+		select {
+		case pending.error <- ErrCallAbandoned:
+		default:
+		}
+		delete(rs.pendingCalls, id)
+		removed++
+	}
+	return removed
+}
+
+// compactSweeper drops empty watch sets and the epochs of nodes that are
+// no longer members. References to such nodes stay refused because their
+// node is not a member
+func (rs *remoteService) compactSweeper(isMember func(NodeID) bool) int {
+	s := rs.sweeper
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for nodeID, watches := range s.watches {
+		if len(watches) == 0 {
+			delete(s.watches, nodeID)
+			removed++
+		}
+	}
+	for nodeID := range s.epochs {
+		if _, watched := s.watches[nodeID]; !watched && !isMember(nodeID) {
+			delete(s.epochs, nodeID)
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,151 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// newCompactionManager creates a manager wired to a remote service and
+// registry, with compaction TTLs short enough to expire within a test
+func newCompactionManager() (*clusterManager, *remoteService) {
+	config := DefaultClusterConfig()
+	config.NodeID = "local"
+	config.QuarantinePeriod = 0
+	config.Compaction = CompactionConfig{
+		DeadNodeTTL:   time.Millisecond,
+		IdleBucketTTL: time.Millisecond,
+	}
+	manager := NewClusterManager(config).(*clusterManager)
+
+	rs := NewRemoteService(manager).(*remoteService)
+	rs.SetRateLimiter(NewRateLimiter(RateLimit{Rate: 100, Burst: 10}))
+	registry := NewServiceRegistry(manager).(*serviceRegistry)
+	rs.registry = registry
+	manager.service = rs
+	manager.registry = registry
+	return manager, rs
+}
+
+// TestCompactionExpiresAbandonedState tests what a single pass expires
+func TestCompactionExpiresAbandonedState(t *testing.T) {
+	manager, rs := newCompactionManager()
+
+	live := NewRemoteNode(&NodeInfo{ID: "live", State: NodeStateActive})
+	dead := NewRemoteNode(&NodeInfo{ID: "dead", State: NodeStateFailed, StateChange: time.Now()})
+	manager.addNode(live)
+	manager.addNode(dead)
+	rs.SweepNode("dead")
+
+	abandoned := &pendingCall{id: "abandoned", error: make(chan error, 1), timeout: time.Now()}
+	waiting := &pendingCall{id: "waiting", error: make(chan error, 1), timeout: time.Now().Add(time.Hour)}
+	rs.pendingCalls[abandoned.id] = abandoned
+	rs.pendingCalls[waiting.id] = waiting
+
+	ctx, cancel := context.WithCancel(context.Background())
+	manager.AddEventListenerContext(ctx, func(ClusterEvent) {})
+	cancel()
+
+	time.Sleep(5 * time.Millisecond)
+	stats := manager.Compact()
+
+	if _, exists := manager.GetNode("dead"); exists {
+		t.Error("Expected failed node to be forgotten")
+	}
+	if _, exists := manager.GetNode("live"); !exists {
+		t.Error("Expected active node to be kept")
+	}
+	if stats.NodesExpired != 1 || stats.PendingCallsExpired != 1 || stats.ListenersExpired != 1 {
+		t.Errorf("Unexpected expiry counters: %+v", stats)
+	}
+	if stats.PendingCalls != 1 || stats.SweepEpochs != 0 {
+		t.Errorf("Unexpected gauges: %+v", stats)
+	}
+
+	select {
+	case err := <-abandoned.error:
+		if !errors.Is(err, ErrCallAbandoned) {
+			t.Errorf("Expected ErrCallAbandoned, got %v", err)
+		}
+	default:
+		t.Error("Expected abandoned call to be failed")
+	}
+
+	// References resolved after the node was swept stay stale once its
+	// epoch is compacted away
+	if err := rs.sweeper.checkRef(RemoteActorRef{NodeID: "dead", Epoch: 1}); !errors.Is(err, ErrNodeLost) {
+		t.Errorf("Expected stale ref to stay refused, got %v", err)
+	}
+}
+
+// TestCompactionBoundsWeekOfChurn simulates a week of hourly churn and
+// checks that bookkeeping maps and heap stay bounded
+func TestCompactionBoundsWeekOfChurn(t *testing.T) {
+	const (
+		hours        = 7 * 24
+		nodesPerHour = 5
+		callsPerHour = 20
+	)
+
+	manager, rs := newCompactionManager()
+	manager.addNode(NewRemoteNode(&NodeInfo{ID: "stable", State: NodeStateActive}))
+
+	var baseline runtime.MemStats
+	for hour := 0; hour < hours; hour++ {
+		for i := 0; i < nodesPerHour; i++ {
+			id := NodeID(fmt.Sprintf("node-%d-%d", hour, i))
+			manager.addNode(NewRemoteNode(&NodeInfo{ID: id, State: NodeStateActive}))
+
+			_, unwatch := rs.WatchActor(RemoteActorRef{NodeID: id, ActorID: "svc"})
+			unwatch()
+			rs.throttle(id, "svc")
+
+			node, _ := manager.GetNode(id)
+			node.UpdateState(NodeStateFailed)
+			rs.SweepNode(id)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			manager.AddEventListenerContext(ctx, func(ClusterEvent) {})
+			cancel()
+		}
+
+		rs.callsMu.Lock()
+		for i := 0; i < callsPerHour; i++ {
+			id := fmt.Sprintf("call-%d-%d", hour, i)
+			rs.pendingCalls[id] = &pendingCall{id: id, error: make(chan error, 1), timeout: time.Now()}
+		}
+		rs.callsMu.Unlock()
+
+		time.Sleep(2 * time.Millisecond)
+		stats := manager.Compact()
+
+		if stats.Nodes != 1 || stats.PendingCalls != 0 || stats.SweepEpochs != 0 ||
+			stats.ActorWatches != 0 || stats.RateBuckets != 0 {
+			t.Fatalf("Hour %d: bookkeeping not compacted: %+v", hour, stats)
+		}
+		// The listener of the remote service itself stays registered
+		if stats.Listeners != 1 {
+			t.Fatalf("Hour %d: expected 1 listener, got %d", hour, stats.Listeners)
+		}
+
+		if hour == 24 {
+			runtime.GC()
+			runtime.ReadMemStats(&baseline)
+		}
+	}
+
+	var final runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&final)
+	if growth := int64(final.HeapAlloc) - int64(baseline.HeapAlloc); growth > 4<<20 {
+		t.Errorf("Heap grew by %d bytes over six simulated days", growth)
+	}
+
+	stats := manager.CompactionStats()
+	if stats.NodesExpired != hours*nodesPerHour || stats.PendingCallsExpired != hours*callsPerHour {
+		t.Errorf("Unexpected expiry totals: %+v", stats)
+	}
+}
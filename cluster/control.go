@@ -0,0 +1,383 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Control log messages: the leader sends commands, followers acknowledge
+// the index they applied
+const (
+	MessageTypeControlCommand MessageType = "control_command"
+	MessageTypeControlAck     MessageType = "control_ack"
+)
+
+// EventControlApplied is published when a control command is applied on
+// the local node
+const EventControlApplied ClusterEventType = "control_applied"
+
+var (
+	// ErrNotLeader is returned when a command is proposed on a follower
+	ErrNotLeader = errors.New("local node is not the leader")
+
+	// ErrUnknownControlKind is returned for commands without an applier
+	ErrUnknownControlKind = errors.New("unknown control command kind")
+)
+
+// ControlCommand is an entry of the control log. Index orders the
+// commands of the whole cluster, starting at 1.
+type ControlCommand struct {
+	Index    uint64          `json:"index"`
+	Term     uint64          `json:"term"`
+	Leader   NodeID          `json:"leader"`
+	Kind     string          `json:"kind"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	IssuedBy string          `json:"issued_by"`
+	IssuedAt time.Time       `json:"issued_at"`
+}
+
+// ControlApplier applies the commands of one kind to the local node. It is
+// called exactly once per command, in index order, and must not propose
+// commands itself.
+type ControlApplier func(ctx context.Context, cmd ControlCommand) error
+
+// ControlFailure records a command whose applier failed on the local node
+type ControlFailure struct {
+	Index uint64 `json:"index"`
+	Kind  string `json:"kind"`
+	Error string `json:"error"`
+}
+
+// ControlLogStatus reports the progress of the control log on this node
+type ControlLogStatus struct {
+	// Applied is the index of the last command applied locally
+	Applied uint64 `json:"applied"`
+
+	// Term is the highest leadership term commands were accepted from
+	Term uint64 `json:"term"`
+
+	// Acks holds the index each follower acknowledged, on the leader
+	Acks map[NodeID]uint64 `json:"acks,omitempty"`
+
+	// Failures lists the commands whose applier returned an error
+	Failures []ControlFailure `json:"failures,omitempty"`
+}
+
+// Lagging returns the followers that acknowledged less than the applied
+// index, ordered by ID
+func (s ControlLogStatus) Lagging() []NodeID {
+	var lagging []NodeID
+	for node, acked := range s.Acks {
+		if acked < s.Applied {
+			lagging = append(lagging, node)
+		}
+	}
+	sort.Slice(lagging, func(i, j int) bool { return lagging[i] < lagging[j] })
+	return lagging
+}
+
+// controlAck is the payload of a control acknowledgement. Gap is set when
+// the follower received a command beyond the next index and needs the
+// missing ones resent.
+type controlAck struct {
+	Applied uint64 `json:"applied"`
+	Gap     bool   `json:"gap,omitempty"`
+}
+
+// controlLog is the control log of a cluster manager. Every command is
+// applied by every node in the same order, and acknowledged to the
+// leader, which resends whatever a lagging node missed. Commands are
+// kept for the lifetime of the node so a node joining late catches up on
+// all of them.
+type controlLog struct {
+	mu       sync.Mutex
+	appliers map[string]ControlApplier
+	entries  []ControlCommand // entries[i].Index == i+1
+	term     uint64
+	acks     map[NodeID]uint64
+	failures []ControlFailure
+}
+
+// RegisterControlApplier registers the applier of a command kind. Register
+// every kind on every node before commands of that kind are proposed: a
+// node without the applier records the command as failed.
+func (cm *clusterManager) RegisterControlApplier(kind string, applier ControlApplier) error {
+	cm.control.mu.Lock()
+	defer cm.control.mu.Unlock()
+
+	if cm.control.appliers == nil {
+		cm.control.appliers = make(map[string]ControlApplier)
+	}
+	if _, exists := cm.control.appliers[kind]; exists {
+		return fmt.Errorf("control applier %s already registered", kind)
+	}
+	cm.control.appliers[kind] = applier
+	return nil
+}
+
+// ProposeControl appends a command to the control log, applies it locally
+// and sends it to every node. Only the leader can propose commands; a node
+// taking over leadership continues from its own log, so it should be
+// caught up when elected.
+func (cm *clusterManager) ProposeControl(ctx context.Context, kind string, payload interface{}, by string) (ControlCommand, error) {
+	if by == "" {
+		return ControlCommand{}, fmt.Errorf("control command requires an operator identity")
+	}
+	token, ok := cm.FencingToken()
+	if !ok {
+		return ControlCommand{}, ErrNotLeader
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return ControlCommand{}, fmt.Errorf("failed to serialize control payload: %w", err)
+	}
+
+	cm.control.mu.Lock()
+	if _, exists := cm.control.appliers[kind]; !exists {
+		cm.control.mu.Unlock()
+		return ControlCommand{}, fmt.Errorf("%w: %s", ErrUnknownControlKind, kind)
+	}
+	cmd := ControlCommand{
+		Index:    uint64(len(cm.control.entries)) + 1,
+		Term:     token.Term,
+		Leader:   token.Leader,
+		Kind:     kind,
+		Payload:  data,
+		IssuedBy: by,
+		IssuedAt: time.Now(),
+	}
+	cm.control.term = token.Term
+	cm.applyControlLocked(ctx, cmd)
+	cm.control.mu.Unlock()
+
+	if err := cm.sendControl(ctx, "", []ControlCommand{cmd}); err != nil {
+		return cmd, fmt.Errorf("control command applied locally but propagation failed: %w", err)
+	}
+	return cmd, nil
+}
+
+// ControlLog returns the progress of the control log
+func (cm *clusterManager) ControlLog() ControlLogStatus {
+	cm.control.mu.Lock()
+	defer cm.control.mu.Unlock()
+
+	status := ControlLogStatus{
+		Applied:  uint64(len(cm.control.entries)),
+		Term:     cm.control.term,
+		Failures: append([]ControlFailure(nil), cm.control.failures...),
+	}
+	if cm.IsLeader() {
+		status.Acks = make(map[NodeID]uint64, len(cm.control.acks))
+		for node, acked := range cm.control.acks {
+			status.Acks[node] = acked
+		}
+	}
+	return status
+}
+
+// ControlCommands returns the commands applied after index after
+func (cm *clusterManager) ControlCommands(after uint64) []ControlCommand {
+	cm.control.mu.Lock()
+	defer cm.control.mu.Unlock()
+	return cm.controlAfterLocked(after)
+}
+
+// controlAfterLocked copies the entries after index after; callers hold
+// control.mu
+func (cm *clusterManager) controlAfterLocked(after uint64) []ControlCommand {
+	if after >= uint64(len(cm.control.entries)) {
+		return nil
+	}
+	return append([]ControlCommand(nil), cm.control.entries[after:]...)
+}
+
+// applyControlLocked applies the next command and appends it to the log;
+// callers hold control.mu
+func (cm *clusterManager) applyControlLocked(ctx context.Context, cmd ControlCommand) {
+	applier, exists := cm.control.appliers[cmd.Kind]
+	var err error
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrUnknownControlKind, cmd.Kind)
+	} else {
+		err = callControlApplier(ctx, applier, cmd)
+	}
+	if err != nil {
+		cm.control.failures = append(cm.control.failures, ControlFailure{Index: cmd.Index, Kind: cmd.Kind, Error: err.Error()})
+	}
+	cm.control.entries = append(cm.control.entries, cmd)
+
+	cm.publishEvent(ClusterEvent{
+		Type:      EventControlApplied,
+		NodeID:    cmd.Leader,
+		Timestamp: time.Now(),
+		Payload:   ControlApplied{Command: cmd},
+		Data: map[string]interface{}{
+			"index": cmd.Index,
+			"kind":  cmd.Kind,
+		},
+	})
+}
+
+// callControlApplier calls applier, turning a panic into an error
+func callControlApplier(ctx context.Context, applier ControlApplier, cmd ControlCommand) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("control applier panicked: %v", r)
+		}
+	}()
+	return applier(ctx, cmd)
+}
+
+// handleControlCommands applies the commands sent by the leader that are
+// next in order, skipping duplicates, and acknowledges the resulting
+// index. Only the leader of the current cluster term is heard: commands
+// of its term must name it as their leader, and commands of past terms are
+// accepted as the history of its log. With an authenticator set, only a
+// signed batch from a known node is considered.
+func (cm *clusterManager) handleControlCommands(ctx context.Context, from NodeID, message *ClusterMessage) error {
+	if err := cm.verifyClusterMessage(from, message); err != nil {
+		return err
+	}
+
+	var cmds []ControlCommand
+	if err := json.Unmarshal(message.Payload, &cmds); err != nil {
+		return fmt.Errorf("failed to parse control commands: %w", err)
+	}
+	leader, term := cm.leaderTerm()
+	if leader == "" || from != leader {
+		return fmt.Errorf("%w: control commands from %s, leader is %q", ErrNotFromLeader, from, leader)
+	}
+
+	cm.control.mu.Lock()
+	ack := controlAck{}
+	var refused error
+	for _, cmd := range cmds {
+		if cmd.Term > term || (cmd.Term == term && cmd.Leader != from) {
+			refused = fmt.Errorf("%w: command %d of term %d by %s, current term %d", ErrNotFromLeader, cmd.Index, cmd.Term, cmd.Leader, term)
+			break
+		}
+		// Terms never decrease along the log
+		if cmd.Term < cm.control.term {
+			continue
+		}
+		applied := uint64(len(cm.control.entries))
+		if cmd.Index <= applied {
+			continue
+		}
+		if cmd.Index > applied+1 {
+			ack.Gap = true
+			break
+		}
+		cm.control.term = cmd.Term
+		cm.applyControlLocked(ctx, cmd)
+	}
+	ack.Applied = uint64(len(cm.control.entries))
+	cm.control.mu.Unlock()
+	if refused != nil {
+		return refused
+	}
+
+	payload, err := json.Marshal(ack)
+	if err != nil {
+		return fmt.Errorf("failed to serialize control ack: %w", err)
+	}
+	reply := &ClusterMessage{
+		ID:        generateMessageID(),
+		Type:      MessageTypeControlAck,
+		From:      cm.localNode.ID(),
+		To:        from,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	}
+	if err := cm.signClusterMessage(reply); err != nil {
+		return fmt.Errorf("failed to sign control ack: %w", err)
+	}
+	return cm.transport.Send(ctx, from, reply)
+}
+
+// handleControlAck records the index a follower applied, resending what
+// it missed when it reports a gap. With an authenticator set, only a
+// signed ack from a known node is recorded.
+func (cm *clusterManager) handleControlAck(ctx context.Context, from NodeID, message *ClusterMessage) error {
+	if err := cm.verifyClusterMessage(from, message); err != nil {
+		return err
+	}
+
+	var ack controlAck
+	if err := json.Unmarshal(message.Payload, &ack); err != nil {
+		return fmt.Errorf("failed to parse control ack: %w", err)
+	}
+	if !cm.IsLeader() {
+		return nil
+	}
+
+	cm.control.mu.Lock()
+	if cm.control.acks == nil {
+		cm.control.acks = make(map[NodeID]uint64)
+	}
+	if ack.Applied > cm.control.acks[from] {
+		cm.control.acks[from] = ack.Applied
+	}
+	var missing []ControlCommand
+	if ack.Gap {
+		missing = cm.controlAfterLocked(ack.Applied)
+	}
+	cm.control.mu.Unlock()
+
+	if len(missing) == 0 {
+		return nil
+	}
+	return cm.sendControl(ctx, from, missing)
+}
+
+// catchUpControl sends a reconnected node the commands it has not
+// acknowledged, while the local node is leader
+func (cm *clusterManager) catchUpControl(nodeID NodeID) {
+	if !cm.IsLeader() || cm.transport == nil {
+		return
+	}
+
+	cm.control.mu.Lock()
+	missing := cm.controlAfterLocked(cm.control.acks[nodeID])
+	cm.control.mu.Unlock()
+
+	if len(missing) > 0 {
+		go cm.sendControl(cm.ctx, nodeID, missing)
+	}
+}
+
+// sendControl sends commands to a node, or to every node if to is empty
+func (cm *clusterManager) sendControl(ctx context.Context, to NodeID, cmds []ControlCommand) error {
+	if cm.transport == nil {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	payload, err := json.Marshal(cmds)
+	if err != nil {
+		return fmt.Errorf("failed to serialize control commands: %w", err)
+	}
+	msg := &ClusterMessage{
+		ID:        generateMessageID(),
+		Type:      MessageTypeControlCommand,
+		From:      cm.localNode.ID(),
+		To:        to,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	}
+	if err := cm.signClusterMessage(msg); err != nil {
+		return fmt.Errorf("failed to sign control commands: %w", err)
+	}
+
+	if to == "" {
+		return cm.transport.Broadcast(ctx, msg)
+	}
+	return cm.transport.Send(ctx, to, msg)
+}
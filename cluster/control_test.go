@@ -0,0 +1,194 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// linkTransport delivers messages straight to the managers of other
+// nodes, dropping those sent to nodes marked down
+type linkTransport struct {
+	MessageTransport
+	from  NodeID
+	peers map[NodeID]*clusterManager
+
+	mu   sync.Mutex
+	down map[NodeID]bool
+}
+
+func (lt *linkTransport) Send(ctx context.Context, nodeID NodeID, message *ClusterMessage) error {
+	lt.mu.Lock()
+	down := lt.down[nodeID]
+	lt.mu.Unlock()
+	if down {
+		return nil
+	}
+	return lt.peers[nodeID].HandleMessage(ctx, lt.from, message)
+}
+
+func (lt *linkTransport) Broadcast(ctx context.Context, message *ClusterMessage) error {
+	for nodeID := range lt.peers {
+		if nodeID != lt.from {
+			lt.Send(ctx, nodeID, message)
+		}
+	}
+	return nil
+}
+
+func (lt *linkTransport) setDown(nodeID NodeID, down bool) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.down[nodeID] = down
+}
+
+// controlCluster links managers for nodes, each recording the "fence"
+// commands it applies, and makes the first one leader
+func controlCluster(t *testing.T, nodes ...NodeID) (map[NodeID]*clusterManager, map[NodeID]*[]string) {
+	t.Helper()
+	managers := make(map[NodeID]*clusterManager)
+	applied := make(map[NodeID]*[]string)
+	for _, id := range nodes {
+		config := DefaultClusterConfig()
+		config.NodeID = id
+		cm := NewClusterManager(config).(*clusterManager)
+		cm.transport = &linkTransport{from: id, peers: managers, down: make(map[NodeID]bool)}
+
+		fenced := &[]string{}
+		cm.RegisterControlApplier("fence", func(ctx context.Context, cmd ControlCommand) error {
+			var node string
+			json.Unmarshal(cmd.Payload, &node)
+			*fenced = append(*fenced, node)
+			return nil
+		})
+		managers[id], applied[id] = cm, fenced
+	}
+	managers[nodes[0]].electSelf()
+	return managers, applied
+}
+
+func TestControlLogOrdering(t *testing.T) {
+	managers, applied := controlCluster(t, "a", "b", "c")
+	leader := managers["a"]
+	ctx := context.Background()
+
+	if _, err := managers["b"].ProposeControl(ctx, "fence", "x", "alice"); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Expected ErrNotLeader on a follower, got %v", err)
+	}
+	if _, err := leader.ProposeControl(ctx, "drain", "x", "alice"); !errors.Is(err, ErrUnknownControlKind) {
+		t.Errorf("Expected ErrUnknownControlKind, got %v", err)
+	}
+
+	// c misses two commands, b misses one
+	transport := leader.transport.(*linkTransport)
+	leader.ProposeControl(ctx, "fence", "node-1", "alice")
+	transport.setDown("c", true)
+	leader.ProposeControl(ctx, "fence", "node-2", "alice")
+	transport.setDown("b", true)
+	leader.ProposeControl(ctx, "fence", "node-3", "alice")
+	transport.setDown("b", false)
+
+	// b sees a gap on the next command and gets the missing one resent
+	cmd, err := leader.ProposeControl(ctx, "fence", "node-4", "alice")
+	if err != nil || cmd.Index != 4 || cmd.Term != 1 {
+		t.Fatalf("Expected command 4 of term 1, got %+v (%v)", cmd, err)
+	}
+	if lagging := leader.ControlLog().Lagging(); !reflect.DeepEqual(lagging, []NodeID{"c"}) {
+		t.Errorf("Expected c lagging, got %v", lagging)
+	}
+
+	// c catches up once reconnected
+	transport.setDown("c", false)
+	leader.catchUpControl("c")
+	waitFor(t, func() bool { return managers["c"].ControlLog().Applied == 4 })
+
+	want := []string{"node-1", "node-2", "node-3", "node-4"}
+	for id, fenced := range applied {
+		if !reflect.DeepEqual(*fenced, want) {
+			t.Errorf("Expected %s to apply %v once in order, got %v", id, want, *fenced)
+		}
+	}
+	status := leader.ControlLog()
+	if status.Applied != 4 || status.Acks["b"] != 4 || status.Acks["c"] != 4 {
+		t.Errorf("Unexpected leader status %+v", status)
+	}
+
+	// Replays and commands of a deposed leader are ignored
+	old := managers["b"].ControlCommands(0)
+	payload, _ := json.Marshal(old)
+	managers["b"].HandleMessage(ctx, "a", &ClusterMessage{Type: MessageTypeControlCommand, Payload: payload})
+	stale, _ := json.Marshal([]ControlCommand{{Index: 5, Term: 0, Kind: "fence", Payload: json.RawMessage(`"node-5"`)}})
+	managers["b"].HandleMessage(ctx, "a", &ClusterMessage{Type: MessageTypeControlCommand, Payload: stale})
+	if len(*applied["b"]) != 4 {
+		t.Errorf("Expected b to apply nothing more, got %v", *applied["b"])
+	}
+
+	// Only the leader is heard, and only for commands of its own term
+	next, _ := json.Marshal([]ControlCommand{{Index: 5, Term: 1, Leader: "c", Kind: "fence", Payload: json.RawMessage(`"node-5"`)}})
+	if err := managers["b"].HandleMessage(ctx, "c", &ClusterMessage{Type: MessageTypeControlCommand, Payload: next}); !errors.Is(err, ErrNotFromLeader) {
+		t.Errorf("Expected commands of a follower refused, got %v", err)
+	}
+	if err := managers["b"].HandleMessage(ctx, "a", &ClusterMessage{Type: MessageTypeControlCommand, Payload: next}); !errors.Is(err, ErrNotFromLeader) {
+		t.Errorf("Expected a command naming another leader refused, got %v", err)
+	}
+	future, _ := json.Marshal([]ControlCommand{{Index: 5, Term: 7, Leader: "a", Kind: "fence", Payload: json.RawMessage(`"node-5"`)}})
+	if err := managers["b"].HandleMessage(ctx, "a", &ClusterMessage{Type: MessageTypeControlCommand, Payload: future}); !errors.Is(err, ErrNotFromLeader) {
+		t.Errorf("Expected a command of an unknown term refused, got %v", err)
+	}
+	if len(*applied["b"]) != 4 {
+		t.Errorf("Expected b to apply nothing more, got %v", *applied["b"])
+	}
+}
+
+func TestControlLogAuthenticated(t *testing.T) {
+	managers, applied := controlCluster(t, "a", "b")
+	authenticateCluster(managers)
+	leader, follower := managers["a"], managers["b"]
+	ctx := context.Background()
+
+	if _, err := leader.ProposeControl(ctx, "fence", "node-1", "alice"); err != nil {
+		t.Fatalf("Failed to propose: %v", err)
+	}
+	waitFor(t, func() bool { return leader.ControlLog().Acks["b"] == 1 })
+
+	// An unsigned batch claiming to come from the leader is refused
+	forged, _ := json.Marshal([]ControlCommand{{Index: 2, Term: 1, Leader: "a", Kind: "fence", Payload: json.RawMessage(`"node-2"`)}})
+	if err := follower.HandleMessage(ctx, "a", &ClusterMessage{Type: MessageTypeControlCommand, From: "a", Payload: forged}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected an unsigned command batch refused, got %v", err)
+	}
+	if want := []string{"node-1"}; !reflect.DeepEqual(*applied["b"], want) {
+		t.Errorf("Expected b to apply %v only, got %v", want, *applied["b"])
+	}
+
+	// So is an unsigned ack
+	ack, _ := json.Marshal(controlAck{Applied: 42})
+	if err := leader.HandleMessage(ctx, "b", &ClusterMessage{Type: MessageTypeControlAck, From: "b", Payload: ack}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected an unsigned ack refused, got %v", err)
+	}
+	if acked := leader.ControlLog().Acks["b"]; acked != 1 {
+		t.Errorf("Expected b's applied index kept at 1, got %d", acked)
+	}
+}
+
+func TestControlApplierFailure(t *testing.T) {
+	managers, _ := controlCluster(t, "a", "b")
+	managers["b"].RegisterControlApplier("config", func(ctx context.Context, cmd ControlCommand) error {
+		panic("bad config")
+	})
+	managers["a"].RegisterControlApplier("config", func(ctx context.Context, cmd ControlCommand) error {
+		return nil
+	})
+
+	ctx := context.Background()
+	managers["a"].ProposeControl(ctx, "config", map[string]int{"max_players": 100}, "bob")
+	managers["a"].ProposeControl(ctx, "fence", "node-1", "bob")
+
+	// A failed command is recorded and does not stall the ones after it
+	status := managers["b"].ControlLog()
+	if status.Applied != 2 || len(status.Failures) != 1 || status.Failures[0].Index != 1 {
+		t.Errorf("Expected command 1 failed and 2 applied, got %+v", status)
+	}
+}
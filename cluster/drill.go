@@ -0,0 +1,230 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrDrillIncomplete is returned with the report of a failover drill
+// whose milestones were not all reached before its timeout
+var ErrDrillIncomplete = errors.New("failover drill incomplete")
+
+// DefaultDrillTimeout bounds a failover drill run without a timeout
+const DefaultDrillTimeout = 5 * time.Minute
+
+// Drill milestones, as named in DrillReport.Missed
+const (
+	MilestoneSuspect    = "suspect"
+	MilestoneFailed     = "failed"
+	MilestoneReelection = "reelection"
+	MilestoneRehost     = "rehost"
+)
+
+// FailoverDrill describes a controlled failover, e.g. killing a node of a
+// staging cluster, measured from the node observing it
+type FailoverDrill struct {
+	// Target is the node the drill kills; it cannot be the observer
+	Target NodeID `json:"target"`
+
+	// Services are the services expected to be registered again on
+	// another node; leave it empty to skip measuring rehosting
+	Services []string `json:"services,omitempty"`
+
+	// Kill takes the target down, e.g. by stopping its process or
+	// severing its links with a chaos fault
+	Kill func(ctx context.Context) error `json:"-"`
+
+	// Timeout bounds the whole drill, DefaultDrillTimeout when zero
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// DrillReport holds the recovery times of a failover drill, measured from
+// the moment the target was killed. A time is zero for a milestone listed
+// in Missed.
+type DrillReport struct {
+	Target       NodeID    `json:"target"`
+	LeaderKilled bool      `json:"leader_killed"`
+	KilledAt     time.Time `json:"killed_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+
+	// TimeToSuspect is when the target was first suspected
+	TimeToSuspect time.Duration `json:"time_to_suspect"`
+
+	// TimeToFailed is when the target was declared failed
+	TimeToFailed time.Duration `json:"time_to_failed"`
+
+	// TimeToReelection is when another node was elected leader; only
+	// measured when the target was the leader
+	TimeToReelection time.Duration `json:"time_to_reelection,omitempty"`
+
+	// TimeToRehost is when each service was registered on another node
+	TimeToRehost map[string]time.Duration `json:"time_to_rehost,omitempty"`
+
+	// Missed lists the milestones not reached, e.g. "rehost:chat"
+	Missed []string `json:"missed,omitempty"`
+
+	// DroppedEvents counts events lost by the drill's subscription; the
+	// times may be late when it is not zero
+	DroppedEvents uint64 `json:"dropped_events,omitempty"`
+}
+
+// String renders the report as one line per milestone
+func (r DrillReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "failover drill of %s (leader: %v)\n", r.Target, r.LeaderKilled)
+	line := func(milestone string, d time.Duration) {
+		if d == 0 {
+			fmt.Fprintf(&b, "  %-24s missed\n", milestone)
+			return
+		}
+		fmt.Fprintf(&b, "  %-24s %v\n", milestone, d)
+	}
+	line(MilestoneSuspect, r.TimeToSuspect)
+	line(MilestoneFailed, r.TimeToFailed)
+	if r.LeaderKilled {
+		line(MilestoneReelection, r.TimeToReelection)
+	}
+	services := make([]string, 0, len(r.TimeToRehost))
+	for service := range r.TimeToRehost {
+		services = append(services, service)
+	}
+	for _, missed := range r.Missed {
+		if service, ok := strings.CutPrefix(missed, MilestoneRehost+":"); ok {
+			services = append(services, service)
+		}
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		line(MilestoneRehost+":"+service, r.TimeToRehost[service])
+	}
+	return b.String()
+}
+
+// JSON returns the report as indented JSON for archiving next to the
+// drill's runbook
+func (r DrillReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// RunFailoverDrill kills the drill's target and measures how long manager,
+// the observing node, takes to suspect it, declare it failed, elect a new
+// leader if the target led, and see each service registered elsewhere.
+// It returns once every milestone was reached, or with ErrDrillIncomplete
+// and a partial report when the timeout or ctx ends it first.
+func RunFailoverDrill(ctx context.Context, manager ClusterManager, drill FailoverDrill) (DrillReport, error) {
+	if drill.Kill == nil {
+		return DrillReport{}, fmt.Errorf("failover drill needs a kill function")
+	}
+	if drill.Target == manager.LocalNode().ID() {
+		return DrillReport{}, fmt.Errorf("failover drill cannot kill the observing node")
+	}
+	if _, exists := manager.GetNode(drill.Target); !exists {
+		return DrillReport{}, fmt.Errorf("node %s not found", drill.Target)
+	}
+	timeout := drill.Timeout
+	if timeout <= 0 {
+		timeout = DefaultDrillTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := DrillReport{Target: drill.Target}
+	if leader, ok := manager.GetLeader(); ok && leader.ID() == drill.Target {
+		report.LeaderKilled = true
+	}
+
+	pending := map[string]bool{MilestoneSuspect: true, MilestoneFailed: true}
+	if report.LeaderKilled {
+		pending[MilestoneReelection] = true
+	}
+	for _, service := range drill.Services {
+		pending[MilestoneRehost+":"+service] = true
+	}
+
+	// Subscribe before the kill so no milestone is missed
+	sub := manager.Subscribe(ctx, EventFilter{
+		Categories: []EventCategory{CategoryMembership, CategoryLeadership, CategoryServices},
+	}, 256)
+	defer sub.Close()
+
+	report.KilledAt = time.Now()
+	if err := drill.Kill(ctx); err != nil {
+		return report, fmt.Errorf("failed to kill %s: %w", drill.Target, err)
+	}
+
+	reach := func(milestone string, at time.Time) {
+		if !pending[milestone] {
+			return
+		}
+		delete(pending, milestone)
+		elapsed := at.Sub(report.KilledAt)
+		if elapsed <= 0 {
+			elapsed = time.Nanosecond
+		}
+		switch milestone {
+		case MilestoneSuspect:
+			report.TimeToSuspect = elapsed
+		case MilestoneFailed:
+			report.TimeToFailed = elapsed
+		case MilestoneReelection:
+			report.TimeToReelection = elapsed
+		default:
+			if report.TimeToRehost == nil {
+				report.TimeToRehost = make(map[string]time.Duration)
+			}
+			report.TimeToRehost[strings.TrimPrefix(milestone, MilestoneRehost+":")] = elapsed
+		}
+	}
+
+	for len(pending) > 0 {
+		var event ClusterEvent
+		var open bool
+		select {
+		case event, open = <-sub.C:
+		case <-ctx.Done():
+		}
+		if !open {
+			break
+		}
+
+		switch payload := event.Payload.(type) {
+		case NodeStateChanged:
+			if event.NodeID != drill.Target {
+				continue
+			}
+			switch payload.NewState {
+			case NodeStateSuspected:
+				reach(MilestoneSuspect, event.Timestamp)
+			case NodeStateFailed:
+				// A node failed without being suspected first was
+				// suspected no later than that
+				reach(MilestoneSuspect, event.Timestamp)
+				reach(MilestoneFailed, event.Timestamp)
+			}
+		case LeaderElected:
+			if payload.LeaderID != drill.Target {
+				reach(MilestoneReelection, event.Timestamp)
+			}
+		case ServiceChanged:
+			if event.Type == EventServiceRegistered && payload.Instance.NodeID != drill.Target {
+				reach(MilestoneRehost+":"+payload.Instance.ServiceID, event.Timestamp)
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	report.DroppedEvents = sub.Dropped()
+	if len(pending) > 0 {
+		for milestone := range pending {
+			report.Missed = append(report.Missed, milestone)
+		}
+		sort.Strings(report.Missed)
+		return report, fmt.Errorf("%w: missed %s", ErrDrillIncomplete, strings.Join(report.Missed, ", "))
+	}
+	return report, nil
+}
@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunFailoverDrill(t *testing.T) {
+	managers, _ := controlCluster(t, "a", "b", "c")
+	observer := managers["b"]
+	observer.addNode(NewRemoteNode(&NodeInfo{ID: "a", State: NodeStateActive}))
+	observer.addNode(NewRemoteNode(&NodeInfo{ID: "c", State: NodeStateActive}))
+	observer.leaderMu.Lock()
+	observer.leader = "a"
+	observer.leaderMu.Unlock()
+	observer.registry = NewServiceRegistry(observer)
+
+	ctx := context.Background()
+	if _, err := RunFailoverDrill(ctx, observer, FailoverDrill{Target: "b", Kill: func(context.Context) error { return nil }}); err == nil {
+		t.Error("Expected the observer refused as a target")
+	}
+
+	// Killing the leader walks it through suspected and failed, then b
+	// takes over and hosts its service
+	observer.config.SuspicionTimeout = 5 * time.Millisecond
+	observer.config.SuspicionMultiplier = 1
+	kill := func(ctx context.Context) error {
+		go func() {
+			time.Sleep(2 * time.Millisecond)
+			observer.HandleConnectionLost("a", errors.New("connection reset"))
+			if observer.detectFailures(); observer.IsLeader() {
+				t.Error("Expected a suspected node not failed before the timeout")
+			}
+			time.Sleep(10 * time.Millisecond)
+			observer.detectFailures()
+			observer.registry.RegisterService(ctx, "chat", nil)
+		}()
+		return nil
+	}
+	report, err := RunFailoverDrill(ctx, observer, FailoverDrill{
+		Target:   "a",
+		Services: []string{"chat"},
+		Kill:     kill,
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failover drill failed: %v", err)
+	}
+	if !report.LeaderKilled || report.TimeToSuspect <= 0 || report.TimeToFailed < report.TimeToSuspect ||
+		report.TimeToReelection < report.TimeToFailed || report.TimeToRehost["chat"] < report.TimeToReelection {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if text := report.String(); !strings.Contains(text, "rehost:chat") || strings.Contains(text, "missed") {
+		t.Errorf("Unexpected report text:\n%s", text)
+	}
+
+	// A service nobody rehosts is reported missed
+	report, err = RunFailoverDrill(ctx, observer, FailoverDrill{
+		Target:   "c",
+		Services: []string{"match"},
+		Kill: func(context.Context) error {
+			node, _ := observer.GetNode("c")
+			return node.UpdateState(NodeStateFailed)
+		},
+		Timeout: 50 * time.Millisecond,
+	})
+	if !errors.Is(err, ErrDrillIncomplete) {
+		t.Fatalf("Expected ErrDrillIncomplete, got %v", err)
+	}
+	if report.LeaderKilled || report.TimeToFailed <= 0 || len(report.Missed) != 1 || report.Missed[0] != "rehost:match" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+}
@@ -0,0 +1,288 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/najoast/sngo/core"
+)
+
+// EventLeaderLost is published when the local node stops being leader
+const EventLeaderLost ClusterEventType = "leader_lost"
+
+// ErrStaleFencingToken is returned for fencing tokens of a past term
+var ErrStaleFencingToken = errors.New("fencing token is stale")
+
+// FencingToken identifies a leadership term. Leader duties pass it along
+// with their writes, and the writes are refused once the term is over,
+// so a leader that lost leadership but has not noticed yet can't
+// overwrite the work of its successor.
+type FencingToken struct {
+	Term   uint64 `json:"term"`
+	Leader NodeID `json:"leader"`
+}
+
+// fencingTokenKey is the context key of the fencing token of a duty
+type fencingTokenKey struct{}
+
+// WithFencingToken returns ctx carrying a fencing token
+func WithFencingToken(ctx context.Context, token FencingToken) context.Context {
+	return context.WithValue(ctx, fencingTokenKey{}, token)
+}
+
+// FencingTokenFromContext returns the fencing token carried by ctx, as in
+// the context of a leader duty
+func FencingTokenFromContext(ctx context.Context) (FencingToken, bool) {
+	token, ok := ctx.Value(fencingTokenKey{}).(FencingToken)
+	return token, ok
+}
+
+// LeaderDuty is a job that runs only while the local node is leader. ctx
+// carries the token too and is cancelled as soon as leadership is lost.
+type LeaderDuty func(ctx context.Context, token FencingToken) error
+
+// LeaderDutyOptions configures a leader duty
+type LeaderDutyOptions struct {
+	// RestartDelay restarts a duty that returned or panicked while the
+	// node is still leader; 0 runs it once per term
+	RestartDelay time.Duration
+
+	// StopTimeout bounds how long losing leadership waits for the duty
+	// to return. A duty still running after it is abandoned, not
+	// stopped: it may keep running into the next term, and only the
+	// fencing token of its writes tells them apart from the new leader's.
+	StopTimeout time.Duration
+}
+
+// LeaderDutyStatus reports the state of a leader duty
+type LeaderDutyStatus struct {
+	Name        string    `json:"name"`
+	Running     bool      `json:"running"`
+	Term        uint64    `json:"term,omitempty"`
+	Starts      int       `json:"starts"`
+	LastStarted time.Time `json:"last_started,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// leaderDuty is a registered duty and its current run
+type leaderDuty struct {
+	name   string
+	fn     LeaderDuty
+	opts   LeaderDutyOptions
+	status LeaderDutyStatus
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// leaderDuties holds the duties of a cluster manager and the token of the
+// current term, nil while not leader
+type leaderDuties struct {
+	mu     sync.Mutex
+	duties map[string]*leaderDuty
+	token  *FencingToken
+}
+
+// RegisterLeaderDuty registers a duty, starting it at once if the local
+// node is leader
+func (cm *clusterManager) RegisterLeaderDuty(name string, duty LeaderDuty, opts LeaderDutyOptions) error {
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = 5 * time.Second
+	}
+
+	cm.duties.mu.Lock()
+	defer cm.duties.mu.Unlock()
+
+	if cm.duties.duties == nil {
+		cm.duties.duties = make(map[string]*leaderDuty)
+	}
+	if _, exists := cm.duties.duties[name]; exists {
+		return fmt.Errorf("leader duty %s already registered", name)
+	}
+
+	d := &leaderDuty{name: name, fn: duty, opts: opts, status: LeaderDutyStatus{Name: name}}
+	cm.duties.duties[name] = d
+	if cm.duties.token != nil {
+		cm.startDuty(d, *cm.duties.token)
+	}
+	return nil
+}
+
+// UnregisterLeaderDuty stops and removes a duty
+func (cm *clusterManager) UnregisterLeaderDuty(name string) {
+	cm.duties.mu.Lock()
+	d, exists := cm.duties.duties[name]
+	delete(cm.duties.duties, name)
+	cm.duties.mu.Unlock()
+
+	if exists {
+		cm.stopDuty(d)
+	}
+}
+
+// LeaderDuties returns the status of every registered duty, by name
+func (cm *clusterManager) LeaderDuties() []LeaderDutyStatus {
+	cm.duties.mu.Lock()
+	defer cm.duties.mu.Unlock()
+
+	statuses := make([]LeaderDutyStatus, 0, len(cm.duties.duties))
+	for _, d := range cm.duties.duties {
+		statuses = append(statuses, d.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// FencingToken returns the token of the current term if the local node
+// is leader
+func (cm *clusterManager) FencingToken() (FencingToken, bool) {
+	cm.duties.mu.Lock()
+	defer cm.duties.mu.Unlock()
+
+	if cm.duties.token == nil {
+		return FencingToken{}, false
+	}
+	return *cm.duties.token, true
+}
+
+// CheckFencingToken returns ErrStaleFencingToken unless token belongs to
+// the leader of the current cluster term, as known by the local node.
+// Terms are carried by leader announcements and grow cluster-wide, so any
+// node can check a token; resources outside the cluster use a FenceGuard.
+func (cm *clusterManager) CheckFencingToken(token FencingToken) error {
+	leader, term := cm.leaderTerm()
+	if leader == "" || token.Term != term || token.Leader != leader {
+		return fmt.Errorf("term %d of %s: %w", token.Term, token.Leader, ErrStaleFencingToken)
+	}
+	return nil
+}
+
+// StepDown gives up leadership, stopping every leader duty
+func (cm *clusterManager) StepDown() {
+	cm.leaderMu.Lock()
+	if cm.leader != cm.localNode.ID() {
+		cm.leaderMu.Unlock()
+		return
+	}
+	cm.leader = ""
+	term := cm.term
+	cm.leaderMu.Unlock()
+
+	cm.loseLeadership(term)
+}
+
+// loseLeadership stops the duties of term and announces the loss
+func (cm *clusterManager) loseLeadership(term uint64) {
+	cm.stopDuties()
+	cm.publishEvent(ClusterEvent{
+		Type:      EventLeaderLost,
+		NodeID:    cm.localNode.ID(),
+		Timestamp: time.Now(),
+		Payload:   LeaderLost{LeaderID: cm.localNode.ID(), Term: term},
+	})
+}
+
+// startDuties starts every duty for a new term
+func (cm *clusterManager) startDuties(token FencingToken) {
+	cm.duties.mu.Lock()
+	defer cm.duties.mu.Unlock()
+
+	cm.duties.token = &token
+	for _, d := range cm.duties.duties {
+		cm.startDuty(d, token)
+	}
+}
+
+// stopDuties ends the current term and waits for every duty to return
+func (cm *clusterManager) stopDuties() {
+	cm.duties.mu.Lock()
+	cm.duties.token = nil
+	duties := make([]*leaderDuty, 0, len(cm.duties.duties))
+	for _, d := range cm.duties.duties {
+		duties = append(duties, d)
+	}
+	cm.duties.mu.Unlock()
+
+	for _, d := range duties {
+		cm.stopDuty(d)
+	}
+}
+
+// startDuty runs d for the term of token; callers hold duties.mu
+func (cm *clusterManager) startDuty(d *leaderDuty, token FencingToken) {
+	if d.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(WithFencingToken(context.Background(), token))
+	d.cancel = cancel
+	d.done = make(chan struct{})
+	d.status.Running = true
+	d.status.Term = token.Term
+
+	go cm.runDuty(ctx, d, d.done, token)
+}
+
+// runDuty calls the duty until ctx is cancelled, restarting it after
+// RestartDelay if set
+func (cm *clusterManager) runDuty(ctx context.Context, d *leaderDuty, done chan struct{}, token FencingToken) {
+	defer close(done)
+
+	for {
+		cm.duties.mu.Lock()
+		d.status.Starts++
+		d.status.LastStarted = time.Now()
+		cm.duties.mu.Unlock()
+
+		err := callDuty(ctx, d.fn, token)
+
+		cm.duties.mu.Lock()
+		if err != nil && ctx.Err() == nil {
+			d.status.LastError = err.Error()
+		}
+		cm.duties.mu.Unlock()
+
+		if ctx.Err() != nil || d.opts.RestartDelay <= 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.opts.RestartDelay):
+		}
+	}
+}
+
+// callDuty calls fn, turning a panic into an error
+func callDuty(ctx context.Context, fn LeaderDuty, token FencingToken) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("leader duty panicked: %v", r)
+		}
+	}()
+	return fn(ctx, token)
+}
+
+// stopDuty cancels the run of d and waits up to StopTimeout for it
+func (cm *clusterManager) stopDuty(d *leaderDuty) {
+	cm.duties.mu.Lock()
+	cancel, done := d.cancel, d.done
+	d.cancel, d.done = nil, nil
+	d.status.Running = false
+	cm.duties.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(d.opts.StopTimeout):
+		core.ReportError("cluster", "duties", core.SeverityWarning, fmt.Errorf("leader duty %s did not stop within %v and was abandoned", d.name, d.opts.StopTimeout))
+	}
+}
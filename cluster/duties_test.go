@@ -0,0 +1,191 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/najoast/sngo/bootstrap"
+)
+
+// TestLeaderDutiesFollowLeadership tests that duties run only while the
+// node leads and that their tokens are fenced off after a step down
+func TestLeaderDutiesFollowLeadership(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "leader"
+	manager := NewClusterManager(config).(*clusterManager)
+
+	started := make(chan FencingToken, 4)
+	stopped := make(chan struct{}, 4)
+	err := manager.RegisterLeaderDuty("compaction", func(ctx context.Context, token FencingToken) error {
+		started <- token
+		<-ctx.Done()
+		stopped <- struct{}{}
+		return nil
+	}, LeaderDutyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to register duty: %v", err)
+	}
+	if err := manager.RegisterLeaderDuty("compaction", nil, LeaderDutyOptions{}); err == nil {
+		t.Error("Expected duplicate duty names to be refused")
+	}
+
+	select {
+	case <-started:
+		t.Fatal("Expected no duty to run before leadership")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	manager.electSelf()
+	var first FencingToken
+	select {
+	case first = <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Expected duty to start on election")
+	}
+	if first.Term != 1 || first.Leader != "leader" {
+		t.Errorf("Unexpected token %+v", first)
+	}
+	if err := manager.CheckFencingToken(first); err != nil {
+		t.Errorf("Expected current token to pass, got %v", err)
+	}
+	if status := manager.LeaderDuties(); len(status) != 1 || !status[0].Running || status[0].Starts != 1 {
+		t.Errorf("Unexpected duty status %+v", status)
+	}
+
+	manager.StepDown()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected duty to be cancelled on step down")
+	}
+	if manager.IsLeader() {
+		t.Error("Expected node not to lead after stepping down")
+	}
+	if err := manager.CheckFencingToken(first); !errors.Is(err, ErrStaleFencingToken) {
+		t.Errorf("Expected ErrStaleFencingToken after step down, got %v", err)
+	}
+
+	// A new term starts the duty again with a newer token
+	manager.electSelf()
+	select {
+	case second := <-started:
+		if second.Term != 2 {
+			t.Errorf("Expected term 2, got %d", second.Term)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected duty to restart in the new term")
+	}
+	if err := manager.CheckFencingToken(first); !errors.Is(err, ErrStaleFencingToken) {
+		t.Errorf("Expected the first term to stay fenced, got %v", err)
+	}
+
+	manager.UnregisterLeaderDuty("compaction")
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected unregistering to cancel the duty")
+	}
+	if len(manager.LeaderDuties()) != 0 {
+		t.Error("Expected no duties after unregistering")
+	}
+}
+
+// TestLeaderDutyRestartsAfterFailure tests restart and error reporting
+func TestLeaderDutyRestartsAfterFailure(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "leader"
+	manager := NewClusterManager(config).(*clusterManager)
+	manager.electSelf()
+
+	runs := make(chan struct{}, 8)
+	manager.RegisterLeaderDuty("rebalance", func(ctx context.Context, token FencingToken) error {
+		runs <- struct{}{}
+		panic("boom")
+	}, LeaderDutyOptions{RestartDelay: 5 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected duty to be restarted, got %d runs", i)
+		}
+	}
+	manager.StepDown()
+
+	status := manager.LeaderDuties()[0]
+	if status.Starts < 2 || status.Running || status.LastError == "" {
+		t.Errorf("Expected restarts and a recorded panic, got %+v", status)
+	}
+}
+
+// leaderOnlyService records the fencing token of each start
+type leaderOnlyService struct {
+	started chan FencingToken
+	stopped chan struct{}
+}
+
+func (s *leaderOnlyService) Name() string { return "matchmaker" }
+
+func (s *leaderOnlyService) Start(ctx context.Context) error {
+	token, _ := FencingTokenFromContext(ctx)
+	s.started <- token
+	return nil
+}
+
+func (s *leaderOnlyService) Stop(ctx context.Context) error {
+	s.stopped <- struct{}{}
+	return nil
+}
+
+func (s *leaderOnlyService) Health(ctx context.Context) (bootstrap.HealthStatus, error) {
+	return bootstrap.HealthStatus{State: bootstrap.HealthHealthy}, nil
+}
+
+// TestLeaderOnlyService tests that a leader-only bootstrap service runs
+// with the fencing token of each term
+func TestLeaderOnlyService(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "leader"
+	manager := NewClusterManager(config).(*clusterManager)
+
+	lm := bootstrap.NewLifecycleManager(bootstrap.NewContainer()).(*bootstrap.DefaultLifecycleManager)
+	lm.SetLeadership(NewLeadership(manager))
+	service := &leaderOnlyService{started: make(chan FencingToken, 2), stopped: make(chan struct{}, 2)}
+	if err := lm.RegisterLeaderOnly("matchmaker", service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	ctx := context.Background()
+	if err := lm.Start(ctx); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	if health, _ := lm.Health(ctx); health["matchmaker"].Message == "" {
+		t.Errorf("Expected the service standing by, got %+v", health["matchmaker"])
+	}
+
+	manager.electSelf()
+	select {
+	case token := <-service.started:
+		if err := manager.CheckFencingToken(token); err != nil {
+			t.Errorf("Expected the token of the current term, got %+v: %v", token, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the service started on election")
+	}
+	waitFor(t, func() bool { return lm.IsLeading("matchmaker") })
+
+	manager.StepDown()
+	select {
+	case <-service.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the service stopped on leadership loss")
+	}
+
+	if err := lm.Stop(ctx); err != nil {
+		t.Fatalf("Failed to stop: %v", err)
+	}
+	if duties := manager.LeaderDuties(); len(duties) != 0 {
+		t.Errorf("Expected the duty removed on stop, got %+v", duties)
+	}
+}
@@ -0,0 +1,255 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/najoast/sngo/core"
+)
+
+// MessageTypeLeaderAnnounce carries the fencing token of a leader to the
+// other nodes, which adopt its term if it is newer than the one they know
+const MessageTypeLeaderAnnounce MessageType = "leader_announce"
+
+// ErrNotFromLeader is returned for leader-only messages sent by a node
+// that is not the current leader
+var ErrNotFromLeader = errors.New("message not sent by the current leader")
+
+// TermStore persists the highest leadership term a node has seen, so a
+// restarted node never reuses a term the cluster already went through
+type TermStore interface {
+	// LoadTerm returns the stored term, 0 if none was stored
+	LoadTerm() (uint64, error)
+
+	// SaveTerm stores term durably
+	SaveTerm(term uint64) error
+}
+
+// FileTermStore is a TermStore keeping the term in a file
+type FileTermStore struct {
+	Path string
+}
+
+// LoadTerm reads the term from the file
+func (s FileTermStore) LoadTerm() (uint64, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read term: %w", err)
+	}
+	term, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse term file %s: %w", s.Path, err)
+	}
+	return term, nil
+}
+
+// SaveTerm replaces the file atomically, synced before and after the rename
+func (s FileTermStore) SaveTerm(term uint64) error {
+	tmp := s.Path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write term: %w", err)
+	}
+	if _, err := f.WriteString(strconv.FormatUint(term, 10)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write term: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync term: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write term: %w", err)
+	}
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return fmt.Errorf("failed to write term: %w", err)
+	}
+	if dir, err := os.Open(filepath.Dir(s.Path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// MemoryTermStore is a TermStore for tests and single-process clusters
+type MemoryTermStore struct {
+	mu   sync.Mutex
+	term uint64
+}
+
+// LoadTerm returns the stored term
+func (s *MemoryTermStore) LoadTerm() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.term, nil
+}
+
+// SaveTerm stores term
+func (s *MemoryTermStore) SaveTerm(term uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.term = term
+	return nil
+}
+
+// SetTermStore sets where the highest seen term is persisted. Without one
+// a restarted node learns the term from the leader's announcement only,
+// and a node electing itself before hearing it may reuse a past term.
+func (cm *clusterManager) SetTermStore(store TermStore) {
+	cm.leaderMu.Lock()
+	defer cm.leaderMu.Unlock()
+	cm.terms = store
+}
+
+// loadTerm raises the known term to the persisted one
+func (cm *clusterManager) loadTerm() error {
+	cm.leaderMu.Lock()
+	defer cm.leaderMu.Unlock()
+
+	if cm.terms == nil {
+		return nil
+	}
+	term, err := cm.terms.LoadTerm()
+	if err != nil {
+		return err
+	}
+	if term > cm.term {
+		cm.term = term
+	}
+	return nil
+}
+
+// saveTermLocked persists term; callers hold leaderMu
+func (cm *clusterManager) saveTermLocked(term uint64) error {
+	if cm.terms == nil {
+		return nil
+	}
+	return cm.terms.SaveTerm(term)
+}
+
+// leaderTerm returns the current leader and the cluster term
+func (cm *clusterManager) leaderTerm() (NodeID, uint64) {
+	cm.leaderMu.RLock()
+	defer cm.leaderMu.RUnlock()
+	return cm.leader, cm.term
+}
+
+// announceLeadership sends the local leader's token to a node, or to
+// every node if to is empty
+func (cm *clusterManager) announceLeadership(ctx context.Context, to NodeID, token FencingToken) error {
+	if cm.transport == nil {
+		return nil
+	}
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to serialize leader announce: %w", err)
+	}
+	msg := &ClusterMessage{
+		ID:        generateMessageID(),
+		Type:      MessageTypeLeaderAnnounce,
+		From:      cm.localNode.ID(),
+		To:        to,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	}
+	if err := cm.signClusterMessage(msg); err != nil {
+		return fmt.Errorf("failed to sign leader announce: %w", err)
+	}
+	if to == "" {
+		return cm.transport.Broadcast(ctx, msg)
+	}
+	return cm.transport.Send(ctx, to, msg)
+}
+
+// handleLeaderAnnounce adopts the leader and term announced by a peer if
+// they are newer than the known ones. Two leaders of the same term are
+// resolved in favour of the lower node ID; a stale leader is told about
+// the current term. With an authenticator set, only a signed announce
+// from a known node is considered.
+func (cm *clusterManager) handleLeaderAnnounce(ctx context.Context, from NodeID, message *ClusterMessage) error {
+	if err := cm.verifyClusterMessage(from, message); err != nil {
+		return err
+	}
+
+	var token FencingToken
+	if err := json.Unmarshal(message.Payload, &token); err != nil {
+		return fmt.Errorf("failed to parse leader announce: %w", err)
+	}
+	if token.Leader != from {
+		return fmt.Errorf("%w: %s announced %s as leader", ErrNotFromLeader, from, token.Leader)
+	}
+
+	local := cm.localNode.ID()
+	cm.leaderMu.Lock()
+	newer := token.Term > cm.term ||
+		(token.Term == cm.term && token.Leader != cm.leader && (cm.leader == "" || token.Leader < cm.leader))
+	if !newer {
+		current := FencingToken{Term: cm.term, Leader: cm.leader}
+		cm.leaderMu.Unlock()
+		if current.Leader == local && current != token {
+			return cm.announceLeadership(ctx, from, current)
+		}
+		return nil
+	}
+
+	if err := cm.saveTermLocked(token.Term); err != nil {
+		core.ReportError("cluster", "election", core.SeverityError, fmt.Errorf("failed to persist term %d: %w", token.Term, err))
+	}
+	wasLeader := cm.leader == local
+	previousTerm := cm.term
+	cm.term, cm.leader = token.Term, token.Leader
+	cm.leaderMu.Unlock()
+
+	if wasLeader {
+		cm.loseLeadership(previousTerm)
+	}
+	cm.publishEvent(ClusterEvent{
+		Type:      EventLeaderElected,
+		NodeID:    token.Leader,
+		Timestamp: time.Now(),
+		Payload:   LeaderElected{LeaderID: token.Leader, Term: token.Term},
+	})
+	return nil
+}
+
+// FenceGuard lets a resource outside the cluster, e.g. a database row or a
+// lock service, refuse the writes of a deposed leader: it admits a token
+// only if its term is at least the highest term admitted so far. Terms
+// grow cluster-wide, so this holds across nodes and restarts when every
+// node has a TermStore.
+type FenceGuard struct {
+	mu      sync.Mutex
+	highest FencingToken
+}
+
+// Admit records token and returns ErrStaleFencingToken if a newer term
+// was admitted before, or another leader was admitted for the same term
+func (g *FenceGuard) Admit(token FencingToken) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if token.Term < g.highest.Term || (token.Term == g.highest.Term && token.Leader != g.highest.Leader && g.highest.Leader != "") {
+		return fmt.Errorf("term %d of %s, admitted term %d of %s: %w",
+			token.Term, token.Leader, g.highest.Term, g.highest.Leader, ErrStaleFencingToken)
+	}
+	g.highest = token
+	return nil
+}
+
+// Highest returns the newest token admitted
+func (g *FenceGuard) Highest() FencingToken {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.highest
+}
@@ -0,0 +1,158 @@
+package cluster
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// authenticateCluster makes the managers sign and verify their messages
+// with HMAC keys known to every node
+func authenticateCluster(managers map[NodeID]*clusterManager) {
+	for id, cm := range managers {
+		rs := NewRemoteService(cm).(*remoteService)
+		auth := NewHMACAuthenticator(id, 0)
+		for peer := range managers {
+			auth.AddNode(peer, []byte("key-"+peer))
+		}
+		rs.SetAuthenticator(auth)
+		rs.SetAuditHandler(func(AuditEvent) {})
+		cm.service = rs
+	}
+}
+
+func TestLeaderAnnounceAgreesOnTerm(t *testing.T) {
+	managers, _ := controlCluster(t, "a", "b")
+	a, b := managers["a"], managers["b"]
+	if leader, term := b.leaderTerm(); leader != "a" || term != 1 {
+		t.Fatalf("Expected b to follow a in term 1, got %s in term %d", leader, term)
+	}
+	token, _ := a.FencingToken()
+	if err := b.CheckFencingToken(token); err != nil {
+		t.Errorf("Expected the leader's token to pass on a follower, got %v", err)
+	}
+
+	// b takes over during a partition, a keeps leading term 1
+	a.transport.(*linkTransport).setDown("b", true)
+	b.transport.(*linkTransport).setDown("a", true)
+	b.electSelf()
+	if _, term := b.leaderTerm(); term != 2 {
+		t.Fatalf("Expected b to lead term 2, got %d", term)
+	}
+
+	// Healing tells the stale leader about the newer term
+	a.transport.(*linkTransport).setDown("b", false)
+	b.transport.(*linkTransport).setDown("a", false)
+	a.HandleConnectionEstablished("b")
+	waitFor(t, func() bool { leader, _ := a.leaderTerm(); return leader == "b" })
+	if a.IsLeader() {
+		t.Error("Expected a to step down")
+	}
+	if err := a.CheckFencingToken(token); !errors.Is(err, ErrStaleFencingToken) {
+		t.Errorf("Expected the old token refused, got %v", err)
+	}
+
+	// A node can only announce itself
+	forged := &ClusterMessage{Type: MessageTypeLeaderAnnounce, Payload: []byte(`{"term":9,"leader":"c"}`)}
+	if err := a.HandleMessage(a.runContext(), "b", forged); !errors.Is(err, ErrNotFromLeader) {
+		t.Errorf("Expected a forged announce refused, got %v", err)
+	}
+}
+
+func TestLeaderAnnounceAuthenticated(t *testing.T) {
+	managers, _ := controlCluster(t, "a", "b", "c")
+	authenticateCluster(managers)
+	a, b := managers["a"], managers["b"]
+	ctx := a.runContext()
+
+	unsigned := &ClusterMessage{Type: MessageTypeLeaderAnnounce, From: "b", Payload: []byte(`{"term":99,"leader":"b"}`)}
+	if err := a.HandleMessage(ctx, "b", unsigned); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected an unsigned announce refused, got %v", err)
+	}
+
+	// b signing with a key of its own making is no better
+	rogue := NewHMACAuthenticator("b", 0)
+	rogue.AddNode("b", []byte("guessed"))
+	forged := &ClusterMessage{Type: MessageTypeLeaderAnnounce, From: "b", Payload: []byte(`{"term":99,"leader":"b"}`)}
+	rogue.Sign(forged)
+	if err := a.HandleMessage(ctx, "b", forged); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected a forged announce refused, got %v", err)
+	}
+	if leader, term := a.leaderTerm(); leader != "a" || term != 1 {
+		t.Fatalf("Expected a to keep leading term 1, got %s in term %d", leader, term)
+	}
+
+	// A signed announce is still adopted
+	b.electSelf()
+	waitFor(t, func() bool { leader, _ := a.leaderTerm(); return leader == "b" })
+	if leader, term := managers["c"].leaderTerm(); leader != "b" || term != 2 {
+		t.Errorf("Expected c to follow b in term 2, got %s in term %d", leader, term)
+	}
+}
+
+func TestLeaderAnnounceBreaksTies(t *testing.T) {
+	managers, _ := controlCluster(t, "c", "d")
+	c, d := managers["c"], managers["d"]
+	c.StepDown()
+	c.leaderMu.Lock()
+	c.term, c.leader = 0, ""
+	c.leaderMu.Unlock()
+	d.leaderMu.Lock()
+	d.term, d.leader = 0, ""
+	d.leaderMu.Unlock()
+
+	// Both elect themselves in term 1 without hearing each other
+	c.transport.(*linkTransport).setDown("d", true)
+	d.transport.(*linkTransport).setDown("c", true)
+	c.electSelf()
+	d.electSelf()
+	c.transport.(*linkTransport).setDown("d", false)
+	d.transport.(*linkTransport).setDown("c", false)
+
+	d.HandleConnectionEstablished("c")
+	waitFor(t, func() bool { leader, _ := d.leaderTerm(); return leader == "c" })
+	if !c.IsLeader() || d.IsLeader() {
+		t.Error("Expected the lower node ID to keep term 1")
+	}
+}
+
+func TestTermsSurviveRestarts(t *testing.T) {
+	store := FileTermStore{Path: filepath.Join(t.TempDir(), "term")}
+	if term, err := store.LoadTerm(); err != nil || term != 0 {
+		t.Fatalf("Expected no term yet, got %d (%v)", term, err)
+	}
+
+	config := DefaultClusterConfig()
+	config.NodeID = "a"
+	first := NewClusterManager(config).(*clusterManager)
+	first.SetTermStore(store)
+	first.electSelf()
+	first.StepDown()
+	first.electSelf()
+
+	restarted := NewClusterManager(config).(*clusterManager)
+	restarted.SetTermStore(store)
+	if err := restarted.loadTerm(); err != nil {
+		t.Fatalf("Failed to load term: %v", err)
+	}
+	restarted.electSelf()
+	if token, _ := restarted.FencingToken(); token.Term != 3 {
+		t.Errorf("Expected term 3 after a restart, got %+v", token)
+	}
+}
+
+func TestFenceGuard(t *testing.T) {
+	var guard FenceGuard
+	if err := guard.Admit(FencingToken{Term: 2, Leader: "a"}); err != nil {
+		t.Fatalf("Expected the first token admitted, got %v", err)
+	}
+	if err := guard.Admit(FencingToken{Term: 1, Leader: "b"}); !errors.Is(err, ErrStaleFencingToken) {
+		t.Errorf("Expected an older term refused, got %v", err)
+	}
+	if err := guard.Admit(FencingToken{Term: 2, Leader: "b"}); !errors.Is(err, ErrStaleFencingToken) {
+		t.Errorf("Expected a second leader of the same term refused, got %v", err)
+	}
+	if err := guard.Admit(FencingToken{Term: 3, Leader: "b"}); err != nil || guard.Highest().Leader != "b" {
+		t.Errorf("Expected a newer term admitted, got %v", err)
+	}
+}
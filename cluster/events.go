@@ -0,0 +1,292 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Service events published by the registry of the local node
+const (
+	EventServiceRegistered   ClusterEventType = "service_registered"
+	EventServiceUnregistered ClusterEventType = "service_unregistered"
+)
+
+// DefaultSubscriptionBuffer is the buffer of a subscription created
+// without one
+const DefaultSubscriptionBuffer = 64
+
+// EventCategory groups related cluster event types
+type EventCategory string
+
+const (
+	CategoryMembership EventCategory = "membership"
+	CategoryLeadership EventCategory = "leadership"
+	CategoryServices   EventCategory = "services"
+	CategoryPartitions EventCategory = "partitions"
+	CategoryAdmin      EventCategory = "admin"
+)
+
+// Category returns the category of an event type
+func (t ClusterEventType) Category() EventCategory {
+	switch t {
+	case EventNodeJoined, EventNodeLeft, EventNodeFailed, EventNodeRecovered,
+		EventDuplicateNode, EventNodeQuarantined:
+		return CategoryMembership
+	case EventLeaderElected, EventLeaderLost:
+		return CategoryLeadership
+	case EventServiceRegistered, EventServiceUnregistered:
+		return CategoryServices
+	case EventPartition, EventMerge:
+		return CategoryPartitions
+	default:
+		return CategoryAdmin
+	}
+}
+
+// EventPayload is the typed payload of a cluster event; switch on the
+// concrete type to read it
+type EventPayload interface {
+	eventPayload()
+}
+
+// NodeStateChanged is the payload of node joined, left, failed and
+// recovered events
+type NodeStateChanged struct {
+	OldState NodeState `json:"old_state"`
+	NewState NodeState `json:"new_state"`
+}
+
+// LeaderElected is the payload of leader election events
+type LeaderElected struct {
+	LeaderID NodeID `json:"leader_id"`
+	Term     uint64 `json:"term"`
+}
+
+// LeaderLost is the payload of events for the local node losing
+// leadership
+type LeaderLost struct {
+	LeaderID NodeID `json:"leader_id"`
+	Term     uint64 `json:"term"`
+}
+
+// DuplicateNodeRejected is the payload of duplicate node ID events
+type DuplicateNodeRejected struct {
+	JoinerAddress     string `json:"joiner_address"`
+	JoinerBootEpoch   int64  `json:"joiner_boot_epoch"`
+	ExistingAddress   string `json:"existing_address"`
+	ExistingBootEpoch int64  `json:"existing_boot_epoch"`
+}
+
+// NodeQuarantined is the payload of quarantined rejoin events
+type NodeQuarantined struct {
+	JoinerAddress   string        `json:"joiner_address"`
+	JoinerBootEpoch int64         `json:"joiner_boot_epoch"`
+	FailedAt        time.Time     `json:"failed_at"`
+	Remaining       time.Duration `json:"remaining"`
+}
+
+// PartitionChanged is the payload of partition detected and healed events
+type PartitionChanged struct {
+	Nodes []NodeID `json:"nodes"`
+}
+
+// ServiceChanged is the payload of service events
+type ServiceChanged struct {
+	Instance ServiceInstance `json:"instance"`
+}
+
+// ReadOnlyChanged is the payload of read-only mode events
+type ReadOnlyChanged struct {
+	State ReadOnlyState `json:"state"`
+}
+
+// StateImported is the payload of cluster state import events
+type StateImported struct {
+	ExportedAt time.Time    `json:"exported_at"`
+	Report     ImportReport `json:"report"`
+}
+
+// ControlApplied is the payload of control command events
+type ControlApplied struct {
+	Command ControlCommand `json:"command"`
+}
+
+// MigrationProgress is the payload of migration progress events; Node is
+// empty once the whole cluster completed the migration
+type MigrationProgress struct {
+	Migration string         `json:"migration"`
+	Node      NodeID         `json:"node,omitempty"`
+	State     MigrationState `json:"state,omitempty"`
+	Completed bool           `json:"completed,omitempty"`
+}
+
+// ChaosChanged is the payload of chaos injected and reverted events
+type ChaosChanged struct {
+	Fault ChaosFault `json:"fault"`
+}
+
+func (NodeStateChanged) eventPayload()      {}
+func (LeaderElected) eventPayload()         {}
+func (LeaderLost) eventPayload()            {}
+func (DuplicateNodeRejected) eventPayload() {}
+func (NodeQuarantined) eventPayload()       {}
+func (PartitionChanged) eventPayload()      {}
+func (ServiceChanged) eventPayload()        {}
+func (ReadOnlyChanged) eventPayload()       {}
+func (StateImported) eventPayload()         {}
+func (ChaosChanged) eventPayload()          {}
+func (ControlApplied) eventPayload()        {}
+func (MigrationProgress) eventPayload()     {}
+
+// EventFilter selects the events of a subscription. Empty fields match
+// everything; set fields must all match.
+type EventFilter struct {
+	Categories []EventCategory
+	Types      []ClusterEventType
+	Nodes      []NodeID
+
+	// Match is an optional predicate applied last
+	Match func(event ClusterEvent) bool
+}
+
+// matches reports whether the filter selects an event
+func (f EventFilter) matches(event ClusterEvent) bool {
+	if len(f.Categories) > 0 && !containsCategory(f.Categories, event.Type.Category()) {
+		return false
+	}
+	if len(f.Types) > 0 && !containsType(f.Types, event.Type) {
+		return false
+	}
+	if len(f.Nodes) > 0 && !containsNode(f.Nodes, event.NodeID) {
+		return false
+	}
+	return f.Match == nil || f.Match(event)
+}
+
+func containsCategory(categories []EventCategory, c EventCategory) bool {
+	for _, category := range categories {
+		if category == c {
+			return true
+		}
+	}
+	return false
+}
+
+func containsType(types []ClusterEventType, t ClusterEventType) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsNode(nodes []NodeID, id NodeID) bool {
+	for _, node := range nodes {
+		if node == id {
+			return true
+		}
+	}
+	return false
+}
+
+// EventSubscription delivers the events selected by a filter through its
+// own buffer, so a slow subscriber only loses its own events
+type EventSubscription struct {
+	// C receives the events; it is closed when the subscription ends
+	C <-chan ClusterEvent
+
+	ch      chan ClusterEvent
+	filter  EventFilter
+	dropped uint64 // atomic
+	once    sync.Once
+	owner   *eventSubscriptions
+}
+
+// Dropped returns the number of events lost to a full buffer
+func (s *EventSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close ends the subscription and closes C
+func (s *EventSubscription) Close() {
+	s.once.Do(func() {
+		s.owner.remove(s)
+	})
+}
+
+// eventSubscriptions holds the subscriptions of a cluster manager
+type eventSubscriptions struct {
+	mu   sync.RWMutex
+	subs map[*EventSubscription]struct{}
+}
+
+// add registers a subscription ending with ctx
+func (es *eventSubscriptions) add(ctx context.Context, filter EventFilter, buffer int) *EventSubscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+
+	ch := make(chan ClusterEvent, buffer)
+	sub := &EventSubscription{C: ch, ch: ch, filter: filter, owner: es}
+
+	es.mu.Lock()
+	if es.subs == nil {
+		es.subs = make(map[*EventSubscription]struct{})
+	}
+	es.subs[sub] = struct{}{}
+	es.mu.Unlock()
+
+	if done := ctx.Done(); done != nil {
+		go func() {
+			<-done
+			sub.Close()
+		}()
+	}
+	return sub
+}
+
+// remove unregisters a subscription and closes its channel
+func (es *eventSubscriptions) remove(sub *EventSubscription) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	delete(es.subs, sub)
+	close(sub.ch)
+}
+
+// publish delivers an event to every matching subscription without
+// blocking
+func (es *eventSubscriptions) publish(event ClusterEvent) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	for sub := range es.subs {
+		if !sub.filter.matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	}
+}
+
+// count returns the number of open subscriptions
+func (es *eventSubscriptions) count() int {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	return len(es.subs)
+}
+
+// Subscribe delivers the cluster events selected by filter until ctx is
+// done or the subscription is closed. Each subscription has its own
+// buffer of buffer events (0 means DefaultSubscriptionBuffer); events
+// arriving while it is full are dropped and counted.
+func (cm *clusterManager) Subscribe(ctx context.Context, filter EventFilter, buffer int) *EventSubscription {
+	return cm.subscriptions.add(ctx, filter, buffer)
+}
@@ -0,0 +1,127 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/najoast/sngo/core"
+)
+
+// receiveEvent waits for the next event of a subscription
+func receiveEvent(t *testing.T, sub *EventSubscription) ClusterEvent {
+	t.Helper()
+	select {
+	case event, ok := <-sub.C:
+		if !ok {
+			t.Fatal("Subscription closed unexpectedly")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	return ClusterEvent{}
+}
+
+// TestSubscribeFiltersAndTypesEvents tests that subscribers only see the
+// events they asked for, with typed payloads
+func TestSubscribeFiltersAndTypesEvents(t *testing.T) {
+	manager, _ := newCompactionManager()
+
+	membership := manager.Subscribe(context.Background(), EventFilter{
+		Categories: []EventCategory{CategoryMembership},
+		Nodes:      []NodeID{"watched"},
+	}, 0)
+	defer membership.Close()
+	services := manager.Subscribe(context.Background(), EventFilter{
+		Types: []ClusterEventType{EventServiceRegistered},
+	}, 0)
+	defer services.Close()
+
+	watched := NewRemoteNode(&NodeInfo{ID: "watched", State: NodeStateActive})
+	other := NewRemoteNode(&NodeInfo{ID: "other", State: NodeStateActive})
+	manager.addNode(watched)
+	manager.addNode(other)
+
+	other.UpdateState(NodeStateFailed)
+	watched.UpdateState(NodeStateFailed)
+
+	event := receiveEvent(t, membership)
+	if event.Type != EventNodeFailed || event.NodeID != "watched" {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+	change, ok := event.Payload.(NodeStateChanged)
+	if !ok || change.OldState != NodeStateActive || change.NewState != NodeStateFailed {
+		t.Errorf("Unexpected payload: %#v", event.Payload)
+	}
+
+	if err := manager.registry.RegisterService(context.Background(), "chat", nil); err != nil {
+		t.Fatalf("RegisterService failed: %v", err)
+	}
+	event = receiveEvent(t, services)
+	if changed, ok := event.Payload.(ServiceChanged); !ok || changed.Instance.ServiceID != "chat" {
+		t.Errorf("Unexpected service payload: %#v", event.Payload)
+	}
+
+	select {
+	case event := <-membership.C:
+		t.Errorf("Unexpected membership event: %+v", event)
+	default:
+	}
+}
+
+// TestSubscriptionDropsAndCloses tests that a full subscription drops
+// events and that it ends with its context
+func TestSubscriptionDropsAndCloses(t *testing.T) {
+	manager, _ := newCompactionManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := manager.Subscribe(ctx, EventFilter{}, 1)
+
+	for i := 0; i < 3; i++ {
+		manager.publishEvent(ClusterEvent{Type: EventNodeJoined, NodeID: "n"})
+	}
+	if sub.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped events, got %d", sub.Dropped())
+	}
+	if stats := manager.Compact(); stats.Subscriptions != 1 {
+		t.Errorf("Expected 1 subscription, got %d", stats.Subscriptions)
+	}
+
+	cancel()
+	<-sub.C
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-sub.C:
+			if !ok {
+				if manager.subscriptions.count() != 0 {
+					t.Error("Expected subscription to be removed")
+				}
+				sub.Close()
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected channel to close after cancel")
+		}
+	}
+}
+
+// TestEventsCarryNamespace tests that published events are labelled with
+// the telemetry namespace of the process
+func TestEventsCarryNamespace(t *testing.T) {
+	core.SetTelemetryConfig(core.TelemetryConfig{Namespace: "shard-3"})
+	defer core.SetTelemetryConfig(core.TelemetryConfig{})
+
+	manager, _ := newCompactionManager()
+	sub := manager.Subscribe(context.Background(), EventFilter{}, 0)
+	defer sub.Close()
+
+	node := NewRemoteNode(&NodeInfo{ID: "watched", State: NodeStateActive})
+	manager.addNode(node)
+	node.UpdateState(NodeStateFailed)
+
+	if event := receiveEvent(t, sub); event.Namespace != "shard-3" {
+		t.Errorf("Expected the event labelled shard-3, got %+v", event)
+	}
+}
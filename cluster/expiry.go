@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// otherPeers is the peer key that counts drops once the per-peer map
+	// is full
+	otherPeers NodeID = "_other"
+
+	// defaultMaxExpiryPeers is used when MaxExpiryPeers is not set
+	defaultMaxExpiryPeers = 1024
+)
+
+// ExpiryStatistics counts messages dropped on receive because their TTL
+// had passed
+type ExpiryStatistics struct {
+	Total  int64                 `json:"total"`
+	ByType map[MessageType]int64 `json:"by_type,omitempty"`
+
+	// ByPeer holds at most MaxExpiryPeers peers; drops from further peers
+	// are counted under "_other"
+	ByPeer map[NodeID]int64 `json:"by_peer,omitempty"`
+}
+
+// expiryCounter counts expired messages per type and per peer. Message
+// types are a small fixed set, while peers are bounded so a churning
+// cluster can't grow the map without limit.
+type expiryCounter struct {
+	mu       sync.Mutex
+	total    int64 // atomic
+	byType   map[MessageType]int64
+	byPeer   map[NodeID]int64
+	maxPeers int
+}
+
+func newExpiryCounter(maxPeers int) *expiryCounter {
+	if maxPeers <= 0 {
+		maxPeers = defaultMaxExpiryPeers
+	}
+	return &expiryCounter{
+		byType:   make(map[MessageType]int64),
+		byPeer:   make(map[NodeID]int64),
+		maxPeers: maxPeers,
+	}
+}
+
+// record counts an expired message of msgType from peer
+func (ec *expiryCounter) record(peer NodeID, msgType MessageType) {
+	atomic.AddInt64(&ec.total, 1)
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ec.byType[msgType]++
+	if _, ok := ec.byPeer[peer]; !ok && len(ec.byPeer) >= ec.maxPeers {
+		peer = otherPeers
+	}
+	ec.byPeer[peer]++
+}
+
+// snapshot returns a copy of the counters
+func (ec *expiryCounter) snapshot() ExpiryStatistics {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	stats := ExpiryStatistics{
+		Total:  atomic.LoadInt64(&ec.total),
+		ByType: make(map[MessageType]int64, len(ec.byType)),
+		ByPeer: make(map[NodeID]int64, len(ec.byPeer)),
+	}
+	for msgType, count := range ec.byType {
+		stats.ByType[msgType] = count
+	}
+	for peer, count := range ec.byPeer {
+		stats.ByPeer[peer] = count
+	}
+	return stats
+}
+
+// estimateSkew returns how far the clock of a peer is ahead of ours, from
+// the timestamp of its handshake message. The estimate includes the
+// one-way latency of the handshake, which only makes expiry more lenient.
+func estimateSkew(remote time.Time, now time.Time) time.Duration {
+	if remote.IsZero() {
+		return 0
+	}
+	return remote.Sub(now)
+}
+
+// isExpired returns true if message outlived its TTL. The timestamp is
+// moved onto the local clock by the peer skew, and tolerance absorbs the
+// error of that estimate. Messages without a TTL or timestamp never
+// expire.
+func isExpired(message *ClusterMessage, skew, tolerance time.Duration, now time.Time) bool {
+	if message.TTL <= 0 || message.Timestamp.IsZero() {
+		return false
+	}
+	sent := message.Timestamp.Add(-skew)
+	return now.After(sent.Add(message.TTL + tolerance))
+}
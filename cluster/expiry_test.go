@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestIsExpiredAdjustsForSkew tests TTL enforcement across skewed clocks
+func TestIsExpiredAdjustsForSkew(t *testing.T) {
+	now := time.Now()
+	message := &ClusterMessage{Timestamp: now.Add(-3 * time.Second), TTL: 2 * time.Second}
+
+	if !isExpired(message, 0, 0, now) {
+		t.Error("Expected message older than its TTL to expire")
+	}
+	if isExpired(message, 0, 2*time.Second, now) {
+		t.Error("Expected tolerance to keep the message")
+	}
+
+	// A peer 5s behind stamps messages 5s early; they are still fresh
+	if isExpired(message, -5*time.Second, 0, now) {
+		t.Error("Expected skew adjustment to keep a fresh message from a slow clock")
+	}
+
+	if isExpired(&ClusterMessage{Timestamp: now.Add(-time.Hour)}, 0, 0, now) {
+		t.Error("Expected messages without a TTL never to expire")
+	}
+	if isExpired(&ClusterMessage{TTL: time.Second}, 0, 0, now) {
+		t.Error("Expected messages without a timestamp never to expire")
+	}
+}
+
+// TestExpiryCounterBoundsPeers tests that per-peer counts stay bounded
+func TestExpiryCounterBoundsPeers(t *testing.T) {
+	counter := newExpiryCounter(2)
+	counter.record("a", MessageTypeActorCall)
+	counter.record("b", MessageTypeActorCall)
+	counter.record("c", MessageTypeHeartbeat)
+	counter.record("d", MessageTypeHeartbeat)
+	counter.record("a", MessageTypeActorCall)
+
+	stats := counter.snapshot()
+	if stats.Total != 5 {
+		t.Errorf("Expected 5 expired messages, got %d", stats.Total)
+	}
+	if stats.ByType[MessageTypeActorCall] != 3 || stats.ByType[MessageTypeHeartbeat] != 2 {
+		t.Errorf("Unexpected per-type counts: %v", stats.ByType)
+	}
+	if len(stats.ByPeer) != 3 || stats.ByPeer["a"] != 2 || stats.ByPeer[otherPeers] != 2 {
+		t.Errorf("Expected two tracked peers plus overflow, got %v", stats.ByPeer)
+	}
+}
+
+// TestTransportDropsExpiredMessages tests that stale messages are counted
+// and never reach the handler
+func TestTransportDropsExpiredMessages(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "seed"
+	config.ClockSkewTolerance = 0
+
+	mt := NewMessageTransport(config).(*messageTransport)
+	mt.ctx, mt.cancel = context.WithCancel(context.Background())
+	defer mt.cancel()
+
+	handled := make(chan *ClusterMessage, 2)
+	mt.SetMessageHandler(&funcHandler{onMessage: func(msg *ClusterMessage) { handled <- msg }})
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go mt.handleIncomingConnection(server)
+
+	client.SetDeadline(time.Now().Add(time.Second))
+	encoder := json.NewEncoder(client)
+	decoder := json.NewDecoder(client)
+
+	if err := encoder.Encode(&ClusterMessage{Type: MessageTypeJoin, From: "worker", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Failed to send handshake: %v", err)
+	}
+	var response ClusterMessage
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+
+	stale := &ClusterMessage{ID: "stale", Type: MessageTypeActorCall, From: "worker", Timestamp: time.Now().Add(-time.Minute), TTL: time.Second}
+	fresh := &ClusterMessage{ID: "fresh", Type: MessageTypeActorCall, From: "worker", Timestamp: time.Now(), TTL: time.Minute}
+	for _, msg := range []*ClusterMessage{stale, fresh} {
+		if err := encoder.Encode(msg); err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		}
+	}
+
+	select {
+	case msg := <-handled:
+		if msg.ID != "fresh" {
+			t.Errorf("Expected only the fresh message to be handled, got %s", msg.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the fresh message to be handled")
+	}
+
+	stats := mt.GetStatistics()
+	if stats.MessagesExpired != 1 || stats.Expired.ByType[MessageTypeActorCall] != 1 || stats.Expired.ByPeer["worker"] != 1 {
+		t.Errorf("Expected one expired call from worker, got %+v", stats.Expired)
+	}
+	if stats.MessagesReceived != 2 {
+		t.Errorf("Expected expired messages still counted as received, got %d", stats.MessagesReceived)
+	}
+}
+
+// funcHandler is a MessageHandler calling onMessage for each message
+type funcHandler struct {
+	onMessage func(msg *ClusterMessage)
+}
+
+func (h *funcHandler) HandleMessage(ctx context.Context, from NodeID, msg *ClusterMessage) error {
+	h.onMessage(msg)
+	return nil
+}
+
+func (h *funcHandler) HandleConnectionLost(nodeID NodeID, err error) {}
+
+func (h *funcHandler) HandleConnectionEstablished(nodeID NodeID) {}
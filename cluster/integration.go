@@ -6,13 +6,15 @@ import (
 	"time"
 
 	"github.com/najoast/sngo/bootstrap"
+	"github.com/najoast/sngo/network"
 )
 
 // ClusterService implements the bootstrap.Service interface
 type ClusterService struct {
-	manager ClusterManager
-	config  *ClusterConfig
-	started bool
+	manager   ClusterManager
+	config    *ClusterConfig
+	discovery *network.LANDiscovery
+	started   bool
 }
 
 // NewClusterService creates a new cluster service
@@ -35,20 +37,33 @@ func (cs *ClusterService) Start(ctx context.Context) error {
 		return fmt.Errorf("cluster service already started")
 	}
 
-	// Create cluster manager
-	cs.manager = NewClusterManager(cs.config)
+	// Create cluster manager; the copy of the config takes discovered seeds
+	config := *cs.config
+	cs.manager = NewClusterManager(&config)
+
+	// Without seed nodes, use the peers announcing on the LAN as seeds
+	if config.LANDiscovery.Enabled {
+		discovery, seeds, err := startLANDiscovery(ctx, &config, cs.manager.LocalNode())
+		if err != nil {
+			return fmt.Errorf("failed to start LAN discovery: %w", err)
+		}
+		cs.discovery = discovery
+		config.SeedNodes = seeds
+	}
 
 	// Start cluster manager
 	if err := cs.manager.Start(ctx); err != nil {
+		cs.stopDiscovery()
 		return fmt.Errorf("failed to start cluster manager: %w", err)
 	}
 
 	// Join cluster if seed nodes are provided
-	if len(cs.config.SeedNodes) > 0 {
-		joinCtx, cancel := context.WithTimeout(ctx, cs.config.JoinTimeout)
+	if len(config.SeedNodes) > 0 {
+		joinCtx, cancel := context.WithTimeout(ctx, config.JoinTimeout)
 		defer cancel()
 
-		if err := cs.manager.Join(joinCtx, cs.config.SeedNodes); err != nil {
+		if err := cs.manager.Join(joinCtx, config.SeedNodes); err != nil {
+			cs.stopDiscovery()
 			return fmt.Errorf("failed to join cluster: %w", err)
 		}
 	}
@@ -67,10 +82,19 @@ func (cs *ClusterService) Stop(ctx context.Context) error {
 	defer cancel()
 
 	err := cs.manager.Stop(stopCtx)
+	cs.stopDiscovery()
 	cs.started = false
 	return err
 }
 
+// stopDiscovery stops announcing the node on the LAN
+func (cs *ClusterService) stopDiscovery() {
+	if cs.discovery != nil {
+		cs.discovery.Stop()
+		cs.discovery = nil
+	}
+}
+
 func (cs *ClusterService) Health(ctx context.Context) (bootstrap.HealthStatus, error) {
 	if !cs.started || cs.manager == nil {
 		return bootstrap.HealthStatus{
@@ -131,6 +155,46 @@ func (cs *ClusterService) GetServiceRegistry() ServiceRegistry {
 	return nil
 }
 
+// RunWhileLeader implements bootstrap.Leadership, so leader-only services
+// follow the leadership of this cluster service; they must depend on it
+func (cs *ClusterService) RunWhileLeader(name string, lead func(ctx context.Context) error, stopTimeout time.Duration) error {
+	if cs.manager == nil {
+		return fmt.Errorf("cluster service not running")
+	}
+	return NewLeadership(cs.manager).RunWhileLeader(name, lead, stopTimeout)
+}
+
+// StopRunningWhileLeader implements bootstrap.Leadership
+func (cs *ClusterService) StopRunningWhileLeader(name string) {
+	if cs.manager != nil {
+		cs.manager.UnregisterLeaderDuty(name)
+	}
+}
+
+// leadership runs bootstrap leader-only services as leader duties
+type leadership struct {
+	manager ClusterManager
+}
+
+// NewLeadership returns the bootstrap.Leadership of a cluster manager:
+// leader-only services run as leader duties, with the fencing token of the
+// term in their context (see FencingTokenFromContext)
+func NewLeadership(manager ClusterManager) bootstrap.Leadership {
+	return leadership{manager: manager}
+}
+
+// RunWhileLeader registers lead as a leader duty
+func (l leadership) RunWhileLeader(name string, lead func(ctx context.Context) error, stopTimeout time.Duration) error {
+	return l.manager.RegisterLeaderDuty(name, func(ctx context.Context, token FencingToken) error {
+		return lead(ctx)
+	}, LeaderDutyOptions{StopTimeout: stopTimeout})
+}
+
+// StopRunningWhileLeader unregisters the leader duty
+func (l leadership) StopRunningWhileLeader(name string) {
+	l.manager.UnregisterLeaderDuty(name)
+}
+
 // CreateClusterServiceFactory creates a factory function for the cluster service
 func CreateClusterServiceFactory(cfg *ClusterConfig) bootstrap.ServiceFactory {
 	return func(container bootstrap.Container) (interface{}, error) {
@@ -62,6 +62,13 @@ type NodeInfo struct {
 	// Health info
 	Load    float64 `json:"load"`
 	Version string  `json:"version"`
+
+	// BootEpoch is the node process start time in Unix nanoseconds; it
+	// tells a restarted node apart from a second node reusing the same ID
+	BootEpoch int64 `json:"boot_epoch,omitempty"`
+
+	// Role is NodeRoleFull or NodeRoleWitness
+	Role NodeRole `json:"role,omitempty"`
 }
 
 // Node represents a cluster node
@@ -93,10 +100,19 @@ type Node interface {
 
 // ClusterEvent represents an event in the cluster
 type ClusterEvent struct {
-	Type      ClusterEventType       `json:"type"`
-	NodeID    NodeID                 `json:"node_id"`
-	Timestamp time.Time              `json:"timestamp"`
-	Data      map[string]interface{} `json:"data,omitempty"`
+	Type      ClusterEventType `json:"type"`
+	NodeID    NodeID           `json:"node_id"`
+	Timestamp time.Time        `json:"timestamp"`
+
+	// Namespace is the namespace label of the node, see
+	// core.TelemetryConfig; set when the event is published
+	Namespace string `json:"namespace,omitempty"`
+
+	// Payload holds the typed details of the event, e.g. NodeStateChanged
+	Payload EventPayload `json:"payload,omitempty"`
+
+	// Deprecated: Data holds the same details as untyped values; use Payload
+	Data map[string]interface{} `json:"data,omitempty"`
 }
 
 // ClusterEventType represents the type of cluster event
@@ -110,6 +126,10 @@ const (
 	EventLeaderElected ClusterEventType = "leader_elected"
 	EventPartition     ClusterEventType = "partition_detected"
 	EventMerge         ClusterEventType = "partition_healed"
+	EventDuplicateNode ClusterEventType = "duplicate_node_id"
+
+	// EventNodeQuarantined reports a rejoin refused during quarantine
+	EventNodeQuarantined ClusterEventType = "node_quarantined"
 )
 
 // ClusterManager manages the cluster membership and state
@@ -144,17 +164,102 @@ type ClusterManager interface {
 	// GetLeader returns the current cluster leader
 	GetLeader() (Node, bool)
 
-	// Events returns a channel for cluster events
+	// LeaderCandidates returns the active nodes eligible for leadership;
+	// witness nodes are never candidates
+	LeaderCandidates() []Node
+
+	// RegisterLeaderDuty registers a job run only while this node is
+	// leader; it is cancelled when leadership is lost
+	RegisterLeaderDuty(name string, duty LeaderDuty, opts LeaderDutyOptions) error
+
+	// UnregisterLeaderDuty stops and removes a leader duty
+	UnregisterLeaderDuty(name string)
+
+	// LeaderDuties returns the status of the registered leader duties
+	LeaderDuties() []LeaderDutyStatus
+
+	// FencingToken returns the token of the current term while leader
+	FencingToken() (FencingToken, bool)
+
+	// CheckFencingToken refuses tokens other than the current leader's,
+	// on any node of the cluster
+	CheckFencingToken(token FencingToken) error
+
+	// SetTermStore sets where the highest seen leadership term persists
+	SetTermStore(store TermStore)
+
+	// StepDown gives up leadership, stopping the leader duties
+	StepDown()
+
+	// Events returns a channel for cluster events, shared by all readers.
+	//
+	// Deprecated: use Subscribe, which filters and buffers per subscriber
 	Events() <-chan ClusterEvent
 
+	// Subscribe delivers the events selected by filter through a buffer of
+	// its own until ctx is done or the subscription is closed
+	Subscribe(ctx context.Context, filter EventFilter, buffer int) *EventSubscription
+
 	// AddEventListener adds an event listener
 	AddEventListener(listener func(ClusterEvent))
 
+	// AddEventListenerContext adds an event listener that is dropped once
+	// ctx is done
+	AddEventListenerContext(ctx context.Context, listener func(ClusterEvent))
+
 	// GetClusterSize returns the number of active nodes
 	GetClusterSize() int
 
 	// GetClusterHealth returns overall cluster health
 	GetClusterHealth() ClusterHealth
+
+	// SetReadOnly toggles cluster-wide read-only mode; by identifies the operator
+	SetReadOnly(ctx context.Context, enabled bool, by, reason string) error
+
+	// ReadOnly returns the current read-only state
+	ReadOnly() ReadOnlyState
+
+	// ReadOnlyAudit returns the history of read-only changes seen by this node
+	ReadOnlyAudit() []ReadOnlyState
+
+	// SetMetricsCollector sets the collector filling in the local metrics digest
+	SetMetricsCollector(collector MetricsCollector)
+
+	// LocalMetricsDigest returns the metrics digest of the local node
+	LocalMetricsDigest() MetricsDigest
+
+	// MetricsRollup returns the cluster-wide metrics; complete on the aggregator only
+	MetricsRollup() ClusterRollup
+
+	// RegisterControlApplier registers the applier of a control command kind
+	RegisterControlApplier(kind string, applier ControlApplier) error
+
+	// ProposeControl appends a command to the control log; leader only
+	ProposeControl(ctx context.Context, kind string, payload interface{}, by string) (ControlCommand, error)
+
+	// ControlLog returns the progress of the control log
+	ControlLog() ControlLogStatus
+
+	// ControlCommands returns the control commands applied after an index
+	ControlCommands(after uint64) []ControlCommand
+
+	// RegisterMigration registers a migration the leader can run
+	RegisterMigration(m Migration) error
+
+	// RunMigration runs a migration node by node; leader only
+	RunMigration(ctx context.Context, name, by string) (MigrationStatus, error)
+
+	// MigrationStatus returns the per-node progress of a migration
+	MigrationStatus(name string) (MigrationStatus, bool)
+
+	// Migrations returns the progress of every migration started
+	Migrations() []MigrationStatus
+
+	// Compact runs a compaction pass now and returns the updated stats
+	Compact() CompactionStats
+
+	// CompactionStats returns the bookkeeping map sizes and expiry counters
+	CompactionStats() CompactionStats
 }
 
 // ClusterHealth represents the health status of the cluster
@@ -168,6 +273,16 @@ type ClusterHealth struct {
 	PartitionCount int       `json:"partition_count"`
 	LastUpdate     time.Time `json:"last_update"`
 	IsHealthy      bool      `json:"is_healthy"`
+
+	// DuplicateJoins counts join attempts rejected for reusing a NodeID
+	DuplicateJoins int64 `json:"duplicate_joins"`
+
+	// QuarantinedJoins counts rejoins refused while a failed node was quarantined
+	QuarantinedJoins int64 `json:"quarantined_joins"`
+
+	// Witnesses counts the witness nodes; they are included in TotalNodes
+	// and ActiveNodes
+	Witnesses int `json:"witnesses"`
 }
 
 // MessageType represents the type of cluster message
@@ -245,6 +360,14 @@ type TransportStatistics struct {
 	ConnectionsOpen  int           `json:"connections_open"`
 	ErrorCount       int64         `json:"error_count"`
 	AverageLatency   time.Duration `json:"average_latency"`
+
+	// MessagesExpired counts messages dropped because their TTL passed
+	// before they arrived; Expired breaks them down by type and peer
+	MessagesExpired int64            `json:"messages_expired"`
+	Expired         ExpiryStatistics `json:"expired"`
+
+	// Reconnects reports the peers waiting to be redialed
+	Reconnects ReconnectStatistics `json:"reconnects"`
 }
 
 // RemoteActorRef represents a reference to an actor on another node
@@ -252,6 +375,16 @@ type RemoteActorRef struct {
 	NodeID  NodeID `json:"node_id"`
 	ActorID string `json:"actor_id"`
 	Address string `json:"address"`
+
+	// Service is the service the reference was resolved from; calls
+	// through it are accounted to that service. Empty for references
+	// built by hand, which are accounted as plain actor calls.
+	Service string `json:"service,omitempty"`
+
+	// Epoch is the boot epoch of the node process the reference was
+	// resolved against. References to a process that was lost since are
+	// stale; 0 means unknown and is not checked.
+	Epoch uint64 `json:"epoch,omitempty"`
 }
 
 // RemoteService provides remote service call capabilities
@@ -268,11 +401,48 @@ type RemoteService interface {
 	// Unregister unregisters a local service
 	Unregister(serviceID string) error
 
+	// CallSticky calls the instance of a service a stickiness key, such as
+	// a player ID, is pinned to, re-pinning the key if it is unreachable
+	CallSticky(ctx context.Context, serviceID, key string, message interface{}) (interface{}, error)
+
+	// StickySessions returns the pins used by CallSticky
+	StickySessions() *StickySessions
+
 	// Resolve resolves a service ID to actor references across the cluster
 	Resolve(ctx context.Context, serviceID string) ([]RemoteActorRef, error)
 
+	// ResolveSelector resolves the instances of a service whose labels
+	// match a selector such as "env=prod, shard=3, version>=2"
+	ResolveSelector(ctx context.Context, serviceID string, selector string) ([]RemoteActorRef, error)
+
 	// GetServiceRegistry returns the service registry
 	GetServiceRegistry() ServiceRegistry
+
+	// WatchActor returns a channel receiving Terminated once the node of
+	// ref is lost, and a function cancelling the watch
+	WatchActor(ref RemoteActorRef) (<-chan Terminated, func())
+
+	// SweepNode fails pending calls, fires watches and invalidates
+	// references for a lost node; it runs when a node fails or leaves
+	SweepNode(nodeID NodeID) NodeSweep
+
+	// SweepStats returns the node-death sweep counters
+	SweepStats() SweepStats
+
+	// SetAuthenticator sets how remote calls are signed and authenticated
+	SetAuthenticator(auth Authenticator)
+
+	// SetAccessControl sets the per-service ACLs enforced on inbound calls
+	SetAccessControl(acl *AccessControl)
+
+	// SetAuditHandler sets the handler that receives denied-call audit events
+	SetAuditHandler(handler func(AuditEvent))
+
+	// SetRateLimiter sets the per-caller, per-service limits on inbound calls
+	SetRateLimiter(limiter *RateLimiter)
+
+	// SetResolveCache sets the cache of service lookups used by Resolve
+	SetResolveCache(cache *ResolveCache)
 }
 
 // RemoteCallHandler handles remote service calls
@@ -286,12 +456,20 @@ type ServiceRegistry interface {
 	// RegisterService registers a service on this node
 	RegisterService(ctx context.Context, serviceID string, metadata map[string]string) error
 
+	// RegisterServiceWithLabels registers a service on this node with
+	// deployment labels that selector queries match against
+	RegisterServiceWithLabels(ctx context.Context, serviceID string, metadata, labels map[string]string) error
+
 	// UnregisterService unregisters a service from this node
 	UnregisterService(ctx context.Context, serviceID string) error
 
 	// DiscoverService discovers all instances of a service across the cluster
 	DiscoverService(ctx context.Context, serviceID string) ([]ServiceInstance, error)
 
+	// DiscoverServiceSelector discovers the instances of a service whose
+	// labels match selector
+	DiscoverServiceSelector(ctx context.Context, serviceID string, selector Selector) ([]ServiceInstance, error)
+
 	// Watch watches for changes to a service
 	Watch(ctx context.Context, serviceID string) (<-chan ServiceEvent, error)
 
@@ -305,6 +483,7 @@ type ServiceInstance struct {
 	NodeID    NodeID            `json:"node_id"`
 	Address   string            `json:"address"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
 	Health    ServiceHealth     `json:"health"`
 
 	RegisteredAt time.Time `json:"registered_at"`
@@ -345,6 +524,10 @@ type ClusterConfig struct {
 	BindAddr string `yaml:"bind_addr" json:"bind_addr"`
 	BindPort int    `yaml:"bind_port" json:"bind_port"`
 
+	// Role makes the node a witness, a member that never leads nor runs
+	// services; empty means a full node
+	Role NodeRole `yaml:"role,omitempty" json:"role,omitempty"`
+
 	// Cluster settings
 	ClusterName string   `yaml:"cluster_name" json:"cluster_name"`
 	SeedNodes   []string `yaml:"seed_nodes" json:"seed_nodes"`
@@ -359,17 +542,51 @@ type ClusterConfig struct {
 	SuspicionTimeout    time.Duration `yaml:"suspicion_timeout" json:"suspicion_timeout"`
 	SuspicionMultiplier int           `yaml:"suspicion_multiplier" json:"suspicion_multiplier"`
 
+	// TermFile persists the highest leadership term seen, so terms keep
+	// growing across restarts; empty keeps it in memory only
+	TermFile string `yaml:"term_file,omitempty" json:"term_file,omitempty"`
+
+	// QuarantinePeriod is how long a failed node may not rejoin with the
+	// same incarnation (boot epoch); 0 allows immediate rejoins
+	QuarantinePeriod time.Duration `yaml:"quarantine_period" json:"quarantine_period"`
+
+	// Compaction expires bookkeeping that outlived its use
+	Compaction CompactionConfig `yaml:"compaction" json:"compaction"`
+
+	// Reconnect bounds how dropped peer connections are redialed
+	Reconnect ReconnectConfig `yaml:"reconnect" json:"reconnect"`
+
+	// Chaos guards the fault injection commands used for game days
+	Chaos ChaosConfig `yaml:"chaos" json:"chaos"`
+
+	// Rollup ships metrics digests to the aggregator for cluster rollups
+	Rollup RollupConfig `yaml:"rollup" json:"rollup"`
+
+	// LANDiscovery finds seed nodes on the LAN; development clusters only
+	LANDiscovery LANDiscoveryConfig `yaml:"lan_discovery" json:"lan_discovery"`
+
 	// Transport settings
 	MessageTimeout     time.Duration `yaml:"message_timeout" json:"message_timeout"`
 	MaxMessageSize     int           `yaml:"max_message_size" json:"max_message_size"`
 	CompressionEnabled bool          `yaml:"compression_enabled" json:"compression_enabled"`
 	EncryptionEnabled  bool          `yaml:"encryption_enabled" json:"encryption_enabled"`
 
+	// ClockSkewTolerance is added to message TTLs on receive to absorb
+	// the error of the per-peer clock skew estimate
+	ClockSkewTolerance time.Duration `yaml:"clock_skew_tolerance" json:"clock_skew_tolerance"`
+
+	// MaxExpiryPeers bounds the peers tracked in expired-message counts
+	MaxExpiryPeers int `yaml:"max_expiry_peers" json:"max_expiry_peers"`
+
 	// Advanced settings
 	GossipFanout     int           `yaml:"gossip_fanout" json:"gossip_fanout"`
 	GossipInterval   time.Duration `yaml:"gossip_interval" json:"gossip_interval"`
 	PushPullInterval time.Duration `yaml:"push_pull_interval" json:"push_pull_interval"`
 
+	// CrossZoneCostPerGB prices cross-zone traffic in traffic reports; the
+	// local zone is read from Metadata["zone"]
+	CrossZoneCostPerGB float64 `yaml:"cross_zone_cost_per_gb" json:"cross_zone_cost_per_gb"`
+
 	// Metadata
 	Metadata map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
 }
@@ -391,11 +608,21 @@ func DefaultClusterConfig() *ClusterConfig {
 
 		SuspicionTimeout:    5 * time.Second,
 		SuspicionMultiplier: 3,
+		QuarantinePeriod:    30 * time.Second,
+
+		Compaction: DefaultCompactionConfig(),
+		Reconnect:  DefaultReconnectConfig(),
+		Chaos:      DefaultChaosConfig(),
+		Rollup:     DefaultRollupConfig(),
+
+		LANDiscovery: DefaultLANDiscoveryConfig(),
 
 		MessageTimeout:     10 * time.Second,
 		MaxMessageSize:     1024 * 1024, // 1MB
 		CompressionEnabled: true,
 		EncryptionEnabled:  false,
+		ClockSkewTolerance: time.Second,
+		MaxExpiryPeers:     1024,
 
 		GossipFanout:     3,
 		GossipInterval:   200 * time.Millisecond,
@@ -0,0 +1,207 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Header keys carried by the join handshake
+const (
+	HeaderBootEpoch = "boot_epoch"
+	HeaderAddress   = "address"
+	HeaderJoinError = "join_error"
+)
+
+// ErrDuplicateNodeID is returned when a node joins with an ID already in use
+var ErrDuplicateNodeID = errors.New("duplicate node ID")
+
+// ErrNodeQuarantined is returned when a failed node tries to rejoin with
+// its old incarnation before its quarantine period is over
+var ErrNodeQuarantined = errors.New("node is quarantined")
+
+// JoinHandshaker is implemented by message handlers that take part in the
+// join handshake performed by the transport
+type JoinHandshaker interface {
+	// JoinHeaders returns the headers identifying the local node
+	JoinHeaders() map[string]string
+
+	// ValidateJoin accepts or rejects an incoming join handshake
+	ValidateJoin(handshake *ClusterMessage) error
+}
+
+// JoinHeaders identifies the local node by boot epoch and address
+func (cm *clusterManager) JoinHeaders() map[string]string {
+	info := cm.localNode.Info()
+	return map[string]string{
+		HeaderBootEpoch: strconv.FormatInt(info.BootEpoch, 10),
+		HeaderAddress:   cm.localNode.Address().String(),
+		HeaderRole:      string(info.Role),
+	}
+}
+
+// ValidateJoin rejects a joiner reusing the ID of the local node or of a
+// known member running on a different address or boot epoch. The existing
+// member always wins; the newer joiner is turned away.
+func (cm *clusterManager) ValidateJoin(handshake *ClusterMessage) error {
+	nodeID := handshake.From
+	epoch, _ := strconv.ParseInt(handshake.Headers[HeaderBootEpoch], 10, 64)
+	address := handshake.Headers[HeaderAddress]
+
+	if nodeID == cm.localNode.ID() {
+		return cm.rejectDuplicate(nodeID, address, epoch, cm.localNode.Info())
+	}
+
+	if existing, exists := cm.GetNode(nodeID); exists {
+		info := existing.Info()
+		sameAddress := address == "" || address == nodeAddress(info)
+
+		switch {
+		case !sameAddress && existing.IsActive():
+			return cm.rejectDuplicate(nodeID, address, epoch, info)
+		case sameAddress && epoch != 0 && info.BootEpoch != 0 && epoch < info.BootEpoch:
+			// An older process cannot come back once its successor joined
+			return cm.rejectDuplicate(nodeID, address, epoch, info)
+		case info.State == NodeStateFailed && epoch <= info.BootEpoch:
+			if remaining := cm.quarantineRemaining(info); remaining > 0 {
+				return cm.rejectQuarantined(nodeID, address, epoch, info, remaining)
+			}
+		}
+	}
+
+	cm.recordJoiner(nodeID, address, epoch, parseNodeRole(handshake.Headers[HeaderRole]))
+	return nil
+}
+
+// rejectDuplicate counts and announces a duplicate join attempt
+func (cm *clusterManager) rejectDuplicate(nodeID NodeID, address string, epoch int64, existing *NodeInfo) error {
+	atomic.AddInt64(&cm.duplicateJoins, 1)
+
+	cm.publishEvent(ClusterEvent{
+		Type:      EventDuplicateNode,
+		NodeID:    nodeID,
+		Timestamp: time.Now(),
+		Payload: DuplicateNodeRejected{
+			JoinerAddress:     address,
+			JoinerBootEpoch:   epoch,
+			ExistingAddress:   nodeAddress(existing),
+			ExistingBootEpoch: existing.BootEpoch,
+		},
+		Data: map[string]interface{}{
+			"joiner_address":    address,
+			"joiner_boot_epoch": epoch,
+			"existing_address":  nodeAddress(existing),
+			"existing_epoch":    existing.BootEpoch,
+		},
+	})
+
+	return fmt.Errorf("%w: %s already in use by %s (boot epoch %d)",
+		ErrDuplicateNodeID, nodeID, nodeAddress(existing), existing.BootEpoch)
+}
+
+// quarantineRemaining returns how long a failed node stays quarantined
+func (cm *clusterManager) quarantineRemaining(info *NodeInfo) time.Duration {
+	if cm.config.QuarantinePeriod <= 0 {
+		return 0
+	}
+	return cm.config.QuarantinePeriod - time.Since(info.StateChange)
+}
+
+// rejectQuarantined counts and announces a rejoin refused by the
+// quarantine of a failed node
+func (cm *clusterManager) rejectQuarantined(nodeID NodeID, address string, epoch int64, existing *NodeInfo, remaining time.Duration) error {
+	atomic.AddInt64(&cm.quarantinedJoins, 1)
+
+	cm.publishEvent(ClusterEvent{
+		Type:      EventNodeQuarantined,
+		NodeID:    nodeID,
+		Timestamp: time.Now(),
+		Payload: NodeQuarantined{
+			JoinerAddress:   address,
+			JoinerBootEpoch: epoch,
+			FailedAt:        existing.StateChange,
+			Remaining:       remaining,
+		},
+		Data: map[string]interface{}{
+			"joiner_address":    address,
+			"joiner_boot_epoch": epoch,
+			"failed_at":         existing.StateChange,
+			"remaining":         remaining.String(),
+		},
+	})
+
+	return fmt.Errorf("%w: %s failed at %s, rejoin with a new incarnation or retry in %s",
+		ErrNodeQuarantined, nodeID, existing.StateChange.Format(time.RFC3339), remaining.Round(time.Second))
+}
+
+// recordJoiner adds or refreshes the membership entry of an accepted joiner
+func (cm *clusterManager) recordJoiner(nodeID NodeID, address string, epoch int64, role NodeRole) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	now := time.Now()
+	cm.addNode(NewRemoteNode(&NodeInfo{
+		ID:          nodeID,
+		Address:     host,
+		Port:        port,
+		State:       NodeStateActive,
+		Metadata:    make(map[string]string),
+		JoinedAt:    now,
+		LastSeen:    now,
+		StateChange: now,
+		BootEpoch:   epoch,
+		Role:        role,
+	}))
+}
+
+// nodeAddress returns the host:port a node is reachable on
+func nodeAddress(info *NodeInfo) string {
+	if info.Port == 0 {
+		return info.Address
+	}
+	if _, _, err := net.SplitHostPort(info.Address); err == nil {
+		return info.Address
+	}
+	return net.JoinHostPort(info.Address, strconv.Itoa(info.Port))
+}
+
+// joinHandshake performs the joiner side of the handshake on a new link
+// and returns the peer's response
+func (mt *messageTransport) joinHandshake(encoder *json.Encoder, decoder *json.Decoder, to NodeID) (*ClusterMessage, error) {
+	handshake := &ClusterMessage{
+		ID:        generateMessageID(),
+		Type:      MessageTypeJoin,
+		From:      mt.config.NodeID,
+		To:        to,
+		Timestamp: time.Now(),
+	}
+	if hs, ok := mt.handler.(JoinHandshaker); ok {
+		handshake.Headers = hs.JoinHeaders()
+	}
+	mt.addZoneHeader(handshake)
+
+	if err := encoder.Encode(handshake); err != nil {
+		return nil, fmt.Errorf("failed to send join handshake: %w", err)
+	}
+
+	var response ClusterMessage
+	if err := decoder.Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to read join response: %w", err)
+	}
+
+	if reason := response.Headers[HeaderJoinError]; reason != "" {
+		if strings.HasPrefix(reason, ErrNodeQuarantined.Error()) {
+			return nil, fmt.Errorf("%w: %s", ErrNodeQuarantined, reason)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateNodeID, reason)
+	}
+	return &response, nil
+}
@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func joinMessage(from NodeID, address string, epoch int64) *ClusterMessage {
+	return &ClusterMessage{
+		Type: MessageTypeJoin,
+		From: from,
+		Headers: map[string]string{
+			HeaderAddress:   address,
+			HeaderBootEpoch: strconv.FormatInt(epoch, 10),
+		},
+	}
+}
+
+// TestValidateJoinDuplicate tests duplicate NodeID detection during join
+func TestValidateJoinDuplicate(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "seed"
+	manager := NewClusterManager(config).(*clusterManager)
+
+	events := make(chan ClusterEvent, 4)
+	manager.AddEventListener(func(event ClusterEvent) {
+		if event.Type == EventDuplicateNode {
+			events <- event
+		}
+	})
+
+	// A joiner claiming the seed's own ID is rejected
+	err := manager.ValidateJoin(joinMessage("seed", "10.0.0.9:7946", time.Now().UnixNano()))
+	if !errors.Is(err, ErrDuplicateNodeID) {
+		t.Errorf("Expected ErrDuplicateNodeID for local ID, got %v", err)
+	}
+
+	// The first worker-1 is accepted and recorded
+	if err := manager.ValidateJoin(joinMessage("worker-1", "10.0.0.1:7946", 100)); err != nil {
+		t.Fatalf("Expected first join to succeed, got %v", err)
+	}
+
+	// Reconnecting or restarting on the same address is fine
+	if err := manager.ValidateJoin(joinMessage("worker-1", "10.0.0.1:7946", 200)); err != nil {
+		t.Errorf("Expected restart on same address to succeed, got %v", err)
+	}
+
+	// A second process on another address reusing the ID is the newer joiner
+	err = manager.ValidateJoin(joinMessage("worker-1", "10.0.0.2:7946", 300))
+	if !errors.Is(err, ErrDuplicateNodeID) {
+		t.Errorf("Expected ErrDuplicateNodeID for second address, got %v", err)
+	}
+
+	if got := manager.GetClusterHealth().DuplicateJoins; got != 2 {
+		t.Errorf("Expected 2 duplicate joins, got %d", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-events:
+			if event.Data["joiner_address"] == "" {
+				t.Errorf("Event missing joiner address: %+v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected duplicate node event")
+		}
+	}
+}
+
+// TestTransportRejectsDuplicateJoin tests the handshake rejection on the wire
+func TestTransportRejectsDuplicateJoin(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "seed"
+	manager := NewClusterManager(config).(*clusterManager)
+
+	mt := NewMessageTransport(config).(*messageTransport)
+	mt.ctx, mt.cancel = context.WithCancel(context.Background())
+	defer mt.cancel()
+	mt.SetMessageHandler(manager)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go mt.handleIncomingConnection(server)
+
+	client.SetDeadline(time.Now().Add(time.Second))
+	if err := json.NewEncoder(client).Encode(joinMessage("seed", "10.0.0.9:7946", 1)); err != nil {
+		t.Fatalf("Failed to send handshake: %v", err)
+	}
+
+	var response ClusterMessage
+	if err := json.NewDecoder(client).Decode(&response); err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+	if response.Headers[HeaderJoinError] == "" {
+		t.Errorf("Expected join error in response, got %+v", response.Headers)
+	}
+}
+
+// TestValidateJoinQuarantine tests the rejoin quarantine of failed nodes
+func TestValidateJoinQuarantine(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "seed"
+	config.QuarantinePeriod = time.Minute
+	manager := NewClusterManager(config).(*clusterManager)
+
+	events := make(chan ClusterEvent, 4)
+	manager.AddEventListener(func(event ClusterEvent) {
+		if event.Type == EventNodeQuarantined {
+			events <- event
+		}
+	})
+
+	if err := manager.ValidateJoin(joinMessage("worker-1", "10.0.0.1:7946", 100)); err != nil {
+		t.Fatalf("Expected first join to succeed, got %v", err)
+	}
+	node, _ := manager.GetNode("worker-1")
+	node.UpdateState(NodeStateFailed)
+
+	// The old incarnation is turned away while quarantined
+	err := manager.ValidateJoin(joinMessage("worker-1", "10.0.0.1:7946", 100))
+	if !errors.Is(err, ErrNodeQuarantined) {
+		t.Errorf("Expected ErrNodeQuarantined, got %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.NodeID != "worker-1" || event.Data["remaining"] == "" {
+			t.Errorf("Unexpected quarantine event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected quarantine event")
+	}
+	if got := manager.GetClusterHealth().QuarantinedJoins; got != 1 {
+		t.Errorf("Expected 1 quarantined join, got %d", got)
+	}
+
+	// A new incarnation may rejoin at once
+	if err := manager.ValidateJoin(joinMessage("worker-1", "10.0.0.1:7946", 200)); err != nil {
+		t.Errorf("Expected new incarnation to rejoin, got %v", err)
+	}
+
+	// Once the period is over the old incarnation is accepted again
+	node, _ = manager.GetNode("worker-1")
+	node.UpdateState(NodeStateFailed)
+	manager.config.QuarantinePeriod = 0
+	if err := manager.ValidateJoin(joinMessage("worker-1", "10.0.0.1:7946", 200)); err != nil {
+		t.Errorf("Expected rejoin after quarantine, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/najoast/sngo/network"
+)
+
+// LANDiscoveryConfig feeds the seed list of a development cluster from
+// the nodes announcing on the LAN. Announcements are neither authenticated
+// nor encrypted, so it must stay disabled in production.
+type LANDiscoveryConfig struct {
+	// Enabled announces the node and, when no seed nodes are configured,
+	// uses the peers discovered at startup as seeds
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Group is the multicast group to announce on; empty uses
+	// network.DefaultLANDiscoveryGroup
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+
+	// Address is the cluster address announced to peers; empty announces
+	// the bind address
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+
+	// Interval is the time between announcements
+	Interval time.Duration `yaml:"interval" json:"interval"`
+
+	// Wait is how long startup waits for a first peer before the node
+	// starts a cluster of its own
+	Wait time.Duration `yaml:"wait" json:"wait"`
+}
+
+// DefaultLANDiscoveryConfig returns a disabled LAN discovery configuration
+func DefaultLANDiscoveryConfig() LANDiscoveryConfig {
+	return LANDiscoveryConfig{
+		Interval: time.Second,
+		Wait:     3 * time.Second,
+	}
+}
+
+// startLANDiscovery starts announcing the local node and, unless seed
+// nodes are configured, waits up to config.Wait for peers; it returns the
+// discovery, to be stopped with the node, and the seed list to join
+func startLANDiscovery(ctx context.Context, config *ClusterConfig, local Node) (*network.LANDiscovery, []string, error) {
+	address := config.LANDiscovery.Address
+	if address == "" {
+		address = local.Address().String()
+	}
+
+	discovery, err := network.NewLANDiscovery(network.LANDiscoveryConfig{
+		DevMode:     true,
+		Group:       config.LANDiscovery.Group,
+		ClusterName: config.ClusterName,
+		NodeID:      string(local.ID()),
+		Address:     address,
+		Interval:    config.LANDiscovery.Interval,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := discovery.Start(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	if len(config.SeedNodes) > 0 {
+		return discovery, config.SeedNodes, nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, config.LANDiscovery.Wait)
+	defer cancel()
+	seeds, err := discovery.WaitForPeers(waitCtx, 1)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		discovery.Stop()
+		return nil, nil, err
+	}
+	return discovery, seeds, nil
+}
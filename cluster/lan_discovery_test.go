@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// unicastGroup reserves a loopback address to announce on, keeping the
+// tests independent of multicast routing
+func unicastGroup(t *testing.T) string {
+	probe, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	defer probe.Close()
+	return probe.LocalAddr().String()
+}
+
+func TestLANDiscoveryFeedsSeeds(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "game-a"
+	config.ClusterName = "dev"
+	config.LANDiscovery.Enabled = true
+	config.LANDiscovery.Group = unicastGroup(t)
+	config.LANDiscovery.Interval = 20 * time.Millisecond
+	config.LANDiscovery.Wait = 2 * time.Second
+	manager := NewClusterManager(config)
+
+	peer, err := net.Dial("udp4", config.LANDiscovery.Group)
+	if err != nil {
+		t.Fatalf("Failed to dial group: %v", err)
+	}
+	defer peer.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			peer.Write([]byte(`{"cluster":"dev","node_id":"game-b","address":"127.0.0.1:7947"}`))
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}()
+
+	discovery, seeds, err := startLANDiscovery(context.Background(), config, manager.LocalNode())
+	if err != nil {
+		t.Fatalf("Failed to start LAN discovery: %v", err)
+	}
+	discovery.Stop()
+	if len(seeds) != 1 || seeds[0] != "127.0.0.1:7947" {
+		t.Errorf("Expected the announced peer as seed, got %v", seeds)
+	}
+
+	// Configured seeds win over discovered ones
+	config.SeedNodes = []string{"10.0.0.1:7946"}
+	discovery, seeds, err = startLANDiscovery(context.Background(), config, manager.LocalNode())
+	if err != nil {
+		t.Fatalf("Failed to start LAN discovery: %v", err)
+	}
+	defer discovery.Stop()
+	if len(seeds) != 1 || seeds[0] != "10.0.0.1:7946" {
+		t.Errorf("Expected the configured seeds, got %v", seeds)
+	}
+}
+
+func TestClusterServiceLANDiscoveryAlone(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.BindPort = 0
+	config.LANDiscovery.Enabled = true
+	config.LANDiscovery.Group = unicastGroup(t)
+	config.LANDiscovery.Wait = 50 * time.Millisecond
+
+	service := NewClusterService(config)
+	ctx := context.Background()
+	if err := service.Start(ctx); err != nil {
+		t.Fatalf("Failed to start cluster service: %v", err)
+	}
+	defer service.Stop(ctx)
+
+	// No peer answered in time, so the node started a cluster of its own
+	if !service.GetManager().IsLeader() {
+		t.Error("Expected the lone node to lead")
+	}
+	if len(config.SeedNodes) != 0 {
+		t.Errorf("Expected the caller's config untouched, got seeds %v", config.SeedNodes)
+	}
+}
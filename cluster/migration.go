@@ -0,0 +1,541 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Migration messages: the leader asks a node to run its steps, the node
+// reports the outcome
+const (
+	MessageTypeMigrationStep     MessageType = "migration_step"
+	MessageTypeMigrationStepDone MessageType = "migration_step_done"
+)
+
+// EventMigrationProgress is published when a node starts, finishes or
+// fails a migration, and when the whole cluster finished it
+const EventMigrationProgress ClusterEventType = "migration_progress"
+
+// controlKindMigration is the control command kind recording migration
+// progress; every node applies it to its migration table
+const controlKindMigration = "migration"
+
+var (
+	// ErrUnknownMigration is returned for migrations not registered
+	ErrUnknownMigration = errors.New("unknown migration")
+
+	// ErrMigrationFailed is wrapped by the error of a migration whose step
+	// failed on a node
+	ErrMigrationFailed = errors.New("migration failed")
+
+	// ErrMixedVersion is returned for traffic between a node that finished
+	// a breaking migration and one that did not
+	ErrMixedVersion = errors.New("mixed-version traffic refused during breaking migration")
+
+	// ErrNodeDraining is returned for calls to a node draining for a
+	// migration
+	ErrNodeDraining = errors.New("node is draining for a migration")
+)
+
+// MigrationStep is one step of a migration run on a node
+type MigrationStep func(ctx context.Context) error
+
+// Migration is a schema or protocol change rolled out node by node. Every
+// node registers the same migrations; the leader runs them with
+// RunMigration.
+type Migration struct {
+	// Name identifies the migration across the cluster
+	Name string
+
+	// Breaking marks a change old and new nodes cannot talk across: calls
+	// between a migrated node and one that is not are refused until every
+	// node finished
+	Breaking bool
+
+	// Drain refuses calls to a node while its steps run
+	Drain bool
+
+	// Pre runs on a node before it switches over, e.g. to flush state in
+	// the old format; Post runs after, e.g. to load it in the new one
+	Pre  MigrationStep
+	Post MigrationStep
+
+	// StepTimeout bounds the steps of one node, 0 for no bound beyond the
+	// context of RunMigration
+	StepTimeout time.Duration
+}
+
+// MigrationState is the progress of a migration on one node
+type MigrationState string
+
+const (
+	MigrationRunning MigrationState = "running"
+	MigrationDone    MigrationState = "done"
+	MigrationFailed  MigrationState = "failed"
+)
+
+// MigrationNodeStatus is the progress of a migration on one node
+type MigrationNodeStatus struct {
+	State     MigrationState `json:"state"`
+	Error     string         `json:"error,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// MigrationStatus is the progress of a migration across the cluster, as
+// recorded in the control log
+type MigrationStatus struct {
+	Name      string                         `json:"name"`
+	Breaking  bool                           `json:"breaking"`
+	StartedBy string                         `json:"started_by"`
+	Nodes     map[NodeID]MigrationNodeStatus `json:"nodes"`
+	Completed bool                           `json:"completed"`
+}
+
+// done reports whether node finished the migration
+func (s *MigrationStatus) done(node NodeID) bool {
+	return s.Nodes[node].State == MigrationDone
+}
+
+// migrationRecord is the payload of a migration control command
+type migrationRecord struct {
+	Name      string         `json:"name"`
+	Breaking  bool           `json:"breaking"`
+	Node      NodeID         `json:"node,omitempty"`
+	State     MigrationState `json:"state,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Completed bool           `json:"completed,omitempty"`
+}
+
+// migrationStepRequest asks a node to run the steps of a migration
+type migrationStepRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// migrationStepResult reports the outcome of a migrationStepRequest
+type migrationStepResult struct {
+	ID    string `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+// pendingMigrationStep waits for the result of a step sent to node
+type pendingMigrationStep struct {
+	node   NodeID
+	result chan migrationStepResult
+}
+
+// migrations holds the registered migrations and the replicated progress
+type migrations struct {
+	mu         sync.Mutex
+	registered map[string]Migration
+	status     map[string]*MigrationStatus
+	pending    map[string]pendingMigrationStep
+	handled    map[string]bool // step request IDs already run here
+	draining   int32           // atomic
+}
+
+// RegisterMigration registers a migration on the local node. Register it
+// on every node before the leader runs it.
+func (cm *clusterManager) RegisterMigration(m Migration) error {
+	if m.Name == "" {
+		return fmt.Errorf("migration requires a name")
+	}
+
+	cm.migrations.mu.Lock()
+	defer cm.migrations.mu.Unlock()
+
+	if cm.migrations.registered == nil {
+		cm.migrations.registered = make(map[string]Migration)
+	}
+	if _, exists := cm.migrations.registered[m.Name]; exists {
+		return fmt.Errorf("migration %s already registered", m.Name)
+	}
+	cm.migrations.registered[m.Name] = m
+	return nil
+}
+
+// RunMigration runs a migration on every node, one node at a time with the
+// leader last, recording the progress of each node in the control log. It
+// stops at the first node whose steps fail; running it again resumes with
+// the nodes that did not finish. Leader only.
+func (cm *clusterManager) RunMigration(ctx context.Context, name, by string) (MigrationStatus, error) {
+	m, ok := cm.migration(name)
+	if !ok {
+		return MigrationStatus{}, fmt.Errorf("%w: %s", ErrUnknownMigration, name)
+	}
+	if !cm.IsLeader() {
+		return MigrationStatus{}, ErrNotLeader
+	}
+
+	for _, node := range cm.migrationOrder() {
+		if status, _ := cm.MigrationStatus(name); status.done(node) {
+			continue
+		}
+		if err := cm.recordMigration(ctx, by, migrationRecord{Name: name, Breaking: m.Breaking, Node: node, State: MigrationRunning}); err != nil {
+			return cm.migrationStatus(name), err
+		}
+
+		err := cm.runMigrationOn(ctx, m, node)
+		record := migrationRecord{Name: name, Breaking: m.Breaking, Node: node, State: MigrationDone}
+		if err != nil {
+			record.State, record.Error = MigrationFailed, err.Error()
+		}
+		if recordErr := cm.recordMigration(ctx, by, record); recordErr != nil {
+			return cm.migrationStatus(name), recordErr
+		}
+		if err != nil {
+			return cm.migrationStatus(name), fmt.Errorf("%w: %s on node %s: %v", ErrMigrationFailed, name, node, err)
+		}
+	}
+
+	err := cm.recordMigration(ctx, by, migrationRecord{Name: name, Breaking: m.Breaking, Completed: true})
+	return cm.migrationStatus(name), err
+}
+
+// MigrationStatus returns the progress of a migration
+func (cm *clusterManager) MigrationStatus(name string) (MigrationStatus, bool) {
+	cm.migrations.mu.Lock()
+	defer cm.migrations.mu.Unlock()
+
+	status, exists := cm.migrations.status[name]
+	if !exists {
+		return MigrationStatus{}, false
+	}
+	return status.copy(), true
+}
+
+// Migrations returns the progress of every migration started, by name
+func (cm *clusterManager) Migrations() []MigrationStatus {
+	cm.migrations.mu.Lock()
+	defer cm.migrations.mu.Unlock()
+
+	statuses := make([]MigrationStatus, 0, len(cm.migrations.status))
+	for _, status := range cm.migrations.status {
+		statuses = append(statuses, status.copy())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// copy returns a copy of the status safe to hand out
+func (s *MigrationStatus) copy() MigrationStatus {
+	c := *s
+	c.Nodes = make(map[NodeID]MigrationNodeStatus, len(s.Nodes))
+	for node, status := range s.Nodes {
+		c.Nodes[node] = status
+	}
+	return c
+}
+
+// migration returns a registered migration
+func (cm *clusterManager) migration(name string) (Migration, bool) {
+	cm.migrations.mu.Lock()
+	defer cm.migrations.mu.Unlock()
+
+	m, ok := cm.migrations.registered[name]
+	return m, ok
+}
+
+// migrationStatus returns the progress of a migration, empty if unknown
+func (cm *clusterManager) migrationStatus(name string) MigrationStatus {
+	status, _ := cm.MigrationStatus(name)
+	return status
+}
+
+// migrationOrder returns the nodes to migrate: the other active nodes by
+// ID, then the local node
+func (cm *clusterManager) migrationOrder() []NodeID {
+	local := cm.localNode.ID()
+	var nodes []NodeID
+	for _, node := range cm.GetActiveNodes() {
+		if node.ID() != local {
+			nodes = append(nodes, node.ID())
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+	return append(nodes, local)
+}
+
+// recordMigration appends migration progress to the control log
+func (cm *clusterManager) recordMigration(ctx context.Context, by string, record migrationRecord) error {
+	if _, err := cm.ProposeControl(ctx, controlKindMigration, record, by); err != nil {
+		return fmt.Errorf("failed to record migration progress: %w", err)
+	}
+	return nil
+}
+
+// applyMigrationRecord is the control applier of migration progress
+func (cm *clusterManager) applyMigrationRecord(ctx context.Context, cmd ControlCommand) error {
+	var record migrationRecord
+	if err := json.Unmarshal(cmd.Payload, &record); err != nil {
+		return fmt.Errorf("failed to parse migration record: %w", err)
+	}
+
+	cm.migrations.mu.Lock()
+	if cm.migrations.status == nil {
+		cm.migrations.status = make(map[string]*MigrationStatus)
+	}
+	status, exists := cm.migrations.status[record.Name]
+	if !exists {
+		status = &MigrationStatus{
+			Name:      record.Name,
+			Breaking:  record.Breaking,
+			StartedBy: cmd.IssuedBy,
+			Nodes:     make(map[NodeID]MigrationNodeStatus),
+		}
+		cm.migrations.status[record.Name] = status
+	}
+	if record.Node != "" {
+		status.Nodes[record.Node] = MigrationNodeStatus{State: record.State, Error: record.Error, UpdatedAt: cmd.IssuedAt}
+	}
+	if record.Completed {
+		status.Completed = true
+	}
+	cm.migrations.mu.Unlock()
+
+	cm.publishEvent(ClusterEvent{
+		Type:      EventMigrationProgress,
+		NodeID:    record.Node,
+		Timestamp: time.Now(),
+		Payload:   MigrationProgress{Migration: record.Name, Node: record.Node, State: record.State, Completed: record.Completed},
+		Data: map[string]interface{}{
+			"migration": record.Name,
+			"state":     record.State,
+			"completed": record.Completed,
+		},
+	})
+	return nil
+}
+
+// runMigrationOn runs the steps of a migration on node and waits for them
+// to finish
+func (cm *clusterManager) runMigrationOn(ctx context.Context, m Migration, node NodeID) error {
+	if m.StepTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.StepTimeout)
+		defer cancel()
+	}
+	if node == cm.localNode.ID() {
+		return cm.runMigrationSteps(ctx, m)
+	}
+	if cm.transport == nil {
+		return fmt.Errorf("no transport to reach node %s", node)
+	}
+
+	req := migrationStepRequest{ID: generateMessageID(), Name: m.Name}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to serialize migration step: %w", err)
+	}
+	result := make(chan migrationStepResult, 1)
+	cm.migrations.mu.Lock()
+	if cm.migrations.pending == nil {
+		cm.migrations.pending = make(map[string]pendingMigrationStep)
+	}
+	cm.migrations.pending[req.ID] = pendingMigrationStep{node: node, result: result}
+	cm.migrations.mu.Unlock()
+	defer func() {
+		cm.migrations.mu.Lock()
+		delete(cm.migrations.pending, req.ID)
+		cm.migrations.mu.Unlock()
+	}()
+
+	msg := &ClusterMessage{
+		ID:        generateMessageID(),
+		Type:      MessageTypeMigrationStep,
+		From:      cm.localNode.ID(),
+		To:        node,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	}
+	if err := cm.signClusterMessage(msg); err != nil {
+		return err
+	}
+	if err := cm.transport.Send(ctx, node, msg); err != nil {
+		return fmt.Errorf("failed to reach node %s: %w", node, err)
+	}
+
+	select {
+	case res := <-result:
+		if res.Error != "" {
+			return errors.New(res.Error)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("node %s did not finish: %w", node, ctx.Err())
+	}
+}
+
+// runMigrationSteps runs the steps of a migration on the local node,
+// draining it first if the migration asks to
+func (cm *clusterManager) runMigrationSteps(ctx context.Context, m Migration) error {
+	if m.Drain {
+		atomic.AddInt32(&cm.migrations.draining, 1)
+		defer atomic.AddInt32(&cm.migrations.draining, -1)
+	}
+	for _, step := range []struct {
+		name string
+		run  MigrationStep
+	}{{"pre", m.Pre}, {"post", m.Post}} {
+		if step.run == nil {
+			continue
+		}
+		if err := callMigrationStep(ctx, step.run); err != nil {
+			return fmt.Errorf("%s step: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+// callMigrationStep runs a step until it returns or ctx is done, turning a
+// panic into an error
+func callMigrationStep(ctx context.Context, step MigrationStep) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panicked: %v", r)
+			}
+		}()
+		done <- step(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleMigrationStep runs the steps the leader asked for and reports back.
+// Steps are accepted only from the current leader and run once per request
+// ID, so a replayed request is ignored.
+func (cm *clusterManager) handleMigrationStep(from NodeID, message *ClusterMessage) error {
+	if err := cm.verifyClusterMessage(from, message); err != nil {
+		return err
+	}
+	if leader, _ := cm.leaderTerm(); from != leader {
+		return fmt.Errorf("%w: migration step from %s, leader is %s", ErrNotFromLeader, from, leader)
+	}
+
+	var req migrationStepRequest
+	if err := json.Unmarshal(message.Payload, &req); err != nil {
+		return fmt.Errorf("failed to parse migration step: %w", err)
+	}
+
+	cm.migrations.mu.Lock()
+	if cm.migrations.handled[req.ID] {
+		cm.migrations.mu.Unlock()
+		return nil
+	}
+	if cm.migrations.handled == nil {
+		cm.migrations.handled = make(map[string]bool)
+	}
+	cm.migrations.handled[req.ID] = true
+	cm.migrations.mu.Unlock()
+
+	go func() {
+		ctx := cm.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		res := migrationStepResult{ID: req.ID}
+		if m, ok := cm.migration(req.Name); !ok {
+			res.Error = fmt.Sprintf("%v: %s", ErrUnknownMigration, req.Name)
+		} else if err := cm.runMigrationSteps(ctx, m); err != nil {
+			res.Error = err.Error()
+		}
+
+		payload, err := json.Marshal(res)
+		if err != nil {
+			return
+		}
+		reply := &ClusterMessage{
+			ID:        generateMessageID(),
+			Type:      MessageTypeMigrationStepDone,
+			From:      cm.localNode.ID(),
+			To:        from,
+			Payload:   payload,
+			Timestamp: time.Now(),
+		}
+		if err := cm.signClusterMessage(reply); err != nil {
+			return
+		}
+		cm.transport.Send(ctx, from, reply)
+	}()
+	return nil
+}
+
+// handleMigrationStepDone hands the outcome of a step to RunMigration. With
+// an authenticator set, only a signed result is heard, and only from the
+// node the step was sent to.
+func (cm *clusterManager) handleMigrationStepDone(from NodeID, message *ClusterMessage) error {
+	if err := cm.verifyClusterMessage(from, message); err != nil {
+		return err
+	}
+
+	var res migrationStepResult
+	if err := json.Unmarshal(message.Payload, &res); err != nil {
+		return fmt.Errorf("failed to parse migration step result: %w", err)
+	}
+
+	cm.migrations.mu.Lock()
+	pending, exists := cm.migrations.pending[res.ID]
+	cm.migrations.mu.Unlock()
+	if !exists {
+		return nil
+	}
+	if pending.node != from {
+		return fmt.Errorf("result of migration step %s from %s, sent to %s", res.ID, from, pending.node)
+	}
+	select {
+	case pending.result <- res:
+	default:
+	}
+	return nil
+}
+
+// checkMigrationTraffic refuses calls from peer while the local node is
+// draining, or while a breaking migration finished on only one of them
+func (cm *clusterManager) checkMigrationTraffic(peer NodeID) error {
+	if atomic.LoadInt32(&cm.migrations.draining) > 0 {
+		return fmt.Errorf("node %s: %w", cm.localNode.ID(), ErrNodeDraining)
+	}
+	return cm.checkMixedVersion(peer)
+}
+
+// checkMixedVersion refuses traffic with peer while a breaking migration
+// finished on only one of the two nodes
+func (cm *clusterManager) checkMixedVersion(peer NodeID) error {
+	local := cm.localNode.ID()
+	cm.migrations.mu.Lock()
+	defer cm.migrations.mu.Unlock()
+	for _, status := range cm.migrations.status {
+		if status.Breaking && !status.Completed && status.done(local) != status.done(peer) {
+			return fmt.Errorf("%w: %s between %s and %s", ErrMixedVersion, status.Name, local, peer)
+		}
+	}
+	return nil
+}
+
+// migrationCheck is implemented by cluster managers running migrations,
+// letting the service layer refuse mixed-version calls
+type migrationCheck interface {
+	checkMixedVersion(peer NodeID) error
+}
+
+// refuseMixedVersion refuses calls to peer during a breaking migration
+// only one of the nodes finished
+func refuseMixedVersion(manager ClusterManager, peer NodeID) error {
+	if mc, ok := manager.(migrationCheck); ok && peer != manager.LocalNode().ID() {
+		return mc.checkMixedVersion(peer)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunMigration(t *testing.T) {
+	managers, _ := controlCluster(t, "a", "b", "c")
+	leader := managers["a"]
+	for _, id := range []NodeID{"b", "c"} {
+		leader.addNode(NewRemoteNode(&NodeInfo{ID: id, State: NodeStateActive}))
+	}
+
+	var mu sync.Mutex
+	var steps []string
+	failC := true
+	drainErr := make(chan error, 1)
+	for id, cm := range managers {
+		id, cm := id, cm
+		err := cm.RegisterMigration(Migration{
+			Name:     "protocol-v2",
+			Breaking: true,
+			Drain:    true,
+			Pre: func(ctx context.Context) error {
+				if id == "b" {
+					drainErr <- cm.checkMigrationTraffic("a")
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				steps = append(steps, string(id)+":pre")
+				return nil
+			},
+			Post: func(ctx context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if id == "c" && failC {
+					return errors.New("schema locked")
+				}
+				steps = append(steps, string(id)+":post")
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to register migration: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	if _, err := managers["b"].RunMigration(ctx, "protocol-v2", "alice"); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Expected ErrNotLeader on a follower, got %v", err)
+	}
+	if _, err := leader.RunMigration(ctx, "protocol-v3", "alice"); !errors.Is(err, ErrUnknownMigration) {
+		t.Errorf("Expected ErrUnknownMigration, got %v", err)
+	}
+
+	// The rollout stops at c, leaving b migrated and a not
+	status, err := leader.RunMigration(ctx, "protocol-v2", "alice")
+	if !errors.Is(err, ErrMigrationFailed) {
+		t.Fatalf("Expected ErrMigrationFailed, got %v", err)
+	}
+	if !errors.Is(<-drainErr, ErrNodeDraining) {
+		t.Error("Expected b to refuse calls while draining")
+	}
+	if status.Nodes["b"].State != MigrationDone || status.Nodes["c"].State != MigrationFailed || status.Completed {
+		t.Fatalf("Unexpected status %+v", status)
+	}
+	if _, started := status.Nodes["a"]; started {
+		t.Errorf("Expected a not started, got %+v", status.Nodes["a"])
+	}
+
+	// Every node records the progress and refuses mixed-version traffic
+	if replica, _ := managers["c"].MigrationStatus("protocol-v2"); replica.Nodes["c"].Error == "" {
+		t.Errorf("Expected c to record its failure, got %+v", replica)
+	}
+	if err := leader.checkMigrationTraffic("b"); !errors.Is(err, ErrMixedVersion) {
+		t.Errorf("Expected a to refuse b, got %v", err)
+	}
+	if err := refuseMixedVersion(managers["b"], "c"); !errors.Is(err, ErrMixedVersion) {
+		t.Errorf("Expected b to refuse calling c, got %v", err)
+	}
+	if err := leader.checkMigrationTraffic("c"); err != nil {
+		t.Errorf("Expected a and c to talk, got %v", err)
+	}
+
+	// Running it again resumes with the nodes that did not finish
+	mu.Lock()
+	failC = false
+	mu.Unlock()
+	status, err = leader.RunMigration(ctx, "protocol-v2", "alice")
+	if err != nil || !status.Completed {
+		t.Fatalf("Expected the migration completed, got %+v (%v)", status, err)
+	}
+	want := []string{"b:pre", "b:post", "c:pre", "c:pre", "c:post", "a:pre", "a:post"}
+	mu.Lock()
+	if len(steps) != len(want) {
+		t.Errorf("Expected steps %v, got %v", want, steps)
+	} else {
+		for i := range want {
+			if steps[i] != want[i] {
+				t.Errorf("Expected steps %v, got %v", want, steps)
+				break
+			}
+		}
+	}
+	mu.Unlock()
+	if err := managers["b"].checkMigrationTraffic("c"); err != nil {
+		t.Errorf("Expected traffic allowed once completed, got %v", err)
+	}
+	if migrations := managers["c"].Migrations(); len(migrations) != 1 || !migrations[0].Completed {
+		t.Errorf("Expected c to see the migration completed, got %+v", migrations)
+	}
+
+	// Steps come from the leader only and run once per request
+	step := &ClusterMessage{Type: MessageTypeMigrationStep, Payload: []byte(`{"id":"replayed","name":"protocol-v2"}`)}
+	if err := managers["c"].HandleMessage(ctx, "b", step); !errors.Is(err, ErrNotFromLeader) {
+		t.Errorf("Expected a step from a follower refused, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := managers["c"].HandleMessage(ctx, "a", step); err != nil {
+			t.Fatalf("Expected the leader's step accepted, got %v", err)
+		}
+	}
+	waitFor(t, func() bool { mu.Lock(); defer mu.Unlock(); return len(steps) >= len(want)+2 })
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	if len(steps) != len(want)+2 {
+		t.Errorf("Expected the replayed step run once, got %v", steps[len(want):])
+	}
+	mu.Unlock()
+}
+
+func TestMigrationStepDoneAuthenticated(t *testing.T) {
+	managers, _ := controlCluster(t, "a", "b", "c")
+	authenticateCluster(managers)
+	leader := managers["a"]
+	for _, id := range []NodeID{"b", "c"} {
+		leader.addNode(NewRemoteNode(&NodeInfo{ID: id, State: NodeStateActive}))
+	}
+
+	release := make(chan struct{})
+	for id, cm := range managers {
+		id := id
+		cm.RegisterMigration(Migration{
+			Name:     "protocol-v2",
+			Breaking: true,
+			Pre: func(ctx context.Context) error {
+				if id == "b" {
+					<-release
+				}
+				return nil
+			},
+		})
+	}
+
+	ctx := context.Background()
+	done := make(chan error, 1)
+	go func() {
+		_, err := leader.RunMigration(ctx, "protocol-v2", "alice")
+		done <- err
+	}()
+
+	var stepID string
+	waitFor(t, func() bool {
+		leader.migrations.mu.Lock()
+		defer leader.migrations.mu.Unlock()
+		for id := range leader.migrations.pending {
+			stepID = id
+		}
+		return stepID != ""
+	})
+
+	// Neither an unsigned result nor one signed by another node finishes b
+	payload := []byte(`{"id":"` + stepID + `"}`)
+	unsigned := &ClusterMessage{Type: MessageTypeMigrationStepDone, From: "b", Payload: payload}
+	if err := leader.HandleMessage(ctx, "b", unsigned); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected an unsigned result refused, got %v", err)
+	}
+	forged := &ClusterMessage{Type: MessageTypeMigrationStepDone, From: "c", Payload: payload}
+	managers["c"].signClusterMessage(forged)
+	if err := leader.HandleMessage(ctx, "c", forged); err == nil {
+		t.Error("Expected a result from another node refused")
+	}
+	if status, _ := leader.MigrationStatus("protocol-v2"); status.Nodes["b"].State == MigrationDone {
+		t.Fatalf("Expected b still migrating, got %+v", status.Nodes["b"])
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to run migration: %v", err)
+	}
+	if status, _ := leader.MigrationStatus("protocol-v2"); !status.Completed {
+		t.Errorf("Expected the migration completed, got %+v", status)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/najoast/sngo/core"
 )
 
 // localNode implements the Node interface for the local node
@@ -42,6 +44,7 @@ func NewLocalNode(id NodeID, address net.Addr, metadata map[string]string) Node
 		StateChange: now,
 		Load:        0.0,
 		Version:     "1.0.0",
+		BootEpoch:   now.UnixNano(),
 	}
 
 	return &localNode{
@@ -91,6 +94,7 @@ func (n *localNode) UpdateState(state NodeState) error {
 			Type:      getStateChangeEventType(oldState, state),
 			NodeID:    n.id,
 			Timestamp: time.Now(),
+			Payload:   NodeStateChanged{OldState: oldState, NewState: state},
 			Data: map[string]interface{}{
 				"old_state": oldState.String(),
 				"new_state": state.String(),
@@ -178,6 +182,7 @@ func (n *remoteNode) UpdateState(state NodeState) error {
 			Type:      getStateChangeEventType(oldState, state),
 			NodeID:    n.info.ID,
 			Timestamp: time.Now(),
+			Payload:   NodeStateChanged{OldState: oldState, NewState: state},
 			Data: map[string]interface{}{
 				"old_state": oldState.String(),
 				"new_state": state.String(),
@@ -224,18 +229,36 @@ type clusterManager struct {
 	service   RemoteService
 	registry  ServiceRegistry
 
-	events      chan ClusterEvent
-	listeners   []func(ClusterEvent)
-	listenersMu sync.RWMutex
+	events        chan ClusterEvent
+	listeners     []eventListener
+	listenersMu   sync.RWMutex
+	subscriptions eventSubscriptions
+
+	compaction compactionCounters
+	chaos      chaosState
 
 	leader   NodeID
+	term     uint64 // highest term seen cluster-wide
+	terms    TermStore
 	leaderMu sync.RWMutex
+	duties   leaderDuties
+
+	readOnly      ReadOnlyState
+	readOnlyAudit []ReadOnlyState
+	readOnlyMu    sync.RWMutex
+
+	control    controlLog
+	migrations migrations
+	rollup     metricsRollup
 
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
 	started int32 // atomic
+
+	duplicateJoins   int64 // atomic
+	quarantinedJoins int64 // atomic
 }
 
 // NewClusterManager creates a new cluster manager
@@ -251,15 +274,18 @@ func NewClusterManager(config *ClusterConfig) ClusterManager {
 	}
 
 	// Create local node
-	localNode := NewLocalNode(config.NodeID, bindAddr, config.Metadata)
+	local := NewLocalNode(config.NodeID, bindAddr, config.Metadata).(*localNode)
+	local.info.Role = config.Role
 
-	return &clusterManager{
+	cm := &clusterManager{
 		config:    config,
-		localNode: localNode,
+		localNode: local,
 		nodes:     make(map[NodeID]Node),
 		events:    make(chan ClusterEvent, 100),
-		listeners: make([]func(ClusterEvent), 0),
+		listeners: make([]eventListener, 0),
 	}
+	cm.control.appliers = map[string]ControlApplier{controlKindMigration: cm.applyMigrationRecord}
+	return cm
 }
 
 func (cm *clusterManager) Start(ctx context.Context) error {
@@ -269,10 +295,21 @@ func (cm *clusterManager) Start(ctx context.Context) error {
 
 	cm.ctx, cm.cancel = context.WithCancel(ctx)
 
+	if cm.terms == nil && cm.config.TermFile != "" {
+		cm.terms = FileTermStore{Path: cm.config.TermFile}
+	}
+	if err := cm.loadTerm(); err != nil {
+		atomic.StoreInt32(&cm.started, 0)
+		return fmt.Errorf("failed to load leadership term: %w", err)
+	}
+
 	// Initialize transport
 	if cm.transport == nil {
 		cm.transport = NewMessageTransport(cm.config)
 	}
+	if cm.config.Chaos.Enabled {
+		cm.transport = &chaosTransport{MessageTransport: cm.transport, manager: cm}
+	}
 
 	// Initialize service
 	if cm.service == nil {
@@ -296,10 +333,12 @@ func (cm *clusterManager) Start(ctx context.Context) error {
 	cm.addNode(cm.localNode)
 
 	// Start background goroutines
-	cm.wg.Add(3)
+	cm.wg.Add(5)
 	go cm.heartbeatLoop()
 	go cm.failureDetectionLoop()
 	go cm.eventProcessingLoop()
+	go cm.compactionLoop()
+	go cm.rollupLoop()
 
 	// Update local node state
 	if err := cm.localNode.UpdateState(NodeStateActive); err != nil {
@@ -307,7 +346,7 @@ func (cm *clusterManager) Start(ctx context.Context) error {
 	}
 
 	// For single node cluster, elect self as leader
-	if len(cm.config.SeedNodes) == 0 {
+	if len(cm.config.SeedNodes) == 0 && !cm.isWitness() {
 		cm.electSelf()
 	}
 
@@ -322,12 +361,17 @@ func (cm *clusterManager) Stop(ctx context.Context) error {
 	// Update local node state
 	cm.localNode.UpdateState(NodeStateLeaving)
 
+	// Stop leader duties before the node goes away
+	cm.StepDown()
+
 	// Send leave message to cluster
 	if err := cm.broadcastLeave(); err != nil {
 		// Log error but don't fail the stop
-		fmt.Printf("Error broadcasting leave: %v\n", err)
+		core.ReportError("cluster", "leave", core.SeverityError, fmt.Errorf("failed to broadcast leave: %w", err))
 	}
 
+	cm.revertAllFaults()
+
 	// Cancel context and wait for goroutines
 	cm.cancel()
 	cm.wg.Wait()
@@ -350,6 +394,9 @@ func (cm *clusterManager) Stop(ctx context.Context) error {
 
 func (cm *clusterManager) Join(ctx context.Context, seeds []string) error {
 	if len(seeds) == 0 {
+		if cm.isWitness() {
+			return fmt.Errorf("a witness node needs seed nodes to join")
+		}
 		// No seeds provided, start as single node cluster
 		cm.electSelf()
 		return nil
@@ -432,7 +479,14 @@ func (cm *clusterManager) AddEventListener(listener func(ClusterEvent)) {
 	cm.listenersMu.Lock()
 	defer cm.listenersMu.Unlock()
 
-	cm.listeners = append(cm.listeners, listener)
+	cm.listeners = append(cm.listeners, eventListener{handle: listener})
+}
+
+func (cm *clusterManager) AddEventListenerContext(ctx context.Context, listener func(ClusterEvent)) {
+	cm.listenersMu.Lock()
+	defer cm.listenersMu.Unlock()
+
+	cm.listeners = append(cm.listeners, eventListener{handle: listener, done: ctx.Done()})
 }
 
 func (cm *clusterManager) GetClusterSize() int {
@@ -444,9 +498,13 @@ func (cm *clusterManager) GetClusterHealth() ClusterHealth {
 	active := 0
 	suspected := 0
 	failed := 0
+	witnesses := 0
 
 	for _, node := range nodes {
 		info := node.Info()
+		if info.IsWitness() {
+			witnesses++
+		}
 		switch info.State {
 		case NodeStateActive:
 			active++
@@ -471,15 +529,18 @@ func (cm *clusterManager) GetClusterHealth() ClusterHealth {
 	}
 
 	return ClusterHealth{
-		TotalNodes:     len(nodes),
-		ActiveNodes:    active,
-		SuspectedNodes: suspected,
-		FailedNodes:    failed,
-		HasLeader:      hasLeader,
-		LeaderID:       leaderID,
-		PartitionCount: 1, // TODO: Implement partition detection
-		LastUpdate:     time.Now(),
-		IsHealthy:      isHealthy,
+		TotalNodes:       len(nodes),
+		ActiveNodes:      active,
+		SuspectedNodes:   suspected,
+		FailedNodes:      failed,
+		HasLeader:        hasLeader,
+		LeaderID:         leaderID,
+		PartitionCount:   1, // TODO: Implement partition detection
+		LastUpdate:       time.Now(),
+		IsHealthy:        isHealthy,
+		DuplicateJoins:   atomic.LoadInt64(&cm.duplicateJoins),
+		QuarantinedJoins: atomic.LoadInt64(&cm.quarantinedJoins),
+		Witnesses:        witnesses,
 	}
 }
 
@@ -500,32 +561,74 @@ func (cm *clusterManager) addNode(node Node) {
 }
 
 func (cm *clusterManager) publishEvent(event ClusterEvent) {
+	if event.Namespace == "" {
+		event.Namespace = core.TelemetryNamespace()
+	}
+
 	select {
 	case cm.events <- event:
 	default:
 		// Channel full, drop event
 	}
 
+	cm.subscriptions.publish(event)
+
 	cm.listenersMu.RLock()
 	defer cm.listenersMu.RUnlock()
 
 	for _, listener := range cm.listeners {
-		go listener(event)
+		if listener.expired() {
+			continue
+		}
+		go listener.handle(event)
 	}
 }
 
 func (cm *clusterManager) electSelf() {
-	cm.leaderMu.Lock()
-	defer cm.leaderMu.Unlock()
+	if cm.isWitness() {
+		// Witnesses never lead
+		return
+	}
 
+	cm.leaderMu.Lock()
+	if cm.leader == cm.localNode.ID() {
+		cm.leaderMu.Unlock()
+		return
+	}
+	// Persist the new term before using it, so no restart reuses it
+	term := cm.term + 1
+	if err := cm.saveTermLocked(term); err != nil {
+		cm.leaderMu.Unlock()
+		core.ReportError("cluster", "election", core.SeverityError, fmt.Errorf("not taking leadership, failed to persist term %d: %w", term, err))
+		return
+	}
 	cm.leader = cm.localNode.ID()
+	cm.term = term
+	token := FencingToken{Term: term, Leader: cm.localNode.ID()}
+	cm.leaderMu.Unlock()
+
+	cm.startDuties(token)
 
 	event := ClusterEvent{
 		Type:      EventLeaderElected,
 		NodeID:    cm.localNode.ID(),
 		Timestamp: time.Now(),
+		Payload:   LeaderElected{LeaderID: cm.localNode.ID(), Term: token.Term},
 	}
 	cm.publishEvent(event)
+
+	if err := cm.announceLeadership(cm.runContext(), "", token); err != nil {
+		core.ReportError("cluster", "election", core.SeverityWarning, fmt.Errorf("failed to announce leadership: %w", err))
+	}
+}
+
+// runContext returns the context of the running manager, or a background
+// context before Start
+func (cm *clusterManager) runContext() context.Context {
+	if cm.ctx != nil {
+		return cm.ctx
+	}
+	return context.Background()
 }
 
 func (cm *clusterManager) joinViaSeed(ctx context.Context, seed string) error {
@@ -533,11 +636,47 @@ func (cm *clusterManager) joinViaSeed(ctx context.Context, seed string) error {
 	return fmt.Errorf("seed joining not implemented")
 }
 
+// broadcastLeave tells the peers this node is leaving, so they stop
+// redialing it
 func (cm *clusterManager) broadcastLeave() error {
-	// TODO: Implement leave broadcast
+	if cm.transport == nil {
+		return nil
+	}
+	msg := &ClusterMessage{
+		ID:        generateMessageID(),
+		Type:      MessageTypeLeave,
+		From:      cm.localNode.ID(),
+		Timestamp: time.Now(),
+	}
+	if err := cm.signClusterMessage(msg); err != nil {
+		return err
+	}
+	return cm.transport.Broadcast(cm.ctx, msg)
+}
+
+// handleLeave marks a peer that announced its departure as left
+func (cm *clusterManager) handleLeave(from NodeID, message *ClusterMessage) error {
+	if err := cm.verifyClusterMessage(from, message); err != nil {
+		return err
+	}
+	if node, exists := cm.GetNode(from); exists {
+		node.UpdateState(NodeStateLeft)
+	}
+	cm.forgetPeer(from)
 	return nil
 }
 
+// forgetPeer stops redialing a node that left or was removed and drops
+// its metrics digests
+func (cm *clusterManager) forgetPeer(nodeID NodeID) {
+	cm.dropMetricsDigests(nodeID)
+	if r, ok := cm.transport.(ReconnectProvider); ok {
+		if reconnects := r.Reconnects(); reconnects != nil {
+			reconnects.Cancel(nodeID)
+		}
+	}
+}
+
 // Background loops
 
 func (cm *clusterManager) heartbeatLoop() {
@@ -589,8 +728,54 @@ func (cm *clusterManager) sendHeartbeat() {
 	// TODO: Implement heartbeat sending
 }
 
+// detectFailures declares failed the nodes suspected for longer than
+// SuspicionTimeout times SuspicionMultiplier. If the leader is one of
+// them, a new one is elected.
 func (cm *clusterManager) detectFailures() {
-	// TODO: Implement failure detection
+	timeout := cm.config.SuspicionTimeout
+	if cm.config.SuspicionMultiplier > 1 {
+		timeout *= time.Duration(cm.config.SuspicionMultiplier)
+	}
+
+	now := time.Now()
+	leader, _ := cm.leaderTerm()
+	leaderFailed := false
+	for _, node := range cm.GetAllNodes() {
+		info := node.Info()
+		if node.IsLocal() || info.State != NodeStateSuspected || now.Sub(info.StateChange) < timeout {
+			continue
+		}
+		node.UpdateState(NodeStateFailed)
+		if info.ID == leader {
+			leaderFailed = true
+		}
+	}
+
+	if leaderFailed {
+		cm.replaceLeader(leader)
+	}
+}
+
+// replaceLeader forgets a failed leader and elects the local node if it has
+// the lowest ID of the active nodes that may lead; the other nodes follow
+// its announcement
+func (cm *clusterManager) replaceLeader(failed NodeID) {
+	cm.leaderMu.Lock()
+	if cm.leader == failed {
+		cm.leader = ""
+	}
+	cm.leaderMu.Unlock()
+
+	if cm.isWitness() {
+		return
+	}
+	local := cm.localNode.ID()
+	for _, node := range cm.LeaderCandidates() {
+		if node.ID() < local {
+			return
+		}
+	}
+	cm.electSelf()
 }
 
 func (cm *clusterManager) processEvent(event ClusterEvent) {
@@ -600,7 +785,41 @@ func (cm *clusterManager) processEvent(event ClusterEvent) {
 // MessageHandler implementation
 
 func (cm *clusterManager) HandleMessage(ctx context.Context, from NodeID, message *ClusterMessage) error {
-	// TODO: Implement message handling
+	if cm.chaosDrops(from) {
+		return nil
+	}
+
+	switch message.Type {
+	case MessageTypeLeaderAnnounce:
+		return cm.handleLeaderAnnounce(ctx, from, message)
+	case MessageTypeLeave:
+		return cm.handleLeave(from, message)
+	case MessageTypeReadOnly:
+		return cm.handleReadOnlyMessage(from, message)
+	case MessageTypeControlCommand:
+		return cm.handleControlCommands(ctx, from, message)
+	case MessageTypeControlAck:
+		return cm.handleControlAck(ctx, from, message)
+	case MessageTypeMigrationStep:
+		return cm.handleMigrationStep(from, message)
+	case MessageTypeMigrationStepDone:
+		return cm.handleMigrationStepDone(from, message)
+	case MessageTypeMetricsDigest:
+		return cm.handleMetricsDigest(from, message)
+	case MessageTypeActorCall, MessageTypeActorReply:
+		if err := cm.checkDataPlane(); err != nil {
+			return err
+		}
+		if err := cm.checkMigrationTraffic(from); err != nil {
+			return err
+		}
+		if handler, ok := cm.service.(interface {
+			HandleMessage(ctx context.Context, from NodeID, message *ClusterMessage) error
+		}); ok {
+			return handler.HandleMessage(ctx, from, message)
+		}
+	}
+	// TODO: Implement remaining message handling
 	return nil
 }
 
@@ -611,9 +830,16 @@ func (cm *clusterManager) HandleConnectionLost(nodeID NodeID, err error) {
 }
 
 func (cm *clusterManager) HandleConnectionEstablished(nodeID NodeID) {
-	if node, exists := cm.GetNode(nodeID); exists {
+	if node, exists := cm.GetNode(nodeID); exists && !cm.chaosSuspects(nodeID) {
 		node.UpdateState(NodeStateActive)
 	}
+
+	// Bring the peer up to date with the read-only mode and control log
+	cm.sendReadOnly(nodeID)
+	if token, ok := cm.FencingToken(); ok {
+		go cm.announceLeadership(cm.runContext(), nodeID, token)
+	}
+	cm.catchUpControl(nodeID)
 }
 
 // Utility functions
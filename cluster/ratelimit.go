@@ -0,0 +1,225 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrThrottled is returned when a caller exceeds its remote call rate limit.
+// It is retryable: the call may succeed once tokens are refilled.
+var ErrThrottled = errors.New("remote call throttled")
+
+// ErrorCodeThrottled is the remote call error code for ErrThrottled
+const ErrorCodeThrottled = "throttled"
+
+// RateLimit configures a token bucket
+type RateLimit struct {
+	// Rate is the number of calls refilled per second (0 disables the limit)
+	Rate float64 `yaml:"rate" json:"rate"`
+
+	// Burst is the bucket capacity (0 means max(1, Rate))
+	Burst int `yaml:"burst" json:"burst"`
+}
+
+// capacity returns the bucket capacity, defaulting an unset Burst
+func (l RateLimit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, l.Rate)
+}
+
+// rateKey identifies one bucket
+type rateKey struct {
+	caller  NodeID
+	service string
+}
+
+// tokenBucket is a classic token bucket refilled lazily on use
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	limit    RateLimit
+	throttle int64
+}
+
+// take refills the bucket and consumes one token if available
+func (b *tokenBucket) take(now time.Time) bool {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+
+	b.tokens += elapsed * b.limit.Rate
+	if burst := b.limit.capacity(); b.tokens > burst {
+		b.tokens = burst
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// ThrottleCount reports throttled calls for one caller and service
+type ThrottleCount struct {
+	Caller    NodeID `json:"caller"`
+	ServiceID string `json:"service_id"`
+	Throttled int64  `json:"throttled"`
+}
+
+// RateLimitStats holds rate limiter metrics
+type RateLimitStats struct {
+	Allowed   int64           `json:"allowed"`
+	Throttled int64           `json:"throttled"`
+	ByCaller  []ThrottleCount `json:"by_caller,omitempty"`
+}
+
+// RateLimiter limits inbound remote calls per (caller node, service) pair
+// so a single misbehaving node cannot starve a shared service
+type RateLimiter struct {
+	mu           sync.Mutex
+	defaultLimit RateLimit
+	overrides    map[string]RateLimit
+	buckets      map[rateKey]*tokenBucket
+
+	allowed   int64
+	throttled int64
+}
+
+// NewRateLimiter creates a limiter applying defaultLimit to every service
+// without an override
+func NewRateLimiter(defaultLimit RateLimit) *RateLimiter {
+	return &RateLimiter{
+		defaultLimit: defaultLimit,
+		overrides:    make(map[string]RateLimit),
+		buckets:      make(map[rateKey]*tokenBucket),
+	}
+}
+
+// SetServiceLimit overrides the limit of a service
+func (rl *RateLimiter) SetServiceLimit(serviceID string, limit RateLimit) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.overrides[serviceID] = limit
+	rl.resetService(serviceID)
+}
+
+// RemoveServiceLimit restores the default limit of a service
+func (rl *RateLimiter) RemoveServiceLimit(serviceID string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	delete(rl.overrides, serviceID)
+	rl.resetService(serviceID)
+}
+
+// resetService drops the buckets of a service so a new limit applies; mu must be held
+func (rl *RateLimiter) resetService(serviceID string) {
+	for key := range rl.buckets {
+		if key.service == serviceID {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// Allow consumes one token for caller calling serviceID
+func (rl *RateLimiter) Allow(caller NodeID, serviceID string) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	limit, exists := rl.overrides[serviceID]
+	if !exists {
+		limit = rl.defaultLimit
+	}
+	if limit.Rate <= 0 {
+		rl.allowed++
+		return nil
+	}
+
+	now := time.Now()
+	key := rateKey{caller: caller, service: serviceID}
+	bucket, exists := rl.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: limit.capacity(), last: now, limit: limit}
+		rl.buckets[key] = bucket
+	}
+
+	if !bucket.take(now) {
+		bucket.throttle++
+		rl.throttled++
+		return fmt.Errorf("%w: node %s exceeded %.1f calls/s to %s", ErrThrottled, caller, limit.Rate, serviceID)
+	}
+
+	rl.allowed++
+	return nil
+}
+
+// Prune drops buckets idle for longer than idle, bounding memory when many
+// callers come and go
+func (rl *RateLimiter) Prune(idle time.Duration) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	removed := 0
+	cutoff := time.Now().Add(-idle)
+	for key, bucket := range rl.buckets {
+		if bucket.last.Before(cutoff) {
+			delete(rl.buckets, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// bucketCount returns the number of tracked (caller, service) buckets
+func (rl *RateLimiter) bucketCount() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.buckets)
+}
+
+// Stats returns allowed and throttled counters, busiest throttled pairs first
+func (rl *RateLimiter) Stats() RateLimitStats {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	stats := RateLimitStats{Allowed: rl.allowed, Throttled: rl.throttled}
+	for key, bucket := range rl.buckets {
+		if bucket.throttle > 0 {
+			stats.ByCaller = append(stats.ByCaller, ThrottleCount{
+				Caller:    key.caller,
+				ServiceID: key.service,
+				Throttled: bucket.throttle,
+			})
+		}
+	}
+
+	sort.Slice(stats.ByCaller, func(i, j int) bool {
+		return stats.ByCaller[i].Throttled > stats.ByCaller[j].Throttled
+	})
+	return stats
+}
+
+// SetRateLimiter sets the limiter applied to inbound remote calls
+func (rs *remoteService) SetRateLimiter(limiter *RateLimiter) {
+	rs.securityMu.Lock()
+	defer rs.securityMu.Unlock()
+	rs.limiter = limiter
+}
+
+// throttle applies the rate limiter to an inbound call
+func (rs *remoteService) throttle(from NodeID, serviceID string) error {
+	rs.securityMu.RLock()
+	limiter := rs.limiter
+	rs.securityMu.RUnlock()
+
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Allow(from, serviceID)
+}
@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestRateLimiter tests per-caller, per-service token buckets
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{Rate: 1, Burst: 2})
+	limiter.SetServiceLimit("unlimited", RateLimit{})
+	limiter.SetServiceLimit("tight", RateLimit{Rate: 1, Burst: 1})
+
+	// The burst is spent, then calls are throttled
+	for i := 0; i < 2; i++ {
+		if err := limiter.Allow("node-a", "players"); err != nil {
+			t.Fatalf("Call %d should be allowed: %v", i, err)
+		}
+	}
+	err := limiter.Allow("node-a", "players")
+	if !errors.Is(err, ErrThrottled) || !IsRetryable(err) {
+		t.Errorf("Expected retryable ErrThrottled, got %v", err)
+	}
+
+	// Other callers and services have their own buckets
+	if err := limiter.Allow("node-b", "players"); err != nil {
+		t.Errorf("Expected node-b to be allowed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := limiter.Allow("node-a", "unlimited"); err != nil {
+			t.Fatalf("Expected unlimited service to allow call %d: %v", i, err)
+		}
+	}
+
+	// Overrides apply per service
+	limiter.Allow("node-a", "tight")
+	if err := limiter.Allow("node-a", "tight"); !errors.Is(err, ErrThrottled) {
+		t.Errorf("Expected override to throttle, got %v", err)
+	}
+
+	stats := limiter.Stats()
+	if stats.Throttled != 2 || len(stats.ByCaller) != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Tokens refill over time
+	time.Sleep(1100 * time.Millisecond)
+	if err := limiter.Allow("node-a", "players"); err != nil {
+		t.Errorf("Expected refilled bucket to allow call: %v", err)
+	}
+
+	if removed := limiter.Prune(0); removed == 0 {
+		t.Error("Expected idle buckets to be pruned")
+	}
+
+	// An unset burst holds one second of calls
+	defaulted := NewRateLimiter(RateLimit{Rate: 3})
+	for i := 0; i < 3; i++ {
+		if err := defaulted.Allow("node-a", "players"); err != nil {
+			t.Fatalf("Expected call %d within the default burst: %v", i, err)
+		}
+	}
+	if err := defaulted.Allow("node-a", "players"); !errors.Is(err, ErrThrottled) {
+		t.Errorf("Expected the default burst spent, got %v", err)
+	}
+	if err := NewRateLimiter(RateLimit{Rate: 0.5}).Allow("node-a", "players"); err != nil {
+		t.Errorf("Expected a slow rate to allow one call: %v", err)
+	}
+}
+
+// TestRemoteServiceThrottle tests that throttled errors survive the wire
+func TestRemoteServiceThrottle(t *testing.T) {
+	rs := NewRemoteService(NewClusterManager(DefaultClusterConfig())).(*remoteService)
+	rs.SetRateLimiter(NewRateLimiter(RateLimit{Rate: 1, Burst: 1}))
+
+	if err := rs.throttle("node-a", "players"); err != nil {
+		t.Fatalf("First call should be allowed: %v", err)
+	}
+	err := rs.throttle("node-a", "players")
+	remote := remoteCallError(remoteCallErrorCode(err), err.Error())
+	if !errors.Is(remote, ErrThrottled) || !IsRetryable(remote) {
+		t.Errorf("Expected retryable throttled error after round trip, got %v", remote)
+	}
+	if remote.Error() != err.Error() {
+		t.Errorf("Expected the round trip to keep %q, got %q", err, remote)
+	}
+}
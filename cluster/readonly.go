@@ -0,0 +1,274 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MessageTypeReadOnly carries cluster-wide read-only mode changes
+const MessageTypeReadOnly MessageType = "read_only"
+
+// EventReadOnlyChanged is published whenever the read-only mode changes
+const EventReadOnlyChanged ClusterEventType = "read_only_changed"
+
+// ErrReadOnly is returned for write calls while the cluster is read-only.
+// It is retryable: the call may succeed once the mode is lifted.
+var ErrReadOnly = errors.New("cluster is in read-only mode")
+
+// ErrorCodeReadOnly is the remote call error code for ErrReadOnly
+const ErrorCodeReadOnly = "read_only"
+
+// ReadOnlyState describes the cluster-wide read-only mode
+type ReadOnlyState struct {
+	Enabled   bool      `json:"enabled"`
+	Reason    string    `json:"reason,omitempty"`
+	ToggledBy string    `json:"toggled_by,omitempty"`
+	ToggledAt time.Time `json:"toggled_at,omitempty"`
+
+	// Version orders changes; ties are broken by Origin
+	Version uint64 `json:"version"`
+	Origin  NodeID `json:"origin,omitempty"`
+}
+
+// newerThan returns true if s should replace other
+func (s ReadOnlyState) newerThan(other ReadOnlyState) bool {
+	if s.Version != other.Version {
+		return s.Version > other.Version
+	}
+	return s.Origin > other.Origin
+}
+
+// ReadOnlyCallHandler is implemented by remote call handlers that can tell
+// reads from writes. Handlers that do not implement it are treated as
+// writes and rejected while the cluster is read-only.
+type ReadOnlyCallHandler interface {
+	RemoteCallHandler
+
+	// IsReadOnlyCall returns true if the request does not modify state
+	IsReadOnlyCall(request interface{}) bool
+}
+
+// checkReadOnly rejects write calls while the cluster is read-only
+func (rs *remoteService) checkReadOnly(handler RemoteCallHandler, args interface{}) error {
+	if rs.manager == nil || !rs.manager.ReadOnly().Enabled {
+		return nil
+	}
+
+	if ro, ok := handler.(ReadOnlyCallHandler); ok && ro.IsReadOnlyCall(args) {
+		return nil
+	}
+
+	return ErrReadOnly
+}
+
+// ReadOnly returns the current read-only state
+func (cm *clusterManager) ReadOnly() ReadOnlyState {
+	cm.readOnlyMu.RLock()
+	defer cm.readOnlyMu.RUnlock()
+	return cm.readOnly
+}
+
+// ReadOnlyAudit returns every read-only change applied on this node, oldest first
+func (cm *clusterManager) ReadOnlyAudit() []ReadOnlyState {
+	cm.readOnlyMu.RLock()
+	defer cm.readOnlyMu.RUnlock()
+
+	audit := make([]ReadOnlyState, len(cm.readOnlyAudit))
+	copy(audit, cm.readOnlyAudit)
+	return audit
+}
+
+// SetReadOnly toggles read-only mode on this node and propagates it to the
+// cluster. by identifies who toggled it for the audit trail.
+func (cm *clusterManager) SetReadOnly(ctx context.Context, enabled bool, by, reason string) error {
+	if by == "" {
+		return fmt.Errorf("read-only toggle requires an operator identity")
+	}
+
+	cm.readOnlyMu.Lock()
+	state := ReadOnlyState{
+		Enabled:   enabled,
+		Reason:    reason,
+		ToggledBy: by,
+		ToggledAt: time.Now(),
+		Version:   cm.readOnly.Version + 1,
+		Origin:    cm.localNode.ID(),
+	}
+	cm.applyReadOnlyLocked(state)
+	cm.readOnlyMu.Unlock()
+
+	return cm.broadcastReadOnly(ctx, state)
+}
+
+// applyReadOnly applies a state received from another node if it is newer
+func (cm *clusterManager) applyReadOnly(state ReadOnlyState) bool {
+	cm.readOnlyMu.Lock()
+	defer cm.readOnlyMu.Unlock()
+
+	if !state.newerThan(cm.readOnly) {
+		return false
+	}
+
+	cm.applyReadOnlyLocked(state)
+	return true
+}
+
+// applyReadOnlyLocked records and announces a state change; readOnlyMu must be held
+func (cm *clusterManager) applyReadOnlyLocked(state ReadOnlyState) {
+	cm.readOnly = state
+	cm.readOnlyAudit = append(cm.readOnlyAudit, state)
+
+	cm.publishEvent(ClusterEvent{
+		Type:      EventReadOnlyChanged,
+		NodeID:    state.Origin,
+		Timestamp: time.Now(),
+		Payload:   ReadOnlyChanged{State: state},
+		Data: map[string]interface{}{
+			"enabled":    state.Enabled,
+			"reason":     state.Reason,
+			"toggled_by": state.ToggledBy,
+			"version":    state.Version,
+		},
+	})
+}
+
+// broadcastReadOnly sends the state to every connected node
+func (cm *clusterManager) broadcastReadOnly(ctx context.Context, state ReadOnlyState) error {
+	if atomic.LoadInt32(&cm.started) == 0 || cm.transport == nil {
+		return nil
+	}
+
+	msg, err := readOnlyMessage(cm.localNode.ID(), state)
+	if err != nil {
+		return err
+	}
+	if err := cm.signClusterMessage(msg); err != nil {
+		return fmt.Errorf("read-only mode applied locally but not propagated: %w", err)
+	}
+
+	if err := cm.transport.Broadcast(ctx, msg); err != nil {
+		return fmt.Errorf("read-only mode applied locally but propagation failed: %w", err)
+	}
+	return nil
+}
+
+// sendReadOnly sends the current state to a newly connected node
+func (cm *clusterManager) sendReadOnly(nodeID NodeID) {
+	state := cm.ReadOnly()
+	if state.Version == 0 || atomic.LoadInt32(&cm.started) == 0 || cm.transport == nil {
+		return
+	}
+
+	msg, err := readOnlyMessage(cm.localNode.ID(), state)
+	if err != nil {
+		return
+	}
+	msg.To = nodeID
+	if err := cm.signClusterMessage(msg); err != nil {
+		return
+	}
+
+	go cm.transport.Send(cm.ctx, nodeID, msg)
+}
+
+// handleReadOnlyMessage applies a read-only change received from a peer.
+// The change is authenticated like remote calls: with an authenticator set,
+// only a signed message from a known node is applied.
+func (cm *clusterManager) handleReadOnlyMessage(from NodeID, message *ClusterMessage) error {
+	if err := cm.verifyClusterMessage(from, message); err != nil {
+		return err
+	}
+
+	var state ReadOnlyState
+	if err := json.Unmarshal(message.Payload, &state); err != nil {
+		return fmt.Errorf("failed to parse read-only state: %w", err)
+	}
+
+	cm.applyReadOnly(state)
+	return nil
+}
+
+// readOnlyMessage builds the cluster message carrying state
+func readOnlyMessage(from NodeID, state ReadOnlyState) (*ClusterMessage, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize read-only state: %w", err)
+	}
+
+	return &ClusterMessage{
+		ID:        generateMessageID(),
+		Type:      MessageTypeReadOnly,
+		From:      from,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// AdminIdentifier returns the operator an admin HTTP request was
+// authenticated as, or an error if it was not
+type AdminIdentifier func(r *http.Request) (string, error)
+
+// BearerTokenOperators is an AdminIdentifier accepting the requests whose
+// Authorization header carries one of tokens, mapped to their operator
+func BearerTokenOperators(tokens map[string]string) AdminIdentifier {
+	return func(r *http.Request) (string, error) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if operator, ok := tokens[token]; ok && token != "" {
+			return operator, nil
+		}
+		return "", fmt.Errorf("unknown or missing bearer token")
+	}
+}
+
+// ReadOnlyAdminHandler exposes read-only mode over HTTP for the admin API.
+// GET returns the current state and audit trail; POST toggles the mode with a
+// JSON body {"enabled": true, "reason": "..."}. The operator recorded in the
+// audit trail is the one identify authenticated the request as; with a nil
+// identify the mode cannot be toggled over HTTP.
+func ReadOnlyAdminHandler(manager ClusterManager, identify AdminIdentifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if identify == nil {
+				http.Error(w, "read-only toggling is not enabled", http.StatusForbidden)
+				return
+			}
+			operator, err := identify(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			var req struct {
+				Enabled bool   `json:"enabled"`
+				Reason  string `json:"reason"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+				return
+			}
+			if err := manager.SetReadOnly(r.Context(), req.Enabled, operator, req.Reason); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			State ReadOnlyState   `json:"state"`
+			Audit []ReadOnlyState `json:"audit"`
+		}{
+			State: manager.ReadOnly(),
+			Audit: manager.ReadOnlyAudit(),
+		})
+	})
+}
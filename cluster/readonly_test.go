@@ -0,0 +1,144 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readHandler answers reads only for requests equal to "get"
+type readHandler struct{}
+
+func (readHandler) Handle(ctx context.Context, request interface{}) (interface{}, error) {
+	return request, nil
+}
+
+func (readHandler) IsReadOnlyCall(request interface{}) bool {
+	return request == "get"
+}
+
+// TestReadOnlyMode tests toggling, ordering and enforcement of read-only mode
+func TestReadOnlyMode(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "node-b"
+	manager := NewClusterManager(config).(*clusterManager)
+
+	if err := manager.SetReadOnly(context.Background(), true, "", "incident"); err == nil {
+		t.Error("Expected toggle without operator identity to fail")
+	}
+
+	if err := manager.SetReadOnly(context.Background(), true, "alice", "incident 42"); err != nil {
+		t.Fatalf("Failed to enable read-only mode: %v", err)
+	}
+
+	state := manager.ReadOnly()
+	if !state.Enabled || state.ToggledBy != "alice" || state.Version != 1 {
+		t.Errorf("Unexpected read-only state: %+v", state)
+	}
+
+	// Stale changes from peers are ignored, newer ones win
+	if manager.applyReadOnly(ReadOnlyState{Enabled: false, Version: 1, Origin: "node-a"}) {
+		t.Error("Expected stale state to be ignored")
+	}
+	if !manager.applyReadOnly(ReadOnlyState{Enabled: true, ToggledBy: "bob", Version: 2, Origin: "node-a"}) {
+		t.Error("Expected newer state to be applied")
+	}
+
+	audit := manager.ReadOnlyAudit()
+	if len(audit) != 2 || audit[0].ToggledBy != "alice" || audit[1].ToggledBy != "bob" {
+		t.Errorf("Unexpected audit trail: %+v", audit)
+	}
+
+	rs := NewRemoteService(manager).(*remoteService)
+	if err := rs.checkReadOnly(readHandler{}, "get"); err != nil {
+		t.Errorf("Expected reads to continue, got %v", err)
+	}
+	if err := rs.checkReadOnly(readHandler{}, "set"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly for write, got %v", err)
+	}
+
+	// The error code survives the round trip and stays retryable
+	remote := remoteCallError(remoteCallErrorCode(ErrReadOnly), ErrReadOnly.Error())
+	if !IsRetryable(remote) {
+		t.Errorf("Expected remote read-only error to be retryable, got %v", remote)
+	}
+}
+
+// TestReadOnlyMessagesAuthenticated tests that peers only apply signed changes
+func TestReadOnlyMessagesAuthenticated(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "node-b"
+	manager := NewClusterManager(config).(*clusterManager)
+	manager.service = NewRemoteService(manager)
+	local := NewHMACAuthenticator("node-b", time.Minute)
+	local.AddNode("node-a", []byte("key-a"))
+	manager.service.(*remoteService).SetAuthenticator(local)
+	var denied []AuditEvent
+	manager.service.(*remoteService).SetAuditHandler(func(e AuditEvent) { denied = append(denied, e) })
+
+	state := ReadOnlyState{Enabled: true, ToggledBy: "mallory", Version: 1, Origin: "node-a"}
+	unsigned, _ := readOnlyMessage("node-a", state)
+	if err := manager.HandleMessage(context.Background(), "node-a", unsigned); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected an unsigned change refused, got %v", err)
+	}
+
+	peer := NewHMACAuthenticator("node-a", time.Minute)
+	peer.AddNode("node-a", []byte("key-a"))
+	signed, _ := readOnlyMessage("node-a", state)
+	peer.Sign(signed)
+	if err := manager.HandleMessage(context.Background(), "node-c", signed); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected a change relayed by another node refused, got %v", err)
+	}
+	if manager.ReadOnly().Enabled || len(denied) != 2 {
+		t.Errorf("Expected nothing applied and two denials audited, got %+v", denied)
+	}
+
+	// The relayed copy used up the nonce of its signature, so it is signed anew
+	peer.Sign(signed)
+	if err := manager.HandleMessage(context.Background(), "node-a", signed); err != nil || !manager.ReadOnly().Enabled {
+		t.Errorf("Expected the signed change applied, got %v", err)
+	}
+}
+
+// TestReadOnlyAdminHandler tests the admin HTTP endpoint
+func TestReadOnlyAdminHandler(t *testing.T) {
+	manager := NewClusterManager(DefaultClusterConfig())
+	rec := httptest.NewRecorder()
+	ReadOnlyAdminHandler(manager, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/readonly", strings.NewReader(`{"enabled":true}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected toggling refused without an identifier, got %d", rec.Code)
+	}
+
+	handler := ReadOnlyAdminHandler(manager, BearerTokenOperators(map[string]string{"s3cret": "carol"}))
+
+	// The operator comes from the token, never from the body
+	req := httptest.NewRequest(http.MethodPost, "/readonly", strings.NewReader(`{"enabled":true,"by":"mallory","reason":"migration"}`))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		State ReadOnlyState   `json:"state"`
+		Audit []ReadOnlyState `json:"audit"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !resp.State.Enabled || resp.State.ToggledBy != "carol" || len(resp.Audit) != 1 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/readonly", strings.NewReader(`{"enabled":false,"by":"carol"}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an unauthenticated toggle to be rejected, got %d", rec.Code)
+	}
+}
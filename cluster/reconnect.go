@@ -0,0 +1,305 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrPeerReconnecting is returned for sends to a peer whose connection
+// dropped and is waiting in the reconnect queue
+var ErrPeerReconnecting = errors.New("peer is reconnecting")
+
+// Reconnect priorities; peers with a higher priority are dialed first, and
+// peers of equal priority by the traffic they carried
+const (
+	ReconnectPriorityNormal = 0
+	ReconnectPriorityLeader = 100
+)
+
+// ReconnectConfig bounds how dropped peer connections are re-established.
+// Dials go through one queue, so many peers dropping together (a switch
+// reboot) don't turn into a dial storm
+type ReconnectConfig struct {
+	// MaxConcurrent caps the dials in flight across all peers
+	MaxConcurrent int `yaml:"max_concurrent" json:"max_concurrent"`
+
+	// InitialBackoff is the delay before the first dial; later delays grow
+	// by Multiplier up to MaxBackoff
+	InitialBackoff time.Duration `yaml:"initial_backoff" json:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier" json:"multiplier"`
+
+	// Jitter randomizes each delay by up to this fraction, so peers that
+	// dropped together are not dialed together
+	Jitter float64 `yaml:"jitter" json:"jitter"`
+
+	// MaxAttempts gives up on a peer after this many failed dials; 0
+	// retries until the peer is back or cancelled
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts"`
+}
+
+// ReconnectProvider is implemented by transports that redial dropped peers
+type ReconnectProvider interface {
+	// Reconnects returns the manager redialing dropped peers
+	Reconnects() *ReconnectManager
+}
+
+// DefaultReconnectConfig returns the default reconnect settings
+func DefaultReconnectConfig() ReconnectConfig {
+	return ReconnectConfig{
+		MaxConcurrent:  4,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		MaxAttempts:    20,
+	}
+}
+
+// ReconnectStatus is a peer in the reconnect queue
+type ReconnectStatus struct {
+	NodeID      NodeID    `json:"node_id"`
+	Priority    int       `json:"priority"`
+	Traffic     int64     `json:"traffic"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	Dialing     bool      `json:"dialing"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// ReconnectStatistics reports the reconnect queue, in dial order, and the
+// outcome of past dials
+type ReconnectStatistics struct {
+	Queue     []ReconnectStatus `json:"queue,omitempty"`
+	Dialing   int               `json:"dialing"`
+	Succeeded int64             `json:"succeeded"`
+	Failed    int64             `json:"failed"`
+	GaveUp    int64             `json:"gave_up"`
+}
+
+// ReconnectManager re-dials dropped peers under a global concurrency limit,
+// with per-peer exponential backoff and jitter, leader first
+type ReconnectManager struct {
+	config   ReconnectConfig
+	dial     func(ctx context.Context, nodeID NodeID) error
+	priority func(nodeID NodeID) int
+
+	mu        sync.Mutex
+	peers     map[NodeID]*ReconnectStatus
+	dialing   int
+	succeeded int64
+	failed    int64
+	gaveUp    int64
+	rand      *rand.Rand
+
+	wake chan struct{}
+}
+
+// NewReconnectManager creates a reconnect manager dialing peers with dial
+func NewReconnectManager(config ReconnectConfig, dial func(ctx context.Context, nodeID NodeID) error) *ReconnectManager {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 1
+	}
+	return &ReconnectManager{
+		config:   config,
+		dial:     dial,
+		priority: func(NodeID) int { return ReconnectPriorityNormal },
+		peers:    make(map[NodeID]*ReconnectStatus),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// SetPriority sets how peers are prioritized; it is asked again each time
+// the queue is ordered, so a new leader moves up at once
+func (rm *ReconnectManager) SetPriority(priority func(nodeID NodeID) int) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.priority = priority
+}
+
+// Schedule queues a dropped peer for reconnection; traffic is the bytes
+// its connection carried, ranking it among peers of equal priority
+func (rm *ReconnectManager) Schedule(nodeID NodeID, traffic int64) {
+	rm.mu.Lock()
+	if _, queued := rm.peers[nodeID]; !queued {
+		rm.peers[nodeID] = &ReconnectStatus{
+			NodeID:      nodeID,
+			Traffic:     traffic,
+			NextAttempt: time.Now().Add(rm.backoff(0)),
+		}
+	}
+	rm.mu.Unlock()
+	rm.signal()
+}
+
+// Cancel removes a peer from the queue, e.g. once it left the cluster or
+// connected to us
+func (rm *ReconnectManager) Cancel(nodeID NodeID) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	delete(rm.peers, nodeID)
+}
+
+// Pending reports whether a peer is waiting to be reconnected
+func (rm *ReconnectManager) Pending(nodeID NodeID) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	_, queued := rm.peers[nodeID]
+	return queued
+}
+
+// Statistics returns the reconnect queue and dial counters
+func (rm *ReconnectManager) Statistics() ReconnectStatistics {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	return ReconnectStatistics{
+		Queue:     rm.orderedLocked(),
+		Dialing:   rm.dialing,
+		Succeeded: rm.succeeded,
+		Failed:    rm.failed,
+		GaveUp:    rm.gaveUp,
+	}
+}
+
+// Run dials queued peers until ctx is done
+func (rm *ReconnectManager) Run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		wait := rm.dispatch(ctx, time.Now())
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-rm.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// dispatch starts the dials that are due and free slots allow, in queue
+// order, and returns how long until the next one is due
+func (rm *ReconnectManager) dispatch(ctx context.Context, now time.Time) time.Duration {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	wait := time.Hour
+	for _, status := range rm.orderedLocked() {
+		if status.Dialing {
+			continue
+		}
+		if due := status.NextAttempt.Sub(now); due > 0 {
+			if due < wait {
+				wait = due
+			}
+			continue
+		}
+		if rm.dialing >= rm.config.MaxConcurrent {
+			break // a finished dial wakes Run up
+		}
+
+		peer := rm.peers[status.NodeID]
+		peer.Dialing = true
+		peer.Attempts++
+		rm.dialing++
+		go rm.redial(ctx, peer.NodeID)
+	}
+	return wait
+}
+
+// redial dials a peer once and requeues it on failure
+func (rm *ReconnectManager) redial(ctx context.Context, nodeID NodeID) {
+	err := rm.dial(ctx, nodeID)
+
+	rm.mu.Lock()
+	rm.dialing--
+	peer, queued := rm.peers[nodeID]
+	switch {
+	case err == nil:
+		rm.succeeded++
+		delete(rm.peers, nodeID)
+	case !queued:
+		rm.failed++ // cancelled while dialing
+	default:
+		rm.failed++
+		peer.Dialing = false
+		peer.LastError = err.Error()
+		if rm.config.MaxAttempts > 0 && peer.Attempts >= rm.config.MaxAttempts {
+			rm.gaveUp++
+			delete(rm.peers, nodeID)
+		} else {
+			peer.NextAttempt = time.Now().Add(rm.backoff(peer.Attempts))
+		}
+	}
+	rm.mu.Unlock()
+	rm.signal()
+}
+
+// backoff returns the jittered delay before the dial following attempts
+// failed ones; callers hold mu
+func (rm *ReconnectManager) backoff(attempts int) time.Duration {
+	d := rm.config.InitialBackoff
+	for i := 0; i < attempts && rm.config.Multiplier > 1; i++ {
+		d = time.Duration(float64(d) * rm.config.Multiplier)
+		if rm.config.MaxBackoff > 0 && d >= rm.config.MaxBackoff {
+			d = rm.config.MaxBackoff
+			break
+		}
+	}
+	if rm.config.Jitter > 0 {
+		d += time.Duration((rm.rand.Float64()*2 - 1) * rm.config.Jitter * float64(d))
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// orderedLocked returns the queue by priority, then traffic, then due
+// time; callers hold mu
+func (rm *ReconnectManager) orderedLocked() []ReconnectStatus {
+	queue := make([]ReconnectStatus, 0, len(rm.peers))
+	for _, peer := range rm.peers {
+		peer.Priority = rm.priority(peer.NodeID)
+		queue = append(queue, *peer)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		a, b := queue[i], queue[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Traffic != b.Traffic {
+			return a.Traffic > b.Traffic
+		}
+		return a.NextAttempt.Before(b.NextAttempt)
+	})
+	return queue
+}
+
+// signal wakes Run up to reconsider the queue
+func (rm *ReconnectManager) signal() {
+	select {
+	case rm.wake <- struct{}{}:
+	default:
+	}
+}
+
+// leaderLookup is implemented by message handlers that know the leader
+type leaderLookup interface {
+	GetLeader() (Node, bool)
+}
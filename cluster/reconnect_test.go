@@ -0,0 +1,187 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second passes
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReconnectConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight int32
+	dial := func(ctx context.Context, nodeID NodeID) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return nil
+	}
+
+	config := DefaultReconnectConfig()
+	config.MaxConcurrent = 2
+	config.InitialBackoff = 0
+	rm := NewReconnectManager(config, dial)
+	for _, id := range []NodeID{"a", "b", "c", "d", "e", "f"} {
+		rm.Schedule(id, 0)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rm.Run(ctx)
+
+	waitFor(t, func() bool { return rm.Statistics().Succeeded == 6 })
+	if max := atomic.LoadInt32(&maxInFlight); max > 2 {
+		t.Errorf("Expected at most 2 dials in flight, got %d", max)
+	}
+	if stats := rm.Statistics(); len(stats.Queue) != 0 || stats.Dialing != 0 {
+		t.Errorf("Expected an empty queue, got %+v", stats)
+	}
+}
+
+func TestReconnectPriorityOrder(t *testing.T) {
+	var mu sync.Mutex
+	var order []NodeID
+	dial := func(ctx context.Context, nodeID NodeID) error {
+		mu.Lock()
+		order = append(order, nodeID)
+		mu.Unlock()
+		return nil
+	}
+
+	config := DefaultReconnectConfig()
+	config.MaxConcurrent = 1
+	config.InitialBackoff = 0
+	config.Jitter = 0
+	rm := NewReconnectManager(config, dial)
+	rm.SetPriority(func(nodeID NodeID) int {
+		if nodeID == "leader" {
+			return ReconnectPriorityLeader
+		}
+		return ReconnectPriorityNormal
+	})
+	rm.Schedule("quiet", 10)
+	rm.Schedule("leader", 0)
+	rm.Schedule("busy", 1000)
+
+	queue := rm.Statistics().Queue
+	if len(queue) != 3 || queue[0].NodeID != "leader" || queue[1].NodeID != "busy" {
+		t.Fatalf("Expected the leader, then the busiest peer first, got %+v", queue)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rm.Run(ctx)
+
+	waitFor(t, func() bool { return rm.Statistics().Succeeded == 3 })
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 3 || order[0] != "leader" || order[1] != "busy" || order[2] != "quiet" {
+		t.Errorf("Unexpected dial order %v", order)
+	}
+}
+
+func TestReconnectBackoffAndGiveUp(t *testing.T) {
+	var mu sync.Mutex
+	var dials []time.Time
+	dial := func(ctx context.Context, nodeID NodeID) error {
+		mu.Lock()
+		dials = append(dials, time.Now())
+		mu.Unlock()
+		return errors.New("connection refused")
+	}
+
+	config := DefaultReconnectConfig()
+	config.InitialBackoff = 10 * time.Millisecond
+	config.Jitter = 0
+	config.MaxAttempts = 3
+	rm := NewReconnectManager(config, dial)
+	rm.Schedule("gone", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rm.Run(ctx)
+
+	waitFor(t, func() bool { return rm.Statistics().GaveUp == 1 })
+	if rm.Pending("gone") {
+		t.Error("Expected the peer to leave the queue after giving up")
+	}
+	if stats := rm.Statistics(); stats.Failed != 3 {
+		t.Errorf("Expected 3 failed dials, got %+v", stats)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Delays double: 10ms, 20ms, 40ms
+	if gap := dials[2].Sub(dials[1]); gap < 35*time.Millisecond {
+		t.Errorf("Expected exponential backoff, third dial came %v after the second", gap)
+	}
+}
+
+func TestTransportFailsFastWhileReconnecting(t *testing.T) {
+	mt := NewMessageTransport(DefaultClusterConfig()).(*messageTransport)
+	mt.reconnect.Schedule("peer", 0)
+
+	err := mt.Send(context.Background(), "peer", &ClusterMessage{Type: MessageTypeHeartbeat})
+	if !errors.Is(err, ErrPeerReconnecting) {
+		t.Errorf("Expected ErrPeerReconnecting, got %v", err)
+	}
+	if queue := mt.GetStatistics().Reconnects.Queue; len(queue) != 1 || queue[0].NodeID != "peer" {
+		t.Errorf("Expected the peer in the reported queue, got %+v", queue)
+	}
+}
+
+// TestReconnectForgetsDepartedPeers tests that peers that left or were
+// removed are no longer redialed
+func TestReconnectForgetsDepartedPeers(t *testing.T) {
+	if DefaultReconnectConfig().MaxAttempts == 0 {
+		t.Error("Expected the default to give up on a peer eventually")
+	}
+
+	config := DefaultClusterConfig()
+	config.Compaction.DeadNodeTTL = time.Millisecond
+	config.QuarantinePeriod = 0
+	cm := NewClusterManager(config).(*clusterManager)
+	cm.transport = NewMessageTransport(cm.config)
+	reconnects := cm.transport.(ReconnectProvider).Reconnects()
+
+	cm.addNode(NewRemoteNode(&NodeInfo{ID: "leaving", State: NodeStateActive}))
+	reconnects.Schedule("leaving", 0)
+	if err := cm.HandleMessage(context.Background(), "leaving", &ClusterMessage{Type: MessageTypeLeave}); err != nil {
+		t.Fatalf("Failed to handle leave: %v", err)
+	}
+	if reconnects.Pending("leaving") {
+		t.Error("Expected a peer that left no longer redialed")
+	}
+	if node, _ := cm.GetNode("leaving"); node.Info().State != NodeStateLeft {
+		t.Errorf("Expected the peer marked left, got %s", node.Info().State)
+	}
+
+	cm.addNode(NewRemoteNode(&NodeInfo{ID: "dead", State: NodeStateFailed, StateChange: time.Now().Add(-time.Minute)}))
+	reconnects.Schedule("dead", 0)
+	time.Sleep(2 * time.Millisecond)
+	if removed := cm.compactNodes(time.Now()); removed != 2 {
+		t.Errorf("Expected both departed peers removed, got %d", removed)
+	}
+	if reconnects.Pending("dead") {
+		t.Error("Expected a removed peer no longer redialed")
+	}
+}
@@ -0,0 +1,337 @@
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// SnapshotFormatVersion is the version of the cluster snapshot file format.
+// Import refuses files written by a newer format.
+const SnapshotFormatVersion = 1
+
+// EventStateImported is published after a cluster snapshot is imported
+const EventStateImported ClusterEventType = "state_imported"
+
+// ErrSnapshotVersion is returned for snapshots of an unsupported format version
+var ErrSnapshotVersion = errors.New("unsupported cluster snapshot version")
+
+// ErrSnapshotCorrupt is returned when a snapshot fails its checksum
+var ErrSnapshotCorrupt = errors.New("cluster snapshot checksum mismatch")
+
+// MemberIntent is the membership a restored cluster should converge to
+type MemberIntent struct {
+	ID       NodeID            `json:"id"`
+	Address  string            `json:"address"`
+	Port     int               `json:"port"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Version  string            `json:"version,omitempty"`
+}
+
+// ClusterState is the control-plane state carried by a snapshot
+type ClusterState struct {
+	ClusterName string                       `json:"cluster_name"`
+	Members     []MemberIntent               `json:"members"`
+	Services    map[string][]ServiceInstance `json:"services"`
+	ReadOnly    ReadOnlyState                `json:"read_only"`
+}
+
+// ClusterSnapshot is a portable, versioned export of the cluster state
+type ClusterSnapshot struct {
+	FormatVersion int          `json:"format_version"`
+	ExportedAt    time.Time    `json:"exported_at"`
+	ExportedBy    NodeID       `json:"exported_by"`
+	Checksum      string       `json:"checksum"`
+	State         ClusterState `json:"state"`
+}
+
+// ImportOptions controls how a snapshot is imported
+type ImportOptions struct {
+	// DryRun validates the snapshot and reports what would change
+	// without touching the cluster
+	DryRun bool
+
+	// Force imports into a cluster with a different name or one that
+	// already has registered services
+	Force bool
+}
+
+// ImportReport describes the outcome of an import or dry run
+type ImportReport struct {
+	DryRun            bool     `json:"dry_run"`
+	MembersAdded      int      `json:"members_added"`
+	MembersKnown      int      `json:"members_known"`
+	InstancesRestored int      `json:"instances_restored"`
+	ReadOnlyRestored  bool     `json:"read_only_restored"`
+	Warnings          []string `json:"warnings,omitempty"`
+}
+
+// ExportClusterState captures a consistent snapshot of the membership,
+// service registry and read-only mode known to this node
+func ExportClusterState(manager ClusterManager, registry ServiceRegistry) (*ClusterSnapshot, error) {
+	if manager == nil || registry == nil {
+		return nil, fmt.Errorf("export requires a cluster manager and a service registry")
+	}
+
+	state := ClusterState{
+		Services: registry.GetAllServices(),
+		ReadOnly: manager.ReadOnly(),
+	}
+	if cm, ok := manager.(*clusterManager); ok {
+		state.ClusterName = cm.config.ClusterName
+	}
+
+	for _, node := range manager.GetAllNodes() {
+		info := node.Info()
+		if info.State == NodeStateLeft {
+			continue
+		}
+		state.Members = append(state.Members, MemberIntent{
+			ID:       info.ID,
+			Address:  info.Address,
+			Port:     info.Port,
+			Metadata: info.Metadata,
+			Version:  info.Version,
+		})
+	}
+	sort.Slice(state.Members, func(i, j int) bool {
+		return state.Members[i].ID < state.Members[j].ID
+	})
+
+	checksum, err := stateChecksum(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClusterSnapshot{
+		FormatVersion: SnapshotFormatVersion,
+		ExportedAt:    time.Now(),
+		ExportedBy:    manager.LocalNode().ID(),
+		Checksum:      checksum,
+		State:         state,
+	}, nil
+}
+
+// Validate checks the format version and checksum of a snapshot
+func (s *ClusterSnapshot) Validate() error {
+	if s.FormatVersion < 1 || s.FormatVersion > SnapshotFormatVersion {
+		return fmt.Errorf("%w: %d (supported up to %d)", ErrSnapshotVersion, s.FormatVersion, SnapshotFormatVersion)
+	}
+
+	checksum, err := stateChecksum(s.State)
+	if err != nil {
+		return err
+	}
+	if checksum != s.Checksum {
+		return ErrSnapshotCorrupt
+	}
+
+	members := make(map[NodeID]bool, len(s.State.Members))
+	for _, member := range s.State.Members {
+		if member.ID == "" {
+			return fmt.Errorf("snapshot member without a node ID")
+		}
+		if members[member.ID] {
+			return fmt.Errorf("snapshot lists node %s more than once", member.ID)
+		}
+		members[member.ID] = true
+	}
+
+	for serviceID, instances := range s.State.Services {
+		for _, instance := range instances {
+			if instance.ServiceID != serviceID {
+				return fmt.Errorf("instance of %s filed under service %s", instance.ServiceID, serviceID)
+			}
+			if !members[instance.NodeID] {
+				return fmt.Errorf("service %s has an instance on unknown node %s", serviceID, instance.NodeID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteTo writes the snapshot as indented JSON
+func (s *ClusterSnapshot) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to serialize cluster snapshot: %w", err)
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// ReadClusterSnapshot reads and validates a snapshot
+func ReadClusterSnapshot(r io.Reader) (*ClusterSnapshot, error) {
+	var snapshot ClusterSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster snapshot: %w", err)
+	}
+	if err := snapshot.Validate(); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// SaveClusterSnapshot writes a snapshot to path atomically
+func SaveClusterSnapshot(path string, snapshot *ClusterSnapshot) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+
+	if _, err := snapshot.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to sync snapshot file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to close snapshot file: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// LoadClusterSnapshot reads and validates the snapshot at path
+func LoadClusterSnapshot(path string) (*ClusterSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot file: %w", err)
+	}
+	defer f.Close()
+
+	return ReadClusterSnapshot(f)
+}
+
+// ImportClusterState restores a snapshot into a fresh cluster. Members are
+// added as unknown until they heartbeat, instances are restored with
+// unknown health and the read-only mode is reapplied. With DryRun the
+// snapshot is only validated against the cluster.
+func ImportClusterState(ctx context.Context, manager ClusterManager, registry ServiceRegistry,
+	snapshot *ClusterSnapshot, opts ImportOptions) (*ImportReport, error) {
+	if manager == nil || registry == nil {
+		return nil, fmt.Errorf("import requires a cluster manager and a service registry")
+	}
+	if err := snapshot.Validate(); err != nil {
+		return nil, err
+	}
+
+	cm, ok := manager.(*clusterManager)
+	if !ok {
+		return nil, fmt.Errorf("import requires the built-in cluster manager")
+	}
+	sr, ok := registry.(*serviceRegistry)
+	if !ok {
+		return nil, fmt.Errorf("import requires the built-in service registry")
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun}
+	state := snapshot.State
+
+	if state.ClusterName != cm.config.ClusterName {
+		if !opts.Force {
+			return nil, fmt.Errorf("snapshot is for cluster %q, this is %q", state.ClusterName, cm.config.ClusterName)
+		}
+		report.Warnings = append(report.Warnings,
+			fmt.Sprintf("importing cluster %q into %q", state.ClusterName, cm.config.ClusterName))
+	}
+
+	if existing := len(sr.GetAllServices()); existing > 0 {
+		if !opts.Force {
+			return nil, fmt.Errorf("service registry is not empty (%d services); import needs a fresh cluster", existing)
+		}
+		report.Warnings = append(report.Warnings,
+			fmt.Sprintf("merging into a registry with %d services", existing))
+	}
+
+	for _, member := range state.Members {
+		if _, exists := cm.GetNode(member.ID); exists {
+			report.MembersKnown++
+		} else {
+			report.MembersAdded++
+		}
+	}
+	for _, instances := range state.Services {
+		report.InstancesRestored += len(instances)
+	}
+	report.ReadOnlyRestored = state.ReadOnly.Version > 0 && state.ReadOnly.newerThan(cm.ReadOnly())
+
+	if opts.DryRun {
+		return report, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, member := range state.Members {
+		if _, exists := cm.GetNode(member.ID); exists {
+			continue
+		}
+		cm.addNode(NewRemoteNode(&NodeInfo{
+			ID:          member.ID,
+			Address:     member.Address,
+			Port:        member.Port,
+			State:       NodeStateUnknown,
+			Metadata:    member.Metadata,
+			Version:     member.Version,
+			StateChange: now,
+		}))
+	}
+
+	for serviceID, instances := range state.Services {
+		for _, instance := range instances {
+			instance.Health = ServiceHealthUnknown
+			instance.LastSeen = now
+			sr.upsertInstance(instance)
+			sr.notifyWatchers(serviceID, ServiceEvent{
+				Type:      ServiceEventRegistered,
+				ServiceID: serviceID,
+				Instance:  instance,
+				Timestamp: now,
+			})
+		}
+	}
+
+	if report.ReadOnlyRestored {
+		cm.applyReadOnly(state.ReadOnly)
+	}
+
+	cm.publishEvent(ClusterEvent{
+		Type:      EventStateImported,
+		NodeID:    snapshot.ExportedBy,
+		Timestamp: now,
+		Payload:   StateImported{ExportedAt: snapshot.ExportedAt, Report: *report},
+		Data: map[string]interface{}{
+			"exported_at":        snapshot.ExportedAt,
+			"members_added":      report.MembersAdded,
+			"instances_restored": report.InstancesRestored,
+		},
+	})
+
+	return report, nil
+}
+
+// stateChecksum returns the hex SHA-256 of the JSON encoding of state
+func stateChecksum(state ClusterState) (string, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize cluster state: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,115 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// TestClusterStateExportImport tests a snapshot round trip into a fresh cluster
+func TestClusterStateExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	config := DefaultClusterConfig()
+	config.NodeID = "node-a"
+	source := NewClusterManager(config).(*clusterManager)
+	source.addNode(source.localNode)
+	source.addNode(NewRemoteNode(&NodeInfo{ID: "node-b", Address: "10.0.0.2", Port: 7946, State: NodeStateActive}))
+
+	registry := NewServiceRegistry(source).(*serviceRegistry)
+	if err := registry.RegisterServiceWithLabels(ctx, "game", nil, map[string]string{"zone": "eu"}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	registry.upsertInstance(ServiceInstance{ServiceID: "game", NodeID: "node-b", Health: ServiceHealthHealthy})
+	if err := source.SetReadOnly(ctx, true, "alice", "dr drill"); err != nil {
+		t.Fatalf("Failed to enable read-only mode: %v", err)
+	}
+
+	snapshot, err := ExportClusterState(source, registry)
+	if err != nil {
+		t.Fatalf("Failed to export state: %v", err)
+	}
+	if len(snapshot.State.Members) != 2 || len(snapshot.State.Services["game"]) != 2 {
+		t.Fatalf("Unexpected snapshot state: %+v", snapshot.State)
+	}
+
+	path := filepath.Join(t.TempDir(), "cluster.json")
+	if err := SaveClusterSnapshot(path, snapshot); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+	loaded, err := LoadClusterSnapshot(path)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+
+	target := NewClusterManager(DefaultClusterConfig()).(*clusterManager)
+	targetRegistry := NewServiceRegistry(target)
+
+	report, err := ImportClusterState(ctx, target, targetRegistry, loaded, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if report.MembersAdded != 2 || report.InstancesRestored != 2 || !report.ReadOnlyRestored {
+		t.Errorf("Unexpected dry run report: %+v", report)
+	}
+	if len(targetRegistry.GetAllServices()) != 0 || len(target.GetAllNodes()) != 0 {
+		t.Fatal("Dry run modified the cluster")
+	}
+
+	if _, err := ImportClusterState(ctx, target, targetRegistry, loaded, ImportOptions{}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	instances, _ := targetRegistry.DiscoverService(ctx, "game")
+	if len(instances) != 2 || instances[0].Health != ServiceHealthUnknown {
+		t.Errorf("Unexpected restored instances: %+v", instances)
+	}
+	if node, ok := target.GetNode("node-b"); !ok || node.Info().State != NodeStateUnknown {
+		t.Errorf("Expected node-b to be restored as unknown")
+	}
+	if state := target.ReadOnly(); !state.Enabled || state.ToggledBy != "alice" {
+		t.Errorf("Unexpected restored read-only state: %+v", state)
+	}
+
+	// A second import needs Force because the registry is no longer empty
+	if _, err := ImportClusterState(ctx, target, targetRegistry, loaded, ImportOptions{}); err == nil {
+		t.Error("Expected import into a populated registry to fail")
+	}
+}
+
+// TestClusterSnapshotValidation tests version and checksum checks
+func TestClusterSnapshotValidation(t *testing.T) {
+	manager := NewClusterManager(DefaultClusterConfig())
+	snapshot, err := ExportClusterState(manager, NewServiceRegistry(manager))
+	if err != nil {
+		t.Fatalf("Failed to export state: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := snapshot.WriteTo(&buf); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	if _, err := ReadClusterSnapshot(&buf); err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+
+	future := *snapshot
+	future.FormatVersion = SnapshotFormatVersion + 1
+	if err := future.Validate(); !errors.Is(err, ErrSnapshotVersion) {
+		t.Errorf("Expected ErrSnapshotVersion, got %v", err)
+	}
+
+	tampered := *snapshot
+	tampered.State.ClusterName = "other"
+	if err := tampered.Validate(); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("Expected ErrSnapshotCorrupt, got %v", err)
+	}
+
+	orphan := *snapshot
+	orphan.State.Services = map[string][]ServiceInstance{"game": {{ServiceID: "game", NodeID: "ghost"}}}
+	orphan.Checksum, _ = stateChecksum(orphan.State)
+	if err := orphan.Validate(); err == nil {
+		t.Error("Expected instance on unknown node to be rejected")
+	}
+}
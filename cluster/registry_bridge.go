@@ -0,0 +1,365 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/najoast/sngo/core"
+)
+
+// ExternalNodePrefix marks the node IDs of references to services imported
+// from an external registry; they are not cluster members
+const ExternalNodePrefix = "external/"
+
+// ExternalService is a service instance as kept by an external registry
+type ExternalService struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Address  string            `json:"address"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ExternalRegistry is a service registry outside the cluster, such as the
+// Consul catalog, etcd keys or Kubernetes Endpoints
+type ExternalRegistry interface {
+	// Register adds or replaces a service instance
+	Register(ctx context.Context, service ExternalService) error
+
+	// Deregister removes a service instance
+	Deregister(ctx context.Context, service ExternalService) error
+
+	// Instances returns the instances of a service
+	Instances(ctx context.Context, name string) ([]ExternalService, error)
+}
+
+// ExpiringRegistry is an ExternalRegistry whose registrations expire
+// unless renewed, so the services of a crashed node do not stay
+// registered. The bridge renews its exported instances on every sync.
+type ExpiringRegistry interface {
+	ExternalRegistry
+
+	// KeepAlive renews the registration of a service instance
+	KeepAlive(ctx context.Context, service ExternalService) error
+
+	// TTL returns how long a registration outlives its last renewal
+	TTL() time.Duration
+}
+
+// RegistryBridgeConfig configures a RegistryBridge
+type RegistryBridgeConfig struct {
+	// Export mirrors the services registered on the local node
+	Export bool `yaml:"export" json:"export"`
+
+	// Import lists the external services resolvable through the bridge
+	Import []string `yaml:"import" json:"import"`
+
+	// SyncInterval is how often both directions are reconciled; it is
+	// capped at a third of the TTL of an ExpiringRegistry
+	SyncInterval time.Duration `yaml:"sync_interval" json:"sync_interval"`
+}
+
+// DefaultRegistryBridgeConfig returns a bridge exporting local services
+func DefaultRegistryBridgeConfig() RegistryBridgeConfig {
+	return RegistryBridgeConfig{
+		Export:       true,
+		SyncInterval: 10 * time.Second,
+	}
+}
+
+// RegistryBridge dual-writes the services of the local node into an
+// external registry, so infrastructure outside the cluster can discover
+// them, and imports external services as RemoteActorRefs. The cluster
+// registry stays the source of truth: exported instances are reconciled
+// on every sync, and removed again when the bridge stops.
+type RegistryBridge struct {
+	manager  ClusterManager
+	registry ServiceRegistry
+	external ExternalRegistry
+	config   RegistryBridgeConfig
+
+	mu       sync.RWMutex
+	exported map[string]ExternalService
+	imported map[string][]RemoteActorRef
+
+	// Serializes sync passes
+	syncMu sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRegistryBridge creates a bridge between a cluster registry and an
+// external registry
+func NewRegistryBridge(manager ClusterManager, registry ServiceRegistry, external ExternalRegistry, config RegistryBridgeConfig) *RegistryBridge {
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = DefaultRegistryBridgeConfig().SyncInterval
+	}
+	// Renew often enough that one lost renewal does not expire instances
+	if expiring, ok := external.(ExpiringRegistry); ok && config.SyncInterval > expiring.TTL()/3 {
+		config.SyncInterval = expiring.TTL() / 3
+	}
+
+	return &RegistryBridge{
+		manager:  manager,
+		registry: registry,
+		external: external,
+		config:   config,
+		exported: make(map[string]ExternalService),
+		imported: make(map[string][]RemoteActorRef),
+	}
+}
+
+// Start syncs once and then keeps both registries in sync in the background
+func (b *RegistryBridge) Start(ctx context.Context) error {
+	b.mu.Lock()
+	if b.cancel != nil {
+		b.mu.Unlock()
+		return fmt.Errorf("registry bridge already started")
+	}
+	ctx, b.cancel = context.WithCancel(ctx)
+	b.mu.Unlock()
+
+	if err := b.Sync(ctx); err != nil {
+		core.ReportError("cluster", "registry_bridge", core.SeverityWarning, fmt.Errorf("sync failed: %w", err))
+	}
+
+	b.wg.Add(1)
+	go b.syncLoop(ctx)
+	return nil
+}
+
+// Stop stops syncing and withdraws the exported instances
+func (b *RegistryBridge) Stop(ctx context.Context) error {
+	b.mu.Lock()
+	cancel := b.cancel
+	b.cancel = nil
+	b.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	b.wg.Wait()
+
+	b.syncMu.Lock()
+	defer b.syncMu.Unlock()
+
+	var errs []string
+	for id, service := range b.exportedServices() {
+		if err := b.external.Deregister(ctx, service); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		b.mu.Lock()
+		delete(b.exported, id)
+		b.mu.Unlock()
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to withdraw exported services: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Sync reconciles both directions once
+func (b *RegistryBridge) Sync(ctx context.Context) error {
+	b.syncMu.Lock()
+	defer b.syncMu.Unlock()
+
+	var errs []string
+	if b.config.Export {
+		if err := b.export(ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, name := range b.config.Import {
+		if err := b.importService(ctx, name); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("registry bridge: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Resolve returns the references to the instances of an imported service
+func (b *RegistryBridge) Resolve(name string) []RemoteActorRef {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	refs := b.imported[name]
+	result := make([]RemoteActorRef, len(refs))
+	copy(result, refs)
+	return result
+}
+
+// Exported returns the IDs of the instances mirrored into the external registry
+func (b *RegistryBridge) Exported() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	ids := make([]string, 0, len(b.exported))
+	for id := range b.exported {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// IsExternalRef reports whether a reference points outside the cluster
+func IsExternalRef(ref RemoteActorRef) bool {
+	return strings.HasPrefix(string(ref.NodeID), ExternalNodePrefix)
+}
+
+// syncLoop syncs on every interval until the bridge stops
+func (b *RegistryBridge) syncLoop(ctx context.Context) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Sync(ctx); err != nil {
+				core.ReportError("cluster", "registry_bridge", core.SeverityWarning, fmt.Errorf("sync failed: %w", err))
+			}
+		}
+	}
+}
+
+// export registers the local instances missing from the external registry,
+// renews the ones already there and deregisters the ones no longer in the
+// cluster registry
+func (b *RegistryBridge) export(ctx context.Context) error {
+	localID := b.manager.LocalNode().ID()
+
+	wanted := make(map[string]ExternalService)
+	for _, instances := range b.registry.GetAllServices() {
+		for _, instance := range instances {
+			if instance.NodeID != localID {
+				continue
+			}
+			service := externalService(instance)
+			wanted[service.ID] = service
+		}
+	}
+
+	var errs []string
+	current := b.exportedServices()
+	expiring, _ := b.external.(ExpiringRegistry)
+	for id, service := range wanted {
+		if existing, ok := current[id]; ok && sameExternalService(existing, service) {
+			if expiring != nil {
+				if err := expiring.KeepAlive(ctx, service); err != nil {
+					errs = append(errs, fmt.Sprintf("renew %s: %v", id, err))
+				}
+			}
+			continue
+		}
+		if err := b.external.Register(ctx, service); err != nil {
+			errs = append(errs, fmt.Sprintf("export %s: %v", id, err))
+			continue
+		}
+		b.mu.Lock()
+		b.exported[id] = service
+		b.mu.Unlock()
+	}
+	for id, service := range current {
+		if _, ok := wanted[id]; ok {
+			continue
+		}
+		if err := b.external.Deregister(ctx, service); err != nil {
+			errs = append(errs, fmt.Sprintf("withdraw %s: %v", id, err))
+			continue
+		}
+		b.mu.Lock()
+		delete(b.exported, id)
+		b.mu.Unlock()
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// importService refreshes the references to an external service; instances
+// exported by this bridge are skipped so local services are not imported
+// back as external ones
+func (b *RegistryBridge) importService(ctx context.Context, name string) error {
+	instances, err := b.external.Instances(ctx, name)
+	if err != nil {
+		return fmt.Errorf("import %s: %w", name, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	refs := make([]RemoteActorRef, 0, len(instances))
+	for _, instance := range instances {
+		if _, ours := b.exported[instance.ID]; ours {
+			continue
+		}
+		refs = append(refs, RemoteActorRef{
+			NodeID:  NodeID(ExternalNodePrefix + instance.ID),
+			ActorID: name,
+			Address: instance.Address,
+			Service: name,
+		})
+	}
+	b.imported[name] = refs
+	return nil
+}
+
+// exportedServices returns a copy of the exported instances
+func (b *RegistryBridge) exportedServices() map[string]ExternalService {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := make(map[string]ExternalService, len(b.exported))
+	for id, service := range b.exported {
+		result[id] = service
+	}
+	return result
+}
+
+// externalService converts a cluster instance; its ID is unique per node
+// and service
+func externalService(instance ServiceInstance) ExternalService {
+	metadata := make(map[string]string, len(instance.Metadata)+len(instance.Labels)+1)
+	for k, v := range instance.Labels {
+		metadata[k] = v
+	}
+	for k, v := range instance.Metadata {
+		metadata[k] = v
+	}
+	metadata["sngo_node"] = string(instance.NodeID)
+
+	return ExternalService{
+		ID:       fmt.Sprintf("%s-%s", instance.ServiceID, instance.NodeID),
+		Name:     instance.ServiceID,
+		Address:  instance.Address,
+		Metadata: metadata,
+	}
+}
+
+// sameExternalService reports whether re-registering would change nothing
+func sameExternalService(a, b ExternalService) bool {
+	if a.Name != b.Name || a.Address != b.Address || len(a.Metadata) != len(b.Metadata) {
+		return false
+	}
+	for k, v := range a.Metadata {
+		if b.Metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,266 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRegistry is an ExternalRegistry kept in memory
+type memoryRegistry struct {
+	mu       sync.Mutex
+	services map[string]ExternalService
+}
+
+func newMemoryRegistry() *memoryRegistry {
+	return &memoryRegistry{services: make(map[string]ExternalService)}
+}
+
+func (r *memoryRegistry) Register(ctx context.Context, service ExternalService) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.services[service.ID] = service
+	return nil
+}
+
+func (r *memoryRegistry) Deregister(ctx context.Context, service ExternalService) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.services, service.ID)
+	return nil
+}
+
+func (r *memoryRegistry) Instances(ctx context.Context, name string) ([]ExternalService, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []ExternalService
+	for _, service := range r.services {
+		if service.Name == name {
+			result = append(result, service)
+		}
+	}
+	return result, nil
+}
+
+func TestRegistryBridgeDualWrite(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultClusterConfig()
+	config.NodeID = "game-1"
+	manager := NewClusterManager(config)
+	registry := NewServiceRegistry(manager)
+	external := newMemoryRegistry()
+
+	registry.RegisterService(ctx, "login", map[string]string{"zone": "eu"})
+	external.Register(ctx, ExternalService{ID: "pay-1", Name: "payments", Address: "10.0.0.9:443"})
+
+	bridgeConfig := DefaultRegistryBridgeConfig()
+	bridgeConfig.Import = []string{"payments", "login"}
+	bridge := NewRegistryBridge(manager, registry, external, bridgeConfig)
+	if err := bridge.Start(ctx); err != nil {
+		t.Fatalf("Failed to start bridge: %v", err)
+	}
+
+	mirrored, ok := external.services["login-game-1"]
+	if !ok {
+		t.Fatalf("Expected login to be exported, got %v", external.services)
+	}
+	if mirrored.Metadata["zone"] != "eu" || mirrored.Metadata["sngo_node"] != "game-1" {
+		t.Errorf("Unexpected exported metadata: %v", mirrored.Metadata)
+	}
+
+	refs := bridge.Resolve("payments")
+	if len(refs) != 1 || refs[0].Address != "10.0.0.9:443" || !IsExternalRef(refs[0]) {
+		t.Errorf("Unexpected imported refs: %+v", refs)
+	}
+	// Exported instances are not imported back
+	if refs := bridge.Resolve("login"); len(refs) != 0 {
+		t.Errorf("Expected own instances to be skipped, got %+v", refs)
+	}
+
+	registry.UnregisterService(ctx, "login")
+	if err := bridge.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if _, ok := external.services["login-game-1"]; ok {
+		t.Error("Expected unregistered service to be withdrawn")
+	}
+
+	registry.RegisterService(ctx, "chat", nil)
+	bridge.Sync(ctx)
+	if err := bridge.Stop(ctx); err != nil {
+		t.Fatalf("Failed to stop bridge: %v", err)
+	}
+	if _, ok := external.services["chat-game-1"]; ok || len(bridge.Exported()) != 0 {
+		t.Error("Expected exported services to be withdrawn on stop")
+	}
+}
+
+func TestConsulRegistry(t *testing.T) {
+	var registered consulRegistration
+	var passed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/v1/agent/service/register":
+			json.NewDecoder(r.Body).Decode(&registered)
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/v1/agent/check/pass/"):
+			passed = append(passed, strings.TrimPrefix(r.URL.Path, "/v1/agent/check/pass/"))
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+			json.NewEncoder(w).Encode([]map[string]interface{}{{"Service": map[string]interface{}{
+				"ID": registered.ID, "Service": registered.Name, "Address": registered.Address, "Port": registered.Port,
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	registry := NewConsulRegistry(server.URL, "secret", 0, server.Client())
+	ctx := context.Background()
+	service := ExternalService{ID: "login-1", Name: "login", Address: "10.0.0.1:7000"}
+	if err := registry.Register(ctx, service); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if registered.Address != "10.0.0.1" || registered.Port != 7000 {
+		t.Errorf("Unexpected registration: %+v", registered)
+	}
+	// A crashed node stops passing the check and Consul drops its services
+	if registered.Check.TTL != "30s" || registered.Check.DeregisterCriticalServiceAfter == "" {
+		t.Errorf("Expected a TTL check, got %+v", registered.Check)
+	}
+	if err := registry.(ExpiringRegistry).KeepAlive(ctx, service); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	if len(passed) != 1 || passed[0] != registered.Check.CheckID {
+		t.Errorf("Expected the check %q passed, got %v", registered.Check.CheckID, passed)
+	}
+
+	instances, err := registry.Instances(ctx, "login")
+	if err != nil {
+		t.Fatalf("Instances failed: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Address != "10.0.0.1:7000" || instances[0].ID != "login-1" {
+		t.Errorf("Unexpected instances: %+v", instances)
+	}
+
+	if err := NewConsulRegistry(server.URL, "", 0, server.Client()).Deregister(ctx, instances[0]); err == nil {
+		t.Error("Expected request without token to fail")
+	}
+}
+
+func TestEtcdRegistryLease(t *testing.T) {
+	var mu sync.Mutex
+	leases := map[string]bool{}
+	keys := map[string]string{}
+	nextLease := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			nextLease++
+			id := strconv.Itoa(nextLease)
+			leases[id] = true
+			json.NewEncoder(w).Encode(etcdLease{ID: id, TTL: req["TTL"]})
+		case "/v3/lease/keepalive":
+			result := etcdLease{ID: req["ID"]}
+			if leases[req["ID"]] {
+				result.TTL = "30"
+			}
+			json.NewEncoder(w).Encode(map[string]etcdLease{"result": result})
+		case "/v3/lease/revoke":
+			delete(leases, req["ID"])
+			for key, lease := range keys {
+				if lease == req["ID"] {
+					delete(keys, key)
+				}
+			}
+			w.Write([]byte("{}"))
+		case "/v3/kv/put":
+			keys[req["key"]] = req["lease"]
+			w.Write([]byte("{}"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	registry := NewEtcdRegistry(server.URL, "/sngo", 0, server.Client()).(ExpiringRegistry)
+	ctx := context.Background()
+	service := ExternalService{ID: "login-1", Name: "login", Address: "10.0.0.1:7000"}
+	if err := registry.Register(ctx, service); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	key := etcdBytes("/sngo/login/login-1")
+	if lease := keys[key]; lease == "" || !leases[lease] {
+		t.Fatalf("Expected the key attached to a live lease, got %v", keys)
+	}
+
+	if err := registry.KeepAlive(ctx, service); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	if nextLease != 1 {
+		t.Errorf("Expected the lease renewed, got %d grants", nextLease)
+	}
+
+	// An expired lease took the key with it; renewing registers again
+	mu.Lock()
+	for id := range leases {
+		delete(leases, id)
+	}
+	keys = map[string]string{}
+	mu.Unlock()
+	if err := registry.KeepAlive(ctx, service); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	if lease := keys[key]; lease == "" || !leases[lease] {
+		t.Errorf("Expected the key registered again, got %v", keys)
+	}
+}
+
+// expiringMemoryRegistry counts the renewals of a memoryRegistry
+type expiringMemoryRegistry struct {
+	*memoryRegistry
+	renewed int
+}
+
+func (r *expiringMemoryRegistry) KeepAlive(ctx context.Context, service ExternalService) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.renewed++
+	return nil
+}
+
+func (r *expiringMemoryRegistry) TTL() time.Duration { return 3 * time.Second }
+
+func TestRegistryBridgeRenewsExpiringRegistrations(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultClusterConfig()
+	config.NodeID = "game-1"
+	manager := NewClusterManager(config)
+	registry := NewServiceRegistry(manager)
+	external := &expiringMemoryRegistry{memoryRegistry: newMemoryRegistry()}
+	registry.RegisterService(ctx, "login", nil)
+
+	bridge := NewRegistryBridge(manager, registry, external, DefaultRegistryBridgeConfig())
+	if bridge.config.SyncInterval != time.Second {
+		t.Errorf("Expected the sync interval capped at a third of the TTL, got %v", bridge.config.SyncInterval)
+	}
+
+	bridge.Sync(ctx)
+	bridge.Sync(ctx)
+	if external.renewed != 1 {
+		t.Errorf("Expected the exported instance renewed once, got %d", external.renewed)
+	}
+}
@@ -0,0 +1,566 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultExternalServiceTTL is how long Consul and etcd keep a
+// registration that its node stopped renewing
+const DefaultExternalServiceTTL = 30 * time.Second
+
+// httpRegistry holds what the HTTP based external registries share
+type httpRegistry struct {
+	endpoint string
+	client   *http.Client
+	header   http.Header
+}
+
+// newHTTPRegistry defaults client to http.DefaultClient
+func newHTTPRegistry(endpoint string, client *http.Client) httpRegistry {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return httpRegistry{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   client,
+		header:   make(http.Header),
+	}
+}
+
+// do sends a JSON request and decodes the JSON response into out; it
+// returns the status code so callers can handle expected failures
+func (r httpRegistry) do(ctx context.Context, method, path string, in, out interface{}) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.endpoint+path, body)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// splitHostPort splits an instance address, tolerating a missing port
+func splitHostPort(address string) (string, int) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, 0
+	}
+	port, _ := strconv.Atoi(portStr)
+	return host, port
+}
+
+// consulRegistry mirrors services into the Consul catalog through the
+// agent HTTP API. Every service carries a TTL check that the bridge
+// passes on each sync; Consul deregisters the service once the check
+// stays critical, no sooner than a minute.
+type consulRegistry struct {
+	httpRegistry
+	ttl time.Duration
+}
+
+// NewConsulRegistry creates an ExternalRegistry for the Consul agent at
+// address, e.g. "http://127.0.0.1:8500"; token is the ACL token, if any,
+// and ttl the time a registration outlives its last renewal, 0 for
+// DefaultExternalServiceTTL
+func NewConsulRegistry(address, token string, ttl time.Duration, client *http.Client) ExternalRegistry {
+	if ttl <= 0 {
+		ttl = DefaultExternalServiceTTL
+	}
+	r := &consulRegistry{httpRegistry: newHTTPRegistry(address, client), ttl: ttl}
+	if token != "" {
+		r.header.Set("X-Consul-Token", token)
+	}
+	return r
+}
+
+// consulRegistration is the body of an agent service registration
+type consulRegistration struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   consulCheck       `json:"Check"`
+}
+
+// consulCheck is the TTL check registered along with a service
+type consulCheck struct {
+	CheckID                        string `json:"CheckID"`
+	TTL                            string `json:"TTL"`
+	Status                         string `json:"Status"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+// consulCatalogService is a service as returned by the health endpoint
+type consulCatalogService struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta"`
+}
+
+// consulCheckID returns the ID of the TTL check of a service
+func consulCheckID(service ExternalService) string {
+	return "service:" + service.ID
+}
+
+// Register registers the service with a passing TTL check
+func (r *consulRegistry) Register(ctx context.Context, service ExternalService) error {
+	host, port := splitHostPort(service.Address)
+	_, err := r.do(ctx, http.MethodPut, "/v1/agent/service/register", consulRegistration{
+		ID:      service.ID,
+		Name:    service.Name,
+		Address: host,
+		Port:    port,
+		Meta:    service.Metadata,
+		Check: consulCheck{
+			CheckID:                        consulCheckID(service),
+			TTL:                            r.ttl.String(),
+			Status:                         "passing",
+			DeregisterCriticalServiceAfter: r.ttl.String(),
+		},
+	}, nil)
+	return err
+}
+
+// KeepAlive passes the TTL check of the service, registering it again if
+// Consul no longer knows the check
+func (r *consulRegistry) KeepAlive(ctx context.Context, service ExternalService) error {
+	status, err := r.do(ctx, http.MethodPut, "/v1/agent/check/pass/"+url.PathEscape(consulCheckID(service)), nil, nil)
+	if err != nil && status != 0 {
+		return r.Register(ctx, service)
+	}
+	return err
+}
+
+// TTL returns how long a registration outlives its last renewal
+func (r *consulRegistry) TTL() time.Duration {
+	return r.ttl
+}
+
+// Deregister removes the service and its check
+func (r *consulRegistry) Deregister(ctx context.Context, service ExternalService) error {
+	_, err := r.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(service.ID), nil, nil)
+	return err
+}
+
+// Instances returns the instances of the service passing their checks
+func (r *consulRegistry) Instances(ctx context.Context, name string) ([]ExternalService, error) {
+	var entries []struct {
+		Service consulCatalogService `json:"Service"`
+	}
+	if _, err := r.do(ctx, http.MethodGet, "/v1/health/service/"+url.PathEscape(name)+"?passing=true", nil, &entries); err != nil {
+		return nil, err
+	}
+
+	services := make([]ExternalService, 0, len(entries))
+	for _, entry := range entries {
+		address := entry.Service.Address
+		if entry.Service.Port != 0 {
+			address = net.JoinHostPort(address, strconv.Itoa(entry.Service.Port))
+		}
+		services = append(services, ExternalService{
+			ID:       entry.Service.ID,
+			Name:     entry.Service.Service,
+			Address:  address,
+			Metadata: entry.Service.Meta,
+		})
+	}
+	return services, nil
+}
+
+// etcdRegistry stores services as JSON values under
+// <prefix>/<name>/<id> through the etcd v3 JSON gateway. Each key is
+// attached to a lease of its own that the bridge keeps alive on each
+// sync, so the keys of a crashed node expire with their lease.
+type etcdRegistry struct {
+	httpRegistry
+	prefix string
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	leases map[string]string
+}
+
+// NewEtcdRegistry creates an ExternalRegistry storing services under
+// prefix in the etcd cluster at endpoint, e.g. "http://127.0.0.1:2379";
+// ttl is the time a registration outlives its last renewal, 0 for
+// DefaultExternalServiceTTL
+func NewEtcdRegistry(endpoint, prefix string, ttl time.Duration, client *http.Client) ExternalRegistry {
+	if ttl <= 0 {
+		ttl = DefaultExternalServiceTTL
+	}
+	return &etcdRegistry{
+		httpRegistry: newHTTPRegistry(endpoint, client),
+		prefix:       strings.TrimSuffix(prefix, "/"),
+		ttl:          ttl,
+		leases:       make(map[string]string),
+	}
+}
+
+// etcdLease is a lease as returned by the JSON gateway, which encodes
+// 64-bit integers as strings
+type etcdLease struct {
+	ID  string `json:"ID"`
+	TTL string `json:"TTL"`
+}
+
+// key returns the etcd key of a service instance
+func (r *etcdRegistry) key(service ExternalService) string {
+	return fmt.Sprintf("%s/%s/%s", r.prefix, service.Name, service.ID)
+}
+
+// etcdBytes encodes a string as the gateway expects bytes fields
+func etcdBytes(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// Register grants a lease and puts the service under it
+func (r *etcdRegistry) Register(ctx context.Context, service ExternalService) error {
+	value, err := json.Marshal(service)
+	if err != nil {
+		return err
+	}
+
+	var lease etcdLease
+	if _, err := r.do(ctx, http.MethodPost, "/v3/lease/grant", map[string]string{
+		"TTL": strconv.FormatInt(max(1, int64(r.ttl/time.Second)), 10),
+	}, &lease); err != nil {
+		return err
+	}
+	if lease.ID == "" {
+		return fmt.Errorf("etcd granted no lease")
+	}
+
+	if _, err := r.do(ctx, http.MethodPost, "/v3/kv/put", map[string]string{
+		"key":   etcdBytes(r.key(service)),
+		"value": base64.StdEncoding.EncodeToString(value),
+		"lease": lease.ID,
+	}, nil); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	previous := r.leases[service.ID]
+	r.leases[service.ID] = lease.ID
+	r.mu.Unlock()
+
+	// The key moved to the new lease; the previous one only holds time
+	if previous != "" {
+		r.revoke(ctx, previous)
+	}
+	return nil
+}
+
+// KeepAlive renews the lease of the service, registering it again if the
+// lease already expired
+func (r *etcdRegistry) KeepAlive(ctx context.Context, service ExternalService) error {
+	r.mu.Lock()
+	id := r.leases[service.ID]
+	r.mu.Unlock()
+	if id == "" {
+		return r.Register(ctx, service)
+	}
+
+	var resp struct {
+		Result etcdLease `json:"result"`
+	}
+	if _, err := r.do(ctx, http.MethodPost, "/v3/lease/keepalive", map[string]string{"ID": id}, &resp); err != nil {
+		return err
+	}
+	if ttl, _ := strconv.ParseInt(resp.Result.TTL, 10, 64); ttl <= 0 {
+		return r.Register(ctx, service)
+	}
+	return nil
+}
+
+// TTL returns how long a registration outlives its last renewal
+func (r *etcdRegistry) TTL() time.Duration {
+	return r.ttl
+}
+
+// Deregister deletes the service and revokes its lease
+func (r *etcdRegistry) Deregister(ctx context.Context, service ExternalService) error {
+	if _, err := r.do(ctx, http.MethodPost, "/v3/kv/deleterange", map[string]string{
+		"key": etcdBytes(r.key(service)),
+	}, nil); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	id := r.leases[service.ID]
+	delete(r.leases, service.ID)
+	r.mu.Unlock()
+
+	if id != "" {
+		r.revoke(ctx, id)
+	}
+	return nil
+}
+
+// revoke releases a lease that no longer holds keys; a failure only
+// leaves it to expire
+func (r *etcdRegistry) revoke(ctx context.Context, id string) {
+	r.do(ctx, http.MethodPost, "/v3/lease/revoke", map[string]string{"ID": id}, nil)
+}
+
+// Instances returns the instances stored under the prefix of the service
+func (r *etcdRegistry) Instances(ctx context.Context, name string) ([]ExternalService, error) {
+	// The range end of a prefix is the prefix with its last byte incremented
+	prefix := fmt.Sprintf("%s/%s/", r.prefix, name)
+	end := []byte(prefix)
+	end[len(end)-1]++
+
+	var resp struct {
+		KVs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if _, err := r.do(ctx, http.MethodPost, "/v3/kv/range", map[string]string{
+		"key":       etcdBytes(prefix),
+		"range_end": base64.StdEncoding.EncodeToString(end),
+	}, &resp); err != nil {
+		return nil, err
+	}
+
+	services := make([]ExternalService, 0, len(resp.KVs))
+	for _, kv := range resp.KVs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid etcd value: %w", err)
+		}
+		var service ExternalService
+		if err := json.Unmarshal(value, &service); err != nil {
+			return nil, fmt.Errorf("invalid service entry: %w", err)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// kubernetesRegistry publishes each service as an Endpoints object named
+// after it, one address per instance
+type kubernetesRegistry struct {
+	httpRegistry
+	namespace string
+}
+
+// NewKubernetesRegistry creates an ExternalRegistry writing Endpoints in
+// namespace through the API server at apiServer, authenticating with a
+// bearer token such as the pod's service account token. Service names
+// must be valid Kubernetes object names.
+func NewKubernetesRegistry(apiServer, namespace, token string, client *http.Client) ExternalRegistry {
+	r := &kubernetesRegistry{httpRegistry: newHTTPRegistry(apiServer, client), namespace: namespace}
+	if token != "" {
+		r.header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+// k8sConflictRetries bounds the retries of an Endpoints update racing
+// another writer
+const k8sConflictRetries = 5
+
+// k8sEndpoints is the subset of an Endpoints object the registry uses
+type k8sEndpoints struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   k8sObjectMeta     `json:"metadata"`
+	Subsets    []k8sEndpointsSet `json:"subsets"`
+}
+
+// k8sObjectMeta is the metadata of a Kubernetes object
+type k8sObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+// k8sEndpointsSet groups the addresses sharing the same ports
+type k8sEndpointsSet struct {
+	Addresses []k8sEndpointAddress `json:"addresses"`
+	Ports     []k8sEndpointPort    `json:"ports"`
+}
+
+// k8sEndpointAddress is the address of one instance
+type k8sEndpointAddress struct {
+	IP        string        `json:"ip"`
+	Hostname  string        `json:"hostname,omitempty"`
+	TargetRef *k8sObjectRef `json:"targetRef,omitempty"`
+}
+
+// k8sObjectRef names the instance behind an address
+type k8sObjectRef struct {
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// k8sEndpointPort is a port served by the addresses of a subset
+type k8sEndpointPort struct {
+	Name string `json:"name,omitempty"`
+	Port int    `json:"port"`
+}
+
+// path returns the API path of the Endpoints of a service
+func (r *kubernetesRegistry) path(name string) string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/endpoints/%s", url.PathEscape(r.namespace), url.PathEscape(name))
+}
+
+// update applies change to the Endpoints of a service, creating them if
+// needed and retrying when another writer updated them concurrently
+func (r *kubernetesRegistry) update(ctx context.Context, name string, change func(ep *k8sEndpoints)) error {
+	for attempt := 0; ; attempt++ {
+		var ep k8sEndpoints
+		status, err := r.do(ctx, http.MethodGet, r.path(name), nil, &ep)
+		create := status == http.StatusNotFound
+		if err != nil && !create {
+			return err
+		}
+		if create {
+			ep = k8sEndpoints{Metadata: k8sObjectMeta{
+				Name:      name,
+				Namespace: r.namespace,
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": "sngo"},
+			}}
+		}
+		ep.APIVersion, ep.Kind = "v1", "Endpoints"
+		change(&ep)
+
+		if create {
+			status, err = r.do(ctx, http.MethodPost,
+				fmt.Sprintf("/api/v1/namespaces/%s/endpoints", url.PathEscape(r.namespace)), ep, nil)
+		} else {
+			status, err = r.do(ctx, http.MethodPut, r.path(name), ep, nil)
+		}
+		if status == http.StatusConflict && attempt < k8sConflictRetries {
+			continue
+		}
+		return err
+	}
+}
+
+// Register adds the instance to the Endpoints of its service
+func (r *kubernetesRegistry) Register(ctx context.Context, service ExternalService) error {
+	host, port := splitHostPort(service.Address)
+	return r.update(ctx, service.Name, func(ep *k8sEndpoints) {
+		removeK8sAddress(ep, service.ID)
+		address := k8sEndpointAddress{
+			IP:        host,
+			Hostname:  service.ID,
+			TargetRef: &k8sObjectRef{Kind: "SNGOService", Name: service.ID},
+		}
+		for i, subset := range ep.Subsets {
+			if len(subset.Ports) == 1 && subset.Ports[0].Port == port {
+				ep.Subsets[i].Addresses = append(ep.Subsets[i].Addresses, address)
+				return
+			}
+		}
+		ep.Subsets = append(ep.Subsets, k8sEndpointsSet{
+			Addresses: []k8sEndpointAddress{address},
+			Ports:     []k8sEndpointPort{{Port: port}},
+		})
+	})
+}
+
+// Deregister removes the instance from the Endpoints of its service
+func (r *kubernetesRegistry) Deregister(ctx context.Context, service ExternalService) error {
+	return r.update(ctx, service.Name, func(ep *k8sEndpoints) {
+		removeK8sAddress(ep, service.ID)
+	})
+}
+
+// Instances returns the addresses of the Endpoints of the service
+func (r *kubernetesRegistry) Instances(ctx context.Context, name string) ([]ExternalService, error) {
+	var ep k8sEndpoints
+	status, err := r.do(ctx, http.MethodGet, r.path(name), nil, &ep)
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var services []ExternalService
+	for _, subset := range ep.Subsets {
+		port := 0
+		if len(subset.Ports) > 0 {
+			port = subset.Ports[0].Port
+		}
+		for _, address := range subset.Addresses {
+			id := address.Hostname
+			if address.TargetRef != nil && address.TargetRef.Name != "" {
+				id = address.TargetRef.Name
+			}
+			if id == "" {
+				id = address.IP
+			}
+			services = append(services, ExternalService{
+				ID:      id,
+				Name:    name,
+				Address: net.JoinHostPort(address.IP, strconv.Itoa(port)),
+			})
+		}
+	}
+	return services, nil
+}
+
+// removeK8sAddress drops the address of an instance and empty subsets
+func removeK8sAddress(ep *k8sEndpoints, id string) {
+	subsets := ep.Subsets[:0]
+	for _, subset := range ep.Subsets {
+		addresses := subset.Addresses[:0]
+		for _, address := range subset.Addresses {
+			if address.TargetRef == nil || address.TargetRef.Name != id {
+				addresses = append(addresses, address)
+			}
+		}
+		subset.Addresses = addresses
+		if len(addresses) > 0 {
+			subsets = append(subsets, subset)
+		}
+	}
+	ep.Subsets = subsets
+}
@@ -0,0 +1,259 @@
+package cluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultResolveCacheTTL bounds how long a cached resolution is used when
+// no registry event invalidates it first
+const DefaultResolveCacheTTL = 5 * time.Second
+
+// DefaultResolveCacheSize bounds how many services the resolve cache
+// holds when no size is given
+const DefaultResolveCacheSize = 1024
+
+// ResolveCacheEntry reports the cache entry of one service
+type ResolveCacheEntry struct {
+	ServiceID     string    `json:"service_id"`
+	Instances     int       `json:"instances"`
+	Generation    uint64    `json:"generation"`
+	ExpiresAt     time.Time `json:"expires_at,omitempty"`
+	Hits          int64     `json:"hits"`
+	Misses        int64     `json:"misses"`
+	Invalidations int64     `json:"invalidations"`
+}
+
+// ResolveCacheStatistics reports the resolve cache, busiest services first
+type ResolveCacheStatistics struct {
+	Entries []ResolveCacheEntry `json:"entries,omitempty"`
+	Hits    int64               `json:"hits"`
+	Misses  int64               `json:"misses"`
+}
+
+// resolveEntry is the cached discovery result of one service. Its
+// generation is bumped by every registry event for the service, so a
+// lookup that raced an event does not store what it fetched.
+type resolveEntry struct {
+	instances     []ServiceInstance
+	cached        bool
+	generation    uint64
+	expires       time.Time
+	hits          int64
+	misses        int64
+	invalidations int64
+	watching      bool
+	used          time.Time
+	stopWatch     context.CancelFunc
+}
+
+// ResolveCache caches service discovery for Resolve, so hot services are
+// not looked up in the registry on every call. Entries expire after a TTL
+// and are invalidated as soon as the registry reports a change to the
+// service; the TTL bounds staleness when an event is dropped. Past its
+// size, the least recently used service is evicted and no longer watched.
+type ResolveCache struct {
+	registry ServiceRegistry
+	ttl      time.Duration
+	size     int
+
+	mu      sync.Mutex
+	entries map[string]*resolveEntry
+	hits    int64
+	misses  int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewResolveCache creates a cache of lookups in registry holding up to
+// size services; a ttl of 0 uses DefaultResolveCacheTTL and a size of 0
+// DefaultResolveCacheSize
+func NewResolveCache(registry ServiceRegistry, ttl time.Duration, size int) *ResolveCache {
+	if ttl <= 0 {
+		ttl = DefaultResolveCacheTTL
+	}
+	if size <= 0 {
+		size = DefaultResolveCacheSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ResolveCache{
+		registry: registry,
+		ttl:      ttl,
+		size:     size,
+		entries:  make(map[string]*resolveEntry),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Lookup returns the instances of a service, from the cache if fresh
+func (c *ResolveCache) Lookup(ctx context.Context, serviceID string) ([]ServiceInstance, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry := c.entryLocked(serviceID, now)
+	entry.used = now
+	if entry.cached && now.Before(entry.expires) {
+		entry.hits++
+		c.hits++
+		instances := append([]ServiceInstance(nil), entry.instances...)
+		c.mu.Unlock()
+		return instances, nil
+	}
+	entry.misses++
+	c.misses++
+	generation := entry.generation
+	var watchCtx context.Context
+	if !entry.watching {
+		entry.watching = true
+		watchCtx, entry.stopWatch = context.WithCancel(c.ctx)
+	}
+	c.mu.Unlock()
+
+	if watchCtx != nil {
+		c.watch(watchCtx, serviceID, entry)
+	}
+
+	instances, err := c.registry.DiscoverService(ctx, serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if entry.generation == generation {
+		entry.instances = append([]ServiceInstance(nil), instances...)
+		entry.cached = true
+		entry.expires = now.Add(c.ttl)
+	}
+	c.mu.Unlock()
+	return instances, nil
+}
+
+// Prewarm resolves services ahead of their first call, e.g. at startup
+func (c *ResolveCache) Prewarm(ctx context.Context, serviceIDs ...string) error {
+	for _, serviceID := range serviceIDs {
+		if _, err := c.Lookup(ctx, serviceID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Invalidate drops the cached resolution of a service
+func (c *ResolveCache) Invalidate(serviceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[serviceID]
+	if !exists {
+		return
+	}
+	entry.generation++
+	entry.invalidations++
+	entry.instances = nil
+	entry.cached = false
+}
+
+// Statistics returns the hit and miss counters per service
+func (c *ResolveCache) Statistics() ResolveCacheStatistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := ResolveCacheStatistics{Hits: c.hits, Misses: c.misses}
+	for serviceID, entry := range c.entries {
+		report := ResolveCacheEntry{
+			ServiceID:     serviceID,
+			Instances:     len(entry.instances),
+			Generation:    entry.generation,
+			Hits:          entry.hits,
+			Misses:        entry.misses,
+			Invalidations: entry.invalidations,
+		}
+		if entry.cached {
+			report.ExpiresAt = entry.expires
+		}
+		stats.Entries = append(stats.Entries, report)
+	}
+	sort.Slice(stats.Entries, func(i, j int) bool {
+		a, b := stats.Entries[i], stats.Entries[j]
+		if a.Hits+a.Misses != b.Hits+b.Misses {
+			return a.Hits+a.Misses > b.Hits+b.Misses
+		}
+		return a.ServiceID < b.ServiceID
+	})
+	return stats
+}
+
+// Close stops watching the registry
+func (c *ResolveCache) Close() {
+	c.cancel()
+}
+
+// entryLocked returns the entry of a service, creating it and evicting
+// the least recently used entry when the cache is full; callers hold mu
+func (c *ResolveCache) entryLocked(serviceID string, now time.Time) *resolveEntry {
+	entry, exists := c.entries[serviceID]
+	if exists {
+		return entry
+	}
+
+	if len(c.entries) >= c.size {
+		var oldestID string
+		var oldest *resolveEntry
+		for id, candidate := range c.entries {
+			if oldest == nil || candidate.used.Before(oldest.used) {
+				oldestID, oldest = id, candidate
+			}
+		}
+		if oldest.stopWatch != nil {
+			oldest.stopWatch()
+		}
+		delete(c.entries, oldestID)
+	}
+
+	entry = &resolveEntry{used: now}
+	c.entries[serviceID] = entry
+	return entry
+}
+
+// watch invalidates a service on each registry event for it, until ctx
+// is cancelled by the eviction of entry or by Close
+func (c *ResolveCache) watch(ctx context.Context, serviceID string, entry *resolveEntry) {
+	events, err := c.registry.Watch(ctx, serviceID)
+	if err != nil {
+		// Without events the TTL alone bounds staleness; retry next miss
+		c.mu.Lock()
+		entry.watching = false
+		entry.stopWatch()
+		c.mu.Unlock()
+		return
+	}
+
+	go func() {
+		for range events {
+			c.Invalidate(serviceID)
+		}
+	}()
+}
+
+// SetResolveCache sets the cache consulted by Resolve; nil disables it
+func (rs *remoteService) SetResolveCache(cache *ResolveCache) {
+	rs.securityMu.Lock()
+	defer rs.securityMu.Unlock()
+	rs.resolveCache = cache
+}
+
+// discover looks a service up through the resolve cache, if set
+func (rs *remoteService) discover(ctx context.Context, serviceID string) ([]ServiceInstance, error) {
+	rs.securityMu.RLock()
+	cache := rs.resolveCache
+	rs.securityMu.RUnlock()
+
+	if cache != nil {
+		return cache.Lookup(ctx, serviceID)
+	}
+	return rs.registry.DiscoverService(ctx, serviceID)
+}
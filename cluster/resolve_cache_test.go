@@ -0,0 +1,150 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingRegistry counts the lookups reaching a registry
+type countingRegistry struct {
+	*serviceRegistry
+	lookups int
+	before  func()
+}
+
+func (r *countingRegistry) DiscoverService(ctx context.Context, serviceID string) ([]ServiceInstance, error) {
+	r.lookups++
+	if r.before != nil {
+		r.before()
+	}
+	return r.serviceRegistry.DiscoverService(ctx, serviceID)
+}
+
+func newCachedRemoteService(t *testing.T, ttl time.Duration) (*remoteService, *countingRegistry, *ResolveCache) {
+	t.Helper()
+	config := DefaultClusterConfig()
+	config.NodeID = "local"
+	manager := NewClusterManager(config).(*clusterManager)
+
+	rs := NewRemoteService(manager).(*remoteService)
+	registry := &countingRegistry{serviceRegistry: NewServiceRegistry(manager).(*serviceRegistry)}
+	rs.registry = registry
+	cache := NewResolveCache(registry, ttl, 0)
+	t.Cleanup(cache.Close)
+	rs.SetResolveCache(cache)
+	return rs, registry, cache
+}
+
+func TestResolveCacheHitsAndInvalidation(t *testing.T) {
+	rs, registry, cache := newCachedRemoteService(t, time.Minute)
+	registry.upsertInstance(ServiceInstance{ServiceID: "players", NodeID: "node-x"})
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if refs, err := rs.Resolve(ctx, "players"); err != nil || len(refs) != 1 {
+			t.Fatalf("Failed to resolve: %v (%d refs)", err, len(refs))
+		}
+	}
+	if registry.lookups != 1 {
+		t.Errorf("Expected 1 registry lookup, got %d", registry.lookups)
+	}
+
+	// A registration pushes an invalidation through Watch
+	if err := registry.RegisterService(ctx, "players", nil); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	waitFor(t, func() bool { return cache.Statistics().Entries[0].Invalidations == 1 })
+	if refs, _ := rs.Resolve(ctx, "players"); len(refs) != 2 {
+		t.Errorf("Expected the new instance after invalidation, got %d refs", len(refs))
+	}
+
+	stats := cache.Statistics()
+	entry := stats.Entries[0]
+	if entry.ServiceID != "players" || entry.Hits != 2 || entry.Misses != 2 || entry.Generation != 1 {
+		t.Errorf("Unexpected entry statistics %+v", entry)
+	}
+	if stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Unexpected totals %+v", stats)
+	}
+}
+
+func TestResolveCacheTTL(t *testing.T) {
+	rs, registry, _ := newCachedRemoteService(t, 10*time.Millisecond)
+	ctx := context.Background()
+
+	rs.Resolve(ctx, "chat")
+	time.Sleep(20 * time.Millisecond)
+	rs.Resolve(ctx, "chat")
+	if registry.lookups != 2 {
+		t.Errorf("Expected an expired entry to be looked up again, got %d lookups", registry.lookups)
+	}
+}
+
+func TestResolveCacheDropsRacedLookup(t *testing.T) {
+	_, registry, cache := newCachedRemoteService(t, time.Minute)
+	ctx := context.Background()
+
+	// An event lands while the lookup is in flight
+	registry.before = func() { cache.Invalidate("chat") }
+	cache.Lookup(ctx, "chat")
+	registry.before = nil
+
+	cache.Lookup(ctx, "chat")
+	if registry.lookups != 2 {
+		t.Errorf("Expected a lookup racing an invalidation not to be cached, got %d lookups", registry.lookups)
+	}
+}
+
+func TestResolveCachePrewarm(t *testing.T) {
+	rs, registry, _ := newCachedRemoteService(t, time.Minute)
+	ctx := context.Background()
+
+	if err := rs.resolveCache.Prewarm(ctx, "players", "chat"); err != nil {
+		t.Fatalf("Failed to prewarm: %v", err)
+	}
+	rs.Resolve(ctx, "players")
+	rs.Resolve(ctx, "chat")
+	if registry.lookups != 2 {
+		t.Errorf("Expected prewarmed services to be served from the cache, got %d lookups", registry.lookups)
+	}
+}
+
+func TestResolveCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "local"
+	registry := &countingRegistry{serviceRegistry: NewServiceRegistry(NewClusterManager(config)).(*serviceRegistry)}
+	cache := NewResolveCache(registry, time.Minute, 2)
+	defer cache.Close()
+	ctx := context.Background()
+
+	cache.Lookup(ctx, "players")
+	cache.Lookup(ctx, "chat")
+	cache.Lookup(ctx, "players")
+	cache.Lookup(ctx, "guilds")
+
+	if stats := cache.Statistics(); len(stats.Entries) != 2 {
+		t.Fatalf("Expected the cache capped at 2 services, got %+v", stats.Entries)
+	}
+	cache.Lookup(ctx, "players")
+	if registry.lookups != 3 {
+		t.Errorf("Expected the recently used service kept, got %d lookups", registry.lookups)
+	}
+
+	// The evicted service is no longer watched
+	watching := func(serviceID string) int {
+		registry.watchersMu.RLock()
+		defer registry.watchersMu.RUnlock()
+		return len(registry.watchers[serviceID])
+	}
+	deadline := time.Now().Add(time.Second)
+	for watching("chat") != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := watching("chat"); n != 0 {
+		t.Errorf("Expected the watcher of the evicted service stopped, got %d", n)
+	}
+	if n := watching("players"); n != 1 {
+		t.Errorf("Expected the cached service still watched, got %d watchers", n)
+	}
+}
@@ -0,0 +1,312 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/najoast/sngo/core"
+)
+
+// MessageTypeMetricsDigest carries a node's metrics digest to the aggregator
+const MessageTypeMetricsDigest MessageType = "metrics_digest"
+
+// RollupConfig controls the metrics digests nodes ship for cluster rollups
+type RollupConfig struct {
+	// Interval is how often each node ships its digest; 0 disables shipping
+	Interval time.Duration `yaml:"interval" json:"interval"`
+
+	// Aggregator is the node collecting digests; empty means the leader
+	Aggregator NodeID `yaml:"aggregator,omitempty" json:"aggregator,omitempty"`
+
+	// StaleAfter leaves digests older than this out of the rollup;
+	// 3 intervals when zero
+	StaleAfter time.Duration `yaml:"stale_after" json:"stale_after"`
+}
+
+// DefaultRollupConfig returns digests shipped every 10 seconds to the leader
+func DefaultRollupConfig() RollupConfig {
+	return RollupConfig{Interval: 10 * time.Second}
+}
+
+// staleAfter returns the age past which a digest is stale
+func (c RollupConfig) staleAfter() time.Duration {
+	if c.StaleAfter > 0 {
+		return c.StaleAfter
+	}
+	return 3 * c.Interval
+}
+
+// MetricsDigest is the compact summary of one node's metrics. Counters are
+// cumulative since the node started.
+type MetricsDigest struct {
+	Node NodeID `json:"node"`
+
+	// At is when the digest was taken; the aggregator replaces it with
+	// its own receive time, so freshness never depends on a peer's clock
+	At time.Time `json:"at"`
+
+	Connections int64 `json:"connections"`
+	Actors      int64 `json:"actors"`
+
+	RemoteCalls      uint64 `json:"remote_calls"`
+	RemoteCallErrors uint64 `json:"remote_call_errors"`
+
+	// Gauges holds application gauges summed across the cluster
+	Gauges map[string]float64 `json:"gauges,omitempty"`
+}
+
+// MetricsCollector fills in the parts of the local digest the cluster
+// cannot see itself, e.g. connections from the network server and actors
+// from the actor system
+type MetricsCollector func(digest *MetricsDigest)
+
+// ClusterRollup is the cluster-wide view built from the latest digest of
+// every node
+type ClusterRollup struct {
+	Aggregator NodeID    `json:"aggregator"`
+	At         time.Time `json:"at"`
+
+	// Nodes counts the nodes whose digest is fresh; Stale lists the others
+	Nodes int      `json:"nodes"`
+	Stale []NodeID `json:"stale,omitempty"`
+
+	Connections      int64  `json:"connections"`
+	Actors           int64  `json:"actors"`
+	RemoteCalls      uint64 `json:"remote_calls"`
+	RemoteCallErrors uint64 `json:"remote_call_errors"`
+
+	// ErrorRate is the share of remote calls that failed between the last
+	// two digests of each node, or since start for a node's first digest
+	ErrorRate float64 `json:"error_rate"`
+
+	Gauges  map[string]float64 `json:"gauges,omitempty"`
+	PerNode []MetricsDigest    `json:"per_node"`
+}
+
+// metricsRollup holds the local collector and, on the aggregator, the two
+// latest digests of every node
+type metricsRollup struct {
+	mu        sync.RWMutex
+	collector MetricsCollector
+	latest    map[NodeID]MetricsDigest
+	previous  map[NodeID]MetricsDigest
+}
+
+// SetMetricsCollector sets the collector filling in the local digest
+func (cm *clusterManager) SetMetricsCollector(collector MetricsCollector) {
+	cm.rollup.mu.Lock()
+	defer cm.rollup.mu.Unlock()
+
+	cm.rollup.collector = collector
+}
+
+// LocalMetricsDigest returns the digest of the local node as shipped now
+func (cm *clusterManager) LocalMetricsDigest() MetricsDigest {
+	digest := MetricsDigest{Node: cm.localNode.ID(), At: time.Now()}
+	if counter, ok := cm.service.(interface{ remoteCallCounts() (uint64, uint64) }); ok {
+		digest.RemoteCalls, digest.RemoteCallErrors = counter.remoteCallCounts()
+	}
+
+	cm.rollup.mu.RLock()
+	collector := cm.rollup.collector
+	cm.rollup.mu.RUnlock()
+
+	if collector != nil {
+		collector(&digest)
+	}
+	digest.Node = cm.localNode.ID()
+	return digest
+}
+
+// aggregator returns the node collecting digests, or "" when unknown
+func (cm *clusterManager) aggregator() NodeID {
+	if cm.config.Rollup.Aggregator != "" {
+		return cm.config.Rollup.Aggregator
+	}
+	cm.leaderMu.RLock()
+	defer cm.leaderMu.RUnlock()
+	return cm.leader
+}
+
+// rollupLoop ships the local digest to the aggregator every interval
+func (cm *clusterManager) rollupLoop() {
+	defer cm.wg.Done()
+
+	if cm.config.Rollup.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cm.config.Rollup.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cm.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cm.shipMetricsDigest(); err != nil {
+				core.ReportError("cluster", "rollup", core.SeverityWarning, err)
+			}
+		}
+	}
+}
+
+// shipMetricsDigest sends the local digest to the aggregator, or records
+// it when the local node aggregates
+func (cm *clusterManager) shipMetricsDigest() error {
+	target := cm.aggregator()
+	if target == "" {
+		return nil
+	}
+
+	digest := cm.LocalMetricsDigest()
+	if target == cm.localNode.ID() {
+		cm.recordMetricsDigest(digest)
+		return nil
+	}
+
+	payload, err := json.Marshal(digest)
+	if err != nil {
+		return fmt.Errorf("failed to serialize metrics digest: %w", err)
+	}
+	msg := &ClusterMessage{
+		ID:        generateMessageID(),
+		Type:      MessageTypeMetricsDigest,
+		From:      digest.Node,
+		To:        target,
+		Payload:   payload,
+		Timestamp: digest.At,
+	}
+	if err := cm.signClusterMessage(msg); err != nil {
+		return fmt.Errorf("failed to sign metrics digest: %w", err)
+	}
+	if err := cm.transport.Send(cm.ctx, target, msg); err != nil {
+		return fmt.Errorf("failed to ship metrics digest to %s: %w", target, err)
+	}
+	return nil
+}
+
+// handleMetricsDigest records a digest shipped by a peer. With an
+// authenticator set, only a signed digest from a known node is recorded.
+func (cm *clusterManager) handleMetricsDigest(from NodeID, message *ClusterMessage) error {
+	if err := cm.verifyClusterMessage(from, message); err != nil {
+		return err
+	}
+
+	var digest MetricsDigest
+	if err := json.Unmarshal(message.Payload, &digest); err != nil {
+		return fmt.Errorf("failed to parse metrics digest: %w", err)
+	}
+
+	// A node only speaks for itself, and is judged by the local clock
+	digest.Node = from
+	digest.At = time.Now()
+	cm.recordMetricsDigest(digest)
+	return nil
+}
+
+// recordMetricsDigest keeps digest, stamped on receipt, as the latest of
+// its node
+func (cm *clusterManager) recordMetricsDigest(digest MetricsDigest) {
+	cm.rollup.mu.Lock()
+	defer cm.rollup.mu.Unlock()
+
+	if cm.rollup.latest == nil {
+		cm.rollup.latest = make(map[NodeID]MetricsDigest)
+		cm.rollup.previous = make(map[NodeID]MetricsDigest)
+	}
+	if latest, ok := cm.rollup.latest[digest.Node]; ok {
+		cm.rollup.previous[digest.Node] = latest
+	}
+	cm.rollup.latest[digest.Node] = digest
+}
+
+// dropMetricsDigests forgets the digests of a node that left the cluster
+func (cm *clusterManager) dropMetricsDigests(node NodeID) {
+	cm.rollup.mu.Lock()
+	defer cm.rollup.mu.Unlock()
+
+	delete(cm.rollup.latest, node)
+	delete(cm.rollup.previous, node)
+}
+
+// MetricsRollup sums the fresh digests held by this node. Only the
+// aggregator holds digests of other nodes; elsewhere the rollup is empty.
+func (cm *clusterManager) MetricsRollup() ClusterRollup {
+	now := time.Now()
+	staleAfter := cm.config.Rollup.staleAfter()
+	rollup := ClusterRollup{Aggregator: cm.aggregator(), At: now, PerNode: []MetricsDigest{}}
+
+	cm.rollup.mu.RLock()
+	defer cm.rollup.mu.RUnlock()
+
+	var windowCalls, windowErrors uint64
+	for node, digest := range cm.rollup.latest {
+		if staleAfter > 0 && now.Sub(digest.At) > staleAfter {
+			rollup.Stale = append(rollup.Stale, node)
+			continue
+		}
+
+		rollup.Nodes++
+		rollup.Connections += digest.Connections
+		rollup.Actors += digest.Actors
+		rollup.RemoteCalls += digest.RemoteCalls
+		rollup.RemoteCallErrors += digest.RemoteCallErrors
+		for name, value := range digest.Gauges {
+			if rollup.Gauges == nil {
+				rollup.Gauges = make(map[string]float64)
+			}
+			rollup.Gauges[name] += value
+		}
+		rollup.PerNode = append(rollup.PerNode, digest)
+
+		calls, errs := digest.RemoteCalls, digest.RemoteCallErrors
+		// A restarted node's counters went back; count them from zero
+		if previous, ok := cm.rollup.previous[node]; ok && calls >= previous.RemoteCalls && errs >= previous.RemoteCallErrors {
+			calls -= previous.RemoteCalls
+			errs -= previous.RemoteCallErrors
+		}
+		windowCalls += calls
+		windowErrors += errs
+	}
+	if windowCalls > 0 {
+		rollup.ErrorRate = float64(windowErrors) / float64(windowCalls)
+	}
+
+	sort.Slice(rollup.PerNode, func(i, j int) bool { return rollup.PerNode[i].Node < rollup.PerNode[j].Node })
+	sort.Slice(rollup.Stale, func(i, j int) bool { return rollup.Stale[i] < rollup.Stale[j] })
+	return rollup
+}
+
+// countRemoteCall records the outcome of a remote call for the digest
+func (rs *remoteService) countRemoteCall(err error) {
+	atomic.AddUint64(&rs.remoteCalls, 1)
+	if err != nil {
+		atomic.AddUint64(&rs.remoteCallErrors, 1)
+	}
+}
+
+// remoteCallCounts returns the remote calls made and how many failed
+func (rs *remoteService) remoteCallCounts() (uint64, uint64) {
+	return atomic.LoadUint64(&rs.remoteCalls), atomic.LoadUint64(&rs.remoteCallErrors)
+}
+
+// RollupAdminHandler exposes the cluster rollup over HTTP for the admin
+// API. GET returns the rollup held by this node, which is complete on the
+// aggregator only.
+func RollupAdminHandler(manager ClusterManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(manager.MetricsRollup())
+	})
+}
@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricsRollup(t *testing.T) {
+	managers, _ := controlCluster(t, "a", "b", "c")
+	for id, cm := range managers {
+		cm.ctx = context.Background()
+		cm.service = NewRemoteService(cm)
+		actors := map[NodeID]int64{"a": 10, "b": 20, "c": 30}[id]
+		cm.SetMetricsCollector(func(d *MetricsDigest) {
+			d.Node = "spoofed"
+			d.Connections = actors * 2
+			d.Actors = actors
+			d.Gauges = map[string]float64{"rooms": 1}
+		})
+	}
+	for _, id := range []NodeID{"b", "c"} {
+		managers[id].leaderMu.Lock()
+		managers[id].leader = "a"
+		managers[id].leaderMu.Unlock()
+	}
+
+	// b makes two remote calls, one of them failing
+	service := managers["b"].service.(*remoteService)
+	service.countRemoteCall(nil)
+	service.countRemoteCall(errors.New("timeout"))
+
+	for _, id := range []NodeID{"a", "b", "c"} {
+		if err := managers[id].shipMetricsDigest(); err != nil {
+			t.Fatalf("Failed to ship digest of %s: %v", id, err)
+		}
+	}
+	if rollup := managers["b"].MetricsRollup(); rollup.Nodes != 0 || rollup.Aggregator != "a" {
+		t.Errorf("Expected an empty rollup off the aggregator, got %+v", rollup)
+	}
+
+	rollup := managers["a"].MetricsRollup()
+	if rollup.Nodes != 3 || rollup.Actors != 60 || rollup.Connections != 120 || rollup.Gauges["rooms"] != 3 {
+		t.Errorf("Unexpected rollup %+v", rollup)
+	}
+	if rollup.RemoteCalls != 2 || rollup.RemoteCallErrors != 1 || rollup.ErrorRate != 0.5 {
+		t.Errorf("Expected a 50%% error rate over 2 calls, got %+v", rollup)
+	}
+	if len(rollup.PerNode) != 3 || rollup.PerNode[1].Node != "b" {
+		t.Errorf("Expected digests sorted by real node, got %+v", rollup.PerNode)
+	}
+
+	// The error rate covers the window since the previous digest
+	time.Sleep(time.Millisecond)
+	for i := 0; i < 4; i++ {
+		service.countRemoteCall(nil)
+	}
+	managers["b"].shipMetricsDigest()
+	if rollup := managers["a"].MetricsRollup(); rollup.RemoteCalls != 6 || rollup.ErrorRate != 0 {
+		t.Errorf("Expected no errors in the last window, got %+v", rollup)
+	}
+
+	// A node that stops shipping drops out of the totals
+	managers["a"].config.Rollup.StaleAfter = 50 * time.Millisecond
+	time.Sleep(60 * time.Millisecond)
+	managers["b"].shipMetricsDigest()
+	rollup = managers["a"].MetricsRollup()
+	if rollup.Nodes != 1 || rollup.Actors != 20 || len(rollup.Stale) != 2 {
+		t.Errorf("Expected a and c stale, got %+v", rollup)
+	}
+
+	// A digest is judged by when it arrived, not by the sender's clock
+	skewed, _ := json.Marshal(MetricsDigest{Node: "c", At: time.Now().Add(time.Hour), Actors: 30})
+	managers["a"].handleMetricsDigest("c", &ClusterMessage{Type: MessageTypeMetricsDigest, Payload: skewed})
+	behind, _ := json.Marshal(MetricsDigest{Node: "c", At: time.Now().Add(-time.Hour), Actors: 31})
+	managers["a"].handleMetricsDigest("c", &ClusterMessage{Type: MessageTypeMetricsDigest, Payload: behind})
+	rollup = managers["a"].MetricsRollup()
+	if rollup.Nodes != 2 || rollup.Actors != 51 {
+		t.Errorf("Expected the latest digest of c despite its clock, got %+v", rollup)
+	}
+
+	// A node that left is dropped from the rollup
+	managers["a"].forgetPeer("c")
+	if rollup := managers["a"].MetricsRollup(); rollup.Nodes != 1 || len(rollup.Stale) != 1 {
+		t.Errorf("Expected c forgotten, got %+v", rollup)
+	}
+
+	rec := httptest.NewRecorder()
+	RollupAdminHandler(managers["a"]).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rollup", nil))
+	var body ClusterRollup
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Aggregator != "a" || body.Nodes != 1 {
+		t.Errorf("Unexpected admin response %q (%v)", rec.Body.String(), err)
+	}
+}
+
+func TestMetricsDigestAuthenticated(t *testing.T) {
+	managers, _ := controlCluster(t, "a", "b", "c")
+	authenticateCluster(managers)
+	for id, cm := range managers {
+		cm.ctx = context.Background()
+		actors := map[NodeID]int64{"a": 10, "b": 20, "c": 30}[id]
+		cm.SetMetricsCollector(func(d *MetricsDigest) { d.Actors = actors })
+	}
+	managers["b"].leaderMu.Lock()
+	managers["b"].leader = "a"
+	managers["b"].leaderMu.Unlock()
+
+	if err := managers["b"].shipMetricsDigest(); err != nil {
+		t.Fatalf("Failed to ship digest of b: %v", err)
+	}
+
+	// c's made-up numbers never reach the rollup unsigned
+	invented, _ := json.Marshal(MetricsDigest{Node: "c", Actors: 100000, Connections: 100000})
+	err := managers["a"].HandleMessage(context.Background(), "c", &ClusterMessage{Type: MessageTypeMetricsDigest, From: "c", Payload: invented})
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected an unsigned digest refused, got %v", err)
+	}
+	if rollup := managers["a"].MetricsRollup(); rollup.Nodes != 1 || rollup.Actors != 20 {
+		t.Errorf("Expected only the signed digest of b, got %+v", rollup)
+	}
+}
@@ -0,0 +1,388 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SelectorOperator is the comparison of one selector requirement
+type SelectorOperator string
+
+const (
+	SelectorEquals       SelectorOperator = "="
+	SelectorNotEquals    SelectorOperator = "!="
+	SelectorIn           SelectorOperator = "in"
+	SelectorNotIn        SelectorOperator = "notin"
+	SelectorExists       SelectorOperator = "exists"
+	SelectorDoesNotExist SelectorOperator = "!"
+	SelectorGreater      SelectorOperator = ">"
+	SelectorGreaterEqual SelectorOperator = ">="
+	SelectorLess         SelectorOperator = "<"
+	SelectorLessEqual    SelectorOperator = "<="
+)
+
+// Requirement is one condition of a label selector
+type Requirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+}
+
+// Selector matches instance labels. The textual form is a comma separated
+// list of requirements, all of which must hold:
+//
+//	env=prod, shard!=3, tier in (web, api), !canary, version>=2.1
+//
+// Ordering operators compare dotted numeric versions ("2" < "2.1" < "10")
+type Selector []Requirement
+
+// ParseSelector parses a textual label selector; the empty string selects
+// every instance
+func ParseSelector(text string) (Selector, error) {
+	var selector Selector
+
+	for _, term := range splitSelector(text) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", text, err)
+		}
+		selector = append(selector, req)
+	}
+
+	return selector, nil
+}
+
+// MustParseSelector parses a selector and panics on error
+func MustParseSelector(text string) Selector {
+	selector, err := ParseSelector(text)
+	if err != nil {
+		panic(err)
+	}
+	return selector
+}
+
+// splitSelector splits on commas outside of parentheses
+func splitSelector(text string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range text {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, text[start:])
+}
+
+// parseRequirement parses one selector term
+func parseRequirement(term string) (Requirement, error) {
+	if strings.HasPrefix(term, "!") && !strings.ContainsAny(term, "=<>") {
+		key := strings.TrimSpace(term[1:])
+		if key == "" {
+			return Requirement{}, fmt.Errorf("missing key in %q", term)
+		}
+		return Requirement{Key: key, Operator: SelectorDoesNotExist}, nil
+	}
+
+	// Set operators
+	fields := strings.Fields(term)
+	if len(fields) >= 2 && (fields[1] == string(SelectorIn) || fields[1] == string(SelectorNotIn)) {
+		open := strings.Index(term, "(")
+		if open < 0 || !strings.HasSuffix(term, ")") {
+			return Requirement{}, fmt.Errorf("%s needs a parenthesized list in %q", fields[1], term)
+		}
+		var values []string
+		for _, value := range strings.Split(term[open+1:len(term)-1], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return Requirement{}, fmt.Errorf("empty value list in %q", term)
+		}
+		return Requirement{Key: fields[0], Operator: SelectorOperator(fields[1]), Values: values}, nil
+	}
+
+	// Comparison operators, longest first
+	for _, op := range []SelectorOperator{SelectorNotEquals, SelectorGreaterEqual, SelectorLessEqual, "==", SelectorEquals, SelectorGreater, SelectorLess} {
+		index := strings.Index(term, string(op))
+		if index < 0 {
+			continue
+		}
+
+		key := strings.TrimSpace(term[:index])
+		value := strings.TrimSpace(term[index+len(op):])
+		if key == "" {
+			return Requirement{}, fmt.Errorf("missing key in %q", term)
+		}
+		if op == "==" {
+			op = SelectorEquals
+		}
+		if isOrdering(op) {
+			if _, err := parseVersion(value); err != nil {
+				return Requirement{}, err
+			}
+		}
+		return Requirement{Key: key, Operator: op, Values: []string{value}}, nil
+	}
+
+	if strings.ContainsAny(term, " ()") {
+		return Requirement{}, fmt.Errorf("unexpected term %q", term)
+	}
+	return Requirement{Key: term, Operator: SelectorExists}, nil
+}
+
+// isOrdering returns true for the version comparison operators
+func isOrdering(op SelectorOperator) bool {
+	switch op {
+	case SelectorGreater, SelectorGreaterEqual, SelectorLess, SelectorLessEqual:
+		return true
+	}
+	return false
+}
+
+// parseVersion parses a dotted numeric version such as "2" or "2.1.3"; a
+// leading "v" is allowed
+func parseVersion(text string) ([]int, error) {
+	text = strings.TrimPrefix(text, "v")
+	if text == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+
+	parts := strings.Split(text, ".")
+	version := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", text)
+		}
+		version[i] = n
+	}
+	return version, nil
+}
+
+// compareVersions returns -1, 0 or 1; missing components count as zero
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Matches returns true if labels satisfy the requirement
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, exists := labels[r.Key]
+
+	switch r.Operator {
+	case SelectorExists:
+		return exists
+	case SelectorDoesNotExist:
+		return !exists
+	case SelectorEquals:
+		return exists && value == r.Values[0]
+	case SelectorNotEquals:
+		return !exists || value != r.Values[0]
+	case SelectorIn:
+		return exists && containsString(r.Values, value)
+	case SelectorNotIn:
+		return !exists || !containsString(r.Values, value)
+	}
+
+	if !exists {
+		return false
+	}
+	have, err := parseVersion(value)
+	if err != nil {
+		return false
+	}
+	want, _ := parseVersion(r.Values[0])
+	cmp := compareVersions(have, want)
+
+	switch r.Operator {
+	case SelectorGreater:
+		return cmp > 0
+	case SelectorGreaterEqual:
+		return cmp >= 0
+	case SelectorLess:
+		return cmp < 0
+	case SelectorLessEqual:
+		return cmp <= 0
+	}
+	return false
+}
+
+// Matches returns true if labels satisfy every requirement
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the textual form of the selector
+func (s Selector) String() string {
+	terms := make([]string, len(s))
+	for i, req := range s {
+		switch req.Operator {
+		case SelectorExists:
+			terms[i] = req.Key
+		case SelectorDoesNotExist:
+			terms[i] = "!" + req.Key
+		case SelectorIn, SelectorNotIn:
+			terms[i] = fmt.Sprintf("%s %s (%s)", req.Key, req.Operator, strings.Join(req.Values, ", "))
+		default:
+			terms[i] = req.Key + string(req.Operator) + req.Values[0]
+		}
+	}
+	return strings.Join(terms, ", ")
+}
+
+// containsString returns true if values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// labelIndex holds the instances of one service and maps their label
+// values to nodes, so equality and set requirements only visit candidates
+type labelIndex struct {
+	instances map[NodeID]ServiceInstance
+	values    map[string]map[string]map[NodeID]struct{} // key -> value -> nodes
+}
+
+// newLabelIndex creates an empty index
+func newLabelIndex() *labelIndex {
+	return &labelIndex{
+		instances: make(map[NodeID]ServiceInstance),
+		values:    make(map[string]map[string]map[NodeID]struct{}),
+	}
+}
+
+// add indexes an instance, replacing the previous instance on its node
+func (li *labelIndex) add(instance ServiceInstance) {
+	li.remove(instance.NodeID)
+	li.instances[instance.NodeID] = instance
+
+	for key, value := range instance.Labels {
+		byValue, exists := li.values[key]
+		if !exists {
+			byValue = make(map[string]map[NodeID]struct{})
+			li.values[key] = byValue
+		}
+		nodes, exists := byValue[value]
+		if !exists {
+			nodes = make(map[NodeID]struct{})
+			byValue[value] = nodes
+		}
+		nodes[instance.NodeID] = struct{}{}
+	}
+}
+
+// remove drops the instance on node
+func (li *labelIndex) remove(node NodeID) {
+	instance, exists := li.instances[node]
+	if !exists {
+		return
+	}
+	delete(li.instances, node)
+
+	for key, value := range instance.Labels {
+		byValue := li.values[key]
+		delete(byValue[value], node)
+		if len(byValue[value]) == 0 {
+			delete(byValue, value)
+		}
+		if len(byValue) == 0 {
+			delete(li.values, key)
+		}
+	}
+}
+
+// candidates returns the nodes that may match the selector, using its most
+// selective indexable requirement; ok is false if no requirement can use
+// the index and every instance must be checked
+func (li *labelIndex) candidates(selector Selector) (map[NodeID]struct{}, bool) {
+	var best map[NodeID]struct{}
+	found := false
+
+	for _, req := range selector {
+		var nodes map[NodeID]struct{}
+		switch req.Operator {
+		case SelectorEquals:
+			nodes = li.values[req.Key][req.Values[0]]
+		case SelectorIn:
+			nodes = make(map[NodeID]struct{})
+			for _, value := range req.Values {
+				for node := range li.values[req.Key][value] {
+					nodes[node] = struct{}{}
+				}
+			}
+		default:
+			continue
+		}
+
+		if !found || len(nodes) < len(best) {
+			best = nodes
+			found = true
+		}
+		if len(best) == 0 {
+			break
+		}
+	}
+
+	return best, found
+}
+
+// selectInstances returns the instances matching selector, sorted by node
+func (li *labelIndex) selectInstances(selector Selector) []ServiceInstance {
+	result := make([]ServiceInstance, 0)
+
+	if nodes, ok := li.candidates(selector); ok {
+		for node := range nodes {
+			if instance := li.instances[node]; selector.Matches(instance.Labels) {
+				result = append(result, instance)
+			}
+		}
+	} else {
+		for _, instance := range li.instances {
+			if selector.Matches(instance.Labels) {
+				result = append(result, instance)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].NodeID < result[j].NodeID })
+	return result
+}
@@ -0,0 +1,121 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// TestSelectorParsing tests the selector grammar and matching
+func TestSelectorParsing(t *testing.T) {
+	labels := map[string]string{"env": "prod", "shard": "3", "tier": "web", "version": "2.10"}
+
+	cases := []struct {
+		selector string
+		match    bool
+	}{
+		{"", true},
+		{"env=prod, shard=3", true},
+		{"env==prod", true},
+		{"env!=prod", false},
+		{"tier in (web, api)", true},
+		{"tier notin (web, api)", false},
+		{"canary", false},
+		{"!canary, env", true},
+		{"version>=2", true},
+		{"version>2.9", true},
+		{"version<2.2", false},
+		{"version<=v2.10.0", true},
+		{"shard>=4", false},
+		{"missing>=1", false},
+	}
+
+	for _, c := range cases {
+		selector, err := ParseSelector(c.selector)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", c.selector, err)
+			continue
+		}
+		if got := selector.Matches(labels); got != c.match {
+			t.Errorf("Selector %q: expected match=%v, got %v", c.selector, c.match, got)
+		}
+		if reparsed, err := ParseSelector(selector.String()); err != nil || reparsed.String() != selector.String() {
+			t.Errorf("Selector %q did not round trip: %q (%v)", c.selector, selector.String(), err)
+		}
+	}
+
+	for _, invalid := range []string{"version>=two", "tier in web", "tier in ()", "=prod", "a b"} {
+		if _, err := ParseSelector(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+// TestDiscoverServiceSelector tests indexed selector queries over many instances
+func TestDiscoverServiceSelector(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "local"
+	registry := NewServiceRegistry(NewClusterManager(config)).(*serviceRegistry)
+
+	for i := 0; i < 3000; i++ {
+		env := "prod"
+		if i%3 == 0 {
+			env = "staging"
+		}
+		registry.upsertInstance(ServiceInstance{
+			ServiceID: "players",
+			NodeID:    NodeID(fmt.Sprintf("node-%04d", i)),
+			Labels: map[string]string{
+				"env":     env,
+				"shard":   fmt.Sprint(i % 10),
+				"version": fmt.Sprintf("%d.%d", i%4, i%7),
+			},
+		})
+	}
+
+	ctx := context.Background()
+	instances, err := registry.DiscoverServiceSelector(ctx, "players", MustParseSelector("env=prod, shard=3, version>=2"))
+	if err != nil {
+		t.Fatalf("Failed to discover: %v", err)
+	}
+
+	expected := 0
+	for i := 0; i < 3000; i++ {
+		if i%3 != 0 && i%10 == 3 && i%4 >= 2 {
+			expected++
+		}
+	}
+	if len(instances) != expected {
+		t.Errorf("Expected %d instances, got %d", expected, len(instances))
+	}
+	for i := 1; i < len(instances); i++ {
+		if instances[i-1].NodeID >= instances[i].NodeID {
+			t.Fatal("Expected instances sorted by node")
+		}
+	}
+
+	// Replacing an instance re-indexes its labels
+	registry.upsertInstance(ServiceInstance{ServiceID: "players", NodeID: "node-0000", Labels: map[string]string{"env": "canary"}})
+	instances, _ = registry.DiscoverServiceSelector(ctx, "players", MustParseSelector("env in (canary)"))
+	if len(instances) != 1 || instances[0].NodeID != "node-0000" {
+		t.Errorf("Expected only node-0000 to be canary, got %d instances", len(instances))
+	}
+	instances, _ = registry.DiscoverServiceSelector(ctx, "players", MustParseSelector("env=staging"))
+	if len(instances) != 999 {
+		t.Errorf("Expected 999 staging instances, got %d", len(instances))
+	}
+
+	// Local registrations carry labels and are removed from the index
+	if err := registry.RegisterServiceWithLabels(ctx, "players", nil, map[string]string{"env": "dev"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	instances, _ = registry.DiscoverServiceSelector(ctx, "players", MustParseSelector("env=dev"))
+	if len(instances) != 1 || instances[0].NodeID != "local" {
+		t.Errorf("Expected the local instance, got %+v", instances)
+	}
+	registry.UnregisterService(ctx, "players")
+	instances, _ = registry.DiscoverServiceSelector(ctx, "players", MustParseSelector("env=dev"))
+	if len(instances) != 0 {
+		t.Errorf("Expected unregistered instance to leave the index, got %d", len(instances))
+	}
+}
@@ -3,10 +3,14 @@ package cluster
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/najoast/sngo/core"
 )
 
 // remoteService implements the RemoteService interface
@@ -22,11 +26,25 @@ type remoteService struct {
 	callsMu      sync.RWMutex
 
 	callCounter int64 // atomic
+
+	remoteCalls      uint64 // atomic
+	remoteCallErrors uint64 // atomic
+
+	authenticator Authenticator
+	acl           *AccessControl
+	auditHandler  func(AuditEvent)
+	limiter       *RateLimiter
+	resolveCache  *ResolveCache
+	securityMu    sync.RWMutex
+
+	sweeper *nodeSweeper
+	sticky  *StickySessions
 }
 
 // pendingCall represents a pending remote call
 type pendingCall struct {
 	id      string
+	node    NodeID
 	result  chan interface{}
 	error   chan error
 	timeout time.Time
@@ -42,9 +60,11 @@ type RemoteCallRequest struct {
 
 // RemoteCallResponse represents a remote call response
 type RemoteCallResponse struct {
-	CallID string      `json:"call_id"`
-	Result interface{} `json:"result,omitempty"`
-	Error  string      `json:"error,omitempty"`
+	CallID    string      `json:"call_id"`
+	Result    interface{} `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	ErrorCode string      `json:"error_code,omitempty"`
+	Retryable bool        `json:"retryable,omitempty"`
 }
 
 // NewRemoteService creates a new remote service
@@ -53,6 +73,13 @@ func NewRemoteService(manager ClusterManager) RemoteService {
 		manager:      manager,
 		handlers:     make(map[string]RemoteCallHandler),
 		pendingCalls: make(map[string]*pendingCall),
+		sticky:       NewStickySessions(0),
+	}
+	rs.sweeper = newNodeSweeper(rs.incarnation)
+
+	// Fail calls and watches promptly when a node is lost
+	if manager != nil {
+		manager.AddEventListener(rs.handleNodeEvent)
 	}
 
 	// TODO: Get transport from manager
@@ -61,7 +88,19 @@ func NewRemoteService(manager ClusterManager) RemoteService {
 	return rs
 }
 
-func (rs *remoteService) Call(ctx context.Context, ref RemoteActorRef, message interface{}) (interface{}, error) {
+func (rs *remoteService) Call(ctx context.Context, ref RemoteActorRef, message interface{}) (result interface{}, err error) {
+	defer func() { rs.countRemoteCall(err) }()
+
+	if err := refuseOnWitness(rs.manager); err != nil {
+		return nil, err
+	}
+	if err := rs.sweeper.checkRef(ref); err != nil {
+		return nil, err
+	}
+	if err := refuseMixedVersion(rs.manager, ref.NodeID); err != nil {
+		return nil, err
+	}
+
 	// Generate call ID
 	callID := rs.generateCallID()
 
@@ -88,11 +127,13 @@ func (rs *remoteService) Call(ctx context.Context, ref RemoteActorRef, message i
 		Payload:   payload,
 		Timestamp: time.Now(),
 		TTL:       30 * time.Second,
+		Headers:   serviceHeaders(ref.Service),
 	}
 
 	// Create pending call
 	pending := &pendingCall{
 		id:      callID,
+		node:    ref.NodeID,
 		result:  make(chan interface{}, 1),
 		error:   make(chan error, 1),
 		timeout: time.Now().Add(30 * time.Second),
@@ -109,9 +150,13 @@ func (rs *remoteService) Call(ctx context.Context, ref RemoteActorRef, message i
 		rs.callsMu.Unlock()
 	}()
 
+	if err := rs.sign(clusterMsg); err != nil {
+		return nil, err
+	}
+
 	// Send message
 	if err := rs.transport.Send(ctx, ref.NodeID, clusterMsg); err != nil {
-		return nil, fmt.Errorf("failed to send remote call: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrSendFailed, err)
 	}
 
 	// Wait for response
@@ -128,6 +173,16 @@ func (rs *remoteService) Call(ctx context.Context, ref RemoteActorRef, message i
 }
 
 func (rs *remoteService) Send(ctx context.Context, ref RemoteActorRef, message interface{}) error {
+	if err := refuseOnWitness(rs.manager); err != nil {
+		return err
+	}
+	if err := rs.sweeper.checkRef(ref); err != nil {
+		return err
+	}
+	if err := refuseMixedVersion(rs.manager, ref.NodeID); err != nil {
+		return err
+	}
+
 	// Serialize message
 	payload, err := json.Marshal(message)
 	if err != nil {
@@ -142,10 +197,13 @@ func (rs *remoteService) Send(ctx context.Context, ref RemoteActorRef, message i
 		To:        ref.NodeID,
 		Payload:   payload,
 		Timestamp: time.Now(),
-		Headers: map[string]string{
-			"target_actor": ref.ActorID,
-			"fire_forget":  "true",
-		},
+		Headers:   serviceHeaders(ref.Service),
+	}
+	clusterMsg.Headers["target_actor"] = ref.ActorID
+	clusterMsg.Headers["fire_forget"] = "true"
+
+	if err := rs.sign(clusterMsg); err != nil {
+		return err
 	}
 
 	// Send message
@@ -153,6 +211,10 @@ func (rs *remoteService) Send(ctx context.Context, ref RemoteActorRef, message i
 }
 
 func (rs *remoteService) Register(serviceID string, handler RemoteCallHandler) error {
+	if err := refuseOnWitness(rs.manager); err != nil {
+		return err
+	}
+
 	rs.handlersMu.Lock()
 	defer rs.handlersMu.Unlock()
 
@@ -192,7 +254,7 @@ func (rs *remoteService) Resolve(ctx context.Context, serviceID string) ([]Remot
 		return nil, fmt.Errorf("service registry not available")
 	}
 
-	instances, err := rs.registry.DiscoverService(ctx, serviceID)
+	instances, err := rs.discover(ctx, serviceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to discover service: %w", err)
 	}
@@ -203,6 +265,8 @@ func (rs *remoteService) Resolve(ctx context.Context, serviceID string) ([]Remot
 			NodeID:  instance.NodeID,
 			ActorID: serviceID,
 			Address: instance.Address,
+			Service: serviceID,
+			Epoch:   rs.sweeper.epoch(instance.NodeID),
 		}
 		refs = append(refs, ref)
 	}
@@ -210,10 +274,148 @@ func (rs *remoteService) Resolve(ctx context.Context, serviceID string) ([]Remot
 	return refs, nil
 }
 
+func (rs *remoteService) ResolveSelector(ctx context.Context, serviceID string, selector string) ([]RemoteActorRef, error) {
+	if rs.registry == nil {
+		return nil, fmt.Errorf("service registry not available")
+	}
+
+	parsed, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := rs.registry.DiscoverServiceSelector(ctx, serviceID, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover service: %w", err)
+	}
+
+	refs := make([]RemoteActorRef, 0, len(instances))
+	for _, instance := range instances {
+		refs = append(refs, RemoteActorRef{
+			NodeID:  instance.NodeID,
+			ActorID: serviceID,
+			Address: instance.Address,
+			Service: serviceID,
+			Epoch:   rs.sweeper.epoch(instance.NodeID),
+		})
+	}
+
+	return refs, nil
+}
+
 func (rs *remoteService) GetServiceRegistry() ServiceRegistry {
 	return rs.registry
 }
 
+func (rs *remoteService) SetAuthenticator(auth Authenticator) {
+	rs.securityMu.Lock()
+	defer rs.securityMu.Unlock()
+	rs.authenticator = auth
+}
+
+func (rs *remoteService) SetAccessControl(acl *AccessControl) {
+	rs.securityMu.Lock()
+	defer rs.securityMu.Unlock()
+	rs.acl = acl
+}
+
+func (rs *remoteService) SetAuditHandler(handler func(AuditEvent)) {
+	rs.securityMu.Lock()
+	defer rs.securityMu.Unlock()
+	rs.auditHandler = handler
+}
+
+// sign attaches credentials to an outgoing call if an authenticator is set
+func (rs *remoteService) sign(message *ClusterMessage) error {
+	rs.securityMu.RLock()
+	auth := rs.authenticator
+	rs.securityMu.RUnlock()
+
+	if auth == nil {
+		return nil
+	}
+
+	if err := auth.Sign(message); err != nil {
+		return fmt.Errorf("failed to sign remote call: %w", err)
+	}
+	return nil
+}
+
+// authorize authenticates an inbound call and checks the service ACL,
+// auditing every denial
+func (rs *remoteService) authorize(from NodeID, message *ClusterMessage, serviceID string) error {
+	rs.securityMu.RLock()
+	auth, acl := rs.authenticator, rs.acl
+	rs.securityMu.RUnlock()
+
+	identity, err := verifyWith(auth, from, message)
+	if err != nil {
+		rs.audit(from, nil, serviceID, err)
+		return err
+	}
+
+	if acl != nil {
+		if identity == nil {
+			identity = &NodeIdentity{NodeID: from}
+		}
+		if err := acl.Check(identity, serviceID); err != nil {
+			rs.audit(from, identity.Roles, serviceID, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verify authenticates an inbound message sent by from. It returns a nil
+// identity when no authenticator is set.
+func (rs *remoteService) verify(from NodeID, message *ClusterMessage) (*NodeIdentity, error) {
+	rs.securityMu.RLock()
+	auth := rs.authenticator
+	rs.securityMu.RUnlock()
+
+	return verifyWith(auth, from, message)
+}
+
+// verifyWith checks the signature of message with auth, if any, and that
+// it was signed by the node it came from
+func verifyWith(auth Authenticator, from NodeID, message *ClusterMessage) (*NodeIdentity, error) {
+	if auth == nil {
+		return nil, nil
+	}
+	identity, err := auth.Verify(message)
+	if err != nil {
+		return nil, err
+	}
+	if identity.NodeID != from {
+		return nil, fmt.Errorf("%w: %s signed as %s", ErrUnauthenticated, from, identity.NodeID)
+	}
+	return identity, nil
+}
+
+// audit reports a denied call to the audit handler
+func (rs *remoteService) audit(caller NodeID, roles []string, serviceID string, reason error) {
+	event := AuditEvent{
+		Timestamp: time.Now(),
+		Caller:    caller,
+		Roles:     roles,
+		ServiceID: serviceID,
+		Reason:    reason.Error(),
+	}
+
+	rs.securityMu.RLock()
+	handler := rs.auditHandler
+	rs.securityMu.RUnlock()
+
+	if handler != nil {
+		handler(event)
+		return
+	}
+
+	core.ReportError("cluster", "remote_service", core.SeverityWarning,
+		fmt.Errorf("remote call denied: caller=%s service=%s: %w", caller, serviceID, reason))
+}
+
 // MessageHandler interface implementation
 
 func (rs *remoteService) HandleMessage(ctx context.Context, from NodeID, message *ClusterMessage) error {
@@ -239,6 +441,14 @@ func (rs *remoteService) handleRemoteCall(ctx context.Context, from NodeID, mess
 		return fmt.Errorf("failed to parse remote call request: %w", err)
 	}
 
+	if err := rs.authorize(from, message, request.ServiceID); err != nil {
+		return rs.sendErrorResponse(ctx, from, request.CallID, message.Headers[HeaderService], err)
+	}
+
+	if err := rs.throttle(from, request.ServiceID); err != nil {
+		return rs.sendErrorResponse(ctx, from, request.CallID, message.Headers[HeaderService], err)
+	}
+
 	// Get handler
 	rs.handlersMu.RLock()
 	handler, exists := rs.handlers[request.ServiceID]
@@ -246,7 +456,11 @@ func (rs *remoteService) handleRemoteCall(ctx context.Context, from NodeID, mess
 
 	if !exists {
 		// Send error response
-		return rs.sendErrorResponse(ctx, from, request.CallID, fmt.Errorf("service not found: %s", request.ServiceID))
+		return rs.sendErrorResponse(ctx, from, request.CallID, message.Headers[HeaderService], fmt.Errorf("service not found: %s", request.ServiceID))
+	}
+
+	if err := rs.checkReadOnly(handler, request.Args); err != nil {
+		return rs.sendErrorResponse(ctx, from, request.CallID, message.Headers[HeaderService], err)
 	}
 
 	// Handle call
@@ -254,10 +468,10 @@ func (rs *remoteService) handleRemoteCall(ctx context.Context, from NodeID, mess
 
 	// Send response
 	if err != nil {
-		return rs.sendErrorResponse(ctx, from, request.CallID, err)
+		return rs.sendErrorResponse(ctx, from, request.CallID, message.Headers[HeaderService], err)
 	}
 
-	return rs.sendSuccessResponse(ctx, from, request.CallID, result)
+	return rs.sendSuccessResponse(ctx, from, request.CallID, message.Headers[HeaderService], result)
 }
 
 func (rs *remoteService) handleFireAndForget(ctx context.Context, from NodeID, message *ClusterMessage) error {
@@ -266,6 +480,14 @@ func (rs *remoteService) handleFireAndForget(ctx context.Context, from NodeID, m
 		return fmt.Errorf("target_actor header missing")
 	}
 
+	if err := rs.authorize(from, message, targetActor); err != nil {
+		return err
+	}
+
+	if err := rs.throttle(from, targetActor); err != nil {
+		return err
+	}
+
 	// Get handler
 	rs.handlersMu.RLock()
 	handler, exists := rs.handlers[targetActor]
@@ -281,11 +503,15 @@ func (rs *remoteService) handleFireAndForget(ctx context.Context, from NodeID, m
 		return fmt.Errorf("failed to parse message: %w", err)
 	}
 
+	if err := rs.checkReadOnly(handler, args); err != nil {
+		return err
+	}
+
 	// Handle message (fire and forget)
 	go func() {
 		if _, err := handler.Handle(context.Background(), args); err != nil {
 			// Log error but don't return it
-			fmt.Printf("Fire and forget call failed: %v\n", err)
+			core.ReportError("cluster", "remote_service", core.SeverityError, fmt.Errorf("fire and forget call failed: %w", err))
 		}
 	}()
 
@@ -312,7 +538,7 @@ func (rs *remoteService) handleRemoteReply(ctx context.Context, from NodeID, mes
 	// Send result
 	if response.Error != "" {
 		select {
-		case pending.error <- fmt.Errorf(response.Error):
+		case pending.error <- remoteCallError(response.ErrorCode, response.Error):
 		default:
 		}
 	} else {
@@ -325,7 +551,8 @@ func (rs *remoteService) handleRemoteReply(ctx context.Context, from NodeID, mes
 	return nil
 }
 
-func (rs *remoteService) sendSuccessResponse(ctx context.Context, to NodeID, callID string, result interface{}) error {
+// sendSuccessResponse replies to a call, accounted to the service of the call
+func (rs *remoteService) sendSuccessResponse(ctx context.Context, to NodeID, callID, service string, result interface{}) error {
 	response := RemoteCallResponse{
 		CallID: callID,
 		Result: result,
@@ -343,15 +570,20 @@ func (rs *remoteService) sendSuccessResponse(ctx context.Context, to NodeID, cal
 		To:        to,
 		Payload:   payload,
 		Timestamp: time.Now(),
+		Headers:   serviceHeaders(service),
 	}
 
 	return rs.transport.Send(ctx, to, clusterMsg)
 }
 
-func (rs *remoteService) sendErrorResponse(ctx context.Context, to NodeID, callID string, err error) error {
+// sendErrorResponse replies to a call with err, accounted to the service of
+// the call
+func (rs *remoteService) sendErrorResponse(ctx context.Context, to NodeID, callID, service string, err error) error {
 	response := RemoteCallResponse{
-		CallID: callID,
-		Error:  err.Error(),
+		CallID:    callID,
+		Error:     err.Error(),
+		ErrorCode: remoteCallErrorCode(err),
+		Retryable: IsRetryable(err),
 	}
 
 	payload, err := json.Marshal(response)
@@ -366,22 +598,72 @@ func (rs *remoteService) sendErrorResponse(ctx context.Context, to NodeID, callI
 		To:        to,
 		Payload:   payload,
 		Timestamp: time.Now(),
+		Headers:   serviceHeaders(service),
 	}
 
 	return rs.transport.Send(ctx, to, clusterMsg)
 }
 
+// serviceHeaders returns the headers accounting a message to service, if any
+func serviceHeaders(service string) map[string]string {
+	headers := make(map[string]string)
+	if service != "" {
+		headers[HeaderService] = service
+	}
+	return headers
+}
+
 func (rs *remoteService) generateCallID() string {
 	counter := atomic.AddInt64(&rs.callCounter, 1)
 	return fmt.Sprintf("call-%s-%d", rs.manager.LocalNode().ID(), counter)
 }
 
+// IsRetryable returns true if a remote call error may succeed when retried
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrReadOnly) || errors.Is(err, ErrThrottled)
+}
+
+// remoteCallError maps a remote error code back to a local error
+func remoteCallError(code, message string) error {
+	switch code {
+	case ErrorCodeReadOnly:
+		return wrapRemoteCallError(ErrReadOnly, message)
+	case ErrorCodeThrottled:
+		return wrapRemoteCallError(ErrThrottled, message)
+	default:
+		return errors.New(message)
+	}
+}
+
+// wrapRemoteCallError wraps sentinel around message, which already starts
+// with the sentinel text when the remote side wrapped it too
+func wrapRemoteCallError(sentinel error, message string) error {
+	detail := strings.TrimPrefix(strings.TrimPrefix(message, sentinel.Error()), ": ")
+	if detail == "" {
+		return sentinel
+	}
+	return fmt.Errorf("%w: %s", sentinel, detail)
+}
+
+// remoteCallErrorCode returns the wire error code for err
+func remoteCallErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrReadOnly):
+		return ErrorCodeReadOnly
+	case errors.Is(err, ErrThrottled):
+		return ErrorCodeThrottled
+	default:
+		return ""
+	}
+}
+
 // serviceRegistry implements the ServiceRegistry interface
 type serviceRegistry struct {
 	manager   ClusterManager
 	transport MessageTransport
 
 	services   map[string][]ServiceInstance
+	indexes    map[string]*labelIndex
 	servicesMu sync.RWMutex
 
 	watchers   map[string][]chan ServiceEvent
@@ -393,11 +675,20 @@ func NewServiceRegistry(manager ClusterManager) ServiceRegistry {
 	return &serviceRegistry{
 		manager:  manager,
 		services: make(map[string][]ServiceInstance),
+		indexes:  make(map[string]*labelIndex),
 		watchers: make(map[string][]chan ServiceEvent),
 	}
 }
 
 func (sr *serviceRegistry) RegisterService(ctx context.Context, serviceID string, metadata map[string]string) error {
+	return sr.RegisterServiceWithLabels(ctx, serviceID, metadata, nil)
+}
+
+func (sr *serviceRegistry) RegisterServiceWithLabels(ctx context.Context, serviceID string, metadata, labels map[string]string) error {
+	if err := refuseOnWitness(sr.manager); err != nil {
+		return err
+	}
+
 	localNode := sr.manager.LocalNode()
 
 	instance := ServiceInstance{
@@ -405,34 +696,13 @@ func (sr *serviceRegistry) RegisterService(ctx context.Context, serviceID string
 		NodeID:       localNode.ID(),
 		Address:      localNode.Address().String(),
 		Metadata:     metadata,
+		Labels:       labels,
 		Health:       ServiceHealthHealthy,
 		RegisteredAt: time.Now(),
 		LastSeen:     time.Now(),
 	}
 
-	sr.servicesMu.Lock()
-	if instances, exists := sr.services[serviceID]; exists {
-		// Check if already registered
-		for i, existing := range instances {
-			if existing.NodeID == localNode.ID() {
-				// Update existing
-				sr.services[serviceID][i] = instance
-				sr.servicesMu.Unlock()
-				sr.notifyWatchers(serviceID, ServiceEvent{
-					Type:      ServiceEventRegistered,
-					ServiceID: serviceID,
-					Instance:  instance,
-					Timestamp: time.Now(),
-				})
-				return nil
-			}
-		}
-		// Add new
-		sr.services[serviceID] = append(instances, instance)
-	} else {
-		sr.services[serviceID] = []ServiceInstance{instance}
-	}
-	sr.servicesMu.Unlock()
+	sr.upsertInstance(instance)
 
 	// Notify watchers
 	sr.notifyWatchers(serviceID, ServiceEvent{
@@ -441,6 +711,7 @@ func (sr *serviceRegistry) RegisterService(ctx context.Context, serviceID string
 		Instance:  instance,
 		Timestamp: time.Now(),
 	})
+	sr.publishServiceEvent(EventServiceRegistered, instance)
 
 	// TODO: Broadcast registration to cluster
 
@@ -471,8 +742,10 @@ func (sr *serviceRegistry) UnregisterService(ctx context.Context, serviceID stri
 
 	if len(newInstances) == 0 {
 		delete(sr.services, serviceID)
+		delete(sr.indexes, serviceID)
 	} else {
 		sr.services[serviceID] = newInstances
+		sr.indexes[serviceID].remove(localNode.ID())
 	}
 
 	// Notify watchers
@@ -483,6 +756,7 @@ func (sr *serviceRegistry) UnregisterService(ctx context.Context, serviceID stri
 			Instance:  removedInstance,
 			Timestamp: time.Now(),
 		})
+		sr.publishServiceEvent(EventServiceUnregistered, removedInstance)
 	}
 
 	// TODO: Broadcast unregistration to cluster
@@ -505,6 +779,39 @@ func (sr *serviceRegistry) DiscoverService(ctx context.Context, serviceID string
 	return result, nil
 }
 
+func (sr *serviceRegistry) DiscoverServiceSelector(ctx context.Context, serviceID string, selector Selector) ([]ServiceInstance, error) {
+	sr.servicesMu.RLock()
+	defer sr.servicesMu.RUnlock()
+
+	index, exists := sr.indexes[serviceID]
+	if !exists {
+		return []ServiceInstance{}, nil
+	}
+	return index.selectInstances(selector), nil
+}
+
+// upsertInstance adds an instance or replaces the one on the same node
+func (sr *serviceRegistry) upsertInstance(instance ServiceInstance) {
+	sr.servicesMu.Lock()
+	defer sr.servicesMu.Unlock()
+
+	index, exists := sr.indexes[instance.ServiceID]
+	if !exists {
+		index = newLabelIndex()
+		sr.indexes[instance.ServiceID] = index
+	}
+	index.add(instance)
+
+	instances := sr.services[instance.ServiceID]
+	for i, existing := range instances {
+		if existing.NodeID == instance.NodeID {
+			instances[i] = instance
+			return
+		}
+	}
+	sr.services[instance.ServiceID] = append(instances, instance)
+}
+
 func (sr *serviceRegistry) Watch(ctx context.Context, serviceID string) (<-chan ServiceEvent, error) {
 	ch := make(chan ServiceEvent, 100)
 
@@ -540,6 +847,21 @@ func (sr *serviceRegistry) GetAllServices() map[string][]ServiceInstance {
 	return result
 }
 
+// publishServiceEvent announces a registration change of the local node
+// to cluster event subscribers
+func (sr *serviceRegistry) publishServiceEvent(eventType ClusterEventType, instance ServiceInstance) {
+	cm, ok := sr.manager.(*clusterManager)
+	if !ok {
+		return
+	}
+	cm.publishEvent(ClusterEvent{
+		Type:      eventType,
+		NodeID:    instance.NodeID,
+		Timestamp: time.Now(),
+		Payload:   ServiceChanged{Instance: instance},
+	})
+}
+
 func (sr *serviceRegistry) notifyWatchers(serviceID string, event ServiceEvent) {
 	sr.watchersMu.RLock()
 	defer sr.watchersMu.RUnlock()
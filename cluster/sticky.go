@@ -0,0 +1,216 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoInstances is returned when a service has no instance to call
+	ErrNoInstances = errors.New("no service instances available")
+
+	// ErrSendFailed is returned when a remote call could not be sent
+	ErrSendFailed = errors.New("failed to send remote call")
+)
+
+// DefaultStickyFailureCooldown is how long an instance that failed a
+// sticky call is passed over when keys are re-pinned
+const DefaultStickyFailureCooldown = 30 * time.Second
+
+// StickyPin is the instance a stickiness key is routed to
+type StickyPin struct {
+	ServiceID string    `json:"service_id"`
+	Key       string    `json:"key"`
+	NodeID    NodeID    `json:"node_id"`
+	PinnedAt  time.Time `json:"pinned_at"`
+	Repins    int       `json:"repins"`
+}
+
+// stickyKey identifies a pin
+type stickyKey struct {
+	service string
+	key     string
+}
+
+// StickySessions routes calls carrying the same key, such as a player ID,
+// to the same instance of a service while it stays registered, so the
+// instance can keep per-key caches. Keys are first placed by rendezvous
+// hashing, spreading them evenly and moving few when instances come and
+// go; a key is re-pinned only when its instance leaves or fails a call.
+type StickySessions struct {
+	cooldown time.Duration
+
+	mu     sync.Mutex
+	pins   map[stickyKey]*StickyPin
+	failed map[NodeID]time.Time
+}
+
+// NewStickySessions creates an empty pin table; failed instances are
+// avoided for cooldown, 0 using DefaultStickyFailureCooldown
+func NewStickySessions(cooldown time.Duration) *StickySessions {
+	if cooldown <= 0 {
+		cooldown = DefaultStickyFailureCooldown
+	}
+	return &StickySessions{
+		cooldown: cooldown,
+		pins:     make(map[stickyKey]*StickyPin),
+		failed:   make(map[NodeID]time.Time),
+	}
+}
+
+// Pick returns the instance among refs a key is routed to, pinning it
+func (s *StickySessions) Pick(serviceID, key string, refs []RemoteActorRef) (RemoteActorRef, error) {
+	if len(refs) == 0 {
+		return RemoteActorRef{}, fmt.Errorf("%w: %s", ErrNoInstances, serviceID)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pin := s.pins[stickyKey{service: serviceID, key: key}]
+	if pin != nil {
+		for _, ref := range refs {
+			if ref.NodeID == pin.NodeID {
+				return ref, nil
+			}
+		}
+	}
+
+	// Prefer instances that have not failed recently
+	candidates := make([]RemoteActorRef, 0, len(refs))
+	for _, ref := range refs {
+		if failedAt, failed := s.failed[ref.NodeID]; !failed || now.Sub(failedAt) >= s.cooldown {
+			candidates = append(candidates, ref)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = refs
+	}
+
+	chosen := rendezvous(key, candidates)
+	if pin == nil {
+		pin = &StickyPin{ServiceID: serviceID, Key: key}
+		s.pins[stickyKey{service: serviceID, key: key}] = pin
+	} else {
+		pin.Repins++
+	}
+	pin.NodeID = chosen.NodeID
+	pin.PinnedAt = now
+	return chosen, nil
+}
+
+// Fail re-pins a key whose instance failed a call; the instance is passed
+// over by re-pinned keys for the cooldown
+func (s *StickySessions) Fail(serviceID, key string, nodeID NodeID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failed[nodeID] = time.Now()
+	if pin := s.pins[stickyKey{service: serviceID, key: key}]; pin != nil && pin.NodeID == nodeID {
+		pin.NodeID = ""
+	}
+}
+
+// Pins returns the pins of a service, or of all services if serviceID is
+// empty, ordered by service and key
+func (s *StickySessions) Pins(serviceID string) []StickyPin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pins []StickyPin
+	for k, pin := range s.pins {
+		if (serviceID == "" || k.service == serviceID) && pin.NodeID != "" {
+			pins = append(pins, *pin)
+		}
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		if pins[i].ServiceID != pins[j].ServiceID {
+			return pins[i].ServiceID < pins[j].ServiceID
+		}
+		return pins[i].Key < pins[j].Key
+	})
+	return pins
+}
+
+// Clear drops the pin of a key, e.g. after its player logged out; an empty
+// key clears the whole service
+func (s *StickySessions) Clear(serviceID, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k := range s.pins {
+		if k.service == serviceID && (key == "" || k.key == key) {
+			delete(s.pins, k)
+		}
+	}
+}
+
+// unpinNode re-pins the keys of a lost node on their next call
+func (s *StickySessions) unpinNode(nodeID NodeID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pin := range s.pins {
+		if pin.NodeID == nodeID {
+			pin.NodeID = ""
+		}
+	}
+	delete(s.failed, nodeID)
+}
+
+// rendezvous returns the ref with the highest hash weight for key
+func rendezvous(key string, refs []RemoteActorRef) RemoteActorRef {
+	var best RemoteActorRef
+	var bestWeight uint64
+	for i, ref := range refs {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(ref.NodeID))
+		if weight := h.Sum64(); i == 0 || weight > bestWeight {
+			best, bestWeight = ref, weight
+		}
+	}
+	return best
+}
+
+// isInstanceFailure reports whether a call failed because its instance was
+// unreachable rather than because the handler returned an error
+func isInstanceFailure(err error) bool {
+	return errors.Is(err, ErrNodeLost) || errors.Is(err, ErrPeerReconnecting) || errors.Is(err, ErrSendFailed)
+}
+
+// CallSticky calls the instance of a service that key is pinned to. If the
+// instance is unreachable the key is re-pinned and the call retried once.
+func (rs *remoteService) CallSticky(ctx context.Context, serviceID, key string, message interface{}) (interface{}, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		refs, err := rs.Resolve(ctx, serviceID)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := rs.sticky.Pick(serviceID, key, refs)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := rs.Call(ctx, ref, message)
+		if err == nil || !isInstanceFailure(err) {
+			return result, err
+		}
+		rs.sticky.Fail(serviceID, key, ref.NodeID)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// StickySessions returns the pins used by CallSticky
+func (rs *remoteService) StickySessions() *StickySessions {
+	return rs.sticky
+}
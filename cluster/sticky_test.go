@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func stickyRefs(nodes ...NodeID) []RemoteActorRef {
+	refs := make([]RemoteActorRef, len(nodes))
+	for i, node := range nodes {
+		refs[i] = RemoteActorRef{NodeID: node, ActorID: "inventory"}
+	}
+	return refs
+}
+
+func TestStickySessionsPinning(t *testing.T) {
+	s := NewStickySessions(0)
+	refs := stickyRefs("a", "b", "c")
+
+	perNode := make(map[NodeID]int)
+	for i := 0; i < 300; i++ {
+		ref, _ := s.Pick("inventory", fmt.Sprintf("player-%d", i), refs)
+		perNode[ref.NodeID]++
+	}
+	for node, n := range perNode {
+		if n < 50 {
+			t.Errorf("Expected keys spread over instances, %s got %d of 300", node, n)
+		}
+	}
+
+	// A pinned key stays put when an instance joins
+	first, _ := s.Pick("inventory", "player-1", refs)
+	again, _ := s.Pick("inventory", "player-1", stickyRefs("d", "c", "b", "a"))
+	if again.NodeID != first.NodeID {
+		t.Errorf("Expected player-1 to stay on %s, moved to %s", first.NodeID, again.NodeID)
+	}
+
+	// and moves once its instance is gone
+	var rest []NodeID
+	for _, ref := range refs {
+		if ref.NodeID != first.NodeID {
+			rest = append(rest, ref.NodeID)
+		}
+	}
+	moved, _ := s.Pick("inventory", "player-1", stickyRefs(rest...))
+	if moved.NodeID == first.NodeID {
+		t.Fatal("Expected player-1 to be re-pinned")
+	}
+	if pins := s.Pins("inventory"); len(pins) != 300 {
+		t.Errorf("Expected 300 pins, got %d", len(pins))
+	}
+
+	s.Clear("inventory", "player-1")
+	s.Clear("inventory", "player-2")
+	if pins := s.Pins(""); len(pins) != 298 {
+		t.Errorf("Expected 298 pins after clearing two, got %d", len(pins))
+	}
+	if _, err := s.Pick("inventory", "player-1", nil); !errors.Is(err, ErrNoInstances) {
+		t.Errorf("Expected ErrNoInstances, got %v", err)
+	}
+}
+
+func TestStickySessionsFailover(t *testing.T) {
+	s := NewStickySessions(0)
+	refs := stickyRefs("a", "b", "c")
+
+	pinned, _ := s.Pick("inventory", "player-7", refs)
+	s.Fail("inventory", "player-7", pinned.NodeID)
+
+	// The failed instance is still registered but passed over
+	repinned, _ := s.Pick("inventory", "player-7", refs)
+	if repinned.NodeID == pinned.NodeID {
+		t.Fatalf("Expected player-7 to leave failed instance %s", pinned.NodeID)
+	}
+	pins := s.Pins("inventory")
+	if len(pins) != 1 || pins[0].NodeID != repinned.NodeID || pins[0].Repins != 1 {
+		t.Errorf("Unexpected pins %+v", pins)
+	}
+}
+
+// stickyTransport answers calls with the ID of the called node, and fails
+// sends to down
+type stickyTransport struct {
+	MessageTransport
+	rs   *remoteService
+	down NodeID
+}
+
+func (st *stickyTransport) Send(ctx context.Context, nodeID NodeID, message *ClusterMessage) error {
+	if nodeID == st.down {
+		return errors.New("connection refused")
+	}
+	var request RemoteCallRequest
+	json.Unmarshal(message.Payload, &request)
+	payload, _ := json.Marshal(RemoteCallResponse{CallID: request.CallID, Result: string(nodeID)})
+	go st.rs.HandleMessage(ctx, nodeID, &ClusterMessage{Type: MessageTypeActorReply, Payload: payload})
+	return nil
+}
+
+func TestCallStickyRepinsUnreachableInstance(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "local"
+	manager := NewClusterManager(config).(*clusterManager)
+
+	rs := NewRemoteService(manager).(*remoteService)
+	registry := NewServiceRegistry(manager).(*serviceRegistry)
+	rs.registry = registry
+	registry.upsertInstance(ServiceInstance{ServiceID: "inventory", NodeID: "a"})
+	registry.upsertInstance(ServiceInstance{ServiceID: "inventory", NodeID: "b"})
+
+	transport := &stickyTransport{rs: rs}
+	rs.transport = transport
+
+	ctx := context.Background()
+	first, err := rs.CallSticky(ctx, "inventory", "player-1", "get")
+	if err != nil {
+		t.Fatalf("Failed to call: %v", err)
+	}
+	if again, _ := rs.CallSticky(ctx, "inventory", "player-1", "get"); again != first {
+		t.Errorf("Expected player-1 to stay on %v, got %v", first, again)
+	}
+
+	transport.down = NodeID(first.(string))
+	moved, err := rs.CallSticky(ctx, "inventory", "player-1", "get")
+	if err != nil || moved == first {
+		t.Fatalf("Expected the call to fail over from %v, got %v (%v)", first, moved, err)
+	}
+	if pins := rs.StickySessions().Pins("inventory"); len(pins) != 1 || string(pins[0].NodeID) != moved {
+		t.Errorf("Expected player-1 pinned to %v, got %+v", moved, pins)
+	}
+
+	// Losing the node re-pins its keys
+	rs.SweepNode(NodeID(moved.(string)))
+	if pins := rs.StickySessions().Pins("inventory"); len(pins) != 0 {
+		t.Errorf("Expected no pins after the node was lost, got %+v", pins)
+	}
+}
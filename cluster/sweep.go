@@ -0,0 +1,283 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNodeLost is returned for calls to a node that failed or left, and for
+// references resolved before it did
+var ErrNodeLost = errors.New("remote node lost")
+
+// Terminated is delivered to actor watches when the watched actor's node
+// is lost
+type Terminated struct {
+	Ref       RemoteActorRef `json:"ref"`
+	Reason    string         `json:"reason"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// NodeSweep reports what a single node-death sweep cleaned up
+type NodeSweep struct {
+	NodeID            NodeID        `json:"node_id"`
+	Epoch             uint64        `json:"epoch"`
+	CallsFailed       int           `json:"calls_failed"`
+	WatchesTerminated int           `json:"watches_terminated"`
+	InstancesRemoved  int           `json:"instances_removed"`
+	Duration          time.Duration `json:"duration"`
+}
+
+// SweepStats aggregates the node-death sweeps of a remote service
+type SweepStats struct {
+	Sweeps            int64 `json:"sweeps"`
+	CallsFailed       int64 `json:"calls_failed"`
+	WatchesTerminated int64 `json:"watches_terminated"`
+	InstancesRemoved  int64 `json:"instances_removed"`
+
+	// StaleRefsRejected counts calls and sends refused because their
+	// reference predates the loss of its node
+	StaleRefsRejected int64 `json:"stale_refs_rejected"`
+}
+
+// actorWatch is a single WatchActor registration
+type actorWatch struct {
+	ref  RemoteActorRef
+	ch   chan Terminated
+	once sync.Once
+}
+
+// fire delivers the termination and closes the channel
+func (w *actorWatch) fire(event Terminated) {
+	w.once.Do(func() {
+		w.ch <- event
+		close(w.ch)
+	})
+}
+
+// nodeSweeper tracks the per-node state a node-death sweep invalidates
+type nodeSweeper struct {
+	mu      sync.Mutex
+	epochs  map[NodeID]uint64 // incarnation of each node when it was last swept
+	watches map[NodeID]map[*actorWatch]struct{}
+
+	// incarnation returns the boot epoch of a member, and false for a node
+	// that is not a member
+	incarnation func(NodeID) (uint64, bool)
+
+	sweeps            int64 // atomic
+	callsFailed       int64 // atomic
+	watchesTerminated int64 // atomic
+	instancesRemoved  int64 // atomic
+	staleRefs         int64 // atomic
+}
+
+// newNodeSweeper creates an empty sweeper
+func newNodeSweeper(incarnation func(NodeID) (uint64, bool)) *nodeSweeper {
+	return &nodeSweeper{
+		epochs:      make(map[NodeID]uint64),
+		watches:     make(map[NodeID]map[*actorWatch]struct{}),
+		incarnation: incarnation,
+	}
+}
+
+// epoch returns the incarnation references to a node are resolved at, 0
+// if it is unknown
+func (s *nodeSweeper) epoch(nodeID NodeID) uint64 {
+	epoch, _ := s.incarnation(nodeID)
+	return epoch
+}
+
+// checkRef rejects references resolved before their node was lost
+func (s *nodeSweeper) checkRef(ref RemoteActorRef) error {
+	s.mu.Lock()
+	err := s.staleLocked(ref)
+	s.mu.Unlock()
+
+	if err != nil {
+		atomic.AddInt64(&s.staleRefs, 1)
+	}
+	return err
+}
+
+// staleLocked returns ErrNodeLost for a reference to an incarnation that
+// was swept, or to a node that is no longer a member. References without
+// an epoch are not checked. s.mu must be held.
+func (s *nodeSweeper) staleLocked(ref RemoteActorRef) error {
+	if ref.Epoch == 0 {
+		return nil
+	}
+	if swept, exists := s.epochs[ref.NodeID]; exists && ref.Epoch <= swept {
+		return fmt.Errorf("%w: %s/%s resolved at incarnation %d, lost at incarnation %d",
+			ErrNodeLost, ref.NodeID, ref.ActorID, ref.Epoch, swept)
+	}
+	if _, member := s.incarnation(ref.NodeID); !member {
+		return fmt.Errorf("%w: %s/%s, %s is no longer a member", ErrNodeLost, ref.NodeID, ref.ActorID, ref.NodeID)
+	}
+	return nil
+}
+
+// incarnation returns the boot epoch of a node known to the manager
+func (rs *remoteService) incarnation(nodeID NodeID) (uint64, bool) {
+	if rs.manager == nil {
+		return 0, true
+	}
+	if local := rs.manager.LocalNode(); local != nil && local.ID() == nodeID {
+		return uint64(local.Info().BootEpoch), true
+	}
+	node, exists := rs.manager.GetNode(nodeID)
+	if !exists {
+		return 0, false
+	}
+	return uint64(node.Info().BootEpoch), true
+}
+
+// handleNodeEvent sweeps nodes that failed or left the cluster
+func (rs *remoteService) handleNodeEvent(event ClusterEvent) {
+	switch event.Type {
+	case EventNodeFailed, EventNodeLeft:
+		rs.SweepNode(event.NodeID)
+	}
+}
+
+// SweepNode fails every pending call to a lost node, fires Terminated for
+// its actor watches, invalidates the references resolved before the loss
+// and drops its service instances. It runs automatically when a node
+// fails or leaves
+func (rs *remoteService) SweepNode(nodeID NodeID) NodeSweep {
+	start := time.Now()
+	s := rs.sweeper
+
+	incarnation := s.epoch(nodeID)
+	s.mu.Lock()
+	if incarnation > s.epochs[nodeID] {
+		s.epochs[nodeID] = incarnation
+	}
+	result := NodeSweep{NodeID: nodeID, Epoch: incarnation}
+	watches := s.watches[nodeID]
+	delete(s.watches, nodeID)
+	s.mu.Unlock()
+
+	reason := fmt.Errorf("%w: %s", ErrNodeLost, nodeID)
+
+	rs.callsMu.RLock()
+	for _, pending := range rs.pendingCalls {
+		if pending.node != nodeID {
+			continue
+		}
+		select {
+		case pending.error <- reason:
+			result.CallsFailed++
+		default:
+			// Already completed
+		}
+	}
+	rs.callsMu.RUnlock()
+
+	for watch := range watches {
+		watch.fire(Terminated{Ref: watch.ref, Reason: reason.Error(), Timestamp: start})
+		result.WatchesTerminated++
+	}
+
+	if registry, ok := rs.registry.(*serviceRegistry); ok {
+		result.InstancesRemoved = registry.removeNode(nodeID)
+	}
+	rs.sticky.unpinNode(nodeID)
+
+	result.Duration = time.Since(start)
+
+	atomic.AddInt64(&s.sweeps, 1)
+	atomic.AddInt64(&s.callsFailed, int64(result.CallsFailed))
+	atomic.AddInt64(&s.watchesTerminated, int64(result.WatchesTerminated))
+	atomic.AddInt64(&s.instancesRemoved, int64(result.InstancesRemoved))
+
+	return result
+}
+
+// WatchActor returns a channel receiving a single Terminated event when the
+// node of ref is lost; stale references are terminated immediately. The
+// returned function cancels the watch
+func (rs *remoteService) WatchActor(ref RemoteActorRef) (<-chan Terminated, func()) {
+	watch := &actorWatch{ref: ref, ch: make(chan Terminated, 1)}
+	s := rs.sweeper
+
+	s.mu.Lock()
+	if err := s.staleLocked(ref); err != nil {
+		s.mu.Unlock()
+		atomic.AddInt64(&s.watchesTerminated, 1)
+		watch.fire(Terminated{
+			Ref:       ref,
+			Reason:    err.Error(),
+			Timestamp: time.Now(),
+		})
+		return watch.ch, func() {}
+	}
+	if s.watches[ref.NodeID] == nil {
+		s.watches[ref.NodeID] = make(map[*actorWatch]struct{})
+	}
+	s.watches[ref.NodeID][watch] = struct{}{}
+	s.mu.Unlock()
+
+	cancel := func() {
+		s.mu.Lock()
+		delete(s.watches[ref.NodeID], watch)
+		if len(s.watches[ref.NodeID]) == 0 {
+			delete(s.watches, ref.NodeID)
+		}
+		s.mu.Unlock()
+	}
+	return watch.ch, cancel
+}
+
+// SweepStats returns the node-death sweep counters
+func (rs *remoteService) SweepStats() SweepStats {
+	s := rs.sweeper
+	return SweepStats{
+		Sweeps:            atomic.LoadInt64(&s.sweeps),
+		CallsFailed:       atomic.LoadInt64(&s.callsFailed),
+		WatchesTerminated: atomic.LoadInt64(&s.watchesTerminated),
+		InstancesRemoved:  atomic.LoadInt64(&s.instancesRemoved),
+		StaleRefsRejected: atomic.LoadInt64(&s.staleRefs),
+	}
+}
+
+// removeNode drops every instance hosted on a node, notifying watchers,
+// and returns how many were removed
+func (sr *serviceRegistry) removeNode(nodeID NodeID) int {
+	var removed []ServiceInstance
+
+	sr.servicesMu.Lock()
+	for serviceID, instances := range sr.services {
+		kept := instances[:0]
+		for _, instance := range instances {
+			if instance.NodeID == nodeID {
+				removed = append(removed, instance)
+			} else {
+				kept = append(kept, instance)
+			}
+		}
+		if len(kept) == len(instances) {
+			continue
+		}
+		if len(kept) == 0 {
+			delete(sr.services, serviceID)
+			delete(sr.indexes, serviceID)
+		} else {
+			sr.services[serviceID] = kept
+			sr.indexes[serviceID].remove(nodeID)
+		}
+	}
+	sr.servicesMu.Unlock()
+
+	for _, instance := range removed {
+		sr.notifyWatchers(instance.ServiceID, ServiceEvent{
+			Type:      ServiceEventUnregistered,
+			ServiceID: instance.ServiceID,
+			Instance:  instance,
+			Timestamp: time.Now(),
+		})
+	}
+	return len(removed)
+}
@@ -0,0 +1,142 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blackholeTransport accepts every message and never replies
+type blackholeTransport struct {
+	MessageTransport
+}
+
+func (blackholeTransport) Send(ctx context.Context, nodeID NodeID, message *ClusterMessage) error {
+	return nil
+}
+
+// TestNodeDeathSweep tests that losing a node fails its calls and watches
+// and invalidates references resolved before the loss
+func TestNodeDeathSweep(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "local"
+	manager := NewClusterManager(config).(*clusterManager)
+
+	rs := NewRemoteService(manager).(*remoteService)
+	rs.transport = blackholeTransport{}
+	registry := NewServiceRegistry(manager).(*serviceRegistry)
+	rs.registry = registry
+
+	manager.addNode(NewRemoteNode(&NodeInfo{ID: "node-x", State: NodeStateActive, BootEpoch: 100}))
+	manager.addNode(NewRemoteNode(&NodeInfo{ID: "node-y", State: NodeStateActive, BootEpoch: 200}))
+	registry.upsertInstance(ServiceInstance{ServiceID: "players", NodeID: "node-x"})
+	registry.upsertInstance(ServiceInstance{ServiceID: "chat", NodeID: "node-x"})
+	registry.upsertInstance(ServiceInstance{ServiceID: "players", NodeID: "node-y"})
+
+	ctx := context.Background()
+	refs, err := rs.Resolve(ctx, "players")
+	if err != nil || len(refs) != 2 {
+		t.Fatalf("Failed to resolve: %v (%d refs)", err, len(refs))
+	}
+	lost, alive := refs[0], refs[1]
+	if lost.Epoch != 100 || alive.Epoch != 200 {
+		t.Fatalf("Expected refs resolved at the boot epochs of their nodes, got %+v", refs)
+	}
+
+	events, err := registry.Watch(ctx, "players")
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	calls := make(chan error, 3)
+	for _, ref := range []RemoteActorRef{lost, lost, alive} {
+		go func(ref RemoteActorRef) {
+			callCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			_, err := rs.Call(callCtx, ref, "ping")
+			calls <- err
+		}(ref)
+	}
+	terminated, _ := rs.WatchActor(lost)
+	survivor, cancelSurvivor := rs.WatchActor(alive)
+	defer cancelSurvivor()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		rs.callsMu.RLock()
+		n := len(rs.pendingCalls)
+		rs.callsMu.RUnlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	manager.publishEvent(ClusterEvent{Type: EventNodeFailed, NodeID: "node-x", Timestamp: time.Now()})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-calls:
+			if !errors.Is(err, ErrNodeLost) {
+				t.Errorf("Expected ErrNodeLost, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected pending calls to fail promptly")
+		}
+	}
+
+	select {
+	case event := <-terminated:
+		if event.Ref != lost {
+			t.Errorf("Unexpected terminated ref %+v", event.Ref)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Terminated for the lost node")
+	}
+	select {
+	case event := <-survivor:
+		t.Errorf("Unexpected termination of %+v", event.Ref)
+	default:
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != ServiceEventUnregistered || event.Instance.NodeID != "node-x" {
+			t.Errorf("Unexpected registry event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the lost instance to be unregistered")
+	}
+
+	stats := rs.SweepStats()
+	if stats.Sweeps != 1 || stats.CallsFailed != 2 || stats.WatchesTerminated != 1 || stats.InstancesRemoved != 2 {
+		t.Errorf("Unexpected sweep stats %+v", stats)
+	}
+
+	// The old reference stays invalid after the node rejoins; resolving
+	// again yields a usable one
+	if err := rs.Send(ctx, lost, "hello"); !errors.Is(err, ErrNodeLost) {
+		t.Errorf("Expected stale reference to be rejected, got %v", err)
+	}
+	if ch, _ := rs.WatchActor(lost); len(ch) != 1 {
+		t.Error("Expected watching a stale reference to terminate immediately")
+	}
+
+	// A reference built by hand carries no epoch and is not checked
+	if err := rs.Send(ctx, RemoteActorRef{NodeID: "node-x", ActorID: "players"}, "hello"); err != nil {
+		t.Errorf("Expected a reference without epoch accepted, got %v", err)
+	}
+
+	manager.addNode(NewRemoteNode(&NodeInfo{ID: "node-x", State: NodeStateActive, BootEpoch: 300}))
+	registry.upsertInstance(ServiceInstance{ServiceID: "players", NodeID: "node-x"})
+	refs, _ = rs.Resolve(ctx, "players")
+	for _, ref := range refs {
+		if err := rs.Send(ctx, ref, "hello"); err != nil {
+			t.Errorf("Expected fresh reference to %s to work, got %v", ref.NodeID, err)
+		}
+	}
+	if got := rs.SweepStats().StaleRefsRejected; got != 1 {
+		t.Errorf("Expected 1 stale reference rejected, got %d", got)
+	}
+}
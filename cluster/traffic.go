@@ -0,0 +1,226 @@
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Traffic accounting keys
+const (
+	// MetadataZone is the node metadata key holding the availability zone
+	MetadataZone = "zone"
+
+	// HeaderZone carries the sender's zone in the join handshake
+	HeaderZone = "zone"
+
+	// HeaderService names the service a message is accounted to
+	HeaderService = "service"
+)
+
+// unknownZone is reported for peers that did not announce a zone
+const unknownZone = "unknown"
+
+// bytesPerGB converts byte counters to the unit costs are quoted in
+const bytesPerGB = 1 << 30
+
+// TrafficProvider is implemented by transports that account traffic by zone
+type TrafficProvider interface {
+	// Traffic returns the zone traffic accounting
+	Traffic() *TrafficAccounting
+}
+
+// trafficKey identifies one flow
+type trafficKey struct {
+	service string
+	src     string
+	dst     string
+}
+
+// trafficCounter holds the counters of one flow
+type trafficCounter struct {
+	messages int64
+	bytes    int64
+}
+
+// ZoneFlow reports the traffic of one service between two zones
+type ZoneFlow struct {
+	Service       string  `json:"service"`
+	SourceZone    string  `json:"source_zone"`
+	DestZone      string  `json:"dest_zone"`
+	Messages      int64   `json:"messages"`
+	Bytes         int64   `json:"bytes"`
+	CrossZone     bool    `json:"cross_zone"`
+	EstimatedCost float64 `json:"estimated_cost,omitempty"`
+}
+
+// ServiceTraffic aggregates the traffic of one service
+type ServiceTraffic struct {
+	Service        string  `json:"service"`
+	CrossZoneBytes int64   `json:"cross_zone_bytes"`
+	SameZoneBytes  int64   `json:"same_zone_bytes"`
+	EstimatedCost  float64 `json:"estimated_cost,omitempty"`
+}
+
+// TrafficReport summarizes zone traffic, most expensive services first
+type TrafficReport struct {
+	LocalZone      string           `json:"local_zone"`
+	CrossZoneBytes int64            `json:"cross_zone_bytes"`
+	SameZoneBytes  int64            `json:"same_zone_bytes"`
+	EstimatedCost  float64          `json:"estimated_cost,omitempty"`
+	Services       []ServiceTraffic `json:"services"`
+	Flows          []ZoneFlow       `json:"flows"`
+}
+
+// TrafficAccounting aggregates the bytes a node exchanges with its peers by
+// service and source/destination zone, so inter-zone traffic can be priced
+type TrafficAccounting struct {
+	mu        sync.Mutex
+	localZone string
+	costPerGB float64
+	flows     map[trafficKey]*trafficCounter
+}
+
+// NewTrafficAccounting creates accounting for a node in localZone
+func NewTrafficAccounting(localZone string) *TrafficAccounting {
+	return &TrafficAccounting{
+		localZone: localZone,
+		flows:     make(map[trafficKey]*trafficCounter),
+	}
+}
+
+// LocalZone returns the zone of the local node
+func (ta *TrafficAccounting) LocalZone() string {
+	return ta.localZone
+}
+
+// SetCostPerGB sets the price of one GB of cross-zone traffic
+func (ta *TrafficAccounting) SetCostPerGB(cost float64) {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	ta.costPerGB = cost
+}
+
+// Record accounts bytes of service traffic flowing from src to dst zone
+func (ta *TrafficAccounting) Record(service, src, dst string, bytes int64) {
+	if src == "" {
+		src = unknownZone
+	}
+	if dst == "" {
+		dst = unknownZone
+	}
+
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	key := trafficKey{service: service, src: src, dst: dst}
+	counter, exists := ta.flows[key]
+	if !exists {
+		counter = &trafficCounter{}
+		ta.flows[key] = counter
+	}
+	counter.messages++
+	counter.bytes += bytes
+}
+
+// RecordSent accounts a message sent to a peer in peerZone
+func (ta *TrafficAccounting) RecordSent(service, peerZone string, bytes int64) {
+	ta.Record(service, ta.localZone, peerZone, bytes)
+}
+
+// RecordReceived accounts a message received from a peer in peerZone
+func (ta *TrafficAccounting) RecordReceived(service, peerZone string, bytes int64) {
+	ta.Record(service, peerZone, ta.localZone, bytes)
+}
+
+// Report returns the accounted traffic
+func (ta *TrafficAccounting) Report() TrafficReport {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	report := TrafficReport{LocalZone: ta.localZone}
+	services := make(map[string]*ServiceTraffic)
+
+	for key, counter := range ta.flows {
+		flow := ZoneFlow{
+			Service:    key.service,
+			SourceZone: key.src,
+			DestZone:   key.dst,
+			Messages:   counter.messages,
+			Bytes:      counter.bytes,
+			CrossZone:  isCrossZone(key.src, key.dst),
+		}
+
+		service, exists := services[key.service]
+		if !exists {
+			service = &ServiceTraffic{Service: key.service}
+			services[key.service] = service
+		}
+
+		if flow.CrossZone {
+			flow.EstimatedCost = ta.cost(flow.Bytes)
+			service.CrossZoneBytes += flow.Bytes
+			service.EstimatedCost += flow.EstimatedCost
+			report.CrossZoneBytes += flow.Bytes
+			report.EstimatedCost += flow.EstimatedCost
+		} else {
+			service.SameZoneBytes += flow.Bytes
+			report.SameZoneBytes += flow.Bytes
+		}
+		report.Flows = append(report.Flows, flow)
+	}
+
+	for _, service := range services {
+		report.Services = append(report.Services, *service)
+	}
+
+	sort.Slice(report.Services, func(i, j int) bool {
+		if report.Services[i].CrossZoneBytes != report.Services[j].CrossZoneBytes {
+			return report.Services[i].CrossZoneBytes > report.Services[j].CrossZoneBytes
+		}
+		return report.Services[i].Service < report.Services[j].Service
+	})
+	sort.Slice(report.Flows, func(i, j int) bool {
+		if report.Flows[i].CrossZone != report.Flows[j].CrossZone {
+			return report.Flows[i].CrossZone
+		}
+		return report.Flows[i].Bytes > report.Flows[j].Bytes
+	})
+
+	return report
+}
+
+// Reset clears all counters
+func (ta *TrafficAccounting) Reset() {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	ta.flows = make(map[trafficKey]*trafficCounter)
+}
+
+// ServeHTTP writes the traffic report as JSON
+func (ta *TrafficAccounting) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ta.Report())
+}
+
+// cost prices bytes of cross-zone traffic; ta.mu must be held
+func (ta *TrafficAccounting) cost(bytes int64) float64 {
+	return float64(bytes) / bytesPerGB * ta.costPerGB
+}
+
+// isCrossZone returns true if both zones are known and differ
+func isCrossZone(src, dst string) bool {
+	return src != unknownZone && dst != unknownZone && src != dst
+}
+
+// trafficService returns the service a message is accounted to; control
+// and gossip messages are grouped by message type
+func trafficService(message *ClusterMessage) string {
+	if service := message.Headers[HeaderService]; service != "" {
+		return service
+	}
+	return "cluster." + string(message.Type)
+}
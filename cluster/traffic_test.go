@@ -0,0 +1,143 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestTrafficAccounting tests per-service zone aggregation and pricing
+func TestTrafficAccounting(t *testing.T) {
+	ta := NewTrafficAccounting("us-east-1a")
+	ta.SetCostPerGB(0.02)
+
+	ta.RecordSent("players", "us-east-1b", bytesPerGB)
+	ta.RecordReceived("players", "us-east-1b", bytesPerGB/2)
+	ta.RecordSent("players", "us-east-1a", 100)
+	ta.RecordSent("chat", "us-east-1a", 500)
+	ta.RecordSent("chat", "", 50)
+
+	report := ta.Report()
+	if report.CrossZoneBytes != bytesPerGB+bytesPerGB/2 {
+		t.Errorf("Unexpected cross-zone bytes: %d", report.CrossZoneBytes)
+	}
+	if report.SameZoneBytes != 650 {
+		t.Errorf("Expected unknown zones to count as same-zone, got %d", report.SameZoneBytes)
+	}
+	if report.EstimatedCost < 0.029 || report.EstimatedCost > 0.031 {
+		t.Errorf("Expected cost of 1.5GB at 0.02/GB, got %f", report.EstimatedCost)
+	}
+
+	if len(report.Services) != 2 || report.Services[0].Service != "players" {
+		t.Fatalf("Expected players to be the most expensive service: %+v", report.Services)
+	}
+	if !report.Flows[0].CrossZone {
+		t.Errorf("Expected cross-zone flows first: %+v", report.Flows)
+	}
+
+	ta.Reset()
+	if report := ta.Report(); len(report.Flows) != 0 {
+		t.Errorf("Expected no flows after reset, got %d", len(report.Flows))
+	}
+}
+
+// TestTransportTrafficZones tests that peers exchange zones and traffic is tagged
+func TestTransportTrafficZones(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "seed"
+	config.Metadata[MetadataZone] = "us-east-1a"
+
+	mt := NewMessageTransport(config).(*messageTransport)
+	mt.ctx, mt.cancel = context.WithCancel(context.Background())
+	defer mt.cancel()
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go mt.handleIncomingConnection(server)
+
+	client.SetDeadline(time.Now().Add(time.Second))
+	encoder := json.NewEncoder(client)
+	decoder := json.NewDecoder(client)
+
+	handshake := &ClusterMessage{Type: MessageTypeJoin, From: "worker", Headers: map[string]string{HeaderZone: "us-east-1b"}}
+	if err := encoder.Encode(handshake); err != nil {
+		t.Fatalf("Failed to send handshake: %v", err)
+	}
+
+	var response ClusterMessage
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+	if response.Headers[HeaderZone] != "us-east-1a" {
+		t.Errorf("Expected seed zone in response, got %+v", response.Headers)
+	}
+
+	call := &ClusterMessage{Type: MessageTypeActorCall, From: "worker", Payload: []byte(`{}`), Headers: map[string]string{HeaderService: "players"}}
+	if err := encoder.Encode(call); err != nil {
+		t.Fatalf("Failed to send call: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for mt.GetStatistics().MessagesReceived == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	report := mt.Traffic().Report()
+	if len(report.Services) != 1 || report.Services[0].Service != "players" || report.Services[0].CrossZoneBytes == 0 {
+		t.Fatalf("Expected cross-zone players traffic, got %+v", report)
+	}
+	if flow := report.Flows[0]; flow.SourceZone != "us-east-1b" || flow.DestZone != "us-east-1a" {
+		t.Errorf("Unexpected flow zones: %+v", flow)
+	}
+	if stats := mt.GetStatistics(); stats.BytesReceived != report.CrossZoneBytes {
+		t.Errorf("Expected transport bytes %d to match traffic bytes %d", stats.BytesReceived, report.CrossZoneBytes)
+	}
+}
+
+// headerTransport keeps the headers of the last message sent through it
+type headerTransport struct {
+	MessageTransport
+	headers map[string]string
+}
+
+func (ht *headerTransport) Send(ctx context.Context, nodeID NodeID, message *ClusterMessage) error {
+	ht.headers = message.Headers
+	return nil
+}
+
+// TestRemoteCallsAccountedToService tests that calls through a resolved
+// reference carry the service name, not the target actor
+func TestRemoteCallsAccountedToService(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "local"
+	manager := NewClusterManager(config).(*clusterManager)
+	transport := &headerTransport{}
+	rs := NewRemoteService(manager).(*remoteService)
+	rs.transport = transport
+	registry := NewServiceRegistry(manager).(*serviceRegistry)
+	rs.registry = registry
+	manager.addNode(NewRemoteNode(&NodeInfo{ID: "node-x", State: NodeStateActive}))
+	registry.upsertInstance(ServiceInstance{ServiceID: "players", NodeID: "node-x"})
+
+	ctx := context.Background()
+	refs, err := rs.Resolve(ctx, "players")
+	if err != nil || len(refs) != 1 {
+		t.Fatalf("Failed to resolve players: %v %v", refs, err)
+	}
+	if err := rs.Send(ctx, refs[0], "hello"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got := transport.headers[HeaderService]; got != "players" {
+		t.Errorf("Expected the call accounted to players, got %q", got)
+	}
+
+	// A reference to a single actor is not a service of its own
+	if err := rs.Send(ctx, RemoteActorRef{NodeID: "node-x", ActorID: "player-42"}, "hello"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got, ok := transport.headers[HeaderService]; ok {
+		t.Errorf("Expected no service for a hand-built reference, got %q", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -19,7 +20,10 @@ type messageTransport struct {
 	connections map[NodeID]*connection
 	connMu      sync.RWMutex
 
-	stats TransportStatistics
+	stats     TransportStatistics
+	traffic   *TrafficAccounting
+	expired   *expiryCounter
+	reconnect *ReconnectManager
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -34,9 +38,20 @@ type connection struct {
 	conn    net.Conn
 	encoder *json.Encoder
 	decoder *json.Decoder
+	written *countingWriter
+	zone    string
+
+	// skew is how far the peer clock is ahead of ours, estimated at the
+	// handshake and used to enforce message TTLs
+	skew time.Duration
 
 	sendChan chan *ClusterMessage
 
+	// outbound connections were dialed by us and are redialed when they
+	// drop; received counts their inbound bytes
+	outbound bool
+	received int64 // atomic
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
@@ -46,10 +61,18 @@ type connection struct {
 
 // NewMessageTransport creates a new message transport
 func NewMessageTransport(config *ClusterConfig) MessageTransport {
-	return &messageTransport{
+	traffic := NewTrafficAccounting(config.Metadata[MetadataZone])
+	traffic.SetCostPerGB(config.CrossZoneCostPerGB)
+
+	mt := &messageTransport{
 		config:      config,
 		connections: make(map[NodeID]*connection),
+		traffic:     traffic,
+		expired:     newExpiryCounter(config.MaxExpiryPeers),
 	}
+	mt.reconnect = NewReconnectManager(config.Reconnect, mt.redial)
+	mt.reconnect.SetPriority(mt.reconnectPriority)
+	return mt
 }
 
 func (mt *messageTransport) Start(ctx context.Context) error {
@@ -72,6 +95,13 @@ func (mt *messageTransport) Start(ctx context.Context) error {
 	mt.wg.Add(1)
 	go mt.acceptLoop()
 
+	// Start redialing dropped peers
+	mt.wg.Add(1)
+	go func() {
+		defer mt.wg.Done()
+		mt.reconnect.Run(mt.ctx)
+	}()
+
 	return nil
 }
 
@@ -173,9 +203,17 @@ func (mt *messageTransport) GetStatistics() TransportStatistics {
 		ConnectionsOpen:  connCount,
 		ErrorCount:       atomic.LoadInt64(&mt.stats.ErrorCount),
 		AverageLatency:   mt.stats.AverageLatency,
+		MessagesExpired:  atomic.LoadInt64(&mt.expired.total),
+		Expired:          mt.expired.snapshot(),
+		Reconnects:       mt.reconnect.Statistics(),
 	}
 }
 
+// Reconnects returns the manager redialing dropped peers
+func (mt *messageTransport) Reconnects() *ReconnectManager {
+	return mt.reconnect
+}
+
 // Connection management
 
 func (mt *messageTransport) getConnection(nodeID NodeID) (*connection, error) {
@@ -187,6 +225,11 @@ func (mt *messageTransport) getConnection(nodeID NodeID) (*connection, error) {
 		return conn, nil
 	}
 
+	// Dropped peers are redialed by the reconnect manager only
+	if mt.reconnect.Pending(nodeID) {
+		return nil, ErrPeerReconnecting
+	}
+
 	// Need to create new connection
 	return mt.createConnection(nodeID)
 }
@@ -209,12 +252,27 @@ func (mt *messageTransport) createConnection(nodeID NodeID) (*connection, error)
 		return nil, fmt.Errorf("failed to dial %s: %w", address, err)
 	}
 
+	written := &countingWriter{w: netConn}
+	encoder := json.NewEncoder(written)
+	decoder := json.NewDecoder(netConn)
+
+	netConn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	response, err := mt.joinHandshake(encoder, decoder, nodeID)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
 	conn := &connection{
 		nodeID:   nodeID,
 		conn:     netConn,
-		encoder:  json.NewEncoder(netConn),
-		decoder:  json.NewDecoder(netConn),
+		encoder:  encoder,
+		decoder:  decoder,
+		written:  written,
+		zone:     response.Headers[HeaderZone],
+		skew:     estimateSkew(response.Timestamp, time.Now()),
 		sendChan: make(chan *ClusterMessage, 100),
+		outbound: true,
 	}
 
 	conn.ctx, conn.cancel = context.WithCancel(mt.ctx)
@@ -234,6 +292,22 @@ func (mt *messageTransport) createConnection(nodeID NodeID) (*connection, error)
 	return conn, nil
 }
 
+// redial reconnects to a dropped peer for the reconnect manager
+func (mt *messageTransport) redial(ctx context.Context, nodeID NodeID) error {
+	_, err := mt.createConnection(nodeID)
+	return err
+}
+
+// reconnectPriority dials the leader before other peers
+func (mt *messageTransport) reconnectPriority(nodeID NodeID) int {
+	if lookup, ok := mt.handler.(leaderLookup); ok {
+		if leader, ok := lookup.GetLeader(); ok && leader.ID() == nodeID {
+			return ReconnectPriorityLeader
+		}
+	}
+	return ReconnectPriorityNormal
+}
+
 func (mt *messageTransport) removeConnection(nodeID NodeID) {
 	mt.connMu.Lock()
 	defer mt.connMu.Unlock()
@@ -272,8 +346,9 @@ func (mt *messageTransport) handleIncomingConnection(netConn net.Conn) {
 	// Set read timeout for handshake
 	netConn.SetReadDeadline(time.Now().Add(10 * time.Second))
 
+	written := &countingWriter{w: netConn}
 	decoder := json.NewDecoder(netConn)
-	encoder := json.NewEncoder(netConn)
+	encoder := json.NewEncoder(written)
 
 	// Read handshake message
 	var handshake ClusterMessage
@@ -298,26 +373,44 @@ func (mt *messageTransport) handleIncomingConnection(netConn net.Conn) {
 		Timestamp: time.Now(),
 	}
 
+	var joinErr error
+	if hs, ok := mt.handler.(JoinHandshaker); ok {
+		response.Headers = hs.JoinHeaders()
+		if joinErr = hs.ValidateJoin(&handshake); joinErr != nil {
+			response.Headers[HeaderJoinError] = joinErr.Error()
+		}
+	}
+	mt.addZoneHeader(response)
+
 	if err := encoder.Encode(response); err != nil {
 		atomic.AddInt64(&mt.stats.ErrorCount, 1)
 		return
 	}
 
+	if joinErr != nil {
+		atomic.AddInt64(&mt.stats.ErrorCount, 1)
+		return
+	}
+
 	// Create connection
 	conn := &connection{
 		nodeID:   nodeID,
 		conn:     netConn,
 		encoder:  encoder,
 		decoder:  decoder,
+		written:  written,
+		zone:     handshake.Headers[HeaderZone],
+		skew:     estimateSkew(handshake.Timestamp, time.Now()),
 		sendChan: make(chan *ClusterMessage, 100),
 	}
 
 	conn.ctx, conn.cancel = context.WithCancel(mt.ctx)
 
-	// Add to connections
+	// Add to connections; a peer that dialed us needs no redial
 	mt.connMu.Lock()
 	mt.connections[nodeID] = conn
 	mt.connMu.Unlock()
+	mt.reconnect.Cancel(nodeID)
 
 	// Start connection goroutines
 	conn.wg.Add(2)
@@ -337,6 +430,10 @@ func (mt *messageTransport) handleConnection(conn *connection) {
 	defer conn.wg.Done()
 	defer func() {
 		mt.removeConnection(conn.nodeID)
+		if conn.outbound && atomic.LoadInt32(&mt.started) == 1 {
+			traffic := atomic.LoadInt64(&conn.written.n) + atomic.LoadInt64(&conn.received)
+			mt.reconnect.Schedule(conn.nodeID, traffic)
+		}
 		if mt.handler != nil {
 			mt.handler.HandleConnectionLost(conn.nodeID, fmt.Errorf("connection closed"))
 		}
@@ -351,13 +448,24 @@ func (mt *messageTransport) handleConnection(conn *connection) {
 			conn.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
 
 			var message ClusterMessage
+			offset := conn.decoder.InputOffset()
 			if err := conn.decoder.Decode(&message); err != nil {
 				atomic.AddInt64(&mt.stats.ErrorCount, 1)
 				return
 			}
 
+			size := conn.decoder.InputOffset() - offset
 			atomic.StoreInt64(&conn.lastActivity, time.Now().UnixNano())
 			atomic.AddInt64(&mt.stats.MessagesReceived, 1)
+			atomic.AddInt64(&mt.stats.BytesReceived, size)
+			atomic.AddInt64(&conn.received, size)
+			mt.traffic.RecordReceived(trafficService(&message), conn.zone, size)
+
+			// Drop messages that went stale on a slow link
+			if isExpired(&message, conn.skew, mt.config.ClockSkewTolerance, time.Now()) {
+				mt.expired.record(conn.nodeID, message.Type)
+				continue
+			}
 
 			// Handle message
 			if mt.handler != nil {
@@ -377,16 +485,50 @@ func (mt *messageTransport) sendLoop(conn *connection) {
 		case <-conn.ctx.Done():
 			return
 		case message := <-conn.sendChan:
+			written := atomic.LoadInt64(&conn.written.n)
 			if err := conn.encoder.Encode(message); err != nil {
 				atomic.AddInt64(&mt.stats.ErrorCount, 1)
 				return
 			}
 
+			size := atomic.LoadInt64(&conn.written.n) - written
 			atomic.StoreInt64(&conn.lastActivity, time.Now().UnixNano())
+			atomic.AddInt64(&mt.stats.BytesSent, size)
+			mt.traffic.RecordSent(trafficService(message), conn.zone, size)
 		}
 	}
 }
 
+// Traffic returns the per-service, per-zone traffic accounting
+func (mt *messageTransport) Traffic() *TrafficAccounting {
+	return mt.traffic
+}
+
+// addZoneHeader announces the local zone in a handshake message
+func (mt *messageTransport) addZoneHeader(message *ClusterMessage) {
+	zone := mt.traffic.LocalZone()
+	if zone == "" {
+		return
+	}
+	if message.Headers == nil {
+		message.Headers = make(map[string]string)
+	}
+	message.Headers[HeaderZone] = zone
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64 // atomic
+}
+
+// Write writes p and counts the bytes written
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	atomic.AddInt64(&cw.n, int64(n))
+	return n, err
+}
+
 // Connection methods
 
 func (c *connection) isActive() bool {
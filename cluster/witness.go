@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NodeRole decides which duties a node takes in the cluster
+type NodeRole string
+
+const (
+	// NodeRoleFull is a regular node: it votes, may lead and runs services
+	NodeRoleFull NodeRole = ""
+
+	// NodeRoleWitness is a lightweight member that never leads, hosts no
+	// services and takes no remote calls. It still joins, gossips and
+	// heartbeats like any member, so it shows in membership and health.
+	NodeRoleWitness NodeRole = "witness"
+)
+
+// HeaderRole carries the role of a joiner in the join handshake
+const HeaderRole = "role"
+
+// ErrWitnessNode is returned for service and remote call operations on a
+// witness node
+var ErrWitnessNode = errors.New("witness nodes carry no services")
+
+// String returns the string representation of a role
+func (r NodeRole) String() string {
+	if r == NodeRoleFull {
+		return "full"
+	}
+	return string(r)
+}
+
+// parseNodeRole parses a role received from a peer; unknown roles are
+// treated as full nodes
+func parseNodeRole(s string) NodeRole {
+	if NodeRole(s) == NodeRoleWitness {
+		return NodeRoleWitness
+	}
+	return NodeRoleFull
+}
+
+// IsWitness returns true for witness nodes
+func (info *NodeInfo) IsWitness() bool {
+	return info.Role == NodeRoleWitness
+}
+
+// isWitness returns true if the local node is a witness
+func (cm *clusterManager) isWitness() bool {
+	return cm.config.Role == NodeRoleWitness
+}
+
+// checkDataPlane refuses service and call operations on a witness node
+func (cm *clusterManager) checkDataPlane() error {
+	if cm.isWitness() {
+		return fmt.Errorf("node %s: %w", cm.localNode.ID(), ErrWitnessNode)
+	}
+	return nil
+}
+
+// LeaderCandidates returns the active nodes that may become leader, which
+// excludes witnesses
+func (cm *clusterManager) LeaderCandidates() []Node {
+	var candidates []Node
+	for _, node := range cm.GetActiveNodes() {
+		if !node.Info().IsWitness() {
+			candidates = append(candidates, node)
+		}
+	}
+	return candidates
+}
+
+// witnessCheck is implemented by cluster managers that may be witnesses,
+// letting the service layer refuse data plane operations
+type witnessCheck interface {
+	checkDataPlane() error
+}
+
+// refuseOnWitness refuses data plane operations when manager is a witness
+func refuseOnWitness(manager ClusterManager) error {
+	if wc, ok := manager.(witnessCheck); ok {
+		return wc.checkDataPlane()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestWitnessNodeRefusesDataPlane tests that a witness never leads and
+// hosts no services
+func TestWitnessNodeRefusesDataPlane(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "witness"
+	config.Role = NodeRoleWitness
+	manager := NewClusterManager(config).(*clusterManager)
+	rs := NewRemoteService(manager).(*remoteService)
+	registry := NewServiceRegistry(manager)
+	rs.registry = registry
+
+	if !manager.LocalNode().Info().IsWitness() {
+		t.Fatal("Expected local node to carry the witness role")
+	}
+	if manager.JoinHeaders()[HeaderRole] != string(NodeRoleWitness) {
+		t.Error("Expected join handshake to announce the witness role")
+	}
+
+	manager.electSelf()
+	if manager.IsLeader() {
+		t.Error("Expected witness never to elect itself")
+	}
+	if err := manager.Join(context.Background(), nil); err == nil {
+		t.Error("Expected witness to need seed nodes")
+	}
+
+	if err := registry.RegisterService(context.Background(), "chat", nil); !errors.Is(err, ErrWitnessNode) {
+		t.Errorf("Expected ErrWitnessNode registering a service, got %v", err)
+	}
+	if err := rs.Register("chat", nil); !errors.Is(err, ErrWitnessNode) {
+		t.Errorf("Expected ErrWitnessNode registering a handler, got %v", err)
+	}
+	if _, err := rs.Call(context.Background(), RemoteActorRef{NodeID: "game-1", ActorID: "chat"}, "hi"); !errors.Is(err, ErrWitnessNode) {
+		t.Errorf("Expected ErrWitnessNode making a call, got %v", err)
+	}
+	err := manager.HandleMessage(context.Background(), "game-1", &ClusterMessage{Type: MessageTypeActorCall})
+	if !errors.Is(err, ErrWitnessNode) {
+		t.Errorf("Expected ErrWitnessNode for an incoming call, got %v", err)
+	}
+}
+
+// TestWitnessIsNeverLeaderCandidate tests that a joined witness is a member
+// but never a leader candidate
+func TestWitnessIsNeverLeaderCandidate(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "game-a"
+	manager := NewClusterManager(config).(*clusterManager)
+	manager.addNode(manager.localNode)
+	manager.localNode.UpdateState(NodeStateActive)
+	manager.electSelf()
+
+	if err := manager.ValidateJoin(joinMessage("game-b", "10.0.1.1:7946", 100)); err != nil {
+		t.Fatalf("Expected game-b to join, got %v", err)
+	}
+	witness := joinMessage("witness", "10.0.2.1:7946", 100)
+	witness.Headers[HeaderRole] = string(NodeRoleWitness)
+	if err := manager.ValidateJoin(witness); err != nil {
+		t.Fatalf("Expected witness to join, got %v", err)
+	}
+
+	node, _ := manager.GetNode("witness")
+	if !node.Info().IsWitness() {
+		t.Fatal("Expected joined witness to carry its role")
+	}
+	for _, candidate := range manager.LeaderCandidates() {
+		if candidate.ID() == "witness" {
+			t.Error("Expected witness not to be a leader candidate")
+		}
+	}
+	if got := len(manager.LeaderCandidates()); got != 2 {
+		t.Errorf("Expected 2 leader candidates, got %d", got)
+	}
+
+	if health := manager.GetClusterHealth(); health.Witnesses != 1 || health.TotalNodes != 3 {
+		t.Errorf("Expected the witness counted as a member, got %+v", health)
+	}
+}
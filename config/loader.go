@@ -30,6 +30,9 @@ type Loader struct {
 
 	// Default configuration
 	defaultConfig *Config
+
+	// Per-environment defaults replacing defaultConfig (optional)
+	profiles func(env Environment) *Config
 }
 
 // NewLoader creates a new configuration loader
@@ -65,13 +68,38 @@ func (l *Loader) SetDefaultConfig(config *Config) *Loader {
 	return l
 }
 
+// SetProfiles sets per-environment defaults: configuration files are
+// merged over profiles(env) for the environment they select, which the
+// SNGO_APP_ENVIRONMENT variable overrides. A nil result falls back to the
+// default configuration.
+func (l *Loader) SetProfiles(profiles func(env Environment) *Config) *Loader {
+	l.profiles = profiles
+	return l
+}
+
+// defaults returns the configuration missing fields are filled from
+func (l *Loader) defaults(env Environment) *Config {
+	if l.profiles != nil {
+		if val := os.Getenv(l.envPrefix + "_APP_ENVIRONMENT"); val != "" {
+			env = Environment(val)
+		}
+		if env == "" && l.defaultConfig != nil {
+			env = l.defaultConfig.App.Environment
+		}
+		if config := l.profiles(env); config != nil {
+			return config
+		}
+	}
+	if l.defaultConfig != nil {
+		return l.defaultConfig
+	}
+	return DefaultConfig()
+}
+
 // Load loads configuration from the specified file
 func (l *Loader) Load(filename string) (*Config, error) {
 	// Start with default configuration
-	config := l.defaultConfig
-	if config == nil {
-		config = DefaultConfig()
-	}
+	config := l.defaults("")
 
 	// Try to load configuration file
 	if filename != "" {
@@ -119,10 +147,7 @@ func (l *Loader) AutoLoad() (*Config, error) {
 	if err != nil {
 		// If no config file found, use default config
 		if err == ErrConfigFileNotFound {
-			config := l.defaultConfig
-			if config == nil {
-				config = DefaultConfig()
-			}
+			config := l.defaults("")
 
 			// Still apply environment variables
 			err = l.loadFromEnv(config)
@@ -147,18 +172,12 @@ func (l *Loader) AutoLoad() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file %s: %w", configFile, err)
 	}
 
-	config, err := l.parseConfig(data, format)
+	// Fields the file leaves out keep their defaults
+	config, err := l.parseOverDefaults(data, format)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file %s: %w", configFile, err)
 	}
 
-	// Merge with default config to fill missing fields
-	defaultConfig := l.defaultConfig
-	if defaultConfig == nil {
-		defaultConfig = DefaultConfig()
-	}
-	config = l.mergeConfig(defaultConfig, config)
-
 	// Override with environment variables
 	err = l.loadFromEnv(config)
 	if err != nil {
@@ -234,18 +253,12 @@ func (l *Loader) loadFromFile(filename string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	config, err := l.parseConfig(data, format)
+	// Fields the file leaves out keep their defaults
+	config, err := l.parseOverDefaults(data, format)
 	if err != nil {
 		return nil, err
 	}
 
-	// Merge with default config to fill missing fields
-	defaultConfig := l.defaultConfig
-	if defaultConfig == nil {
-		defaultConfig = DefaultConfig()
-	}
-	config = l.mergeConfig(defaultConfig, config)
-
 	// Override with environment variables
 	err = l.loadFromEnv(config)
 	if err != nil {
@@ -261,26 +274,54 @@ func (l *Loader) loadFromFile(filename string) (*Config, error) {
 	return config, nil
 }
 
+// parseOverDefaults parses configuration data over a copy of the defaults
+// of the environment it selects, so every field the data sets, including
+// false and zero values, wins over the profile
+func (l *Loader) parseOverDefaults(data []byte, format ConfigFormat) (*Config, error) {
+	selected, err := l.parseConfig(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy through YAML, so decoding cannot write into shared maps or slices
+	defaults, err := yaml.Marshal(l.defaults(selected.App.Environment))
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy default config: %w", err)
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(defaults, config); err != nil {
+		return nil, fmt.Errorf("failed to copy default config: %w", err)
+	}
+	return config, l.decode(data, format, config)
+}
+
 // parseConfig parses configuration data based on format
 func (l *Loader) parseConfig(data []byte, format ConfigFormat) (*Config, error) {
 	config := &Config{}
+	if err := l.decode(data, format, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
 
+// decode decodes configuration data into config
+func (l *Loader) decode(data []byte, format ConfigFormat, config *Config) error {
 	switch format {
 	case FormatYAML:
 		err := yaml.Unmarshal(data, config)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+			return fmt.Errorf("failed to parse YAML config: %w", err)
 		}
 	case FormatJSON:
 		err := json.Unmarshal(data, config)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse JSON config: %w", err)
+			return fmt.Errorf("failed to parse JSON config: %w", err)
 		}
 	default:
-		return nil, fmt.Errorf("unsupported config format: %s", format)
+		return fmt.Errorf("unsupported config format: %s", format)
 	}
 
-	return config, nil
+	return nil
 }
 
 // loadFromEnv loads configuration overrides from environment variables
@@ -356,78 +397,3 @@ func parsePort(val string) (int, error) {
 	}
 	return port, nil
 }
-
-// mergeConfig merges user config with default config
-func (l *Loader) mergeConfig(defaultConfig, userConfig *Config) *Config {
-	// Start with default config
-	merged := *defaultConfig
-
-	// Override with user config values where specified
-	if userConfig.App.Name != "" {
-		merged.App.Name = userConfig.App.Name
-	}
-	if userConfig.App.Version != "" {
-		merged.App.Version = userConfig.App.Version
-	}
-	if userConfig.App.Environment != "" {
-		merged.App.Environment = userConfig.App.Environment
-	}
-	if userConfig.App.Description != "" {
-		merged.App.Description = userConfig.App.Description
-	}
-	merged.App.Debug = userConfig.App.Debug
-	if userConfig.App.Metadata != nil {
-		merged.App.Metadata = userConfig.App.Metadata
-	}
-
-	// Log config
-	if userConfig.Log.Level != "" {
-		merged.Log.Level = userConfig.Log.Level
-	}
-	if userConfig.Log.Format != "" {
-		merged.Log.Format = userConfig.Log.Format
-	}
-	if userConfig.Log.Output != "" {
-		merged.Log.Output = userConfig.Log.Output
-	}
-	merged.Log.Color = userConfig.Log.Color
-	if userConfig.Log.Fields != nil {
-		merged.Log.Fields = userConfig.Log.Fields
-	}
-
-	// Network config
-	if userConfig.Network.TCP.Address != "" {
-		merged.Network.TCP.Address = userConfig.Network.TCP.Address
-	}
-	if userConfig.Network.TCP.Port != 0 {
-		merged.Network.TCP.Port = userConfig.Network.TCP.Port
-	}
-	if userConfig.Network.Limits.MaxConnections != 0 {
-		merged.Network.Limits.MaxConnections = userConfig.Network.Limits.MaxConnections
-	}
-
-	// Actor config
-	if userConfig.Actor.MaxActors != 0 {
-		merged.Actor.MaxActors = userConfig.Actor.MaxActors
-	}
-	if userConfig.Actor.DefaultMailboxSize != 0 {
-		merged.Actor.DefaultMailboxSize = userConfig.Actor.DefaultMailboxSize
-	}
-
-	// Discovery config
-	if userConfig.Discovery.Registration.Name != "" {
-		merged.Discovery.Registration.Name = userConfig.Discovery.Registration.Name
-	}
-
-	// Custom fields
-	if userConfig.Custom != nil {
-		if merged.Custom == nil {
-			merged.Custom = make(map[string]interface{})
-		}
-		for k, v := range userConfig.Custom {
-			merged.Custom[k] = v
-		}
-	}
-
-	return &merged
-}
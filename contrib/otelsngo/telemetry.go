@@ -0,0 +1,213 @@
+// Package otelsngo bridges the telemetry hooks of SNGO to OpenTelemetry.
+//
+// It lives in its own module so that the framework itself has no
+// dependency on the OpenTelemetry SDK. Install it once at startup, with
+// the providers of an existing OTLP pipeline:
+//
+//	t, err := otelsngo.Install(otelsngo.Options{
+//		TracerProvider: tracerProvider,
+//		MeterProvider:  meterProvider,
+//	})
+//
+// Every message handled by an Actor then becomes a span, and its handling
+// and queueing times are recorded in histograms. Errors reported by
+// background goroutines are counted, and ObserveUsage exports the per
+// message type traffic of network servers and clients.
+package otelsngo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/najoast/sngo/core"
+	"github.com/najoast/sngo/network"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the tracer and meter
+const ScopeName = "github.com/najoast/sngo/contrib/otelsngo"
+
+// Attribute keys set on spans and metrics
+const (
+	NamespaceKey      = attribute.Key("sngo." + core.LabelNamespace)
+	ActorNameKey      = attribute.Key("sngo.actor.name")
+	ActorIDKey        = attribute.Key("sngo.actor.id")
+	MessageTypeKey    = attribute.Key("sngo.message.type")
+	MessageSourceKey  = attribute.Key("sngo.message.source")
+	MessageSessionKey = attribute.Key("sngo.message.session")
+	ErrorKey          = attribute.Key("sngo.error")
+	ModuleKey         = attribute.Key("sngo.module")
+	SourceKey         = attribute.Key("sngo.source")
+	SeverityKey       = attribute.Key("sngo.severity")
+	EndpointKey       = attribute.Key("sngo.endpoint")
+	DirectionKey      = attribute.Key("sngo.direction")
+)
+
+// Options configures the adapter
+type Options struct {
+	// TracerProvider creates the tracer; nil uses the global provider
+	TracerProvider trace.TracerProvider
+
+	// MeterProvider creates the meter; nil uses the global provider
+	MeterProvider metric.MeterProvider
+}
+
+// Telemetry implements core.Telemetry with an OpenTelemetry tracer and
+// meter
+type Telemetry struct {
+	tracer trace.Tracer
+	meter  metric.Meter
+
+	handleDuration metric.Float64Histogram
+	queueDuration  metric.Float64Histogram
+	errors         metric.Int64Counter
+}
+
+// New creates the adapter without installing it
+func New(opts Options) (*Telemetry, error) {
+	if opts.TracerProvider == nil {
+		opts.TracerProvider = otel.GetTracerProvider()
+	}
+	if opts.MeterProvider == nil {
+		opts.MeterProvider = otel.GetMeterProvider()
+	}
+
+	t := &Telemetry{
+		tracer: opts.TracerProvider.Tracer(ScopeName),
+		meter:  opts.MeterProvider.Meter(ScopeName),
+	}
+
+	var err error
+	t.handleDuration, err = t.meter.Float64Histogram("sngo.actor.handle.duration",
+		metric.WithDescription("Time Actors spend handling a message"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create handle duration histogram: %w", err)
+	}
+	t.queueDuration, err = t.meter.Float64Histogram("sngo.actor.queue.duration",
+		metric.WithDescription("Time messages wait before an Actor handles them"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create queue duration histogram: %w", err)
+	}
+	t.errors, err = t.meter.Int64Counter("sngo.errors",
+		metric.WithDescription("Errors reported by background goroutines"),
+		metric.WithUnit("{error}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create error counter: %w", err)
+	}
+	return t, nil
+}
+
+// Install creates the adapter and makes it the telemetry of the framework;
+// core.SetTelemetry(nil) removes it
+func Install(opts Options) (*Telemetry, error) {
+	t, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	core.SetTelemetry(t)
+	return t, nil
+}
+
+// StartMessage starts the span of a message; it implements core.Telemetry
+func (t *Telemetry) StartMessage(ctx context.Context, span core.MessageSpan) (context.Context, func(err error)) {
+	// Actor labels are empty when dropped as high cardinality
+	name := span.Actor
+	labels := []attribute.KeyValue{
+		NamespaceKey.String(span.Namespace),
+		MessageTypeKey.String(span.Type.String()),
+	}
+	if span.Actor != "" {
+		labels = append(labels, ActorNameKey.String(span.Actor))
+	} else {
+		name = span.Namespace
+	}
+	attrs := append([]attribute.KeyValue(nil), labels...)
+	if span.Actor != "" {
+		attrs = append(attrs,
+			ActorIDKey.Int64(int64(span.ActorID)),
+			MessageSourceKey.Int64(int64(span.Source)),
+			MessageSessionKey.Int64(int64(span.Session)),
+		)
+	}
+
+	ctx, s := t.tracer.Start(ctx, name+" "+span.Type.String(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...))
+
+	t.queueDuration.Record(ctx, span.Queued.Seconds(), metric.WithAttributes(labels...))
+
+	start := time.Now()
+	return ctx, func(err error) {
+		if err != nil {
+			s.RecordError(err)
+			s.SetStatus(codes.Error, err.Error())
+		}
+		s.End()
+
+		t.handleDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+			append(labels, ErrorKey.Bool(err != nil))...,
+		))
+	}
+}
+
+// ErrorReported counts a background error; it implements core.Telemetry
+func (t *Telemetry) ErrorReported(report core.AsyncError) {
+	t.errors.Add(context.Background(), 1, metric.WithAttributes(
+		NamespaceKey.String(report.Namespace),
+		ModuleKey.String(report.Module),
+		SourceKey.String(report.Source),
+		SeverityKey.String(report.Severity.String()),
+	))
+}
+
+// ObserveUsage exports the per message type traffic of a network server or
+// client as the sngo.network.frames and sngo.network.bytes counters,
+// labelled with endpoint. usage is read at each collection, e.g.
+//
+//	t.ObserveUsage("gate", func() []network.MessageTypeUsage {
+//		return server.GetStatistics().Usage
+//	})
+//
+// Unregister the returned registration when the server stops.
+func (t *Telemetry) ObserveUsage(endpoint string, usage func() []network.MessageTypeUsage) (metric.Registration, error) {
+	frames, err := t.meter.Int64ObservableCounter("sngo.network.frames",
+		metric.WithDescription("Frames sent and received per message type"),
+		metric.WithUnit("{frame}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create frame counter: %w", err)
+	}
+	bytes, err := t.meter.Int64ObservableCounter("sngo.network.bytes",
+		metric.WithDescription("Bytes sent and received per message type"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create byte counter: %w", err)
+	}
+
+	return t.meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for _, u := range usage() {
+			for _, traffic := range []struct {
+				direction string
+				histogram network.SizeHistogram
+			}{{"inbound", u.Inbound}, {"outbound", u.Outbound}} {
+				attrs := metric.WithAttributes(
+					NamespaceKey.String(core.TelemetryNamespace()),
+					EndpointKey.String(endpoint),
+					MessageTypeKey.String(u.Name),
+					DirectionKey.String(traffic.direction),
+				)
+				o.ObserveInt64(frames, traffic.histogram.Count, attrs)
+				o.ObserveInt64(bytes, traffic.histogram.Bytes, attrs)
+			}
+		}
+		return nil
+	}, frames, bytes)
+}
+
+var _ core.Telemetry = (*Telemetry)(nil)
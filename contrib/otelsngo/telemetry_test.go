@@ -0,0 +1,121 @@
+package otelsngo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/najoast/sngo/core"
+	"github.com/najoast/sngo/network"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// failingHandler fails messages saying "fail"
+type failingHandler struct{}
+
+func (h *failingHandler) HandleMessage(ctx context.Context, msg *core.Message) error {
+	if string(msg.Data) == "fail" {
+		return errors.New("out of stock")
+	}
+	return nil
+}
+
+// collect returns the metrics read by reader by name
+func collect(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+func TestTelemetryExportsActorMessages(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	_, err := Install(Options{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to install: %v", err)
+	}
+	defer core.SetTelemetry(nil)
+	core.SetErrorReporter(func(core.AsyncError) {})
+	defer core.SetErrorReporter(nil)
+
+	opts := core.DefaultActorOptions()
+	opts.Name = "inventory"
+	inventory := core.NewActor(3, &failingHandler{}, opts)
+	if err := inventory.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start actor: %v", err)
+	}
+	defer inventory.Stop()
+
+	ctx := context.Background()
+	inventory.Call(ctx, &core.Message{Type: core.MessageTypeRequest, Data: []byte("buy")})
+	inventory.Call(ctx, &core.Message{Type: core.MessageTypeRequest, Data: []byte("fail")})
+	core.ReportError("network", "accept", core.SeverityError, errors.New("too many open files"))
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(ended))
+	}
+	if ended[0].Name() != "inventory request" || ended[0].Status().Code == codes.Error {
+		t.Errorf("Unexpected first span %q with status %v", ended[0].Name(), ended[0].Status())
+	}
+	if status := ended[1].Status(); status.Code != codes.Error || status.Description != "out of stock" {
+		t.Errorf("Expected the failed message to set an error status, got %v", status)
+	}
+
+	metrics := collect(t, reader)
+	handled, ok := metrics["sngo.actor.handle.duration"].(metricdata.Histogram[float64])
+	if !ok || len(handled.DataPoints) != 2 {
+		t.Fatalf("Expected handle durations with and without error, got %+v", metrics["sngo.actor.handle.duration"])
+	}
+	errorCount, ok := metrics["sngo.errors"].(metricdata.Sum[int64])
+	if !ok || len(errorCount.DataPoints) != 1 || errorCount.DataPoints[0].Value != 1 {
+		t.Errorf("Expected one reported error, got %+v", metrics["sngo.errors"])
+	}
+}
+
+func TestTelemetryObservesUsage(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	tel, err := New(Options{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))})
+	if err != nil {
+		t.Fatalf("Failed to create: %v", err)
+	}
+
+	usage := network.NewProtocolUsage()
+	usage.RecordInbound(network.MessageTypeRPC, 100)
+	usage.RecordInbound(network.MessageTypeRPC, 50)
+	registration, err := tel.ObserveUsage("gate", usage.Snapshot)
+	if err != nil {
+		t.Fatalf("Failed to observe usage: %v", err)
+	}
+	defer registration.Unregister()
+
+	bytes, ok := collect(t, reader)["sngo.network.bytes"].(metricdata.Sum[int64])
+	if !ok {
+		t.Fatal("Expected a byte counter")
+	}
+	var inbound int64
+	for _, dp := range bytes.DataPoints {
+		if direction, _ := dp.Attributes.Value(DirectionKey); direction.AsString() == "inbound" {
+			inbound += dp.Value
+		}
+	}
+	if inbound != 150 {
+		t.Errorf("Expected 150 inbound bytes, got %d", inbound)
+	}
+}
@@ -0,0 +1,215 @@
+// Package pionsngo runs the WebRTC transport of SNGO on pion/webrtc.
+//
+// It lives in its own module so that the framework itself has no
+// dependency on a WebRTC implementation. The application forwards the
+// offers and ICE candidates of browsers from its signaling channel to the
+// server:
+//
+//	server, err := network.NewWebRTCServer(config, pionsngo.NewStack(pionsngo.Options{}), signaler)
+//	...
+//	answer, err := server.HandleOffer(ctx, peerID, offer)
+//
+// The browser opens the data channel; its ordering must match the
+// DataChannelMode of the server configuration.
+package pionsngo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/najoast/sngo/network"
+	"github.com/pion/webrtc/v4"
+)
+
+// Options configures the pion stack
+type Options struct {
+	// SettingEngine tunes ICE, e.g. the UDP port range or NAT 1:1 IPs of
+	// a gateway behind a load balancer; nil uses pion's defaults
+	SettingEngine *webrtc.SettingEngine
+}
+
+// Stack implements network.WebRTCStack with pion/webrtc
+type Stack struct {
+	api *webrtc.API
+}
+
+// NewStack creates a pion stack
+func NewStack(options Options) *Stack {
+	var opts []func(*webrtc.API)
+	if options.SettingEngine != nil {
+		opts = append(opts, webrtc.WithSettingEngine(*options.SettingEngine))
+	}
+	return &Stack{api: webrtc.NewAPI(opts...)}
+}
+
+// Answer creates a peer connection for offer and returns its answer. Local
+// candidates are trickled through callbacks.OnCandidate.
+func (s *Stack) Answer(ctx context.Context, offer network.SessionDescription, config network.PeerConfig, callbacks network.PeerCallbacks) (network.PeerConnection, network.SessionDescription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, network.SessionDescription{}, err
+	}
+
+	pc, err := s.api.NewPeerConnection(webrtc.Configuration{ICEServers: iceServers(config.ICEServers)})
+	if err != nil {
+		return nil, network.SessionDescription{}, fmt.Errorf("failed to create peer connection: %w", err)
+	}
+	p := &peer{pc: pc, callbacks: callbacks}
+
+	pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate == nil {
+			return // Gathering complete
+		}
+		init := candidate.ToJSON()
+		signalled := network.ICECandidate{Candidate: init.Candidate}
+		if init.SDPMid != nil {
+			signalled.SDPMid = *init.SDPMid
+		}
+		if init.SDPMLineIndex != nil {
+			signalled.SDPMLineIndex = *init.SDPMLineIndex
+		}
+		callbacks.OnCandidate(signalled)
+	})
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateFailed:
+			p.close(fmt.Errorf("peer connection failed"))
+		case webrtc.PeerConnectionStateClosed:
+			p.close(nil)
+		}
+	})
+	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		if !p.claim(dc) {
+			dc.Close() // One data channel per peer
+			return
+		}
+		if dc.Ordered() != config.Options.Ordered {
+			p.close(fmt.Errorf("data channel %q is not %s", dc.Label(), config.Mode))
+			return
+		}
+		dc.OnOpen(func() { callbacks.OnOpen(p) })
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) { callbacks.OnMessage(msg.Data) })
+		dc.OnClose(func() { p.close(nil) })
+	})
+
+	answer, err := negotiate(pc, offer)
+	if err != nil {
+		pc.Close()
+		return nil, network.SessionDescription{}, err
+	}
+	return p, network.SessionDescription{Type: answer.Type.String(), SDP: answer.SDP}, nil
+}
+
+// negotiate applies offer and sets the local answer
+func negotiate(pc *webrtc.PeerConnection, offer network.SessionDescription) (webrtc.SessionDescription, error) {
+	remote := webrtc.SessionDescription{Type: webrtc.NewSDPType(offer.Type), SDP: offer.SDP}
+	if remote.Type != webrtc.SDPTypeOffer {
+		return webrtc.SessionDescription{}, fmt.Errorf("expected an offer, got %q", offer.Type)
+	}
+	if err := pc.SetRemoteDescription(remote); err != nil {
+		return webrtc.SessionDescription{}, fmt.Errorf("failed to set offer: %w", err)
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return webrtc.SessionDescription{}, fmt.Errorf("failed to create answer: %w", err)
+	}
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return webrtc.SessionDescription{}, fmt.Errorf("failed to set answer: %w", err)
+	}
+	return answer, nil
+}
+
+// iceServers converts the ICE servers of the network configuration
+func iceServers(servers []network.ICEServer) []webrtc.ICEServer {
+	converted := make([]webrtc.ICEServer, 0, len(servers))
+	for _, server := range servers {
+		converted = append(converted, webrtc.ICEServer{
+			URLs:       server.URLs,
+			Username:   server.Username,
+			Credential: server.Credential,
+		})
+	}
+	return converted
+}
+
+// peer is one browser peer connection and its data channel. It is both
+// the network.PeerConnection and, once open, the network.DataChannel.
+type peer struct {
+	pc        *webrtc.PeerConnection
+	callbacks network.PeerCallbacks
+
+	mu     sync.Mutex
+	dc     *webrtc.DataChannel
+	closed bool
+}
+
+// claim makes dc the data channel of the peer unless it already has one
+func (p *peer) claim(dc *webrtc.DataChannel) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.dc != nil || p.closed {
+		return false
+	}
+	p.dc = dc
+	return true
+}
+
+// close closes the peer connection and reports err once
+func (p *peer) close(err error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	// Closing from a pion callback would wait for that callback
+	go p.pc.Close()
+	p.callbacks.OnClose(err)
+}
+
+// AddCandidate adds a remote ICE candidate
+func (p *peer) AddCandidate(candidate network.ICECandidate) error {
+	init := webrtc.ICECandidateInit{
+		Candidate:     candidate.Candidate,
+		SDPMLineIndex: &candidate.SDPMLineIndex,
+	}
+	if candidate.SDPMid != "" {
+		init.SDPMid = &candidate.SDPMid
+	}
+	return p.pc.AddICECandidate(init)
+}
+
+// RemoteAddr returns the remote address of the selected candidate pair
+func (p *peer) RemoteAddr() net.Addr {
+	sctp := p.pc.SCTP()
+	if sctp == nil {
+		return nil
+	}
+	pair, err := sctp.Transport().ICETransport().GetSelectedCandidatePair()
+	if err != nil || pair == nil || pair.Remote == nil {
+		return nil
+	}
+	return &net.UDPAddr{IP: net.ParseIP(pair.Remote.Address), Port: int(pair.Remote.Port)}
+}
+
+// Send sends one message on the data channel
+func (p *peer) Send(data []byte) error {
+	p.mu.Lock()
+	dc := p.dc
+	p.mu.Unlock()
+
+	if dc == nil {
+		return network.ErrDataChannelNotOpen
+	}
+	return dc.Send(data)
+}
+
+// Close closes the data channel and the peer connection
+func (p *peer) Close() error {
+	p.close(nil)
+	return nil
+}
@@ -0,0 +1,116 @@
+package pionsngo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/najoast/sngo/network"
+	"github.com/pion/webrtc/v4"
+)
+
+// loopbackEngine gathers host candidates on loopback only
+func loopbackEngine() *webrtc.SettingEngine {
+	engine := &webrtc.SettingEngine{}
+	engine.SetIncludeLoopbackCandidate(true)
+	engine.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4})
+	engine.SetInterfaceFilter(func(name string) bool { return name == "lo" })
+	return engine
+}
+
+// TestStack connects a pion client, standing in for a browser, to a
+// WebRTC server running on the stack
+func TestStack(t *testing.T) {
+	engine := loopbackEngine()
+	client, err := webrtc.NewAPI(webrtc.WithSettingEngine(*engine)).NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	config := network.DefaultNetworkConfig()
+	config.Protocol = network.ProtocolWebRTC
+	signaler := network.SignalerFunc(func(peerID string, candidate network.ICECandidate) error {
+		return client.AddICECandidate(webrtc.ICECandidateInit{
+			Candidate:     candidate.Candidate,
+			SDPMid:        &candidate.SDPMid,
+			SDPMLineIndex: &candidate.SDPMLineIndex,
+		})
+	})
+	server, err := network.NewWebRTCServer(config, NewStack(Options{SettingEngine: engine}), signaler)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	channel, err := client.CreateDataChannel("sngo", nil)
+	if err != nil {
+		t.Fatalf("Failed to create data channel: %v", err)
+	}
+	received := make(chan []byte, 1)
+	channel.OnMessage(func(msg webrtc.DataChannelMessage) { received <- msg.Data })
+
+	offer, err := client.CreateOffer(nil)
+	if err != nil {
+		t.Fatalf("Failed to create offer: %v", err)
+	}
+	// The client sends its candidates in the offer, the server trickles
+	gathered := webrtc.GatheringCompletePromise(client)
+	if err := client.SetLocalDescription(offer); err != nil {
+		t.Fatalf("Failed to set offer: %v", err)
+	}
+	<-gathered
+	offer = *client.LocalDescription()
+	answer, err := server.HandleOffer(context.Background(), "player-1", network.SessionDescription{Type: offer.Type.String(), SDP: offer.SDP})
+	if err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+	if err := client.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.NewSDPType(answer.Type), SDP: answer.SDP}); err != nil {
+		t.Fatalf("Failed to set answer: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := server.AcceptConnection(ctx)
+	if err != nil {
+		t.Fatalf("Expected the peer connected: %v", err)
+	}
+	if conn.RemoteAddr() == nil {
+		t.Error("Expected the selected candidate as remote address")
+	}
+
+	// Frames flow both ways, one per data channel message
+	codec := network.NewBinaryMessageCodec()
+	frame, _ := codec.Encode(network.NewMessage(network.MessageTypeData, []byte("move")))
+	if err := channel.Send(frame); err != nil {
+		t.Fatalf("Failed to send from client: %v", err)
+	}
+	msg, err := conn.ReadMessage()
+	if err != nil || string(msg.Data) != "move" {
+		t.Fatalf("Expected the move frame, got %v (%v)", msg, err)
+	}
+
+	if err := conn.SendMessage(network.NewMessage(network.MessageTypeData, []byte("state"))); err != nil {
+		t.Fatalf("Failed to send to client: %v", err)
+	}
+	select {
+	case data := <-received:
+		if sent, err := codec.Decode(data); err != nil || string(sent.Data) != "state" {
+			t.Errorf("Expected the state frame, got %v (%v)", sent, err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected the state frame received by the client")
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for server.GetConnectionCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected the peer removed once closed, got %d", count)
+	}
+}
@@ -17,12 +17,30 @@ type actor struct {
 	// Channel for receiving messages
 	mailbox chan *Message
 
+	// Lane for messages with a non-zero Priority (nil without a priority queue)
+	urgent chan *Message
+
+	// Token bucket enforcing the rate limit (nil means unlimited)
+	limiter *rateLimiter
+
+	// Handler slots and in-flight handlers in ConcurrencyParallel (nil slots
+	// means serial handling)
+	slots    chan struct{}
+	inflight sync.WaitGroup
+
 	// Context for controlling the Actor lifecycle
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	// Wait group for graceful shutdown
-	wg sync.WaitGroup
+	// Closed when the message loop exits
+	done chan struct{}
+
+	// Set once the message loop has been (or can no longer be) started
+	started int32
+
+	// Orders Sends against Stop: Sends hold it shared while enqueueing,
+	// Stop holds it exclusively while switching to stopping
+	stopMu sync.RWMutex
 
 	// Atomic counters for statistics
 	state             int32 // ActorState
@@ -36,6 +54,56 @@ type actor struct {
 
 	// Actor options
 	opts ActorOptions
+
+	// Pause requests from the system (used by Handoff)
+	pauseCh chan pauseRequest
+
+	// Forwarding target once the actor has handed off its state
+	forwardMu sync.RWMutex
+	forward   Actor
+
+	// Memory accounting for the mailbox and attachments
+	mem actorMemory
+
+	// System messages queued, which overflow never drops; dropMu orders
+	// queueing them against dropping the oldest message
+	queuedSystem int64 // atomic
+	dropMu       sync.Mutex
+
+	// Profiling flag of the owning system (nil for standalone Actors)
+	profiling *int32
+
+	// Clock and journal of the owning system (nil for standalone Actors)
+	journal *journal
+
+	// Audit subscriptions of the owning system (nil for standalone Actors)
+	audit *auditHub
+
+	// Priority inheritance setting of the owning system (nil for standalone
+	// Actors) and the Calls into this Actor it elevated
+	inheritance *priorityInheritance
+	inherited   uint64 // atomic
+
+	// Goroutine running the handler, recorded for the thread-safety detector
+	handlerGoroutine int64 // atomic
+
+	// Poison pill taken off the urgent lane by a yielding handler, acted on
+	// by the message loop once the handler returns
+	poison *Message
+
+	// Cooperative yields of long-running handlers
+	yields       uint64 // atomic
+	yieldedNanos int64  // atomic
+	progressAt   int64  // atomic, UnixNano of the last handler start or yield
+
+	// Exceeded message deadlines by stage
+	deadlines deadlineCounters
+}
+
+// pauseRequest asks the message loop to hold between messages until resumed.
+type pauseRequest struct {
+	paused chan struct{}
+	resume chan struct{}
 }
 
 // NewActor creates a new Actor instance.
@@ -51,6 +119,22 @@ func NewActor(id ActorID, handler MessageHandler, opts ActorOptions) Actor {
 		cancel:    cancel,
 		createdAt: time.Now(),
 		opts:      opts,
+		pauseCh:   make(chan pauseRequest),
+		done:      make(chan struct{}),
+	}
+
+	if opts.PriorityQueue {
+		a.urgent = make(chan *Message, opts.MailboxSize)
+	}
+	if opts.RateLimit > 0 {
+		a.limiter = newRateLimiter(opts.RateLimit, opts.RateBurst)
+	}
+	if opts.Concurrency == ConcurrencyParallel && opts.MaxConcurrency > 1 {
+		a.slots = make(chan struct{}, opts.MaxConcurrency)
+	}
+
+	if guarded, ok := handler.(guardedHandler); ok {
+		guarded.bindGuard(a)
 	}
 
 	// Set initial state
@@ -67,56 +151,253 @@ func (a *actor) ID() ActorID {
 // Start begins the Actor's message processing loop.
 func (a *actor) Start(ctx context.Context) error {
 	currentState := ActorState(atomic.LoadInt32(&a.state))
-	if currentState != ActorStateIdle {
+	if currentState != ActorStateIdle || !atomic.CompareAndSwapInt32(&a.started, 0, 1) {
 		return fmt.Errorf("actor %d is already started (state: %s)", a.id, currentState)
 	}
 
-	a.wg.Add(1)
+	if pool := a.opts.LockedThreads; pool != nil {
+		err := checkLockedThread(a.opts)
+		if err == nil {
+			err = pool.dispatch(a.messageLoop)
+		}
+		if err != nil {
+			// Not started: a later Start may find a free thread
+			atomic.StoreInt32(&a.started, 0)
+			return fmt.Errorf("actor %d: %w", a.id, err)
+		}
+		return nil
+	}
+
 	go a.messageLoop()
 
 	return nil
 }
 
-// Stop gracefully shuts down the Actor.
+// Stop gracefully shuts down the Actor. It enqueues a poison pill behind
+// every message already accepted by Send, so all of them are processed
+// before the Actor stops; later Sends are rejected. Handlers must not call
+// Stop on their own Actor, they should Send a PoisonPill instead.
 func (a *actor) Stop() error {
-	// Set state to stopping
+	if err := a.beginStop(); err != nil {
+		return err
+	}
+
+	// Never started: there is no loop to drain the pill
+	if atomic.CompareAndSwapInt32(&a.started, 0, 1) {
+		a.drainMailbox()
+		return a.finishStop()
+	}
+
+	pill := PoisonPill()
+	a.queueing(pill)
+	select {
+	case a.mailbox <- pill:
+	case <-a.done:
+		// The loop already exited on a poison pill sent by someone else
+		a.dequeued(pill)
+	}
+
+	<-a.done
+	return a.finishStop()
+}
+
+// ForceStop stops the Actor without waiting for queued messages. The
+// current message is finished; queued calls fail with a shutdown error.
+func (a *actor) ForceStop() error {
+	if err := a.beginStop(); err != nil {
+		return err
+	}
+
+	a.cancel()
+	if !atomic.CompareAndSwapInt32(&a.started, 0, 1) {
+		<-a.done
+	} else {
+		a.drainMailbox()
+	}
+	return a.finishStop()
+}
+
+// beginStop switches the Actor to stopping. Holding stopMu guarantees every
+// Send either completed its enqueue before or observes the new state.
+func (a *actor) beginStop() error {
+	a.stopMu.Lock()
+	defer a.stopMu.Unlock()
+
 	if !atomic.CompareAndSwapInt32(&a.state, int32(ActorStateIdle), int32(ActorStateStopping)) &&
 		!atomic.CompareAndSwapInt32(&a.state, int32(ActorStateRunning), int32(ActorStateStopping)) {
 		return fmt.Errorf("actor %d cannot be stopped from state %s",
 			a.id, ActorState(atomic.LoadInt32(&a.state)))
 	}
+	return nil
+}
 
-	// Cancel context to signal shutdown
+// finishStop releases pending calls and attached memory and marks the
+// Actor stopped.
+func (a *actor) finishStop() error {
 	a.cancel()
-
-	// Wait for message loop to finish
-	a.wg.Wait()
-
-	// Set final state
+	a.detachAll()
 	atomic.StoreInt32(&a.state, int32(ActorStateStopped))
-
 	return nil
 }
 
 // Send sends a message to this Actor's mailbox.
 func (a *actor) Send(msg *Message) error {
+	a.forwardMu.RLock()
+	defer a.forwardMu.RUnlock()
+
+	if a.forward != nil {
+		msg.Target = a.forward.ID()
+		return a.forward.Send(msg)
+	}
+
+	// Messages sent by handlers during a replay are already in the journal
+	if a.journal != nil && a.journal.isReplaying() {
+		return nil
+	}
+
+	a.stopMu.RLock()
+	defer a.stopMu.RUnlock()
+
 	currentState := ActorState(atomic.LoadInt32(&a.state))
 	if currentState == ActorStateStopped || currentState == ActorStateStopping {
 		return fmt.Errorf("actor %d is not running (state: %s)", a.id, currentState)
 	}
 
+	if a.limiter != nil && !a.limiter.allow(time.Now()) {
+		return fmt.Errorf("actor %d: %w", a.id, ErrRateLimited)
+	}
+
+	spilled, err := a.admit(msg)
+	if err != nil {
+		return err
+	}
+	if spilled {
+		return nil
+	}
+
+	a.queueing(msg)
+	lane := a.lane(msg)
 	select {
-	case a.mailbox <- msg:
+	case lane <- msg:
 		return nil
 	case <-a.ctx.Done():
-		return fmt.Errorf("actor %d is shutting down", a.id)
+		a.dequeued(msg)
+		return fmt.Errorf("actor %d: %w", a.id, ErrActorStopping)
+	default:
+		return a.overflow(lane, msg)
+	}
+}
+
+// lane returns the queue a message goes to.
+func (a *actor) lane(msg *Message) chan *Message {
+	if a.urgent != nil && msg.Priority > 0 {
+		return a.urgent
+	}
+	return a.mailbox
+}
+
+// overflow applies the overflow policy to a message whose lane is full.
+func (a *actor) overflow(lane chan *Message, msg *Message) error {
+	switch a.opts.OverflowPolicy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case lane <- msg:
+				return nil
+			default:
+			}
+
+			if !a.dropOldestFrom(lane) {
+				break
+			}
+		}
+
+	case OverflowBlock:
+		// Bounded so a paused Actor (e.g. during Handoff) cannot wedge Sends
+		timer := time.NewTimer(a.opts.ProcessTimeout)
+		defer timer.Stop()
+
+		select {
+		case lane <- msg:
+			return nil
+		case <-a.ctx.Done():
+			a.dequeued(msg)
+			return fmt.Errorf("actor %d: %w", a.id, ErrActorStopping)
+		case <-timer.C:
+		}
+	}
+
+	a.dequeued(msg)
+	return fmt.Errorf("actor %d: %w", a.id, ErrMailboxFull)
+}
+
+// isSystemMessage reports whether msg controls the Actor itself rather
+// than carrying work; system messages are never dropped or reordered.
+func isSystemMessage(msg *Message) bool {
+	return msg.Type == MessageTypePoisonPill || msg.Type == MessageTypeSystem
+}
+
+// queueing records a system message about to be queued.
+func (a *actor) queueing(msg *Message) {
+	if isSystemMessage(msg) {
+		a.dropMu.Lock()
+		atomic.AddInt64(&a.queuedSystem, 1)
+		a.dropMu.Unlock()
+	}
+}
+
+// dropOldestFrom drops the oldest message of lane to make room. While a
+// system message is queued it drops nothing and returns false: the oldest
+// message could be that one, and it can't be put back in front.
+func (a *actor) dropOldestFrom(lane chan *Message) bool {
+	a.dropMu.Lock()
+	defer a.dropMu.Unlock()
+
+	if atomic.LoadInt64(&a.queuedSystem) > 0 {
+		return false
+	}
+	select {
+	case old := <-lane:
+		if old != nil {
+			a.dequeued(old)
+			if old.Session != 0 {
+				a.sendResponse(old, fmt.Errorf("actor %d dropped message: %w", a.id, ErrMailboxFull))
+			}
+		}
 	default:
-		return fmt.Errorf("actor %d mailbox is full", a.id)
 	}
+	return true
 }
 
 // Call sends a message and waits for a response.
 func (a *actor) Call(ctx context.Context, msg *Message) (*Message, error) {
+	if a.journal != nil && a.journal.isReplaying() {
+		return nil, ErrReplayCall
+	}
+
+	// Stamp a copy: the caller may reuse or share msg
+	call := *msg
+	msg = &call
+
+	// The handler gets the time the caller has left
+	if deadline, ok := ctx.Deadline(); ok && (msg.Deadline.IsZero() || deadline.Before(msg.Deadline)) {
+		msg.Deadline = deadline
+	}
+	// and the priority of the message it is handling
+	a.inheritPriority(ctx, msg)
+
+	respChan, done, err := a.enqueueCall(msg)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return a.awaitResponse(ctx, respChan)
+}
+
+// enqueueCall sends msg under a new session and returns the channel its
+// response arrives on and a function releasing the session.
+func (a *actor) enqueueCall(msg *Message) (chan *Message, func(), error) {
 	// Generate a unique session ID
 	session := atomic.AddUint32(&a.sessionCounter, 1)
 	msg.Session = session
@@ -124,21 +405,25 @@ func (a *actor) Call(ctx context.Context, msg *Message) (*Message, error) {
 	// Create response channel
 	respChan := make(chan *Message, 1)
 	a.pendingCalls.Store(session, respChan)
-	defer a.pendingCalls.Delete(session)
+	done := func() { a.pendingCalls.Delete(session) }
 
 	// Send the message
 	if err := a.Send(msg); err != nil {
-		return nil, err
+		done()
+		return nil, nil, err
 	}
+	return respChan, done, nil
+}
 
-	// Wait for response or timeout
+// awaitResponse waits for the response of an enqueued call.
+func (a *actor) awaitResponse(ctx context.Context, respChan chan *Message) (*Message, error) {
 	select {
 	case resp := <-respChan:
 		return resp, nil
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	case <-a.ctx.Done():
-		return nil, fmt.Errorf("actor %d is shutting down", a.id)
+		return nil, fmt.Errorf("actor %d: %w", a.id, ErrActorStopping)
 	}
 }
 
@@ -149,55 +434,195 @@ func (a *actor) Stats() ActorStats {
 	if lastMsg > 0 {
 		lastMessageAt = time.Unix(lastMsg, 0)
 	}
+	var progressAt time.Time
+	if progress := atomic.LoadInt64(&a.progressAt); progress > 0 {
+		progressAt = time.Unix(0, progress)
+	}
 
 	return ActorStats{
 		ID:                a.id,
 		Name:              a.name,
 		State:             ActorState(atomic.LoadInt32(&a.state)),
 		MessagesProcessed: atomic.LoadUint64(&a.messagesProcessed),
-		MailboxSize:       len(a.mailbox),
+		MailboxSize:       len(a.mailbox) + len(a.urgent),
+		MailboxBytes:      atomic.LoadInt64(&a.mem.mailboxBytes),
 		CreatedAt:         a.createdAt,
 		LastMessageAt:     lastMessageAt,
+		Yields:            atomic.LoadUint64(&a.yields),
+		YieldedTime:       time.Duration(atomic.LoadInt64(&a.yieldedNanos)),
+		HandlerProgressAt: progressAt,
+		DeadlinesExceeded: a.deadlines.snapshot(),
+		PriorityInherited: atomic.LoadUint64(&a.inherited),
 	}
 }
 
 // messageLoop is the main processing loop for the Actor.
 func (a *actor) messageLoop() {
-	defer a.wg.Done()
-	defer close(a.mailbox)
+	defer close(a.done)
 
 	for {
+		// Urgent messages overtake the mailbox
+		if a.urgent != nil {
+			select {
+			case msg := <-a.urgent:
+				if !a.receive(msg) {
+					return
+				}
+				continue
+			default:
+			}
+		}
+
 		select {
+		case msg := <-a.urgent:
+			if !a.receive(msg) {
+				return
+			}
+
 		case msg := <-a.mailbox:
-			if msg == nil {
-				continue
+			if !a.receive(msg) {
+				return
+			}
+
+		case req := <-a.pauseCh:
+			a.inflight.Wait()
+			close(req.paused)
+			select {
+			case <-req.resume:
+			case <-a.ctx.Done():
+				a.drainMailbox()
+				return
 			}
-			a.processMessage(msg)
 
 		case <-a.ctx.Done():
 			// Process remaining messages before shutting down
+			a.inflight.Wait()
 			a.drainMailbox()
 			return
 		}
 	}
 }
 
+// receive handles a message taken from a queue. It returns false once a
+// poison pill has stopped the Actor; urgent messages accepted before the
+// pill are still handled.
+func (a *actor) receive(msg *Message) bool {
+	if msg == nil {
+		return true
+	}
+	a.dequeued(msg)
+
+	if msg.Type != MessageTypePoisonPill {
+		a.dispatch(msg)
+		if a.poison == nil {
+			return true
+		}
+		a.poison = nil
+	}
+
+	// A poison pill, taken here or by a yielding handler, stops the Actor
+	for a.urgent != nil {
+		select {
+		case next := <-a.urgent:
+			a.dequeued(next)
+			if next.Type != MessageTypePoisonPill {
+				a.dispatch(next)
+			}
+			continue
+		default:
+		}
+		break
+	}
+	a.inflight.Wait()
+	a.poisoned()
+	return false
+}
+
+// dispatch records and handles a message, on its own goroutine when the
+// Actor handles messages in parallel.
+func (a *actor) dispatch(msg *Message) {
+	// Work queued with RunOnActor cannot be replayed or audited
+	if a.journal != nil && msg.run == nil {
+		a.journal.record(a, msg)
+	}
+	if a.audit != nil && msg.run == nil {
+		a.audit.tap(a.id, msg)
+	}
+
+	if a.slots == nil {
+		a.processMessage(msg)
+		return
+	}
+
+	a.slots <- struct{}{}
+	a.inflight.Add(1)
+	go func() {
+		defer func() {
+			<-a.slots
+			a.inflight.Done()
+		}()
+		a.processMessage(msg)
+	}()
+}
+
+// poisoned stops accepting messages after a poison pill and fails whatever
+// was queued behind it.
+func (a *actor) poisoned() {
+	a.stopMu.Lock()
+	atomic.StoreInt32(&a.state, int32(ActorStateStopping))
+	a.stopMu.Unlock()
+
+	a.drainMailbox()
+	a.finishStop()
+}
+
 // processMessage handles a single message.
 func (a *actor) processMessage(msg *Message) {
-	// Set state to running
-	atomic.StoreInt32(&a.state, int32(ActorStateRunning))
-	defer atomic.StoreInt32(&a.state, int32(ActorStateIdle))
+	// Set state to running, unless a stop is already under way
+	if atomic.CompareAndSwapInt32(&a.state, int32(ActorStateIdle), int32(ActorStateRunning)) {
+		defer atomic.CompareAndSwapInt32(&a.state, int32(ActorStateRunning), int32(ActorStateIdle))
+	}
 
 	// Update statistics
 	atomic.AddUint64(&a.messagesProcessed, 1)
 	atomic.StoreInt64(&a.lastMessageAt, time.Now().Unix())
 
-	// Create context with timeout
+	// Refuse messages whose caller already gave up
+	if msg.run == nil && expired(msg, time.Now()) {
+		a.deadlines.add(DeadlineStageMailbox)
+		if msg.Session != 0 {
+			a.sendResponse(msg, context.DeadlineExceeded)
+		}
+		return
+	}
+
+	// Create context with timeout, bounded by the message deadline
 	ctx, cancel := context.WithTimeout(a.ctx, a.opts.ProcessTimeout)
 	defer cancel()
+	ctx, cancelDeadline := withMessageDeadline(ctx, msg)
+	defer cancelDeadline()
+	run := &handlerRun{actor: a, slice: time.Now(), priority: msg.Priority}
+	ctx = context.WithValue(ctx, actorContextKey{}, run)
+
+	defer a.enterHandler()()
+	defer a.progress(run.slice)()
+
+	if msg.run != nil {
+		msg.run(ctx)
+		return
+	}
+
+	if msg.tick != nil {
+		msg.tick.observe(msg, a.now())
+	}
 
 	// Handle the message
-	err := a.handler.HandleMessage(ctx, msg)
+	ctx, end := a.startMessage(ctx, msg)
+	err := a.handle(ctx, msg)
+	end(err)
+	if err != nil {
+		countDeadline(ctx, DeadlineStageHandler, err)
+	}
 
 	// If this was a call (has session), send response
 	if msg.Session != 0 {
@@ -205,6 +630,15 @@ func (a *actor) processMessage(msg *Message) {
 	}
 }
 
+// now returns the time of the system clock, or the wall clock for
+// standalone Actors.
+func (a *actor) now() time.Time {
+	if a.journal == nil {
+		return time.Now()
+	}
+	return a.journal.now()
+}
+
 // sendResponse sends a response message for a call.
 func (a *actor) sendResponse(originalMsg *Message, err error) {
 	if respChan, ok := a.pendingCalls.Load(originalMsg.Session); ok {
@@ -231,20 +665,85 @@ func (a *actor) sendResponse(originalMsg *Message, err error) {
 	}
 }
 
-// drainMailbox processes remaining messages during shutdown.
-func (a *actor) drainMailbox() {
+// pause blocks the message loop between two messages and returns a function
+// that resumes it. Messages sent while paused keep queueing in the mailbox.
+func (a *actor) pause() (func(), error) {
+	req := pauseRequest{
+		paused: make(chan struct{}),
+		resume: make(chan struct{}),
+	}
+
+	select {
+	case a.pauseCh <- req:
+	case <-a.ctx.Done():
+		return nil, fmt.Errorf("actor %d: %w", a.id, ErrActorStopping)
+	}
+
+	<-req.paused
+
+	var once sync.Once
+	return func() { once.Do(func() { close(req.resume) }) }, nil
+}
+
+// forwardTo redirects all future messages to target and moves every message
+// still queued in the mailbox over to it, preserving order. The caller must
+// hold the actor paused so the mailbox is not consumed concurrently.
+func (a *actor) forwardTo(target *actor) error {
+	a.forwardMu.Lock()
+	defer a.forwardMu.Unlock()
+
 	for {
+		msg := a.nextQueued()
+		if msg == nil {
+			a.forward = target
+			return nil
+		}
+
+		a.dequeued(msg)
+		target.charge(msg)
+		msg.Target = target.id
+		select {
+		case target.lane(msg) <- msg:
+		case <-target.ctx.Done():
+			target.dequeued(msg)
+			return fmt.Errorf("actor %d stopped during handoff", target.id)
+		}
+	}
+}
+
+// nextQueued takes the next queued message without blocking, urgent ones
+// first; it returns nil once both queues are empty.
+func (a *actor) nextQueued() *Message {
+	for {
+		select {
+		case msg := <-a.urgent:
+			return msg
+		default:
+		}
+
 		select {
 		case msg := <-a.mailbox:
 			if msg == nil {
-				return
-			}
-			// Send error response for any pending calls
-			if msg.Session != 0 {
-				a.sendResponse(msg, fmt.Errorf("actor %d is shutting down", a.id))
+				continue
 			}
+			return msg
 		default:
+			return nil
+		}
+	}
+}
+
+// drainMailbox processes remaining messages during shutdown.
+func (a *actor) drainMailbox() {
+	for {
+		msg := a.nextQueued()
+		if msg == nil {
 			return
 		}
+		a.dequeued(msg)
+		// Send error response for any pending calls
+		if msg.Session != 0 {
+			a.sendResponse(msg, fmt.Errorf("actor %d: %w", a.id, ErrActorStopping))
+		}
 	}
 }
@@ -20,6 +20,9 @@ type AdvancedRouter interface {
 	// RegisterService registers a service with a name
 	RegisterService(actor Actor, name string) (*Handle, error)
 
+	// RegisterSystemService registers a service that may use reserved name prefixes
+	RegisterSystemService(actor Actor, name string) (*Handle, error)
+
 	// UnregisterService unregisters a service by name
 	UnregisterService(name string) error
 
@@ -64,13 +67,28 @@ func (ar *advancedRouter) Register(actor Actor) error {
 
 // RegisterService registers a service with a name.
 func (ar *advancedRouter) RegisterService(actor Actor, name string) (*Handle, error) {
+	return ar.registerService(actor, name, ar.handleManager.AllocateHandle)
+}
+
+// RegisterSystemService registers a service that may use reserved name prefixes.
+func (ar *advancedRouter) RegisterSystemService(actor Actor, name string) (*Handle, error) {
+	return ar.registerService(actor, name, ar.handleManager.AllocateSystemHandle)
+}
+
+// registerService routes the actor and allocates its named handle.
+func (ar *advancedRouter) registerService(actor Actor, name string, allocate func(ActorID, string) (*Handle, error)) (*Handle, error) {
 	// Register with basic router first
 	if err := ar.router.Register(actor); err != nil {
 		return nil, err
 	}
 
 	// Allocate a named handle
-	return ar.handleManager.AllocateHandle(actor.ID(), name)
+	handle, err := allocate(actor.ID(), name)
+	if err != nil {
+		ar.router.Unregister(actor.ID())
+		return nil, err
+	}
+	return handle, nil
 }
 
 // Unregister removes an Actor from the routing table.
@@ -177,12 +195,19 @@ type SessionManager struct {
 	mu       sync.RWMutex
 	sessions map[uint32]*MessageSession
 	counter  uint32
+
+	// Stops the cleanup goroutine on Close
+	done      chan struct{}
+	closeOnce sync.Once
+	stopped   chan struct{}
 }
 
 // NewSessionManager creates a new SessionManager.
 func NewSessionManager() *SessionManager {
 	sm := &SessionManager{
 		sessions: make(map[uint32]*MessageSession),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
 	}
 
 	// Start cleanup goroutine
@@ -253,12 +278,26 @@ func (sm *SessionManager) CleanupSession(sessionID uint32) {
 	}
 }
 
+// Close stops the cleanup goroutine and waits for it to exit.
+func (sm *SessionManager) Close() {
+	sm.closeOnce.Do(func() { close(sm.done) })
+	<-sm.stopped
+}
+
 // cleanupExpiredSessions periodically removes expired sessions.
 func (sm *SessionManager) cleanupExpiredSessions() {
+	defer close(sm.stopped)
+
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-sm.done:
+			return
+		}
+
 		now := time.Now()
 		sm.mu.Lock()
 
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// threadChecks enables the thread-safety detector; it is on by default in
+// builds tagged sngo_debug.
+var threadChecks atomic.Bool
+
+func init() {
+	threadChecks.Store(debugBuild)
+}
+
+// SetThreadSafetyChecks turns the thread-safety detector on or off. While
+// on, every serial Actor records the goroutine running its handler and
+// ActorGuard.Check panics when handler state is touched from any other
+// goroutine. Recording costs a stack read per message, so it is meant for
+// debug builds and tests.
+func SetThreadSafetyChecks(enabled bool) {
+	threadChecks.Store(enabled)
+}
+
+// ThreadSafetyChecks reports whether the thread-safety detector is on.
+func ThreadSafetyChecks() bool {
+	return threadChecks.Load()
+}
+
+// ActorGuard enforces the thread-safety contract of Actors: handler state
+// belongs to the Actor and may only be used from its handler. Embed an
+// ActorGuard in a MessageHandler and call Check from the methods touching
+// its state; a closure leaked to another goroutine then fails loudly
+// instead of racing. Work that must reach handler state from outside
+// goes through RunOnActor.
+//
+// Actors handling messages in parallel have no single owning goroutine
+// and are not checked.
+type ActorGuard struct {
+	owner atomic.Pointer[actor]
+}
+
+// guardedHandler is a MessageHandler embedding an ActorGuard.
+type guardedHandler interface {
+	bindGuard(a *actor)
+}
+
+// bindGuard ties the guard to the Actor running its handler.
+func (g *ActorGuard) bindGuard(a *actor) {
+	g.owner.CompareAndSwap(nil, a)
+}
+
+// Check panics if the detector is on and the caller is not the handler of
+// the Actor owning the guard.
+func (g *ActorGuard) Check() {
+	if !threadChecks.Load() {
+		return
+	}
+	a := g.owner.Load()
+	if a == nil || a.slots != nil {
+		return
+	}
+
+	current := goid()
+	owner := atomic.LoadInt64(&a.handlerGoroutine)
+	if owner == current {
+		return
+	}
+
+	status := "while its handler is idle"
+	if owner != 0 {
+		status = fmt.Sprintf("while its handler runs on goroutine %d", owner)
+	}
+	panic(fmt.Sprintf("sngo: thread-safety violation: state of actor %d (%s) used from goroutine %d %s; "+
+		"use RunOnActor to run the work on the actor instead", a.id, a.name, current, status))
+}
+
+// enterHandler records the goroutine about to run the handler and
+// returns the function restoring the previous one, as urgent messages
+// handled while a handler yields nest inside it.
+func (a *actor) enterHandler() func() {
+	if !threadChecks.Load() || a.slots != nil {
+		return func() {}
+	}
+	prev := atomic.SwapInt64(&a.handlerGoroutine, goid())
+	return func() { atomic.StoreInt64(&a.handlerGoroutine, prev) }
+}
+
+// actorContextKey keys the handlerRun in a handler context.
+type actorContextKey struct{}
+
+// RunOnActor queues fn on the mailbox of the Actor whose handler received
+// ctx, so goroutines started by a handler can hand results back without
+// touching handler state themselves. fn runs on the Actor, in mailbox
+// order, with a fresh handler context; ctx may already be cancelled.
+func RunOnActor(ctx context.Context, fn func(ctx context.Context)) error {
+	run, ok := ctx.Value(actorContextKey{}).(*handlerRun)
+	if !ok {
+		return fmt.Errorf("context does not belong to an actor handler")
+	}
+	a := run.actor
+	return a.Send(&Message{
+		Type:   MessageTypeSystem,
+		Source: a.id,
+		Target: a.id,
+		run:    fn,
+	})
+}
+
+// goid returns the ID of the calling goroutine, parsed from its stack
+// header "goroutine 42 [running]:".
+func goid() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	header := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i > 0 {
+		header = header[:i]
+	}
+	id, _ := strconv.ParseInt(string(header), 10, 64)
+	return id
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// guardedCounter guards a counter that leaks a closure to other goroutines.
+type guardedCounter struct {
+	ActorGuard
+	count  int
+	leaked chan func()
+}
+
+func (h *guardedCounter) HandleMessage(ctx context.Context, msg *Message) error {
+	h.increment()
+
+	switch string(msg.Data) {
+	case "leak":
+		h.leaked <- h.increment
+	case "marshal":
+		go func() {
+			RunOnActor(ctx, func(context.Context) {
+				h.increment()
+				h.leaked <- nil
+			})
+		}()
+	}
+	return nil
+}
+
+func (h *guardedCounter) increment() {
+	h.Check()
+	h.count++
+}
+
+func newGuardedActor(t *testing.T) (*guardedCounter, Actor) {
+	t.Helper()
+	SetThreadSafetyChecks(true)
+	t.Cleanup(func() { SetThreadSafetyChecks(debugBuild) })
+
+	handler := &guardedCounter{leaked: make(chan func(), 1)}
+	a := NewActor(1, handler, DefaultActorOptions())
+	if err := a.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start actor: %v", err)
+	}
+	t.Cleanup(func() { a.Stop() })
+	return handler, a
+}
+
+func TestActorGuardDetectsLeakedClosure(t *testing.T) {
+	handler, a := newGuardedActor(t)
+
+	if err := a.Send(&Message{Type: MessageTypeRequest, Data: []byte("leak")}); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	var leaked func()
+	select {
+	case leaked = <-handler.leaked:
+	case <-time.After(time.Second):
+		t.Fatal("Handler did not leak its closure")
+	}
+
+	defer func() {
+		r := recover()
+		msg, ok := r.(string)
+		if !ok || !strings.Contains(msg, "thread-safety violation") || !strings.Contains(msg, "RunOnActor") {
+			t.Fatalf("Expected thread-safety panic, got %v", r)
+		}
+	}()
+	leaked()
+}
+
+func TestActorGuardDisabled(t *testing.T) {
+	handler, _ := newGuardedActor(t)
+	SetThreadSafetyChecks(false)
+
+	// Unchecked access is allowed, just unsafe
+	handler.increment()
+}
+
+func TestRunOnActor(t *testing.T) {
+	handler, a := newGuardedActor(t)
+
+	if err := a.Send(&Message{Type: MessageTypeRequest, Data: []byte("marshal")}); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	select {
+	case <-handler.leaked:
+	case <-time.After(time.Second):
+		t.Fatal("Marshalled work did not run")
+	}
+
+	// Runs on the actor, so the count is consistent without locking
+	done := make(chan int)
+	a.Send(&Message{run: func(context.Context) { done <- handler.count }})
+	if count := <-done; count != 2 {
+		t.Errorf("Expected count 2, got %d", count)
+	}
+
+	if err := RunOnActor(context.Background(), func(context.Context) {}); err == nil {
+		t.Error("Expected error outside an actor handler")
+	}
+}
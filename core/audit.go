@@ -0,0 +1,212 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// AuditFilter selects the messages copied to an auditing Actor.
+type AuditFilter struct {
+	// Types to copy; empty matches every type
+	Types []MessageType
+
+	// Sources to copy from; empty matches every source
+	Sources []ActorID
+
+	// Match further narrows the selection when set
+	Match func(msg *Message) bool
+
+	// SampleRate is the fraction of matching messages copied; 0 or 1
+	// copies all of them. Sampling is deterministic (every Nth match)
+	SampleRate float64
+
+	// BufferSize bounds the copies waiting for delivery; when it is full
+	// further copies are dropped instead of slowing down the system
+	BufferSize int
+}
+
+// matches returns true if msg passes the type, source and match filters.
+func (f *AuditFilter) matches(msg *Message) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == msg.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Sources) > 0 {
+		found := false
+		for _, source := range f.Sources {
+			if source == msg.Source {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return f.Match == nil || f.Match(msg)
+}
+
+// AuditStats reports the activity of an audit subscription.
+type AuditStats struct {
+	// Matched messages passed the filter
+	Matched uint64
+
+	// Sampled out matches were skipped by SampleRate
+	SampledOut uint64
+
+	// Dropped copies did not fit the buffer or the auditor's mailbox
+	Dropped uint64
+
+	// Delivered copies reached the auditor's mailbox
+	Delivered uint64
+}
+
+// AuditSubscription delivers copies of matching messages to an auditing
+// Actor. Copies keep the original type, source and target, so the auditor
+// tells them apart from its own traffic by a Target other than its ID;
+// messages handled by the auditor itself are never copied.
+type AuditSubscription struct {
+	subscriber ActorID
+	filter     AuditFilter
+	every      uint64
+
+	queue chan *Message
+	stop  chan struct{}
+	once  sync.Once
+
+	matched    uint64
+	sampledOut uint64
+	dropped    uint64
+	delivered  uint64
+}
+
+// Subscriber returns the ID of the auditing Actor.
+func (sub *AuditSubscription) Subscriber() ActorID {
+	return sub.subscriber
+}
+
+// Stats returns the subscription counters.
+func (sub *AuditSubscription) Stats() AuditStats {
+	return AuditStats{
+		Matched:    atomic.LoadUint64(&sub.matched),
+		SampledOut: atomic.LoadUint64(&sub.sampledOut),
+		Dropped:    atomic.LoadUint64(&sub.dropped),
+		Delivered:  atomic.LoadUint64(&sub.delivered),
+	}
+}
+
+// offer queues a copy of a message handled by handler without ever
+// blocking the caller; the auditor's own messages are never copied.
+func (sub *AuditSubscription) offer(handler ActorID, msg *Message) {
+	if handler == sub.subscriber || !sub.filter.matches(msg) {
+		return
+	}
+
+	n := atomic.AddUint64(&sub.matched, 1)
+	if sub.every > 1 && (n-1)%sub.every != 0 {
+		atomic.AddUint64(&sub.sampledOut, 1)
+		return
+	}
+
+	dup := *msg
+	dup.Session = 0
+	select {
+	case sub.queue <- &dup:
+	default:
+		atomic.AddUint64(&sub.dropped, 1)
+	}
+}
+
+// deliver forwards queued copies to the auditor until stopped.
+func (sub *AuditSubscription) deliver(lookup func(ActorID) (Actor, bool), done <-chan struct{}) {
+	for {
+		select {
+		case msg := <-sub.queue:
+			auditor, exists := lookup(sub.subscriber)
+			if !exists || auditor.Send(msg) != nil {
+				atomic.AddUint64(&sub.dropped, 1)
+				continue
+			}
+			atomic.AddUint64(&sub.delivered, 1)
+		case <-sub.stop:
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+// auditHub holds the audit subscriptions a system shares with its Actors.
+type auditHub struct {
+	mu   sync.Mutex
+	subs atomic.Value // []*AuditSubscription, copy on write
+}
+
+// tap offers a message handled by handler to every subscription.
+func (h *auditHub) tap(handler ActorID, msg *Message) {
+	subs, _ := h.subs.Load().([]*AuditSubscription)
+	for _, sub := range subs {
+		sub.offer(handler, msg)
+	}
+}
+
+// Subscribe copies the messages handled anywhere in the system that match
+// filter to the subscriber Actor, asynchronously and without blocking the
+// Actors being audited.
+func (s *system) Subscribe(subscriber ActorID, filter AuditFilter) (*AuditSubscription, error) {
+	if _, exists := s.router.Lookup(subscriber); !exists {
+		return nil, fmt.Errorf("auditor %d not found", subscriber)
+	}
+	if filter.SampleRate < 0 || filter.SampleRate > 1 {
+		return nil, fmt.Errorf("sample rate %v out of range", filter.SampleRate)
+	}
+	if filter.BufferSize <= 0 {
+		filter.BufferSize = 1024
+	}
+
+	sub := &AuditSubscription{
+		subscriber: subscriber,
+		filter:     filter,
+		every:      1,
+		queue:      make(chan *Message, filter.BufferSize),
+		stop:       make(chan struct{}),
+	}
+	if filter.SampleRate > 0 && filter.SampleRate < 1 {
+		sub.every = uint64(1/filter.SampleRate + 0.5)
+	}
+
+	s.audit.mu.Lock()
+	subs, _ := s.audit.subs.Load().([]*AuditSubscription)
+	s.audit.subs.Store(append(append([]*AuditSubscription(nil), subs...), sub))
+	s.audit.mu.Unlock()
+
+	go sub.deliver(s.router.Lookup, s.ctx.Done())
+	return sub, nil
+}
+
+// Unsubscribe stops an audit subscription; queued copies are discarded.
+func (s *system) Unsubscribe(sub *AuditSubscription) {
+	s.audit.mu.Lock()
+	subs, _ := s.audit.subs.Load().([]*AuditSubscription)
+	kept := make([]*AuditSubscription, 0, len(subs))
+	for _, existing := range subs {
+		if existing != sub {
+			kept = append(kept, existing)
+		}
+	}
+	s.audit.subs.Store(kept)
+	s.audit.mu.Unlock()
+
+	sub.once.Do(func() { close(sub.stop) })
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// auditorHandler records the audit copies it receives.
+type auditorHandler struct {
+	mu     sync.Mutex
+	copies []*Message
+}
+
+func (h *auditorHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.copies = append(h.copies, msg)
+	return nil
+}
+
+func (h *auditorHandler) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.copies)
+}
+
+func TestAuditSubscription(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+
+	auditor := &auditorHandler{}
+	auditHandle, err := sys.NewService("auditor", auditor, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create auditor: %v", err)
+	}
+	shop, err := sys.NewService("shop", &echoHandler{}, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create shop: %v", err)
+	}
+
+	if _, err := sys.Subscribe(auditHandle.ActorID, AuditFilter{SampleRate: 2}); err == nil {
+		t.Error("Expected sample rate above 1 to be rejected")
+	}
+
+	full, err := sys.Subscribe(auditHandle.ActorID, AuditFilter{
+		Types:   []MessageType{MessageTypeRequest},
+		Sources: []ActorID{7},
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// Only requests from source 7 are copied
+	for i := 0; i < 10; i++ {
+		sys.Send(7, shop.ActorID, MessageTypeRequest, []byte("purchase"))
+		sys.Send(8, shop.ActorID, MessageTypeRequest, []byte("purchase"))
+		sys.Send(7, shop.ActorID, MessageTypeText, []byte("chat"))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for full.Stats().Delivered < 10 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := full.Stats(); stats.Matched != 10 || stats.Delivered != 10 {
+		t.Fatalf("Expected 10 matched and delivered copies, got %+v", stats)
+	}
+
+	auditor.mu.Lock()
+	first := auditor.copies[0]
+	auditor.mu.Unlock()
+	if first.Type != MessageTypeRequest || first.Source != 7 || first.Target != shop.ActorID || string(first.Data) != "purchase" {
+		t.Errorf("Unexpected audit copy: %+v", first)
+	}
+	sys.Unsubscribe(full)
+
+	// Sampling copies every Nth match, and a full buffer drops rather than blocks
+	sampled, err := sys.Subscribe(auditHandle.ActorID, AuditFilter{
+		Types:      []MessageType{MessageTypeText},
+		SampleRate: 0.25,
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	for i := 0; i < 40; i++ {
+		sys.Send(1, shop.ActorID, MessageTypeText, nil)
+	}
+
+	deadline = time.Now().Add(time.Second)
+	for sampled.Stats().Delivered < 10 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := sampled.Stats(); stats.Matched != 40 || stats.SampledOut != 30 || stats.Delivered != 10 {
+		t.Errorf("Expected 1 in 4 matches delivered, got %+v", stats)
+	}
+	sys.Unsubscribe(sampled)
+
+	// Unsubscribed filters no longer copy anything
+	before := auditor.count()
+	sys.Send(7, shop.ActorID, MessageTypeRequest, nil)
+	time.Sleep(20 * time.Millisecond)
+	if auditor.count() != before {
+		t.Error("Expected no copies after unsubscribing")
+	}
+
+	sub := &AuditSubscription{subscriber: 99, every: 1, queue: make(chan *Message, 1)}
+	sub.offer(1, &Message{Type: MessageTypeText})
+	sub.offer(1, &Message{Type: MessageTypeText})
+	if stats := sub.Stats(); stats.Dropped != 1 {
+		t.Errorf("Expected overflow to be dropped, got %+v", stats)
+	}
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrBroadcastIncomplete is returned when some group members did not
+// acknowledge a barrier broadcast before the timeout.
+var ErrBroadcastIncomplete = errors.New("broadcast not acknowledged by all members")
+
+// BroadcastOptions configures a group broadcast.
+type BroadcastOptions struct {
+	// WaitAll waits until every member has handled the message
+	WaitAll bool
+
+	// Timeout bounds the wait for acknowledgements (0 waits for ctx only)
+	Timeout time.Duration
+}
+
+// BroadcastResult reports how the members of a group took a broadcast.
+type BroadcastResult struct {
+	// Acked members handled the message (always empty without WaitAll)
+	Acked []ActorID
+
+	// Laggards had not handled the message when the wait ended
+	Laggards []ActorID
+
+	// Failed members could not be sent the message, or their handler
+	// returned an error
+	Failed map[ActorID]error
+}
+
+// broadcaster orders the broadcasts of a system.
+type broadcaster struct {
+	mu sync.Mutex
+}
+
+// pendingAck is a member's acknowledgement still to come.
+type pendingAck struct {
+	member ActorID
+	wait   func(ctx context.Context) (*Message, error)
+}
+
+// Broadcast sends a message to every member of a group. All broadcasts of
+// a system are enqueued under one lock, so every member receives them in
+// the same order. With WaitAll it acts as a barrier: it returns once each
+// member's handler has finished with the message, or reports the members
+// still lagging when the timeout elapses.
+func (s *system) Broadcast(ctx context.Context, from ActorID, members []ActorID, msgType MessageType, data []byte, opts BroadcastOptions) (*BroadcastResult, error) {
+	result := &BroadcastResult{Failed: make(map[ActorID]error)}
+	var pending []pendingAck
+
+	s.broadcast.mu.Lock()
+	for _, member := range members {
+		target, exists := s.router.Lookup(member)
+		if !exists {
+			result.Failed[member] = fmt.Errorf("actor %d not found", member)
+			continue
+		}
+
+		msg := &Message{
+			Type:      msgType,
+			Source:    from,
+			Target:    member,
+			Data:      data,
+			Timestamp: time.Now(),
+		}
+
+		if !opts.WaitAll {
+			if err := target.Send(msg); err != nil {
+				result.Failed[member] = err
+			}
+			continue
+		}
+
+		ack, err := enqueueAck(target, msg)
+		if err != nil {
+			result.Failed[member] = err
+			continue
+		}
+		pending = append(pending, pendingAck{member: member, wait: ack})
+	}
+	s.broadcast.mu.Unlock()
+
+	if opts.WaitAll {
+		s.awaitAcks(ctx, opts.Timeout, pending, result)
+	}
+
+	if len(result.Laggards) > 0 || len(result.Failed) > 0 {
+		return result, fmt.Errorf("%w: %d lagging, %d failed",
+			ErrBroadcastIncomplete, len(result.Laggards), len(result.Failed))
+	}
+	return result, nil
+}
+
+// enqueueAck sends msg as a call, so the framework acknowledges it once
+// the handler returns, and returns a function waiting for that ack. The
+// message is in the member's mailbox when enqueueAck returns.
+func enqueueAck(target Actor, msg *Message) (func(ctx context.Context) (*Message, error), error) {
+	if a, ok := target.(*actor); ok {
+		respChan, done, err := a.enqueueCall(msg)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) (*Message, error) {
+			defer done()
+			return a.awaitResponse(ctx, respChan)
+		}, nil
+	}
+
+	// Other Actor implementations only offer a blocking Call
+	type reply struct {
+		msg *Message
+		err error
+	}
+	replies := make(chan reply, 1)
+	callCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		resp, err := target.Call(callCtx, msg)
+		replies <- reply{resp, err}
+	}()
+	return func(ctx context.Context) (*Message, error) {
+		defer cancel()
+		select {
+		case r := <-replies:
+			return r.msg, r.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}, nil
+}
+
+// awaitAcks collects the acknowledgements of a barrier broadcast.
+func (s *system) awaitAcks(ctx context.Context, timeout time.Duration, pending []pendingAck, result *BroadcastResult) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range pending {
+		wg.Add(1)
+		go func(p pendingAck) {
+			defer wg.Done()
+			resp, err := p.wait(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil && ctx.Err() != nil:
+				result.Laggards = append(result.Laggards, p.member)
+			case err != nil:
+				result.Failed[p.member] = err
+			case resp.Type == MessageTypeError:
+				result.Failed[p.member] = fmt.Errorf("handler error: %s", string(resp.Data))
+			default:
+				result.Acked = append(result.Acked, p.member)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	sort.Slice(result.Acked, func(i, j int) bool { return result.Acked[i] < result.Acked[j] })
+	sort.Slice(result.Laggards, func(i, j int) bool { return result.Laggards[i] < result.Laggards[j] })
+}
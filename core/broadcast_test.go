@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateHandler blocks on gate before recording each message.
+type gateHandler struct {
+	auditorHandler
+	gate chan struct{}
+}
+
+func (h *gateHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	<-h.gate
+	return h.auditorHandler.HandleMessage(ctx, msg)
+}
+
+func TestBroadcastBarrier(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+
+	fast := &auditorHandler{}
+	fastActor, err := sys.NewActor(fast, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create actor: %v", err)
+	}
+	slow := &gateHandler{gate: make(chan struct{})}
+	slowActor, err := sys.NewActor(slow, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create actor: %v", err)
+	}
+	members := []ActorID{fastActor.ID(), slowActor.ID()}
+
+	result, err := sys.Broadcast(context.Background(), 0, members, MessageTypeSystem, []byte("config v2"),
+		BroadcastOptions{WaitAll: true, Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, ErrBroadcastIncomplete) {
+		t.Fatalf("Expected ErrBroadcastIncomplete, got %v", err)
+	}
+	if len(result.Acked) != 1 || result.Acked[0] != fastActor.ID() {
+		t.Errorf("Unexpected acked members: %v", result.Acked)
+	}
+	if len(result.Laggards) != 1 || result.Laggards[0] != slowActor.ID() {
+		t.Errorf("Unexpected laggards: %v", result.Laggards)
+	}
+
+	// Once the slow member catches up the barrier completes
+	close(slow.gate)
+	result, err = sys.Broadcast(context.Background(), 0, append(members, 9999), MessageTypeSystem, []byte("config v3"),
+		BroadcastOptions{WaitAll: true, Timeout: time.Second})
+	if len(result.Acked) != 2 || len(result.Laggards) != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result.Failed[9999] == nil || !errors.Is(err, ErrBroadcastIncomplete) {
+		t.Errorf("Expected unknown member to fail, got %v", err)
+	}
+}
+
+func TestBroadcastOrder(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+
+	var recorders []*auditorHandler
+	var members []ActorID
+	for i := 0; i < 3; i++ {
+		h := &auditorHandler{}
+		a, err := sys.NewActor(h, DefaultActorOptions())
+		if err != nil {
+			t.Fatalf("Failed to create actor: %v", err)
+		}
+		recorders = append(recorders, h)
+		members = append(members, a.ID())
+	}
+
+	// Concurrent broadcasts reach every member in the same order
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sys.Broadcast(context.Background(), 0, members, MessageTypeText, []byte(fmt.Sprint(i)), BroadcastOptions{})
+		}(i)
+	}
+	wg.Wait()
+
+	if _, err := sys.Broadcast(context.Background(), 0, members, MessageTypeText, []byte("end"),
+		BroadcastOptions{WaitAll: true, Timeout: time.Second}); err != nil {
+		t.Fatalf("Barrier failed: %v", err)
+	}
+
+	order := func(h *auditorHandler) string {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		var s string
+		for _, msg := range h.copies {
+			s += string(msg.Data) + ","
+		}
+		return s
+	}
+	first := order(recorders[0])
+	for _, h := range recorders[1:] {
+		if got := order(h); got != first {
+			t.Errorf("Members saw different orders: %s vs %s", first, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -99,6 +100,87 @@ func TestActorSend(t *testing.T) {
 	}
 }
 
+// countingHandler counts handled messages, optionally slowing each one down.
+type countingHandler struct {
+	delay   time.Duration
+	handled int64
+}
+
+func (h *countingHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	time.Sleep(h.delay)
+	atomic.AddInt64(&h.handled, 1)
+	return nil
+}
+
+func TestActorStopOrdering(t *testing.T) {
+	handler := &countingHandler{delay: time.Millisecond}
+	actor := NewActor(4, handler, DefaultActorOptions())
+	if err := actor.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start actor: %v", err)
+	}
+
+	// Every message sent before Stop is processed before the Actor stops
+	for i := 0; i < 20; i++ {
+		if err := actor.Send(&Message{Type: MessageTypeText, Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Failed to send message %d: %v", i, err)
+		}
+	}
+	if err := actor.Stop(); err != nil {
+		t.Fatalf("Failed to stop actor: %v", err)
+	}
+	if got := atomic.LoadInt64(&handler.handled); got != 20 {
+		t.Errorf("Expected 20 handled messages, got %d", got)
+	}
+	if err := actor.Send(&Message{Type: MessageTypeText}); err == nil {
+		t.Error("Expected send after stop to fail")
+	}
+
+	// A poison pill sent like a normal message stops the Actor in order
+	handler = &countingHandler{}
+	actor = NewActor(5, handler, DefaultActorOptions())
+	actor.Start(context.Background())
+	actor.Send(&Message{Type: MessageTypeText})
+	actor.Send(PoisonPill())
+	deadline := time.Now().Add(time.Second)
+	for actor.Stats().State != ActorStateStopped && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if actor.Stats().State != ActorStateStopped || atomic.LoadInt64(&handler.handled) != 1 {
+		t.Errorf("Expected poison pill to stop actor after 1 message, got %s with %d handled",
+			actor.Stats().State, atomic.LoadInt64(&handler.handled))
+	}
+}
+
+func TestActorForceStop(t *testing.T) {
+	handler := &countingHandler{delay: 10 * time.Millisecond}
+	actor := NewActor(6, handler, DefaultActorOptions())
+	if err := actor.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start actor: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		actor.Send(&Message{Type: MessageTypeText})
+	}
+	if err := actor.ForceStop(); err != nil {
+		t.Fatalf("Failed to force stop actor: %v", err)
+	}
+	if got := atomic.LoadInt64(&handler.handled); got >= 20 {
+		t.Errorf("Expected force stop to skip queued messages, got %d handled", got)
+	}
+	if state := actor.Stats().State; state != ActorStateStopped {
+		t.Errorf("Expected final state %s, got %s", ActorStateStopped, state)
+	}
+
+	// Stopping an Actor that never started does not block
+	idle := NewActor(7, &echoHandler{}, DefaultActorOptions())
+	if err := idle.Stop(); err != nil {
+		t.Errorf("Failed to stop idle actor: %v", err)
+	}
+	if err := idle.Start(context.Background()); err == nil {
+		t.Error("Expected start after stop to fail")
+	}
+}
+
 func TestRouter(t *testing.T) {
 	router := NewRouter()
 
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DeadlineStageMailbox counts messages whose deadline passed while
+	// they waited in the mailbox; they are refused without running the
+	// handler
+	DeadlineStageMailbox = "mailbox"
+
+	// DeadlineStageHandler counts handlers that ran out of time outside
+	// of any stage run with RunStage
+	DeadlineStageHandler = "handler"
+)
+
+// deadlineCounters counts the deadlines an Actor exceeded, by stage.
+type deadlineCounters struct {
+	mu     sync.Mutex
+	stages map[string]uint64
+}
+
+// add counts an exceeded deadline at stage.
+func (c *deadlineCounters) add(stage string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stages == nil {
+		c.stages = make(map[string]uint64)
+	}
+	c.stages[stage]++
+}
+
+// snapshot returns a copy of the counters, nil if none was exceeded.
+func (c *deadlineCounters) snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.stages) == 0 {
+		return nil
+	}
+	stages := make(map[string]uint64, len(c.stages))
+	for stage, n := range c.stages {
+		stages[stage] = n
+	}
+	return stages
+}
+
+// RunStage runs one step of a handler, such as a storage read or a
+// persistence write, under the handler context. That context ends at the
+// deadline of the message being handled, so op gets the time the caller
+// has left rather than a fixed timeout of its own. If op fails because
+// the deadline passed, the failure is counted under stage in the Actor's
+// DeadlinesExceeded statistics.
+//
+// RunStage must be called from the handler itself, with its context.
+func RunStage(ctx context.Context, stage string, op func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		countDeadline(ctx, stage, err)
+		return err
+	}
+
+	err := op(ctx)
+	if err != nil {
+		countDeadline(ctx, stage, err)
+	}
+	return err
+}
+
+// countDeadline counts err at stage if it is a deadline failure of the
+// handler context.
+func countDeadline(ctx context.Context, stage string, err error) {
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	run, ok := ctx.Value(actorContextKey{}).(*handlerRun)
+	if !ok || run.deadlineCounted {
+		return
+	}
+	run.deadlineCounted = true
+	run.actor.deadlines.add(stage)
+}
+
+// withMessageDeadline bounds the handler context of msg by its deadline.
+func withMessageDeadline(ctx context.Context, msg *Message) (context.Context, context.CancelFunc) {
+	if msg.Deadline.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, msg.Deadline)
+}
+
+// expired reports whether the deadline of msg passed before it was handled.
+func expired(msg *Message, now time.Time) bool {
+	return !msg.Deadline.IsZero() && !now.Before(msg.Deadline)
+}
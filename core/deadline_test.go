@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowStorageHandler writes to a storage that takes longer than callers
+// wait, or blocks on release before handling
+type slowStorageHandler struct {
+	deadlines chan time.Time
+	release   chan struct{}
+	handled   int32
+}
+
+func (h *slowStorageHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	atomic.AddInt32(&h.handled, 1)
+	if string(msg.Data) == "block" {
+		<-h.release
+		return nil
+	}
+
+	deadline, _ := ctx.Deadline()
+	h.deadlines <- deadline
+	return RunStage(ctx, "storage.write", func(ctx context.Context) error {
+		select {
+		case <-time.After(time.Second):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+func TestCallDeadlinePropagatesToStages(t *testing.T) {
+	handler := &slowStorageHandler{deadlines: make(chan time.Time, 1)}
+	a := NewActor(1, handler, NewActorOptions())
+	a.Start(context.Background())
+	defer a.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	want, _ := ctx.Deadline()
+
+	start := time.Now()
+	msg := &Message{Type: MessageTypeRequest}
+	a.Call(ctx, msg)
+	if got := <-handler.deadlines; !got.Equal(want) {
+		t.Errorf("Expected the handler deadline %v, got %v", want, got)
+	}
+	if !msg.Deadline.IsZero() {
+		t.Errorf("Expected the caller's message untouched, got deadline %v", msg.Deadline)
+	}
+
+	// The stage gives up with the caller instead of holding the mailbox
+	deadline := time.Now().Add(time.Second)
+	for a.Stats().DeadlinesExceeded["storage.write"] != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the write to stop at the deadline, took %v", elapsed)
+	}
+	if stats := a.Stats().DeadlinesExceeded; stats["storage.write"] != 1 || stats[DeadlineStageHandler] != 0 {
+		t.Errorf("Expected one exceeded deadline at storage.write, got %v", stats)
+	}
+}
+
+func TestExpiredCallIsRefusedInMailbox(t *testing.T) {
+	handler := &slowStorageHandler{deadlines: make(chan time.Time, 1), release: make(chan struct{})}
+	a := NewActor(1, handler, NewActorOptions())
+	a.Start(context.Background())
+	defer a.Stop()
+
+	a.Send(&Message{Type: MessageTypeRequest, Data: []byte("block")})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := a.Call(ctx, &Message{Type: MessageTypeRequest}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the call to time out, got %v", err)
+	}
+	close(handler.release)
+
+	deadline := time.Now().Add(time.Second)
+	for a.Stats().DeadlinesExceeded[DeadlineStageMailbox] != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := a.Stats().DeadlinesExceeded; stats[DeadlineStageMailbox] != 1 {
+		t.Errorf("Expected one exceeded deadline in the mailbox, got %v", stats)
+	}
+	if n := atomic.LoadInt32(&handler.handled); n != 1 {
+		t.Errorf("Expected the expired call not to be handled, got %d handled", n)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build sngo_debug
+
+package core
+
+// debugBuild turns on the debug-only checks of builds tagged sngo_debug.
+const debugBuild = true
@@ -0,0 +1,454 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrServiceNotDeclared is returned for operations on a service that was
+// not declared with DeclareService.
+var ErrServiceNotDeclared = errors.New("service not declared")
+
+// ServiceFactory creates the handlers of declared services of one kind.
+// The kind is what the manifest records, since code cannot be persisted:
+// after a restart the same factories are passed to RespawnServices.
+type ServiceFactory struct {
+	// Kind identifies the factory across restarts
+	Kind string
+
+	// New creates the handler of the service called name
+	New func(name string) (MessageHandler, error)
+
+	// Configure optionally sets the options that cannot be persisted,
+	// such as Supervisor and Spiller, before the service is created
+	Configure func(name string, opts *ActorOptions)
+}
+
+// ServiceDeclaration is a declared service as recorded in the manifest.
+type ServiceDeclaration struct {
+	Name       string          `json:"name"`
+	Kind       string          `json:"kind"`
+	Deps       []string        `json:"deps,omitempty"`
+	Options    DeclaredOptions `json:"options"`
+	DeclaredAt time.Time       `json:"declared_at"`
+}
+
+// DeclaredOptions are the persisted ActorOptions of a declared service.
+type DeclaredOptions struct {
+	MailboxSize    int             `json:"mailbox_size,omitempty"`
+	ProcessTimeout time.Duration   `json:"process_timeout,omitempty"`
+	MemoryBudget   int64           `json:"memory_budget,omitempty"`
+	MemoryPolicy   MemoryPolicy    `json:"memory_policy,omitempty"`
+	OverflowPolicy OverflowPolicy  `json:"overflow_policy,omitempty"`
+	RateLimit      float64         `json:"rate_limit,omitempty"`
+	RateBurst      int             `json:"rate_burst,omitempty"`
+	Concurrency    ConcurrencyMode `json:"concurrency,omitempty"`
+	MaxConcurrency int             `json:"max_concurrency,omitempty"`
+	PriorityQueue  bool            `json:"priority_queue,omitempty"`
+	YieldBudget    time.Duration   `json:"yield_budget,omitempty"`
+	YieldToUrgent  bool            `json:"yield_to_urgent,omitempty"`
+}
+
+// declaredOptions keeps the persistable part of opts.
+func declaredOptions(opts ActorOptions) DeclaredOptions {
+	return DeclaredOptions{
+		MailboxSize:    opts.MailboxSize,
+		ProcessTimeout: opts.ProcessTimeout,
+		MemoryBudget:   opts.MemoryBudget,
+		MemoryPolicy:   opts.MemoryPolicy,
+		OverflowPolicy: opts.OverflowPolicy,
+		RateLimit:      opts.RateLimit,
+		RateBurst:      opts.RateBurst,
+		Concurrency:    opts.Concurrency,
+		MaxConcurrency: opts.MaxConcurrency,
+		PriorityQueue:  opts.PriorityQueue,
+		YieldBudget:    opts.YieldBudget,
+		YieldToUrgent:  opts.YieldToUrgent,
+	}
+}
+
+// ActorOptions rebuilds the options of a declared service.
+func (o DeclaredOptions) ActorOptions(name string) ActorOptions {
+	return ActorOptions{
+		Name:           name,
+		MailboxSize:    o.MailboxSize,
+		ProcessTimeout: o.ProcessTimeout,
+		MemoryBudget:   o.MemoryBudget,
+		MemoryPolicy:   o.MemoryPolicy,
+		OverflowPolicy: o.OverflowPolicy,
+		RateLimit:      o.RateLimit,
+		RateBurst:      o.RateBurst,
+		Concurrency:    o.Concurrency,
+		MaxConcurrency: o.MaxConcurrency,
+		PriorityQueue:  o.PriorityQueue,
+		YieldBudget:    o.YieldBudget,
+		YieldToUrgent:  o.YieldToUrgent,
+	}
+}
+
+// manifestRecord is one line of the manifest file.
+type manifestRecord struct {
+	Op          string              `json:"op"`
+	Declaration *ServiceDeclaration `json:"declaration,omitempty"`
+	Name        string              `json:"name,omitempty"`
+}
+
+const (
+	manifestDeclare = "declare"
+	manifestRemove  = "remove"
+)
+
+// serviceManifest is an append-only log of declarations. Every change is
+// one JSON line synced to disk before it takes effect, so a crash loses at
+// most the change in progress; a torn last line is ignored on load.
+type serviceManifest struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	declared map[string]*ServiceDeclaration
+	records  int
+}
+
+// openServiceManifest loads the manifest at path and opens it for appends.
+func openServiceManifest(path string) (*serviceManifest, error) {
+	m := &serviceManifest{path: path, declared: make(map[string]*ServiceDeclaration)}
+
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			var record manifestRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				// Torn write of the last change before a crash
+				continue
+			}
+			m.apply(record)
+			m.records++
+		}
+		f.Close()
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read service manifest: %w", err)
+	}
+
+	// Rewrite on open, which also drops a torn tail
+	if err := m.compact(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// apply updates the declarations with a record.
+func (m *serviceManifest) apply(record manifestRecord) {
+	switch record.Op {
+	case manifestDeclare:
+		if record.Declaration != nil {
+			m.declared[record.Declaration.Name] = record.Declaration
+		}
+	case manifestRemove:
+		delete(m.declared, record.Name)
+	}
+}
+
+// append durably records a change and applies it.
+func (m *serviceManifest) append(record manifestRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return fmt.Errorf("service manifest %s is closed", m.path)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest record: %w", err)
+	}
+	if _, err := m.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write service manifest: %w", err)
+	}
+	if err := m.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync service manifest: %w", err)
+	}
+	m.apply(record)
+	m.records++
+
+	// Keep the log proportional to the live declarations
+	if m.records > 64 && m.records > 4*len(m.declared) {
+		return m.compactLocked()
+	}
+	return nil
+}
+
+// compact rewrites the manifest with only the live declarations.
+func (m *serviceManifest) compact() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.compactLocked()
+}
+
+// compactLocked writes a new manifest next to the old one and renames it
+// into place, so a crash leaves either the old or the new file.
+func (m *serviceManifest) compactLocked() error {
+	if dir := filepath.Dir(m.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create manifest directory: %w", err)
+		}
+	}
+
+	tmp := m.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write service manifest: %w", err)
+	}
+	encoder := json.NewEncoder(f)
+	for _, decl := range m.sorted() {
+		if err := encoder.Encode(manifestRecord{Op: manifestDeclare, Declaration: decl}); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write service manifest: %w", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync service manifest: %w", err)
+	}
+	f.Close()
+
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to replace service manifest: %w", err)
+	}
+
+	if m.file != nil {
+		m.file.Close()
+	}
+	m.file, err = os.OpenFile(m.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open service manifest: %w", err)
+	}
+	m.records = len(m.declared)
+	return nil
+}
+
+// sorted returns the declarations by declaration time.
+func (m *serviceManifest) sorted() []*ServiceDeclaration {
+	decls := make([]*ServiceDeclaration, 0, len(m.declared))
+	for _, decl := range m.declared {
+		decls = append(decls, decl)
+	}
+	sort.Slice(decls, func(i, j int) bool {
+		if !decls[i].DeclaredAt.Equal(decls[j].DeclaredAt) {
+			return decls[i].DeclaredAt.Before(decls[j].DeclaredAt)
+		}
+		return decls[i].Name < decls[j].Name
+	})
+	return decls
+}
+
+// close closes the manifest file.
+func (m *serviceManifest) close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return nil
+	}
+	err := m.file.Close()
+	m.file = nil
+	return err
+}
+
+// OpenServiceManifest makes the system persist its declared services to
+// the manifest at path. Declarations found in an existing manifest are
+// kept for RespawnServices.
+func (s *system) OpenServiceManifest(path string) error {
+	manifest, err := openServiceManifest(path)
+	if err != nil {
+		return err
+	}
+
+	s.declMu.Lock()
+	defer s.declMu.Unlock()
+
+	if s.manifest != nil {
+		s.manifest.close()
+	}
+	s.manifest = manifest
+	return nil
+}
+
+// DeclareService creates a named service and records it in the manifest,
+// so RespawnServices can recreate it after a crash. Its dependencies must
+// be running services.
+func (s *system) DeclareService(name string, factory ServiceFactory, opts ActorOptions, deps ...string) (*Handle, error) {
+	if factory.Kind == "" || factory.New == nil {
+		return nil, fmt.Errorf("service %s needs a factory with a kind and a constructor", name)
+	}
+	for _, dep := range deps {
+		if _, exists := s.GetService(dep); !exists {
+			return nil, fmt.Errorf("service %s depends on %s, which is not running", name, dep)
+		}
+	}
+
+	s.declMu.Lock()
+	defer s.declMu.Unlock()
+
+	decl := &ServiceDeclaration{
+		Name:       name,
+		Kind:       factory.Kind,
+		Deps:       deps,
+		Options:    declaredOptions(opts),
+		DeclaredAt: time.Now(),
+	}
+	return s.spawnDeclared(decl, factory, opts)
+}
+
+// spawnDeclared creates a declared service, then records it so a failed
+// creation is never respawned.
+func (s *system) spawnDeclared(decl *ServiceDeclaration, factory ServiceFactory, opts ActorOptions) (*Handle, error) {
+	if factory.Configure != nil {
+		factory.Configure(decl.Name, &opts)
+	}
+	handler, err := factory.New(decl.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service %s: %w", decl.Name, err)
+	}
+	handle, err := s.NewService(decl.Name, handler, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.manifest != nil {
+		if err := s.manifest.append(manifestRecord{Op: manifestDeclare, Declaration: decl}); err != nil {
+			s.stopService(decl.Name, handle)
+			return nil, err
+		}
+	}
+	return handle, nil
+}
+
+// UndeclareService stops a declared service and removes it from the
+// manifest, so it is not respawned.
+func (s *system) UndeclareService(name string) error {
+	s.declMu.Lock()
+	defer s.declMu.Unlock()
+
+	if s.manifest != nil {
+		s.manifest.mu.Lock()
+		_, declared := s.manifest.declared[name]
+		s.manifest.mu.Unlock()
+		if !declared {
+			return fmt.Errorf("%w: %s", ErrServiceNotDeclared, name)
+		}
+		if err := s.manifest.append(manifestRecord{Op: manifestRemove, Name: name}); err != nil {
+			return err
+		}
+	}
+
+	if handle, exists := s.GetService(name); exists {
+		s.stopService(name, handle)
+	}
+	return nil
+}
+
+// stopService stops a service and removes its name.
+func (s *system) stopService(name string, handle *Handle) {
+	if actor, exists := s.GetActor(handle.ActorID); exists {
+		actor.Stop()
+	}
+	s.router.UnregisterService(name)
+	s.serviceDiscovery.UnregisterService(name)
+}
+
+// DeclaredServices returns the services recorded in the manifest, in
+// declaration order.
+func (s *system) DeclaredServices() []ServiceDeclaration {
+	s.declMu.Lock()
+	defer s.declMu.Unlock()
+
+	if s.manifest == nil {
+		return nil
+	}
+	s.manifest.mu.Lock()
+	defer s.manifest.mu.Unlock()
+
+	decls := make([]ServiceDeclaration, 0, len(s.manifest.declared))
+	for _, decl := range s.manifest.sorted() {
+		decls = append(decls, *decl)
+	}
+	return decls
+}
+
+// RespawnServices recreates the services recorded in the manifest that are
+// not running, dependencies first, using the factory of each kind. A
+// service whose kind has no factory, or whose dependencies cannot be
+// started, is skipped and reported in the error; the others still start.
+func (s *system) RespawnServices(factories ...ServiceFactory) ([]*Handle, error) {
+	byKind := make(map[string]ServiceFactory, len(factories))
+	for _, factory := range factories {
+		byKind[factory.Kind] = factory
+	}
+
+	decls := s.DeclaredServices()
+	pending := make(map[string]*ServiceDeclaration, len(decls))
+	for i := range decls {
+		if _, running := s.GetService(decls[i].Name); !running {
+			pending[decls[i].Name] = &decls[i]
+		}
+	}
+
+	s.declMu.Lock()
+	defer s.declMu.Unlock()
+
+	var handles []*Handle
+	var failures []string
+	for len(pending) > 0 {
+		progress := false
+		for _, decl := range decls {
+			d, ok := pending[decl.Name]
+			if !ok || !s.depsRunning(d.Deps) {
+				continue
+			}
+			delete(pending, d.Name)
+			progress = true
+
+			factory, ok := byKind[d.Kind]
+			if !ok {
+				failures = append(failures, fmt.Sprintf("%s: no factory for kind %q", d.Name, d.Kind))
+				continue
+			}
+			handle, err := s.spawnDeclared(d, factory, d.Options.ActorOptions(d.Name))
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", d.Name, err))
+				continue
+			}
+			handles = append(handles, handle)
+		}
+		if !progress {
+			break
+		}
+	}
+
+	for name := range pending {
+		failures = append(failures, fmt.Sprintf("%s: dependencies not running", name))
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return handles, fmt.Errorf("failed to respawn services: %s", strings.Join(failures, "; "))
+	}
+	return handles, nil
+}
+
+// depsRunning returns true if all dependencies are running services.
+func (s *system) depsRunning(deps []string) bool {
+	for _, dep := range deps {
+		if _, exists := s.GetService(dep); !exists {
+			return false
+		}
+	}
+	return true
+}
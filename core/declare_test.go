@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// nopHandler accepts every message.
+type nopHandler struct{}
+
+func (nopHandler) HandleMessage(ctx context.Context, msg *Message) error { return nil }
+
+func nopFactory(kind string, created *[]string) ServiceFactory {
+	return ServiceFactory{
+		Kind: kind,
+		New: func(name string) (MessageHandler, error) {
+			*created = append(*created, name)
+			return nopHandler{}, nil
+		},
+	}
+}
+
+func TestDeclaredServicesRespawnAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.manifest")
+	var created []string
+
+	first := NewActorSystem()
+	if err := first.OpenServiceManifest(path); err != nil {
+		t.Fatalf("Failed to open manifest: %v", err)
+	}
+	if _, err := first.DeclareService("game", nopFactory("game", &created), DefaultActorOptions(), "db"); err == nil {
+		t.Error("Expected declaring a service before its dependency to fail")
+	}
+	if _, err := first.DeclareService("db", nopFactory("db", &created), NewActorOptions(WithMailboxSize(64))); err != nil {
+		t.Fatalf("Failed to declare db: %v", err)
+	}
+	if _, err := first.DeclareService("game", nopFactory("game", &created), DefaultActorOptions(), "db"); err != nil {
+		t.Fatalf("Failed to declare game: %v", err)
+	}
+	if _, err := first.DeclareService("lobby", nopFactory("lobby", &created), DefaultActorOptions()); err != nil {
+		t.Fatalf("Failed to declare lobby: %v", err)
+	}
+	if err := first.UndeclareService("lobby"); err != nil {
+		t.Fatalf("Failed to undeclare lobby: %v", err)
+	}
+	if _, exists := first.GetService("lobby"); exists {
+		t.Error("Expected undeclared service to be stopped")
+	}
+
+	// A crash tears the last write; the manifest keeps what was synced
+	first.Shutdown(context.Background())
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	f.WriteString(`{"op":"declare","declaration":{"name":"half`)
+	f.Close()
+
+	created = nil
+	second := NewActorSystem()
+	defer second.Shutdown(context.Background())
+	if err := second.OpenServiceManifest(path); err != nil {
+		t.Fatalf("Failed to reopen manifest: %v", err)
+	}
+
+	decls := second.DeclaredServices()
+	if len(decls) != 2 || decls[0].Name != "db" || decls[0].Options.MailboxSize != 64 {
+		t.Fatalf("Unexpected declarations: %+v", decls)
+	}
+
+	handles, err := second.RespawnServices(nopFactory("game", &created), nopFactory("db", &created))
+	if err != nil {
+		t.Fatalf("Failed to respawn: %v", err)
+	}
+	if len(handles) != 2 || strings.Join(created, ",") != "db,game" {
+		t.Errorf("Expected db before game, got %v", created)
+	}
+
+	for _, name := range []string{"db", "game"} {
+		if _, exists := second.GetService(name); !exists {
+			t.Errorf("Expected %s to be running", name)
+		}
+	}
+	// Running services are not respawned twice
+	if handles, err := second.RespawnServices(nopFactory("game", &created), nopFactory("db", &created)); err != nil || len(handles) != 0 {
+		t.Errorf("Expected nothing to respawn, got %d handles, %v", len(handles), err)
+	}
+}
+
+func TestRespawnReportsMissingFactories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.manifest")
+	var created []string
+
+	first := NewActorSystem()
+	first.OpenServiceManifest(path)
+	first.DeclareService("db", nopFactory("db", &created), DefaultActorOptions())
+	first.DeclareService("game", nopFactory("game", &created), DefaultActorOptions(), "db")
+	first.Shutdown(context.Background())
+
+	second := NewActorSystem()
+	defer second.Shutdown(context.Background())
+	second.OpenServiceManifest(path)
+
+	_, err := second.RespawnServices(nopFactory("game", &created))
+	if err == nil || !strings.Contains(err.Error(), `no factory for kind "db"`) ||
+		!strings.Contains(err.Error(), "game: dependencies not running") {
+		t.Errorf("Expected missing factory and dependency to be reported, got %v", err)
+	}
+}
@@ -3,4 +3,60 @@
 // This package provides the basic building blocks including Actor,
 // Message, and Router components that form the foundation of the
 // SNGO actor framework.
+//
+// Thread safety: a handler's state belongs to its Actor and may only be
+// used from HandleMessage. Goroutines started by a handler hand their
+// results back with RunOnActor. Handlers embedding ActorGuard can verify
+// this at runtime; the checks are on in builds tagged sngo_debug and can
+// be toggled with SetThreadSafetyChecks.
+//
+// Retries: CallWithRetry repeats a Call under a RetryPolicy while it fails
+// with a retryable error (see IsRetryable). Every attempt carries the same
+// Message.IdempotencyKey; handlers wrap their side effects in an
+// IdempotencyCache so a re-executed request is not applied twice.
+//
+// State machines: protocol actors can use an FSM as their handler,
+// declaring states with entry and exit actions, guarded transitions and
+// per-state timeouts instead of switching on ad-hoc state fields.
+//
+// Deadlines: Call passes the deadline of its context along with the
+// message and the handler context ends at it, so storage and persistence
+// work wrapped in RunStage fails when the caller stops waiting. Exceeded
+// deadlines are counted by stage in ActorStats.DeadlinesExceeded.
+//
+// Priority inheritance: with SetPriorityInheritance, a Call made while
+// handling a message is enqueued at the priority of that message if it is
+// higher, so an urgent request is not stuck behind the normal mailbox of a
+// lower-priority Actor it depends on. Elevated Calls are counted in
+// ActorStats.PriorityInherited and PriorityInheritances.
+//
+// Load balancing: a LoadBalancer runs a Strategy. Built-in strategies are
+// registered under the names of their LoadBalanceStrategy, including power
+// of two choices and a consistent hash ring keyed by SelectKey; custom ones
+// are added with RegisterStrategy and set per service with
+// SetServiceStrategy.
+//
+// Encryption: snapshots and journals written to disk can be sealed with
+// AES-GCM under a Keyring. Sealed data names its key, so keys rotate
+// without rewriting old data, and tampering fails with ErrIntegrity.
+//
+// Background errors: goroutines with no caller to return an error to, in
+// this and the network and cluster packages, hand it to ReportError with
+// a Severity. The application installs an ErrorReporter collecting them.
+//
+// Telemetry: SetTelemetry installs a Telemetry told about each message an
+// Actor handles and each reported error. The core has no dependency on any
+// telemetry SDK; the contrib/otelsngo module adapts it to OpenTelemetry.
+// SetTelemetryConfig labels spans, reported errors and cluster events with
+// a namespace, taken from Actor names or the process, and can drop the
+// high-cardinality per-Actor labels.
+//
+// Locked threads: Actors can run on a LockedThreadPool with
+// WithLockedThread. Each runs its message loop on its own OS thread, locked
+// with runtime.LockOSThread and optionally pinned to a CPU, away from the
+// goroutines of the rest of the process. This does not reduce jitter:
+// BenchmarkTickJitter found higher p50 and p99 tick delays on a locked
+// thread than on a plain goroutine, as every wakeup costs an OS thread
+// switch. Use it for handlers that need a fixed thread, and measure before
+// relying on it for latency.
 package core
@@ -0,0 +1,302 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrUnknownKey is returned for data sealed with a key the keyring
+	// does not hold
+	ErrUnknownKey = errors.New("unknown encryption key")
+
+	// ErrIntegrity is returned for sealed data that was corrupted or
+	// tampered with
+	ErrIntegrity = errors.New("sealed data failed integrity check")
+)
+
+// sealedMagic starts every sealed snapshot
+var sealedMagic = []byte("SNGOENC1")
+
+// KeyProvider supplies the keys protecting persisted snapshots and
+// journals. New data is sealed with the current key; older data names the
+// key it was sealed with, so keys can be rotated without rewriting it.
+type KeyProvider interface {
+	// CurrentKey returns the ID and the AES key new data is sealed with
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the given ID, or ErrUnknownKey
+	Key(id string) ([]byte, error)
+}
+
+// Keyring is a KeyProvider holding keys in memory, e.g. loaded from
+// configuration or a secrets store. It is safe for concurrent use.
+type Keyring struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring creates a keyring sealing with the key current. Keys must be
+// 16, 24 or 32 bytes long, for AES-128, AES-192 or AES-256.
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string][]byte, len(keys))}
+	for id, key := range keys {
+		if err := kr.Add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	if err := kr.SetCurrent(current); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// DecodeKeyring creates a keyring from base64 encoded keys, as they are
+// kept in configuration files and secrets stores
+func DecodeKeyring(current string, keys map[string]string) (*Keyring, error) {
+	raw := make(map[string][]byte, len(keys))
+	for id, encoded := range keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		raw[id] = key
+	}
+	return NewKeyring(current, raw)
+}
+
+// Add adds a key, e.g. a new one before rotating to it
+func (kr *Keyring) Add(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("invalid key id %q", id)
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("key %s: %w", id, err)
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys[id] = append([]byte(nil), key...)
+	return nil
+}
+
+// SetCurrent rotates to the key id; data sealed with earlier keys can
+// still be opened while they stay in the keyring
+func (kr *Keyring) SetCurrent(id string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if _, ok := kr.keys[id]; !ok {
+		return fmt.Errorf("key %s: %w", id, ErrUnknownKey)
+	}
+	kr.current = id
+	return nil
+}
+
+// Remove drops a retired key; data sealed with it can no longer be opened
+func (kr *Keyring) Remove(id string) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if id != kr.current {
+		delete(kr.keys, id)
+	}
+}
+
+// CurrentKey implements KeyProvider.
+func (kr *Keyring) CurrentKey() (string, []byte, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.current, kr.keys[kr.current], nil
+}
+
+// Key implements KeyProvider.
+func (kr *Keyring) Key(id string) ([]byte, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	key, ok := kr.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key %s: %w", id, ErrUnknownKey)
+	}
+	return key, nil
+}
+
+// sealed is data encrypted with AES-GCM under a named key. The key ID is
+// authenticated along with the data.
+type sealed struct {
+	KeyID string `json:"kid"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// seal encrypts plaintext with the current key of keys.
+func seal(keys KeyProvider, plaintext []byte) (*sealed, error) {
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return &sealed{KeyID: id, Nonce: nonce, Data: gcm.Seal(nil, nonce, plaintext, []byte(id))}, nil
+}
+
+// open decrypts and verifies s.
+func (s *sealed) open(keys KeyProvider) ([]byte, error) {
+	key, err := keys.Key(s.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != gcm.NonceSize() {
+		return nil, ErrIntegrity
+	}
+	plaintext, err := gcm.Open(nil, s.Nonce, s.Data, []byte(s.KeyID))
+	if err != nil {
+		return nil, ErrIntegrity
+	}
+	return plaintext, nil
+}
+
+// newGCM creates an AES-GCM cipher.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealSnapshot encrypts a handler snapshot for storage. The result starts
+// with a header naming the key, so it can be opened after a key rotation.
+func SealSnapshot(keys KeyProvider, snapshot []byte) ([]byte, error) {
+	s, err := seal(keys, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(sealedMagic)+1+len(s.KeyID)+len(s.Nonce)+len(s.Data))
+	out = append(out, sealedMagic...)
+	out = append(out, byte(len(s.KeyID)))
+	out = append(out, s.KeyID...)
+	out = append(out, s.Nonce...)
+	return append(out, s.Data...), nil
+}
+
+// OpenSnapshot decrypts a snapshot sealed by SealSnapshot, returning
+// ErrIntegrity if it was altered.
+func OpenSnapshot(keys KeyProvider, data []byte) ([]byte, error) {
+	if !IsSealedSnapshot(data) || len(data) < len(sealedMagic)+1 {
+		return nil, ErrIntegrity
+	}
+	rest := data[len(sealedMagic):]
+	idLen := int(rest[0])
+	rest = rest[1:]
+	if len(rest) < idLen {
+		return nil, ErrIntegrity
+	}
+	s := &sealed{KeyID: string(rest[:idLen])}
+	rest = rest[idLen:]
+
+	key, err := keys.Key(s.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize() {
+		return nil, ErrIntegrity
+	}
+	s.Nonce, s.Data = rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	return s.open(keys)
+}
+
+// IsSealedSnapshot reports whether data was written by SealSnapshot, so
+// stores can load snapshots persisted before encryption was turned on.
+func IsSealedSnapshot(data []byte) bool {
+	return bytes.HasPrefix(data, sealedMagic)
+}
+
+// EncryptedJournal writes journal entries to a writer as encrypted JSON
+// lines, each sealed on its own so a journal can span key rotations.
+type EncryptedJournal struct {
+	mu   sync.Mutex
+	keys KeyProvider
+	enc  *json.Encoder
+}
+
+// NewEncryptedJournal creates a journal sealing entries with keys.
+func NewEncryptedJournal(w io.Writer, keys KeyProvider) *EncryptedJournal {
+	return &EncryptedJournal{keys: keys, enc: json.NewEncoder(w)}
+}
+
+// Record seals and writes an entry, unless ctx is done while it waits its
+// turn.
+func (j *EncryptedJournal) Record(ctx context.Context, entry JournalEntry) error {
+	plaintext, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	s, err := seal(j.keys, plaintext)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return j.enc.Encode(s)
+}
+
+// ReadEncryptedJournal reads and verifies the entries written by an
+// EncryptedJournal.
+func ReadEncryptedJournal(r io.Reader, keys KeyProvider) ([]JournalEntry, error) {
+	var entries []JournalEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var s sealed
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		plaintext, err := s.open(keys)
+		if err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(plaintext, &entry); err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	kr, err := NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	return kr
+}
+
+func TestSealedSnapshotRotation(t *testing.T) {
+	kr := testKeyring(t)
+	old, err := SealSnapshot(kr, []byte("player state"))
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	if bytes.Contains(old, []byte("player state")) || !IsSealedSnapshot(old) {
+		t.Fatal("Expected an encrypted snapshot")
+	}
+
+	// Rotate: new snapshots use k2, old ones still open with k1
+	if err := kr.Add("k2", bytes.Repeat([]byte{2}, 16)); err != nil {
+		t.Fatalf("Failed to add key: %v", err)
+	}
+	if err := kr.SetCurrent("k2"); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	sealedNew, _ := SealSnapshot(kr, []byte("newer state"))
+
+	for data, want := range map[string]string{string(old): "player state", string(sealedNew): "newer state"} {
+		got, err := OpenSnapshot(kr, []byte(data))
+		if err != nil || string(got) != want {
+			t.Errorf("Expected %q, got %q (%v)", want, got, err)
+		}
+	}
+
+	kr.Remove("k1")
+	if _, err := OpenSnapshot(kr, old); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey after retiring k1, got %v", err)
+	}
+}
+
+func TestSealedSnapshotIntegrity(t *testing.T) {
+	kr := testKeyring(t)
+	data, _ := SealSnapshot(kr, []byte("gold=100"))
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := OpenSnapshot(kr, tampered); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Expected ErrIntegrity for a flipped bit, got %v", err)
+	}
+	if _, err := OpenSnapshot(kr, data[:len(sealedMagic)+3]); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Expected ErrIntegrity for a truncated snapshot, got %v", err)
+	}
+}
+
+func TestEncryptedJournal(t *testing.T) {
+	kr := testKeyring(t)
+	var buf bytes.Buffer
+	journal := NewEncryptedJournal(&buf, kr)
+	journal.Record(context.Background(), JournalEntry{Seq: 1, Data: []byte("secret move")})
+	kr.Add("k2", bytes.Repeat([]byte{2}, 32))
+	kr.SetCurrent("k2")
+	journal.Record(context.Background(), JournalEntry{Seq: 2})
+
+	if strings.Contains(buf.String(), "secret move") {
+		t.Fatal("Expected journal entries to be encrypted")
+	}
+	entries, err := ReadEncryptedJournal(bytes.NewReader(buf.Bytes()), kr)
+	if err != nil || len(entries) != 2 || string(entries[0].Data) != "secret move" || entries[1].Seq != 2 {
+		t.Fatalf("Unexpected entries %+v (%v)", entries, err)
+	}
+
+	// Swapping the key ID of a line breaks its authentication
+	forged := strings.Replace(buf.String(), `"kid":"k2"`, `"kid":"k1"`, 1)
+	if _, err := ReadEncryptedJournal(strings.NewReader(forged), kr); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Expected ErrIntegrity, got %v", err)
+	}
+}
+
+func TestDecodeKeyring(t *testing.T) {
+	if _, err := DecodeKeyring("k1", map[string]string{"k1": "c2hvcnQ="}); err == nil {
+		t.Error("Expected a short key to be refused")
+	}
+	if _, err := DecodeKeyring("missing", map[string]string{"k1": "MDEyMzQ1Njc4OWFiY2RlZg=="}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey for the current key, got %v", err)
+	}
+}
@@ -0,0 +1,379 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrFSMUnhandled is returned for events the current state has no
+	// transition for
+	ErrFSMUnhandled = errors.New("event not handled in current state")
+
+	// ErrFSMUnknownState is returned for transitions to undeclared states
+	ErrFSMUnknownState = errors.New("unknown state")
+)
+
+// TimeoutEvent is the event a state timeout fires unless another is given.
+const TimeoutEvent = "timeout"
+
+// FSMState names a state of an FSM.
+type FSMState string
+
+// FSMEvent is an input to an FSM. Transitions match on Name; Payload
+// carries typed data read with EventPayload.
+type FSMEvent struct {
+	Name    string
+	Payload interface{}
+
+	// Message is the actor message the event was decoded from, if any
+	Message *Message
+}
+
+// EventPayload returns the payload of ev as a T.
+func EventPayload[T any](ev FSMEvent) (T, bool) {
+	payload, ok := ev.Payload.(T)
+	return payload, ok
+}
+
+// FSMGuard decides whether a transition may be taken.
+type FSMGuard func(ctx context.Context, ev FSMEvent) bool
+
+// FSMAction runs on entry, on exit or on a transition.
+type FSMAction func(ctx context.Context, ev FSMEvent) error
+
+// FSMTransition describes what an event does in a state. The action runs
+// before the state is left, so an action error aborts the transition. An
+// empty To handles the event without leaving the state; To equal to the
+// current state re-enters it, running exit and entry actions and
+// restarting its timeout.
+type FSMTransition struct {
+	To     FSMState
+	Guard  FSMGuard
+	Action FSMAction
+}
+
+// FSMStateSpec declares the behavior of one state.
+type FSMStateSpec struct {
+	name         FSMState
+	onEnter      []FSMAction
+	onExit       []FSMAction
+	timeout      time.Duration
+	timeoutEvent string
+	transitions  map[string][]FSMTransition
+}
+
+// OnEnter adds an action run when the state is entered.
+func (s *FSMStateSpec) OnEnter(fn FSMAction) *FSMStateSpec {
+	s.onEnter = append(s.onEnter, fn)
+	return s
+}
+
+// OnExit adds an action run when the state is left.
+func (s *FSMStateSpec) OnExit(fn FSMAction) *FSMStateSpec {
+	s.onExit = append(s.onExit, fn)
+	return s
+}
+
+// Timeout fires event (TimeoutEvent if empty) if the FSM is still in the
+// state d after entering it.
+func (s *FSMStateSpec) Timeout(d time.Duration, event string) *FSMStateSpec {
+	if event == "" {
+		event = TimeoutEvent
+	}
+	s.timeout = d
+	s.timeoutEvent = event
+	return s
+}
+
+// On adds a transition for event. Transitions of the same event are tried
+// in the order added; the first whose guard passes is taken.
+func (s *FSMStateSpec) On(event string, t FSMTransition) *FSMStateSpec {
+	s.transitions[event] = append(s.transitions[event], t)
+	return s
+}
+
+// Goto is shorthand for an unguarded transition to state on event.
+func (s *FSMStateSpec) Goto(event string, to FSMState) *FSMStateSpec {
+	return s.On(event, FSMTransition{To: to})
+}
+
+// FSM is a finite state machine run by an Actor. It implements
+// MessageHandler: messages are decoded into events and drive transitions,
+// so each state behaves as a separate handler. Like any handler state it
+// belongs to its Actor; state timeouts and After timers are delivered
+// back through RunOnActor.
+//
+//	fsm := core.NewFSM("wait_hello")
+//	fsm.State("wait_hello").Timeout(5*time.Second, "").
+//		Goto("hello", "wait_auth").
+//		Goto(core.TimeoutEvent, "closed")
+//	fsm.State("wait_auth").On("auth", core.FSMTransition{To: "ready", Guard: validToken})
+type FSM struct {
+	initial FSMState
+	current FSMState
+	states  map[FSMState]*FSMStateSpec
+	any     *FSMStateSpec
+	started bool
+
+	decode       func(msg *Message) (FSMEvent, error)
+	unhandled    FSMAction
+	onTransition func(from, to FSMState, ev FSMEvent)
+	onError      func(ev FSMEvent, err error)
+
+	// epoch changes with every state change, invalidating timers armed
+	// in an earlier state
+	epoch  uint64
+	timers []*time.Timer
+
+	firing bool
+	queue  []FSMEvent
+}
+
+// NewFSM creates an FSM starting in initial.
+func NewFSM(initial FSMState) *FSM {
+	f := &FSM{
+		initial: initial,
+		current: initial,
+		states:  make(map[FSMState]*FSMStateSpec),
+		decode:  decodeMessageEvent,
+	}
+	f.any = newFSMStateSpec("*")
+	return f
+}
+
+func newFSMStateSpec(name FSMState) *FSMStateSpec {
+	return &FSMStateSpec{name: name, transitions: make(map[string][]FSMTransition)}
+}
+
+// decodeMessageEvent names an event after the message type and carries
+// the message data as payload.
+func decodeMessageEvent(msg *Message) (FSMEvent, error) {
+	return FSMEvent{Name: msg.Type.String(), Payload: msg.Data}, nil
+}
+
+// State returns the spec of state, declaring it on first use.
+func (f *FSM) State(state FSMState) *FSMStateSpec {
+	spec, ok := f.states[state]
+	if !ok {
+		spec = newFSMStateSpec(state)
+		f.states[state] = spec
+	}
+	return spec
+}
+
+// AnyState returns the spec of transitions available in every state,
+// tried after those of the current state. Its entry, exit and timeout
+// settings are ignored.
+func (f *FSM) AnyState() *FSMStateSpec {
+	return f.any
+}
+
+// Decode sets how HandleMessage turns messages into events.
+func (f *FSM) Decode(fn func(msg *Message) (FSMEvent, error)) *FSM {
+	f.decode = fn
+	return f
+}
+
+// OnUnhandled sets the action for events no transition matches; without
+// one such events fail with ErrFSMUnhandled.
+func (f *FSM) OnUnhandled(fn FSMAction) *FSM {
+	f.unhandled = fn
+	return f
+}
+
+// OnTransition sets a hook called after every state change.
+func (f *FSM) OnTransition(fn func(from, to FSMState, ev FSMEvent)) *FSM {
+	f.onTransition = fn
+	return f
+}
+
+// OnError sets the handler for errors of events fired by timers, which
+// have no caller to return them to. By default they are logged.
+func (f *FSM) OnError(fn func(ev FSMEvent, err error)) *FSM {
+	f.onError = fn
+	return f
+}
+
+// Current returns the current state.
+func (f *FSM) Current() FSMState {
+	return f.current
+}
+
+// Is returns true if the FSM is in state.
+func (f *FSM) Is(state FSMState) bool {
+	return f.current == state
+}
+
+// Validate checks that the initial state and every transition target are
+// declared.
+func (f *FSM) Validate() error {
+	if _, ok := f.states[f.initial]; !ok {
+		return fmt.Errorf("fsm initial state %q: %w", f.initial, ErrFSMUnknownState)
+	}
+	specs := []*FSMStateSpec{f.any}
+	for _, spec := range f.states {
+		specs = append(specs, spec)
+	}
+	for _, spec := range specs {
+		for event, transitions := range spec.transitions {
+			for _, t := range transitions {
+				if _, ok := f.states[t.To]; t.To != "" && !ok {
+					return fmt.Errorf("fsm state %s on %q to %q: %w", spec.name, event, t.To, ErrFSMUnknownState)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Start validates the FSM and enters the initial state. It is called by
+// the first Fire if not called before.
+func (f *FSM) Start(ctx context.Context) error {
+	if f.started {
+		return nil
+	}
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	f.started = true
+	return f.enter(ctx, FSMEvent{Name: "start"})
+}
+
+// HandleMessage implements MessageHandler by firing the decoded event.
+func (f *FSM) HandleMessage(ctx context.Context, msg *Message) error {
+	ev, err := f.decode(msg)
+	if err != nil {
+		return err
+	}
+	ev.Message = msg
+	return f.Fire(ctx, ev)
+}
+
+// Fire processes ev. Events raised while an event is being processed are
+// queued and processed after it, so every transition runs to completion.
+func (f *FSM) Fire(ctx context.Context, ev FSMEvent) error {
+	if err := f.Start(ctx); err != nil {
+		return err
+	}
+	if f.firing {
+		f.queue = append(f.queue, ev)
+		return nil
+	}
+
+	f.firing = true
+	defer func() {
+		f.firing = false
+		f.queue = nil
+	}()
+
+	err := f.fire(ctx, ev)
+	for err == nil && len(f.queue) > 0 {
+		next := f.queue[0]
+		f.queue = f.queue[1:]
+		err = f.fire(ctx, next)
+	}
+	return err
+}
+
+// Raise queues ev from an action, to be processed once the current event
+// completes.
+func (f *FSM) Raise(ev FSMEvent) {
+	f.queue = append(f.queue, ev)
+}
+
+// After fires ev after d unless the state changes first. ctx must be the
+// context of the Actor's handler.
+func (f *FSM) After(ctx context.Context, d time.Duration, ev FSMEvent) {
+	epoch := f.epoch
+	timer := time.AfterFunc(d, func() {
+		err := RunOnActor(ctx, func(ctx context.Context) {
+			if f.epoch != epoch {
+				return
+			}
+			if err := f.Fire(ctx, ev); err != nil {
+				f.reportError(ev, err)
+			}
+		})
+		if err != nil {
+			f.reportError(ev, err)
+		}
+	})
+	f.timers = append(f.timers, timer)
+}
+
+// fire takes the first matching transition for ev.
+func (f *FSM) fire(ctx context.Context, ev FSMEvent) error {
+	for _, spec := range []*FSMStateSpec{f.states[f.current], f.any} {
+		for _, t := range spec.transitions[ev.Name] {
+			if t.Guard != nil && !t.Guard(ctx, ev) {
+				continue
+			}
+			return f.take(ctx, t, ev)
+		}
+	}
+
+	if f.unhandled != nil {
+		return f.unhandled(ctx, ev)
+	}
+	return fmt.Errorf("fsm event %q in state %s: %w", ev.Name, f.current, ErrFSMUnhandled)
+}
+
+// take runs the action of t and moves to its target state.
+func (f *FSM) take(ctx context.Context, t FSMTransition, ev FSMEvent) error {
+	if t.Action != nil {
+		if err := t.Action(ctx, ev); err != nil {
+			return err
+		}
+	}
+	if t.To == "" {
+		return nil
+	}
+
+	from := f.current
+	for _, fn := range f.states[from].onExit {
+		if err := fn(ctx, ev); err != nil {
+			return fmt.Errorf("fsm exit %s: %w", from, err)
+		}
+	}
+	f.stopTimers()
+	f.current = t.To
+
+	if f.onTransition != nil {
+		f.onTransition(from, t.To, ev)
+	}
+	return f.enter(ctx, ev)
+}
+
+// enter arms the timeout of the current state and runs its entry actions.
+func (f *FSM) enter(ctx context.Context, ev FSMEvent) error {
+	spec := f.states[f.current]
+	if spec.timeout > 0 {
+		f.After(ctx, spec.timeout, FSMEvent{Name: spec.timeoutEvent})
+	}
+	for _, fn := range spec.onEnter {
+		if err := fn(ctx, ev); err != nil {
+			return fmt.Errorf("fsm enter %s: %w", f.current, err)
+		}
+	}
+	return nil
+}
+
+// stopTimers cancels the timers of the state being left.
+func (f *FSM) stopTimers() {
+	f.epoch++
+	for _, timer := range f.timers {
+		timer.Stop()
+	}
+	f.timers = nil
+}
+
+func (f *FSM) reportError(ev FSMEvent, err error) {
+	if f.onError != nil {
+		f.onError(ev, err)
+		return
+	}
+	ReportError("core", "fsm", SeverityError, fmt.Errorf("timer event %q: %w", ev.Name, err))
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSessionFSM builds a login session: hello, then an authenticated
+// token, with a timeout on each waiting state
+func newSessionFSM(transitions chan<- FSMState) *FSM {
+	fsm := NewFSM("wait_hello")
+	fsm.Decode(func(msg *Message) (FSMEvent, error) {
+		name, payload, _ := strings.Cut(string(msg.Data), ":")
+		return FSMEvent{Name: name, Payload: payload}, nil
+	})
+	fsm.OnTransition(func(from, to FSMState, ev FSMEvent) { transitions <- to })
+
+	validToken := func(ctx context.Context, ev FSMEvent) bool {
+		token, ok := EventPayload[string](ev)
+		return ok && token == "secret"
+	}
+
+	fsm.State("wait_hello").Timeout(50*time.Millisecond, "").
+		Goto("hello", "wait_auth").
+		Goto(TimeoutEvent, "closed")
+	fsm.State("wait_auth").Timeout(50*time.Millisecond, "").
+		On("auth", FSMTransition{To: "ready", Guard: validToken}).
+		Goto(TimeoutEvent, "closed")
+	fsm.State("ready").
+		OnEnter(func(ctx context.Context, ev FSMEvent) error {
+			fsm.Raise(FSMEvent{Name: "welcome"})
+			return nil
+		}).
+		Goto("welcome", "playing")
+	fsm.State("playing")
+	fsm.State("closed")
+	fsm.AnyState().Goto("disconnect", "closed")
+	return fsm
+}
+
+// expectTransitions waits for the FSM to pass through states
+func expectTransitions(t *testing.T, transitions <-chan FSMState, states ...FSMState) {
+	t.Helper()
+	for _, want := range states {
+		select {
+		case got := <-transitions:
+			if got != want {
+				t.Fatalf("Expected transition to %s, got %s", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for transition to %s", want)
+		}
+	}
+}
+
+func startFSMActor(t *testing.T, fsm *FSM) Actor {
+	t.Helper()
+	a := NewActor(1, fsm, DefaultActorOptions())
+	if err := a.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start actor: %v", err)
+	}
+	t.Cleanup(func() { a.Stop() })
+	return a
+}
+
+func TestFSMDrivesProtocolActor(t *testing.T) {
+	transitions := make(chan FSMState, 8)
+	a := startFSMActor(t, newSessionFSM(transitions))
+	call := func(data string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		resp, err := a.Call(ctx, &Message{Type: MessageTypeRequest, Data: []byte(data)})
+		if err == nil && resp.Type == MessageTypeError {
+			err = errors.New(string(resp.Data))
+		}
+		return err
+	}
+
+	if err := call("hello"); err != nil {
+		t.Fatalf("Expected hello to be accepted, got %v", err)
+	}
+	expectTransitions(t, transitions, "wait_auth")
+
+	// The guard rejects a wrong token, leaving the event unhandled
+	if err := call("auth:guess"); err == nil || !strings.Contains(err.Error(), ErrFSMUnhandled.Error()) {
+		t.Errorf("Expected unhandled auth with a bad token, got %v", err)
+	}
+
+	// Entering ready raises welcome, processed once auth completes
+	if err := call("auth:secret"); err != nil {
+		t.Fatalf("Expected auth to be accepted, got %v", err)
+	}
+	expectTransitions(t, transitions, "ready", "playing")
+
+	if err := call("disconnect"); err != nil {
+		t.Fatalf("Expected disconnect from any state, got %v", err)
+	}
+	expectTransitions(t, transitions, "closed")
+}
+
+func TestFSMStateTimeout(t *testing.T) {
+	transitions := make(chan FSMState, 8)
+	a := startFSMActor(t, newSessionFSM(transitions))
+
+	if err := a.Send(&Message{Type: MessageTypeRequest, Data: []byte("hello")}); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	// The timeout of wait_hello is cancelled on leaving it; the one of
+	// wait_auth closes the session
+	expectTransitions(t, transitions, "wait_auth", "closed")
+
+	select {
+	case to := <-transitions:
+		t.Errorf("Expected stale timers to be ignored, got transition to %s", to)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFSMValidateAndActionErrors(t *testing.T) {
+	fsm := NewFSM("idle")
+	fsm.State("idle").Goto("go", "missing")
+	if err := fsm.Start(context.Background()); !errors.Is(err, ErrFSMUnknownState) {
+		t.Errorf("Expected ErrFSMUnknownState, got %v", err)
+	}
+
+	fsm = NewFSM("idle")
+	exited := false
+	fsm.State("idle").
+		OnExit(func(ctx context.Context, ev FSMEvent) error {
+			exited = true
+			return nil
+		}).
+		On("go", FSMTransition{To: "busy", Action: func(ctx context.Context, ev FSMEvent) error {
+			return errors.New("refused")
+		}}).
+		On("noop", FSMTransition{})
+	fsm.State("busy")
+
+	if err := fsm.Fire(context.Background(), FSMEvent{Name: "go"}); err == nil {
+		t.Error("Expected action error to abort the transition")
+	}
+	if !fsm.Is("idle") || exited {
+		t.Errorf("Expected to stay in idle without exiting, got %s", fsm.Current())
+	}
+	if err := fsm.Fire(context.Background(), FSMEvent{Name: "noop"}); err != nil || exited {
+		t.Errorf("Expected internal transition without exit, got %v", err)
+	}
+}
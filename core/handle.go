@@ -83,6 +83,9 @@ type HandleManager struct {
 
 	// Local node ID
 	nodeID uint32
+
+	// Rules for service names
+	policy NamePolicy
 }
 
 // NewHandleManager creates a new HandleManager.
@@ -93,11 +96,17 @@ func NewHandleManager(nodeID uint32) *HandleManager {
 		nameToHandle:  make(map[string]uint32),
 		nodeID:        nodeID,
 		handleCounter: nodeID<<24 + 1, // Encode node ID in high bits
+		policy:        DefaultNamePolicy(),
 	}
 }
 
 // AllocateHandle creates a new handle for an actor.
 func (hm *HandleManager) AllocateHandle(actorID ActorID, name string) (*Handle, error) {
+	return hm.allocateHandle(actorID, name, false)
+}
+
+// allocateHandle creates a handle, validating the name for user or system code.
+func (hm *HandleManager) allocateHandle(actorID ActorID, name string, system bool) (*Handle, error) {
 	hm.mu.Lock()
 	defer hm.mu.Unlock()
 
@@ -106,8 +115,16 @@ func (hm *HandleManager) AllocateHandle(actorID ActorID, name string) (*Handle,
 		return hm.handles[existingHandleID], nil
 	}
 
-	// Check if name is already taken
+	// Check if name is valid and not already taken
 	if name != "" {
+		validate := hm.policy.Validate
+		if system {
+			validate = hm.policy.ValidateSystem
+		}
+		if err := validate(name); err != nil {
+			return nil, err
+		}
+
 		if _, exists := hm.nameToHandle[name]; exists {
 			return nil, fmt.Errorf("service name '%s' already exists", name)
 		}
@@ -187,6 +204,28 @@ func (hm *HandleManager) ReleaseHandle(handleID uint32) error {
 	return nil
 }
 
+// SwapActors exchanges the actors bound to two handles in a single step,
+// so lookups by either handle ID or name never observe a half-updated state.
+func (hm *HandleManager) SwapActors(a, b uint32) error {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	ha, exists := hm.handles[a]
+	if !exists {
+		return fmt.Errorf("handle %d not found", a)
+	}
+	hb, exists := hm.handles[b]
+	if !exists {
+		return fmt.Errorf("handle %d not found", b)
+	}
+
+	ha.ActorID, hb.ActorID = hb.ActorID, ha.ActorID
+	hm.actorToHandle[ha.ActorID] = a
+	hm.actorToHandle[hb.ActorID] = b
+
+	return nil
+}
+
 // ListHandles returns all handles.
 func (hm *HandleManager) ListHandles() []*Handle {
 	hm.mu.RLock()
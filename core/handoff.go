@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+)
+
+// Handoff transfers the state of the service behind from to the Actor behind
+// to, enabling blue/green replacement of a live service instance.
+//
+// The source Actor is paused, its handler snapshot is restored into the
+// target handler, every message still queued in the source mailbox is moved
+// to the target in order, and the two handles swap Actors so the source
+// handle (and its name) now addresses the target. The source Actor is then
+// stopped and its leftover handle released; messages sent to it in the
+// meantime are forwarded to the target instead of being dropped.
+//
+// Both handlers must implement Snapshotter.
+func (s *system) Handoff(from, to *Handle) error {
+	if from == nil || to == nil {
+		return fmt.Errorf("handoff requires both source and target handles")
+	}
+	if !from.IsLocal || !to.IsLocal {
+		return fmt.Errorf("handoff is only supported between local services")
+	}
+	if from.ID == to.ID {
+		return fmt.Errorf("cannot hand off service %s to itself", from)
+	}
+
+	src, err := s.localActor(from)
+	if err != nil {
+		return err
+	}
+	dst, err := s.localActor(to)
+	if err != nil {
+		return err
+	}
+
+	srcSnap, ok := src.handler.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("source service %s does not support snapshots", from)
+	}
+	dstSnap, ok := dst.handler.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("target service %s does not support snapshots", to)
+	}
+
+	resumeSrc, err := src.pause()
+	if err != nil {
+		return err
+	}
+	defer resumeSrc()
+
+	resumeDst, err := dst.pause()
+	if err != nil {
+		return err
+	}
+
+	data, err := srcSnap.Snapshot()
+	if err != nil {
+		resumeDst()
+		return fmt.Errorf("failed to snapshot service %s: %w", from, err)
+	}
+
+	if err := dstSnap.Restore(data); err != nil {
+		resumeDst()
+		return fmt.Errorf("failed to restore snapshot into service %s: %w", to, err)
+	}
+
+	// The target must run again before the backlog is moved, otherwise a
+	// full target mailbox would block the transfer forever.
+	resumeDst()
+
+	if err := src.forwardTo(dst); err != nil {
+		return err
+	}
+
+	if err := s.router.GetHandleManager().SwapActors(from.ID, to.ID); err != nil {
+		return fmt.Errorf("failed to swap handles: %w", err)
+	}
+
+	// The source Actor is now bound to the target's old handle; drop both.
+	// The handle is released first so it goes regardless of whether the
+	// router releases the handles of the Actors it unregisters.
+	resumeSrc()
+	if err := s.router.GetHandleManager().ReleaseHandle(to.ID); err != nil {
+		return fmt.Errorf("failed to release handle %d: %w", to.ID, err)
+	}
+	if err := s.router.Unregister(src.id); err != nil {
+		return fmt.Errorf("failed to unregister source actor: %w", err)
+	}
+	if to.Name != "" {
+		s.serviceDiscovery.UnregisterService(to.Name)
+	}
+
+	return src.Stop()
+}
+
+// localActor resolves a local handle to its concrete Actor implementation.
+func (s *system) localActor(h *Handle) (*actor, error) {
+	a, exists := s.router.Lookup(h.ActorID)
+	if !exists {
+		return nil, fmt.Errorf("service %s not found", h)
+	}
+
+	impl, ok := a.(*actor)
+	if !ok {
+		return nil, fmt.Errorf("service %s does not support handoff", h)
+	}
+
+	return impl, nil
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// counterHandler counts messages and supports snapshots of its counter.
+type counterHandler struct {
+	mu    sync.Mutex
+	count int
+	block chan struct{}
+}
+
+func (h *counterHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	if h.block != nil {
+		<-h.block
+	}
+	h.mu.Lock()
+	h.count++
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *counterHandler) Snapshot() ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return []byte(strconv.Itoa(h.count)), nil
+}
+
+func (h *counterHandler) Restore(data []byte) error {
+	n, err := strconv.Atoi(string(data))
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.count = n
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *counterHandler) Count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// handleManagerOf returns the handles allocated by an actor system
+func handleManagerOf(s ActorSystem) *HandleManager {
+	return s.(*system).router.GetHandleManager()
+}
+
+func TestHandoff(t *testing.T) {
+	system := NewActorSystem()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		system.Shutdown(ctx)
+	}()
+
+	blue := &counterHandler{}
+	green := &counterHandler{}
+
+	blueHandle, err := system.NewService("counter", blue, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create blue service: %v", err)
+	}
+	greenHandle, err := system.NewService("counter-green", green, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create green service: %v", err)
+	}
+	blueActorID, greenActorID := blueHandle.ActorID, greenHandle.ActorID
+	handles := handleManagerOf(system)
+	before := len(handles.ListHandles())
+
+	for i := 0; i < 5; i++ {
+		if err := system.SendByName("", "counter", MessageTypeText, nil); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if err := system.Handoff(blueHandle, greenHandle); err != nil {
+		t.Fatalf("Handoff failed: %v", err)
+	}
+
+	handle, exists := system.GetService("counter")
+	if !exists {
+		t.Fatal("Service name should survive handoff")
+	}
+	if handle.ActorID != greenActorID {
+		t.Errorf("Expected name to address actor %d, got %d", greenActorID, handle.ActorID)
+	}
+	if _, exists := system.GetService("counter-green"); exists {
+		t.Error("Target's old name should be released")
+	}
+	if _, exists := system.GetActor(blueActorID); exists {
+		t.Error("Source actor should be unregistered")
+	}
+	if _, exists := handles.GetHandleByName("counter-green"); exists {
+		t.Error("Target's old handle should be released")
+	}
+	if after := len(handles.ListHandles()); after != before-1 {
+		t.Errorf("Expected %d handles after handoff, got %d", before-1, after)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := system.SendByName("", "counter", MessageTypeText, nil); err != nil {
+			t.Fatalf("Failed to send after handoff: %v", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if got := green.Count(); got != 8 {
+		t.Errorf("Expected target count 8, got %d", got)
+	}
+}
+
+func TestHandoffForwardsMailbox(t *testing.T) {
+	system := NewActorSystem()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		system.Shutdown(ctx)
+	}()
+
+	blue := &counterHandler{block: make(chan struct{})}
+	green := &counterHandler{}
+
+	blueHandle, _ := system.NewService("queue", blue, DefaultActorOptions())
+	greenHandle, _ := system.NewService("queue-green", green, DefaultActorOptions())
+
+	// The first message blocks the handler; the rest stay queued.
+	for i := 0; i < 4; i++ {
+		system.SendByName("", "queue", MessageTypeText, nil)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- system.Handoff(blueHandle, greenHandle) }()
+
+	time.Sleep(10 * time.Millisecond)
+	close(blue.block)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Handoff failed: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if got := green.Count(); got != 4 {
+		t.Errorf("Expected all 4 messages accounted for on target, got %d", got)
+	}
+}
+
+func TestHandoffRequiresSnapshotter(t *testing.T) {
+	system := NewActorSystem()
+	defer system.Shutdown(context.Background())
+
+	a, _ := system.NewService("plain-a", &echoHandler{}, DefaultActorOptions())
+	b, _ := system.NewService("plain-b", &echoHandler{}, DefaultActorOptions())
+
+	if err := system.Handoff(a, b); err == nil {
+		t.Error("Expected handoff without Snapshotter to fail")
+	}
+}
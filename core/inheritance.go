@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// priorityInheritance is the priority inheritance setting of a system and
+// its count of inheritance events, shared with its Actors.
+type priorityInheritance struct {
+	enabled int32  // atomic
+	events  uint64 // atomic
+}
+
+// SetPriorityInheritance makes a Call issued while handling a message carry
+// the priority of that message when it is higher than the request's own,
+// so a high-priority request is not held up behind the normal mailbox of
+// the lower-priority Actors it calls into. It is off by default.
+func (s *system) SetPriorityInheritance(enabled bool) {
+	var flag int32
+	if enabled {
+		flag = 1
+	}
+	atomic.StoreInt32(&s.inheritance.enabled, flag)
+}
+
+// PriorityInheritances returns how many Calls were elevated to the
+// priority of the message their caller was handling.
+func (s *system) PriorityInheritances() uint64 {
+	return atomic.LoadUint64(&s.inheritance.events)
+}
+
+// inheritPriority raises the priority of a request to that of the message
+// the calling handler is running, if the system enables inheritance.
+func (a *actor) inheritPriority(ctx context.Context, msg *Message) {
+	if a.inheritance == nil || atomic.LoadInt32(&a.inheritance.enabled) == 0 {
+		return
+	}
+	run, ok := ctx.Value(actorContextKey{}).(*handlerRun)
+	if !ok || run.priority <= msg.Priority {
+		return
+	}
+	msg.Priority = run.priority
+	atomic.AddUint64(&a.inherited, 1)
+	atomic.AddUint64(&a.inheritance.events, 1)
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// relayHandler calls into callee while handling each message.
+type relayHandler struct {
+	callee Actor
+	errs   chan error
+}
+
+func (h *relayHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	_, err := h.callee.Call(ctx, &Message{Type: MessageTypeRequest, Data: []byte("lookup")})
+	h.errs <- err
+	return nil
+}
+
+func TestPriorityInheritance(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+	sys.SetPriorityInheritance(true)
+
+	opts := DefaultActorOptions()
+	opts.PriorityQueue = true
+	inventory := &gateHandler{gate: make(chan struct{})}
+	callee, err := sys.NewActor(inventory, opts)
+	if err != nil {
+		t.Fatalf("Failed to create actor: %v", err)
+	}
+	relay := &relayHandler{callee: callee, errs: make(chan error, 1)}
+	caller, err := sys.NewActor(relay, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create actor: %v", err)
+	}
+
+	// The callee is busy, with a backlog of normal messages
+	waitUntil := func(cond func() bool) {
+		deadline := time.Now().Add(time.Second)
+		for !cond() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	callee.Send(&Message{Type: MessageTypeRequest, Data: []byte("hold")})
+	waitUntil(func() bool { return callee.Stats().State == ActorStateRunning })
+	for _, data := range []string{"backlog-1", "backlog-2"} {
+		callee.Send(&Message{Type: MessageTypeRequest, Data: []byte(data)})
+	}
+
+	// An urgent message reaches the caller, whose Call inherits its priority
+	caller.Send(&Message{Type: MessageTypeRequest, Priority: 1, Data: []byte("alert")})
+	waitUntil(func() bool { return callee.Stats().MailboxSize == 3 })
+	close(inventory.gate)
+
+	if err := <-relay.errs; err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	var order []string
+	inventory.mu.Lock()
+	for _, msg := range inventory.copies {
+		order = append(order, string(msg.Data))
+	}
+	inventory.mu.Unlock()
+	if len(order) < 2 || order[1] != "lookup" {
+		t.Errorf("Expected the inherited call to overtake the backlog, got %v", order)
+	}
+
+	if n := sys.PriorityInheritances(); n != 1 {
+		t.Errorf("Expected 1 inheritance event, got %d", n)
+	}
+	if n := callee.Stats().PriorityInherited; n != 1 {
+		t.Errorf("Expected the callee to count 1 inherited call, got %d", n)
+	}
+
+	// Calls made outside of a handler have nothing to inherit
+	if _, err := callee.Call(context.Background(), &Message{Type: MessageTypeRequest}); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if n := sys.PriorityInheritances(); n != 1 {
+		t.Errorf("Expected no inheritance outside of a handler, got %d", n)
+	}
+}
@@ -22,9 +22,14 @@ type Actor interface {
 	Start(ctx context.Context) error
 
 	// Stop gracefully shuts down the Actor.
-	// It will finish processing the current message before stopping.
+	// Every message accepted by Send before Stop is processed first;
+	// messages sent afterwards are rejected.
 	Stop() error
 
+	// ForceStop shuts down the Actor without processing queued messages.
+	// It will finish processing the current message before stopping.
+	ForceStop() error
+
 	// Send sends a message to this Actor's mailbox.
 	// It returns an error if the Actor is stopped or mailbox is full.
 	Send(msg *Message) error
@@ -101,6 +106,97 @@ type ActorSystem interface {
 
 	// SetLoadBalanceStrategy sets the load balancing strategy
 	SetLoadBalanceStrategy(strategy LoadBalanceStrategy) error
+
+	// SetServiceStrategy overrides the strategy of one service by name
+	SetServiceStrategy(name, strategy string) error
+
+	// Handoff transfers the state and pending messages of one live service
+	// to another and rebinds the source handle to the target Actor.
+	Handoff(from, to *Handle) error
+
+	// SetMemoryBudget sets the system-wide memory budget in bytes (0 disables it)
+	SetMemoryBudget(bytes int64)
+
+	// AttachMemory records memory held by an Actor outside its mailbox
+	AttachMemory(id ActorID, key string, bytes int64) error
+
+	// TopMemoryConsumers returns the Actors holding the most memory
+	TopMemoryConsumers(n int) []ActorMemoryUsage
+
+	// StartProfiler starts attributing wall time to Actors by sampling.
+	StartProfiler(opts ProfilerOptions) error
+
+	// StopProfiler stops the profiler and returns its final report.
+	StopProfiler() ProfileReport
+
+	// ProfileReport returns the busiest Actors over the profiler window.
+	ProfileReport(n int) (ProfileReport, error)
+
+	// SetClock replaces the clock handlers read the time from.
+	SetClock(clock Clock)
+
+	// Clock returns the system clock.
+	Clock() Clock
+
+	// StartJournal records every handled message for later replay.
+	StartJournal(recorder JournalRecorder) error
+
+	// StopJournal stops recording messages.
+	StopJournal()
+
+	// Subscribe copies matching messages handled system-wide to an auditing Actor.
+	Subscribe(subscriber ActorID, filter AuditFilter) (*AuditSubscription, error)
+
+	// Unsubscribe stops an audit subscription.
+	Unsubscribe(sub *AuditSubscription)
+
+	// SetPriorityInheritance makes Calls made by a handler inherit the
+	// priority of the message it handles.
+	SetPriorityInheritance(enabled bool)
+
+	// PriorityInheritances returns how many Calls inherited a priority.
+	PriorityInheritances() uint64
+
+	// SetNamePolicy replaces the rules service names must follow.
+	SetNamePolicy(policy NamePolicy)
+
+	// NewSystemService creates a named service that may use reserved prefixes.
+	NewSystemService(name string, handler MessageHandler, opts ActorOptions) (*Handle, error)
+
+	// ListNamespace returns the services registered under a namespace.
+	ListNamespace(namespace string) []*Handle
+
+	// NewTickService starts delivering fixed-rate ticks to subscribed Actors.
+	NewTickService(opts TickOptions) (*TickService, error)
+
+	// Broadcast sends a message to a group in a system-wide order and can
+	// wait until every member has handled it.
+	Broadcast(ctx context.Context, from ActorID, members []ActorID, msgType MessageType, data []byte, opts BroadcastOptions) (*BroadcastResult, error)
+
+	// OpenServiceManifest persists declared services to a manifest file.
+	OpenServiceManifest(path string) error
+
+	// DeclareService creates a named service recorded in the manifest.
+	DeclareService(name string, factory ServiceFactory, opts ActorOptions, deps ...string) (*Handle, error)
+
+	// UndeclareService stops a declared service and forgets it.
+	UndeclareService(name string) error
+
+	// DeclaredServices returns the services recorded in the manifest.
+	DeclaredServices() []ServiceDeclaration
+
+	// RespawnServices recreates the declared services that are not running.
+	RespawnServices(factories ...ServiceFactory) ([]*Handle, error)
+}
+
+// Snapshotter is implemented by message handlers whose state can be
+// captured and restored, e.g. to hand a service over to a new instance.
+type Snapshotter interface {
+	// Snapshot serializes the handler's current state.
+	Snapshot() ([]byte, error)
+
+	// Restore replaces the handler's state with a previous snapshot.
+	Restore(data []byte) error
 }
 
 // Supervisor monitors Actor health and handles failures.
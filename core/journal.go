@@ -0,0 +1,284 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the time source of an ActorSystem. Handlers that read the time
+// through ActorSystem.Clock behave identically when their messages are
+// replayed with a FakeClock.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+}
+
+// systemClock reads the wall clock.
+type systemClock struct{}
+
+// Now returns time.Now().
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a manually driven Clock for tests and replays.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock creates a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// JournalEntry records one message as it was taken from an Actor's mailbox.
+type JournalEntry struct {
+	// Seq orders entries across the whole system
+	Seq uint64 `json:"seq"`
+
+	// Time is the system clock when the message was handled
+	Time time.Time `json:"time"`
+
+	// Actor that handled the message, and its service name if it had one
+	Actor     ActorID `json:"actor"`
+	ActorName string  `json:"actor_name,omitempty"`
+
+	// Message fields
+	ID        uint64      `json:"id,omitempty"`
+	Type      MessageType `json:"type"`
+	Source    ActorID     `json:"source"`
+	Session   uint32      `json:"session,omitempty"`
+	Data      []byte      `json:"data,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// Message rebuilds the journaled message.
+func (e JournalEntry) Message() *Message {
+	return &Message{
+		ID:        e.ID,
+		Type:      e.Type,
+		Source:    e.Source,
+		Target:    e.Actor,
+		Session:   e.Session,
+		Data:      e.Data,
+		Timestamp: e.Timestamp,
+
+		IdempotencyKey: e.IdempotencyKey,
+	}
+}
+
+// JournalRecorder stores journal entries. Record is called from the message
+// loops of all Actors and must be safe for concurrent use; ctx ends at the
+// deadline of the message recorded, if it has one.
+type JournalRecorder interface {
+	Record(ctx context.Context, entry JournalEntry) error
+}
+
+// MemoryJournal keeps journal entries in memory.
+type MemoryJournal struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+}
+
+// NewMemoryJournal creates an empty in-memory journal.
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{}
+}
+
+// Record appends an entry.
+func (j *MemoryJournal) Record(ctx context.Context, entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+// Entries returns a copy of the recorded entries.
+func (j *MemoryJournal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// JSONJournal writes journal entries to a writer as JSON lines.
+type JSONJournal struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONJournal creates a journal writing one JSON object per line to w.
+func NewJSONJournal(w io.Writer) *JSONJournal {
+	return &JSONJournal{enc: json.NewEncoder(w)}
+}
+
+// Record writes an entry, unless ctx is done while it waits its turn.
+func (j *JSONJournal) Record(ctx context.Context, entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return j.enc.Encode(entry)
+}
+
+// ReadJournal reads the entries written by a JSONJournal.
+func ReadJournal(r io.Reader) ([]JournalEntry, error) {
+	var entries []JournalEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return entries, nil
+}
+
+// journal is the journaling and clock state a system shares with its Actors.
+// Actors read it on every message, so the state is kept in atomics and a
+// system that is not journaling pays a single atomic load; mu only
+// serializes starting and stopping journals and replays.
+type journal struct {
+	mu        sync.Mutex
+	recording atomic.Pointer[journalRecording]
+	clock     atomic.Pointer[clockBox]
+	replaying atomic.Bool
+}
+
+// journalRecording is a running journal.
+type journalRecording struct {
+	recorder JournalRecorder
+	seq      atomic.Uint64
+}
+
+// clockBox holds a Clock for atomic replacement.
+type clockBox struct {
+	Clock
+}
+
+// now returns the time of the system clock.
+func (j *journal) now() time.Time {
+	return j.getClock().Now()
+}
+
+// getClock returns the system clock.
+func (j *journal) getClock() Clock {
+	return j.clock.Load().Clock
+}
+
+// setClock replaces the system clock.
+func (j *journal) setClock(clock Clock) {
+	j.clock.Store(&clockBox{clock})
+}
+
+// isReplaying returns true while a Replayer drives the system.
+func (j *journal) isReplaying() bool {
+	return j.replaying.Load()
+}
+
+// record journals a message about to be handled by a.
+func (j *journal) record(a *actor, msg *Message) {
+	recording := j.recording.Load()
+	if recording == nil {
+		return
+	}
+	entry := JournalEntry{
+		Seq:       recording.seq.Add(1),
+		Time:      j.now(),
+		Actor:     a.id,
+		ActorName: a.name,
+		ID:        msg.ID,
+		Type:      msg.Type,
+		Source:    msg.Source,
+		Session:   msg.Session,
+		Data:      msg.Data,
+		Timestamp: msg.Timestamp,
+
+		IdempotencyKey: msg.IdempotencyKey,
+	}
+
+	ctx, cancel := withMessageDeadline(a.ctx, msg)
+	defer cancel()
+
+	// A failing recorder must not stop message processing
+	_ = recording.recorder.Record(ctx, entry)
+}
+
+// SetClock replaces the system clock.
+func (s *system) SetClock(clock Clock) {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	s.journal.setClock(clock)
+}
+
+// Clock returns the system clock.
+func (s *system) Clock() Clock {
+	return s.journal.getClock()
+}
+
+// StartJournal records every message handled by the system's Actors, in the
+// order they are taken from the mailboxes, until StopJournal is called.
+func (s *system) StartJournal(recorder JournalRecorder) error {
+	if recorder == nil {
+		return fmt.Errorf("journal recorder is nil")
+	}
+
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+
+	if s.journal.recording.Load() != nil {
+		return fmt.Errorf("journal is already running")
+	}
+	if s.journal.isReplaying() {
+		return fmt.Errorf("cannot journal a replaying system")
+	}
+	s.journal.recording.Store(&journalRecording{recorder: recorder})
+	return nil
+}
+
+// StopJournal stops recording messages.
+func (s *system) StopJournal() {
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+	s.journal.recording.Store(nil)
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNoLockedThread is returned when starting an Actor on a LockedThreadPool
+// whose threads are all taken.
+var ErrNoLockedThread = errors.New("no free locked thread")
+
+// ErrLockedThreadPoolClosed is returned when starting an Actor on a closed
+// LockedThreadPool.
+var ErrLockedThreadPoolClosed = errors.New("locked thread pool is closed")
+
+// LockedThreadConfig configures a LockedThreadPool.
+type LockedThreadConfig struct {
+	// Threads is the number of OS threads, each running the message loop of
+	// one Actor (0 means 1). A locked thread holds a P only while its Actor
+	// handles a message and releases it while waiting on the mailbox; the
+	// pool still leaves at least one P of GOMAXPROCS to everything else, so
+	// busy handlers cannot take them all.
+	Threads int
+
+	// CPUs are affinity hints: thread i is pinned to CPUs[i%len(CPUs)].
+	// Pinning is best effort and Linux only; failures are reported as
+	// warnings and the thread runs unpinned. Empty leaves placement to the
+	// OS.
+	CPUs []int
+}
+
+// LockedThreadStats describes the threads of a LockedThreadPool.
+type LockedThreadStats struct {
+	Threads int
+	Busy    int
+
+	// Pinned counts the threads whose affinity hint was applied
+	Pinned int
+}
+
+// LockedThreadPool is a small dedicated dispatcher for Actors that must
+// stay on one OS thread. Each of its worker goroutines calls
+// runtime.LockOSThread, and an Actor started with WithLockedThread runs its
+// whole message loop on one of them, so its handler never migrates between
+// threads and shares its thread with no other goroutine. It does not exempt
+// the handler from GC assists, which are charged to allocating goroutines:
+// handlers on the pool should avoid allocating. It does not make them
+// more punctual either: see BenchmarkTickJitter.
+type LockedThreadPool struct {
+	mu     sync.Mutex
+	idle   chan *lockedThread
+	all    []*lockedThread
+	closed bool
+	wg     sync.WaitGroup
+
+	pinned int32 // atomic
+}
+
+// lockedThread is one worker of a LockedThreadPool.
+type lockedThread struct {
+	index int
+	work  chan func()
+}
+
+// NewLockedThreadPool starts the locked threads of config.
+func NewLockedThreadPool(config LockedThreadConfig) (*LockedThreadPool, error) {
+	threads := config.Threads
+	if threads <= 0 {
+		threads = 1
+	}
+	if procs := runtime.GOMAXPROCS(0); threads > 1 && threads >= procs {
+		return nil, fmt.Errorf("%d locked threads leave no P of GOMAXPROCS=%d to other goroutines", threads, procs)
+	}
+	for _, cpu := range config.CPUs {
+		if cpu < 0 || cpu >= runtime.NumCPU() {
+			return nil, fmt.Errorf("CPU %d out of range (%d CPUs)", cpu, runtime.NumCPU())
+		}
+	}
+
+	p := &LockedThreadPool{idle: make(chan *lockedThread, threads)}
+	started := make(chan struct{}, threads)
+	for i := 0; i < threads; i++ {
+		t := &lockedThread{index: i, work: make(chan func(), 1)}
+		p.all = append(p.all, t)
+
+		cpu := -1
+		if len(config.CPUs) > 0 {
+			cpu = config.CPUs[i%len(config.CPUs)]
+		}
+		p.wg.Add(1)
+		go p.run(t, cpu, started)
+	}
+	for i := 0; i < threads; i++ {
+		<-started
+	}
+	return p, nil
+}
+
+// run locks the worker goroutine to its thread, pins it and runs the
+// message loops handed to it until the pool closes.
+func (p *LockedThreadPool) run(t *lockedThread, cpu int, started chan<- struct{}) {
+	defer p.wg.Done()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if cpu >= 0 {
+		if err := setThreadAffinity(cpu); err != nil {
+			ReportError("core", "locked_thread", SeverityWarning, fmt.Errorf("thread %d: affinity hint for CPU %d not applied: %w", t.index, cpu, err))
+		} else {
+			atomic.AddInt32(&p.pinned, 1)
+		}
+	}
+	p.idle <- t
+	started <- struct{}{}
+
+	for fn := range t.work {
+		fn()
+		p.idle <- t
+	}
+}
+
+// dispatch runs fn on a free locked thread.
+func (p *LockedThreadPool) dispatch(fn func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrLockedThreadPoolClosed
+	}
+	select {
+	case t := <-p.idle:
+		t.work <- fn
+		return nil
+	default:
+		return ErrNoLockedThread
+	}
+}
+
+// Stats returns the threads of the pool and how many run an Actor.
+func (p *LockedThreadPool) Stats() LockedThreadStats {
+	return LockedThreadStats{
+		Threads: len(p.all),
+		Busy:    len(p.all) - len(p.idle),
+		Pinned:  int(atomic.LoadInt32(&p.pinned)),
+	}
+}
+
+// Close releases the threads once the Actors running on them stop, and
+// waits for them. Stop those Actors first or Close blocks until they are.
+func (p *LockedThreadPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, t := range p.all {
+		close(t.work)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// WithLockedThread runs the Actor's message loop on a thread of pool. The
+// Actor must handle messages serially; NewActor and NewService return
+// ErrNoLockedThread when every thread of pool is taken.
+func WithLockedThread(pool *LockedThreadPool) ActorOption {
+	return func(o *ActorOptions) { o.LockedThreads = pool }
+}
+
+// checkLockedThread rejects options a locked thread cannot honour.
+func checkLockedThread(opts ActorOptions) error {
+	if opts.LockedThreads != nil && opts.Concurrency == ConcurrencyParallel {
+		return fmt.Errorf("actors on a locked thread must handle messages serially")
+	}
+	return nil
+}
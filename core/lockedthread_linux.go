@@ -0,0 +1,24 @@
+//go:build linux
+
+package core
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// setThreadAffinity pins the calling thread to cpu.
+func setThreadAffinity(cpu int) error {
+	var mask [16]uint64 // 1024 CPUs, the kernel's default cpu_set_t
+	if cpu >= len(mask)*64 {
+		return syscall.EINVAL
+	}
+	mask[cpu/64] |= 1 << (uint(cpu) % 64)
+
+	// pid 0 is the calling thread
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package core
+
+import "errors"
+
+// setThreadAffinity is only supported on Linux.
+func setThreadAffinity(cpu int) error {
+	return errors.New("thread affinity is not supported on this platform")
+}
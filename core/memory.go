@@ -0,0 +1,317 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrMemoryBudgetExceeded is returned when a message would push an Actor or
+// the whole system over its memory budget and the policy rejects it.
+var ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+
+// MemoryPolicy decides what happens to a message that does not fit the budget.
+type MemoryPolicy uint8
+
+const (
+	// MemoryPolicyReject refuses the new message
+	MemoryPolicyReject MemoryPolicy = iota
+
+	// MemoryPolicyDropOldest drops queued messages, oldest first, to make room
+	MemoryPolicyDropOldest
+
+	// MemoryPolicySpill hands the new message to the configured Spiller
+	MemoryPolicySpill
+)
+
+// String returns the string representation of MemoryPolicy.
+func (p MemoryPolicy) String() string {
+	switch p {
+	case MemoryPolicyReject:
+		return "reject"
+	case MemoryPolicyDropOldest:
+		return "drop_oldest"
+	case MemoryPolicySpill:
+		return "spill"
+	default:
+		return "unknown"
+	}
+}
+
+// Spiller takes over messages that do not fit an Actor's memory budget,
+// e.g. by writing them to disk for later replay.
+type Spiller interface {
+	// Spill stores a message that could not be queued for the Actor.
+	Spill(id ActorID, msg *Message) error
+}
+
+// ActorMemoryUsage reports the approximate memory held by one Actor.
+type ActorMemoryUsage struct {
+	// ID of the Actor
+	ID ActorID
+
+	// Name of the Actor
+	Name string
+
+	// MailboxBytes is the sum of payload sizes queued in the mailbox
+	MailboxBytes int64
+
+	// AttachedBytes is the sum of registered attachment sizes
+	AttachedBytes int64
+
+	// Budget is the per-Actor limit (0 means unlimited)
+	Budget int64
+
+	// Dropped is the number of messages dropped or spilled over budget
+	Dropped uint64
+}
+
+// Total returns the total bytes attributed to the Actor.
+func (u ActorMemoryUsage) Total() int64 {
+	return u.MailboxBytes + u.AttachedBytes
+}
+
+// memoryAccountant tracks memory across all Actors of a system.
+type memoryAccountant struct {
+	limit int64 // atomic, 0 means unlimited
+	used  int64 // atomic
+}
+
+// reserve accounts n bytes, failing if the system budget would be exceeded.
+func (m *memoryAccountant) reserve(n int64) bool {
+	if m == nil {
+		return true
+	}
+	for {
+		used := atomic.LoadInt64(&m.used)
+		limit := atomic.LoadInt64(&m.limit)
+		if limit > 0 && used+n > limit && n > 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&m.used, used, used+n) {
+			return true
+		}
+	}
+}
+
+// release returns n bytes to the system budget.
+func (m *memoryAccountant) release(n int64) {
+	if m != nil {
+		atomic.AddInt64(&m.used, -n)
+	}
+}
+
+// actorMemory holds the per-Actor memory accounting state.
+type actorMemory struct {
+	mailboxBytes  int64 // atomic
+	attachedBytes int64 // atomic
+	dropped       uint64
+
+	attachMu    sync.Mutex
+	attachments map[string]int64
+	detached    bool
+
+	system *memoryAccountant
+}
+
+// messageSize approximates the memory held by a queued message.
+func messageSize(msg *Message) int64 {
+	return int64(len(msg.Data))
+}
+
+// admit accounts a message about to be queued, applying the memory policy
+// when it does not fit. It returns spilled=true if the message was handed to
+// the Spiller instead of being queued.
+func (a *actor) admit(msg *Message) (spilled bool, err error) {
+	size := messageSize(msg)
+
+	for !a.fits(size) {
+		switch a.opts.MemoryPolicy {
+		case MemoryPolicyDropOldest:
+			if !a.dropOldest() {
+				atomic.AddUint64(&a.mem.dropped, 1)
+				return false, fmt.Errorf("actor %d: %w", a.id, ErrMemoryBudgetExceeded)
+			}
+		case MemoryPolicySpill:
+			if a.opts.Spiller == nil {
+				return false, fmt.Errorf("actor %d: %w (no spiller configured)", a.id, ErrMemoryBudgetExceeded)
+			}
+			atomic.AddUint64(&a.mem.dropped, 1)
+			if err := a.opts.Spiller.Spill(a.id, msg); err != nil {
+				return false, fmt.Errorf("actor %d: failed to spill message: %w", a.id, err)
+			}
+			return true, nil
+		default:
+			atomic.AddUint64(&a.mem.dropped, 1)
+			return false, fmt.Errorf("actor %d: %w", a.id, ErrMemoryBudgetExceeded)
+		}
+	}
+
+	return false, nil
+}
+
+// fits reserves size bytes if both the Actor and system budgets allow it.
+func (a *actor) fits(size int64) bool {
+	budget := a.opts.MemoryBudget
+	for {
+		queued := atomic.LoadInt64(&a.mem.mailboxBytes)
+		if budget > 0 && size > 0 && queued+atomic.LoadInt64(&a.mem.attachedBytes)+size > budget {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&a.mem.mailboxBytes, queued, queued+size) {
+			break
+		}
+	}
+
+	if !a.mem.system.reserve(size) {
+		atomic.AddInt64(&a.mem.mailboxBytes, -size)
+		return false
+	}
+	return true
+}
+
+// dropOldest removes the oldest queued message to free memory, unless a
+// system message is queued (see dropOldestFrom).
+func (a *actor) dropOldest() bool {
+	a.dropMu.Lock()
+	defer a.dropMu.Unlock()
+
+	if atomic.LoadInt64(&a.queuedSystem) > 0 {
+		return false
+	}
+	select {
+	case msg := <-a.mailbox:
+		if msg == nil {
+			return false
+		}
+		a.dequeued(msg)
+		atomic.AddUint64(&a.mem.dropped, 1)
+		if msg.Session != 0 {
+			a.sendResponse(msg, fmt.Errorf("actor %d dropped message: %w", a.id, ErrMemoryBudgetExceeded))
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// dequeued releases the accounting of a message leaving the mailbox.
+func (a *actor) dequeued(msg *Message) {
+	if isSystemMessage(msg) {
+		atomic.AddInt64(&a.queuedSystem, -1)
+	}
+	size := messageSize(msg)
+	atomic.AddInt64(&a.mem.mailboxBytes, -size)
+	a.mem.system.release(size)
+}
+
+// charge accounts a message moved into the mailbox without budget checks.
+func (a *actor) charge(msg *Message) {
+	a.queueing(msg)
+	size := messageSize(msg)
+	atomic.AddInt64(&a.mem.mailboxBytes, size)
+	a.mem.system.release(-size)
+}
+
+// attach records bytes held by the Actor outside its mailbox under key.
+func (a *actor) attach(key string, bytes int64) {
+	a.mem.attachMu.Lock()
+	defer a.mem.attachMu.Unlock()
+
+	// A stopped Actor holds nothing
+	if a.mem.detached {
+		return
+	}
+	if a.mem.attachments == nil {
+		a.mem.attachments = make(map[string]int64)
+	}
+
+	delta := bytes - a.mem.attachments[key]
+	if bytes <= 0 {
+		delete(a.mem.attachments, key)
+	} else {
+		a.mem.attachments[key] = bytes
+	}
+
+	// Attachments are reported, not admitted: they count toward the system
+	// total so later messages see the pressure, but are never refused.
+	atomic.AddInt64(&a.mem.attachedBytes, delta)
+	a.mem.system.release(-delta)
+}
+
+// detachAll gives the attachments of a stopping Actor back to the system
+// budget; later attachments are ignored.
+func (a *actor) detachAll() {
+	a.mem.attachMu.Lock()
+	defer a.mem.attachMu.Unlock()
+
+	if a.mem.detached {
+		return
+	}
+	a.mem.detached = true
+	a.mem.attachments = nil
+	a.mem.system.release(atomic.SwapInt64(&a.mem.attachedBytes, 0))
+}
+
+// memoryUsage returns the current memory accounting of the Actor.
+func (a *actor) memoryUsage() ActorMemoryUsage {
+	return ActorMemoryUsage{
+		ID:            a.id,
+		Name:          a.name,
+		MailboxBytes:  atomic.LoadInt64(&a.mem.mailboxBytes),
+		AttachedBytes: atomic.LoadInt64(&a.mem.attachedBytes),
+		Budget:        a.opts.MemoryBudget,
+		Dropped:       atomic.LoadUint64(&a.mem.dropped),
+	}
+}
+
+// SetMemoryBudget sets the system-wide memory budget in bytes (0 disables it).
+func (s *system) SetMemoryBudget(bytes int64) {
+	atomic.StoreInt64(&s.memory.limit, bytes)
+}
+
+// MemoryUsed returns the approximate bytes held by all Actors.
+func (s *system) MemoryUsed() int64 {
+	return atomic.LoadInt64(&s.memory.used)
+}
+
+// AttachMemory records bytes held by an Actor outside its mailbox (caches,
+// buffers, ...) under key. Passing 0 removes the attachment.
+func (s *system) AttachMemory(id ActorID, key string, bytes int64) error {
+	a, exists := s.router.Lookup(id)
+	if !exists {
+		return fmt.Errorf("actor %d not found", id)
+	}
+
+	impl, ok := a.(*actor)
+	if !ok {
+		return fmt.Errorf("actor %d does not support memory accounting", id)
+	}
+
+	impl.attach(key, bytes)
+	return nil
+}
+
+// TopMemoryConsumers returns the n Actors holding the most memory, largest
+// first. n <= 0 returns all Actors.
+func (s *system) TopMemoryConsumers(n int) []ActorMemoryUsage {
+	var usage []ActorMemoryUsage
+	for _, id := range s.router.List() {
+		if a, exists := s.router.Lookup(id); exists {
+			if impl, ok := a.(*actor); ok {
+				usage = append(usage, impl.memoryUsage())
+			}
+		}
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Total() > usage[j].Total()
+	})
+
+	if n > 0 && n < len(usage) {
+		usage = usage[:n]
+	}
+	return usage
+}
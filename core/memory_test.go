@@ -0,0 +1,175 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingSpiller collects spilled messages.
+type recordingSpiller struct {
+	spilled []*Message
+}
+
+func (s *recordingSpiller) Spill(id ActorID, msg *Message) error {
+	s.spilled = append(s.spilled, msg)
+	return nil
+}
+
+func newBudgetActor(policy MemoryPolicy, spiller Spiller) *actor {
+	opts := DefaultActorOptions()
+	opts.MemoryBudget = 100
+	opts.MemoryPolicy = policy
+	opts.Spiller = spiller
+
+	// The actor is never started, so messages stay queued.
+	return NewActor(1, &echoHandler{}, opts).(*actor)
+}
+
+func TestActorMemoryBudgetReject(t *testing.T) {
+	a := newBudgetActor(MemoryPolicyReject, nil)
+
+	for i := 0; i < 2; i++ {
+		if err := a.Send(&Message{Data: make([]byte, 40)}); err != nil {
+			t.Fatalf("Send %d failed: %v", i, err)
+		}
+	}
+
+	err := a.Send(&Message{Data: make([]byte, 40)})
+	if !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Fatalf("Expected ErrMemoryBudgetExceeded, got %v", err)
+	}
+
+	usage := a.memoryUsage()
+	if usage.MailboxBytes != 80 || usage.Dropped != 1 {
+		t.Errorf("Expected 80 bytes / 1 dropped, got %d / %d", usage.MailboxBytes, usage.Dropped)
+	}
+}
+
+func TestActorMemoryBudgetConcurrentSenders(t *testing.T) {
+	a := newBudgetActor(MemoryPolicyReject, nil)
+
+	var wg sync.WaitGroup
+	var admitted int64
+	start := make(chan struct{})
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if a.fits(40) {
+				atomic.AddInt64(&admitted, 1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// Nothing is released, so the mailbox bytes only ever grew
+	if usage := a.memoryUsage(); usage.MailboxBytes > 100 || admitted != 2 {
+		t.Errorf("Expected 2 reservations within the 100 byte budget, got %d holding %d bytes", admitted, usage.MailboxBytes)
+	}
+}
+
+func TestActorMemoryBudgetDropOldest(t *testing.T) {
+	a := newBudgetActor(MemoryPolicyDropOldest, nil)
+
+	for i := 0; i < 3; i++ {
+		if err := a.Send(&Message{ID: uint64(i), Data: make([]byte, 40)}); err != nil {
+			t.Fatalf("Send %d failed: %v", i, err)
+		}
+	}
+
+	if len(a.mailbox) != 2 {
+		t.Fatalf("Expected 2 queued messages, got %d", len(a.mailbox))
+	}
+	if oldest := <-a.mailbox; oldest.ID != 1 {
+		t.Errorf("Expected oldest message to be dropped, head is %d", oldest.ID)
+	}
+}
+
+func TestActorMemoryBudgetSpill(t *testing.T) {
+	spiller := &recordingSpiller{}
+	a := newBudgetActor(MemoryPolicySpill, spiller)
+
+	for i := 0; i < 3; i++ {
+		if err := a.Send(&Message{ID: uint64(i), Data: make([]byte, 40)}); err != nil {
+			t.Fatalf("Send %d failed: %v", i, err)
+		}
+	}
+
+	if len(spiller.spilled) != 1 || spiller.spilled[0].ID != 2 {
+		t.Errorf("Expected message 2 to be spilled, got %v", spiller.spilled)
+	}
+}
+
+func TestSystemMemoryBudget(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+
+	block := make(chan struct{})
+	defer close(block)
+
+	handler := &counterHandler{block: block}
+	handle, err := sys.NewService("hungry", handler, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	idle, _ := sys.NewService("idle", &echoHandler{}, DefaultActorOptions())
+
+	sys.SetMemoryBudget(100)
+
+	// The first message is dequeued into the blocked handler.
+	sys.SendByName("", "hungry", MessageTypeText, make([]byte, 10))
+	time.Sleep(10 * time.Millisecond)
+
+	if err := sys.SendByName("", "hungry", MessageTypeText, make([]byte, 60)); err != nil {
+		t.Fatalf("Send within budget failed: %v", err)
+	}
+	err = sys.SendByName("", "hungry", MessageTypeText, make([]byte, 60))
+	if !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Fatalf("Expected system budget to reject, got %v", err)
+	}
+
+	if err := sys.AttachMemory(idle.ActorID, "cache", 30); err != nil {
+		t.Fatalf("AttachMemory failed: %v", err)
+	}
+
+	top := sys.TopMemoryConsumers(2)
+	if len(top) != 2 || top[0].ID != handle.ActorID || top[1].ID != idle.ActorID {
+		t.Fatalf("Unexpected top consumers: %+v", top)
+	}
+	if top[0].MailboxBytes != 60 || top[1].AttachedBytes != 30 {
+		t.Errorf("Unexpected usage: %+v", top)
+	}
+}
+
+func TestStoppedActorsReleaseAttachedMemory(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+	sys.SetMemoryBudget(100)
+
+	// Without the release the budget would run out after two actors
+	for i := 0; i < 20; i++ {
+		a, err := sys.NewActor(&echoHandler{}, DefaultActorOptions())
+		if err != nil {
+			t.Fatalf("Failed to create actor: %v", err)
+		}
+		if err := sys.AttachMemory(a.ID(), "cache", 40); err != nil {
+			t.Fatalf("AttachMemory failed: %v", err)
+		}
+		if err := sys.Send(0, a.ID(), MessageTypeText, make([]byte, 10)); err != nil {
+			t.Fatalf("Send %d failed: %v", i, err)
+		}
+		if err := a.Stop(); err != nil {
+			t.Fatalf("Failed to stop actor: %v", err)
+		}
+		sys.AttachMemory(a.ID(), "cache", 40)
+	}
+	if used := sys.(*system).MemoryUsed(); used != 0 {
+		t.Errorf("Expected the budget fully released, %d bytes still used", used)
+	}
+}
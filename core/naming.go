@@ -0,0 +1,161 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NamespaceSeparator separates the levels of a hierarchical service name,
+// e.g. "game/zone1/agent42".
+const NamespaceSeparator = "/"
+
+// Service name errors.
+var (
+	// ErrInvalidServiceName is returned for names that break the NamePolicy
+	ErrInvalidServiceName = errors.New("invalid service name")
+
+	// ErrReservedServiceName is returned when user code registers a name
+	// under a reserved prefix
+	ErrReservedServiceName = errors.New("reserved service name")
+)
+
+// NamePolicy defines which service names may be registered.
+type NamePolicy struct {
+	// MaxLength is the maximum name length in bytes (0 means unlimited)
+	MaxLength int
+
+	// Symbols lists the characters allowed besides ASCII letters, digits
+	// and the namespace separator
+	Symbols string
+
+	// ReservedPrefixes may only be registered by system services
+	ReservedPrefixes []string
+}
+
+// DefaultNamePolicy returns the default service naming rules.
+func DefaultNamePolicy() NamePolicy {
+	return NamePolicy{
+		MaxLength:        128,
+		Symbols:          "._-",
+		ReservedPrefixes: []string{"sys/"},
+	}
+}
+
+// Validate checks a name registered by user code.
+func (p NamePolicy) Validate(name string) error {
+	if err := p.validateSyntax(name); err != nil {
+		return err
+	}
+
+	if prefix, reserved := p.reservedPrefix(name); reserved {
+		return fmt.Errorf("%w: %q uses reserved prefix %q", ErrReservedServiceName, name, prefix)
+	}
+	return nil
+}
+
+// ValidateSystem checks a name registered by a system service, which may
+// use reserved prefixes.
+func (p NamePolicy) ValidateSystem(name string) error {
+	return p.validateSyntax(name)
+}
+
+// validateSyntax checks the length, charset and namespace structure.
+func (p NamePolicy) validateSyntax(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty name", ErrInvalidServiceName)
+	}
+	if p.MaxLength > 0 && len(name) > p.MaxLength {
+		return fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidServiceName, name, p.MaxLength)
+	}
+
+	for _, segment := range strings.Split(name, NamespaceSeparator) {
+		if segment == "" {
+			return fmt.Errorf("%w: %q has an empty namespace segment", ErrInvalidServiceName, name)
+		}
+	}
+
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune(NamespaceSeparator, c), strings.ContainsRune(p.Symbols, c):
+		default:
+			return fmt.Errorf("%w: %q contains %q", ErrInvalidServiceName, name, c)
+		}
+	}
+	return nil
+}
+
+// reservedPrefix returns the reserved prefix name falls under, if any.
+func (p NamePolicy) reservedPrefix(name string) (string, bool) {
+	for _, prefix := range p.ReservedPrefixes {
+		if strings.HasPrefix(name, prefix) || name == strings.TrimSuffix(prefix, NamespaceSeparator) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// InNamespace returns true if name is namespace itself or lies below it.
+func InNamespace(name, namespace string) bool {
+	namespace = strings.TrimSuffix(namespace, NamespaceSeparator)
+	if namespace == "" {
+		return true
+	}
+	return name == namespace || strings.HasPrefix(name, namespace+NamespaceSeparator)
+}
+
+// SetNamePolicy replaces the naming rules for future registrations.
+func (hm *HandleManager) SetNamePolicy(policy NamePolicy) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	hm.policy = policy
+}
+
+// NamePolicy returns the current naming rules.
+func (hm *HandleManager) NamePolicy() NamePolicy {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
+	return hm.policy
+}
+
+// AllocateSystemHandle creates a named handle that may use reserved prefixes.
+func (hm *HandleManager) AllocateSystemHandle(actorID ActorID, name string) (*Handle, error) {
+	return hm.allocateHandle(actorID, name, true)
+}
+
+// ListNamespace returns the named handles in namespace, sorted by name.
+func (hm *HandleManager) ListNamespace(namespace string) []*Handle {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
+	var handles []*Handle
+	for name, handleID := range hm.nameToHandle {
+		if InNamespace(name, namespace) {
+			handles = append(handles, hm.handles[handleID])
+		}
+	}
+
+	sort.Slice(handles, func(i, j int) bool {
+		return handles[i].Name < handles[j].Name
+	})
+	return handles
+}
+
+// SetNamePolicy replaces the naming rules for future service registrations.
+func (s *system) SetNamePolicy(policy NamePolicy) {
+	s.router.GetHandleManager().SetNamePolicy(policy)
+}
+
+// NewSystemService creates a named service that may use reserved prefixes.
+func (s *system) NewSystemService(name string, handler MessageHandler, opts ActorOptions) (*Handle, error) {
+	return s.newService(name, handler, opts, true)
+}
+
+// ListNamespace returns the services registered in namespace.
+func (s *system) ListNamespace(namespace string) []*Handle {
+	return s.router.GetHandleManager().ListNamespace(namespace)
+}
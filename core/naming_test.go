@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNamePolicyValidate(t *testing.T) {
+	policy := DefaultNamePolicy()
+
+	valid := []string{"SIMPLEDB", "echo-service", ".launcher", "game/zone1/agent42"}
+	for _, name := range valid {
+		if err := policy.Validate(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+
+	invalid := []string{"", "has space", "game//zone", "/game", "game/", "semi;colon", strings.Repeat("a", 129)}
+	for _, name := range invalid {
+		if err := policy.Validate(name); !errors.Is(err, ErrInvalidServiceName) {
+			t.Errorf("Expected %q to be invalid, got %v", name, err)
+		}
+	}
+
+	if err := policy.Validate("sys/logger"); !errors.Is(err, ErrReservedServiceName) {
+		t.Errorf("Expected reserved prefix to be rejected, got %v", err)
+	}
+	if err := policy.ValidateSystem("sys/logger"); err != nil {
+		t.Errorf("Expected system service to use reserved prefix, got %v", err)
+	}
+}
+
+func TestServiceNamespaces(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+
+	for _, name := range []string{"game/zone1/agent1", "game/zone1/agent2", "game/zone10/agent1", "chat"} {
+		if _, err := sys.NewService(name, &echoHandler{}, DefaultActorOptions()); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	zone1 := sys.ListNamespace("game/zone1")
+	if len(zone1) != 2 || zone1[0].Name != "game/zone1/agent1" || zone1[1].Name != "game/zone1/agent2" {
+		t.Errorf("Unexpected zone1 services: %v", zone1)
+	}
+	if got := len(sys.ListNamespace("game/")); got != 3 {
+		t.Errorf("Expected 3 game services, got %d", got)
+	}
+
+	if _, err := sys.NewService("sys/monitor", &echoHandler{}, DefaultActorOptions()); !errors.Is(err, ErrReservedServiceName) {
+		t.Errorf("Expected reserved name to be rejected, got %v", err)
+	}
+	if _, err := sys.NewSystemService("sys/monitor", &echoHandler{}, DefaultActorOptions()); err != nil {
+		t.Errorf("Expected system service to register, got %v", err)
+	}
+
+	// A rejected registration must not leave the actor routed
+	before := len(sys.Stats())
+	if _, err := sys.NewService("bad name", &echoHandler{}, DefaultActorOptions()); err == nil {
+		t.Error("Expected invalid name to be rejected")
+	}
+	if after := len(sys.Stats()); after != before {
+		t.Errorf("Expected %d actors after rejected registration, got %d", before, after)
+	}
+
+	policy := DefaultNamePolicy()
+	policy.MaxLength = 4
+	sys.SetNamePolicy(policy)
+	if _, err := sys.NewService("toolong", &echoHandler{}, DefaultActorOptions()); !errors.Is(err, ErrInvalidServiceName) {
+		t.Errorf("Expected custom max length to apply, got %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by Send when an Actor's rate limit is exceeded.
+var ErrRateLimited = errors.New("actor rate limit exceeded")
+
+// ErrMailboxFull is returned by Send when a message does not fit in an
+// Actor's mailbox.
+var ErrMailboxFull = errors.New("mailbox is full")
+
+// ErrActorStopping is returned when an Actor is shutting down.
+var ErrActorStopping = errors.New("actor is shutting down")
+
+// OverflowPolicy decides what happens to a message sent to a full mailbox.
+type OverflowPolicy uint8
+
+const (
+	// OverflowReject refuses the new message
+	OverflowReject OverflowPolicy = iota
+
+	// OverflowDropOldest drops the oldest queued message to make room
+	OverflowDropOldest
+
+	// OverflowBlock waits for room, or until the Actor stops
+	OverflowBlock
+)
+
+// String returns the string representation of OverflowPolicy.
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowReject:
+		return "reject"
+	case OverflowDropOldest:
+		return "drop_oldest"
+	case OverflowBlock:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// ConcurrencyMode decides how many messages an Actor handles at once.
+type ConcurrencyMode uint8
+
+const (
+	// ConcurrencySerial handles one message at a time, in mailbox order
+	ConcurrencySerial ConcurrencyMode = iota
+
+	// ConcurrencyParallel handles up to MaxConcurrency messages at once.
+	// The handler must be safe for concurrent use and messages may
+	// complete out of order
+	ConcurrencyParallel
+)
+
+// String returns the string representation of ConcurrencyMode.
+func (m ConcurrencyMode) String() string {
+	switch m {
+	case ConcurrencySerial:
+		return "serial"
+	case ConcurrencyParallel:
+		return "parallel"
+	default:
+		return "unknown"
+	}
+}
+
+// ActorOption configures ActorOptions.
+type ActorOption func(*ActorOptions)
+
+// NewActorOptions returns the default options with opts applied in order.
+func NewActorOptions(opts ...ActorOption) ActorOptions {
+	return DefaultActorOptions().With(opts...)
+}
+
+// With returns a copy of o with opts applied in order.
+func (o ActorOptions) With(opts ...ActorOption) ActorOptions {
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// withDefaults fills the fields left at their zero value with defaults.
+func (o ActorOptions) withDefaults() ActorOptions {
+	defaults := DefaultActorOptions()
+	if o.MailboxSize <= 0 {
+		o.MailboxSize = defaults.MailboxSize
+	}
+	if o.ProcessTimeout <= 0 {
+		o.ProcessTimeout = defaults.ProcessTimeout
+	}
+	if o.Concurrency == ConcurrencyParallel && o.MaxConcurrency <= 0 {
+		o.MaxConcurrency = 1
+	}
+	return o
+}
+
+// WithName sets the human-readable name of the Actor.
+func WithName(name string) ActorOption {
+	return func(o *ActorOptions) { o.Name = name }
+}
+
+// WithMailboxSize sets the capacity of the mailbox.
+func WithMailboxSize(size int) ActorOption {
+	return func(o *ActorOptions) { o.MailboxSize = size }
+}
+
+// WithProcessTimeout bounds the handling of a single message.
+func WithProcessTimeout(timeout time.Duration) ActorOption {
+	return func(o *ActorOptions) { o.ProcessTimeout = timeout }
+}
+
+// WithMemoryBudget caps the bytes held by the mailbox and attachments and
+// sets what happens to messages over budget.
+func WithMemoryBudget(budget int64, policy MemoryPolicy) ActorOption {
+	return func(o *ActorOptions) {
+		o.MemoryBudget = budget
+		o.MemoryPolicy = policy
+	}
+}
+
+// WithSpiller sets where over-budget messages go under MemoryPolicySpill.
+func WithSpiller(spiller Spiller) ActorOption {
+	return func(o *ActorOptions) { o.Spiller = spiller }
+}
+
+// WithOverflowPolicy sets what happens to messages sent to a full mailbox.
+func WithOverflowPolicy(policy OverflowPolicy) ActorOption {
+	return func(o *ActorOptions) { o.OverflowPolicy = policy }
+}
+
+// WithSupervisor sets the Supervisor that watches the Actor once created.
+func WithSupervisor(supervisor Supervisor) ActorOption {
+	return func(o *ActorOptions) { o.Supervisor = supervisor }
+}
+
+// WithRateLimit limits accepted messages to perSecond on average with
+// bursts of up to burst messages; Send returns ErrRateLimited beyond it.
+func WithRateLimit(perSecond float64, burst int) ActorOption {
+	return func(o *ActorOptions) {
+		o.RateLimit = perSecond
+		o.RateBurst = burst
+	}
+}
+
+// WithConcurrencyMode sets how many messages are handled at once;
+// maxConcurrency only applies to ConcurrencyParallel.
+func WithConcurrencyMode(mode ConcurrencyMode, maxConcurrency int) ActorOption {
+	return func(o *ActorOptions) {
+		o.Concurrency = mode
+		o.MaxConcurrency = maxConcurrency
+	}
+}
+
+// WithPriorityQueue lets messages with a non-zero Priority overtake the
+// rest of the mailbox.
+func WithPriorityQueue(enabled bool) ActorOption {
+	return func(o *ActorOptions) { o.PriorityQueue = enabled }
+}
+
+// WithYieldBudget sets how long a handler may run before CheckBudget
+// yields, and whether urgent messages are handled while it yields.
+func WithYieldBudget(budget time.Duration, toUrgent bool) ActorOption {
+	return func(o *ActorOptions) {
+		o.YieldBudget = budget
+		o.YieldToUrgent = toUrgent
+	}
+}
+
+// rateLimiter is a token bucket refilled continuously.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a full bucket; burst defaults to one second of rate.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	b := float64(burst)
+	if b <= 0 {
+		b = rate
+	}
+	if b < 1 {
+		b = 1
+	}
+	return &rateLimiter{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// allow takes a token if one is available.
+func (l *rateLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,237 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// orderHandler records message payloads, optionally blocking on gate first.
+type orderHandler struct {
+	gate chan struct{}
+
+	mu    sync.Mutex
+	order []string
+
+	active, peak int32
+}
+
+func (h *orderHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	n := atomic.AddInt32(&h.active, 1)
+	defer atomic.AddInt32(&h.active, -1)
+	for {
+		peak := atomic.LoadInt32(&h.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&h.peak, peak, n) {
+			break
+		}
+	}
+
+	if h.gate != nil {
+		<-h.gate
+	}
+
+	h.mu.Lock()
+	h.order = append(h.order, string(msg.Data))
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *orderHandler) received() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.order...)
+}
+
+// recordingSupervisor records the Actors it watches.
+type recordingSupervisor struct {
+	watched []ActorID
+	err     error
+}
+
+func (s *recordingSupervisor) Watch(actor Actor) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.watched = append(s.watched, actor.ID())
+	return nil
+}
+
+func (s *recordingSupervisor) Unwatch(id ActorID) error { return nil }
+func (s *recordingSupervisor) Restart(id ActorID) error { return nil }
+
+func TestActorOptionsBuilder(t *testing.T) {
+	supervisor := &recordingSupervisor{}
+	opts := NewActorOptions(
+		WithName("inventory"),
+		WithMailboxSize(8),
+		WithOverflowPolicy(OverflowDropOldest),
+		WithRateLimit(100, 10),
+		WithConcurrencyMode(ConcurrencyParallel, 4),
+		WithPriorityQueue(true),
+		WithSupervisor(supervisor),
+	)
+	if opts.Name != "inventory" || opts.MailboxSize != 8 || opts.ProcessTimeout != DefaultActorOptions().ProcessTimeout {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+	if opts.OverflowPolicy != OverflowDropOldest || opts.RateLimit != 100 || opts.RateBurst != 10 ||
+		opts.Concurrency != ConcurrencyParallel || opts.MaxConcurrency != 4 || !opts.PriorityQueue {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	// With derives without modifying the original
+	derived := opts.With(WithMailboxSize(16))
+	if derived.MailboxSize != 16 || opts.MailboxSize != 8 {
+		t.Errorf("Expected a modified copy, got %d and %d", derived.MailboxSize, opts.MailboxSize)
+	}
+
+	// Partially filled options keep their fields
+	filled := ActorOptions{Name: "partial"}.withDefaults()
+	if filled.Name != "partial" || filled.MailboxSize == 0 || filled.ProcessTimeout == 0 {
+		t.Errorf("Expected defaults to fill only zero fields, got %+v", filled)
+	}
+
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+
+	handle, err := sys.NewService("inventory", &echoHandler{}, opts)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	if len(supervisor.watched) != 1 || supervisor.watched[0] != handle.ActorID {
+		t.Errorf("Expected the supervisor to watch the service, got %v", supervisor.watched)
+	}
+
+	supervisor.err = errors.New("refused")
+	if _, err := sys.NewService("orphan", &echoHandler{}, opts); err == nil {
+		t.Error("Expected a supervisor error to fail creation")
+	}
+	if _, exists := sys.GetService("orphan"); exists {
+		t.Error("Expected the failed service to be unregistered")
+	}
+}
+
+func TestActorOverflowPolicies(t *testing.T) {
+	gate := make(chan struct{})
+	handler := &orderHandler{gate: gate}
+	actor := NewActor(1, handler, NewActorOptions(WithMailboxSize(2), WithOverflowPolicy(OverflowDropOldest)))
+	actor.Start(context.Background())
+	defer actor.ForceStop()
+
+	// The first message blocks the handler, the next ones fill the mailbox
+	actor.Send(&Message{Data: []byte("0")})
+	time.Sleep(10 * time.Millisecond)
+	for _, data := range []string{"1", "2", "3", "4"} {
+		if err := actor.Send(&Message{Data: []byte(data)}); err != nil {
+			t.Fatalf("Expected drop-oldest to accept %s, got %v", data, err)
+		}
+	}
+	close(gate)
+
+	deadline := time.Now().Add(time.Second)
+	for len(handler.received()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := handler.received(); len(got) != 3 || got[1] != "3" || got[2] != "4" {
+		t.Errorf("Expected the oldest queued messages dropped, got %v", got)
+	}
+
+	blocking := NewActor(2, &orderHandler{}, NewActorOptions(
+		WithMailboxSize(1),
+		WithOverflowPolicy(OverflowBlock),
+		WithProcessTimeout(20*time.Millisecond),
+	))
+	defer blocking.ForceStop()
+	blocking.Send(&Message{})
+	start := time.Now()
+	if err := blocking.Send(&Message{}); err == nil || time.Since(start) < 20*time.Millisecond {
+		t.Errorf("Expected a bounded wait before rejecting, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestActorOverflowKeepsSystemMessages(t *testing.T) {
+	gate := make(chan struct{})
+	handler := &orderHandler{gate: gate}
+	a := NewActor(1, handler, NewActorOptions(WithMailboxSize(2), WithOverflowPolicy(OverflowDropOldest))).(*actor)
+	a.Start(context.Background())
+
+	a.Send(&Message{Data: []byte("0")})
+	time.Sleep(10 * time.Millisecond)
+	a.Send(&Message{Data: []byte("1")})
+	a.Send(PoisonPill())
+
+	// The pill may be the oldest message, so nothing is dropped
+	if err := a.Send(&Message{Data: []byte("2")}); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("Expected ErrMailboxFull while a pill is queued, got %v", err)
+	}
+	close(gate)
+
+	select {
+	case <-a.done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the pill to stop the actor")
+	}
+	if got := handler.received(); len(got) != 2 || got[1] != "1" {
+		t.Errorf("Expected the messages before the pill handled in order, got %v", got)
+	}
+}
+
+func TestActorRateLimit(t *testing.T) {
+	actor := NewActor(1, &echoHandler{}, NewActorOptions(WithRateLimit(1, 3)))
+	actor.Start(context.Background())
+	defer actor.ForceStop()
+
+	for i := 0; i < 3; i++ {
+		if err := actor.Send(&Message{}); err != nil {
+			t.Fatalf("Expected burst message %d to be accepted, got %v", i, err)
+		}
+	}
+	if err := actor.Send(&Message{}); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestActorPriorityQueue(t *testing.T) {
+	gate := make(chan struct{})
+	handler := &orderHandler{gate: gate}
+	actor := NewActor(1, handler, NewActorOptions(WithPriorityQueue(true)))
+	actor.Start(context.Background())
+
+	actor.Send(&Message{Data: []byte("first")})
+	time.Sleep(10 * time.Millisecond)
+	actor.Send(&Message{Data: []byte("normal")})
+	actor.Send(&Message{Data: []byte("urgent"), Priority: 1})
+	close(gate)
+
+	// Stop still handles everything accepted before it
+	actor.Stop()
+	if got := handler.received(); len(got) != 3 || got[1] != "urgent" || got[2] != "normal" {
+		t.Errorf("Expected the urgent message to overtake, got %v", got)
+	}
+}
+
+func TestActorParallelConcurrency(t *testing.T) {
+	gate := make(chan struct{})
+	handler := &orderHandler{gate: gate}
+	actor := NewActor(1, handler, NewActorOptions(WithConcurrencyMode(ConcurrencyParallel, 3)))
+	actor.Start(context.Background())
+
+	for i := 0; i < 6; i++ {
+		actor.Send(&Message{})
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&handler.active) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(gate)
+
+	actor.Stop()
+	if got := len(handler.received()); got != 6 {
+		t.Errorf("Expected all 6 messages handled before stopping, got %d", got)
+	}
+	if peak := atomic.LoadInt32(&handler.peak); peak != 3 {
+		t.Errorf("Expected 3 messages handled at once, got %d", peak)
+	}
+}
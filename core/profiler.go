@@ -0,0 +1,287 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// ProfilerLabel is the pprof label key carrying the Actor name while a
+// message is being handled, so CPU profiles can be filtered per Actor with
+// `go tool pprof -tagfocus actor=<name>`.
+const ProfilerLabel = "actor"
+
+// ProfilerOptions configures the Actor sampling profiler.
+type ProfilerOptions struct {
+	// SampleInterval is the time between samples
+	SampleInterval time.Duration
+
+	// Window is the period covered by reports
+	Window time.Duration
+
+	// Buckets is the number of slices the window is divided into; older
+	// slices are discarded as the window slides
+	Buckets int
+}
+
+// DefaultProfilerOptions returns the default profiler options.
+func DefaultProfilerOptions() ProfilerOptions {
+	return ProfilerOptions{
+		SampleInterval: 10 * time.Millisecond,
+		Window:         time.Minute,
+		Buckets:        12,
+	}
+}
+
+// ActorProfile is the time one Actor spent handling messages over a
+// window. It is sampled wall-clock time, blocking included; for CPU time,
+// take a CPU profile and focus it on the ProfilerLabel of the Actor.
+type ActorProfile struct {
+	// Name of the Actor (or "actor-<id>" for unnamed Actors)
+	Name string
+
+	// Samples in which the Actor was handling a message
+	Samples uint64
+
+	// WallTime is the estimated wall-clock time spent handling messages,
+	// including time the handler was blocked
+	WallTime time.Duration
+
+	// Percent of all busy samples attributed to this Actor
+	Percent float64
+}
+
+// ProfileReport ranks the busiest Actors over the profiler window.
+type ProfileReport struct {
+	// Window actually covered by the report
+	Window time.Duration
+
+	// TotalSamples taken, busy or idle
+	TotalSamples uint64
+
+	// Actors ranked by wall time, busiest first
+	Actors []ActorProfile
+}
+
+// WriteText writes the report as an aligned table, e.g. for a debug console.
+func (r ProfileReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "window %s, %d samples\n", r.Window.Round(time.Millisecond), r.TotalSamples)
+	fmt.Fprintln(tw, "ACTOR\tSAMPLES\tWALL\tPERCENT")
+	for _, p := range r.Actors {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.1f%%\n", p.Name, p.Samples, p.WallTime.Round(time.Millisecond), p.Percent)
+	}
+	return tw.Flush()
+}
+
+// profileBucket holds the samples of one slice of the window.
+type profileBucket struct {
+	start   time.Time
+	total   uint64
+	samples map[string]uint64
+}
+
+// actorProfiler periodically samples which Actors are handling a message.
+type actorProfiler struct {
+	opts ProfilerOptions
+
+	mu      sync.Mutex
+	buckets []profileBucket
+	current int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartProfiler starts sampling which Actors are busy handling a message.
+// Samples measure wall time; handlers also run under a pprof label while
+// the profiler is active, so CPU profiles taken meanwhile split per Actor.
+func (s *system) StartProfiler(opts ProfilerOptions) error {
+	defaults := DefaultProfilerOptions()
+	if opts.SampleInterval <= 0 {
+		opts.SampleInterval = defaults.SampleInterval
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaults.Window
+	}
+	if opts.Buckets <= 0 {
+		opts.Buckets = defaults.Buckets
+	}
+
+	s.profilerMu.Lock()
+	defer s.profilerMu.Unlock()
+
+	if s.profiler != nil {
+		return fmt.Errorf("profiler already running")
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	p := &actorProfiler{
+		opts:    opts,
+		buckets: make([]profileBucket, opts.Buckets),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	p.buckets[0] = profileBucket{start: time.Now(), samples: make(map[string]uint64)}
+
+	s.profiler = p
+	atomic.StoreInt32(&s.profiling, 1)
+
+	go p.run(ctx, s)
+	return nil
+}
+
+// StopProfiler stops sampling and returns the final report.
+func (s *system) StopProfiler() ProfileReport {
+	s.profilerMu.Lock()
+	p := s.profiler
+	s.profiler = nil
+	atomic.StoreInt32(&s.profiling, 0)
+	s.profilerMu.Unlock()
+
+	if p == nil {
+		return ProfileReport{}
+	}
+
+	p.cancel()
+	<-p.done
+	return p.report(0)
+}
+
+// ProfileReport returns the n busiest Actors over the profiler window.
+// n <= 0 returns all sampled Actors.
+func (s *system) ProfileReport(n int) (ProfileReport, error) {
+	s.profilerMu.Lock()
+	p := s.profiler
+	s.profilerMu.Unlock()
+
+	if p == nil {
+		return ProfileReport{}, fmt.Errorf("profiler not running")
+	}
+	return p.report(n), nil
+}
+
+// run takes samples until ctx is cancelled.
+func (p *actorProfiler) run(ctx context.Context, s *system) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.opts.SampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.sample(now, s.busyActors())
+		}
+	}
+}
+
+// busyActors returns the names of Actors currently handling a message.
+func (s *system) busyActors() []string {
+	var busy []string
+	for _, id := range s.router.List() {
+		a, exists := s.router.Lookup(id)
+		if !exists {
+			continue
+		}
+		if impl, ok := a.(*actor); ok && ActorState(atomic.LoadInt32(&impl.state)) == ActorStateRunning {
+			busy = append(busy, impl.profileName())
+		}
+	}
+	return busy
+}
+
+// sample records one sample taken at now.
+func (p *actorProfiler) sample(now time.Time, busy []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bucketSize := p.opts.Window / time.Duration(len(p.buckets))
+	if now.Sub(p.buckets[p.current].start) >= bucketSize {
+		p.current = (p.current + 1) % len(p.buckets)
+		p.buckets[p.current] = profileBucket{start: now, samples: make(map[string]uint64)}
+	}
+
+	bucket := &p.buckets[p.current]
+	bucket.total++
+	for _, name := range busy {
+		bucket.samples[name]++
+	}
+}
+
+// report aggregates all buckets still inside the window.
+func (p *actorProfiler) report(n int) ProfileReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	samples := make(map[string]uint64)
+	var report ProfileReport
+	var busy uint64
+	oldest := now
+
+	for _, bucket := range p.buckets {
+		if bucket.samples == nil || now.Sub(bucket.start) > p.opts.Window {
+			continue
+		}
+		if bucket.start.Before(oldest) {
+			oldest = bucket.start
+		}
+		report.TotalSamples += bucket.total
+		for name, count := range bucket.samples {
+			samples[name] += count
+			busy += count
+		}
+	}
+
+	report.Window = now.Sub(oldest)
+	for name, count := range samples {
+		report.Actors = append(report.Actors, ActorProfile{
+			Name:     name,
+			Samples:  count,
+			WallTime: time.Duration(count) * p.opts.SampleInterval,
+			Percent:  float64(count) * 100 / float64(busy),
+		})
+	}
+
+	sort.Slice(report.Actors, func(i, j int) bool {
+		if report.Actors[i].Samples != report.Actors[j].Samples {
+			return report.Actors[i].Samples > report.Actors[j].Samples
+		}
+		return report.Actors[i].Name < report.Actors[j].Name
+	})
+
+	if n > 0 && n < len(report.Actors) {
+		report.Actors = report.Actors[:n]
+	}
+	return report
+}
+
+// profileName returns the name the profiler attributes samples to.
+func (a *actor) profileName() string {
+	if a.name != "" {
+		return a.name
+	}
+	return fmt.Sprintf("actor-%d", a.id)
+}
+
+// handle runs the handler, under a pprof label while profiling is active.
+func (a *actor) handle(ctx context.Context, msg *Message) error {
+	if a.profiling == nil || atomic.LoadInt32(a.profiling) == 0 {
+		return a.handler.HandleMessage(ctx, msg)
+	}
+
+	var err error
+	pprof.Do(ctx, pprof.Labels(ProfilerLabel, a.profileName()), func(ctx context.Context) {
+		err = a.handler.HandleMessage(ctx, msg)
+	})
+	return err
+}
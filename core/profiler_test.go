@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+// busyHandler spins for a fixed time per message and records its pprof label.
+type busyHandler struct {
+	spin  time.Duration
+	label chan string
+}
+
+func (h *busyHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	if h.label != nil {
+		value, _ := pprof.Label(ctx, ProfilerLabel)
+		select {
+		case h.label <- value:
+		default:
+		}
+	}
+
+	deadline := time.Now().Add(h.spin)
+	for time.Now().Before(deadline) {
+	}
+	return nil
+}
+
+func TestActorProfiler(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+
+	hot := &busyHandler{spin: 20 * time.Millisecond, label: make(chan string, 1)}
+	if _, err := sys.NewService("hot", hot, DefaultActorOptions()); err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	if _, err := sys.NewService("cold", &busyHandler{spin: time.Millisecond}, DefaultActorOptions()); err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	if _, err := sys.ProfileReport(0); err == nil {
+		t.Error("Expected error before the profiler is started")
+	}
+
+	if err := sys.StartProfiler(ProfilerOptions{SampleInterval: time.Millisecond, Window: time.Minute}); err != nil {
+		t.Fatalf("Failed to start profiler: %v", err)
+	}
+	if err := sys.StartProfiler(ProfilerOptions{}); err == nil {
+		t.Error("Expected error starting the profiler twice")
+	}
+
+	for i := 0; i < 10; i++ {
+		sys.SendByName("", "hot", MessageTypeText, nil)
+		sys.SendByName("", "cold", MessageTypeText, nil)
+	}
+
+	select {
+	case label := <-hot.label:
+		if label != "hot" {
+			t.Errorf("Expected pprof label %q, got %q", "hot", label)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Handler did not run")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	report, err := sys.ProfileReport(1)
+	if err != nil {
+		t.Fatalf("Failed to get report: %v", err)
+	}
+	if len(report.Actors) != 1 || report.Actors[0].Name != "hot" {
+		t.Fatalf("Expected hot to rank first, got %+v", report.Actors)
+	}
+
+	var out strings.Builder
+	if err := report.WriteText(&out); err != nil || !strings.Contains(out.String(), "hot") {
+		t.Errorf("Unexpected text report %q: %v", out.String(), err)
+	}
+
+	final := sys.StopProfiler()
+	if final.TotalSamples == 0 {
+		t.Error("Expected samples in final report")
+	}
+	if _, err := sys.ProfileReport(0); err == nil {
+		t.Error("Expected error after the profiler is stopped")
+	}
+}
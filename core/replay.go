@@ -0,0 +1,239 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrReplayCall is returned by synchronous Calls made while a system is
+// being replayed; their responses are not part of the journal.
+var ErrReplayCall = errors.New("calls are not supported during replay")
+
+// ErrReplayFinished is returned by Step once every entry has been replayed.
+var ErrReplayFinished = errors.New("replay finished")
+
+// Breakpoint selects journal entries a replay should stop before.
+type Breakpoint func(entry JournalEntry) bool
+
+// BreakAtSeq stops before the entry with the given sequence number.
+func BreakAtSeq(seq uint64) Breakpoint {
+	return func(entry JournalEntry) bool {
+		return entry.Seq == seq
+	}
+}
+
+// BreakOnType stops before every message of the given type.
+func BreakOnType(msgType MessageType) Breakpoint {
+	return func(entry JournalEntry) bool {
+		return entry.Type == msgType
+	}
+}
+
+// BreakOnActor stops before every message handled by the named Actor.
+func BreakOnActor(name string) Breakpoint {
+	return func(entry JournalEntry) bool {
+		return entry.ActorName == name
+	}
+}
+
+// Replayer feeds journaled messages back into an ActorSystem one at a time.
+//
+// The system must be freshly built with the same services and Actors, in
+// the same order, as the recorded run. While replaying, the system clock is
+// a FakeClock set to each entry's recorded time, messages are handled
+// synchronously on the caller's goroutine (so debugger breakpoints in
+// handlers work), and messages the handlers send are dropped because they
+// appear later in the journal themselves.
+type Replayer struct {
+	mu          sync.Mutex
+	system      *system
+	entries     []JournalEntry
+	next        int
+	clock       *FakeClock
+	prevClock   Clock
+	breakpoints []Breakpoint
+	stoppedAt   int
+	closed      bool
+}
+
+// NewReplayer puts sys into replay mode for entries.
+func NewReplayer(sys ActorSystem, entries []JournalEntry) (*Replayer, error) {
+	s, ok := sys.(*system)
+	if !ok {
+		return nil, fmt.Errorf("unsupported actor system %T", sys)
+	}
+
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+
+	if s.journal.isReplaying() {
+		return nil, fmt.Errorf("actor system is already replaying")
+	}
+	if s.journal.recording.Load() != nil {
+		return nil, fmt.Errorf("cannot replay a journaling system")
+	}
+
+	r := &Replayer{
+		system:    s,
+		entries:   entries,
+		clock:     NewFakeClock(s.journal.now()),
+		prevClock: s.journal.getClock(),
+		stoppedAt: -1,
+	}
+	if len(entries) > 0 {
+		r.clock.Set(entries[0].Time)
+	}
+
+	s.journal.setClock(r.clock)
+	s.journal.replaying.Store(true)
+	return r, nil
+}
+
+// Clock returns the fake clock driving the replay.
+func (r *Replayer) Clock() *FakeClock {
+	return r.clock
+}
+
+// AddBreakpoint makes Run stop before entries matching bp.
+func (r *Replayer) AddBreakpoint(bp Breakpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakpoints = append(r.breakpoints, bp)
+}
+
+// ClearBreakpoints removes all breakpoints.
+func (r *Replayer) ClearBreakpoints() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakpoints = nil
+}
+
+// Peek returns the entry the next Step will replay.
+func (r *Replayer) Peek() (JournalEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.entries) {
+		return JournalEntry{}, false
+	}
+	return r.entries[r.next], true
+}
+
+// Remaining returns the number of entries not yet replayed.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries) - r.next
+}
+
+// Step replays the next entry and returns it.
+func (r *Replayer) Step() (JournalEntry, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return JournalEntry{}, fmt.Errorf("replayer is closed")
+	}
+	if r.next >= len(r.entries) {
+		r.mu.Unlock()
+		return JournalEntry{}, ErrReplayFinished
+	}
+	entry := r.entries[r.next]
+	r.next++
+	r.mu.Unlock()
+
+	target, err := r.target(entry)
+	if err != nil {
+		return entry, fmt.Errorf("journal entry %d: %w", entry.Seq, err)
+	}
+
+	r.clock.Set(entry.Time)
+	target.processMessage(entry.Message())
+	return entry, nil
+}
+
+// Run replays entries until one matches a breakpoint or the journal ends.
+// It returns the entry it stopped before and true on a breakpoint; the
+// breakpoint entry is replayed by the next Step or Run.
+func (r *Replayer) Run(ctx context.Context) (JournalEntry, bool, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return JournalEntry{}, false, err
+		}
+
+		entry, hit, ok := r.checkBreakpoint()
+		if !ok {
+			return JournalEntry{}, false, nil
+		}
+		if hit {
+			return entry, true, nil
+		}
+
+		if _, err := r.Step(); err != nil {
+			return entry, false, err
+		}
+	}
+}
+
+// Close leaves replay mode and restores the system clock.
+func (r *Replayer) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	r.closed = true
+
+	r.system.journal.mu.Lock()
+	r.system.journal.replaying.Store(false)
+	r.system.journal.setClock(r.prevClock)
+	r.system.journal.mu.Unlock()
+}
+
+// checkBreakpoint returns the next entry and whether Run should stop before
+// it; an entry Run already stopped before is not stopped at again.
+func (r *Replayer) checkBreakpoint() (JournalEntry, bool, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.entries) {
+		return JournalEntry{}, false, false
+	}
+	entry := r.entries[r.next]
+	if r.stoppedAt == r.next {
+		return entry, false, true
+	}
+
+	for _, bp := range r.breakpoints {
+		if bp(entry) {
+			r.stoppedAt = r.next
+			return entry, true, true
+		}
+	}
+	return entry, false, true
+}
+
+// target resolves the Actor an entry was handled by: named services by
+// name, other Actors by ID.
+func (r *Replayer) target(entry JournalEntry) (*actor, error) {
+	var found Actor
+	if entry.ActorName != "" {
+		if handle, ok := r.system.router.LookupService(entry.ActorName); ok {
+			found, _ = r.system.router.Lookup(handle.ActorID)
+		}
+	}
+	if found == nil {
+		found, _ = r.system.router.Lookup(entry.Actor)
+	}
+	if found == nil {
+		return nil, fmt.Errorf("actor %d (%q) not found", entry.Actor, entry.ActorName)
+	}
+
+	impl, ok := found.(*actor)
+	if !ok {
+		return nil, fmt.Errorf("actor %d is not replayable", entry.Actor)
+	}
+	return impl, nil
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ledgerHandler logs every message with the system time and forwards it to
+// the audit service.
+type ledgerHandler struct {
+	sys ActorSystem
+
+	mu  sync.Mutex
+	log []string
+}
+
+func (h *ledgerHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	h.mu.Lock()
+	h.log = append(h.log, fmt.Sprintf("%s@%d", msg.Data, h.sys.Clock().Now().Unix()))
+	h.mu.Unlock()
+
+	return h.sys.SendByName("ledger", "audit", MessageTypeText, msg.Data)
+}
+
+func (h *ledgerHandler) entries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.log...)
+}
+
+// newLedgerSystem builds the services of the recorded run.
+func newLedgerSystem(t *testing.T) (ActorSystem, *ledgerHandler, *ledgerHandler) {
+	sys := NewActorSystem()
+	ledger := &ledgerHandler{sys: sys}
+	audit := &ledgerHandler{sys: sys}
+	if _, err := sys.NewService("ledger", ledger, DefaultActorOptions()); err != nil {
+		t.Fatalf("Failed to create ledger: %v", err)
+	}
+	if _, err := sys.NewService("audit", &auditHandler{audit}, DefaultActorOptions()); err != nil {
+		t.Fatalf("Failed to create audit: %v", err)
+	}
+	return sys, ledger, audit
+}
+
+// auditHandler logs messages without forwarding them.
+type auditHandler struct {
+	*ledgerHandler
+}
+
+func (h *auditHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.log = append(h.log, fmt.Sprintf("%s@%d", msg.Data, h.sys.Clock().Now().Unix()))
+	return nil
+}
+
+func TestJournalReplay(t *testing.T) {
+	// Record a run driven by a fake clock
+	sys, ledger, audit := newLedgerSystem(t)
+	clock := NewFakeClock(time.Unix(1000, 0))
+	sys.SetClock(clock)
+
+	var buf bytes.Buffer
+	if err := sys.StartJournal(NewJSONJournal(&buf)); err != nil {
+		t.Fatalf("Failed to start journal: %v", err)
+	}
+	handle, _ := sys.GetService("ledger")
+	for i, data := range []string{"a", "b", "c"} {
+		clock.Advance(time.Second)
+		if err := sys.Send(0, handle.ActorID, MessageTypeText, []byte(data)); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for len(audit.entries()) <= i {
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for audit")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	sys.StopJournal()
+	sys.Shutdown(context.Background())
+
+	entries, err := ReadJournal(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	if len(entries) != 6 {
+		t.Fatalf("Expected 6 journal entries, got %d", len(entries))
+	}
+
+	// Replay into a fresh system with a breakpoint on the second audit entry
+	replaySys, replayLedger, replayAudit := newLedgerSystem(t)
+	defer replaySys.Shutdown(context.Background())
+
+	replayer, err := NewReplayer(replaySys, entries)
+	if err != nil {
+		t.Fatalf("Failed to create replayer: %v", err)
+	}
+	defer replayer.Close()
+
+	var breakSeq uint64
+	for _, entry := range entries {
+		if entry.ActorName == "audit" && string(entry.Data) == "b" {
+			breakSeq = entry.Seq
+		}
+	}
+	replayer.AddBreakpoint(BreakAtSeq(breakSeq))
+
+	entry, hit, err := replayer.Run(context.Background())
+	if err != nil || !hit || entry.Seq != breakSeq {
+		t.Fatalf("Expected to stop at seq %d, got %d (hit=%v, err=%v)", breakSeq, entry.Seq, hit, err)
+	}
+	if got := replayAudit.entries(); !reflect.DeepEqual(got, []string{"a@1001"}) {
+		t.Errorf("Unexpected audit state at breakpoint: %v", got)
+	}
+
+	if _, err := replayer.Step(); err != nil {
+		t.Fatalf("Failed to step: %v", err)
+	}
+	if _, hit, err := replayer.Run(context.Background()); hit || err != nil {
+		t.Fatalf("Expected replay to finish, got hit=%v err=%v", hit, err)
+	}
+	if _, err := replayer.Step(); err != ErrReplayFinished {
+		t.Errorf("Expected ErrReplayFinished, got %v", err)
+	}
+
+	if !reflect.DeepEqual(replayLedger.entries(), ledger.entries()) {
+		t.Errorf("Ledger diverged: %v vs %v", replayLedger.entries(), ledger.entries())
+	}
+	if !reflect.DeepEqual(replayAudit.entries(), audit.entries()) {
+		t.Errorf("Audit diverged: %v vs %v", replayAudit.entries(), audit.entries())
+	}
+
+	// Handler sends were dropped rather than delivered a second time
+	time.Sleep(20 * time.Millisecond)
+	if got := len(replayAudit.entries()); got != 3 {
+		t.Errorf("Expected 3 audit entries after replay, got %d", got)
+	}
+}
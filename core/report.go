@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Severity grades an error reported from a background goroutine
+type Severity int
+
+const (
+	// SeverityWarning is a recoverable problem, e.g. a failed retry
+	SeverityWarning Severity = iota
+	// SeverityError is a failure that lost work or gave up on something
+	SeverityError
+	// SeverityCritical is a failure that needs attention now, e.g. a panic
+	SeverityCritical
+)
+
+// String returns the name of the severity
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// AsyncError is an error raised where there is no caller to return it to,
+// such as an accept loop, a timer or a reconnect goroutine
+type AsyncError struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace,omitempty"`
+	Module    string    `json:"module"`
+	Source    string    `json:"source"`
+	Severity  Severity  `json:"severity"`
+	Err       error     `json:"-"`
+}
+
+// Error implements error
+func (e AsyncError) Error() string {
+	return fmt.Sprintf("%s/%s: %v", e.Module, e.Source, e.Err)
+}
+
+// ErrorReporter receives the errors of background goroutines
+type ErrorReporter func(AsyncError)
+
+var errorReporter atomic.Value // ErrorReporter
+
+// SetErrorReporter routes background errors of all modules to reporter;
+// nil restores printing them to stdout
+func SetErrorReporter(reporter ErrorReporter) {
+	errorReporter.Store(reporter)
+}
+
+// ReportError reports an error of a background goroutine. module is the
+// package raising it and source the goroutine or component, e.g.
+// "network", "accept".
+func ReportError(module, source string, severity Severity, err error) {
+	report := AsyncError{
+		Time:      time.Now(),
+		Namespace: TelemetryNamespace(),
+		Module:    module,
+		Source:    source,
+		Severity:  severity,
+		Err:       err,
+	}
+	if t := currentTelemetry(); t != nil {
+		t.ErrorReported(report)
+	}
+	if reporter, _ := errorReporter.Load().(ErrorReporter); reporter != nil {
+		reporter(report)
+		return
+	}
+	fmt.Printf("[%s] %s\n", severity, report.Error())
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"sync"
+	"time"
+)
+
+// ErrAttemptTimeout is returned for an attempt of CallWithRetry that ran
+// out of its AttemptTimeout while the caller was still waiting.
+var ErrAttemptTimeout = errors.New("call attempt timed out")
+
+// CallError is the error response of a handler, returned by CallWithRetry.
+type CallError struct {
+	Actor   ActorID
+	Message string
+}
+
+// Error implements error.
+func (e *CallError) Error() string {
+	return fmt.Sprintf("remote error from actor %d: %s", e.Actor, e.Message)
+}
+
+// IsRetryable reports whether a failed call may succeed when repeated: the
+// callee was overloaded (ErrMailboxFull, ErrRateLimited), an attempt timed
+// out, or the error says so with a Retryable() bool method. Handler errors
+// and stopped Actors are not retryable.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrMailboxFull) || errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrAttemptTimeout) {
+		return true
+	}
+	var retryable interface{ Retryable() bool }
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
+
+// RetryPolicy configures CallWithRetry.
+type RetryPolicy struct {
+	// MaxAttempts bounds the calls made, including the first one
+	MaxAttempts int
+
+	// InitialBackoff is the pause before the second attempt; it grows by
+	// Multiplier for every further attempt, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Jitter randomizes each pause by up to this fraction of it, so callers
+	// failing together do not retry together
+	Jitter float64
+
+	// AttemptTimeout bounds each attempt; 0 leaves only the deadline of ctx
+	AttemptTimeout time.Duration
+
+	// RetryOn decides whether an error is retried; nil means IsRetryable
+	RetryOn func(err error) bool
+}
+
+// DefaultRetryPolicy returns a policy of 3 attempts with jittered
+// exponential backoff from 50ms.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// backoff returns the pause before attempt n (n >= 2).
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 2; i < n; i++ {
+		d *= p.Multiplier
+		if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*mathrand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// CallWithRetry calls target with msg and repeats the call under policy
+// while it fails with a retryable error. Every attempt carries the same
+// IdempotencyKey, generated when msg has none, so the callee can tell a
+// re-execution from a new request. An error response of the handler is
+// returned as *CallError.
+func CallWithRetry(ctx context.Context, target Actor, msg *Message, policy RetryPolicy) (*Message, error) {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 1
+	}
+	retryOn := policy.RetryOn
+	if retryOn == nil {
+		retryOn = IsRetryable
+	}
+	if msg.IdempotencyKey == "" {
+		msg.IdempotencyKey = NewIdempotencyKey()
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			timer := time.NewTimer(policy.backoff(attempt))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			}
+		}
+
+		resp, err := callAttempt(ctx, target, msg, policy.AttemptTimeout)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		lastErr = err
+		if !retryOn(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("call failed after %d attempts: %w", policy.MaxAttempts, lastErr)
+}
+
+// callAttempt makes one attempt of CallWithRetry on a copy of msg, since
+// Call assigns the session of the attempt to the message.
+func callAttempt(ctx context.Context, target Actor, msg *Message, timeout time.Duration) (*Message, error) {
+	attemptCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	attempt := *msg
+	resp, err := target.Call(attemptCtx, &attempt)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("actor %d: %w", target.ID(), ErrAttemptTimeout)
+		}
+		return nil, err
+	}
+	if resp.Type == MessageTypeError {
+		return nil, &CallError{Actor: target.ID(), Message: string(resp.Data)}
+	}
+	return resp, nil
+}
+
+// NewIdempotencyKey returns a random idempotency key.
+func NewIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// IdempotencyCache lets a handler execute each idempotency key once: a
+// repeated key gets the result of the first execution for TTL after it
+// completed. It is safe for concurrent use, so Actors with parallel
+// handling can share one.
+type IdempotencyCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*idempotentResult
+	lastSweep time.Time
+}
+
+// idempotentResult is the result of one execution.
+type idempotentResult struct {
+	done    chan struct{}
+	data    []byte
+	err     error
+	expires time.Time
+}
+
+// NewIdempotencyCache creates a cache remembering results for ttl.
+func NewIdempotencyCache(ttl time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		ttl:     ttl,
+		entries: make(map[string]*idempotentResult),
+	}
+}
+
+// Do runs fn for a new key and returns its result; for a key seen within
+// the TTL it returns the remembered result instead, waiting for an
+// execution still in progress. An empty key always runs fn.
+func (c *IdempotencyCache) Do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	if key == "" {
+		return fn()
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	c.sweep(now)
+	if entry, ok := c.entries[key]; ok && (entry.expires.IsZero() || now.Before(entry.expires)) {
+		c.mu.Unlock()
+		<-entry.done
+		return entry.data, entry.err
+	}
+	entry := &idempotentResult{done: make(chan struct{})}
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		entry.expires = time.Now().Add(c.ttl)
+		c.mu.Unlock()
+		close(entry.done)
+	}()
+	entry.data, entry.err = fn()
+	return entry.data, entry.err
+}
+
+// Len returns the number of remembered keys.
+func (c *IdempotencyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// sweep forgets expired results, at most once per TTL.
+func (c *IdempotencyCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	c.lastSweep = now
+	for key, entry := range c.entries {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// dedupingHandler executes each idempotency key once; the first execution
+// is slower than an attempt timeout.
+type dedupingHandler struct {
+	cache      *IdempotencyCache
+	executions int32
+	keys       chan string
+}
+
+func (h *dedupingHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	h.keys <- msg.IdempotencyKey
+	_, err := h.cache.Do(msg.IdempotencyKey, func() ([]byte, error) {
+		if atomic.AddInt32(&h.executions, 1) == 1 {
+			time.Sleep(60 * time.Millisecond)
+		}
+		if string(msg.Data) == "fail" {
+			return nil, errors.New("invalid request")
+		}
+		return nil, nil
+	})
+	return err
+}
+
+func TestCallWithRetryDedupesReexecution(t *testing.T) {
+	handler := &dedupingHandler{cache: NewIdempotencyCache(time.Minute), keys: make(chan string, 10)}
+	a := NewActor(1, handler, NewActorOptions())
+	a.Start(context.Background())
+	defer a.Stop()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.AttemptTimeout = 20 * time.Millisecond
+	policy.MaxAttempts = 10
+
+	_, err := CallWithRetry(context.Background(), a, &Message{Type: MessageTypeRequest}, policy)
+	if err != nil {
+		t.Fatalf("Expected call to succeed after retries, got %v", err)
+	}
+	if n := atomic.LoadInt32(&handler.executions); n != 1 {
+		t.Errorf("Expected one execution, got %d", n)
+	}
+
+	first := <-handler.keys
+	if first == "" {
+		t.Fatal("Expected an idempotency key")
+	}
+	if second := <-handler.keys; second != first {
+		t.Errorf("Expected retries to reuse key %q, got %q", first, second)
+	}
+}
+
+func TestCallWithRetryStopsOnHandlerError(t *testing.T) {
+	handler := &dedupingHandler{cache: NewIdempotencyCache(time.Minute), keys: make(chan string, 10)}
+	atomic.StoreInt32(&handler.executions, 1)
+	a := NewActor(1, handler, NewActorOptions())
+	a.Start(context.Background())
+	defer a.Stop()
+
+	_, err := CallWithRetry(context.Background(), a,
+		&Message{Type: MessageTypeRequest, Data: []byte("fail")}, DefaultRetryPolicy())
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Message != "invalid request" {
+		t.Fatalf("Expected CallError, got %v", err)
+	}
+	if len(handler.keys) != 1 {
+		t.Errorf("Expected a single attempt, got %d", len(handler.keys))
+	}
+}
+
+func TestCallWithRetryRetriesRateLimit(t *testing.T) {
+	handler := &dedupingHandler{cache: NewIdempotencyCache(time.Minute), keys: make(chan string, 10)}
+	atomic.StoreInt32(&handler.executions, 1)
+	a := NewActor(1, handler, NewActorOptions(WithRateLimit(50, 1)))
+	a.Start(context.Background())
+	defer a.Stop()
+
+	// Use up the burst so the first attempt is refused
+	a.Send(&Message{Type: MessageTypeRequest})
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = 30 * time.Millisecond
+	if _, err := CallWithRetry(context.Background(), a, &Message{Type: MessageTypeRequest}, policy); err != nil {
+		t.Fatalf("Expected rate limited call to succeed when retried, got %v", err)
+	}
+
+	policy.MaxAttempts = 1
+	a.Send(&Message{Type: MessageTypeRequest})
+	_, err := CallWithRetry(context.Background(), a, &Message{Type: MessageTypeRequest}, policy)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited once attempts run out, got %v", err)
+	}
+}
+
+func TestIdempotencyCacheExpires(t *testing.T) {
+	cache := NewIdempotencyCache(10 * time.Millisecond)
+	runs := 0
+	run := func() ([]byte, error) {
+		runs++
+		return []byte("result"), nil
+	}
+
+	cache.Do("k", run)
+	if data, _ := cache.Do("k", run); string(data) != "result" || runs != 1 {
+		t.Errorf("Expected remembered result, runs=%d", runs)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	cache.Do("k", run)
+	if runs != 2 {
+		t.Errorf("Expected key to run again after TTL, runs=%d", runs)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Expected expired entry to be swept, got %d", cache.Len())
+	}
+}
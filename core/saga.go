@@ -0,0 +1,542 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSagaAborted is returned when a saga step failed and the completed
+// steps were compensated.
+var ErrSagaAborted = errors.New("saga aborted")
+
+// ErrSagaCompensationFailed is returned when a saga could not be rolled
+// back; its state is kept so a later Resume can retry the compensation.
+var ErrSagaCompensationFailed = errors.New("saga compensation failed")
+
+// SagaStatus is the progress of a saga.
+type SagaStatus string
+
+const (
+	// SagaRunning sagas are executing their steps
+	SagaRunning SagaStatus = "running"
+
+	// SagaCompensating sagas are undoing their completed steps
+	SagaCompensating SagaStatus = "compensating"
+
+	// SagaCompleted sagas ran every step
+	SagaCompleted SagaStatus = "completed"
+
+	// SagaCompensated sagas failed and were rolled back
+	SagaCompensated SagaStatus = "compensated"
+)
+
+// SagaCall is a call made to a named service by a saga step.
+type SagaCall struct {
+	Service string      `json:"service"`
+	Type    MessageType `json:"type"`
+	Data    []byte      `json:"data,omitempty"`
+}
+
+// SagaStep is one step of a saga: an action and the call undoing it.
+// Compensations must be idempotent and tolerate an action that never
+// took effect: a step whose call timed out, or that was in flight when
+// the process crashed, is compensated as if it had been applied.
+type SagaStep struct {
+	Name         string        `json:"name"`
+	Action       SagaCall      `json:"action"`
+	Compensation *SagaCall     `json:"compensation,omitempty"`
+	Timeout      time.Duration `json:"timeout,omitempty"`
+}
+
+// SagaState is the persisted progress of a saga.
+type SagaState struct {
+	ID     string     `json:"id"`
+	Steps  []SagaStep `json:"steps"`
+	Status SagaStatus `json:"status"`
+
+	// Next is the step being executed while running, and the number of
+	// steps still to compensate while compensating
+	Next int `json:"next"`
+
+	// Results holds the responses of the completed actions
+	Results [][]byte `json:"results,omitempty"`
+
+	// Error is the failure that triggered compensation
+	Error string `json:"error,omitempty"`
+
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Finished reports whether the saga reached a final status.
+func (s *SagaState) Finished() bool {
+	return s.Status == SagaCompleted || s.Status == SagaCompensated
+}
+
+// SagaStore persists saga state so unfinished sagas survive a crash.
+type SagaStore interface {
+	// Save stores the state of a saga, replacing any earlier state
+	Save(ctx context.Context, state *SagaState) error
+
+	// Load returns the state of a saga, or nil if it is unknown
+	Load(ctx context.Context, id string) (*SagaState, error)
+
+	// List returns the states of every stored saga
+	List(ctx context.Context) ([]*SagaState, error)
+
+	// Delete forgets a saga
+	Delete(ctx context.Context, id string) error
+}
+
+// MemorySagaStore keeps saga state in memory, for tests and for sagas that
+// need not survive a restart.
+type MemorySagaStore struct {
+	mu     sync.Mutex
+	states map[string][]byte
+}
+
+// NewMemorySagaStore creates an empty MemorySagaStore.
+func NewMemorySagaStore() *MemorySagaStore {
+	return &MemorySagaStore{states: make(map[string][]byte)}
+}
+
+// Save stores a copy of the state.
+func (m *MemorySagaStore) Save(ctx context.Context, state *SagaState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[state.ID] = data
+	return nil
+}
+
+// Load returns a copy of the state of a saga.
+func (m *MemorySagaStore) Load(ctx context.Context, id string) (*SagaState, error) {
+	m.mu.Lock()
+	data, exists := m.states[id]
+	m.mu.Unlock()
+	if !exists {
+		return nil, nil
+	}
+	var state SagaState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// List returns copies of every stored state, sorted by ID.
+func (m *MemorySagaStore) List(ctx context.Context) ([]*SagaState, error) {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.states))
+	for id := range m.states {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+	sort.Strings(ids)
+
+	states := make([]*SagaState, 0, len(ids))
+	for _, id := range ids {
+		state, err := m.Load(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if state != nil {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// Delete forgets a saga.
+func (m *MemorySagaStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, id)
+	return nil
+}
+
+// FileSagaStore keeps one JSON file per saga in a directory. Files are
+// replaced atomically, so a crash never leaves a torn state behind. Each
+// operation checks ctx between its file system calls and gives up once it
+// is done; a single call blocked on a slow disk still runs to its end.
+type FileSagaStore struct {
+	dir string
+}
+
+// NewFileSagaStore creates a store in dir, creating the directory if needed.
+func NewFileSagaStore(dir string) (*FileSagaStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create saga directory: %w", err)
+	}
+	return &FileSagaStore{dir: dir}, nil
+}
+
+// path returns the file holding a saga.
+func (f *FileSagaStore) path(id string) string {
+	return filepath.Join(f.dir, id+".json")
+}
+
+// Save writes the state to a temporary file, synced before it is renamed
+// into place and the directory synced after. Once ctx is done the earlier
+// state is kept.
+func (f *FileSagaStore) Save(ctx context.Context, state *SagaState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to write saga %s: %w", state.ID, err)
+	}
+	tmp := f.path(state.ID) + ".tmp"
+	if err := writeSynced(tmp, data); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write saga %s: %w", state.ID, err)
+	}
+	if err := ctx.Err(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write saga %s: %w", state.ID, err)
+	}
+	if err := os.Rename(tmp, f.path(state.ID)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write saga %s: %w", state.ID, err)
+	}
+	if dir, err := os.Open(f.dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// writeSynced writes data to a new file at path and syncs it to disk, so
+// a rename never exposes a file whose content is still in flight.
+func writeSynced(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load reads the state of a saga.
+func (f *FileSagaStore) Load(ctx context.Context, id string) (*SagaState, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read saga %s: %w", id, err)
+	}
+	data, err := os.ReadFile(f.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saga %s: %w", id, err)
+	}
+	var state SagaState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode saga %s: %w", id, err)
+	}
+	return &state, nil
+}
+
+// List reads every saga in the directory, sorted by ID.
+func (f *FileSagaStore) List(ctx context.Context) ([]*SagaState, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sagas: %w", err)
+	}
+
+	var states []*SagaState
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		state, err := f.Load(ctx, strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if state != nil {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// Delete removes the file of a saga.
+func (f *FileSagaStore) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to delete saga %s: %w", id, err)
+	}
+	err := os.Remove(f.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SagaOptions configures a SagaCoordinator.
+type SagaOptions struct {
+	// StepTimeout bounds each call of a step without its own Timeout
+	StepTimeout time.Duration
+
+	// CompensationRetries is how often a failing compensation is retried
+	// before the saga is left for Resume
+	CompensationRetries int
+
+	// RetryBackoff is the pause between compensation retries
+	RetryBackoff time.Duration
+
+	// KeepFinished keeps finished sagas in the store instead of deleting them
+	KeepFinished bool
+}
+
+// SagaCoordinator runs multi-actor workflows as sagas: each step calls a
+// service, and when a step fails the completed steps are compensated in
+// reverse order. Progress is persisted before and after every call, so
+// the sagas interrupted by a crash can be finished with Resume.
+type SagaCoordinator struct {
+	system ActorSystem
+	from   ActorID
+	store  SagaStore
+	opts   SagaOptions
+
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+// NewSagaCoordinator creates a coordinator whose calls carry from as their
+// source and which persists saga state in store.
+func NewSagaCoordinator(system ActorSystem, from ActorID, store SagaStore, opts SagaOptions) *SagaCoordinator {
+	if opts.StepTimeout <= 0 {
+		opts.StepTimeout = 5 * time.Second
+	}
+	if opts.CompensationRetries < 0 {
+		opts.CompensationRetries = 0
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 100 * time.Millisecond
+	}
+	return &SagaCoordinator{
+		system: system,
+		from:   from,
+		store:  store,
+		opts:   opts,
+		active: make(map[string]bool),
+	}
+}
+
+// Execute runs a new saga to completion. When a step fails, the completed
+// steps are compensated and the error wraps ErrSagaAborted; if a
+// compensation fails too, it wraps ErrSagaCompensationFailed.
+func (c *SagaCoordinator) Execute(ctx context.Context, id string, steps []SagaStep) (*SagaState, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid saga ID %q", id)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("saga %s has no steps", id)
+	}
+
+	existing, err := c.store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("saga %s already exists", id)
+	}
+
+	now := time.Now()
+	state := &SagaState{
+		ID:        id,
+		Steps:     steps,
+		Status:    SagaRunning,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	return c.drive(ctx, state)
+}
+
+// Resume finishes the sagas left unfinished in the store, e.g. after a
+// crash. A saga interrupted while running is compensated, including the
+// step that was in flight.
+func (c *SagaCoordinator) Resume(ctx context.Context) ([]*SagaState, error) {
+	states, err := c.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resumed []*SagaState
+	var errs []error
+	for _, state := range states {
+		if state.Finished() {
+			continue
+		}
+		if state.Status == SagaRunning {
+			// Whether the in-flight action was applied is unknown
+			state.Status = SagaCompensating
+			if state.Next < len(state.Steps) {
+				state.Next++
+			}
+			if state.Error == "" {
+				state.Error = "interrupted"
+			}
+		}
+
+		result, err := c.drive(ctx, state)
+		if result != nil {
+			resumed = append(resumed, result)
+		}
+		if err != nil && !errors.Is(err, ErrSagaAborted) {
+			errs = append(errs, err)
+		}
+	}
+	return resumed, errors.Join(errs...)
+}
+
+// drive advances a saga until it finishes or compensation gives up.
+func (c *SagaCoordinator) drive(ctx context.Context, state *SagaState) (*SagaState, error) {
+	c.mu.Lock()
+	if c.active[state.ID] {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("saga %s is already running", state.ID)
+	}
+	c.active[state.ID] = true
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.active, state.ID)
+		c.mu.Unlock()
+	}()
+
+	for state.Status == SagaRunning && state.Next < len(state.Steps) {
+		if err := c.save(ctx, state); err != nil {
+			return state, err
+		}
+
+		step := state.Steps[state.Next]
+		result, err := c.call(ctx, step, step.Action)
+		if err != nil {
+			state.Status = SagaCompensating
+			state.Error = fmt.Sprintf("step %s: %v", step.Name, err)
+			if errors.Is(err, context.DeadlineExceeded) {
+				// The step may have been applied before its call timed out
+				state.Next++
+			}
+			break
+		}
+
+		state.Results = append(state.Results, result)
+		state.Next++
+	}
+
+	if state.Status == SagaRunning {
+		state.Status = SagaCompleted
+		return state, c.finish(ctx, state)
+	}
+
+	for state.Next > 0 {
+		if err := c.save(ctx, state); err != nil {
+			return state, err
+		}
+
+		step := state.Steps[state.Next-1]
+		if step.Compensation != nil {
+			if err := c.compensate(ctx, step); err != nil {
+				return state, fmt.Errorf("%w: saga %s, step %s: %v", ErrSagaCompensationFailed, state.ID, step.Name, err)
+			}
+		}
+		state.Next--
+	}
+
+	state.Status = SagaCompensated
+	if err := c.finish(ctx, state); err != nil {
+		return state, err
+	}
+	return state, fmt.Errorf("%w: saga %s: %s", ErrSagaAborted, state.ID, state.Error)
+}
+
+// compensate runs a step's compensation, retrying failures.
+func (c *SagaCoordinator) compensate(ctx context.Context, step SagaStep) error {
+	var err error
+	for attempt := 0; attempt <= c.opts.CompensationRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(c.opts.RetryBackoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if _, err = c.call(ctx, step, *step.Compensation); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// call makes one call of a step with the step timeout.
+func (c *SagaCoordinator) call(ctx context.Context, step SagaStep, call SagaCall) ([]byte, error) {
+	timeout := step.Timeout
+	if timeout <= 0 {
+		timeout = c.opts.StepTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	handle, exists := c.system.GetService(call.Service)
+	if !exists {
+		return nil, fmt.Errorf("service '%s' not found", call.Service)
+	}
+	target, exists := c.system.GetActor(handle.ActorID)
+	if !exists {
+		return nil, fmt.Errorf("service '%s' has no running actor", call.Service)
+	}
+
+	resp, err := target.Call(ctx, &Message{
+		Type:      call.Type,
+		Source:    c.from,
+		Target:    handle.ActorID,
+		Data:      call.Data,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Type == MessageTypeError {
+		return nil, fmt.Errorf("remote error: %s", string(resp.Data))
+	}
+	return resp.Data, nil
+}
+
+// save persists the progress of a saga.
+func (c *SagaCoordinator) save(ctx context.Context, state *SagaState) error {
+	state.UpdatedAt = time.Now()
+	if err := c.store.Save(ctx, state); err != nil {
+		return fmt.Errorf("failed to persist saga %s: %w", state.ID, err)
+	}
+	return nil
+}
+
+// finish persists or forgets a finished saga.
+func (c *SagaCoordinator) finish(ctx context.Context, state *SagaState) error {
+	if c.opts.KeepFinished {
+		return c.save(ctx, state)
+	}
+	return c.store.Delete(ctx, state.ID)
+}
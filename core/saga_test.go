@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// walletHandler applies signed amounts to a balance that may not go negative
+type walletHandler struct {
+	mu      sync.Mutex
+	balance int
+}
+
+func (h *walletHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	amount, err := strconv.Atoi(string(msg.Data))
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.balance+amount < 0 {
+		return fmt.Errorf("insufficient funds")
+	}
+	h.balance += amount
+	return nil
+}
+
+func (h *walletHandler) Balance() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.balance
+}
+
+// transfer returns the steps moving gold from one wallet to another
+func transfer(from, to string, gold int) []SagaStep {
+	call := func(service string, amount int) *SagaCall {
+		return &SagaCall{Service: service, Type: MessageTypeRequest, Data: []byte(strconv.Itoa(amount))}
+	}
+	return []SagaStep{
+		{Name: "debit", Action: *call(from, -gold), Compensation: call(from, gold)},
+		{Name: "credit", Action: *call(to, gold), Compensation: call(to, -gold)},
+	}
+}
+
+func newSagaTestSystem(t *testing.T) (ActorSystem, Actor, *walletHandler, *walletHandler) {
+	sys := NewActorSystem()
+	t.Cleanup(func() { sys.Shutdown(context.Background()) })
+
+	alice := &walletHandler{balance: 100}
+	bob := &walletHandler{balance: 0}
+	if _, err := sys.NewService("alice", alice, DefaultActorOptions()); err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	if _, err := sys.NewService("bob", bob, DefaultActorOptions()); err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	coordinator, err := sys.NewActor(&auditorHandler{}, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create actor: %v", err)
+	}
+	return sys, coordinator, alice, bob
+}
+
+func TestSagaCompensatesFailedStep(t *testing.T) {
+	sys, from, alice, bob := newSagaTestSystem(t)
+	store := NewMemorySagaStore()
+	sagas := NewSagaCoordinator(sys, from.ID(), store, SagaOptions{StepTimeout: time.Second})
+	ctx := context.Background()
+
+	state, err := sagas.Execute(ctx, "trade-1", transfer("alice", "bob", 30))
+	if err != nil || state.Status != SagaCompleted {
+		t.Fatalf("Expected completed saga, got %v (%v)", state, err)
+	}
+	if alice.Balance() != 70 || bob.Balance() != 30 {
+		t.Errorf("Unexpected balances: alice=%d bob=%d", alice.Balance(), bob.Balance())
+	}
+
+	// The credit fails, so bob's debit is undone
+	state, err = sagas.Execute(ctx, "trade-2", transfer("bob", "missing", 20))
+	if !errors.Is(err, ErrSagaAborted) || state.Status != SagaCompensated {
+		t.Fatalf("Expected aborted saga, got %v (%v)", state, err)
+	}
+	if bob.Balance() != 30 {
+		t.Errorf("Expected bob's debit to be compensated, balance %d", bob.Balance())
+	}
+
+	if states, _ := store.List(context.Background()); len(states) != 0 {
+		t.Errorf("Expected finished sagas to be deleted, got %d", len(states))
+	}
+}
+
+func TestSagaResumeAfterCrash(t *testing.T) {
+	sys, from, alice, bob := newSagaTestSystem(t)
+	store, err := NewFileSagaStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// The process crashed while crediting bob, after alice was debited
+	alice.balance = 70
+	steps := transfer("alice", "bob", 30)
+	if err := store.Save(context.Background(), &SagaState{ID: "trade-3", Steps: steps, Status: SagaRunning, Next: 1}); err != nil {
+		t.Fatalf("Failed to save saga: %v", err)
+	}
+
+	// The credit never took effect, so its compensation cannot apply
+	// either; only alice's debit is undone
+	sagas := NewSagaCoordinator(sys, from.ID(), store, SagaOptions{
+		StepTimeout:         time.Second,
+		CompensationRetries: 1,
+		RetryBackoff:        time.Millisecond,
+		KeepFinished:        true,
+	})
+	resumed, err := sagas.Resume(context.Background())
+	if !errors.Is(err, ErrSagaCompensationFailed) || len(resumed) != 1 {
+		t.Fatalf("Expected failed compensation of bob's credit, got %v", err)
+	}
+	if resumed[0].Status != SagaCompensating || resumed[0].Next != 2 {
+		t.Errorf("Expected saga to stay compensating at step 2, got %+v", resumed[0])
+	}
+
+	// Once bob's compensation can succeed, a later resume finishes the saga
+	bob.balance = 30
+	resumed, err = sagas.Resume(context.Background())
+	if err != nil || len(resumed) != 1 || resumed[0].Status != SagaCompensated {
+		t.Fatalf("Expected compensated saga, got %v", err)
+	}
+	if alice.Balance() != 100 || bob.Balance() != 0 {
+		t.Errorf("Unexpected balances: alice=%d bob=%d", alice.Balance(), bob.Balance())
+	}
+
+	state, err := store.Load(context.Background(), "trade-3")
+	if err != nil || state == nil || state.Status != SagaCompensated {
+		t.Errorf("Expected finished saga to be kept, got %v (%v)", state, err)
+	}
+
+	// A write whose caller gave up keeps the earlier state
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := store.Save(expired, &SagaState{ID: "trade-3", Status: SagaRunning}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the save to fail with its context, got %v", err)
+	}
+	if state, _ := store.Load(context.Background(), "trade-3"); state == nil || state.Status != SagaCompensated {
+		t.Errorf("Expected the earlier state kept, got %+v", state)
+	}
+}
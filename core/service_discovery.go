@@ -13,6 +13,9 @@ type LoadBalancer interface {
 	// Select chooses the best service instance based on the load balancing strategy
 	Select(services []*ServiceInfo) (*ServiceInfo, error)
 
+	// SelectKey chooses a service instance for a request with a routing key
+	SelectKey(services []*ServiceInfo, key string) (*ServiceInfo, error)
+
 	// UpdateMetrics updates the metrics for a service instance
 	UpdateMetrics(serviceID string, metrics ServiceMetrics) error
 
@@ -36,8 +39,15 @@ const (
 	// StrategyWeightedRoundRobin uses service weights for selection
 	StrategyWeightedRoundRobin
 
-	// StrategyConsistentHash uses consistent hashing for selection
+	// StrategyConsistentHash maps routing keys to instances on a hash ring
 	StrategyConsistentHash
+
+	// StrategyPowerOfTwoChoices picks the less loaded of two random services
+	StrategyPowerOfTwoChoices
+
+	// StrategyCustom is reported by load balancers running a strategy
+	// registered with RegisterStrategy
+	StrategyCustom
 )
 
 // String returns the string representation of LoadBalanceStrategy.
@@ -53,6 +63,10 @@ func (s LoadBalanceStrategy) String() string {
 		return "weighted_round_robin"
 	case StrategyConsistentHash:
 		return "consistent_hash"
+	case StrategyPowerOfTwoChoices:
+		return "power_of_two_choices"
+	case StrategyCustom:
+		return "custom"
 	default:
 		return "unknown"
 	}
@@ -97,32 +111,46 @@ func (m ServiceMetrics) SuccessRate() float64 {
 // loadBalancer implements the LoadBalancer interface.
 type loadBalancer struct {
 	strategy LoadBalanceStrategy
+	impl     Strategy
 	mu       sync.RWMutex
 
-	// Round robin state
-	roundRobinIndex int
-
 	// Service metrics
 	metrics map[string]*ServiceMetrics // key: service name
 
-	// Weighted round robin state
-	weightedServices []weightedService
-	weightedIndex    int
-
 	// Random generator
 	rand *rand.Rand
 }
 
-// weightedService holds service info with its weight.
-type weightedService struct {
-	Service *ServiceInfo
-	Weight  int
-}
-
 // NewLoadBalancer creates a new LoadBalancer with the specified strategy.
 func NewLoadBalancer(strategy LoadBalanceStrategy) LoadBalancer {
+	impl, err := NewStrategy(strategy.String())
+	if err != nil {
+		impl = firstStrategy{}
+	}
+	return newLoadBalancer(strategy, impl)
+}
+
+// NewLoadBalancerFor creates a LoadBalancer using a strategy registered
+// with RegisterStrategy, or a built-in one by name.
+func NewLoadBalancerFor(name string) (LoadBalancer, error) {
+	impl, err := NewStrategy(name)
+	if err != nil {
+		return nil, err
+	}
+	strategy := StrategyCustom
+	for s := StrategyRoundRobin; s < StrategyCustom; s++ {
+		if s.String() == name {
+			strategy = s
+		}
+	}
+	return newLoadBalancer(strategy, impl), nil
+}
+
+// newLoadBalancer creates a loadBalancer running impl.
+func newLoadBalancer(strategy LoadBalanceStrategy, impl Strategy) *loadBalancer {
 	return &loadBalancer{
 		strategy: strategy,
+		impl:     impl,
 		metrics:  make(map[string]*ServiceMetrics),
 		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
@@ -130,6 +158,12 @@ func NewLoadBalancer(strategy LoadBalanceStrategy) LoadBalancer {
 
 // Select chooses the best service instance based on the load balancing strategy.
 func (lb *loadBalancer) Select(services []*ServiceInfo) (*ServiceInfo, error) {
+	return lb.SelectKey(services, "")
+}
+
+// SelectKey chooses a service instance for a request with a routing key,
+// which hashing strategies map to the same instance every time.
+func (lb *loadBalancer) SelectKey(services []*ServiceInfo, key string) (*ServiceInfo, error) {
 	if len(services) == 0 {
 		return nil, errors.New("no services available")
 	}
@@ -143,26 +177,11 @@ func (lb *loadBalancer) Select(services []*ServiceInfo) (*ServiceInfo, error) {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 
-	switch lb.strategy {
-	case StrategyRoundRobin:
-		return lb.selectRoundRobin(healthyServices), nil
-
-	case StrategyRandom:
-		return lb.selectRandom(healthyServices), nil
-
-	case StrategyLeastConnections:
-		return lb.selectLeastConnections(healthyServices), nil
-
-	case StrategyWeightedRoundRobin:
-		return lb.selectWeightedRoundRobin(healthyServices), nil
-
-	case StrategyConsistentHash:
-		// TODO: Implement consistent hashing
-		return lb.selectRandom(healthyServices), nil
-
-	default:
-		return healthyServices[0], nil
+	selected := lb.impl.Select(healthyServices, Selection{Key: key, Metrics: lb.serviceMetrics, Rand: lb.rand})
+	if selected == nil {
+		return nil, errors.New("load balancing strategy selected no service")
 	}
+	return selected, nil
 }
 
 // UpdateMetrics updates the metrics for a service instance.
@@ -180,6 +199,14 @@ func (lb *loadBalancer) GetStrategy() LoadBalanceStrategy {
 	return lb.strategy
 }
 
+// serviceMetrics returns the metrics of an instance; callers hold mu.
+func (lb *loadBalancer) serviceMetrics(service *ServiceInfo) ServiceMetrics {
+	if metrics := lb.metrics[service.Handle.Name]; metrics != nil {
+		return *metrics
+	}
+	return ServiceMetrics{}
+}
+
 // filterHealthyServices returns only healthy services.
 func (lb *loadBalancer) filterHealthyServices(services []*ServiceInfo) []*ServiceInfo {
 	var healthy []*ServiceInfo
@@ -191,111 +218,11 @@ func (lb *loadBalancer) filterHealthyServices(services []*ServiceInfo) []*Servic
 	return healthy
 }
 
-// selectRoundRobin implements round robin selection.
-func (lb *loadBalancer) selectRoundRobin(services []*ServiceInfo) *ServiceInfo {
-	if len(services) == 0 {
-		return nil
-	}
-
-	service := services[lb.roundRobinIndex%len(services)]
-	lb.roundRobinIndex++
-	return service
-}
-
-// selectRandom implements random selection.
-func (lb *loadBalancer) selectRandom(services []*ServiceInfo) *ServiceInfo {
-	if len(services) == 0 {
-		return nil
-	}
-
-	index := lb.rand.Intn(len(services))
-	return services[index]
-}
-
-// selectLeastConnections implements least connections selection.
-func (lb *loadBalancer) selectLeastConnections(services []*ServiceInfo) *ServiceInfo {
-	if len(services) == 0 {
-		return nil
-	}
-
-	bestService := services[0]
-	bestConnections := int64(^uint64(0) >> 1) // Max int64
-
-	for _, service := range services {
-		metrics := lb.metrics[service.Handle.Name]
-		connections := int64(0)
-		if metrics != nil {
-			connections = metrics.ActiveConnections
-		}
-
-		if connections < bestConnections {
-			bestConnections = connections
-			bestService = service
-		}
-	}
-
-	return bestService
-}
-
-// selectWeightedRoundRobin implements weighted round robin selection.
-func (lb *loadBalancer) selectWeightedRoundRobin(services []*ServiceInfo) *ServiceInfo {
-	if len(services) == 0 {
-		return nil
-	}
-
-	// Build weighted services list if needed
-	if len(lb.weightedServices) != len(services) {
-		lb.buildWeightedServices(services)
-	}
-
-	if len(lb.weightedServices) == 0 {
-		return services[0]
-	}
-
-	service := lb.weightedServices[lb.weightedIndex%len(lb.weightedServices)]
-	lb.weightedIndex++
-	return service.Service
-}
-
-// buildWeightedServices builds the weighted services list.
-func (lb *loadBalancer) buildWeightedServices(services []*ServiceInfo) {
-	lb.weightedServices = nil
-
-	for _, service := range services {
-		weight := lb.getServiceWeight(service)
-		for i := 0; i < weight; i++ {
-			lb.weightedServices = append(lb.weightedServices, weightedService{
-				Service: service,
-				Weight:  weight,
-			})
-		}
-	}
-}
-
-// getServiceWeight calculates the weight of a service based on its metrics.
-func (lb *loadBalancer) getServiceWeight(service *ServiceInfo) int {
-	metrics := lb.metrics[service.Handle.Name]
-	if metrics == nil {
-		return 1 // Default weight
-	}
-
-	// Calculate weight based on success rate and response time
-	successRate := metrics.SuccessRate()
-	responseTimeFactor := 1.0
-	if metrics.AverageResponseTime > 0 {
-		// Lower response time = higher weight
-		responseTimeFactor = 1000.0 / float64(metrics.AverageResponseTime.Milliseconds())
-	}
-
-	weight := int(successRate * responseTimeFactor * 10)
-	if weight < 1 {
-		weight = 1
-	}
-	if weight > 100 {
-		weight = 100
-	}
+// firstStrategy picks the first instance, for unknown strategies.
+type firstStrategy struct{}
 
-	return weight
+func (firstStrategy) Select(instances []*ServiceInfo, sel Selection) *ServiceInfo {
+	return instances[0]
 }
 
 // ServiceDiscovery combines service registry and load balancing for complete service discovery.
@@ -320,6 +247,16 @@ type ServiceDiscovery interface {
 
 	// SetLoadBalanceStrategy sets the load balancing strategy
 	SetLoadBalanceStrategy(strategy LoadBalanceStrategy) error
+
+	// SetServiceStrategy overrides the strategy of one service with a
+	// registered strategy name; "" restores the default
+	SetServiceStrategy(name, strategy string) error
+
+	// DiscoverServiceByKey selects an instance for a request with a routing key
+	DiscoverServiceByKey(name, key string) (*ServiceInfo, error)
+
+	// Close stops the goroutines of the underlying registry
+	Close() error
 }
 
 // ServiceRegistrationInfo contains information for registering a service.
@@ -335,6 +272,10 @@ type ServiceRegistrationInfo struct {
 type serviceDiscovery struct {
 	registry     ServiceRegistry
 	loadBalancer LoadBalancer
+
+	// Per-service strategy overrides
+	overridesMu sync.RWMutex
+	overrides   map[string]LoadBalancer
 }
 
 // NewServiceDiscovery creates a new ServiceDiscovery instance.
@@ -372,6 +313,12 @@ func (sd *serviceDiscovery) UnregisterService(name string) error {
 
 // DiscoverService finds and selects the best service instance.
 func (sd *serviceDiscovery) DiscoverService(name string) (*ServiceInfo, error) {
+	return sd.DiscoverServiceByKey(name, "")
+}
+
+// DiscoverServiceByKey selects an instance for a request with a routing
+// key, using the strategy of the service.
+func (sd *serviceDiscovery) DiscoverServiceByKey(name, key string) (*ServiceInfo, error) {
 	// Find all instances of the service
 	services, err := sd.registry.Discover(ServiceQuery{Name: name})
 	if err != nil {
@@ -383,7 +330,39 @@ func (sd *serviceDiscovery) DiscoverService(name string) (*ServiceInfo, error) {
 	}
 
 	// Use load balancer to select the best instance
-	return sd.loadBalancer.Select(services)
+	return sd.balancerFor(name).SelectKey(services, key)
+}
+
+// balancerFor returns the load balancer of a service.
+func (sd *serviceDiscovery) balancerFor(name string) LoadBalancer {
+	sd.overridesMu.RLock()
+	defer sd.overridesMu.RUnlock()
+
+	if lb, ok := sd.overrides[name]; ok {
+		return lb
+	}
+	return sd.loadBalancer
+}
+
+// SetServiceStrategy overrides the strategy of one service with a
+// registered strategy name; "" restores the default strategy.
+func (sd *serviceDiscovery) SetServiceStrategy(name, strategy string) error {
+	sd.overridesMu.Lock()
+	defer sd.overridesMu.Unlock()
+
+	if strategy == "" {
+		delete(sd.overrides, name)
+		return nil
+	}
+	lb, err := NewLoadBalancerFor(strategy)
+	if err != nil {
+		return err
+	}
+	if sd.overrides == nil {
+		sd.overrides = make(map[string]LoadBalancer)
+	}
+	sd.overrides[name] = lb
+	return nil
 }
 
 // DiscoverServices finds all matching services.
@@ -398,9 +377,20 @@ func (sd *serviceDiscovery) UpdateServiceHealth(name string, status ServiceStatu
 
 // UpdateServiceMetrics updates the performance metrics of a service.
 func (sd *serviceDiscovery) UpdateServiceMetrics(name string, metrics ServiceMetrics) error {
+	sd.overridesMu.RLock()
+	defer sd.overridesMu.RUnlock()
+
+	for _, lb := range sd.overrides {
+		lb.UpdateMetrics(name, metrics)
+	}
 	return sd.loadBalancer.UpdateMetrics(name, metrics)
 }
 
+// Close stops the goroutines of the underlying registry.
+func (sd *serviceDiscovery) Close() error {
+	return sd.registry.Close()
+}
+
 // SetLoadBalanceStrategy sets the load balancing strategy.
 func (sd *serviceDiscovery) SetLoadBalanceStrategy(strategy LoadBalanceStrategy) error {
 	// Create new load balancer with the specified strategy
@@ -2,6 +2,9 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -236,6 +239,125 @@ func TestLoadBalancer(t *testing.T) {
 	}
 }
 
+// instances returns healthy service instances with the given names.
+func instances(names ...string) []*ServiceInfo {
+	services := make([]*ServiceInfo, len(names))
+	for i, name := range names {
+		services[i] = &ServiceInfo{Handle: &Handle{Name: name}, Status: ServiceStatusHealthy}
+	}
+	return services
+}
+
+func TestPowerOfTwoChoices(t *testing.T) {
+	lb := NewLoadBalancer(StrategyPowerOfTwoChoices)
+	services := instances("a", "b", "c")
+	lb.UpdateMetrics("a", ServiceMetrics{ActiveConnections: 100})
+	lb.UpdateMetrics("b", ServiceMetrics{ActiveConnections: 1})
+	lb.UpdateMetrics("c", ServiceMetrics{ActiveConnections: 50})
+
+	// The busiest instance loses every comparison, the idlest wins both
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		selected, err := lb.Select(services)
+		if err != nil {
+			t.Fatalf("Failed to select: %v", err)
+		}
+		counts[selected.Handle.Name]++
+	}
+	if counts["a"] != 0 || counts["b"] <= counts["c"] {
+		t.Errorf("Expected b preferred and a never chosen, got %v", counts)
+	}
+}
+
+func TestConsistentHashStrategy(t *testing.T) {
+	lb := NewLoadBalancer(StrategyConsistentHash)
+	services := instances("shard-1", "shard-2", "shard-3", "shard-4")
+
+	owners := make(map[string]string)
+	spread := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("player-%d", i)
+		selected, err := lb.SelectKey(services, key)
+		if err != nil {
+			t.Fatalf("Failed to select: %v", err)
+		}
+		again, _ := lb.SelectKey(services, key)
+		if again != selected {
+			t.Fatalf("Expected %s to stick to %s, got %s", key, selected.Handle.Name, again.Handle.Name)
+		}
+		owners[key] = selected.Handle.Name
+		spread[selected.Handle.Name]++
+	}
+	for name, n := range spread {
+		if n < 150 || n > 350 {
+			t.Errorf("Expected keys spread evenly, %s got %d of 1000", name, n)
+		}
+	}
+
+	// Removing an instance only moves the keys it owned
+	moved := 0
+	for key, owner := range owners {
+		selected, _ := lb.SelectKey(services[:3], key)
+		if selected.Handle.Name != owner {
+			moved++
+			if owner != "shard-4" {
+				t.Fatalf("Expected only keys of shard-4 to move, %s moved from %s", key, owner)
+			}
+		}
+	}
+	if moved != spread["shard-4"] {
+		t.Errorf("Expected the %d keys of shard-4 to move, %d did", spread["shard-4"], moved)
+	}
+}
+
+// pinnedStrategy always picks the last instance.
+type pinnedStrategy struct{}
+
+func (pinnedStrategy) Select(instances []*ServiceInfo, sel Selection) *ServiceInfo {
+	return instances[len(instances)-1]
+}
+
+func TestServiceStrategyOverride(t *testing.T) {
+	// The registry is global, so the strategy may be left from an earlier run
+	RegisterStrategy("pinned", func() Strategy { return pinnedStrategy{} })
+	if err := RegisterStrategy("pinned", func() Strategy { return pinnedStrategy{} }); err == nil {
+		t.Error("Expected a duplicate strategy to be rejected")
+	}
+	if err := RegisterStrategy(StrategyRandom.String(), func() Strategy { return pinnedStrategy{} }); err == nil {
+		t.Error("Expected a built-in strategy name to be rejected")
+	}
+
+	lb, err := NewLoadBalancerFor("pinned")
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	if lb.GetStrategy() != StrategyCustom {
+		t.Errorf("Expected StrategyCustom, got %s", lb.GetStrategy())
+	}
+	if selected, _ := lb.Select(instances("a", "b")); selected.Handle.Name != "b" {
+		t.Errorf("Expected the custom strategy to pick b, got %s", selected.Handle.Name)
+	}
+
+	sd := NewServiceDiscovery()
+	defer sd.Close()
+	if err := sd.SetServiceStrategy("matchmaker", "no-such-strategy"); err == nil {
+		t.Error("Expected an unknown strategy to be rejected")
+	}
+	if err := sd.SetServiceStrategy("matchmaker", "pinned"); err != nil {
+		t.Fatalf("Failed to override strategy: %v", err)
+	}
+	if got := sd.(*serviceDiscovery).balancerFor("matchmaker").GetStrategy(); got != StrategyCustom {
+		t.Errorf("Expected the override for matchmaker, got %s", got)
+	}
+	if got := sd.(*serviceDiscovery).balancerFor("chat").GetStrategy(); got != StrategyRoundRobin {
+		t.Errorf("Expected the default for chat, got %s", got)
+	}
+	sd.SetServiceStrategy("matchmaker", "")
+	if got := sd.(*serviceDiscovery).balancerFor("matchmaker").GetStrategy(); got != StrategyRoundRobin {
+		t.Errorf("Expected the override cleared, got %s", got)
+	}
+}
+
 func TestServiceDiscovery(t *testing.T) {
 	sd := NewServiceDiscovery()
 
@@ -390,3 +512,57 @@ func TestIntegratedServiceDiscovery(t *testing.T) {
 		t.Fatalf("Failed to shutdown system: %v", err)
 	}
 }
+
+// waitForGoroutines polls until at most n goroutines are running
+func waitForGoroutines(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Leaked goroutines: %d running, expected at most %d", runtime.NumGoroutine(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServiceRegistryCloseStopsGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	registry := NewServiceRegistry()
+	events, err := registry.Watch(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := registry.Watch(ctx); err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	if err := registry.Close(); err != nil {
+		t.Fatalf("Failed to close registry: %v", err)
+	}
+	if _, open := <-events; open {
+		t.Error("Expected Close to close the watch channel")
+	}
+	if _, err := registry.Watch(context.Background()); !errors.Is(err, ErrRegistryClosed) {
+		t.Errorf("Expected ErrRegistryClosed, got %v", err)
+	}
+	registry.Close()
+	cancel()
+
+	waitForGoroutines(t, before)
+}
+
+func TestActorSystemShutdownStopsDiscovery(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	system := NewActorSystem()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := system.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to shutdown system: %v", err)
+	}
+
+	waitForGoroutines(t, before)
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -124,8 +125,14 @@ type ServiceRegistry interface {
 
 	// Watch starts watching for service changes
 	Watch(ctx context.Context) (<-chan ServiceEvent, error)
+
+	// Close stops the health check routine and closes all watch channels
+	Close() error
 }
 
+// ErrRegistryClosed is returned by a ServiceRegistry after Close.
+var ErrRegistryClosed = errors.New("service registry closed")
+
 // ServiceEvent represents a change in service registry.
 type ServiceEvent struct {
 	// Type of the event
@@ -180,6 +187,11 @@ type localServiceRegistry struct {
 	watchers     map[uint64]chan ServiceEvent
 	watcherID    uint64
 	watcherMutex sync.RWMutex
+
+	// Stops the health check routine and watch cleanups on Close
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
 }
 
 // NewServiceRegistry creates a new local service registry.
@@ -187,9 +199,11 @@ func NewServiceRegistry() ServiceRegistry {
 	registry := &localServiceRegistry{
 		services: make(map[string]*ServiceInfo),
 		watchers: make(map[uint64]chan ServiceEvent),
+		done:     make(chan struct{}),
 	}
 
 	// Start health check routine
+	registry.wg.Add(1)
 	go registry.healthCheckRoutine()
 
 	return registry
@@ -205,6 +219,10 @@ func (r *localServiceRegistry) Register(info *ServiceInfo) error {
 		return fmt.Errorf("service name cannot be empty")
 	}
 
+	if r.closed() {
+		return ErrRegistryClosed
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -368,24 +386,63 @@ func (r *localServiceRegistry) Watch(ctx context.Context) (<-chan ServiceEvent,
 	r.watcherMutex.Lock()
 	defer r.watcherMutex.Unlock()
 
+	if r.closed() {
+		return nil, ErrRegistryClosed
+	}
+
 	r.watcherID++
 	watcherID := r.watcherID
 
 	eventChan := make(chan ServiceEvent, 100)
 	r.watchers[watcherID] = eventChan
 
-	// Start a goroutine to clean up when context is done
+	// Start a goroutine to clean up when context is done; Close closes
+	// the channel itself
+	r.wg.Add(1)
 	go func() {
-		<-ctx.Done()
+		defer r.wg.Done()
+		select {
+		case <-ctx.Done():
+		case <-r.done:
+			return
+		}
 		r.watcherMutex.Lock()
-		delete(r.watchers, watcherID)
-		close(eventChan)
+		if _, exists := r.watchers[watcherID]; exists {
+			delete(r.watchers, watcherID)
+			close(eventChan)
+		}
 		r.watcherMutex.Unlock()
 	}()
 
 	return eventChan, nil
 }
 
+// Close stops the health check routine and closes all watch channels. It
+// waits for the registry goroutines to exit and is safe to call twice.
+func (r *localServiceRegistry) Close() error {
+	r.closeOnce.Do(func() {
+		r.watcherMutex.Lock()
+		close(r.done)
+		for watcherID, eventChan := range r.watchers {
+			delete(r.watchers, watcherID)
+			close(eventChan)
+		}
+		r.watcherMutex.Unlock()
+	})
+	r.wg.Wait()
+	return nil
+}
+
+// closed reports whether Close was called.
+func (r *localServiceRegistry) closed() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // matchesQuery checks if a service matches the query criteria.
 func (r *localServiceRegistry) matchesQuery(service *ServiceInfo, query ServiceQuery) bool {
 	// Check name exact match
@@ -464,11 +521,18 @@ func (r *localServiceRegistry) notifyWatchers(event ServiceEvent) {
 
 // healthCheckRoutine periodically checks the health of registered services.
 func (r *localServiceRegistry) healthCheckRoutine() {
+	defer r.wg.Done()
+
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		r.performHealthChecks()
+	for {
+		select {
+		case <-ticker.C:
+			r.performHealthChecks()
+		case <-r.done:
+			return
+		}
 	}
 }
 
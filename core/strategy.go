@@ -0,0 +1,293 @@
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultVirtualNodes is the number of points each instance gets on the
+// ring of the consistent hash strategy.
+const DefaultVirtualNodes = 128
+
+// Selection is what a Strategy knows about a request when picking an
+// instance.
+type Selection struct {
+	// Key routes requests with the same key to the same instance for
+	// hashing strategies, e.g. a player or room ID; empty without one
+	Key string
+
+	// Metrics returns the last metrics reported for an instance
+	Metrics func(instance *ServiceInfo) ServiceMetrics
+
+	// Rand is the random source of the load balancer
+	Rand *rand.Rand
+}
+
+// Strategy picks one of the healthy instances of a service. A load
+// balancer calls Select under its lock, so a Strategy keeps state such as
+// a round robin position without locking of its own.
+type Strategy interface {
+	Select(instances []*ServiceInfo, sel Selection) *ServiceInfo
+}
+
+// StrategyFactory creates a Strategy for a new load balancer.
+type StrategyFactory func() Strategy
+
+var strategies = struct {
+	sync.RWMutex
+	factories map[string]StrategyFactory
+}{
+	factories: map[string]StrategyFactory{
+		StrategyRoundRobin.String():         func() Strategy { return &roundRobinStrategy{} },
+		StrategyRandom.String():             func() Strategy { return randomStrategy{} },
+		StrategyLeastConnections.String():   func() Strategy { return leastConnectionsStrategy{} },
+		StrategyWeightedRoundRobin.String(): func() Strategy { return &weightedRoundRobinStrategy{} },
+		StrategyConsistentHash.String():     func() Strategy { return NewConsistentHashStrategy(DefaultVirtualNodes) },
+		StrategyPowerOfTwoChoices.String():  func() Strategy { return powerOfTwoChoicesStrategy{} },
+	},
+}
+
+// RegisterStrategy makes a Strategy available by name to NewLoadBalancerFor
+// and per-service overrides. Built-in strategies are registered under the
+// names of their LoadBalanceStrategy.
+func RegisterStrategy(name string, factory StrategyFactory) error {
+	if name == "" || factory == nil {
+		return fmt.Errorf("strategy needs a name and a factory")
+	}
+
+	strategies.Lock()
+	defer strategies.Unlock()
+
+	if _, exists := strategies.factories[name]; exists {
+		return fmt.Errorf("strategy %s already registered", name)
+	}
+	strategies.factories[name] = factory
+	return nil
+}
+
+// NewStrategy creates a registered Strategy by name.
+func NewStrategy(name string) (Strategy, error) {
+	strategies.RLock()
+	factory, exists := strategies.factories[name]
+	strategies.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown load balancing strategy %q", name)
+	}
+	return factory(), nil
+}
+
+// roundRobinStrategy cycles through instances in order.
+type roundRobinStrategy struct {
+	next int
+}
+
+func (s *roundRobinStrategy) Select(instances []*ServiceInfo, sel Selection) *ServiceInfo {
+	instance := instances[s.next%len(instances)]
+	s.next++
+	return instance
+}
+
+// randomStrategy picks instances at random.
+type randomStrategy struct{}
+
+func (randomStrategy) Select(instances []*ServiceInfo, sel Selection) *ServiceInfo {
+	return instances[sel.Rand.Intn(len(instances))]
+}
+
+// leastConnectionsStrategy picks the instance with the fewest active
+// connections, the first one on ties.
+type leastConnectionsStrategy struct{}
+
+func (leastConnectionsStrategy) Select(instances []*ServiceInfo, sel Selection) *ServiceInfo {
+	best := instances[0]
+	bestConnections := sel.Metrics(best).ActiveConnections
+	for _, instance := range instances[1:] {
+		if connections := sel.Metrics(instance).ActiveConnections; connections < bestConnections {
+			best, bestConnections = instance, connections
+		}
+	}
+	return best
+}
+
+// powerOfTwoChoicesStrategy picks two instances at random and takes the one
+// with fewer active connections. It spreads load almost as well as least
+// connections without sending every request to the same instance between
+// two metrics updates.
+type powerOfTwoChoicesStrategy struct{}
+
+func (powerOfTwoChoicesStrategy) Select(instances []*ServiceInfo, sel Selection) *ServiceInfo {
+	if len(instances) == 1 {
+		return instances[0]
+	}
+
+	i := sel.Rand.Intn(len(instances))
+	j := sel.Rand.Intn(len(instances) - 1)
+	if j >= i {
+		j++
+	}
+	a, b := instances[i], instances[j]
+	if sel.Metrics(b).ActiveConnections < sel.Metrics(a).ActiveConnections {
+		return b
+	}
+	return a
+}
+
+// weightedRoundRobinStrategy cycles through instances, each repeated by a
+// weight derived from its success rate and response time.
+type weightedRoundRobinStrategy struct {
+	weighted []*ServiceInfo
+	next     int
+}
+
+func (s *weightedRoundRobinStrategy) Select(instances []*ServiceInfo, sel Selection) *ServiceInfo {
+	// Rebuild the weighted list if needed
+	if !sameInstances(s.weighted, instances) {
+		s.weighted = nil
+		for _, instance := range instances {
+			for i := serviceWeight(sel.Metrics(instance)); i > 0; i-- {
+				s.weighted = append(s.weighted, instance)
+			}
+		}
+	}
+
+	instance := s.weighted[s.next%len(s.weighted)]
+	s.next++
+	return instance
+}
+
+// sameInstances reports whether the weighted list holds exactly instances.
+func sameInstances(weighted, instances []*ServiceInfo) bool {
+	seen := make(map[*ServiceInfo]bool, len(instances))
+	for _, instance := range weighted {
+		seen[instance] = true
+	}
+	if len(seen) != len(instances) {
+		return false
+	}
+	for _, instance := range instances {
+		if !seen[instance] {
+			return false
+		}
+	}
+	return true
+}
+
+// serviceWeight calculates the weight of an instance from its metrics.
+func serviceWeight(metrics ServiceMetrics) int {
+	if metrics.LastUpdated.IsZero() {
+		return 1 // Default weight
+	}
+
+	// Calculate weight based on success rate and response time
+	successRate := metrics.SuccessRate()
+	responseTimeFactor := 1.0
+	if ms := metrics.AverageResponseTime.Milliseconds(); ms > 0 {
+		// Lower response time = higher weight
+		responseTimeFactor = 1000.0 / float64(ms)
+	}
+
+	weight := int(successRate * responseTimeFactor * 10)
+	if weight < 1 {
+		weight = 1
+	}
+	if weight > 100 {
+		weight = 100
+	}
+	return weight
+}
+
+// ConsistentHashStrategy maps keys onto a ring of instances, each placed at
+// several virtual points so keys spread evenly. Adding or removing an
+// instance only moves the keys of its own points. Requests without a key
+// go to a random instance.
+type ConsistentHashStrategy struct {
+	virtualNodes int
+
+	// Ring of the instance set it was built for
+	members string
+	points  []uint64
+	owners  map[uint64]string
+}
+
+// NewConsistentHashStrategy creates a consistent hash strategy placing
+// each instance at virtualNodes points of the ring.
+func NewConsistentHashStrategy(virtualNodes int) *ConsistentHashStrategy {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	return &ConsistentHashStrategy{virtualNodes: virtualNodes}
+}
+
+// Select returns the instance owning the first point of the ring at or
+// after the hash of the key.
+func (s *ConsistentHashStrategy) Select(instances []*ServiceInfo, sel Selection) *ServiceInfo {
+	if sel.Key == "" {
+		return instances[sel.Rand.Intn(len(instances))]
+	}
+	s.build(instances)
+
+	hash := hashKey(sel.Key)
+	i := sort.Search(len(s.points), func(i int) bool { return s.points[i] >= hash })
+	if i == len(s.points) {
+		i = 0
+	}
+	owner := s.owners[s.points[i]]
+	for _, instance := range instances {
+		if instance.Handle.Name == owner {
+			return instance
+		}
+	}
+	return nil
+}
+
+// build rebuilds the ring when the instance set changed.
+func (s *ConsistentHashStrategy) build(instances []*ServiceInfo) {
+	names := make([]string, len(instances))
+	for i, instance := range instances {
+		names[i] = instance.Handle.Name
+	}
+	sort.Strings(names)
+	members := strings.Join(names, "\x00")
+	if members == s.members && s.owners != nil {
+		return
+	}
+
+	s.members = members
+	s.points = make([]uint64, 0, len(instances)*s.virtualNodes)
+	s.owners = make(map[uint64]string, len(instances)*s.virtualNodes)
+	for _, name := range names {
+		for v := 0; v < s.virtualNodes; v++ {
+			point := hashKey(name + "#" + strconv.Itoa(v))
+			if _, taken := s.owners[point]; taken {
+				continue
+			}
+			s.owners[point] = name
+			s.points = append(s.points, point)
+		}
+	}
+	sort.Slice(s.points, func(i, j int) bool { return s.points[i] < s.points[j] })
+}
+
+// hashKey hashes a key onto the ring.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return mix64(h.Sum64())
+}
+
+// mix64 spreads the bits of an FNV hash, whose low bits are poorly mixed
+// for short keys differing in their last characters.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
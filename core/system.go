@@ -21,6 +21,30 @@ type system struct {
 
 	// Wait group for all actors
 	wg sync.WaitGroup
+
+	// System-wide memory accounting
+	memory *memoryAccountant
+
+	// Sampling profiler, nil unless started
+	profiler   *actorProfiler
+	profilerMu sync.Mutex
+	profiling  int32 // atomic, shared with actors
+
+	// Clock and message journal, shared with actors
+	journal journal
+
+	// Audit subscriptions, shared with actors
+	audit auditHub
+
+	// Priority inheritance of Calls, shared with actors
+	inheritance priorityInheritance
+
+	// Orders group broadcasts
+	broadcast broadcaster
+
+	// Manifest of declared services, nil unless opened
+	manifest *serviceManifest
+	declMu   sync.Mutex
 }
 
 // NewActorSystem creates a new ActorSystem instance.
@@ -32,14 +56,17 @@ func NewActorSystem() ActorSystem {
 func NewActorSystemWithNodeID(nodeID uint32) ActorSystem {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &system{
+	s := &system{
 		router:           NewAdvancedRouter(nodeID),
 		sessionManager:   NewSessionManager(),
 		serviceDiscovery: NewServiceDiscovery(),
 		nodeID:           nodeID,
 		ctx:              ctx,
 		cancel:           cancel,
+		memory:           &memoryAccountant{},
 	}
+	s.journal.setClock(systemClock{})
+	return s
 }
 
 // NewActor creates and registers a new Actor.
@@ -58,33 +85,90 @@ func (s *system) NewActor(handler MessageHandler, opts ActorOptions) (Actor, err
 	id := s.router.(*advancedRouter).router.NextID()
 
 	// Apply default options if needed
-	if opts.MailboxSize == 0 {
-		opts = DefaultActorOptions()
+	opts = opts.withDefaults()
+	if err := checkLockedThread(opts); err != nil {
+		return nil, err
 	}
 
 	// Create actor
-	actor := NewActor(id, handler, opts)
+	actor := s.newActor(id, handler, opts)
 
 	// Register with router
 	if err := s.router.Register(actor); err != nil {
 		return nil, fmt.Errorf("failed to register actor: %w", err)
 	}
 
+	if err := supervise(actor, opts); err != nil {
+		s.router.Unregister(id)
+		return nil, err
+	}
+
 	// Start the actor
+	if err := s.start(actor, opts); err != nil {
+		unsupervise(actor, opts)
+		s.router.Unregister(id)
+		return nil, err
+	}
+
+	return actor, nil
+}
+
+// start runs the message loop of a new Actor. One on a locked thread takes
+// its thread before returning, so a full pool fails the caller instead of
+// leaving a registered Actor whose mailbox never drains.
+func (s *system) start(actor Actor, opts ActorOptions) error {
+	if opts.LockedThreads != nil {
+		return actor.Start(s.ctx)
+	}
+
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
 		if err := actor.Start(s.ctx); err != nil {
-			// Log error but don't panic
-			// TODO: Add proper logging
+			ReportError("core", "spawn", SeverityError, err)
 		}
 	}()
+	return nil
+}
 
-	return actor, nil
+// newActor creates an Actor bound to the system's shared accounting.
+func (s *system) newActor(id ActorID, handler MessageHandler, opts ActorOptions) Actor {
+	a := NewActor(id, handler, opts)
+	if impl, ok := a.(*actor); ok {
+		impl.mem.system = s.memory
+		impl.profiling = &s.profiling
+		impl.journal = &s.journal
+		impl.audit = &s.audit
+		impl.inheritance = &s.inheritance
+	}
+	return a
+}
+
+// supervise hands a new Actor to the Supervisor from its options, if any.
+func supervise(actor Actor, opts ActorOptions) error {
+	if opts.Supervisor == nil {
+		return nil
+	}
+	if err := opts.Supervisor.Watch(actor); err != nil {
+		return fmt.Errorf("failed to supervise actor %d: %w", actor.ID(), err)
+	}
+	return nil
+}
+
+// unsupervise takes back an Actor handed over by supervise.
+func unsupervise(actor Actor, opts ActorOptions) {
+	if opts.Supervisor != nil {
+		opts.Supervisor.Unwatch(actor.ID())
+	}
 }
 
 // NewService creates and registers a named service.
 func (s *system) NewService(name string, handler MessageHandler, opts ActorOptions) (*Handle, error) {
+	return s.newService(name, handler, opts, false)
+}
+
+// newService creates a named service; system services may use reserved names.
+func (s *system) newService(name string, handler MessageHandler, opts ActorOptions, systemService bool) (*Handle, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -99,22 +183,32 @@ func (s *system) NewService(name string, handler MessageHandler, opts ActorOptio
 	id := s.router.(*advancedRouter).router.NextID()
 
 	// Apply default options if needed
-	if opts.MailboxSize == 0 {
-		opts = DefaultActorOptions()
+	opts = opts.withDefaults()
+	if err := checkLockedThread(opts); err != nil {
+		return nil, err
 	}
 	if opts.Name == "" {
 		opts.Name = name
 	}
 
 	// Create actor
-	actor := NewActor(id, handler, opts)
+	actor := s.newActor(id, handler, opts)
 
 	// Register as named service
-	handle, err := s.router.RegisterService(actor, name)
+	register := s.router.RegisterService
+	if systemService {
+		register = s.router.RegisterSystemService
+	}
+	handle, err := register(actor, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to register service: %w", err)
 	}
 
+	if err := supervise(actor, opts); err != nil {
+		s.router.UnregisterService(name)
+		return nil, err
+	}
+
 	// Register with service discovery
 	regInfo := ServiceRegistrationInfo{
 		Description:         fmt.Sprintf("Service %s", name),
@@ -131,14 +225,12 @@ func (s *system) NewService(name string, handler MessageHandler, opts ActorOptio
 	}
 
 	// Start the actor
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		if err := actor.Start(s.ctx); err != nil {
-			// Log error but don't panic
-			// TODO: Add proper logging
-		}
-	}()
+	if err := s.start(actor, opts); err != nil {
+		s.serviceDiscovery.UnregisterService(name)
+		unsupervise(actor, opts)
+		s.router.UnregisterService(name)
+		return nil, err
+	}
 
 	return handle, nil
 }
@@ -231,6 +323,16 @@ func (s *system) Shutdown(ctx context.Context) error {
 	// Signal shutdown
 	s.cancel()
 
+	s.declMu.Lock()
+	if s.manifest != nil {
+		s.manifest.close()
+	}
+	s.declMu.Unlock()
+
+	// Stop the discovery health checks, watchers and session cleanup
+	s.serviceDiscovery.Close()
+	s.sessionManager.Close()
+
 	// Stop all actors
 	actorIDs := s.router.List()
 	for _, id := range actorIDs {
@@ -295,3 +397,8 @@ func (s *system) UpdateServiceHealth(name string, status ServiceStatus) error {
 func (s *system) SetLoadBalanceStrategy(strategy LoadBalanceStrategy) error {
 	return s.serviceDiscovery.SetLoadBalanceStrategy(strategy)
 }
+
+// SetServiceStrategy overrides the load balancing strategy of one service.
+func (s *system) SetServiceStrategy(name, strategy string) error {
+	return s.serviceDiscovery.SetServiceStrategy(name, strategy)
+}
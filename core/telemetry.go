@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LabelNamespace is the label key exporters use for the namespace of
+// telemetry, so per-namespace dashboards work across modules
+const LabelNamespace = "namespace"
+
+// MessageSpan describes a message an Actor is about to handle
+type MessageSpan struct {
+	// Namespace is the namespace label of the Actor, see TelemetryConfig
+	Namespace string
+
+	// Actor is the name of the Actor, or "actor-<id>" if it has none. It
+	// and the IDs below are empty with TelemetryConfig.DropHighCardinality.
+	Actor   string
+	ActorID ActorID
+	Type    MessageType
+	Source  ActorID
+	Session uint32
+
+	// Queued is the time the message waited since it was created
+	Queued time.Duration
+}
+
+// Telemetry receives the metrics and tracing events of the framework. It
+// lets exporters, such as the OpenTelemetry adapter module in
+// contrib/otelsngo, observe the core without the core depending on them.
+// Implementations are called on hot paths and must not block.
+type Telemetry interface {
+	// StartMessage is called before a handler runs. The returned context
+	// is passed to the handler, and end is called with the handler error
+	// once it returns.
+	StartMessage(ctx context.Context, span MessageSpan) (context.Context, func(err error))
+
+	// ErrorReported is called with each error passed to ReportError
+	ErrorReported(report AsyncError)
+}
+
+// TelemetryConfig controls the labels of the telemetry emitted by the
+// core, network and cluster modules
+type TelemetryConfig struct {
+	// Namespace labels telemetry not tied to a named Actor, such as
+	// background errors and cluster events, and Actors outside any
+	// namespace; typically the shard or tenant the process serves
+	Namespace string
+
+	// NamespaceDepth keeps that many leading segments of an Actor name as
+	// its namespace label, e.g. 1 labels "game/zone1/agent42" with
+	// "game"; 0 keeps every segment but the last
+	NamespaceDepth int
+
+	// DropHighCardinality leaves out the per-Actor and per-message labels
+	// (Actor name and ID, source and session), so that metrics aggregate
+	// by namespace and message type only
+	DropHighCardinality bool
+}
+
+// NamespaceOf returns the namespace label of an Actor name
+func (c TelemetryConfig) NamespaceOf(name string) string {
+	segments := strings.Split(name, NamespaceSeparator)
+	if len(segments) < 2 {
+		return c.Namespace
+	}
+	segments = segments[:len(segments)-1]
+	if c.NamespaceDepth > 0 && c.NamespaceDepth < len(segments) {
+		segments = segments[:c.NamespaceDepth]
+	}
+	return strings.Join(segments, NamespaceSeparator)
+}
+
+var telemetryConfig atomic.Value // TelemetryConfig
+
+// SetTelemetryConfig sets the labels of all emitted telemetry
+func SetTelemetryConfig(config TelemetryConfig) {
+	telemetryConfig.Store(config)
+}
+
+// CurrentTelemetryConfig returns the labels of emitted telemetry
+func CurrentTelemetryConfig() TelemetryConfig {
+	config, _ := telemetryConfig.Load().(TelemetryConfig)
+	return config
+}
+
+// TelemetryNamespace returns the namespace label of telemetry not tied to
+// an Actor, for modules emitting their own events
+func TelemetryNamespace() string {
+	return CurrentTelemetryConfig().Namespace
+}
+
+// telemetryHolder lets atomic.Value store any Telemetry, or none
+type telemetryHolder struct {
+	telemetry Telemetry
+}
+
+var telemetry atomic.Value // telemetryHolder
+
+// SetTelemetry installs the telemetry of all Actor systems; nil removes it
+func SetTelemetry(t Telemetry) {
+	telemetry.Store(telemetryHolder{telemetry: t})
+}
+
+// currentTelemetry returns the installed telemetry, or nil
+func currentTelemetry() Telemetry {
+	holder, _ := telemetry.Load().(telemetryHolder)
+	return holder.telemetry
+}
+
+// startMessage reports a message about to be handled by a to the installed
+// telemetry, returning the context for the handler and the function ending
+// the span
+func (a *actor) startMessage(ctx context.Context, msg *Message) (context.Context, func(err error)) {
+	t := currentTelemetry()
+	if t == nil {
+		return ctx, func(error) {}
+	}
+
+	config := CurrentTelemetryConfig()
+	span := MessageSpan{
+		Namespace: config.NamespaceOf(a.name),
+		Actor:     a.profileName(),
+		ActorID:   a.id,
+		Type:      msg.Type,
+		Source:    msg.Source,
+		Session:   msg.Session,
+	}
+	if config.DropHighCardinality {
+		span.Actor, span.ActorID, span.Source, span.Session = "", 0, 0, 0
+	}
+	if !msg.Timestamp.IsZero() {
+		span.Queued = time.Since(msg.Timestamp)
+	}
+	return t.StartMessage(ctx, span)
+}
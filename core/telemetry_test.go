@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type telemetryKey struct{}
+
+// recordingTelemetry records the spans and errors it is told about
+type recordingTelemetry struct {
+	mu     sync.Mutex
+	spans  []MessageSpan
+	ended  []error
+	errors []AsyncError
+}
+
+func (rt *recordingTelemetry) StartMessage(ctx context.Context, span MessageSpan) (context.Context, func(err error)) {
+	rt.mu.Lock()
+	rt.spans = append(rt.spans, span)
+	rt.mu.Unlock()
+	return context.WithValue(ctx, telemetryKey{}, span.Actor), func(err error) {
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		rt.ended = append(rt.ended, err)
+	}
+}
+
+func (rt *recordingTelemetry) ErrorReported(report AsyncError) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.errors = append(rt.errors, report)
+}
+
+// tracedHandler fails messages saying "fail" and checks it runs in the
+// context of its span
+type tracedHandler struct{}
+
+func (h *tracedHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	if ctx.Value(telemetryKey{}) != "inventory" {
+		return errors.New("handler not in span context")
+	}
+	if string(msg.Data) == "fail" {
+		return errors.New("out of stock")
+	}
+	return nil
+}
+
+func TestTelemetry(t *testing.T) {
+	rt := &recordingTelemetry{}
+	SetTelemetry(rt)
+	defer SetTelemetry(nil)
+	SetErrorReporter(func(AsyncError) {})
+	defer SetErrorReporter(nil)
+
+	opts := DefaultActorOptions()
+	opts.Name = "inventory"
+	inventory := NewActor(3, &tracedHandler{}, opts)
+	if err := inventory.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start actor: %v", err)
+	}
+	defer inventory.Stop()
+
+	ctx := context.Background()
+	resp, err := inventory.Call(ctx, &Message{Type: MessageTypeRequest, Source: 7, Data: []byte("buy")})
+	if err != nil || resp.Type != MessageTypeResponse {
+		t.Fatalf("Expected call to succeed, got %v (%v)", resp, err)
+	}
+	inventory.Call(ctx, &Message{Type: MessageTypeRequest, Source: 7, Data: []byte("fail")})
+	ReportError("core", "test", SeverityWarning, errors.New("boom"))
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if len(rt.spans) != 2 || len(rt.ended) != 2 {
+		t.Fatalf("Expected 2 spans started and ended, got %d and %d", len(rt.spans), len(rt.ended))
+	}
+	span := rt.spans[0]
+	if span.Actor != "inventory" || span.ActorID != 3 || span.Source != 7 ||
+		span.Type != MessageTypeRequest || span.Session == 0 {
+		t.Errorf("Unexpected span %+v", span)
+	}
+	if rt.ended[0] != nil || rt.ended[1] == nil {
+		t.Errorf("Expected the second span to end with the handler error, got %v", rt.ended)
+	}
+	if len(rt.errors) != 1 || rt.errors[0].Source != "test" {
+		t.Errorf("Expected the reported error, got %+v", rt.errors)
+	}
+}
+
+func TestTelemetryNamespaceLabels(t *testing.T) {
+	config := TelemetryConfig{Namespace: "shard-3"}
+	for name, want := range map[string]string{
+		"inventory":          "shard-3",
+		"game/zone1/agent42": "game/zone1",
+		"game/lobby":         "game",
+	} {
+		if got := config.NamespaceOf(name); got != want {
+			t.Errorf("Expected %s labelled %q, got %q", name, want, got)
+		}
+	}
+	config.NamespaceDepth = 1
+	if got := config.NamespaceOf("game/zone1/agent42"); got != "game" {
+		t.Errorf("Expected depth 1 to label game, got %q", got)
+	}
+
+	rt := &recordingTelemetry{}
+	SetTelemetry(rt)
+	defer SetTelemetry(nil)
+	SetTelemetryConfig(TelemetryConfig{Namespace: "shard-3", DropHighCardinality: true})
+	defer SetTelemetryConfig(TelemetryConfig{})
+	SetErrorReporter(func(AsyncError) {})
+	defer SetErrorReporter(nil)
+
+	opts := DefaultActorOptions()
+	opts.Name = "game/zone1/agent42"
+	agent := NewActor(4, &tracedHandler{}, opts)
+	if err := agent.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start actor: %v", err)
+	}
+	defer agent.Stop()
+
+	agent.Call(context.Background(), &Message{Type: MessageTypeRequest, Source: 7})
+	ReportError("network", "accept", SeverityWarning, errors.New("boom"))
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if len(rt.spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(rt.spans))
+	}
+	if span := rt.spans[0]; span.Namespace != "game/zone1" || span.Actor != "" || span.ActorID != 0 || span.Source != 0 {
+		t.Errorf("Expected only the namespace label, got %+v", span)
+	}
+	if len(rt.errors) != 1 || rt.errors[0].Namespace != "shard-3" {
+		t.Errorf("Expected the error labelled with the process namespace, got %+v", rt.errors)
+	}
+}
@@ -0,0 +1,321 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CatchUpPolicy decides what a TickService does with ticks it could not
+// deliver on time, e.g. after a GC pause or a stalled host.
+type CatchUpPolicy uint8
+
+const (
+	// CatchUpSkip delivers only the latest due tick and skips the rest
+	CatchUpSkip CatchUpPolicy = iota
+
+	// CatchUpBurst delivers missed ticks back to back, up to MaxBurst,
+	// and skips any beyond that
+	CatchUpBurst
+)
+
+// String returns the string representation of CatchUpPolicy.
+func (p CatchUpPolicy) String() string {
+	switch p {
+	case CatchUpSkip:
+		return "skip"
+	case CatchUpBurst:
+		return "burst"
+	default:
+		return "unknown"
+	}
+}
+
+// TickOptions configures a TickService.
+type TickOptions struct {
+	// Rate is the number of ticks per second, e.g. 20 for a 20Hz game loop
+	Rate int
+
+	// CatchUp decides what happens to ticks that are late
+	CatchUp CatchUpPolicy
+
+	// MaxBurst caps the ticks delivered at once under CatchUpBurst
+	MaxBurst int
+}
+
+// Tick is the payload of a MessageTypeTick message.
+type Tick struct {
+	// Seq numbers ticks from 1; a gap means ticks were skipped
+	Seq uint64
+
+	// Scheduled is when the tick was due; handlers should advance their
+	// simulation by Seq rather than by the wall clock
+	Scheduled time.Time
+}
+
+// tickPayloadSize is the encoded size of a Tick.
+const tickPayloadSize = 16
+
+// ParseTick decodes the Tick carried by a MessageTypeTick message.
+func ParseTick(msg *Message) (Tick, error) {
+	if msg.Type != MessageTypeTick || len(msg.Data) != tickPayloadSize {
+		return Tick{}, fmt.Errorf("message %d is not a tick", msg.ID)
+	}
+	return Tick{
+		Seq:       binary.BigEndian.Uint64(msg.Data[0:8]),
+		Scheduled: time.Unix(0, int64(binary.BigEndian.Uint64(msg.Data[8:16]))),
+	}, nil
+}
+
+// TickStats reports the ticks delivered to one subscriber.
+type TickStats struct {
+	// Delivered ticks reached the subscriber's mailbox
+	Delivered uint64
+
+	// Handled ticks reached the subscriber's handler
+	Handled uint64
+
+	// Skipped ticks were dropped by the catch-up policy
+	Skipped uint64
+
+	// Dropped ticks did not fit the subscriber's mailbox
+	Dropped uint64
+
+	// LastLatency, AvgLatency and MaxLatency measure how late handled
+	// ticks started relative to their schedule, on the system clock
+	LastLatency time.Duration
+	AvgLatency  time.Duration
+	MaxLatency  time.Duration
+}
+
+// TickSubscription delivers the ticks of a TickService to one Actor.
+type TickSubscription struct {
+	subscriber ActorID
+
+	delivered    uint64 // atomic
+	handled      uint64 // atomic
+	skipped      uint64 // atomic
+	dropped      uint64 // atomic
+	lastLatency  int64  // atomic
+	totalLatency int64  // atomic
+	maxLatency   int64  // atomic
+}
+
+// Subscriber returns the ID of the ticked Actor.
+func (sub *TickSubscription) Subscriber() ActorID {
+	return sub.subscriber
+}
+
+// Stats returns the subscription counters.
+func (sub *TickSubscription) Stats() TickStats {
+	stats := TickStats{
+		Delivered:   atomic.LoadUint64(&sub.delivered),
+		Handled:     atomic.LoadUint64(&sub.handled),
+		Skipped:     atomic.LoadUint64(&sub.skipped),
+		Dropped:     atomic.LoadUint64(&sub.dropped),
+		LastLatency: time.Duration(atomic.LoadInt64(&sub.lastLatency)),
+		MaxLatency:  time.Duration(atomic.LoadInt64(&sub.maxLatency)),
+	}
+	if stats.Handled > 0 {
+		stats.AvgLatency = time.Duration(atomic.LoadInt64(&sub.totalLatency) / int64(stats.Handled))
+	}
+	return stats
+}
+
+// observe records the latency of a tick whose handler starts at now.
+func (sub *TickSubscription) observe(msg *Message, now time.Time) {
+	tick, err := ParseTick(msg)
+	if err != nil {
+		return
+	}
+	latency := now.Sub(tick.Scheduled)
+	atomic.AddUint64(&sub.handled, 1)
+	atomic.StoreInt64(&sub.lastLatency, int64(latency))
+	atomic.AddInt64(&sub.totalLatency, int64(latency))
+	for {
+		max := atomic.LoadInt64(&sub.maxLatency)
+		if int64(latency) <= max || atomic.CompareAndSwapInt64(&sub.maxLatency, max, int64(latency)) {
+			return
+		}
+	}
+}
+
+// TickService delivers fixed-rate MessageTypeTick messages to subscribed
+// Actors, so game loops tick inside their Actor's message loop instead of
+// running a time.Ticker of their own. Ticks are scheduled from the start
+// time rather than from the previous tick, so they do not drift under
+// load; ticks that fall behind are handled by the catch-up policy.
+type TickService struct {
+	opts     TickOptions
+	interval time.Duration
+	lookup   func(ActorID) (Actor, bool)
+	clock    func() time.Time
+
+	mu   sync.Mutex
+	subs map[ActorID]*TickSubscription
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewTickService starts a TickService that delivers ticks to the system's
+// Actors until it is stopped or the system shuts down.
+func (s *system) NewTickService(opts TickOptions) (*TickService, error) {
+	if opts.Rate <= 0 || opts.Rate > int(time.Second/time.Millisecond) {
+		return nil, fmt.Errorf("tick rate %d out of range", opts.Rate)
+	}
+	if opts.CatchUp == CatchUpBurst && opts.MaxBurst <= 0 {
+		opts.MaxBurst = 1
+	}
+
+	ts := &TickService{
+		opts:     opts,
+		interval: time.Second / time.Duration(opts.Rate),
+		lookup:   s.router.Lookup,
+		clock:    s.journal.now,
+		subs:     make(map[ActorID]*TickSubscription),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go ts.run(ts.now(), s.ctx.Done())
+	return ts, nil
+}
+
+// now returns the time of the system clock, which schedules the ticks.
+func (ts *TickService) now() time.Time {
+	if ts.clock == nil {
+		return time.Now()
+	}
+	return ts.clock()
+}
+
+// Interval returns the time between ticks.
+func (ts *TickService) Interval() time.Duration {
+	return ts.interval
+}
+
+// Subscribe starts delivering ticks to an Actor from the next tick on.
+func (ts *TickService) Subscribe(id ActorID) (*TickSubscription, error) {
+	if _, exists := ts.lookup(id); !exists {
+		return nil, fmt.Errorf("actor %d not found", id)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if _, exists := ts.subs[id]; exists {
+		return nil, fmt.Errorf("actor %d is already subscribed", id)
+	}
+	sub := &TickSubscription{subscriber: id}
+	ts.subs[id] = sub
+	return sub, nil
+}
+
+// Unsubscribe stops delivering ticks to a subscription's Actor.
+func (ts *TickService) Unsubscribe(sub *TickSubscription) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.subs[sub.subscriber] == sub {
+		delete(ts.subs, sub.subscriber)
+	}
+}
+
+// Stop stops the service and waits for the tick loop to exit.
+func (ts *TickService) Stop() {
+	ts.once.Do(func() { close(ts.stop) })
+	<-ts.done
+}
+
+// run fires ticks at start + n*interval until stopped.
+func (ts *TickService) run(start time.Time, shutdown <-chan struct{}) {
+	defer close(ts.done)
+
+	timer := time.NewTimer(ts.interval)
+	defer timer.Stop()
+
+	var last uint64
+	for {
+		select {
+		case <-timer.C:
+		case <-ts.stop:
+			return
+		case <-shutdown:
+			return
+		}
+
+		due := uint64(ts.now().Sub(start) / ts.interval)
+		if due > last {
+			last = ts.fire(start, last, due)
+		}
+
+		// A clock that does not advance, e.g. a FakeClock, is polled
+		wait := start.Add(time.Duration(last+1) * ts.interval).Sub(ts.now())
+		if wait <= 0 || wait > ts.interval {
+			wait = ts.interval
+		}
+		timer.Reset(wait)
+	}
+}
+
+// fire delivers the ticks after last up to due according to the catch-up
+// policy and returns the last tick handled.
+func (ts *TickService) fire(start time.Time, last, due uint64) uint64 {
+	first := due
+	if ts.opts.CatchUp == CatchUpBurst {
+		first = last + 1
+		if due-last > uint64(ts.opts.MaxBurst) {
+			first = due - uint64(ts.opts.MaxBurst) + 1
+		}
+	}
+	skipped := first - last - 1
+
+	ts.mu.Lock()
+	subs := make([]*TickSubscription, 0, len(ts.subs))
+	for _, sub := range ts.subs {
+		subs = append(subs, sub)
+	}
+	ts.mu.Unlock()
+
+	for _, sub := range subs {
+		atomic.AddUint64(&sub.skipped, skipped)
+	}
+
+	for seq := first; seq <= due; seq++ {
+		scheduled := start.Add(time.Duration(seq) * ts.interval)
+		for _, sub := range subs {
+			ts.deliver(sub, seq, scheduled)
+		}
+	}
+	return due
+}
+
+// deliver sends one tick to a subscriber without waiting on its mailbox.
+func (ts *TickService) deliver(sub *TickSubscription, seq uint64, scheduled time.Time) {
+	target, exists := ts.lookup(sub.subscriber)
+	if !exists {
+		atomic.AddUint64(&sub.dropped, 1)
+		return
+	}
+
+	data := make([]byte, tickPayloadSize)
+	binary.BigEndian.PutUint64(data[0:8], seq)
+	binary.BigEndian.PutUint64(data[8:16], uint64(scheduled.UnixNano()))
+
+	msg := &Message{
+		Type:      MessageTypeTick,
+		Target:    sub.subscriber,
+		Data:      data,
+		Timestamp: ts.now(),
+		tick:      sub,
+	}
+	if err := target.Send(msg); err != nil {
+		atomic.AddUint64(&sub.dropped, 1)
+		return
+	}
+	atomic.AddUint64(&sub.delivered, 1)
+}
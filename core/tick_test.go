@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTickService(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+
+	if _, err := sys.NewTickService(TickOptions{Rate: 0}); err == nil {
+		t.Error("Expected zero tick rate to be rejected")
+	}
+
+	recorder := &auditorHandler{}
+	handle, err := sys.NewService("loop", recorder, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	ticks, err := sys.NewTickService(TickOptions{Rate: 200})
+	if err != nil {
+		t.Fatalf("Failed to create tick service: %v", err)
+	}
+	defer ticks.Stop()
+
+	sub, err := ticks.Subscribe(handle.ActorID)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if _, err := ticks.Subscribe(handle.ActorID); err == nil {
+		t.Error("Expected duplicate subscription to be rejected")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for recorder.count() < 5 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	ticks.Unsubscribe(sub)
+
+	recorder.mu.Lock()
+	var prev uint64
+	for _, msg := range recorder.copies {
+		tick, err := ParseTick(msg)
+		if err != nil {
+			t.Fatalf("Failed to parse tick: %v", err)
+		}
+		if tick.Seq <= prev {
+			t.Errorf("Tick %d delivered after %d", tick.Seq, prev)
+		}
+		prev = tick.Seq
+	}
+	recorder.mu.Unlock()
+
+	stats := sub.Stats()
+	if stats.Delivered < 5 || stats.MaxLatency < stats.AvgLatency {
+		t.Errorf("Unexpected tick stats: %+v", stats)
+	}
+}
+
+func TestTickCatchUp(t *testing.T) {
+	start := time.Now()
+	sub := &TickSubscription{subscriber: 1}
+	sent := make(map[uint64]bool)
+	lookup := func(id ActorID) (Actor, bool) {
+		return &tickSink{sent: sent}, true
+	}
+
+	skip := &TickService{opts: TickOptions{Rate: 10}, interval: 100 * time.Millisecond,
+		lookup: lookup, subs: map[ActorID]*TickSubscription{1: sub}}
+	if last := skip.fire(start, 2, 7); last != 7 || len(sent) != 1 || !sent[7] {
+		t.Errorf("Skip policy delivered %v", sent)
+	}
+	if got := sub.Stats().Skipped; got != 4 {
+		t.Errorf("Expected 4 skipped ticks, got %d", got)
+	}
+
+	for seq := range sent {
+		delete(sent, seq)
+	}
+	burst := &TickService{opts: TickOptions{Rate: 10, CatchUp: CatchUpBurst, MaxBurst: 3}, interval: 100 * time.Millisecond,
+		lookup: lookup, subs: map[ActorID]*TickSubscription{1: sub}}
+	burst.fire(start, 7, 13)
+	if len(sent) != 3 || !sent[11] || !sent[12] || !sent[13] {
+		t.Errorf("Burst policy delivered %v", sent)
+	}
+	if got := sub.Stats().Skipped; got != 7 {
+		t.Errorf("Expected 7 skipped ticks in total, got %d", got)
+	}
+}
+
+// tickSink is an Actor recording the sequence numbers of ticks sent to it.
+type tickSink struct {
+	Actor
+	sent map[uint64]bool
+}
+
+func (s *tickSink) Send(msg *Message) error {
+	tick, err := ParseTick(msg)
+	if err != nil {
+		return err
+	}
+	s.sent[tick.Seq] = true
+	return nil
+}
+
+// TestTickLatencyOnSystemClock tests that tick latency is measured when
+// the handler starts, on the clock of the system
+func TestTickLatencyOnSystemClock(t *testing.T) {
+	sys := NewActorSystem()
+	defer sys.Shutdown(context.Background())
+	clock := NewFakeClock(time.Unix(1000, 0))
+	sys.SetClock(clock)
+
+	recorder := &auditorHandler{}
+	handle, err := sys.NewService("loop", recorder, DefaultActorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	ticks, err := sys.NewTickService(TickOptions{Rate: 100})
+	if err != nil {
+		t.Fatalf("Failed to create tick service: %v", err)
+	}
+	defer ticks.Stop()
+	sub, err := ticks.Subscribe(handle.ActorID)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// The first tick is due at 10ms and handled 3ms late on the fake clock
+	clock.Advance(13 * time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for sub.Stats().Handled == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stats := sub.Stats()
+	if stats.Delivered != 1 || stats.Handled != 1 {
+		t.Fatalf("Expected one tick handled on the fake clock, got %+v", stats)
+	}
+	if stats.LastLatency != 3*time.Millisecond {
+		t.Errorf("Expected 3ms latency on the fake clock, got %v", stats.LastLatency)
+	}
+}
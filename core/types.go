@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"time"
 )
 
@@ -32,6 +33,26 @@ type Message struct {
 
 	// Timestamp when the message was created
 	Timestamp time.Time
+
+	// Priority lets a message overtake the mailbox of an Actor created
+	// with a priority queue; 0 is normal priority
+	Priority uint8
+
+	// IdempotencyKey identifies a logical request across retries, so the
+	// receiver can recognize a re-execution (see IdempotencyCache)
+	IdempotencyKey string
+
+	// Deadline is when the caller stops waiting for the reply; zero means
+	// none. Call sets it from its context, and the handler context ends at
+	// it, so storage work done for an abandoned call is cut short.
+	Deadline time.Time
+
+	// run is work queued with RunOnActor, executed instead of the handler
+	run func(ctx context.Context)
+
+	// tick is the subscription a tick's latency is recorded for once its
+	// handler starts
+	tick *TickSubscription
 }
 
 // ActorState represents the current state of an Actor.
@@ -86,8 +107,21 @@ const (
 
 	// MessageTypeMulticast for multicast messages
 	MessageTypeMulticast
+
+	// MessageTypePoisonPill stops an Actor once every earlier message is processed
+	MessageTypePoisonPill
+
+	// MessageTypeTick for fixed-rate ticks delivered by a TickService
+	MessageTypeTick
 )
 
+// PoisonPill returns a message that stops the receiving Actor after all
+// messages queued before it. Unlike Stop it can be sent from the Actor's
+// own handler.
+func PoisonPill() *Message {
+	return &Message{Type: MessageTypePoisonPill, Timestamp: time.Now()}
+}
+
 // String returns the string representation of MessageType.
 func (t MessageType) String() string {
 	switch t {
@@ -103,6 +137,10 @@ func (t MessageType) String() string {
 		return "error"
 	case MessageTypeMulticast:
 		return "multicast"
+	case MessageTypePoisonPill:
+		return "poison_pill"
+	case MessageTypeTick:
+		return "tick"
 	default:
 		return "unknown"
 	}
@@ -118,9 +156,52 @@ type ActorOptions struct {
 
 	// Timeout for message processing
 	ProcessTimeout time.Duration
+
+	// MemoryBudget caps the bytes held by the mailbox and attachments (0 means unlimited)
+	MemoryBudget int64
+
+	// MemoryPolicy decides what happens to messages over budget
+	MemoryPolicy MemoryPolicy
+
+	// Spiller receives over-budget messages when MemoryPolicy is MemoryPolicySpill
+	Spiller Spiller
+
+	// OverflowPolicy decides what happens to messages sent to a full mailbox
+	OverflowPolicy OverflowPolicy
+
+	// Supervisor watches the Actor once it is created (nil means unsupervised)
+	Supervisor Supervisor
+
+	// RateLimit caps accepted messages per second (0 means unlimited)
+	RateLimit float64
+
+	// RateBurst is the number of messages accepted at once above RateLimit
+	RateBurst int
+
+	// Concurrency decides how many messages are handled at once
+	Concurrency ConcurrencyMode
+
+	// MaxConcurrency bounds the messages handled at once in ConcurrencyParallel
+	MaxConcurrency int
+
+	// PriorityQueue lets messages with a non-zero Priority overtake the mailbox
+	PriorityQueue bool
+
+	// YieldBudget is how long a handler may run before CheckBudget yields
+	// (0 means DefaultYieldBudget)
+	YieldBudget time.Duration
+
+	// YieldToUrgent handles queued urgent messages whenever the handler
+	// yields; needs PriorityQueue and serial handling
+	YieldToUrgent bool
+
+	// LockedThreads runs the message loop on a thread of a LockedThreadPool
+	// (nil means an ordinary goroutine)
+	LockedThreads *LockedThreadPool
 }
 
-// DefaultActorOptions returns sensible default options.
+// DefaultActorOptions returns sensible default options. Use NewActorOptions
+// to adjust them with functional options.
 func DefaultActorOptions() ActorOptions {
 	return ActorOptions{
 		MailboxSize:    1000,
@@ -146,9 +227,34 @@ type ActorStats struct {
 	// Messages currently in mailbox
 	MailboxSize int
 
+	// Approximate bytes of payload currently in mailbox
+	MailboxBytes int64
+
 	// Time when Actor was created
 	CreatedAt time.Time
 
 	// Last message processing time
 	LastMessageAt time.Time
+
+	// Cooperative yields of long-running handlers and the time spent in them
+	Yields      uint64
+	YieldedTime time.Duration
+
+	// HandlerProgressAt is when the running handler started or last
+	// yielded; zero while idle and for parallel Actors
+	HandlerProgressAt time.Time
+
+	// DeadlinesExceeded counts messages that ran out of time, by the stage
+	// they were in (see RunStage)
+	DeadlinesExceeded map[string]uint64
+
+	// PriorityInherited counts Calls into this Actor whose priority was
+	// raised to that of the caller's message (see SetPriorityInheritance)
+	PriorityInherited uint64
+}
+
+// Stuck reports whether a handler has made no progress for longer than
+// threshold: it neither returned nor yielded.
+func (s ActorStats) Stuck(threshold time.Duration) bool {
+	return !s.HandlerProgressAt.IsZero() && time.Since(s.HandlerProgressAt) > threshold
 }
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultYieldBudget is how long a handler runs between yields by default.
+const DefaultYieldBudget = 10 * time.Millisecond
+
+// handlerRun is the message being handled, carried in the handler context.
+type handlerRun struct {
+	actor *actor
+
+	// Start of the current slice: when the handler started or last yielded
+	slice time.Time
+
+	// Set once an exceeded deadline was counted for this message
+	deadlineCounted bool
+
+	// Priority of the message, inherited by the Calls the handler makes
+	priority uint8
+}
+
+// Yield lets a long-running handler pause between chunks of work, e.g.
+// between pathfinding iterations. Other goroutines get to run, urgent
+// messages are handled first when the Actor was created with
+// YieldToUrgent, and the handler is marked as making progress so it is not
+// reported as stuck. Yield returns the context error once the handler
+// should give up, because it ran out of time or the Actor is stopping.
+//
+// Yield must be called from the handler itself, with its context.
+func Yield(ctx context.Context) error {
+	run, ok := ctx.Value(actorContextKey{}).(*handlerRun)
+	if !ok {
+		runtime.Gosched()
+		return ctx.Err()
+	}
+	return run.yield(ctx)
+}
+
+// CheckBudget yields if the handler has used up its YieldBudget since it
+// started or last yielded, and is otherwise cheap enough to call on every
+// iteration of a long loop. Like Yield it returns the context error once
+// the handler should give up.
+func CheckBudget(ctx context.Context) error {
+	run, ok := ctx.Value(actorContextKey{}).(*handlerRun)
+	if !ok {
+		return ctx.Err()
+	}
+
+	budget := run.actor.opts.YieldBudget
+	if budget <= 0 {
+		budget = DefaultYieldBudget
+	}
+	if time.Since(run.slice) < budget {
+		return ctx.Err()
+	}
+	return run.yield(ctx)
+}
+
+// yield runs the urgent messages, if allowed, then starts a new slice.
+func (r *handlerRun) yield(ctx context.Context) error {
+	a := r.actor
+	start := time.Now()
+
+	if a.opts.YieldToUrgent && a.urgent != nil && a.slots == nil {
+		a.handleUrgent()
+	}
+	runtime.Gosched()
+
+	now := time.Now()
+	atomic.AddUint64(&a.yields, 1)
+	atomic.AddInt64(&a.yieldedNanos, int64(now.Sub(start)))
+	if a.slots == nil {
+		atomic.StoreInt64(&a.progressAt, now.UnixNano())
+	}
+	r.slice = now
+	return ctx.Err()
+}
+
+// handleUrgent handles the urgent messages queued when a handler yields,
+// nested inside it. A poison pill cannot stop the Actor from inside a
+// handler: it is taken off the lane and kept for the message loop, which
+// stops once the yielding handler returns.
+func (a *actor) handleUrgent() {
+	for n := len(a.urgent); n > 0 && a.poison == nil; n-- {
+		var msg *Message
+		select {
+		case msg = <-a.urgent:
+		default:
+			return
+		}
+
+		a.dequeued(msg)
+		if msg.Type == MessageTypePoisonPill {
+			a.poison = msg
+			return
+		}
+		a.dispatch(msg)
+	}
+}
+
+// progress marks the handler as started and returns the function
+// restoring the mark of the handler it nests in, if any. Only serial
+// Actors are tracked, parallel ones have no single running handler.
+func (a *actor) progress(now time.Time) func() {
+	if a.slots != nil {
+		return func() {}
+	}
+	prev := atomic.SwapInt64(&a.progressAt, now.UnixNano())
+	return func() {
+		if prev != 0 {
+			prev = time.Now().UnixNano()
+		}
+		atomic.StoreInt64(&a.progressAt, prev)
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// chunkedHandler computes in chunks, yielding between them.
+type chunkedHandler struct {
+	mu       sync.Mutex
+	order    []string
+	chunks   int
+	started  chan struct{}
+	progress chan time.Time
+	stats    func() ActorStats
+}
+
+func (h *chunkedHandler) HandleMessage(ctx context.Context, msg *Message) error {
+	h.mu.Lock()
+	h.order = append(h.order, string(msg.Data))
+	h.mu.Unlock()
+
+	if string(msg.Data) != "batch" {
+		return nil
+	}
+
+	close(h.started)
+	for i := 0; i < h.chunks; i++ {
+		time.Sleep(2 * time.Millisecond)
+		if err := CheckBudget(ctx); err != nil {
+			return err
+		}
+		if h.progress != nil {
+			h.progress <- h.stats().HandlerProgressAt
+		}
+	}
+
+	h.mu.Lock()
+	h.order = append(h.order, "batch done")
+	h.mu.Unlock()
+	return nil
+}
+
+func TestYieldHandlesUrgentMessages(t *testing.T) {
+	handler := &chunkedHandler{chunks: 20, started: make(chan struct{})}
+	a := NewActor(1, handler, NewActorOptions(
+		WithPriorityQueue(true),
+		WithYieldBudget(time.Millisecond, true),
+	))
+	a.Start(context.Background())
+	defer a.Stop()
+
+	a.Send(&Message{Type: MessageTypeRequest, Data: []byte("batch")})
+	<-handler.started
+	a.Send(&Message{Type: MessageTypeRequest, Data: []byte("normal")})
+	a.Send(&Message{Type: MessageTypeRequest, Data: []byte("urgent"), Priority: 1})
+
+	resp, err := a.Call(context.Background(), &Message{Type: MessageTypeRequest, Data: []byte("sync")})
+	if err != nil || resp == nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	want := []string{"batch", "urgent", "batch done", "normal", "sync"}
+	if len(handler.order) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, handler.order)
+	}
+	for i := range want {
+		if handler.order[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, handler.order)
+		}
+	}
+
+	stats := a.Stats()
+	if stats.Yields == 0 || stats.YieldedTime <= 0 {
+		t.Errorf("Expected yields to be counted: %+v", stats)
+	}
+	if !stats.HandlerProgressAt.IsZero() {
+		t.Errorf("Expected no progress mark while idle, got %v", stats.HandlerProgressAt)
+	}
+}
+
+func TestYieldKeepsUrgentPoisonPill(t *testing.T) {
+	handler := &chunkedHandler{chunks: 20, started: make(chan struct{})}
+	a := NewActor(1, handler, NewActorOptions(
+		WithPriorityQueue(true),
+		WithYieldBudget(time.Millisecond, true),
+	))
+	a.Start(context.Background())
+	defer a.Stop()
+
+	a.Send(&Message{Type: MessageTypeRequest, Data: []byte("batch")})
+	<-handler.started
+	a.Send(&Message{Type: MessageTypeRequest, Data: []byte("urgent"), Priority: 1})
+	pill := PoisonPill()
+	pill.Priority = 1
+	a.Send(pill)
+	a.Send(&Message{Type: MessageTypeRequest, Data: []byte("normal")})
+
+	// The pill stops the Actor once the yielding handler returns
+	deadline := time.Now().Add(2 * time.Second)
+	for a.Stats().State != ActorStateStopped && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if state := a.Stats().State; state != ActorStateStopped {
+		t.Fatalf("Expected the urgent poison pill to stop the Actor, got %s", state)
+	}
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	want := []string{"batch", "urgent", "batch done"}
+	if len(handler.order) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, handler.order)
+	}
+	for i := range want {
+		if handler.order[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, handler.order)
+		}
+	}
+}
+
+func TestYieldMarksProgress(t *testing.T) {
+	handler := &chunkedHandler{chunks: 3, started: make(chan struct{}), progress: make(chan time.Time)}
+	a := NewActor(1, handler, NewActorOptions(WithYieldBudget(time.Millisecond, false)))
+	handler.stats = a.Stats
+	a.Start(context.Background())
+	defer a.Stop()
+
+	start := time.Now()
+	a.Send(&Message{Type: MessageTypeRequest, Data: []byte("batch")})
+
+	var last time.Time
+	for i := 0; i < handler.chunks; i++ {
+		progress := <-handler.progress
+		if !progress.After(last) || progress.Before(start) {
+			t.Fatalf("Expected progress to advance, got %v after %v", progress, last)
+		}
+		last = progress
+	}
+	if a.Stats().Stuck(time.Hour) {
+		t.Error("Expected handler making progress not to be stuck")
+	}
+}
+
+func TestYieldOutsideActor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := Yield(ctx); err != nil {
+		t.Errorf("Expected nil error, got %v", err)
+	}
+	cancel()
+	if err := CheckBudget(ctx); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
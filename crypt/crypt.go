@@ -7,9 +7,11 @@ import (
 	"crypto/rand"
 	"crypto/sha1"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"math/bits"
 )
 
 // DH parameters - using a simpler approach for compatibility
@@ -110,7 +112,8 @@ func HexDecode(s string) ([]byte, error) {
 	return hex.DecodeString(s)
 }
 
-// HMAC64 calculates HMAC-SHA1 and returns first 8 bytes
+// HMAC64 calculates HMAC-SHA1 and returns first 8 bytes. It is not
+// skynet's crypt.hmac64, see SkynetHMAC64
 func HMAC64(challenge, secret []byte) []byte {
 	h := hmac.New(sha1.New, secret)
 	h.Write(challenge)
@@ -123,13 +126,90 @@ func HMACHash(secret []byte, text string) []byte {
 	return HMAC64([]byte(text), secret)
 }
 
-// HashKey creates a hash key from string (MD5)
+// HashKey creates a hash key from string (MD5). It is not skynet's
+// crypt.hashkey, see SkynetHashKey
 func HashKey(text string) []byte {
 	h := md5.New()
 	h.Write([]byte(text))
 	return h.Sum(nil)[:8] // Return first 8 bytes for compatibility
 }
 
+// skynetK and skynetR are the MD5 round constants and shift amounts
+var (
+	skynetK = [64]uint32{
+		0xd76aa478, 0xe8c7b756, 0x242070db, 0xc1bdceee, 0xf57c0faf, 0x4787c62a, 0xa8304613, 0xfd469501,
+		0x698098d8, 0x8b44f7af, 0xffff5bb1, 0x895cd7be, 0x6b901122, 0xfd987193, 0xa679438e, 0x49b40821,
+		0xf61e2562, 0xc040b340, 0x265e5a51, 0xe9b6c7aa, 0xd62f105d, 0x02441453, 0xd8a1e681, 0xe7d3fbc8,
+		0x21e1cde6, 0xc33707d6, 0xf4d50d87, 0x455a14ed, 0xa9e3e905, 0xfcefa3f8, 0x676f02d9, 0x8d2a4c8a,
+		0xfffa3942, 0x8771f681, 0x6d9d6122, 0xfde5380c, 0xa4beea44, 0x4bdecfa9, 0xf6bb4b60, 0xbebfbc70,
+		0x289b7ec6, 0xeaa127fa, 0xd4ef3085, 0x04881d05, 0xd9d4d039, 0xe6db99e5, 0x1fa27cf8, 0xc4ac5665,
+		0xf4292244, 0x432aff97, 0xab9423a7, 0xfc93a039, 0x655b59c3, 0x8f0ccc92, 0xffeff47d, 0x85845dd1,
+		0x6fa87e4f, 0xfe2ce6e0, 0xa3014314, 0x4e0811a1, 0xf7537e82, 0xbd3af235, 0x2ad7d2bb, 0xeb86d391,
+	}
+	skynetR = [64]uint{
+		7, 12, 17, 22, 7, 12, 17, 22, 7, 12, 17, 22, 7, 12, 17, 22,
+		5, 9, 14, 20, 5, 9, 14, 20, 5, 9, 14, 20, 5, 9, 14, 20,
+		4, 11, 16, 23, 4, 11, 16, 23, 4, 11, 16, 23, 4, 11, 16, 23,
+		6, 10, 15, 21, 6, 10, 15, 21, 6, 10, 15, 21, 6, 10, 15, 21,
+	}
+)
+
+// SkynetHashKey returns skynet's crypt.hashkey of text: the djb and js
+// string hashes, 4 little-endian bytes each
+func SkynetHashKey(text string) []byte {
+	djb := uint32(5381)
+	js := uint32(1315423911)
+	for i := 0; i < len(text); i++ {
+		c := uint32(text[i])
+		djb += djb<<5 + c
+		js ^= js<<5 + c + js>>2
+	}
+
+	key := make([]byte, 8)
+	binary.LittleEndian.PutUint32(key[0:4], djb)
+	binary.LittleEndian.PutUint32(key[4:8], js)
+	return key
+}
+
+// SkynetHMAC64 returns skynet's crypt.hmac64 of the 8-byte values x and y:
+// the 64 MD5 rounds over a block repeating x and y, without padding, folded
+// into 8 bytes. msgserver clients sign their handshake with
+// SkynetHMAC64(SkynetHashKey("username:index"), secret).
+func SkynetHMAC64(x, y []byte) []byte {
+	if len(x) != 8 || len(y) != 8 {
+		panic("skynet hmac64 values must be 8 bytes")
+	}
+
+	x0, x1 := binary.LittleEndian.Uint32(x[0:4]), binary.LittleEndian.Uint32(x[4:8])
+	y0, y1 := binary.LittleEndian.Uint32(y[0:4]), binary.LittleEndian.Uint32(y[4:8])
+	var w [16]uint32
+	for i := 0; i < 16; i += 4 {
+		w[i], w[i+1], w[i+2], w[i+3] = x1, x0, y1, y0
+	}
+
+	a, b, c, d := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+	for i := 0; i < 64; i++ {
+		var f uint32
+		var g int
+		switch {
+		case i < 16:
+			f, g = (b&c)|(^b&d), i
+		case i < 32:
+			f, g = (d&b)|(^d&c), (5*i+1)%16
+		case i < 48:
+			f, g = b^c^d, (3*i+5)%16
+		default:
+			f, g = c^(b|^d), (7*i)%16
+		}
+		a, b, c, d = d, b+bits.RotateLeft32(a+f+skynetK[i]+w[g], int(skynetR[i])), b, c
+	}
+
+	result := make([]byte, 8)
+	binary.LittleEndian.PutUint32(result[0:4], c^d)
+	binary.LittleEndian.PutUint32(result[4:8], a^b)
+	return result
+}
+
 // DESEncode encrypts data using DES with given key
 func DESEncode(key, data []byte) []byte {
 	if len(key) != 8 {
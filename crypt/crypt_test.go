@@ -130,3 +130,27 @@ func TestHashKey(t *testing.T) {
 		t.Error("Hash should be consistent")
 	}
 }
+
+// Vectors computed with the Hash and hmac routines of skynet's
+// lualib-src/lua-crypt.c, compiled standalone
+func TestSkynetHMAC64(t *testing.T) {
+	tests := []struct {
+		text, secret string
+		key, hmac    string
+	}{
+		{"", "12345678", "05150000a7c6674e", "d49c41903be1acb7"},
+		{"hello", "abcdefgh", "9930920fcbef1867", "1ab2a24b8a0b4f6c"},
+		{"YQ==@c2FtcGxl#MQ==:1", "\x01\x02\x03\x04\x05\x06\x07\x08", "0cea012c2c7a230a", "da90d36ea8cf97e9"},
+		{"YWxpY2U=@Z2FtZQ==#Mg==:42", "secret!!", "f6c467087072a9d0", "bbc6ca0585c6f76b"},
+	}
+
+	for _, tt := range tests {
+		key := SkynetHashKey(tt.text)
+		if got := HexEncode(key); got != tt.key {
+			t.Errorf("hashkey(%q) = %s, expected %s", tt.text, got, tt.key)
+		}
+		if got := HexEncode(SkynetHMAC64(key, []byte(tt.secret))); got != tt.hmac {
+			t.Errorf("hmac64(hashkey(%q), %q) = %s, expected %s", tt.text, tt.secret, got, tt.hmac)
+		}
+	}
+}
@@ -136,9 +136,18 @@ func main() {
 
 	// Listen for cluster events
 	go func() {
-		for event := range manager.Events() {
-			log.Printf("Cluster event: %s - Node: %s at %s",
-				event.Type, event.NodeID, event.Timestamp.Format(time.RFC3339))
+		sub := manager.Subscribe(context.Background(), cluster.EventFilter{
+			Categories: []cluster.EventCategory{cluster.CategoryMembership, cluster.CategoryLeadership},
+		}, 0)
+		for event := range sub.C {
+			switch payload := event.Payload.(type) {
+			case cluster.NodeStateChanged:
+				log.Printf("Node %s: %s -> %s at %s", event.NodeID, payload.OldState, payload.NewState,
+					event.Timestamp.Format(time.RFC3339))
+			default:
+				log.Printf("Cluster event: %s - Node: %s at %s",
+					event.Type, event.NodeID, event.Timestamp.Format(time.RFC3339))
+			}
 		}
 	}()
 
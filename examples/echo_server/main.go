@@ -33,7 +33,7 @@ func main() {
 	})
 
 	// Set up message handler
-	server.SetMessageHandler(&EchoMessageHandler{})
+	server.SetMessageHandler(newEchoDispatcher())
 
 	// Start server
 	fmt.Printf("Starting echo server on port %d...\n", config.Port)
@@ -102,56 +102,61 @@ func (h *EchoConnectionHandler) OnError(conn network.Connection, err error) {
 	fmt.Printf("Connection %s error: %v\n", conn.ID(), err)
 }
 
-// EchoMessageHandler handles incoming messages
-type EchoMessageHandler struct{}
+// newEchoDispatcher routes incoming messages to one handler per route
+func newEchoDispatcher() *network.MessageDispatcher {
+	dispatcher := network.NewMessageDispatcher()
 
-func (h *EchoMessageHandler) OnMessage(conn network.Connection, msg *network.Message) {
-	switch msg.Type {
-	case network.MessageTypeHeartbeat:
-		// Respond to heartbeat
-		ack := network.NewAckMessage(msg.Sequence)
-		conn.SendMessage(ack)
+	// RPC calls carry their method in the payload
+	dispatcher.SetMethodFunc(func(msg *network.Message) string {
+		if msg.Type != network.MessageTypeRPC {
+			return ""
+		}
+		return string(msg.Data)
+	})
+
+	dispatcher.Handle(network.MessageTypeHeartbeat, func(conn network.Connection, msg *network.Message) error {
+		return conn.SendMessage(network.NewAckMessage(msg.Sequence))
+	})
 
-	case network.MessageTypeData:
+	dispatcher.Handle(network.MessageTypeData, func(conn network.Connection, msg *network.Message) error {
 		// Echo the message back with prefix
 		echoData := fmt.Sprintf("Echo: %s", string(msg.Data))
-		response := network.NewMessage(network.MessageTypeData, []byte(echoData))
-		conn.SendMessage(response)
-
 		fmt.Printf("Echoed to %s: %s\n", conn.ID(), string(msg.Data))
+		return conn.SendMessage(network.NewMessage(network.MessageTypeData, []byte(echoData)))
+	})
 
-	case network.MessageTypeRPC:
-		// Handle RPC calls
-		h.handleRPC(conn, msg)
+	dispatcher.HandleMethod("ping", rpcHandler(func(conn network.Connection) []byte {
+		return []byte("pong")
+	}))
+	dispatcher.HandleMethod("time", rpcHandler(func(conn network.Connection) []byte {
+		return []byte(time.Now().Format(time.RFC3339))
+	}))
+	dispatcher.HandleMethod("stats", rpcHandler(func(conn network.Connection) []byte {
+		stats := conn.GetStatistics()
+		return []byte(fmt.Sprintf("Bytes: R/W=%d/%d, Messages: R/S=%d/%d",
+			stats.BytesRead, stats.BytesWritten, stats.MessagesRead, stats.MessagesSent))
+	}))
+	dispatcher.Handle(network.MessageTypeRPC, func(conn network.Connection, msg *network.Message) error {
+		response := []byte(fmt.Sprintf("Unknown RPC call: %s", string(msg.Data)))
+		return conn.SendMessage(network.NewRPCMessage(msg.Destination, msg.Source, response))
+	})
 
-	default:
+	dispatcher.HandleDefault(func(conn network.Connection, msg *network.Message) error {
 		fmt.Printf("Unknown message type from %s: %v\n", conn.ID(), msg.Type)
-	}
-}
+		return nil
+	})
 
-func (h *EchoMessageHandler) OnError(conn network.Connection, err error) {
-	fmt.Printf("Message handling error for %s: %v\n", conn.ID(), err)
+	dispatcher.SetErrorHandler(func(conn network.Connection, err error) {
+		fmt.Printf("Message handling error for %s: %v\n", conn.ID(), err)
+	})
+	return dispatcher
 }
 
-func (h *EchoMessageHandler) handleRPC(conn network.Connection, msg *network.Message) {
-	rpcCall := string(msg.Data)
-	var response []byte
-
-	switch rpcCall {
-	case "ping":
-		response = []byte("pong")
-	case "time":
-		response = []byte(time.Now().Format(time.RFC3339))
-	case "stats":
-		stats := conn.GetStatistics()
-		response = []byte(fmt.Sprintf("Bytes: R/W=%d/%d, Messages: R/S=%d/%d",
-			stats.BytesRead, stats.BytesWritten, stats.MessagesRead, stats.MessagesSent))
-	default:
-		response = []byte(fmt.Sprintf("Unknown RPC call: %s", rpcCall))
+// rpcHandler replies to an RPC call with the result of call
+func rpcHandler(call func(conn network.Connection) []byte) network.RouteHandler {
+	return func(conn network.Connection, msg *network.Message) error {
+		return conn.SendMessage(network.NewRPCMessage(msg.Destination, msg.Source, call(conn)))
 	}
-
-	rpcResponse := network.NewRPCMessage(msg.Destination, msg.Source, response)
-	conn.SendMessage(rpcResponse)
 }
 
 // reportStatistics periodically reports server statistics
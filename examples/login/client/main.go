@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"log"
@@ -10,7 +11,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/najoast/sngo/crypt"
+	"github.com/najoast/sngo/network"
 )
 
 func main() {
@@ -51,58 +52,7 @@ func main() {
 }
 
 func performLogin(conn net.Conn) error {
-	reader := bufio.NewReader(conn)
-	
-	// 1. 接收challenge
-	challengeStr, err := reader.ReadString('\n')
-	if err != nil {
-		return fmt.Errorf("failed to read challenge: %v", err)
-	}
-	challengeStr = strings.TrimSpace(challengeStr)
-	
-	challenge, err := base64.StdEncoding.DecodeString(challengeStr)
-	if err != nil {
-		return fmt.Errorf("invalid challenge: %v", err)
-	}
-	
-	fmt.Printf("Received challenge: %x\n", challenge)
-	
-	// 2. 生成客户端密钥对
-	clientPrivate := crypt.RandomKey()
-	clientPublic := crypt.DHExchange(clientPrivate)
-	
-	// 发送客户端公钥
-	clientKeyStr := base64.StdEncoding.EncodeToString(clientPublic) + "\n"
-	_, err = conn.Write([]byte(clientKeyStr))
-	if err != nil {
-		return fmt.Errorf("failed to send client key: %v", err)
-	}
-	
-	// 3. 接收服务器公钥
-	serverKeyStr, err := reader.ReadString('\n')
-	if err != nil {
-		return fmt.Errorf("failed to read server key: %v", err)
-	}
-	serverKeyStr = strings.TrimSpace(serverKeyStr)
-	
-	serverPublic, err := base64.StdEncoding.DecodeString(serverKeyStr)
-	if err != nil {
-		return fmt.Errorf("invalid server key: %v", err)
-	}
-	
-	// 4. 计算共享密钥
-	secret := crypt.DHSecret(clientPrivate, serverPublic)
-	fmt.Printf("Calculated secret: %x\n", secret)
-	
-	// 5. 计算并发送HMAC
-	hmac := crypt.HMAC64(challenge, secret)
-	hmacStr := base64.StdEncoding.EncodeToString(hmac) + "\n"
-	_, err = conn.Write([]byte(hmacStr))
-	if err != nil {
-		return fmt.Errorf("failed to send HMAC: %v", err)
-	}
-	
-	// 6. 构造并发送token
+	// 构造token: base64(user)@base64(server):base64(password)
 	user := "testuser"
 	server := "sample"
 	password := "password"
@@ -110,39 +60,23 @@ func performLogin(conn net.Conn) error {
 	userB64 := base64.StdEncoding.EncodeToString([]byte(user))
 	serverB64 := base64.StdEncoding.EncodeToString([]byte(server))
 	passwordB64 := base64.StdEncoding.EncodeToString([]byte(password))
-	
 	token := fmt.Sprintf("%s@%s:%s", userB64, serverB64, passwordB64)
-	encryptedToken := crypt.DesEncode(secret, []byte(token))
-	tokenStr := base64.StdEncoding.EncodeToString(encryptedToken) + "\n"
-	
-	_, err = conn.Write([]byte(tokenStr))
-	if err != nil {
-		return fmt.Errorf("failed to send token: %v", err)
-	}
 	
-	// 7. 接收响应
-	response, err := reader.ReadString('\n')
+	// 接收challenge、DH密钥交换、发送加密token并接收subid
+	hs, err := network.NewHandshake(conn, network.DefaultHandshakeConfig(),
+		network.ClientChallenge(),
+		network.ClientKeyExchange(),
+		network.ClientAuth(token),
+	)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %v", err)
-	}
-	response = strings.TrimSpace(response)
-	
-	if !strings.HasPrefix(response, "200") {
-		return fmt.Errorf("login failed: %s", response)
+		return err
 	}
-	
-	// 解析subid
-	parts := strings.Split(response, " ")
-	if len(parts) < 2 {
-		return fmt.Errorf("invalid response format")
-	}
-	
-	subidBytes, err := base64.StdEncoding.DecodeString(parts[1])
-	if err != nil {
-		return fmt.Errorf("invalid subid: %v", err)
+	if err := hs.Run(context.Background()); err != nil {
+		return fmt.Errorf("login failed: %v", err)
 	}
 	
-	fmt.Printf("Login successful! SubID: %s\n", string(subidBytes))
+	fmt.Printf("Calculated secret: %x\n", hs.Secret)
+	fmt.Printf("Login successful! SubID: %s\n", hs.SubID)
 	return nil
 }
 
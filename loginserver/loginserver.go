@@ -1,14 +1,14 @@
 package loginserver
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net"
-	"strings"
 	"time"
 
-	"github.com/najoast/sngo/crypt"
+	"github.com/najoast/sngo/network"
 )
 
 // GameServerActor 游戏服务器接口
@@ -112,107 +112,38 @@ func (ls *LoginServer) handleConnection(conn net.Conn) {
 
 	log.Printf("New connection from %s", conn.RemoteAddr().String())
 
-	// 设置超时
-	conn.SetDeadline(time.Now().Add(30 * time.Second))
-
-	// DH密钥交换阶段
-	challenge := crypt.RandomKey()
-	log.Printf("Generated challenge: %x", challenge)
-
-	// 发送challenge
-	challengeStr := crypt.Base64Encode(challenge) + "\n"
-	_, err := conn.Write([]byte(challengeStr))
-	if err != nil {
-		log.Printf("Failed to send challenge: %v", err)
-		return
-	}
-	log.Printf("Sent challenge: %s", challengeStr)
-
-	// 接收客户端公钥
-	clientKeyStr, err := ls.readLine(conn)
-	if err != nil {
-		log.Printf("Failed to read client key: %v", err)
-		return
-	}
-	log.Printf("Received client key: %s", clientKeyStr)
-
-	clientKey, err := crypt.Base64Decode(clientKeyStr)
-	if err != nil {
-		log.Printf("Invalid client key: %v", err)
-		return
-	}
-	log.Printf("Decoded client key: %x", clientKey)
-
-	// 生成服务器密钥对
-	serverPrivate := crypt.RandomKey()
-	serverPublic := crypt.DHExchange(serverPrivate)
-	log.Printf("Generated server keys - private: %x, public: %x", serverPrivate, serverPublic)
-
-	// 发送服务器公钥
-	serverKeyStr := crypt.Base64Encode(serverPublic) + "\n"
-	_, err = conn.Write([]byte(serverKeyStr))
+	// 挑战、DH密钥交换与token验证
+	hs, err := network.NewHandshake(conn, network.DefaultHandshakeConfig(),
+		network.ServerChallenge(),
+		network.ServerKeyExchange(),
+		network.ServerAuth(ls.authenticate),
+	)
 	if err != nil {
-		log.Printf("Failed to send server key: %v", err)
+		log.Printf("Failed to create handshake: %v", err)
 		return
 	}
-	log.Printf("Sent server key: %s", serverKeyStr)
-
-	// 计算共享密钥
-	secret := crypt.DHSecret(serverPrivate, clientKey)
-	log.Printf("Calculated shared secret: %x", secret)
-
-	// 接收HMAC验证
-	hmacStr, err := ls.readLine(conn)
-	if err != nil {
-		log.Printf("Failed to read HMAC: %v", err)
-		return
-	}
-
-	clientHMAC, err := crypt.Base64Decode(hmacStr)
-	if err != nil {
-		log.Printf("Invalid HMAC: %v", err)
+	if err := hs.Run(context.Background()); err != nil {
+		log.Printf("Handshake with %s failed: %v", conn.RemoteAddr().String(), err)
 		return
 	}
 
-	// 验证HMAC
-	expectedHMAC := crypt.HMAC64(challenge, secret)
-	if string(clientHMAC) != string(expectedHMAC) {
-		log.Printf("HMAC verification failed")
-		conn.Write([]byte("401 HMAC verification failed\n"))
-		return
-	}
-
-	// 接收加密的token
-	tokenStr, err := ls.readLine(conn)
-	if err != nil {
-		log.Printf("Failed to read token: %v", err)
-		return
-	}
-
-	encryptedToken, err := crypt.Base64Decode(tokenStr)
-	if err != nil {
-		log.Printf("Invalid token: %v", err)
-		return
-	}
-
-	// 解密token
-	tokenBytes := crypt.DesDecode(secret, encryptedToken)
-	token := string(tokenBytes)
+	log.Printf("User logged in with subid %s", hs.SubID)
+}
 
+// authenticate 验证token并登录游戏服务器，返回subid
+func (ls *LoginServer) authenticate(ctx context.Context, hs *network.Handshake, token string) (string, error) {
 	// 验证token
 	server, uid, err := ls.handler.AuthHandler(token)
 	if err != nil {
 		log.Printf("Auth failed: %v", err)
-		conn.Write([]byte(fmt.Sprintf("403 %s\n", err.Error())))
-		return
+		return "", network.RejectHandshake(network.HandshakeStatusForbidden, err.Error())
 	}
 
 	// 检查游戏服务器是否存在
 	gameServer, exists := ls.actors[server]
 	if !exists {
 		log.Printf("Unknown server: %s", server)
-		conn.Write([]byte("404 Unknown server\n"))
-		return
+		return "", network.RejectHandshake(network.HandshakeStatusNotFound, "Unknown server")
 	}
 
 	// 检查是否允许多重登录
@@ -224,40 +155,23 @@ func (ls *LoginServer) handleConnection(conn net.Conn) {
 	}
 
 	// 向游戏服务器发送登录请求
-	subid, err := ls.handler.LoginHandler(server, uid, secret)
+	subid, err := ls.handler.LoginHandler(server, uid, hs.Secret)
 	if err != nil {
 		log.Printf("Login handler failed: %v", err)
-		conn.Write([]byte(fmt.Sprintf("500 %s\n", err.Error())))
-		return
+		return "", network.RejectHandshake(network.HandshakeStatusInternal, err.Error())
 	}
 
 	// 记录用户信息
-	userInfo := &UserInfo{
+	ls.users[uid] = &UserInfo{
 		UID:     uid,
 		Server:  server,
 		Address: gameServer.GetHandle(),
 		SubID:   subid,
 		LoginAt: time.Now(),
 	}
-	ls.users[uid] = userInfo
-
-	// 返回成功响应和subid
-	response := fmt.Sprintf("200 %s\n", crypt.Base64Encode([]byte(subid)))
-	conn.Write([]byte(response))
 
 	log.Printf("User %s logged into server %s with subid %s", uid, server, subid)
-}
-
-// readLine 从连接读取一行
-func (ls *LoginServer) readLine(conn net.Conn) (string, error) {
-	buf := make([]byte, 1024)
-	n, err := conn.Read(buf)
-	if err != nil {
-		return "", err
-	}
-
-	line := strings.TrimSpace(string(buf[:n]))
-	return line, nil
+	return subid, nil
 }
 
 // kickUser 踢出用户
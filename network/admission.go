@@ -0,0 +1,170 @@
+// Package network provides admission control for inbound traffic
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdmissionMode selects which inbound messages a server hands to its
+// message handler
+type AdmissionMode int32
+
+const (
+	// AdmitAll passes every message
+	AdmitAll AdmissionMode = iota
+
+	// AdmitHealthChecks passes health checks only
+	AdmitHealthChecks
+
+	// AdmitTrickle passes health checks and up to the trickle rate of
+	// other messages
+	AdmitTrickle
+)
+
+// String returns the string representation of AdmissionMode
+func (m AdmissionMode) String() string {
+	switch m {
+	case AdmitAll:
+		return "all"
+	case AdmitHealthChecks:
+		return "health_checks"
+	case AdmitTrickle:
+		return "trickle"
+	default:
+		return "unknown"
+	}
+}
+
+// AdmissionRejectedMessage is the error text sent back for a message
+// refused by admission control; clients can retry it shortly
+const AdmissionRejectedMessage = "server not ready, retry later"
+
+// AdmissionStatistics holds the counters of an admission controller
+type AdmissionStatistics struct {
+	Mode     string `json:"mode"`
+	Admitted int64  `json:"admitted"`
+	Rejected int64  `json:"rejected"`
+}
+
+// AdmissionController decides which inbound messages are admitted while a
+// server is not ready for full traffic, such as during warm-up after a
+// deploy. It is safe for concurrent use.
+type AdmissionController struct {
+	mode atomic.Int32
+
+	admitted int64
+	rejected int64
+
+	mu            sync.Mutex
+	isHealthCheck func(msg *Message) bool
+	rate          float64
+	tokens        float64
+	last          time.Time
+}
+
+// NewAdmissionController creates an admission controller admitting all
+// traffic; health checks are the system messages, such as heartbeats
+func NewAdmissionController() *AdmissionController {
+	return &AdmissionController{
+		isHealthCheck: func(msg *Message) bool {
+			return msg.Type < MessageTypeUserStart
+		},
+	}
+}
+
+// SetMode sets the admission mode
+func (ac *AdmissionController) SetMode(mode AdmissionMode) {
+	ac.mode.Store(int32(mode))
+}
+
+// Mode returns the admission mode
+func (ac *AdmissionController) Mode() AdmissionMode {
+	return AdmissionMode(ac.mode.Load())
+}
+
+// SetTrickleRate sets how many non health check messages per second are
+// admitted in AdmitTrickle mode, with a burst of one second worth
+func (ac *AdmissionController) SetTrickleRate(perSecond float64) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.rate = perSecond
+	ac.tokens = 0
+	ac.last = time.Now()
+}
+
+// SetHealthCheck sets the predicate recognising health check messages,
+// which are admitted in every mode
+func (ac *AdmissionController) SetHealthCheck(isHealthCheck func(msg *Message) bool) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.isHealthCheck = isHealthCheck
+}
+
+// Admit reports whether msg may be handled
+func (ac *AdmissionController) Admit(msg *Message) bool {
+	mode := ac.Mode()
+	if mode == AdmitAll {
+		atomic.AddInt64(&ac.admitted, 1)
+		return true
+	}
+
+	ac.mu.Lock()
+	admitted := ac.isHealthCheck(msg) || (mode == AdmitTrickle && ac.takeToken(time.Now()))
+	ac.mu.Unlock()
+
+	if admitted {
+		atomic.AddInt64(&ac.admitted, 1)
+	} else {
+		atomic.AddInt64(&ac.rejected, 1)
+	}
+	return admitted
+}
+
+// takeToken refills the trickle bucket and takes a token from it; callers
+// hold mu
+func (ac *AdmissionController) takeToken(now time.Time) bool {
+	if ac.rate <= 0 {
+		return false
+	}
+
+	if !ac.last.IsZero() {
+		ac.tokens += now.Sub(ac.last).Seconds() * ac.rate
+	}
+	ac.last = now
+
+	burst := ac.rate
+	if burst < 1 {
+		burst = 1
+	}
+	if ac.tokens > burst {
+		ac.tokens = burst
+	}
+
+	if ac.tokens < 1 {
+		return false
+	}
+	ac.tokens--
+	return true
+}
+
+// GetStatistics returns the admission counters
+func (ac *AdmissionController) GetStatistics() AdmissionStatistics {
+	return AdmissionStatistics{
+		Mode:     ac.Mode().String(),
+		Admitted: atomic.LoadInt64(&ac.admitted),
+		Rejected: atomic.LoadInt64(&ac.rejected),
+	}
+}
+
+// rejectMessage tells the sender that msg was refused, keeping its
+// session so callers waiting on a reply fail fast instead of timing out
+func rejectMessage(conn Connection, msg *Message) {
+	reply := NewErrorMessage(AdmissionRejectedMessage)
+	reply.SessionID = msg.SessionID
+	reply.Sequence = msg.Sequence
+	conn.SendMessage(reply)
+}
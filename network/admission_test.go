@@ -0,0 +1,137 @@
+package network
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdmissionModes(t *testing.T) {
+	ac := NewAdmissionController()
+	heartbeat := NewHeartbeatMessage()
+	data := NewMessage(MessageTypeData, []byte("x"))
+
+	if !ac.Admit(data) {
+		t.Error("Expected AdmitAll to pass user messages")
+	}
+
+	ac.SetMode(AdmitHealthChecks)
+	if !ac.Admit(heartbeat) {
+		t.Error("Expected health checks to pass")
+	}
+	if ac.Admit(data) {
+		t.Error("Expected user messages to be held back")
+	}
+
+	ac.SetMode(AdmitTrickle)
+	ac.SetTrickleRate(2)
+	ac.last = ac.last.Add(-time.Second) // a second of refill
+	admitted := 0
+	for i := 0; i < 10; i++ {
+		if ac.Admit(data) {
+			admitted++
+		}
+	}
+	if admitted != 2 {
+		t.Errorf("Expected a burst of 2 trickled messages, got %d", admitted)
+	}
+
+	stats := ac.GetStatistics()
+	if stats.Mode != "trickle" || stats.Admitted != 4 || stats.Rejected != 9 {
+		t.Errorf("Unexpected statistics %+v", stats)
+	}
+}
+
+func TestAdmissionCustomHealthCheck(t *testing.T) {
+	ac := NewAdmissionController()
+	ac.SetMode(AdmitHealthChecks)
+	ac.SetHealthCheck(func(msg *Message) bool {
+		return msg.Destination == "health"
+	})
+
+	probe := NewRPCMessage("lb", "health", nil)
+	if !ac.Admit(probe) {
+		t.Error("Expected the custom health check to pass")
+	}
+	if ac.Admit(NewHeartbeatMessage()) {
+		t.Error("Expected heartbeats to be held back by the custom predicate")
+	}
+}
+
+func TestTCPServerAdmission(t *testing.T) {
+	config := DefaultNetworkConfig()
+	config.Port = 18086
+
+	server, err := NewTCPServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	var mu sync.Mutex
+	var handled []MessageType
+	server.SetMessageHandler(&testMessageHandler{
+		onMessage: func(conn Connection, msg *Message) {
+			mu.Lock()
+			handled = append(handled, msg.Type)
+			mu.Unlock()
+		},
+	})
+
+	ac := NewAdmissionController()
+	ac.SetMode(AdmitHealthChecks)
+	server.(AdmissionControllable).SetAdmissionController(ac)
+
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	client, err := NewTCPClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	replies := make(chan *Message, 4)
+	client.SetMessageHandler(&testMessageHandler{
+		onMessage: func(conn Connection, msg *Message) {
+			replies <- msg
+		},
+	})
+	if _, err := client.Connect(server.Listen().String()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	held := NewMessage(MessageTypeData, []byte("early"))
+	held.SessionID = 42
+	client.SendMessage(held)
+	client.SendMessage(NewHeartbeatMessage())
+
+	select {
+	case reply := <-replies:
+		if reply.Type != MessageTypeError || reply.SessionID != 42 || string(reply.Data) != AdmissionRejectedMessage {
+			t.Errorf("Expected a rejection for session 42, got %+v", reply)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the held back message to be rejected")
+	}
+
+	ac.SetMode(AdmitAll)
+	client.SendMessage(NewMessage(MessageTypeData, []byte("late")))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(handled)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handled) != 2 || handled[0] != MessageTypeHeartbeat || handled[1] != MessageTypeData {
+		t.Errorf("Expected the heartbeat and the late message to be handled, got %v", handled)
+	}
+}
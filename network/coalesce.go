@@ -0,0 +1,140 @@
+// Package network provides write coalescing for small frames
+package network
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// CoalescingPolicy batches small outgoing frames into fewer writes. With
+// TCP_NODELAY every frame costs its own packet; coalescing trades up to
+// MaxDelay of latency for fewer syscalls and packets.
+type CoalescingPolicy struct {
+	// Enabled turns coalescing on
+	Enabled bool
+
+	// MaxBytes flushes the batch once it holds this many bytes
+	MaxBytes int
+
+	// MaxDelay is how long the writer waits for more frames before
+	// flushing a batch below MaxBytes (0 only batches frames already queued)
+	MaxDelay time.Duration
+}
+
+// LowLatencyCoalescing returns the policy for interactive connections such
+// as game clients: frames already queued are batched, nothing waits
+func LowLatencyCoalescing() CoalescingPolicy {
+	return CoalescingPolicy{Enabled: true, MaxBytes: 16 * 1024}
+}
+
+// ThroughputCoalescing returns the policy for bulk connections such as
+// cluster peers: frames wait up to 2ms to fill a batch
+func ThroughputCoalescing() CoalescingPolicy {
+	return CoalescingPolicy{Enabled: true, MaxBytes: 64 * 1024, MaxDelay: 2 * time.Millisecond}
+}
+
+// withDefaults fills in the batch size of an enabled policy
+func (p CoalescingPolicy) withDefaults() CoalescingPolicy {
+	if p.Enabled && p.MaxBytes <= 0 {
+		p.MaxBytes = 16 * 1024
+	}
+	return p
+}
+
+// CoalescingStats reports how well outgoing frames were batched
+type CoalescingStats struct {
+	Frames int64 `json:"frames"`
+	Writes int64 `json:"writes"`
+
+	// FramesPerWrite is the average number of frames per write syscall
+	FramesPerWrite float64 `json:"frames_per_write"`
+}
+
+// WriteCoalescable is implemented by connections that can batch outgoing frames
+type WriteCoalescable interface {
+	// SetWriteCoalescing replaces the connection's coalescing policy
+	SetWriteCoalescing(policy CoalescingPolicy)
+
+	// CoalescingStats returns the frames and writes issued so far
+	CoalescingStats() CoalescingStats
+}
+
+// configureCoalescing applies the write coalescing settings to a connection
+func configureCoalescing(conn Connection, config *NetworkConfig) {
+	if c, ok := conn.(WriteCoalescable); ok {
+		c.SetWriteCoalescing(config.WriteCoalescing)
+	}
+}
+
+// SetWriteCoalescing replaces the connection's coalescing policy; it takes
+// effect from the next batch on
+func (tc *tcpConnection) SetWriteCoalescing(policy CoalescingPolicy) {
+	tc.coalescing.Store(policy.withDefaults())
+}
+
+// CoalescingStats returns the frames and writes issued so far
+func (tc *tcpConnection) CoalescingStats() CoalescingStats {
+	stats := CoalescingStats{
+		Frames: atomic.LoadInt64(&tc.framesWritten),
+		Writes: atomic.LoadInt64(&tc.writes),
+	}
+	if stats.Writes > 0 {
+		stats.FramesPerWrite = float64(stats.Frames) / float64(stats.Writes)
+	}
+	return stats
+}
+
+// coalescingPolicy returns the current coalescing policy
+func (tc *tcpConnection) coalescingPolicy() CoalescingPolicy {
+	policy, _ := tc.coalescing.Load().(CoalescingPolicy)
+	return policy
+}
+
+// coalesce appends frames queued after first to a batch until the policy's
+// size or delay budget is spent. It returns the batch, the number of frames
+// in it and false once the send channel is closed.
+func (tc *tcpConnection) coalesce(first []byte, policy CoalescingPolicy, buf []byte) ([]byte, int, bool) {
+	batch := append(buf[:0], first...)
+	frames := 1
+
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for len(batch) < policy.MaxBytes {
+		select {
+		case data, ok := <-tc.sendChan:
+			if !ok {
+				return batch, frames, false
+			}
+			batch = append(batch, data...)
+			frames++
+			continue
+		default:
+		}
+
+		if policy.MaxDelay <= 0 {
+			break
+		}
+		if timer == nil {
+			timer = time.NewTimer(policy.MaxDelay)
+		}
+
+		select {
+		case data, ok := <-tc.sendChan:
+			if !ok {
+				return batch, frames, false
+			}
+			batch = append(batch, data...)
+			frames++
+			continue
+		case <-timer.C:
+		}
+		break
+	}
+
+	return batch, frames, true
+}
@@ -0,0 +1,70 @@
+// Package network provides tests for write coalescing
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWriteCoalescing(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	conn := NewTCPConnection(local)
+	defer conn.Close()
+
+	coalescable, ok := conn.(WriteCoalescable)
+	if !ok {
+		t.Fatal("TCP connection does not support write coalescing")
+	}
+	policy := ThroughputCoalescing()
+	policy.MaxDelay = 50 * time.Millisecond
+	coalescable.SetWriteCoalescing(policy)
+
+	const frames = 10
+	for i := 0; i < frames; i++ {
+		if err := conn.SendMessage(NewMessage(MessageTypeData, []byte{byte(i)})); err != nil {
+			t.Fatalf("Failed to send frame %d: %v", i, err)
+		}
+	}
+
+	remote.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := 0; i < frames; i++ {
+		msg, err := ReadFrame(remote)
+		if err != nil {
+			t.Fatalf("Failed to read frame %d: %v", i, err)
+		}
+		if err := ExpectFrame(msg, MessageTypeData, []byte{byte(i)}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Statistics are updated once the write returns
+	stats := coalescable.CoalescingStats()
+	for deadline := time.Now().Add(time.Second); stats.Frames < frames && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		stats = coalescable.CoalescingStats()
+	}
+	if stats.Frames != frames || stats.Writes >= frames {
+		t.Errorf("Expected frames to be batched, got %+v", stats)
+	}
+	if got := conn.GetStatistics().FramesPerWrite; got <= 1 {
+		t.Errorf("Expected more than one frame per write, got %.2f", got)
+	}
+
+	// Disabled coalescing writes one frame per call
+	coalescable.SetWriteCoalescing(CoalescingPolicy{})
+	conn.SendMessage(NewMessage(MessageTypeData, []byte("solo")))
+	if _, err := ReadFrame(remote); err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	after := coalescable.CoalescingStats()
+	for deadline := time.Now().Add(time.Second); after.Frames == stats.Frames && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		after = coalescable.CoalescingStats()
+	}
+	if after.Writes != stats.Writes+1 {
+		t.Errorf("Expected a single extra write, got %+v", after)
+	}
+}
@@ -0,0 +1,450 @@
+// Package network provides the wire format spec and conformance vectors
+// for client implementations in other languages
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// WireFormatVersion is bumped whenever the frame layout changes
+const WireFormatVersion = 1
+
+// WireField describes one field of the frame header or its extensions
+type WireField struct {
+	Name        string `json:"name"`
+	Offset      int    `json:"offset"`
+	Size        int    `json:"size"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// WireFlag describes one bit of the flags field
+type WireFlag struct {
+	Name        string `json:"name"`
+	Bit         int    `json:"bit"`
+	Description string `json:"description"`
+}
+
+// WireMessageType describes a reserved message type
+type WireMessageType struct {
+	Name  string      `json:"name"`
+	Value MessageType `json:"value"`
+}
+
+// WireFormatSpec is the machine-readable description of the binary frame
+// written by BinaryMessageCodec. Extension offsets are relative to the end
+// of the header; an extension is present only if its flag is set, and
+// later extensions move up when earlier ones are absent.
+type WireFormatSpec struct {
+	Version      int               `json:"version"`
+	Endianness   string            `json:"endianness"`
+	HeaderSize   int               `json:"header_size"`
+	MaxFrameSize int               `json:"max_frame_size"`
+	Header       []WireField       `json:"header"`
+	Extensions   []WireField       `json:"extensions"`
+	Flags        []WireFlag        `json:"flags"`
+	MessageTypes []WireMessageType `json:"message_types"`
+	Checksum     string            `json:"checksum"`
+	Payload      string            `json:"payload"`
+}
+
+// WireSpec returns the spec of the current wire format
+func WireSpec() WireFormatSpec {
+	return WireFormatSpec{
+		Version:      WireFormatVersion,
+		Endianness:   "big",
+		HeaderSize:   MessageHeaderSize,
+		MaxFrameSize: MaxMessageSize,
+		Header: []WireField{
+			{Name: "type", Offset: 0, Size: 4, Type: "uint32", Description: "message type; 1-99 system, 100+ user"},
+			{Name: "flags", Offset: 4, Size: 4, Type: "uint32", Description: "bit set of flags"},
+			{Name: "sequence", Offset: 8, Size: 4, Type: "uint32", Description: "sender sequence number"},
+			{Name: "session_id", Offset: 12, Size: 8, Type: "uint64", Description: "session the message belongs to"},
+			{Name: "timestamp", Offset: 20, Size: 8, Type: "int64", Description: "creation time in Unix seconds"},
+			{Name: "length", Offset: 28, Size: 4, Type: "uint32", Description: "payload length in bytes, excluding header and extensions"},
+		},
+		Extensions: []WireField{
+			{Name: "checksum", Offset: 0, Size: ChecksumSize, Type: "uint32", Description: "present with flag checksum"},
+			{Name: "sent_at", Offset: ChecksumSize, Size: TimestampSize, Type: "int64", Description: "present with flag timestamp; send time in Unix nanoseconds, at offset 0 without a checksum"},
+		},
+		Flags: []WireFlag{
+			{Name: "compressed", Bit: 0, Description: "payload is compressed by the application"},
+			{Name: "encrypted", Bit: 1, Description: "payload is encrypted by the application"},
+			{Name: "priority", Bit: 2, Description: "deliver ahead of normal traffic"},
+			{Name: "reliable", Bit: 3, Description: "sender expects an ack"},
+			{Name: "ordered", Bit: 4, Description: "deliver in sequence order"},
+			{Name: "checksum", Bit: 5, Description: "checksum extension follows the header"},
+			{Name: "timestamp", Bit: 6, Description: "sent_at extension follows the header"},
+		},
+		MessageTypes: []WireMessageType{
+			{Name: "heartbeat", Value: MessageTypeHeartbeat},
+			{Name: "ack", Value: MessageTypeAck},
+			{Name: "error", Value: MessageTypeError},
+			{Name: "close", Value: MessageTypeClose},
+			{Name: "time_sync", Value: MessageTypeTimeSync},
+			{Name: "rpc", Value: MessageTypeRPC},
+			{Name: "data", Value: MessageTypeData},
+			{Name: "broadcast", Value: MessageTypeBroadcast},
+			{Name: "stream", Value: MessageTypeStream},
+		},
+		Checksum: "CRC-32C (Castagnoli) over the 32 header bytes followed by everything after the checksum: the sent_at extension, then the payload. A connection either checksums every frame or none; a checksumming receiver closes the connection on a frame without a valid checksum",
+		Payload:  "opaque bytes; implementations must not add, strip or translate a byte order mark or line endings",
+	}
+}
+
+// ConformanceMessage is a decoded frame in a form every language can
+// compare field by field. 64-bit fields are JSON strings, since
+// JavaScript numbers lose precision above 2^53.
+type ConformanceMessage struct {
+	Type      uint32 `json:"type"`
+	Flags     uint32 `json:"flags"`
+	Sequence  uint32 `json:"sequence"`
+	SessionID uint64 `json:"session_id,string"`
+	Timestamp int64  `json:"timestamp,string"`
+	SentAtNs  int64  `json:"sent_at_ns,string,omitempty"`
+	Data      string `json:"data"` // hex
+}
+
+// ConformanceVector is a golden frame. Valid vectors must decode to
+// Message and Message must encode to Frame; invalid vectors (Error set)
+// must be rejected by the decoder.
+type ConformanceVector struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Frame       string              `json:"frame"` // hex
+	Message     *ConformanceMessage `json:"message,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// toMessage converts a conformance message to a codec message
+func (cm *ConformanceMessage) toMessage() (*Message, error) {
+	data, err := hex.DecodeString(cm.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data hex: %w", err)
+	}
+	msg := &Message{
+		Type:      MessageType(cm.Type),
+		Flags:     MessageFlag(cm.Flags),
+		Sequence:  cm.Sequence,
+		SessionID: cm.SessionID,
+		Timestamp: time.Unix(cm.Timestamp, 0),
+		Data:      data,
+	}
+	if msg.HasFlag(MessageFlagTimestamp) {
+		msg.SentAt = time.Unix(0, cm.SentAtNs)
+	}
+	return msg, nil
+}
+
+// conformanceMessage converts a codec message to a conformance message
+func conformanceMessage(msg *Message) *ConformanceMessage {
+	cm := &ConformanceMessage{
+		Type:      uint32(msg.Type),
+		Flags:     uint32(msg.Flags),
+		Sequence:  msg.Sequence,
+		SessionID: msg.SessionID,
+		Timestamp: msg.Timestamp.Unix(),
+		Data:      hex.EncodeToString(msg.Data),
+	}
+	if msg.HasFlag(MessageFlagTimestamp) {
+		cm.SentAtNs = msg.SentAt.UnixNano()
+	}
+	return cm
+}
+
+// GenerateConformanceVectors builds the golden vectors with the Go codec.
+// Field values are asymmetric byte patterns so a client reading any field
+// with the wrong endianness or offset fails.
+func GenerateConformanceVectors() ([]ConformanceVector, error) {
+	codec := NewBinaryMessageCodec()
+	ts := time.Unix(1700000000, 0)
+	sentAt := time.Unix(0, 1700000000123456789)
+
+	valid := []struct {
+		name, description string
+		msg               *Message
+	}{
+		{"heartbeat", "empty payload, no flags", &Message{Type: MessageTypeHeartbeat, Timestamp: ts}},
+		{"endianness", "every header field uses a distinct byte pattern", &Message{
+			Type: MessageType(0x01020304), Flags: MessageFlagPriority | MessageFlagReliable,
+			Sequence: 0x0A0B0C0D, SessionID: 0x1112131415161718, Timestamp: ts, Data: []byte{0x01, 0x02, 0x03},
+		}},
+		{"utf8_bom_payload", "payload starting with a UTF-8 byte order mark, passed through untouched", &Message{
+			Type: MessageTypeData, Sequence: 7, Timestamp: ts, Data: []byte("\xEF\xBB\xBFhello\r\n"),
+		}},
+		{"utf16_bom_payload", "payload with a UTF-16 little-endian byte order mark, passed through untouched", &Message{
+			Type: MessageTypeData, Sequence: 8, Timestamp: ts, Data: []byte{0xFF, 0xFE, 'h', 0x00, 'i', 0x00},
+		}},
+		{"checksum", "checksum extension", &Message{
+			Type: MessageTypeRPC, Flags: MessageFlagChecksum, Sequence: 1, SessionID: 42, Timestamp: ts, Data: []byte("ping"),
+		}},
+		{"timestamp", "sent_at extension without a checksum", &Message{
+			Type: MessageTypeTimeSync, Flags: MessageFlagTimestamp, Timestamp: ts, SentAt: sentAt,
+		}},
+		{"checksum_timestamp", "both extensions: checksum, then sent_at", &Message{
+			Type: MessageTypeBroadcast, Flags: MessageFlagChecksum | MessageFlagTimestamp, Sequence: 0xFFFFFFFF,
+			SessionID: 0xFFFFFFFFFFFFFFFF, Timestamp: ts, SentAt: sentAt, Data: []byte{0x00, 0xFF, 0x00},
+		}},
+	}
+
+	var vectors []ConformanceVector
+	var checksumFrame []byte
+	for _, v := range valid {
+		frame, err := codec.Encode(v.msg)
+		if err != nil {
+			return nil, fmt.Errorf("vector %s: %w", v.name, err)
+		}
+		if v.name == "checksum" {
+			checksumFrame = frame
+		}
+		vectors = append(vectors, ConformanceVector{
+			Name:        v.name,
+			Description: v.description,
+			Frame:       hex.EncodeToString(frame),
+			Message:     conformanceMessage(v.msg),
+		})
+	}
+
+	corrupt := append([]byte(nil), checksumFrame...)
+	corrupt[len(corrupt)-1] ^= 0xFF
+	vectors = append(vectors,
+		ConformanceVector{
+			Name:        "bad_checksum",
+			Description: "payload altered after the checksum was computed",
+			Frame:       hex.EncodeToString(corrupt),
+			Error:       "checksum",
+		},
+		ConformanceVector{
+			Name:        "truncated_payload",
+			Description: "length field exceeds the bytes present",
+			Frame:       hex.EncodeToString(checksumFrame[:len(checksumFrame)-1]),
+			Error:       "truncated",
+		},
+		ConformanceVector{
+			Name:        "truncated_header",
+			Description: "fewer than 32 header bytes",
+			Frame:       hex.EncodeToString(checksumFrame[:MessageHeaderSize-1]),
+			Error:       "truncated",
+		},
+	)
+	return vectors, nil
+}
+
+// LoadConformanceVectors reads vectors from a JSON file
+func LoadConformanceVectors(path string) ([]ConformanceVector, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var vectors []ConformanceVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		return nil, fmt.Errorf("invalid vectors in %s: %w", path, err)
+	}
+	return vectors, nil
+}
+
+// ConformanceClient is a codec implementation under test
+type ConformanceClient interface {
+	// Encode encodes a message into a frame
+	Encode(msg *ConformanceMessage) ([]byte, error)
+
+	// Decode decodes a frame, failing for invalid frames
+	Decode(frame []byte) (*ConformanceMessage, error)
+}
+
+// GoConformanceClient runs the vectors against BinaryMessageCodec
+type GoConformanceClient struct {
+	codec BinaryMessageCodec
+}
+
+// Encode implements ConformanceClient
+func (c *GoConformanceClient) Encode(cm *ConformanceMessage) ([]byte, error) {
+	msg, err := cm.toMessage()
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.Encode(msg)
+}
+
+// Decode implements ConformanceClient
+func (c *GoConformanceClient) Decode(frame []byte) (*ConformanceMessage, error) {
+	msg, err := c.codec.Decode(frame)
+	if err != nil {
+		return nil, err
+	}
+	return conformanceMessage(msg), nil
+}
+
+// conformanceRequest is a request sent to an external implementation
+type conformanceRequest struct {
+	Op      string              `json:"op"` // "encode" or "decode"
+	Frame   string              `json:"frame,omitempty"`
+	Message *ConformanceMessage `json:"message,omitempty"`
+}
+
+// conformanceResponse is the reply of an external implementation
+type conformanceResponse struct {
+	Frame   string              `json:"frame,omitempty"`
+	Message *ConformanceMessage `json:"message,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// ProcessConformanceClient drives an implementation in another language
+// through a small adapter program. The adapter reads one JSON request per
+// line on stdin and writes one JSON response per line on stdout:
+//
+//	{"op":"decode","frame":"<hex>"}      -> {"message":{...}} or {"error":"..."}
+//	{"op":"encode","message":{...}}      -> {"frame":"<hex>"} or {"error":"..."}
+type ProcessConformanceClient struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+}
+
+// StartProcessConformanceClient starts the adapter program name with args
+func StartProcessConformanceClient(name string, args ...string) (*ProcessConformanceClient, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 2*MaxMessageSize+1024)
+	return &ProcessConformanceClient{cmd: cmd, stdin: stdin, stdout: scanner}, nil
+}
+
+// Encode implements ConformanceClient
+func (c *ProcessConformanceClient) Encode(msg *ConformanceMessage) ([]byte, error) {
+	resp, err := c.roundTrip(conformanceRequest{Op: "encode", Message: msg})
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(resp.Frame)
+}
+
+// Decode implements ConformanceClient
+func (c *ProcessConformanceClient) Decode(frame []byte) (*ConformanceMessage, error) {
+	resp, err := c.roundTrip(conformanceRequest{Op: "decode", Frame: hex.EncodeToString(frame)})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Message == nil {
+		return nil, fmt.Errorf("adapter returned neither message nor error")
+	}
+	return resp.Message, nil
+}
+
+func (c *ProcessConformanceClient) roundTrip(req conformanceRequest) (*conformanceResponse, error) {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.stdin.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("adapter write failed: %w", err)
+	}
+	if !c.stdout.Scan() {
+		if err := c.stdout.Err(); err != nil {
+			return nil, fmt.Errorf("adapter read failed: %w", err)
+		}
+		return nil, fmt.Errorf("adapter exited")
+	}
+
+	var resp conformanceResponse
+	if err := json.Unmarshal(c.stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("invalid adapter response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// Close stops the adapter program
+func (c *ProcessConformanceClient) Close() error {
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
+
+// ConformanceFailure describes a vector an implementation got wrong
+type ConformanceFailure struct {
+	Vector string `json:"vector"`
+	Op     string `json:"op"`
+	Reason string `json:"reason"`
+}
+
+// ConformanceReport is the outcome of running vectors
+type ConformanceReport struct {
+	Passed   int                  `json:"passed"`
+	Failures []ConformanceFailure `json:"failures,omitempty"`
+}
+
+// OK returns true if every vector passed
+func (r *ConformanceReport) OK() bool {
+	return len(r.Failures) == 0
+}
+
+// RunConformance checks client against vectors: valid frames must decode
+// to their message and re-encode byte for byte, invalid frames must be
+// rejected
+func RunConformance(client ConformanceClient, vectors []ConformanceVector) ConformanceReport {
+	var report ConformanceReport
+	fail := func(v ConformanceVector, op, format string, args ...interface{}) {
+		report.Failures = append(report.Failures, ConformanceFailure{Vector: v.Name, Op: op, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	for _, v := range vectors {
+		frame, err := hex.DecodeString(v.Frame)
+		if err != nil {
+			fail(v, "load", "invalid frame hex: %v", err)
+			continue
+		}
+
+		decoded, err := client.Decode(frame)
+		if v.Error != "" {
+			if err == nil {
+				fail(v, "decode", "expected %s error, frame was accepted", v.Error)
+			} else {
+				report.Passed++
+			}
+			continue
+		}
+		if err != nil {
+			fail(v, "decode", "%v", err)
+			continue
+		}
+		if *decoded != *v.Message {
+			fail(v, "decode", "expected %+v, got %+v", *v.Message, *decoded)
+			continue
+		}
+
+		encoded, err := client.Encode(v.Message)
+		if err != nil {
+			fail(v, "encode", "%v", err)
+			continue
+		}
+		if !bytes.Equal(encoded, frame) {
+			fail(v, "encode", "expected %s, got %s", v.Frame, hex.EncodeToString(encoded))
+			continue
+		}
+		report.Passed++
+	}
+	return report
+}
@@ -0,0 +1,140 @@
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the wire format golden files in testdata")
+
+// checkGolden compares v, as indented JSON, to testdata/name
+func checkGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal %s: %v", name, err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("Failed to update %s: %v", path, err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s (run with -update to create it): %v", path, err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s is out of date with the codec; review the wire format change and run go test -run Golden -update", path)
+	}
+}
+
+func TestWireFormatGolden(t *testing.T) {
+	vectors, err := GenerateConformanceVectors()
+	if err != nil {
+		t.Fatalf("Failed to generate vectors: %v", err)
+	}
+	checkGolden(t, "wire_spec.json", WireSpec())
+	checkGolden(t, "wire_vectors.json", vectors)
+
+	// The published vectors pass against the Go codec
+	loaded, err := LoadConformanceVectors(filepath.Join("testdata", "wire_vectors.json"))
+	if err != nil {
+		t.Fatalf("Failed to load vectors: %v", err)
+	}
+	if report := RunConformance(&GoConformanceClient{}, loaded); !report.OK() || report.Passed != len(loaded) {
+		t.Errorf("Expected the Go codec to pass every vector, got %+v", report)
+	}
+}
+
+// littleEndianClient is a broken implementation reading the type field
+// little-endian
+type littleEndianClient struct {
+	GoConformanceClient
+}
+
+func (c *littleEndianClient) Decode(frame []byte) (*ConformanceMessage, error) {
+	msg, err := c.GoConformanceClient.Decode(frame)
+	if err == nil {
+		msg.Type = binary.LittleEndian.Uint32(frame[0:4])
+	}
+	return msg, err
+}
+
+func TestConformanceCatchesEndianness(t *testing.T) {
+	vectors, err := GenerateConformanceVectors()
+	if err != nil {
+		t.Fatalf("Failed to generate vectors: %v", err)
+	}
+
+	report := RunConformance(&littleEndianClient{}, vectors)
+	if report.OK() {
+		t.Fatal("Expected a little-endian decoder to fail")
+	}
+	for _, failure := range report.Failures {
+		if failure.Op != "decode" {
+			t.Errorf("Expected decode failures only, got %+v", failure)
+		}
+	}
+}
+
+// TestConformanceAdapterProcess is not a real test: it is the adapter
+// program started by TestProcessConformanceClient, speaking the line
+// protocol over the Go codec
+func TestConformanceAdapterProcess(t *testing.T) {
+	if os.Getenv("SNGO_CONFORMANCE_ADAPTER") != "1" {
+		t.Skip("adapter process only")
+	}
+
+	client := &GoConformanceClient{}
+	scanner := bufio.NewScanner(os.Stdin)
+	encoder := json.NewEncoder(os.Stdout)
+	for scanner.Scan() {
+		var req conformanceRequest
+		var resp conformanceResponse
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = err.Error()
+		} else if req.Op == "encode" {
+			frame, err := client.Encode(req.Message)
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			resp.Frame = hex.EncodeToString(frame)
+		} else {
+			frame, _ := hex.DecodeString(req.Frame)
+			msg, err := client.Decode(frame)
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			resp.Message = msg
+		}
+		encoder.Encode(resp)
+	}
+	os.Exit(0)
+}
+
+func TestProcessConformanceClient(t *testing.T) {
+	vectors, err := GenerateConformanceVectors()
+	if err != nil {
+		t.Fatalf("Failed to generate vectors: %v", err)
+	}
+
+	t.Setenv("SNGO_CONFORMANCE_ADAPTER", "1")
+	client, err := StartProcessConformanceClient(os.Args[0], "-test.run=^TestConformanceAdapterProcess$")
+	if err != nil {
+		t.Fatalf("Failed to start adapter: %v", err)
+	}
+	defer client.Close()
+
+	if report := RunConformance(client, vectors); !report.OK() || report.Passed != len(vectors) {
+		t.Errorf("Expected the adapter to pass every vector, got %+v", report)
+	}
+}
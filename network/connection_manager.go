@@ -2,8 +2,10 @@
 package network
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,11 +15,9 @@ type connectionManager struct {
 	mu          sync.RWMutex
 
 	// Heartbeat management
-	heartbeatEnabled  bool
-	heartbeatInterval time.Duration
-	heartbeatTicker   *time.Ticker
-	heartbeatStopChan chan struct{}
-	heartbeatWg       sync.WaitGroup
+	policy    LivenessPolicy
+	liveness  *livenessMonitor
+	deadPeers int64 // closed by stopped monitors
 
 	// Statistics
 	totalConnections int64
@@ -29,6 +29,7 @@ type connectionManager struct {
 func NewConnectionManager() ConnectionManager {
 	return &connectionManager{
 		connections: make(map[string]Connection),
+		policy:      DefaultLivenessPolicy(),
 		startTime:   time.Now(),
 	}
 }
@@ -192,7 +193,8 @@ func (cm *connectionManager) GetConnectionCount() int {
 	return len(cm.connections)
 }
 
-// StartHeartbeat starts heartbeat for all connections
+// StartHeartbeat starts heartbeats for all connections under the liveness
+// policy, closing and removing peers that miss too many of them
 func (cm *connectionManager) StartHeartbeat(interval time.Duration) error {
 	if interval <= 0 {
 		return fmt.Errorf("invalid heartbeat interval: %v", interval)
@@ -201,17 +203,20 @@ func (cm *connectionManager) StartHeartbeat(interval time.Duration) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	if cm.heartbeatEnabled {
+	if cm.liveness != nil {
 		return fmt.Errorf("heartbeat is already running")
 	}
 
-	cm.heartbeatEnabled = true
-	cm.heartbeatInterval = interval
-	cm.heartbeatTicker = time.NewTicker(interval)
-	cm.heartbeatStopChan = make(chan struct{})
+	policy := cm.policy
+	policy.HeartbeatInterval = interval
+	if !policy.UsesHeartbeats() {
+		return fmt.Errorf("liveness mode %s does not use heartbeats", policy.Mode)
+	}
 
-	cm.heartbeatWg.Add(1)
-	go cm.heartbeatLoop()
+	cm.policy = policy
+	cm.liveness = startLivenessMonitor(context.Background(), policy, cm.GetAllConnections, func(conn Connection) {
+		cm.RemoveConnection(conn.ID())
+	})
 
 	return nil
 }
@@ -219,32 +224,26 @@ func (cm *connectionManager) StartHeartbeat(interval time.Duration) error {
 // StopHeartbeat stops heartbeat
 func (cm *connectionManager) StopHeartbeat() error {
 	cm.mu.Lock()
-	defer cm.mu.Unlock()
-
-	if !cm.heartbeatEnabled {
-		return nil // Already stopped
-	}
+	monitor := cm.liveness
+	cm.liveness = nil
+	cm.mu.Unlock()
 
-	cm.heartbeatEnabled = false
-
-	// Stop ticker
-	if cm.heartbeatTicker != nil {
-		cm.heartbeatTicker.Stop()
-		cm.heartbeatTicker = nil
-	}
-
-	// Stop heartbeat loop
-	if cm.heartbeatStopChan != nil {
-		close(cm.heartbeatStopChan)
-		cm.heartbeatStopChan = nil
+	// The monitor reads the connections, so wait for it without the lock
+	if monitor != nil {
+		monitor.stop()
+		atomic.AddInt64(&cm.deadPeers, monitor.DeadPeers())
 	}
 
-	// Wait for heartbeat loop to finish
-	cm.heartbeatWg.Wait()
-
 	return nil
 }
 
+// SetLivenessPolicy sets the policy applied by StartHeartbeat
+func (cm *connectionManager) SetLivenessPolicy(policy LivenessPolicy) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.policy = policy
+}
+
 // Cleanup removes inactive connections
 func (cm *connectionManager) Cleanup(timeout time.Duration) int {
 	if timeout <= 0 {
@@ -295,8 +294,10 @@ func (cm *connectionManager) GetStatistics() ConnectionManagerStatistics {
 		ConnectionsByState: stateCount,
 		TotalBytes:         totalBytes,
 		TotalMessages:      totalMessages,
-		HeartbeatEnabled:   cm.heartbeatEnabled,
-		HeartbeatInterval:  cm.heartbeatInterval,
+		HeartbeatEnabled:   cm.liveness != nil,
+		HeartbeatInterval:  cm.policy.HeartbeatInterval,
+		LivenessMode:       cm.policy.Mode,
+		DeadPeers:          atomic.LoadInt64(&cm.deadPeers) + cm.liveness.DeadPeers(),
 		StartTime:          cm.startTime,
 		Uptime:             time.Since(cm.startTime),
 	}
@@ -349,15 +350,14 @@ func (cm *connectionManager) CloseAllConnections() error {
 	// Clear the connections map
 	cm.connections = make(map[string]Connection)
 
-	// Stop heartbeat if running
-	if cm.heartbeatEnabled {
-		cm.heartbeatEnabled = false
-		if cm.heartbeatTicker != nil {
-			cm.heartbeatTicker.Stop()
-		}
-		if cm.heartbeatStopChan != nil {
-			close(cm.heartbeatStopChan)
-		}
+	// Stop heartbeat if running; the monitor exits on its own once the
+	// lock is released
+	if monitor := cm.liveness; monitor != nil {
+		cm.liveness = nil
+		go func() {
+			monitor.stop()
+			atomic.AddInt64(&cm.deadPeers, monitor.DeadPeers())
+		}()
 	}
 
 	if len(errors) > 0 {
@@ -367,37 +367,6 @@ func (cm *connectionManager) CloseAllConnections() error {
 	return nil
 }
 
-// Private methods
-
-// heartbeatLoop sends periodic heartbeat messages
-func (cm *connectionManager) heartbeatLoop() {
-	defer cm.heartbeatWg.Done()
-
-	heartbeatMsg := NewHeartbeatMessage()
-
-	for {
-		select {
-		case <-cm.heartbeatTicker.C:
-			cm.sendHeartbeatToAll(heartbeatMsg)
-		case <-cm.heartbeatStopChan:
-			return
-		}
-	}
-}
-
-// sendHeartbeatToAll sends heartbeat to all active connections
-func (cm *connectionManager) sendHeartbeatToAll(msg *Message) {
-	connections := cm.GetConnectionsByState(ConnectionStateConnected)
-
-	for _, conn := range connections {
-		go func(c Connection) {
-			if err := c.SendMessage(msg); err != nil {
-				// Connection error, it will be cleaned up in the next cleanup cycle
-			}
-		}(conn)
-	}
-}
-
 // ConnectionManagerStatistics holds statistics for the connection manager
 type ConnectionManagerStatistics struct {
 	TotalConnections   int64                   `json:"total_connections"`
@@ -407,6 +376,8 @@ type ConnectionManagerStatistics struct {
 	TotalMessages      int64                   `json:"total_messages"`
 	HeartbeatEnabled   bool                    `json:"heartbeat_enabled"`
 	HeartbeatInterval  time.Duration           `json:"heartbeat_interval"`
+	LivenessMode       LivenessMode            `json:"liveness_mode"`
+	DeadPeers          int64                   `json:"dead_peers"`
 	StartTime          time.Time               `json:"start_time"`
 	Uptime             time.Duration           `json:"uptime"`
 }
@@ -0,0 +1,288 @@
+// Package network provides per-route message dispatching
+package network
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/najoast/sngo/core"
+)
+
+// ErrFallthrough is returned by a route handler to pass the message on to
+// the next matching route: method, type, type range, then default
+var ErrFallthrough = errors.New("fall through to the next route")
+
+// RouteHandler handles the messages of one route
+type RouteHandler func(conn Connection, msg *Message) error
+
+// RouteStats reports the traffic of one route
+type RouteStats struct {
+	Route     string        `json:"route"`
+	Messages  int64         `json:"messages"`
+	Errors    int64         `json:"errors"`
+	Panics    int64         `json:"panics"`
+	TotalTime time.Duration `json:"total_time"`
+}
+
+// MeanTime returns the mean handling time of the route
+func (s RouteStats) MeanTime() time.Duration {
+	if s.Messages == 0 {
+		return 0
+	}
+	return s.TotalTime / time.Duration(s.Messages)
+}
+
+// route is a registered handler with its counters
+type route struct {
+	name     string
+	handler  RouteHandler
+	messages int64 // atomic
+	errors   int64 // atomic
+	panics   int64 // atomic
+	nanos    int64 // atomic
+}
+
+// typeRange routes an inclusive range of message types, e.g. the opcodes
+// of one game subsystem
+type typeRange struct {
+	lo, hi MessageType
+	route  *route
+}
+
+// MessageDispatcher is a MessageHandler routing each message to handlers
+// registered per RPC method, message type or type range, falling back to
+// a default handler. A panicking handler is recovered and reported as an
+// error without affecting other routes or the connection.
+type MessageDispatcher struct {
+	mu       sync.RWMutex
+	methods  map[string]*route
+	types    map[MessageType]*route
+	ranges   []typeRange
+	fallback *route
+	method   func(msg *Message) string
+	onError  func(conn Connection, err error)
+}
+
+// NewMessageDispatcher creates an empty dispatcher. RPC messages are
+// matched to method routes by their Destination.
+func NewMessageDispatcher() *MessageDispatcher {
+	return &MessageDispatcher{
+		methods: make(map[string]*route),
+		types:   make(map[MessageType]*route),
+		method: func(msg *Message) string {
+			if msg.Type != MessageTypeRPC {
+				return ""
+			}
+			return msg.Destination
+		},
+	}
+}
+
+// Handle registers the handler for a message type
+func (d *MessageDispatcher) Handle(msgType MessageType, handler RouteHandler) error {
+	if handler == nil {
+		return fmt.Errorf("handler for message type %d is nil", msgType)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.types[msgType]; exists {
+		return fmt.Errorf("message type %d already has a handler", msgType)
+	}
+	d.types[msgType] = &route{name: fmt.Sprintf("type:%d", msgType), handler: handler}
+	return nil
+}
+
+// HandleRange registers the handler for message types lo through hi;
+// a type with its own handler takes precedence
+func (d *MessageDispatcher) HandleRange(lo, hi MessageType, handler RouteHandler) error {
+	if handler == nil {
+		return fmt.Errorf("handler for message types %d-%d is nil", lo, hi)
+	}
+	if lo > hi {
+		return fmt.Errorf("invalid message type range %d-%d", lo, hi)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, r := range d.ranges {
+		if lo <= r.hi && r.lo <= hi {
+			return fmt.Errorf("message types %d-%d overlap range %d-%d", lo, hi, r.lo, r.hi)
+		}
+	}
+
+	d.ranges = append(d.ranges, typeRange{
+		lo:    lo,
+		hi:    hi,
+		route: &route{name: fmt.Sprintf("range:%d-%d", lo, hi), handler: handler},
+	})
+	sort.Slice(d.ranges, func(i, j int) bool { return d.ranges[i].lo < d.ranges[j].lo })
+	return nil
+}
+
+// HandleMethod registers the handler for an RPC method; it takes
+// precedence over the handler of the message's type
+func (d *MessageDispatcher) HandleMethod(method string, handler RouteHandler) error {
+	if handler == nil {
+		return fmt.Errorf("handler for method %q is nil", method)
+	}
+	if method == "" {
+		return fmt.Errorf("method name is empty")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.methods[method]; exists {
+		return fmt.Errorf("method %q already has a handler", method)
+	}
+	d.methods[method] = &route{name: "method:" + method, handler: handler}
+	return nil
+}
+
+// HandleDefault registers the handler for messages no other route takes
+func (d *MessageDispatcher) HandleDefault(handler RouteHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if handler == nil {
+		d.fallback = nil
+		return
+	}
+	d.fallback = &route{name: "default", handler: handler}
+}
+
+// SetMethodFunc changes how the RPC method of a message is found, for
+// applications encoding it in the payload; "" means no method
+func (d *MessageDispatcher) SetMethodFunc(method func(msg *Message) string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.method = method
+}
+
+// SetErrorHandler sets the callback receiving handler errors and panics
+// and unrouted messages; by default they are logged
+func (d *MessageDispatcher) SetErrorHandler(onError func(conn Connection, err error)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.onError = onError
+}
+
+// OnMessage routes the message to the first matching route that does not
+// fall through
+func (d *MessageDispatcher) OnMessage(conn Connection, msg *Message) {
+	for _, r := range d.match(msg) {
+		err := d.run(r, conn, msg)
+		if errors.Is(err, ErrFallthrough) {
+			continue
+		}
+		if err != nil {
+			d.OnError(conn, fmt.Errorf("route %s: %w", r.name, err))
+		}
+		return
+	}
+
+	d.OnError(conn, fmt.Errorf("no route for message type %d", msg.Type))
+}
+
+// OnError reports a message processing error
+func (d *MessageDispatcher) OnError(conn Connection, err error) {
+	d.mu.RLock()
+	onError := d.onError
+	d.mu.RUnlock()
+
+	if onError != nil {
+		onError(conn, err)
+		return
+	}
+	if conn != nil {
+		err = fmt.Errorf("connection %s: %w", conn.ID(), err)
+	}
+	core.ReportError("network", "dispatcher", core.SeverityWarning, err)
+}
+
+// Stats returns the counters of every route, sorted by name
+func (d *MessageDispatcher) Stats() []RouteStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var routes []*route
+	for _, r := range d.methods {
+		routes = append(routes, r)
+	}
+	for _, r := range d.types {
+		routes = append(routes, r)
+	}
+	for _, r := range d.ranges {
+		routes = append(routes, r.route)
+	}
+	if d.fallback != nil {
+		routes = append(routes, d.fallback)
+	}
+
+	stats := make([]RouteStats, 0, len(routes))
+	for _, r := range routes {
+		stats = append(stats, RouteStats{
+			Route:     r.name,
+			Messages:  atomic.LoadInt64(&r.messages),
+			Errors:    atomic.LoadInt64(&r.errors),
+			Panics:    atomic.LoadInt64(&r.panics),
+			TotalTime: time.Duration(atomic.LoadInt64(&r.nanos)),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Route < stats[j].Route })
+	return stats
+}
+
+// match returns the routes matching a message, most specific first
+func (d *MessageDispatcher) match(msg *Message) []*route {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	routes := make([]*route, 0, 4)
+	if d.method != nil {
+		if method := d.method(msg); method != "" {
+			if r, ok := d.methods[method]; ok {
+				routes = append(routes, r)
+			}
+		}
+	}
+	if r, ok := d.types[msg.Type]; ok {
+		routes = append(routes, r)
+	}
+	i := sort.Search(len(d.ranges), func(i int) bool { return d.ranges[i].hi >= msg.Type })
+	if i < len(d.ranges) && d.ranges[i].lo <= msg.Type {
+		routes = append(routes, d.ranges[i].route)
+	}
+	if d.fallback != nil {
+		routes = append(routes, d.fallback)
+	}
+	return routes
+}
+
+// run calls a route handler, turning a panic into an error
+func (d *MessageDispatcher) run(r *route, conn Connection, msg *Message) (err error) {
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panicked: %v", p)
+			atomic.AddInt64(&r.panics, 1)
+		}
+
+		atomic.AddInt64(&r.messages, 1)
+		atomic.AddInt64(&r.nanos, int64(time.Since(start)))
+		if err != nil && !errors.Is(err, ErrFallthrough) {
+			atomic.AddInt64(&r.errors, 1)
+		}
+	}()
+
+	return r.handler(conn, msg)
+}
@@ -0,0 +1,88 @@
+// Package network provides tests for per-route message dispatching
+package network
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMessageDispatcherRoutes(t *testing.T) {
+	d := NewMessageDispatcher()
+	var got []string
+	record := func(name string, err error) RouteHandler {
+		return func(conn Connection, msg *Message) error {
+			got = append(got, name)
+			return err
+		}
+	}
+
+	if err := d.HandleMethod("login", record("login", nil)); err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+	d.Handle(MessageTypeRPC, record("rpc", nil))
+	d.Handle(1005, record("type", ErrFallthrough))
+	d.HandleRange(1000, 1999, record("range", nil))
+	d.HandleDefault(record("default", nil))
+
+	if err := d.Handle(MessageTypeRPC, record("dup", nil)); err == nil {
+		t.Error("Expected duplicate type to be rejected")
+	}
+	if err := d.HandleRange(1500, 2500, record("overlap", nil)); err == nil {
+		t.Error("Expected overlapping range to be rejected")
+	}
+
+	d.OnMessage(nil, NewRPCMessage("client", "login", nil))
+	d.OnMessage(nil, NewRPCMessage("client", "logout", nil))
+	d.OnMessage(nil, NewMessage(1005, nil))
+	d.OnMessage(nil, NewMessage(1999, nil))
+	d.OnMessage(nil, NewMessage(2000, nil))
+
+	want := "login rpc type range range default"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Expected routes %q, got %q", want, strings.Join(got, " "))
+	}
+}
+
+func TestMessageDispatcherIsolatesPanics(t *testing.T) {
+	d := NewMessageDispatcher()
+	var errs []error
+	d.SetErrorHandler(func(conn Connection, err error) { errs = append(errs, err) })
+
+	d.Handle(MessageTypeData, func(conn Connection, msg *Message) error {
+		panic("boom")
+	})
+	failure := errors.New("bad request")
+	d.Handle(MessageTypeRPC, func(conn Connection, msg *Message) error {
+		return failure
+	})
+
+	d.OnMessage(nil, NewMessage(MessageTypeData, nil))
+	d.OnMessage(nil, NewMessage(MessageTypeRPC, nil))
+	d.OnMessage(nil, NewMessage(MessageTypeBroadcast, nil))
+
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, got %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "panicked: boom") {
+		t.Errorf("Expected panic error, got %v", errs[0])
+	}
+	if !errors.Is(errs[1], failure) {
+		t.Errorf("Expected handler error, got %v", errs[1])
+	}
+	if !strings.Contains(errs[2].Error(), "no route") {
+		t.Errorf("Expected unrouted error, got %v", errs[2])
+	}
+
+	stats := d.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 routes, got %+v", stats)
+	}
+	// Sorted by name: type:101 (RPC), type:102 (data)
+	if stats[0].Messages != 1 || stats[0].Errors != 1 || stats[0].Panics != 0 {
+		t.Errorf("Unexpected RPC stats: %+v", stats[0])
+	}
+	if stats[1].Messages != 1 || stats[1].Errors != 1 || stats[1].Panics != 1 {
+		t.Errorf("Unexpected data stats: %+v", stats[1])
+	}
+}
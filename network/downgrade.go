@@ -0,0 +1,352 @@
+// Package network provides protocol downgrade ladders for clients
+package network
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FramingJSON frames each message as its JSON encoding behind a 4-byte
+// big-endian length, for clients without a binary codec
+const FramingJSON Framing = "json"
+
+// maxJSONFrameSize bounds a JSON frame: the base64 payload plus its header
+// fields
+const maxJSONFrameSize = MaxDataSize/3*4 + 1024
+
+// ErrDowngradeExhausted is returned when no rung of a downgrade ladder
+// could connect
+var ErrDowngradeExhausted = errors.New("every transport of the downgrade ladder failed")
+
+// encodeJSONFrame encodes msg as a length-prefixed JSON frame
+func encodeJSONFrame(msg *Message) ([]byte, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode json frame: %w", err)
+	}
+	if len(body) > maxJSONFrameSize {
+		return nil, fmt.Errorf("json frame too large: %d bytes (max %d)", len(body), maxJSONFrameSize)
+	}
+	frame := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(len(body)))
+	copy(frame[4:], body)
+	return frame, nil
+}
+
+// readJSONFrame reads a single JSON frame
+func (tc *tcpConnection) readJSONFrame() (*Message, error) {
+	var size [4]byte
+	if _, err := tc.readFull(size[:]); err != nil {
+		return nil, fmt.Errorf("failed to read frame size: %w", err)
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxJSONFrameSize {
+		return nil, fmt.Errorf("json frame too large: %d bytes (max %d)", n, maxJSONFrameSize)
+	}
+	body := make([]byte, n)
+	if _, err := tc.readFull(body); err != nil {
+		return nil, fmt.Errorf("failed to read frame: %w", err)
+	}
+
+	msg := &Message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, fmt.Errorf("failed to decode json frame: %w", err)
+	}
+
+	atomic.AddInt64(&tc.messagesRead, 1)
+	if tc.usage != nil {
+		tc.usage.RecordInbound(msg.Type, len(size)+len(body))
+	}
+	tc.updateActivity()
+	atomic.StoreInt64(&tc.lastRead, time.Now().UnixNano())
+	msg.ConnectionID = tc.id
+	return msg, nil
+}
+
+// DowngradeRung is one transport and codec combination of a downgrade
+// ladder, e.g. QUIC with binary frames or TCP with JSON frames
+type DowngradeRung struct {
+	// Name identifies the rung in reports, e.g. "tcp+json"
+	Name string
+
+	// Protocol and Framing configure the client of the rung
+	Protocol Protocol
+	Framing  Framing
+
+	// Address overrides the address passed to Connect, for servers
+	// offering each combination on its own port
+	Address string
+
+	// NewClient creates the client of the rung from the ladder's config
+	// with Protocol and Framing applied. Transports outside this package,
+	// such as QUIC, plug in here; nil uses the built-in stream client,
+	// which supports tcp and unix.
+	NewClient func(config *NetworkConfig) (Client, error)
+
+	// Verify checks a new connection really works, e.g. with
+	// RoundTripVerifier, so a codec the server does not speak downgrades
+	// too; it runs before the message handler is installed. nil accepts
+	// any connection.
+	Verify func(conn Connection) error
+}
+
+// DefaultDowngradeLadder returns binary then JSON frames over the
+// protocol of config, each verified by a heartbeat round trip
+func DefaultDowngradeLadder(config *NetworkConfig) []DowngradeRung {
+	verify := RoundTripVerifier(NewHeartbeatMessage(), 5*time.Second)
+	return []DowngradeRung{
+		{Name: string(config.Protocol) + "+binary", Protocol: config.Protocol, Framing: FramingSNGO, Verify: verify},
+		{Name: string(config.Protocol) + "+json", Protocol: config.Protocol, Framing: FramingJSON, Verify: verify},
+	}
+}
+
+// RoundTripVerifier returns a Verify function sending probe and expecting
+// any message back within timeout. Servers must answer the probe, e.g. by
+// echoing it.
+func RoundTripVerifier(probe *Message, timeout time.Duration) func(conn Connection) error {
+	return func(conn Connection) error {
+		copied := *probe
+		if err := conn.SendMessage(&copied); err != nil {
+			return fmt.Errorf("failed to send probe: %w", err)
+		}
+
+		conn.SetReadTimeout(timeout)
+		defer conn.SetReadTimeout(0)
+		if _, err := conn.ReadMessage(); err != nil {
+			return fmt.Errorf("no answer to probe: %w", err)
+		}
+		return nil
+	}
+}
+
+// DowngradeAttempt records one rung tried by a downgrade ladder
+type DowngradeAttempt struct {
+	Rung     string        `json:"rung"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// DowngradeReport records how the last connect of a downgrade ladder went
+type DowngradeReport struct {
+	// Rung is the name of the rung connected, empty if none did
+	Rung     string             `json:"rung,omitempty"`
+	Attempts []DowngradeAttempt `json:"attempts"`
+}
+
+// DowngradeClient is a Client connecting through the first rung of a
+// downgrade ladder that works; every other call goes to the client of
+// that rung
+type DowngradeClient struct {
+	config *NetworkConfig
+	rungs  []DowngradeRung
+
+	mu                sync.RWMutex
+	active            Client
+	msgHandler        MessageHandler
+	autoReconnect     bool
+	reconnectInterval time.Duration
+	report            DowngradeReport
+}
+
+// NewDowngradeClient creates a client trying rungs in order on each
+// connect
+func NewDowngradeClient(config *NetworkConfig, rungs ...DowngradeRung) (*DowngradeClient, error) {
+	if config == nil {
+		config = DefaultNetworkConfig()
+	}
+	if len(rungs) == 0 {
+		return nil, fmt.Errorf("downgrade ladder has no rungs")
+	}
+	for i, rung := range rungs {
+		if rung.NewClient == nil && rung.Protocol != ProtocolTCP && rung.Protocol != ProtocolUnix {
+			return nil, fmt.Errorf("rung %d (%s): protocol %s needs a NewClient", i, rung.Name, rung.Protocol)
+		}
+	}
+	return &DowngradeClient{config: config, rungs: rungs}, nil
+}
+
+// Connect connects to the remote server
+func (dc *DowngradeClient) Connect(address string) (Connection, error) {
+	return dc.ConnectWithTimeout(address, 30*time.Second)
+}
+
+// ConnectWithTimeout tries each rung in order, each within timeout, and
+// keeps the first that connects and passes its Verify
+func (dc *DowngradeClient) ConnectWithTimeout(address string, timeout time.Duration) (Connection, error) {
+	dc.mu.RLock()
+	handler := dc.msgHandler
+	autoReconnect, interval := dc.autoReconnect, dc.reconnectInterval
+	dc.mu.RUnlock()
+
+	var report DowngradeReport
+	var errs []error
+	for _, rung := range dc.rungs {
+		start := time.Now()
+		client, conn, err := dc.connectRung(rung, address, timeout)
+		attempt := DowngradeAttempt{Rung: rung.Name, Duration: time.Since(start)}
+		if err != nil {
+			attempt.Error = err.Error()
+			report.Attempts = append(report.Attempts, attempt)
+			errs = append(errs, fmt.Errorf("%s: %w", rung.Name, err))
+			continue
+		}
+		report.Attempts = append(report.Attempts, attempt)
+		report.Rung = rung.Name
+
+		if handler != nil {
+			client.SetMessageHandler(handler)
+		}
+		if autoReconnect {
+			client.SetAutoReconnect(true, interval)
+		}
+
+		dc.mu.Lock()
+		previous := dc.active
+		dc.active, dc.report = client, report
+		dc.mu.Unlock()
+		if previous != nil {
+			previous.Disconnect()
+		}
+		return conn, nil
+	}
+
+	dc.mu.Lock()
+	dc.report = report
+	dc.mu.Unlock()
+	return nil, fmt.Errorf("%w: %w", ErrDowngradeExhausted, errors.Join(errs...))
+}
+
+// connectRung connects and verifies the client of one rung
+func (dc *DowngradeClient) connectRung(rung DowngradeRung, address string, timeout time.Duration) (Client, Connection, error) {
+	config := *dc.config
+	config.Protocol = rung.Protocol
+	config.Framing = rung.Framing
+
+	var client Client
+	var err error
+	if rung.NewClient != nil {
+		client, err = rung.NewClient(&config)
+	} else {
+		client = newStreamClient(&config)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	if rung.Address != "" {
+		address = rung.Address
+	}
+	conn, err := client.ConnectWithTimeout(address, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rung.Verify != nil {
+		if err := rung.Verify(conn); err != nil {
+			client.Disconnect()
+			return nil, nil, err
+		}
+	}
+	return client, conn, nil
+}
+
+// Report returns how the last connect went
+func (dc *DowngradeClient) Report() DowngradeReport {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	report := dc.report
+	report.Attempts = append([]DowngradeAttempt(nil), report.Attempts...)
+	return report
+}
+
+// activeClient returns the client of the connected rung, or nil
+func (dc *DowngradeClient) activeClient() Client {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+	return dc.active
+}
+
+// ConnectAsync connects asynchronously
+func (dc *DowngradeClient) ConnectAsync(address string) <-chan ConnectionResult {
+	resultChan := make(chan ConnectionResult, 1)
+
+	go func() {
+		conn, err := dc.Connect(address)
+		resultChan <- ConnectionResult{Connection: conn, Error: err}
+		close(resultChan)
+	}()
+
+	return resultChan
+}
+
+// Disconnect disconnects the client of the connected rung
+func (dc *DowngradeClient) Disconnect() error {
+	dc.mu.Lock()
+	active := dc.active
+	dc.active = nil
+	dc.mu.Unlock()
+
+	if active == nil {
+		return nil
+	}
+	return active.Disconnect()
+}
+
+// GetConnection returns the connection of the connected rung
+func (dc *DowngradeClient) GetConnection() Connection {
+	if active := dc.activeClient(); active != nil {
+		return active.GetConnection()
+	}
+	return nil
+}
+
+// SetAutoReconnect enables auto reconnection on the connected rung; a
+// reconnect stays on that rung
+func (dc *DowngradeClient) SetAutoReconnect(enabled bool, interval time.Duration) {
+	dc.mu.Lock()
+	dc.autoReconnect, dc.reconnectInterval = enabled, interval
+	dc.mu.Unlock()
+
+	if active := dc.activeClient(); active != nil {
+		active.SetAutoReconnect(enabled, interval)
+	}
+}
+
+// SetMessageHandler sets the handler for incoming messages
+func (dc *DowngradeClient) SetMessageHandler(handler MessageHandler) {
+	dc.mu.Lock()
+	dc.msgHandler = handler
+	dc.mu.Unlock()
+
+	if active := dc.activeClient(); active != nil {
+		active.SetMessageHandler(handler)
+	}
+}
+
+// IsConnected returns true if a rung is connected
+func (dc *DowngradeClient) IsConnected() bool {
+	active := dc.activeClient()
+	return active != nil && active.IsConnected()
+}
+
+// GetStatistics returns the statistics of the connected rung
+func (dc *DowngradeClient) GetStatistics() ClientStatistics {
+	if active := dc.activeClient(); active != nil {
+		return active.GetStatistics()
+	}
+	return ClientStatistics{}
+}
+
+// SendMessage sends a message through the connected rung
+func (dc *DowngradeClient) SendMessage(msg *Message) error {
+	active := dc.activeClient()
+	if active == nil {
+		return fmt.Errorf("client not connected")
+	}
+	return active.SendMessage(msg)
+}
@@ -0,0 +1,161 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newFramingEchoServer starts an echo server speaking one framing
+func newFramingEchoServer(t *testing.T, pn *PipeNetwork, address string, framing Framing, checksum, timestamps bool) Server {
+	t.Helper()
+	config := DefaultNetworkConfig()
+	config.Address = address
+	config.Port = 1
+	config.Framing = framing
+	config.FrameChecksum = checksum
+	config.FrameTimestamps = timestamps
+
+	server, err := NewPipeServer(pn, config)
+	if err != nil {
+		t.Fatalf("Failed to create pipe server: %v", err)
+	}
+	server.SetMessageHandler(&testMessageHandler{
+		onMessage: func(conn Connection, msg *Message) {
+			conn.SendMessage(NewMessage(MessageTypeData, msg.Data))
+		},
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start pipe server: %v", err)
+	}
+	return server
+}
+
+// pipeRung is a rung dialing the in-memory network
+func pipeRung(pn *PipeNetwork, framing Framing) DowngradeRung {
+	name := "binary"
+	if framing == FramingJSON {
+		name = "json"
+	}
+	return DowngradeRung{
+		Name:      name,
+		Protocol:  ProtocolTCP,
+		Framing:   framing,
+		NewClient: func(config *NetworkConfig) (Client, error) { return NewPipeClient(pn, config) },
+		Verify:    RoundTripVerifier(NewMessage(MessageTypeData, []byte("probe")), 100*time.Millisecond),
+	}
+}
+
+// TestDowngradeCompatibilityMatrix connects every client ladder to every
+// server codec, with and without timestamps on either side and checksums
+// on both, and checks the ladder lands on the first rung the server speaks
+func TestDowngradeCompatibilityMatrix(t *testing.T) {
+	framings := []Framing{FramingSNGO, FramingJSON}
+	ladders := map[string][]Framing{
+		"binary":      {FramingSNGO},
+		"json":        {FramingJSON},
+		"binary,json": {FramingSNGO, FramingJSON},
+		"json,binary": {FramingJSON, FramingSNGO},
+	}
+	extensions := []struct{ checksum, timestamps bool }{{false, false}, {true, false}, {false, true}, {true, true}}
+
+	for _, serverFraming := range framings {
+		for _, serverExt := range extensions {
+			for name, ladder := range ladders {
+				for _, clientExt := range extensions {
+					if clientExt.checksum != serverExt.checksum {
+						continue // Checksums are a setting both ends share
+					}
+					serverFraming, serverExt, ladder, clientExt := serverFraming, serverExt, ladder, clientExt
+					label := fmt.Sprintf("server=%q%+v/client=%s%+v", serverFraming, serverExt, name, clientExt)
+					t.Run(label, func(t *testing.T) {
+						t.Parallel()
+						pn := NewPipeNetwork()
+						server := newFramingEchoServer(t, pn, "game", serverFraming, serverExt.checksum, serverExt.timestamps)
+						defer server.Stop()
+
+						config := DefaultNetworkConfig()
+						config.FrameChecksum = clientExt.checksum
+						config.FrameTimestamps = clientExt.timestamps
+						var rungs []DowngradeRung
+						want := ""
+						for _, framing := range ladder {
+							rung := pipeRung(pn, framing)
+							rungs = append(rungs, rung)
+							if framing == serverFraming && want == "" {
+								want = rung.Name
+							}
+						}
+						client, err := NewDowngradeClient(config, rungs...)
+						if err != nil {
+							t.Fatalf("Failed to create client: %v", err)
+						}
+						defer client.Disconnect()
+
+						conn, err := client.ConnectWithTimeout("game:1", time.Second)
+						if want == "" {
+							if !errors.Is(err, ErrDowngradeExhausted) {
+								t.Fatalf("Expected ErrDowngradeExhausted, got %v", err)
+							}
+							return
+						}
+						if err != nil {
+							t.Fatalf("Expected to connect over %s, got %v (%+v)", want, err, client.Report())
+						}
+						if report := client.Report(); report.Rung != want {
+							t.Fatalf("Expected rung %s, got %+v", want, report)
+						}
+
+						if err := conn.SendMessage(NewMessage(MessageTypeData, []byte("move"))); err != nil {
+							t.Fatalf("Failed to send: %v", err)
+						}
+						conn.SetReadTimeout(time.Second)
+						reply, err := conn.ReadMessage()
+						if err != nil || string(reply.Data) != "move" {
+							t.Errorf("Expected the echo, got %v (%v)", reply, err)
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestDowngradeClientHandsOverHandler(t *testing.T) {
+	pn := NewPipeNetwork()
+	server := newFramingEchoServer(t, pn, "game", FramingJSON, false, false)
+	defer server.Stop()
+
+	if _, err := NewDowngradeClient(nil, DowngradeRung{Name: "quic", Protocol: "quic"}); err == nil {
+		t.Error("Expected a rung of an unknown protocol refused without a NewClient")
+	}
+
+	client, err := NewDowngradeClient(nil, pipeRung(pn, FramingSNGO), pipeRung(pn, FramingJSON))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Disconnect()
+
+	replies := make(chan *Message, 1)
+	client.SetMessageHandler(&testMessageHandler{onMessage: func(conn Connection, msg *Message) { replies <- msg }})
+	if _, err := client.ConnectWithTimeout("game:1", time.Second); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	report := client.Report()
+	if len(report.Attempts) != 2 || report.Attempts[0].Error == "" || report.Rung != "json" {
+		t.Errorf("Expected a failed binary attempt then json, got %+v", report)
+	}
+
+	if err := client.SendMessage(NewMessage(MessageTypeData, []byte("chat"))); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	select {
+	case reply := <-replies:
+		if string(reply.Data) != "chat" {
+			t.Errorf("Unexpected reply %q", reply.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the handler to receive the reply")
+	}
+}
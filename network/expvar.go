@@ -0,0 +1,197 @@
+// Package network exports statistics as expvar variables
+package network
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultExpvarName is the expvar variable the default exporter publishes
+const DefaultExpvarName = "sngo_network"
+
+// ExpvarExporter publishes the statistics of servers, clients, connection
+// managers and dispatchers as one expvar map, e.g.
+//
+//	{"servers": {"gate": {...}}, "clients": {...}, ...}
+//
+// Statistics are read when the variable is rendered, so every request to
+// /debug/vars sees current values without a collection loop.
+type ExpvarExporter struct {
+	mu       sync.RWMutex
+	sections map[string]map[string]func() interface{}
+}
+
+var (
+	exportersMu sync.Mutex
+	exporters   = make(map[string]*ExpvarExporter)
+)
+
+// NewExpvarExporter returns the exporter published under name, creating
+// and publishing it on first use. expvar names are global, so exporters
+// of the same name are shared.
+func NewExpvarExporter(name string) *ExpvarExporter {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+
+	if exporter, ok := exporters[name]; ok {
+		return exporter
+	}
+	exporter := &ExpvarExporter{sections: make(map[string]map[string]func() interface{})}
+	expvar.Publish(name, expvar.Func(exporter.snapshot))
+	exporters[name] = exporter
+	return exporter
+}
+
+// AddServer exports the statistics of a server under servers.<name>
+func (e *ExpvarExporter) AddServer(name string, server Server) {
+	e.add("servers", name, func() interface{} { return server.GetStatistics() })
+}
+
+// AddClient exports the statistics of a client under clients.<name>
+func (e *ExpvarExporter) AddClient(name string, client Client) {
+	e.add("clients", name, func() interface{} { return client.GetStatistics() })
+}
+
+// AddConnectionManager exports the statistics of a connection manager
+// under connection_managers.<name>
+func (e *ExpvarExporter) AddConnectionManager(name string, manager ConnectionManager) {
+	e.add("connection_managers", name, func() interface{} { return manager.GetStatistics() })
+}
+
+// AddDispatcher exports the route statistics of a dispatcher under
+// dispatchers.<name>
+func (e *ExpvarExporter) AddDispatcher(name string, dispatcher *MessageDispatcher) {
+	e.add("dispatchers", name, func() interface{} { return dispatcher.Stats() })
+}
+
+// AddFunc exports the value returned by fn under section.<name>; fn is
+// called on every render and must be safe for concurrent use
+func (e *ExpvarExporter) AddFunc(section, name string, fn func() interface{}) {
+	e.add(section, name, fn)
+}
+
+// Remove stops exporting an entry
+func (e *ExpvarExporter) Remove(section, name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.sections[section], name)
+	if len(e.sections[section]) == 0 {
+		delete(e.sections, section)
+	}
+}
+
+func (e *ExpvarExporter) add(section, name string, fn func() interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.sections[section] == nil {
+		e.sections[section] = make(map[string]func() interface{})
+	}
+	e.sections[section][name] = fn
+}
+
+// snapshot reads the current statistics of every entry
+func (e *ExpvarExporter) snapshot() interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	out := make(map[string]map[string]interface{}, len(e.sections))
+	for section, entries := range e.sections {
+		values := make(map[string]interface{}, len(entries))
+		for name, fn := range entries {
+			values[name] = fn()
+		}
+		out[section] = values
+	}
+	return out
+}
+
+// VarsHandler serves all published expvar variables as JSON, in the
+// format of the standard /debug/vars endpoint
+func VarsHandler() http.Handler {
+	return expvar.Handler()
+}
+
+// MonitorServer is a small HTTP server exposing /debug/vars and a health
+// endpoint, for environments without a metrics stack
+type MonitorServer struct {
+	address    string
+	mux        *http.ServeMux
+	running    int32
+	listener   net.Listener
+	httpServer *http.Server
+	wg         sync.WaitGroup
+}
+
+// NewMonitorServer creates a monitor server serving the expvar variables
+// on varsPath (default /debug/vars) and an OK on healthPath (default
+// /health)
+func NewMonitorServer(address, varsPath, healthPath string) *MonitorServer {
+	if varsPath == "" {
+		varsPath = "/debug/vars"
+	}
+	if healthPath == "" {
+		healthPath = "/health"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(varsPath, VarsHandler())
+	mux.HandleFunc(healthPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	return &MonitorServer{address: address, mux: mux}
+}
+
+// Handle adds an endpoint to the monitor server; call it before Start
+func (ms *MonitorServer) Handle(pattern string, handler http.Handler) {
+	ms.mux.Handle(pattern, handler)
+}
+
+// Start starts serving on the configured address
+func (ms *MonitorServer) Start() error {
+	if !atomic.CompareAndSwapInt32(&ms.running, 0, 1) {
+		return fmt.Errorf("monitor server is already running")
+	}
+
+	listener, err := net.Listen("tcp", ms.address)
+	if err != nil {
+		atomic.StoreInt32(&ms.running, 0)
+		return fmt.Errorf("failed to listen on %s: %w", ms.address, err)
+	}
+
+	ms.listener = listener
+	ms.httpServer = &http.Server{Handler: ms.mux, ReadHeaderTimeout: 5 * time.Second}
+
+	ms.wg.Add(1)
+	go func() {
+		defer ms.wg.Done()
+		ms.httpServer.Serve(listener)
+	}()
+
+	fmt.Printf("Monitor server started on %s\n", listener.Addr())
+	return nil
+}
+
+// Addr returns the listening address, or nil before Start
+func (ms *MonitorServer) Addr() net.Addr {
+	if ms.listener == nil {
+		return nil
+	}
+	return ms.listener.Addr()
+}
+
+// Stop stops the server
+func (ms *MonitorServer) Stop() error {
+	if atomic.CompareAndSwapInt32(&ms.running, 1, 0) {
+		ms.httpServer.Close()
+	}
+	ms.wg.Wait()
+	return nil
+}
@@ -0,0 +1,81 @@
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestExpvarExporterServesCurrentStats(t *testing.T) {
+	exporter := NewExpvarExporter("sngo_network_test")
+	if NewExpvarExporter("sngo_network_test") != exporter {
+		t.Fatal("Expected exporters of the same name to be shared")
+	}
+
+	manager := NewConnectionManager()
+	exporter.AddConnectionManager("main", manager)
+	dispatcher := NewMessageDispatcher()
+	dispatcher.Handle(MessageTypeData, func(conn Connection, msg *Message) error { return nil })
+	exporter.AddDispatcher("gate", dispatcher)
+
+	calls := 0
+	exporter.AddFunc("custom", "calls", func() interface{} {
+		calls++
+		return calls
+	})
+
+	server := NewMonitorServer("127.0.0.1:0", "", "")
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start monitor server: %v", err)
+	}
+	defer server.Stop()
+
+	fetch := func() map[string]map[string]json.RawMessage {
+		resp, err := http.Get("http://" + server.Addr().String() + "/debug/vars")
+		if err != nil {
+			t.Fatalf("GET /debug/vars failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var vars map[string]json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		if _, ok := vars["memstats"]; !ok {
+			t.Error("Expected the standard variables alongside the exporter")
+		}
+		var sections map[string]map[string]json.RawMessage
+		if err := json.Unmarshal(vars["sngo_network_test"], &sections); err != nil {
+			t.Fatalf("Invalid exporter JSON: %v", err)
+		}
+		return sections
+	}
+
+	sections := fetch()
+	var stats ConnectionManagerStatistics
+	if err := json.Unmarshal(sections["connection_managers"]["main"], &stats); err != nil {
+		t.Fatalf("Invalid connection manager stats: %v", err)
+	}
+	if _, ok := sections["dispatchers"]["gate"]; !ok {
+		t.Error("Expected dispatcher stats")
+	}
+	if string(sections["custom"]["calls"]) != "1" {
+		t.Errorf("Expected first render, got %s", sections["custom"]["calls"])
+	}
+
+	// Values are read on demand
+	if sections = fetch(); string(sections["custom"]["calls"]) != "2" {
+		t.Errorf("Expected value refreshed per request, got %s", sections["custom"]["calls"])
+	}
+
+	exporter.Remove("custom", "calls")
+	if sections = fetch(); sections["custom"] != nil {
+		t.Error("Expected removed entry to disappear")
+	}
+
+	resp, err := http.Get("http://" + server.Addr().String() + "/health")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected health endpoint, got %v", err)
+	}
+	resp.Body.Close()
+}
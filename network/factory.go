@@ -22,9 +22,12 @@ func (nf *networkFactory) CreateServer(config *NetworkConfig) (Server, error) {
 	switch config.Protocol {
 	case ProtocolTCP:
 		return NewTCPServer(config)
+	case ProtocolHTTP:
+		return NewHTTPFallbackServer(config)
+	case ProtocolUnix:
+		return NewIPCServer(config, nil)
 	case ProtocolUDP:
-		// TODO: Implement UDP server
-		return nil, fmt.Errorf("UDP server not implemented yet")
+		return NewUDPServer(config)
 	default:
 		return nil, fmt.Errorf("unsupported protocol: %s", config.Protocol)
 	}
@@ -38,10 +41,14 @@ func (nf *networkFactory) CreateClient(config *NetworkConfig) (Client, error) {
 
 	switch config.Protocol {
 	case ProtocolTCP:
+		if config.FallbackURL != "" {
+			return NewFallbackClient(config)
+		}
 		return NewTCPClient(config)
+	case ProtocolUnix:
+		return NewIPCClient(config)
 	case ProtocolUDP:
-		// TODO: Implement UDP client
-		return nil, fmt.Errorf("UDP client not implemented yet")
+		return NewUDPClient(config)
 	default:
 		return nil, fmt.Errorf("unsupported protocol: %s", config.Protocol)
 	}
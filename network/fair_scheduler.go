@@ -0,0 +1,282 @@
+// Package network provides fair scheduling of decoded messages across connections
+package network
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrSchedulerStopped is reported for messages arriving after a
+// FairScheduler stopped
+var ErrSchedulerStopped = errors.New("fair scheduler stopped")
+
+// FairSchedulerConfig caps the messages each connection has decoded but
+// not yet handled, and sizes the worker pool handling them
+type FairSchedulerConfig struct {
+	// Workers is the number of goroutines handling messages
+	Workers int
+
+	// MaxInFlight caps the messages of one connection queued or being
+	// handled; the connection's read loop stops decoding frames at the cap
+	MaxInFlight int
+
+	// MaxInFlightBytes caps the payload bytes of one connection queued or
+	// being handled (0 for no cap); a single message above it is still
+	// accepted once nothing else is in flight
+	MaxInFlightBytes int
+
+	// Quantum is how many messages of a connection a worker handles before
+	// moving on to the next connection with messages waiting
+	Quantum int
+}
+
+// DefaultFairSchedulerConfig returns one worker per CPU, up to 64 messages
+// in flight per connection and strict round-robin between connections
+func DefaultFairSchedulerConfig() FairSchedulerConfig {
+	return FairSchedulerConfig{
+		Workers:     runtime.NumCPU(),
+		MaxInFlight: 64,
+		Quantum:     1,
+	}
+}
+
+// withDefaults fills in the zero fields of the config
+func (c FairSchedulerConfig) withDefaults() FairSchedulerConfig {
+	defaults := DefaultFairSchedulerConfig()
+	if c.Workers <= 0 {
+		c.Workers = defaults.Workers
+	}
+	if c.MaxInFlight <= 0 {
+		c.MaxInFlight = defaults.MaxInFlight
+	}
+	if c.Quantum <= 0 {
+		c.Quantum = defaults.Quantum
+	}
+	return c
+}
+
+// FairSchedulerStats reports the work of a FairScheduler
+type FairSchedulerStats struct {
+	// Connections is the number of connections with messages in flight
+	Connections int `json:"connections"`
+
+	// InFlight is the number of messages queued or being handled
+	InFlight int `json:"in_flight"`
+
+	// Handled is the number of messages handed to the handler
+	Handled int64 `json:"handled"`
+
+	// Throttled counts the times a read loop waited at its in-flight cap
+	Throttled int64 `json:"throttled"`
+}
+
+// fairQueue holds the messages of one connection
+type fairQueue struct {
+	conn      Connection
+	pending   []*Message
+	inFlight  int
+	bytes     int
+	scheduled bool // in the ready list or held by a worker
+}
+
+// FairScheduler is a MessageHandler handing the messages of all
+// connections to a shared pool of workers, so a client pipelining
+// thousands of requests cannot starve the others. Each connection gets
+// a bounded queue: when it is full, OnMessage blocks the connection's read
+// loop, which stops decoding frames until the workers catch up. Workers
+// take connections round-robin and handle the messages of one connection
+// in order, one worker at a time.
+type FairScheduler struct {
+	handler MessageHandler
+	config  FairSchedulerConfig
+
+	mu        sync.Mutex
+	work      *sync.Cond // signalled when a connection becomes ready
+	space     *sync.Cond // broadcast when a connection's in-flight drops
+	queues    map[string]*fairQueue
+	ready     []*fairQueue
+	stopped   bool
+	handled   int64
+	throttled int64
+	wg        sync.WaitGroup
+}
+
+// NewFairScheduler creates a FairScheduler in front of handler and starts
+// its workers
+func NewFairScheduler(handler MessageHandler, config FairSchedulerConfig) *FairScheduler {
+	fs := &FairScheduler{
+		handler: handler,
+		config:  config.withDefaults(),
+		queues:  make(map[string]*fairQueue),
+	}
+	fs.work = sync.NewCond(&fs.mu)
+	fs.space = sync.NewCond(&fs.mu)
+
+	for i := 0; i < fs.config.Workers; i++ {
+		fs.wg.Add(1)
+		go fs.worker()
+	}
+	return fs
+}
+
+// OnMessage queues the message behind the others of its connection,
+// blocking while the connection is at its in-flight cap
+func (fs *FairScheduler) OnMessage(conn Connection, msg *Message) {
+	size := len(msg.Data)
+
+	fs.mu.Lock()
+	q, exists := fs.queues[conn.ID()]
+	if !exists {
+		q = &fairQueue{conn: conn}
+		fs.queues[conn.ID()] = q
+	}
+	if fs.full(q, size) {
+		fs.throttled++
+		for fs.full(q, size) && !fs.stopped {
+			fs.space.Wait()
+		}
+	}
+	if fs.stopped {
+		fs.release(q)
+		fs.mu.Unlock()
+		fs.handler.OnError(conn, ErrSchedulerStopped)
+		return
+	}
+
+	// Workers forget the connection whenever its in-flight drops to 0
+	fs.queues[conn.ID()] = q
+	q.pending = append(q.pending, msg)
+	q.inFlight++
+	q.bytes += size
+	if !q.scheduled {
+		q.scheduled = true
+		fs.ready = append(fs.ready, q)
+		fs.work.Signal()
+	}
+	fs.mu.Unlock()
+}
+
+// OnError passes the error on to the handler
+func (fs *FairScheduler) OnError(conn Connection, err error) {
+	fs.handler.OnError(conn, err)
+}
+
+// InFlight returns the messages of a connection queued or being handled
+func (fs *FairScheduler) InFlight(connID string) int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if q, exists := fs.queues[connID]; exists {
+		return q.inFlight
+	}
+	return 0
+}
+
+// Stats returns the counters of the scheduler
+func (fs *FairScheduler) Stats() FairSchedulerStats {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	stats := FairSchedulerStats{
+		Connections: len(fs.queues),
+		Handled:     fs.handled,
+		Throttled:   fs.throttled,
+	}
+	for _, q := range fs.queues {
+		stats.InFlight += q.inFlight
+	}
+	return stats
+}
+
+// Stop stops the workers once they finish the messages they hold; queued
+// messages are dropped and blocked read loops released
+func (fs *FairScheduler) Stop() {
+	fs.mu.Lock()
+	fs.stopped = true
+	for _, q := range fs.ready {
+		fs.drop(q)
+		fs.release(q)
+	}
+	fs.ready = nil
+	fs.work.Broadcast()
+	fs.space.Broadcast()
+	fs.mu.Unlock()
+
+	fs.wg.Wait()
+}
+
+// full reports whether a message of size bytes must wait for the
+// connection's in-flight to drop; callers hold mu
+func (fs *FairScheduler) full(q *fairQueue, size int) bool {
+	if q.inFlight >= fs.config.MaxInFlight {
+		return true
+	}
+	return fs.config.MaxInFlightBytes > 0 && q.inFlight > 0 && q.bytes+size > fs.config.MaxInFlightBytes
+}
+
+// release forgets a connection with nothing in flight; callers hold mu
+func (fs *FairScheduler) release(q *fairQueue) {
+	if q.inFlight == 0 && !q.scheduled {
+		delete(fs.queues, q.conn.ID())
+	}
+}
+
+// drop discards the queued messages of a connection and unschedules it;
+// callers hold mu
+func (fs *FairScheduler) drop(q *fairQueue) {
+	for _, msg := range q.pending {
+		q.inFlight--
+		q.bytes -= len(msg.Data)
+	}
+	q.pending = nil
+	q.scheduled = false
+}
+
+// worker handles up to a quantum of messages of the next ready connection,
+// then requeues the connection behind the others if it has more
+func (fs *FairScheduler) worker() {
+	defer fs.wg.Done()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for {
+		for len(fs.ready) == 0 && !fs.stopped {
+			fs.work.Wait()
+		}
+		if fs.stopped {
+			return
+		}
+
+		q := fs.ready[0]
+		fs.ready[0] = nil
+		fs.ready = fs.ready[1:]
+
+		n := fs.config.Quantum
+		if n > len(q.pending) {
+			n = len(q.pending)
+		}
+		batch := append([]*Message(nil), q.pending[:n]...)
+		q.pending = q.pending[n:]
+		fs.mu.Unlock()
+
+		for _, msg := range batch {
+			fs.handler.OnMessage(q.conn, msg)
+		}
+
+		fs.mu.Lock()
+		fs.handled += int64(n)
+		q.inFlight -= n
+		for _, msg := range batch {
+			q.bytes -= len(msg.Data)
+		}
+		if len(q.pending) > 0 && !fs.stopped {
+			fs.ready = append(fs.ready, q)
+			fs.work.Signal()
+		} else {
+			fs.drop(q)
+		}
+		fs.release(q)
+		fs.space.Broadcast()
+	}
+}
@@ -0,0 +1,110 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFairSchedulerRoundRobin(t *testing.T) {
+	chatty, _ := NewPipeConnectionPair()
+	quiet, _ := NewPipeConnectionPair()
+	defer chatty.Close()
+	defer quiet.Close()
+
+	var mu sync.Mutex
+	var order []string
+	gate := make(chan struct{})
+	handler := &testMessageHandler{onMessage: func(conn Connection, msg *Message) {
+		<-gate
+		mu.Lock()
+		order = append(order, string(msg.Data))
+		mu.Unlock()
+	}}
+	fs := NewFairScheduler(handler, FairSchedulerConfig{Workers: 1, MaxInFlight: 4})
+	defer fs.Stop()
+
+	// The chatty client pipelines far more than its cap
+	const pipelined = 20
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < pipelined; i++ {
+			fs.OnMessage(chatty, NewMessage(MessageTypeData, []byte(fmt.Sprintf("chatty-%d", i))))
+		}
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for fs.Stats().Throttled == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := fs.InFlight(chatty.ID()); n != 4 {
+		t.Fatalf("Expected the chatty connection held at 4 in flight, got %d", n)
+	}
+	fs.OnMessage(quiet, NewMessage(MessageTypeData, []byte("quiet")))
+	close(gate)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the chatty client to be drained")
+	}
+	for fs.Stats().Handled < pipelined+1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != pipelined+1 {
+		t.Fatalf("Expected %d messages handled, got %d", pipelined+1, len(order))
+	}
+	// The quiet message waits behind one chatty message, not the backlog
+	if order[1] != "quiet" {
+		t.Errorf("Expected the quiet connection served second, got %v", order[:3])
+	}
+	chattyOrder := 0
+	for _, data := range order {
+		if data == "quiet" {
+			continue
+		}
+		if want := fmt.Sprintf("chatty-%d", chattyOrder); data != want {
+			t.Fatalf("Expected %s next, got %s", want, data)
+		}
+		chattyOrder++
+	}
+	if stats := fs.Stats(); stats.InFlight != 0 || stats.Connections != 0 {
+		t.Errorf("Expected nothing left in flight, got %+v", stats)
+	}
+}
+
+func TestFairSchedulerByteCap(t *testing.T) {
+	conn, _ := NewPipeConnectionPair()
+	defer conn.Close()
+
+	gate := make(chan struct{})
+	handler := &testMessageHandler{onMessage: func(conn Connection, msg *Message) { <-gate }}
+	fs := NewFairScheduler(handler, FairSchedulerConfig{Workers: 1, MaxInFlight: 100, MaxInFlightBytes: 1000})
+
+	// A message above the byte cap is accepted alone, the next one waits
+	fs.OnMessage(conn, NewMessage(MessageTypeData, make([]byte, 1500)))
+	queued := make(chan struct{})
+	go func() {
+		fs.OnMessage(conn, NewMessage(MessageTypeData, make([]byte, 10)))
+		close(queued)
+	}()
+	select {
+	case <-queued:
+		t.Fatal("Expected the second message to wait for the byte cap")
+	case <-time.After(30 * time.Millisecond):
+	}
+	close(gate)
+	<-queued
+
+	fs.Stop()
+	errs := make(chan error, 1)
+	handler.onError = func(conn Connection, err error) { errs <- err }
+	fs.OnMessage(conn, NewMessage(MessageTypeData, nil))
+	if err := <-errs; err != ErrSchedulerStopped {
+		t.Errorf("Expected ErrSchedulerStopped, got %v", err)
+	}
+}
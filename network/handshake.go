@@ -0,0 +1,626 @@
+// Package network provides a framework for line-based connection handshakes
+package network
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/najoast/sngo/crypt"
+)
+
+var (
+	// ErrHandshakeOrder is returned for steps that are not in stage order
+	ErrHandshakeOrder = errors.New("handshake steps out of order")
+
+	// ErrHandshakeState is returned when a handshake is run twice or a step
+	// runs without the state an earlier step should have produced
+	ErrHandshakeState = errors.New("invalid handshake state")
+
+	// ErrHandshakeVerify is returned when a peer fails a proof, such as the
+	// challenge HMAC or a resume signature
+	ErrHandshakeVerify = errors.New("handshake verification failed")
+)
+
+// HandshakeStage is the position of a step in a handshake. Steps run in
+// strictly increasing stage order, so a handshake can't authenticate
+// before the key exchange or run the same stage twice.
+type HandshakeStage int
+
+const (
+	// HandshakeStageInit is the stage of a handshake that has not run yet
+	HandshakeStageInit HandshakeStage = iota
+
+	// HandshakeStageChallenge sends or receives the server challenge
+	HandshakeStageChallenge
+
+	// HandshakeStageKeyExchange agrees on a shared secret
+	HandshakeStageKeyExchange
+
+	// HandshakeStageAuth authenticates a token under the shared secret
+	HandshakeStageAuth
+
+	// HandshakeStageResume resumes a session with a previously agreed
+	// secret
+	HandshakeStageResume
+
+	// HandshakeStageDone is the stage of a handshake whose steps all
+	// succeeded
+	HandshakeStageDone
+
+	// HandshakeStageFailed is the stage of a handshake that failed
+	HandshakeStageFailed
+)
+
+// String returns the string representation of HandshakeStage
+func (s HandshakeStage) String() string {
+	switch s {
+	case HandshakeStageInit:
+		return "init"
+	case HandshakeStageChallenge:
+		return "challenge"
+	case HandshakeStageKeyExchange:
+		return "key_exchange"
+	case HandshakeStageAuth:
+		return "auth"
+	case HandshakeStageResume:
+		return "resume"
+	case HandshakeStageDone:
+		return "done"
+	case HandshakeStageFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Handshake status codes, sent as "<code> <payload>" lines
+const (
+	HandshakeStatusOK           = 200
+	HandshakeStatusUnauthorized = 401
+	HandshakeStatusBadIndex     = 402
+	HandshakeStatusForbidden    = 403
+	HandshakeStatusNotFound     = 404
+	HandshakeStatusInternal     = 500
+)
+
+// HandshakeError describes a failed handshake step. Code is the status
+// sent to or received from the peer, or 0 if none was exchanged.
+type HandshakeError struct {
+	Stage   HandshakeStage
+	Code    int
+	Message string
+	Err     error
+}
+
+// Error implements error
+func (e *HandshakeError) Error() string {
+	msg := fmt.Sprintf("handshake %s failed", e.Stage)
+	if e.Code != 0 {
+		msg += fmt.Sprintf(": %d", e.Code)
+	}
+	if e.Message != "" {
+		msg += " " + e.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// RejectHandshake returns an error that makes a server step reply with
+// code and message, e.g. from an AuthFunc
+func RejectHandshake(code int, message string) error {
+	return &HandshakeError{Code: code, Message: message}
+}
+
+// HandshakeStep is one step of a handshake
+type HandshakeStep interface {
+	// Stage returns the stage the step belongs to
+	Stage() HandshakeStage
+
+	// Run runs the step over the handshake connection, reading and
+	// updating its state
+	Run(ctx context.Context, hs *Handshake) error
+}
+
+// HandshakeConfig represents handshake configuration
+type HandshakeConfig struct {
+	// StepTimeout bounds each step unless the step sets its own
+	StepTimeout time.Duration
+
+	// Timeout bounds the whole handshake
+	Timeout time.Duration
+
+	// MaxLineSize is the longest line a peer may send
+	MaxLineSize int
+}
+
+// DefaultHandshakeConfig returns the default handshake configuration
+func DefaultHandshakeConfig() HandshakeConfig {
+	return HandshakeConfig{
+		StepTimeout: 10 * time.Second,
+		Timeout:     30 * time.Second,
+		MaxLineSize: 1024,
+	}
+}
+
+// Handshake runs ordered steps over a connection using newline
+// terminated lines, and holds the state they share
+type Handshake struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	config HandshakeConfig
+	steps  []HandshakeStep
+	stage  HandshakeStage
+
+	// Challenge is the random challenge sent by the server
+	Challenge []byte
+
+	// Secret is the shared secret from the key exchange or resume lookup
+	Secret []byte
+
+	// Token is the token a client authenticated with
+	Token string
+
+	// SubID is the session id the server assigned on authentication
+	SubID string
+
+	// Username and Index identify a resumed session
+	Username string
+	Index    uint64
+}
+
+// NewHandshake creates a handshake running steps over conn. Steps must be
+// in strictly increasing stage order.
+func NewHandshake(conn net.Conn, config HandshakeConfig, steps ...HandshakeStep) (*Handshake, error) {
+	defaults := DefaultHandshakeConfig()
+	if config.StepTimeout <= 0 {
+		config.StepTimeout = defaults.StepTimeout
+	}
+	if config.MaxLineSize <= 0 {
+		config.MaxLineSize = defaults.MaxLineSize
+	}
+
+	last := HandshakeStageInit
+	for i, step := range steps {
+		stage := step.Stage()
+		if stage <= last || stage >= HandshakeStageDone {
+			return nil, fmt.Errorf("step %d (%s) after %s: %w", i, stage, last, ErrHandshakeOrder)
+		}
+		last = stage
+	}
+
+	return &Handshake{
+		conn:   conn,
+		reader: bufio.NewReaderSize(conn, config.MaxLineSize),
+		config: config,
+		steps:  steps,
+	}, nil
+}
+
+// Stage returns the stage of the last completed step
+func (hs *Handshake) Stage() HandshakeStage {
+	return hs.stage
+}
+
+// Reader returns the buffered reader of the connection. Read through it
+// after the handshake, since it may hold bytes the peer sent early.
+func (hs *Handshake) Reader() *bufio.Reader {
+	return hs.reader
+}
+
+// Run runs every step in order. A handshake runs once; after a failure
+// the connection should be closed.
+func (hs *Handshake) Run(ctx context.Context) error {
+	if hs.stage != HandshakeStageInit {
+		return fmt.Errorf("handshake is %s: %w", hs.stage, ErrHandshakeState)
+	}
+
+	if hs.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hs.config.Timeout)
+		defer cancel()
+	}
+	defer hs.conn.SetDeadline(time.Time{})
+
+	for _, step := range hs.steps {
+		if err := hs.runStep(ctx, step); err != nil {
+			hs.stage = HandshakeStageFailed
+			var hsErr *HandshakeError
+			if errors.As(err, &hsErr) {
+				if hsErr.Stage == HandshakeStageInit {
+					hsErr.Stage = step.Stage()
+				}
+				return hsErr
+			}
+			return &HandshakeError{Stage: step.Stage(), Err: err}
+		}
+		hs.stage = step.Stage()
+	}
+
+	hs.stage = HandshakeStageDone
+	return nil
+}
+
+// runStep runs a step under its timeout, interrupting blocked reads and
+// writes when ctx is done
+func (hs *Handshake) runStep(ctx context.Context, step HandshakeStep) error {
+	timeout := hs.config.StepTimeout
+	if t, ok := step.(interface{ Timeout() time.Duration }); ok && t.Timeout() > 0 {
+		timeout = t.Timeout()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+	if err := hs.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		hs.conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	err := step.Run(ctx, hs)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	// The connection deadline can fire just before the context timer
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+// ReadLine reads one line without its terminator
+func (hs *Handshake) ReadLine() (string, error) {
+	line, err := hs.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", fmt.Errorf("line exceeds %d bytes", hs.config.MaxLineSize)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// WriteLine writes one line
+func (hs *Handshake) WriteLine(line string) error {
+	_, err := hs.conn.Write([]byte(line + "\n"))
+	return err
+}
+
+// ReadBytes reads a base64 encoded line
+func (hs *Handshake) ReadBytes() ([]byte, error) {
+	line, err := hs.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	return crypt.Base64Decode(line)
+}
+
+// WriteBytes writes data as a base64 encoded line
+func (hs *Handshake) WriteBytes(data []byte) error {
+	return hs.WriteLine(crypt.Base64Encode(data))
+}
+
+// WriteStatus writes a "<code> <message>" status line
+func (hs *Handshake) WriteStatus(code int, message string) error {
+	return hs.WriteLine(fmt.Sprintf("%d %s", code, message))
+}
+
+// ReadStatus reads a status line and returns its message, or a
+// HandshakeError carrying the code if it is not OK
+func (hs *Handshake) ReadStatus() (string, error) {
+	line, err := hs.ReadLine()
+	if err != nil {
+		return "", err
+	}
+
+	codeStr, message, _ := strings.Cut(line, " ")
+	code, err := strconv.Atoi(codeStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid status line %q", line)
+	}
+	if code != HandshakeStatusOK {
+		return "", &HandshakeError{Code: code, Message: message}
+	}
+	return message, nil
+}
+
+// reject replies with the status of err and returns it. Errors that are
+// not a HandshakeError with a code are replied to with fallback.
+func (hs *Handshake) reject(err error, fallback int) error {
+	var hsErr *HandshakeError
+	if !errors.As(err, &hsErr) || hsErr.Code == 0 {
+		hsErr = &HandshakeError{Code: fallback, Message: err.Error(), Err: err}
+	}
+	hs.WriteStatus(hsErr.Code, hsErr.Message)
+	return hsErr
+}
+
+// require returns ErrHandshakeState if an earlier step has not produced
+// the named state
+func (hs *Handshake) require(name string, value []byte) error {
+	if len(value) == 0 {
+		return fmt.Errorf("no %s: %w", name, ErrHandshakeState)
+	}
+	return nil
+}
+
+// stepFunc adapts a function to HandshakeStep
+type stepFunc struct {
+	stage HandshakeStage
+	run   func(ctx context.Context, hs *Handshake) error
+}
+
+func (s *stepFunc) Stage() HandshakeStage {
+	return s.stage
+}
+
+func (s *stepFunc) Run(ctx context.Context, hs *Handshake) error {
+	return s.run(ctx, hs)
+}
+
+// HandshakeStepFunc returns a step of stage that runs fn
+func HandshakeStepFunc(stage HandshakeStage, fn func(ctx context.Context, hs *Handshake) error) HandshakeStep {
+	return &stepFunc{stage: stage, run: fn}
+}
+
+// timedStep overrides the timeout of a step
+type timedStep struct {
+	HandshakeStep
+	timeout time.Duration
+}
+
+func (s *timedStep) Timeout() time.Duration {
+	return s.timeout
+}
+
+// WithStepTimeout returns step bounded by timeout instead of the
+// configured StepTimeout
+func WithStepTimeout(step HandshakeStep, timeout time.Duration) HandshakeStep {
+	return &timedStep{HandshakeStep: step, timeout: timeout}
+}
+
+// ServerChallenge sends a random challenge the client proves the shared
+// secret against
+func ServerChallenge() HandshakeStep {
+	return HandshakeStepFunc(HandshakeStageChallenge, func(ctx context.Context, hs *Handshake) error {
+		hs.Challenge = crypt.RandomKey()
+		return hs.WriteBytes(hs.Challenge)
+	})
+}
+
+// ClientChallenge receives the server challenge
+func ClientChallenge() HandshakeStep {
+	return HandshakeStepFunc(HandshakeStageChallenge, func(ctx context.Context, hs *Handshake) error {
+		challenge, err := hs.ReadBytes()
+		if err != nil {
+			return fmt.Errorf("failed to read challenge: %w", err)
+		}
+		if len(challenge) != 8 {
+			return fmt.Errorf("invalid challenge length %d", len(challenge))
+		}
+		hs.Challenge = challenge
+		return nil
+	})
+}
+
+// ServerKeyExchange agrees on a secret by Diffie-Hellman and verifies the
+// client proves it with an HMAC of the challenge
+func ServerKeyExchange() HandshakeStep {
+	return HandshakeStepFunc(HandshakeStageKeyExchange, func(ctx context.Context, hs *Handshake) error {
+		if err := hs.require("challenge", hs.Challenge); err != nil {
+			return err
+		}
+
+		clientKey, err := hs.ReadBytes()
+		if err != nil {
+			return fmt.Errorf("failed to read client key: %w", err)
+		}
+		if len(clientKey) != 8 {
+			return fmt.Errorf("invalid client key length %d", len(clientKey))
+		}
+
+		private := crypt.RandomKey()
+		if err := hs.WriteBytes(crypt.DHExchange(private)); err != nil {
+			return fmt.Errorf("failed to send server key: %w", err)
+		}
+		secret := crypt.DHSecret(private, clientKey)
+
+		proof, err := hs.ReadBytes()
+		if err != nil {
+			return fmt.Errorf("failed to read challenge HMAC: %w", err)
+		}
+		if !hmac.Equal(proof, crypt.HMAC64(hs.Challenge, secret)) {
+			hs.WriteStatus(HandshakeStatusUnauthorized, "HMAC verification failed")
+			return &HandshakeError{Code: HandshakeStatusUnauthorized, Err: ErrHandshakeVerify}
+		}
+
+		hs.Secret = secret
+		return nil
+	})
+}
+
+// ClientKeyExchange agrees on a secret by Diffie-Hellman and proves it to
+// the server with an HMAC of the challenge
+func ClientKeyExchange() HandshakeStep {
+	return HandshakeStepFunc(HandshakeStageKeyExchange, func(ctx context.Context, hs *Handshake) error {
+		if err := hs.require("challenge", hs.Challenge); err != nil {
+			return err
+		}
+
+		private := crypt.RandomKey()
+		if err := hs.WriteBytes(crypt.DHExchange(private)); err != nil {
+			return fmt.Errorf("failed to send client key: %w", err)
+		}
+
+		serverKey, err := hs.ReadBytes()
+		if err != nil {
+			return fmt.Errorf("failed to read server key: %w", err)
+		}
+		if len(serverKey) != 8 {
+			return fmt.Errorf("invalid server key length %d", len(serverKey))
+		}
+
+		hs.Secret = crypt.DHSecret(private, serverKey)
+		return hs.WriteBytes(crypt.HMAC64(hs.Challenge, hs.Secret))
+	})
+}
+
+// AuthFunc authenticates a token and returns the session id to send the
+// client. Return RejectHandshake to choose the status code; other errors
+// are replied to with 403.
+type AuthFunc func(ctx context.Context, hs *Handshake, token string) (subid string, err error)
+
+// ServerAuth receives a token encrypted with the shared secret and
+// authenticates it with fn
+func ServerAuth(fn AuthFunc) HandshakeStep {
+	return HandshakeStepFunc(HandshakeStageAuth, func(ctx context.Context, hs *Handshake) error {
+		if err := hs.require("secret", hs.Secret); err != nil {
+			return err
+		}
+
+		encrypted, err := hs.ReadBytes()
+		if err != nil {
+			return fmt.Errorf("failed to read token: %w", err)
+		}
+		// DesDecode panics on partial blocks, so check before decoding
+		if len(encrypted) == 0 || len(encrypted)%8 != 0 {
+			return hs.reject(RejectHandshake(HandshakeStatusUnauthorized, "invalid token"), 0)
+		}
+		hs.Token = string(crypt.DesDecode(hs.Secret, encrypted))
+
+		subid, err := fn(ctx, hs, hs.Token)
+		if err != nil {
+			return hs.reject(err, HandshakeStatusForbidden)
+		}
+
+		hs.SubID = subid
+		return hs.WriteStatus(HandshakeStatusOK, crypt.Base64Encode([]byte(subid)))
+	})
+}
+
+// ClientAuth sends token encrypted with the shared secret and receives
+// the session id
+func ClientAuth(token string) HandshakeStep {
+	return HandshakeStepFunc(HandshakeStageAuth, func(ctx context.Context, hs *Handshake) error {
+		if err := hs.require("secret", hs.Secret); err != nil {
+			return err
+		}
+
+		if err := hs.WriteBytes(crypt.DesEncode(hs.Secret, []byte(token))); err != nil {
+			return fmt.Errorf("failed to send token: %w", err)
+		}
+		hs.Token = token
+
+		message, err := hs.ReadStatus()
+		if err != nil {
+			return err
+		}
+		subid, err := crypt.Base64Decode(message)
+		if err != nil {
+			return fmt.Errorf("invalid subid: %w", err)
+		}
+		hs.SubID = string(subid)
+		return nil
+	})
+}
+
+// ResumeFunc looks up the secret and last used index of a session
+type ResumeFunc func(ctx context.Context, username string) (secret []byte, lastIndex uint64, err error)
+
+// resumeSignature signs username and index with secret
+func resumeSignature(secret []byte, username string, index uint64) []byte {
+	return crypt.HMACHash(secret, fmt.Sprintf("%s:%d", username, index))
+}
+
+// ServerResume resumes a session from a "username:index:signature" line.
+// The signature must be an HMAC of "username:index" under the session
+// secret and the index must exceed the last one used, so a captured
+// handshake can't be replayed.
+func ServerResume(fn ResumeFunc) HandshakeStep {
+	return HandshakeStepFunc(HandshakeStageResume, func(ctx context.Context, hs *Handshake) error {
+		line, err := hs.ReadLine()
+		if err != nil {
+			return fmt.Errorf("failed to read resume request: %w", err)
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 {
+			return hs.reject(RejectHandshake(HandshakeStatusUnauthorized, "invalid resume request"), 0)
+		}
+		username := parts[0]
+		index, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return hs.reject(RejectHandshake(HandshakeStatusUnauthorized, "invalid index"), 0)
+		}
+		signature, err := crypt.Base64Decode(parts[2])
+		if err != nil {
+			return hs.reject(RejectHandshake(HandshakeStatusUnauthorized, "invalid signature"), 0)
+		}
+
+		secret, lastIndex, err := fn(ctx, username)
+		if err != nil {
+			return hs.reject(err, HandshakeStatusUnauthorized)
+		}
+		if !hmac.Equal(signature, resumeSignature(secret, username, index)) {
+			hs.WriteStatus(HandshakeStatusUnauthorized, "Unauthorized")
+			return &HandshakeError{Code: HandshakeStatusUnauthorized, Err: ErrHandshakeVerify}
+		}
+		if index <= lastIndex {
+			return hs.reject(RejectHandshake(HandshakeStatusBadIndex, "Index expired"), 0)
+		}
+
+		hs.Secret = secret
+		hs.Username = username
+		hs.Index = index
+		return hs.WriteStatus(HandshakeStatusOK, "OK")
+	})
+}
+
+// ClientResume resumes the session of username with index, signed with
+// secret or, if nil, the secret agreed earlier in the handshake
+func ClientResume(username string, index uint64, secret []byte) HandshakeStep {
+	return HandshakeStepFunc(HandshakeStageResume, func(ctx context.Context, hs *Handshake) error {
+		key := secret
+		if key == nil {
+			key = hs.Secret
+		}
+		if err := hs.require("secret", key); err != nil {
+			return err
+		}
+
+		signature := crypt.Base64Encode(resumeSignature(key, username, index))
+		if err := hs.WriteLine(fmt.Sprintf("%s:%d:%s", username, index, signature)); err != nil {
+			return fmt.Errorf("failed to send resume request: %w", err)
+		}
+		if _, err := hs.ReadStatus(); err != nil {
+			return err
+		}
+
+		hs.Secret = key
+		hs.Username = username
+		hs.Index = index
+		return nil
+	})
+}
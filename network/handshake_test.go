@@ -0,0 +1,178 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// handshakePair returns both ends of a loopback TCP connection
+func handshakePair(t *testing.T) (server, client net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+	}()
+
+	client, err = net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	server = <-accepted
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return server, client
+}
+
+// runHandshakes runs a server and a client handshake concurrently
+func runHandshakes(t *testing.T, serverSteps, clientSteps []HandshakeStep) (*Handshake, *Handshake, error, error) {
+	serverConn, clientConn := handshakePair(t)
+	server, err := NewHandshake(serverConn, DefaultHandshakeConfig(), serverSteps...)
+	if err != nil {
+		t.Fatalf("Failed to create server handshake: %v", err)
+	}
+	client, err := NewHandshake(clientConn, DefaultHandshakeConfig(), clientSteps...)
+	if err != nil {
+		t.Fatalf("Failed to create client handshake: %v", err)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.Run(context.Background()) }()
+	clientErr := client.Run(context.Background())
+	return server, client, <-serverErr, clientErr
+}
+
+func TestHandshakeLogin(t *testing.T) {
+	auth := func(ctx context.Context, hs *Handshake, token string) (string, error) {
+		if token != "alice@game:secret" {
+			return "", RejectHandshake(HandshakeStatusNotFound, "unknown user")
+		}
+		return "42", nil
+	}
+
+	server, client, serverErr, clientErr := runHandshakes(t,
+		[]HandshakeStep{ServerChallenge(), ServerKeyExchange(), ServerAuth(auth)},
+		[]HandshakeStep{ClientChallenge(), ClientKeyExchange(), ClientAuth("alice@game:secret")})
+	if serverErr != nil || clientErr != nil {
+		t.Fatalf("Expected handshake to succeed, got server %v, client %v", serverErr, clientErr)
+	}
+	if !bytes.Equal(server.Secret, client.Secret) || len(server.Secret) != 8 {
+		t.Errorf("Expected a shared 8 byte secret, got %x and %x", server.Secret, client.Secret)
+	}
+	if server.Token != "alice@game:secret" || client.SubID != "42" {
+		t.Errorf("Expected token and subid to be exchanged, got %q and %q", server.Token, client.SubID)
+	}
+	if server.Stage() != HandshakeStageDone || client.Stage() != HandshakeStageDone {
+		t.Errorf("Expected both handshakes done, got %s and %s", server.Stage(), client.Stage())
+	}
+
+	// The status code chosen by the auth function reaches the client
+	_, _, serverErr, clientErr = runHandshakes(t,
+		[]HandshakeStep{ServerChallenge(), ServerKeyExchange(), ServerAuth(auth)},
+		[]HandshakeStep{ClientChallenge(), ClientKeyExchange(), ClientAuth("mallory@game:guess")})
+	var hsErr *HandshakeError
+	if !errors.As(clientErr, &hsErr) || hsErr.Code != HandshakeStatusNotFound || hsErr.Stage != HandshakeStageAuth {
+		t.Errorf("Expected client to see 404 in the auth stage, got %v", clientErr)
+	}
+	if serverErr == nil {
+		t.Error("Expected server handshake to fail")
+	}
+}
+
+func TestHandshakeResume(t *testing.T) {
+	secret := []byte("01234567")
+	lookup := func(ctx context.Context, username string) ([]byte, uint64, error) {
+		if username != "alice" {
+			return nil, 0, errors.New("no session")
+		}
+		return secret, 1, nil
+	}
+
+	server, _, serverErr, clientErr := runHandshakes(t,
+		[]HandshakeStep{ServerResume(lookup)},
+		[]HandshakeStep{ClientResume("alice", 2, secret)})
+	if serverErr != nil || clientErr != nil {
+		t.Fatalf("Expected resume to succeed, got server %v, client %v", serverErr, clientErr)
+	}
+	if server.Username != "alice" || server.Index != 2 {
+		t.Errorf("Expected resumed session alice:2, got %s:%d", server.Username, server.Index)
+	}
+
+	// A replayed index is refused
+	_, _, _, clientErr = runHandshakes(t,
+		[]HandshakeStep{ServerResume(lookup)},
+		[]HandshakeStep{ClientResume("alice", 1, secret)})
+	var hsErr *HandshakeError
+	if !errors.As(clientErr, &hsErr) || hsErr.Code != HandshakeStatusBadIndex {
+		t.Errorf("Expected 402 for a replayed index, got %v", clientErr)
+	}
+
+	// A wrong secret fails verification
+	_, _, serverErr, clientErr = runHandshakes(t,
+		[]HandshakeStep{ServerResume(lookup)},
+		[]HandshakeStep{ClientResume("alice", 3, []byte("76543210"))})
+	if !errors.Is(serverErr, ErrHandshakeVerify) {
+		t.Errorf("Expected server to fail verification, got %v", serverErr)
+	}
+	if !errors.As(clientErr, &hsErr) || hsErr.Code != HandshakeStatusUnauthorized {
+		t.Errorf("Expected 401 for a bad signature, got %v", clientErr)
+	}
+}
+
+func TestHandshakeStateMachine(t *testing.T) {
+	serverConn, _ := handshakePair(t)
+
+	_, err := NewHandshake(serverConn, DefaultHandshakeConfig(), ServerAuth(nil), ServerKeyExchange())
+	if !errors.Is(err, ErrHandshakeOrder) {
+		t.Errorf("Expected ErrHandshakeOrder for auth before key exchange, got %v", err)
+	}
+	_, err = NewHandshake(serverConn, DefaultHandshakeConfig(), ServerChallenge(), ServerChallenge())
+	if !errors.Is(err, ErrHandshakeOrder) {
+		t.Errorf("Expected ErrHandshakeOrder for a repeated stage, got %v", err)
+	}
+
+	// Auth without a key exchange has no secret to decrypt with
+	hs, err := NewHandshake(serverConn, DefaultHandshakeConfig(), ServerAuth(nil))
+	if err != nil {
+		t.Fatalf("Failed to create handshake: %v", err)
+	}
+	if err := hs.Run(context.Background()); !errors.Is(err, ErrHandshakeState) {
+		t.Errorf("Expected ErrHandshakeState without a secret, got %v", err)
+	}
+	if hs.Stage() != HandshakeStageFailed {
+		t.Errorf("Expected failed stage, got %s", hs.Stage())
+	}
+	if err := hs.Run(context.Background()); !errors.Is(err, ErrHandshakeState) {
+		t.Errorf("Expected a handshake to run once, got %v", err)
+	}
+}
+
+func TestHandshakeStepTimeout(t *testing.T) {
+	serverConn, _ := handshakePair(t)
+
+	// The client never sends its challenge, so the step times out
+	hs, err := NewHandshake(serverConn, DefaultHandshakeConfig(), WithStepTimeout(ClientChallenge(), 50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create handshake: %v", err)
+	}
+
+	start := time.Now()
+	err = hs.Run(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected step deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected step timeout to interrupt the read, took %v", elapsed)
+	}
+}
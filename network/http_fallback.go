@@ -0,0 +1,791 @@
+// Package network provides an HTTP long-polling fallback transport
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fallback transport endpoints, relative to where the handler is mounted.
+// Upstream frames are POSTed to /send, downstream frames are long-polled
+// from /poll; every batch is a sequence of [seq uint64][length uint32][frame]
+const (
+	FallbackPathOpen  = "/open"
+	FallbackPathSend  = "/send"
+	FallbackPathPoll  = "/poll"
+	FallbackPathClose = "/close"
+
+	// FallbackSessionHeader carries the session token on every request
+	FallbackSessionHeader = "X-Sngo-Session"
+
+	// FallbackSessionCookie carries the session token as well, so sticky
+	// load balancers keep routing a session to the node that owns it
+	FallbackSessionCookie = "SNGO_SESSION"
+)
+
+// Fallback transport errors
+var (
+	// ErrFallbackSessionNotFound is returned for unknown or expired sessions
+	ErrFallbackSessionNotFound = errors.New("fallback session not found")
+
+	// ErrFallbackSessionGap is returned when frames were lost and the
+	// stream can no longer be resumed in order
+	ErrFallbackSessionGap = errors.New("fallback session lost frames")
+
+	// ErrFallbackBufferFull is returned when the peer stopped draining frames
+	ErrFallbackBufferFull = errors.New("fallback buffer full")
+)
+
+// fallbackFrame is one encoded message tagged with its transport sequence
+type fallbackFrame struct {
+	seq  uint64
+	data []byte
+}
+
+// writeFallbackFrames writes frames as [seq][length][frame] records
+func writeFallbackFrames(w io.Writer, frames []fallbackFrame) error {
+	header := make([]byte, 12)
+	for _, frame := range frames {
+		binary.BigEndian.PutUint64(header[0:8], frame.seq)
+		binary.BigEndian.PutUint32(header[8:12], uint32(len(frame.data)))
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		if _, err := w.Write(frame.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFallbackFrames reads [seq][length][frame] records until EOF
+func readFallbackFrames(r io.Reader) ([]fallbackFrame, error) {
+	var frames []fallbackFrame
+	header := make([]byte, 12)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return frames, nil
+			}
+			return nil, fmt.Errorf("failed to read fallback frame header: %w", err)
+		}
+
+		size := binary.BigEndian.Uint32(header[8:12])
+		if size > MaxMessageSize+ChecksumSize+TimestampSize {
+			return nil, fmt.Errorf("fallback frame too large: %d bytes", size)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("failed to read fallback frame: %w", err)
+		}
+		frames = append(frames, fallbackFrame{seq: binary.BigEndian.Uint64(header[0:8]), data: data})
+	}
+}
+
+// httpAddr is the net.Addr of an HTTP fallback peer
+type httpAddr string
+
+// Network returns "http"
+func (a httpAddr) Network() string { return "http" }
+
+// String returns the address
+func (a httpAddr) String() string { return string(a) }
+
+// httpFallbackServer implements the Server interface over HTTP long-polling.
+// It is also an http.Handler, so it can be mounted on an existing mux
+// instead of being started on its own port
+type httpFallbackServer struct {
+	config     *NetworkConfig
+	listener   net.Listener
+	httpServer *http.Server
+	running    int32 // atomic flag
+
+	// Event handlers
+	connHandler ConnectionHandler
+	msgHandler  MessageHandler
+
+	// Session management, keyed by token
+	sessions       map[string]*httpSession
+	sessionsMu     sync.RWMutex
+	connectionChan chan Connection
+	reaperOnce     sync.Once
+
+	// Synchronization
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Statistics
+	totalConnections int64
+	totalMessages    int64
+	startTime        time.Time
+}
+
+// NewHTTPFallbackServer creates a long-polling server for clients whose
+// networks block raw TCP and WebSocket
+func NewHTTPFallbackServer(config *NetworkConfig) (Server, error) {
+	if config == nil {
+		config = DefaultNetworkConfig()
+		config.Protocol = ProtocolHTTP
+	}
+
+	if config.Protocol != ProtocolHTTP {
+		return nil, fmt.Errorf("invalid protocol for HTTP fallback server: %s", config.Protocol)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &httpFallbackServer{
+		config:         config,
+		sessions:       make(map[string]*httpSession),
+		connectionChan: make(chan Connection, 100),
+		ctx:            ctx,
+		cancel:         cancel,
+		startTime:      time.Now(),
+	}, nil
+}
+
+// Start starts serving the fallback endpoints on the configured address
+func (hs *httpFallbackServer) Start() error {
+	if !atomic.CompareAndSwapInt32(&hs.running, 0, 1) {
+		return fmt.Errorf("server is already running")
+	}
+
+	address := fmt.Sprintf("%s:%d", hs.config.Address, hs.config.Port)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		atomic.StoreInt32(&hs.running, 0)
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	hs.listener = listener
+	hs.httpServer = &http.Server{Handler: hs}
+
+	hs.wg.Add(1)
+	go func() {
+		defer hs.wg.Done()
+		hs.httpServer.Serve(listener)
+	}()
+
+	fmt.Printf("HTTP fallback server started on %s\n", address)
+	return nil
+}
+
+// Stop stops the server and closes all sessions
+func (hs *httpFallbackServer) Stop() error {
+	hs.cancel()
+
+	if atomic.CompareAndSwapInt32(&hs.running, 1, 0) {
+		hs.httpServer.Close()
+	}
+	hs.wg.Wait()
+
+	for _, conn := range hs.GetActiveConnections() {
+		conn.Close()
+	}
+	return nil
+}
+
+// Listen returns the listening address
+func (hs *httpFallbackServer) Listen() net.Addr {
+	if hs.listener == nil {
+		return nil
+	}
+	return hs.listener.Addr()
+}
+
+// AcceptConnection waits for and returns new sessions
+func (hs *httpFallbackServer) AcceptConnection(ctx context.Context) (Connection, error) {
+	select {
+	case conn := <-hs.connectionChan:
+		return conn, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-hs.ctx.Done():
+		return nil, fmt.Errorf("server is shutting down")
+	}
+}
+
+// SetConnectionHandler sets the handler for new sessions
+func (hs *httpFallbackServer) SetConnectionHandler(handler ConnectionHandler) {
+	hs.connHandler = handler
+}
+
+// SetMessageHandler sets the handler for upstream messages
+func (hs *httpFallbackServer) SetMessageHandler(handler MessageHandler) {
+	hs.msgHandler = handler
+}
+
+// GetActiveConnections returns all open sessions
+func (hs *httpFallbackServer) GetActiveConnections() []Connection {
+	hs.sessionsMu.RLock()
+	defer hs.sessionsMu.RUnlock()
+
+	connections := make([]Connection, 0, len(hs.sessions))
+	for _, session := range hs.sessions {
+		connections = append(connections, session)
+	}
+	return connections
+}
+
+// GetConnectionCount returns the number of open sessions
+func (hs *httpFallbackServer) GetConnectionCount() int {
+	hs.sessionsMu.RLock()
+	defer hs.sessionsMu.RUnlock()
+
+	return len(hs.sessions)
+}
+
+// GetStatistics returns server statistics
+func (hs *httpFallbackServer) GetStatistics() ServerStatistics {
+	address := ""
+	if addr := hs.Listen(); addr != nil {
+		address = addr.String()
+	}
+
+	return ServerStatistics{
+		Address:            address,
+		Protocol:           string(ProtocolHTTP),
+		Running:            atomic.LoadInt32(&hs.running) == 1,
+		StartTime:          hs.startTime,
+		Uptime:             time.Since(hs.startTime),
+		TotalConnections:   atomic.LoadInt64(&hs.totalConnections),
+		CurrentConnections: int64(hs.GetConnectionCount()),
+		TotalMessages:      atomic.LoadInt64(&hs.totalMessages),
+	}
+}
+
+// BroadcastMessage queues a message on every session
+func (hs *httpFallbackServer) BroadcastMessage(msg *Message) error {
+	if msg == nil {
+		return fmt.Errorf("message is nil")
+	}
+
+	var failed []error
+	for _, conn := range hs.GetActiveConnections() {
+		if err := conn.SendMessage(msg); err != nil {
+			failed = append(failed, fmt.Errorf("failed to send to %s: %w", conn.ID(), err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("broadcast failed for %d connections: %v", len(failed), failed)
+	}
+	return nil
+}
+
+// ServeHTTP dispatches the fallback endpoints
+func (hs *httpFallbackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, FallbackPathOpen):
+		hs.handleOpen(w, r)
+	case strings.HasSuffix(r.URL.Path, FallbackPathSend):
+		hs.handleSend(w, r)
+	case strings.HasSuffix(r.URL.Path, FallbackPathPoll):
+		hs.handlePoll(w, r)
+	case strings.HasSuffix(r.URL.Path, FallbackPathClose):
+		hs.handleClose(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleOpen creates a session and returns its token
+func (hs *httpFallbackServer) handleOpen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	select {
+	case <-hs.ctx.Done():
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	if max := hs.config.MaxConnections; max > 0 && hs.GetConnectionCount() >= max {
+		http.Error(w, "too many sessions", http.StatusServiceUnavailable)
+		return
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	session := newHTTPSession(hs, token, httpAddr(r.RemoteAddr), httpAddr(r.Host))
+
+	hs.sessionsMu.Lock()
+	hs.sessions[token] = session
+	hs.sessionsMu.Unlock()
+	atomic.AddInt64(&hs.totalConnections, 1)
+	hs.startReaper()
+
+	http.SetCookie(w, &http.Cookie{Name: FallbackSessionCookie, Value: token, Path: "/", HttpOnly: true})
+	w.Header().Set(FallbackSessionHeader, token)
+	io.WriteString(w, token)
+
+	if hs.connHandler != nil {
+		hs.connHandler.OnConnect(session)
+	}
+	select {
+	case hs.connectionChan <- session:
+	default:
+		// Nobody is accepting; the session is still tracked
+	}
+}
+
+// handleSend delivers upstream frames to the session
+func (hs *httpFallbackServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, ok := hs.lookupSession(r)
+	if !ok {
+		http.Error(w, ErrFallbackSessionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	frames, err := readFallbackFrames(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := session.receive(frames); err != nil {
+		http.Error(w, err.Error(), fallbackStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePoll holds the request until downstream frames are available or
+// the poll timeout expires. The ack parameter acknowledges every frame up
+// to that sequence, so a poll lost in transit is simply replayed
+func (hs *httpFallbackServer) handlePoll(w http.ResponseWriter, r *http.Request) {
+	session, ok := hs.lookupSession(r)
+	if !ok {
+		http.Error(w, ErrFallbackSessionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	ack, err := strconv.ParseUint(r.URL.Query().Get("ack"), 10, 64)
+	if err != nil {
+		ack = 0
+	}
+
+	frames, err := session.poll(r.Context(), ack, hs.config.FallbackPollTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), fallbackStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	writeFallbackFrames(w, frames)
+}
+
+// handleClose closes a session at the client's request
+func (hs *httpFallbackServer) handleClose(w http.ResponseWriter, r *http.Request) {
+	if session, ok := hs.lookupSession(r); ok {
+		session.Close()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookupSession finds the session named by the header or sticky cookie
+func (hs *httpFallbackServer) lookupSession(r *http.Request) (*httpSession, bool) {
+	token := r.Header.Get(FallbackSessionHeader)
+	if token == "" {
+		if cookie, err := r.Cookie(FallbackSessionCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+
+	hs.sessionsMu.RLock()
+	defer hs.sessionsMu.RUnlock()
+
+	session, ok := hs.sessions[token]
+	return session, ok
+}
+
+// removeSession forgets a closed session
+func (hs *httpFallbackServer) removeSession(token string) {
+	hs.sessionsMu.Lock()
+	defer hs.sessionsMu.Unlock()
+
+	delete(hs.sessions, token)
+}
+
+// startReaper starts closing sessions that stopped polling
+func (hs *httpFallbackServer) startReaper() {
+	timeout := hs.config.FallbackSessionTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	hs.reaperOnce.Do(func() {
+		hs.wg.Add(1)
+		go func() {
+			defer hs.wg.Done()
+
+			ticker := time.NewTicker(timeout / 2)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					for _, conn := range hs.GetActiveConnections() {
+						if session := conn.(*httpSession); session.idleFor() > timeout {
+							session.Close()
+						}
+					}
+				case <-hs.ctx.Done():
+					return
+				}
+			}
+		}()
+	})
+}
+
+// fallbackStatus maps a session error to an HTTP status code
+func fallbackStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrFallbackSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrFallbackSessionGap):
+		return http.StatusGone
+	case errors.Is(err, ErrFallbackBufferFull):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// newSessionToken returns an unguessable session token
+func newSessionToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// httpSession implements the Connection interface for one fallback client
+type httpSession struct {
+	id     string
+	token  string
+	server *httpFallbackServer
+	remote net.Addr
+	local  net.Addr
+	codec  MessageCodec
+
+	state        int32 // ConnectionState as atomic int32
+	closed       int32 // atomic flag
+	done         chan struct{}
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	lastActivity int64 // Unix timestamp as atomic int64
+	lastPoll     int64 // UnixNano as atomic int64
+	userData     interface{}
+	mu           sync.RWMutex
+
+	// Downstream frames kept until the client acknowledges them
+	outMu   sync.Mutex
+	outbox  []fallbackFrame
+	nextSeq uint64
+	acked   uint64
+	notify  chan struct{}
+
+	// Upstream sequence for duplicate suppression
+	inMu        sync.Mutex
+	lastInbound uint64
+	inbox       chan *Message
+
+	// Statistics
+	bytesRead    int64
+	bytesWritten int64
+	messagesRead int64
+	messagesSent int64
+}
+
+// newHTTPSession creates a session owned by server
+func newHTTPSession(server *httpFallbackServer, token string, remote, local net.Addr) *httpSession {
+	now := time.Now()
+	return &httpSession{
+		id:           fmt.Sprintf("http-%d", atomic.AddInt64(&connectionIDCounter, 1)),
+		token:        token,
+		server:       server,
+		remote:       remote,
+		local:        local,
+		codec:        NewBinaryMessageCodec(),
+		state:        int32(ConnectionStateConnected),
+		done:         make(chan struct{}),
+		readTimeout:  30 * time.Second,
+		writeTimeout: 30 * time.Second,
+		lastActivity: now.Unix(),
+		lastPoll:     now.UnixNano(),
+		nextSeq:      1,
+		notify:       make(chan struct{}),
+		inbox:        make(chan *Message, 256),
+	}
+}
+
+// ID returns the connection ID
+func (s *httpSession) ID() string {
+	return s.id
+}
+
+// RemoteAddr returns the client address of the session's first request
+func (s *httpSession) RemoteAddr() net.Addr {
+	return s.remote
+}
+
+// LocalAddr returns the host the session was opened on
+func (s *httpSession) LocalAddr() net.Addr {
+	return s.local
+}
+
+// Send queues an encoded frame for the next poll
+func (s *httpSession) Send(data []byte) error {
+	if atomic.LoadInt32(&s.closed) == 1 {
+		return fmt.Errorf("connection %s is closed", s.id)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	if max := s.server.config.FallbackBufferSize; max > 0 && len(s.outbox) >= max {
+		return fmt.Errorf("connection %s: %w", s.id, ErrFallbackBufferFull)
+	}
+
+	s.outbox = append(s.outbox, fallbackFrame{seq: s.nextSeq, data: data})
+	s.nextSeq++
+	close(s.notify)
+	s.notify = make(chan struct{})
+
+	atomic.AddInt64(&s.bytesWritten, int64(len(data)))
+	return nil
+}
+
+// SendMessage encodes and queues a message
+func (s *httpSession) SendMessage(msg *Message) error {
+	if msg == nil {
+		return fmt.Errorf("message is nil")
+	}
+
+	msg.ConnectionID = s.id
+	data, err := s.codec.Encode(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	if err := s.Send(data); err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.messagesSent, 1)
+	return nil
+}
+
+// Close closes the session; pending polls return immediately
+func (s *httpSession) Close() error {
+	if !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return nil
+	}
+
+	atomic.StoreInt32(&s.state, int32(ConnectionStateClosed))
+	close(s.done)
+	s.server.removeSession(s.token)
+
+	if s.server.connHandler != nil {
+		s.server.connHandler.OnDisconnect(s, nil)
+	}
+	return nil
+}
+
+// State returns the current connection state
+func (s *httpSession) State() ConnectionState {
+	return ConnectionState(atomic.LoadInt32(&s.state))
+}
+
+// SetReadTimeout sets the timeout of ReadMessage
+func (s *httpSession) SetReadTimeout(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readTimeout = timeout
+}
+
+// SetWriteTimeout sets the write timeout
+func (s *httpSession) SetWriteTimeout(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeTimeout = timeout
+}
+
+// GetLastActivity returns the timestamp of last activity
+func (s *httpSession) GetLastActivity() time.Time {
+	return time.Unix(atomic.LoadInt64(&s.lastActivity), 0)
+}
+
+// GetUserData returns user-defined data
+func (s *httpSession) GetUserData() interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.userData
+}
+
+// SetUserData sets user-defined data
+func (s *httpSession) SetUserData(data interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userData = data
+}
+
+// ReadMessage returns the next upstream message when the server has no
+// message handler
+func (s *httpSession) ReadMessage() (*Message, error) {
+	s.mu.RLock()
+	timeout := s.readTimeout
+	s.mu.RUnlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case msg := <-s.inbox:
+		return msg, nil
+	case <-s.done:
+		return nil, io.EOF
+	case <-expired:
+		return nil, fmt.Errorf("read timeout on connection %s", s.id)
+	}
+}
+
+// GetStatistics returns connection statistics
+func (s *httpSession) GetStatistics() ConnectionStatistics {
+	return ConnectionStatistics{
+		ConnectionID: s.id,
+		State:        s.State(),
+		BytesRead:    atomic.LoadInt64(&s.bytesRead),
+		BytesWritten: atomic.LoadInt64(&s.bytesWritten),
+		MessagesRead: atomic.LoadInt64(&s.messagesRead),
+		MessagesSent: atomic.LoadInt64(&s.messagesSent),
+		LastActivity: s.GetLastActivity(),
+		RemoteAddr:   s.remote.String(),
+		LocalAddr:    s.local.String(),
+	}
+}
+
+// receive delivers upstream frames in sequence order. Frames the session
+// already accepted are skipped, so clients can safely retry a failed POST
+func (s *httpSession) receive(frames []fallbackFrame) error {
+	s.inMu.Lock()
+	defer s.inMu.Unlock()
+
+	atomic.StoreInt64(&s.lastActivity, time.Now().Unix())
+
+	for _, frame := range frames {
+		if frame.seq <= s.lastInbound {
+			continue
+		}
+		if frame.seq != s.lastInbound+1 {
+			return fmt.Errorf("%w: expected upstream frame %d, got %d", ErrFallbackSessionGap, s.lastInbound+1, frame.seq)
+		}
+
+		msg, err := s.codec.Decode(frame.data)
+		if err != nil {
+			return fmt.Errorf("failed to decode frame %d: %w", frame.seq, err)
+		}
+		msg.ConnectionID = s.id
+
+		if handler := s.server.msgHandler; handler != nil {
+			handler.OnMessage(s, msg)
+		} else {
+			select {
+			case s.inbox <- msg:
+			default:
+				return fmt.Errorf("connection %s: %w", s.id, ErrFallbackBufferFull)
+			}
+		}
+
+		s.lastInbound = frame.seq
+		atomic.AddInt64(&s.bytesRead, int64(len(frame.data)))
+		atomic.AddInt64(&s.messagesRead, 1)
+		atomic.AddInt64(&s.server.totalMessages, 1)
+	}
+	return nil
+}
+
+// poll drops frames up to ack and waits up to timeout for newer ones
+func (s *httpSession) poll(ctx context.Context, ack uint64, timeout time.Duration) ([]fallbackFrame, error) {
+	atomic.StoreInt64(&s.lastPoll, time.Now().UnixNano())
+	defer atomic.StoreInt64(&s.lastPoll, time.Now().UnixNano())
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.outMu.Lock()
+		if ack < s.acked || ack >= s.nextSeq {
+			s.outMu.Unlock()
+			return nil, fmt.Errorf("%w: ack %d outside [%d, %d)", ErrFallbackSessionGap, ack, s.acked, s.nextSeq)
+		}
+
+		s.acked = ack
+		drop := 0
+		for drop < len(s.outbox) && s.outbox[drop].seq <= ack {
+			drop++
+		}
+		s.outbox = s.outbox[drop:]
+
+		if len(s.outbox) > 0 {
+			frames := append([]fallbackFrame(nil), s.outbox...)
+			s.outMu.Unlock()
+			atomic.StoreInt64(&s.lastActivity, time.Now().Unix())
+			return frames, nil
+		}
+
+		notify := s.notify
+		s.outMu.Unlock()
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.done:
+			return nil, ErrFallbackSessionNotFound
+		}
+	}
+}
+
+// idleFor returns how long the session has gone without a poll
+func (s *httpSession) idleFor() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&s.lastPoll)))
+}
@@ -0,0 +1,558 @@
+// Package network provides the client side of the HTTP long-polling fallback
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fallbackSendAttempts is how often an upstream POST is tried before the
+// session is considered broken
+const fallbackSendAttempts = 3
+
+// httpClientConnection implements the Connection interface over a fallback
+// session opened with openFallbackSession
+type httpClientConnection struct {
+	id      string
+	token   string
+	baseURL string
+	client  *http.Client
+	codec   MessageCodec
+
+	state        int32 // ConnectionState as atomic int32
+	closed       int32 // atomic flag
+	done         chan struct{}
+	err          error
+	errMu        sync.Mutex
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	retry        time.Duration
+	lastActivity int64 // Unix timestamp as atomic int64
+	userData     interface{}
+	msgHandler   MessageHandler
+	mu           sync.RWMutex
+
+	// Upstream sequence, advanced once the server accepted a frame
+	sendMu  sync.Mutex
+	sendSeq uint64
+
+	// Highest downstream sequence delivered, acknowledged by the next poll
+	lastRecv uint64
+	inbox    chan *Message
+
+	// Statistics
+	bytesRead    int64
+	bytesWritten int64
+	messagesRead int64
+	messagesSent int64
+}
+
+// openFallbackSession opens a session at baseURL and starts polling it
+func openFallbackSession(baseURL string, config *NetworkConfig, handler MessageHandler) (*httpClientConnection, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	client := &http.Client{Timeout: config.FallbackPollTimeout + config.WriteTimeout}
+
+	resp, err := client.Post(baseURL+FallbackPathOpen, "application/octet-stream", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fallback session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to open fallback session: %s", resp.Status)
+	}
+
+	token := resp.Header.Get(FallbackSessionHeader)
+	if token == "" {
+		return nil, fmt.Errorf("failed to open fallback session: no session token")
+	}
+
+	conn := &httpClientConnection{
+		id:           fmt.Sprintf("http-%d", atomic.AddInt64(&connectionIDCounter, 1)),
+		token:        token,
+		baseURL:      baseURL,
+		client:       client,
+		codec:        NewBinaryMessageCodec(),
+		state:        int32(ConnectionStateConnected),
+		done:         make(chan struct{}),
+		readTimeout:  config.ReadTimeout,
+		writeTimeout: config.WriteTimeout,
+		retry:        time.Second,
+		lastActivity: time.Now().Unix(),
+		msgHandler:   handler,
+		inbox:        make(chan *Message, 256),
+	}
+
+	go conn.pollLoop()
+	return conn, nil
+}
+
+// ID returns the connection ID
+func (c *httpClientConnection) ID() string {
+	return c.id
+}
+
+// RemoteAddr returns the fallback endpoint
+func (c *httpClientConnection) RemoteAddr() net.Addr {
+	return httpAddr(c.baseURL)
+}
+
+// LocalAddr returns nil, the local socket changes between requests
+func (c *httpClientConnection) LocalAddr() net.Addr {
+	return nil
+}
+
+// Send posts an encoded frame, retrying transient failures with the same
+// sequence so the server drops duplicates
+func (c *httpClientConnection) Send(data []byte) error {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return fmt.Errorf("connection %s is closed", c.id)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	var body bytes.Buffer
+	seq := c.sendSeq + 1
+	writeFallbackFrames(&body, []fallbackFrame{{seq: seq, data: data}})
+
+	var err error
+	for attempt := 1; attempt <= fallbackSendAttempts; attempt++ {
+		var status int
+		status, err = c.do(http.MethodPost, FallbackPathSend, body.Bytes(), nil)
+		if err == nil && status == http.StatusNoContent {
+			c.sendSeq = seq
+			atomic.AddInt64(&c.bytesWritten, int64(len(data)))
+			atomic.StoreInt64(&c.lastActivity, time.Now().Unix())
+			return nil
+		}
+
+		if err == nil {
+			err = statusError(status)
+			if status != http.StatusServiceUnavailable {
+				break
+			}
+		}
+
+		select {
+		case <-time.After(c.retry * time.Duration(attempt)):
+		case <-c.done:
+			return fmt.Errorf("connection %s is closed", c.id)
+		}
+	}
+
+	c.fail(err)
+	return fmt.Errorf("failed to send on connection %s: %w", c.id, err)
+}
+
+// SendMessage encodes and posts a message
+func (c *httpClientConnection) SendMessage(msg *Message) error {
+	if msg == nil {
+		return fmt.Errorf("message is nil")
+	}
+
+	msg.ConnectionID = c.id
+	data, err := c.codec.Encode(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	if err := c.Send(data); err != nil {
+		return err
+	}
+	atomic.AddInt64(&c.messagesSent, 1)
+	return nil
+}
+
+// Close closes the session on both sides
+func (c *httpClientConnection) Close() error {
+	if !c.shutdown(nil) {
+		return nil
+	}
+
+	c.do(http.MethodPost, FallbackPathClose, nil, nil)
+	return nil
+}
+
+// State returns the current connection state
+func (c *httpClientConnection) State() ConnectionState {
+	return ConnectionState(atomic.LoadInt32(&c.state))
+}
+
+// SetReadTimeout sets the timeout of ReadMessage
+func (c *httpClientConnection) SetReadTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readTimeout = timeout
+}
+
+// SetWriteTimeout sets the write timeout
+func (c *httpClientConnection) SetWriteTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeTimeout = timeout
+}
+
+// GetLastActivity returns the timestamp of last activity
+func (c *httpClientConnection) GetLastActivity() time.Time {
+	return time.Unix(atomic.LoadInt64(&c.lastActivity), 0)
+}
+
+// GetUserData returns user-defined data
+func (c *httpClientConnection) GetUserData() interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.userData
+}
+
+// SetUserData sets user-defined data
+func (c *httpClientConnection) SetUserData(data interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.userData = data
+}
+
+// ReadMessage returns the next downstream message when no message handler
+// is set
+func (c *httpClientConnection) ReadMessage() (*Message, error) {
+	c.mu.RLock()
+	timeout := c.readTimeout
+	c.mu.RUnlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case msg := <-c.inbox:
+		return msg, nil
+	case <-c.done:
+		if err := c.failure(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	case <-expired:
+		return nil, fmt.Errorf("read timeout on connection %s", c.id)
+	}
+}
+
+// GetStatistics returns connection statistics
+func (c *httpClientConnection) GetStatistics() ConnectionStatistics {
+	return ConnectionStatistics{
+		ConnectionID: c.id,
+		State:        c.State(),
+		BytesRead:    atomic.LoadInt64(&c.bytesRead),
+		BytesWritten: atomic.LoadInt64(&c.bytesWritten),
+		MessagesRead: atomic.LoadInt64(&c.messagesRead),
+		MessagesSent: atomic.LoadInt64(&c.messagesSent),
+		LastActivity: c.GetLastActivity(),
+		RemoteAddr:   c.baseURL,
+	}
+}
+
+// setMessageHandler replaces the handler downstream messages go to
+func (c *httpClientConnection) setMessageHandler(handler MessageHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgHandler = handler
+}
+
+// pollLoop long-polls for downstream frames. A failed poll is retried with
+// the same ack, so the server replays whatever was lost in transit
+func (c *httpClientConnection) pollLoop() {
+	for atomic.LoadInt32(&c.closed) == 0 {
+		var body bytes.Buffer
+		query := "?ack=" + strconv.FormatUint(c.lastRecv, 10)
+		status, err := c.do(http.MethodGet, FallbackPathPoll+query, nil, &body)
+		if err == nil && status != http.StatusOK {
+			err = statusError(status)
+			if status == http.StatusNotFound || status == http.StatusGone {
+				c.fail(err)
+				return
+			}
+		}
+
+		if err != nil {
+			select {
+			case <-time.After(c.retry):
+			case <-c.done:
+				return
+			}
+			continue
+		}
+
+		frames, err := readFallbackFrames(&body)
+		if err != nil {
+			continue // Nothing was acknowledged, the next poll replays
+		}
+
+		if err := c.deliver(frames); err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
+
+// deliver decodes downstream frames in order and dispatches them
+func (c *httpClientConnection) deliver(frames []fallbackFrame) error {
+	for _, frame := range frames {
+		if frame.seq <= c.lastRecv {
+			continue
+		}
+		if frame.seq != c.lastRecv+1 {
+			return fmt.Errorf("%w: expected downstream frame %d, got %d", ErrFallbackSessionGap, c.lastRecv+1, frame.seq)
+		}
+		c.lastRecv = frame.seq
+
+		msg, err := c.codec.Decode(frame.data)
+		if err != nil {
+			continue // Frame boundaries are intact, drop just this frame
+		}
+		msg.ConnectionID = c.id
+
+		atomic.AddInt64(&c.bytesRead, int64(len(frame.data)))
+		atomic.AddInt64(&c.messagesRead, 1)
+		atomic.StoreInt64(&c.lastActivity, time.Now().Unix())
+
+		c.mu.RLock()
+		handler := c.msgHandler
+		c.mu.RUnlock()
+
+		if handler != nil {
+			handler.OnMessage(c, msg)
+			continue
+		}
+
+		select {
+		case c.inbox <- msg:
+		case <-c.done:
+			return nil
+		}
+	}
+	return nil
+}
+
+// do sends one request carrying the session token; out receives the body
+func (c *httpClientConnection) do(method, path string, body []byte, out io.Writer) (int, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set(FallbackSessionHeader, c.token)
+	req.AddCookie(&http.Cookie{Name: FallbackSessionCookie, Value: c.token})
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode == http.StatusOK {
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			return 0, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// fail closes the connection locally because of err
+func (c *httpClientConnection) fail(err error) {
+	c.shutdown(err)
+}
+
+// shutdown marks the connection closed, recording err as the cause
+func (c *httpClientConnection) shutdown(err error) bool {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return false
+	}
+
+	c.errMu.Lock()
+	c.err = err
+	c.errMu.Unlock()
+
+	atomic.StoreInt32(&c.state, int32(ConnectionStateClosed))
+	close(c.done)
+
+	c.mu.RLock()
+	handler := c.msgHandler
+	c.mu.RUnlock()
+	if handler != nil && err != nil {
+		handler.OnError(c, err)
+	}
+	return true
+}
+
+// failure returns why the connection was closed, if it failed
+func (c *httpClientConnection) failure() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.err
+}
+
+// statusError maps a fallback response status back to a session error
+func statusError(status int) error {
+	switch status {
+	case http.StatusNotFound:
+		return ErrFallbackSessionNotFound
+	case http.StatusGone, http.StatusConflict:
+		return ErrFallbackSessionGap
+	case http.StatusServiceUnavailable:
+		return ErrFallbackBufferFull
+	default:
+		return fmt.Errorf("unexpected fallback status %d", status)
+	}
+}
+
+// fallbackClient is a Client that connects over TCP first and negotiates
+// the HTTP long-polling fallback when TCP cannot be established
+type fallbackClient struct {
+	Client
+
+	config     *NetworkConfig
+	msgHandler MessageHandler
+	fallback   *httpClientConnection
+	mu         sync.RWMutex
+}
+
+// NewFallbackClient creates a TCP client that falls back to the HTTP
+// long-polling transport at config.FallbackURL
+func NewFallbackClient(config *NetworkConfig) (Client, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	if config.FallbackURL == "" {
+		return nil, fmt.Errorf("fallback URL is not configured")
+	}
+
+	tcp, err := NewTCPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fallbackClient{Client: tcp, config: config}, nil
+}
+
+// Connect connects to address, falling back to HTTP if TCP fails
+func (fc *fallbackClient) Connect(address string) (Connection, error) {
+	return fc.ConnectWithTimeout(address, 30*time.Second)
+}
+
+// ConnectWithTimeout tries TCP within timeout, then the HTTP fallback
+func (fc *fallbackClient) ConnectWithTimeout(address string, timeout time.Duration) (Connection, error) {
+	conn, err := fc.Client.ConnectWithTimeout(address, timeout)
+	if err == nil {
+		return conn, nil
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fallback, fallbackErr := openFallbackSession(fc.config.FallbackURL, fc.config, fc.msgHandler)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%v; fallback: %w", err, fallbackErr)
+	}
+
+	if fc.fallback != nil {
+		fc.fallback.Close()
+	}
+	fc.fallback = fallback
+	return fallback, nil
+}
+
+// ConnectAsync connects asynchronously
+func (fc *fallbackClient) ConnectAsync(address string) <-chan ConnectionResult {
+	resultChan := make(chan ConnectionResult, 1)
+
+	go func() {
+		conn, err := fc.Connect(address)
+		resultChan <- ConnectionResult{Connection: conn, Error: err}
+		close(resultChan)
+	}()
+
+	return resultChan
+}
+
+// Disconnect closes the fallback session or the TCP connection
+func (fc *fallbackClient) Disconnect() error {
+	fc.mu.Lock()
+	fallback := fc.fallback
+	fc.fallback = nil
+	fc.mu.Unlock()
+
+	if fallback != nil {
+		return fallback.Close()
+	}
+	return fc.Client.Disconnect()
+}
+
+// GetConnection returns the active connection of either transport
+func (fc *fallbackClient) GetConnection() Connection {
+	if fallback := fc.activeFallback(); fallback != nil {
+		return fallback
+	}
+	return fc.Client.GetConnection()
+}
+
+// SetMessageHandler sets the handler for both transports
+func (fc *fallbackClient) SetMessageHandler(handler MessageHandler) {
+	fc.mu.Lock()
+	fc.msgHandler = handler
+	if fc.fallback != nil {
+		fc.fallback.setMessageHandler(handler)
+	}
+	fc.mu.Unlock()
+
+	fc.Client.SetMessageHandler(handler)
+}
+
+// IsConnected returns true if either transport is connected
+func (fc *fallbackClient) IsConnected() bool {
+	if fallback := fc.activeFallback(); fallback != nil {
+		return true
+	}
+	return fc.Client.IsConnected()
+}
+
+// SendMessage sends through whichever transport is connected
+func (fc *fallbackClient) SendMessage(msg *Message) error {
+	if fallback := fc.activeFallback(); fallback != nil {
+		return fallback.SendMessage(msg)
+	}
+	return fc.Client.SendMessage(msg)
+}
+
+// activeFallback returns the fallback session if it is open
+func (fc *fallbackClient) activeFallback() *httpClientConnection {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	if fc.fallback == nil || fc.fallback.State() != ConnectionStateConnected {
+		return nil
+	}
+	return fc.fallback
+}
+
+// IsFallback returns true if conn uses the HTTP long-polling transport
+func IsFallback(conn Connection) bool {
+	switch conn.(type) {
+	case *httpClientConnection, *httpSession:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,160 @@
+// Package network provides tests for the HTTP long-polling fallback transport
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newEchoFallbackServer serves a fallback server that echoes data frames
+func newEchoFallbackServer(t *testing.T) (*httpFallbackServer, *httptest.Server) {
+	config := DefaultNetworkConfig()
+	config.Protocol = ProtocolHTTP
+	config.FallbackPollTimeout = 200 * time.Millisecond
+
+	server, err := NewHTTPFallbackServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create fallback server: %v", err)
+	}
+
+	server.SetMessageHandler(&testMessageHandler{
+		onMessage: func(conn Connection, msg *Message) {
+			conn.SendMessage(NewMessage(MessageTypeData, msg.Data))
+		},
+	})
+
+	hs := server.(*httpFallbackServer)
+	return hs, httptest.NewServer(hs)
+}
+
+func TestHTTPFallbackRoundTrip(t *testing.T) {
+	server, ts := newEchoFallbackServer(t)
+	defer ts.Close()
+	defer server.Stop()
+
+	config := DefaultNetworkConfig()
+	config.FallbackPollTimeout = 200 * time.Millisecond
+	config.ReadTimeout = time.Second
+
+	conn, err := openFallbackSession(ts.URL+"/", config, nil)
+	if err != nil {
+		t.Fatalf("Failed to open session: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		payload := []byte{byte('a' + i)}
+		if err := conn.SendMessage(NewMessage(MessageTypeData, payload)); err != nil {
+			t.Fatalf("Failed to send message %d: %v", i, err)
+		}
+
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read echo %d: %v", i, err)
+		}
+		if err := ExpectFrame(msg, MessageTypeData, payload); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if server.GetConnectionCount() != 1 {
+		t.Errorf("Expected 1 session, got %d", server.GetConnectionCount())
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for server.GetConnectionCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if server.GetConnectionCount() != 0 {
+		t.Error("Expected session to be closed on the server")
+	}
+}
+
+func TestHTTPFallbackSequencing(t *testing.T) {
+	server, ts := newEchoFallbackServer(t)
+	defer ts.Close()
+	defer server.Stop()
+
+	session := newHTTPSession(server, "token", httpAddr("client"), httpAddr("server"))
+	for _, data := range []string{"one", "two", "three"} {
+		session.Send([]byte(data))
+	}
+
+	// A lost poll response is replayed by polling with the same ack
+	frames, err := session.poll(context.Background(), 0, time.Second)
+	if err != nil || len(frames) != 3 {
+		t.Fatalf("Expected 3 frames, got %d (%v)", len(frames), err)
+	}
+	frames, _ = session.poll(context.Background(), 1, time.Second)
+	if len(frames) != 2 || frames[0].seq != 2 {
+		t.Fatalf("Expected replay from frame 2, got %+v", frames)
+	}
+
+	// Going back past an acknowledged frame is an unrecoverable gap
+	if _, err := session.poll(context.Background(), 0, time.Second); !errors.Is(err, ErrFallbackSessionGap) {
+		t.Errorf("Expected gap error, got %v", err)
+	}
+
+	// Retried upstream frames are delivered once, skipped frames are a gap
+	codec := NewBinaryMessageCodec()
+	data, _ := codec.Encode(NewMessage(MessageTypeData, []byte("up")))
+	frame := fallbackFrame{seq: 1, data: data}
+	session.receive([]fallbackFrame{frame})
+	session.receive([]fallbackFrame{frame})
+	if got := session.GetStatistics().MessagesRead; got != 1 {
+		t.Errorf("Expected duplicate frame to be dropped, got %d messages", got)
+	}
+	if err := session.receive([]fallbackFrame{{seq: 3, data: data}}); !errors.Is(err, ErrFallbackSessionGap) {
+		t.Errorf("Expected upstream gap error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	writeFallbackFrames(&buf, []fallbackFrame{frame, {seq: 2, data: data}})
+	decoded, err := readFallbackFrames(&buf)
+	if err != nil || len(decoded) != 2 || decoded[1].seq != 2 || !bytes.Equal(decoded[1].data, data) {
+		t.Errorf("Frame batch did not round trip: %+v (%v)", decoded, err)
+	}
+}
+
+func TestFallbackClientNegotiation(t *testing.T) {
+	server, ts := newEchoFallbackServer(t)
+	defer ts.Close()
+	defer server.Stop()
+
+	config := DefaultNetworkConfig()
+	config.FallbackURL = ts.URL
+	config.FallbackPollTimeout = 200 * time.Millisecond
+	config.ReadTimeout = time.Second
+
+	client, err := NewNetworkFactory().CreateClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Nothing listens on port 1, so the client negotiates the fallback
+	conn, err := client.ConnectWithTimeout("127.0.0.1:1", 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected fallback connection, got %v", err)
+	}
+	defer client.Disconnect()
+
+	if !IsFallback(conn) || !client.IsConnected() {
+		t.Fatal("Expected client to be connected over the fallback transport")
+	}
+
+	if err := client.SendMessage(NewMessage(MessageTypeData, []byte("ping"))); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if err := ExpectFrame(msg, MessageTypeData, []byte("ping")); err != nil {
+		t.Error(err)
+	}
+}
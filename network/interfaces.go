@@ -11,8 +11,12 @@ import (
 type Protocol string
 
 const (
-	ProtocolTCP Protocol = "tcp"
-	ProtocolUDP Protocol = "udp"
+	ProtocolTCP  Protocol = "tcp"
+	ProtocolUDP  Protocol = "udp"
+	ProtocolHTTP Protocol = "http"
+
+	// ProtocolUnix is a same-host Unix domain socket; Address is its path
+	ProtocolUnix Protocol = "unix"
 )
 
 // ConnectionState represents the state of a network connection
@@ -119,6 +123,60 @@ type Server interface {
 	BroadcastMessage(msg *Message) error
 }
 
+// ProtocolRoutable is implemented by servers that can share one port
+// between several protocols selected by the first inbound frame
+type ProtocolRoutable interface {
+	// SetProtocolRouter sets the router used for new connections
+	SetProtocolRouter(router *ProtocolRouter)
+}
+
+// AdmissionControllable is implemented by servers that can hold back
+// inbound messages while not ready for full traffic
+type AdmissionControllable interface {
+	// SetAdmissionController sets the controller admitting inbound messages
+	SetAdmissionController(controller *AdmissionController)
+}
+
+// ChecksumConfigurable is implemented by connections supporting frame checksums
+type ChecksumConfigurable interface {
+	// SetChecksum checksums outgoing frames and requires checksums on
+	// inbound ones
+	SetChecksum(enabled bool)
+}
+
+// UsageTrackable is implemented by connections counting their frames per
+// message type
+type UsageTrackable interface {
+	// SetUsage sets the counters frames are recorded in
+	SetUsage(usage *ProtocolUsage)
+}
+
+// LatencyTrackable is implemented by connections supporting frame timestamps
+type LatencyTrackable interface {
+	// SetTimestamps enables outgoing send timestamps and periodic clock
+	// sync requests every syncInterval
+	SetTimestamps(enabled bool, syncInterval time.Duration)
+
+	// Latency returns the connection's latency tracker
+	Latency() *LatencyTracker
+}
+
+// LivenessObservable is implemented by connections tracking inbound traffic
+// for liveness checks
+type LivenessObservable interface {
+	// LastRead returns when the last frame was read
+	LastRead() time.Time
+
+	// LastHeartbeat returns when the peer last sent a heartbeat (zero if never)
+	LastHeartbeat() time.Time
+}
+
+// IPStatsProvider is implemented by servers that aggregate traffic by remote IP
+type IPStatsProvider interface {
+	// IPStats returns the per-IP statistics tracker
+	IPStats() *IPStatsTracker
+}
+
 // Client represents a network client
 type Client interface {
 	// Connect connects to the remote server
@@ -208,6 +266,10 @@ type ConnectionManager interface {
 	// StopHeartbeat stops heartbeat
 	StopHeartbeat() error
 
+	// SetLivenessPolicy sets the policy applied by StartHeartbeat; its
+	// heartbeat interval is replaced by the one passed to StartHeartbeat
+	SetLivenessPolicy(policy LivenessPolicy)
+
 	// Cleanup removes inactive connections
 	Cleanup(timeout time.Duration) int
 
@@ -226,10 +288,10 @@ type ConnectionManager interface {
 
 // NetworkConfig represents network configuration
 type NetworkConfig struct {
-	// Protocol is the network protocol (tcp, udp)
+	// Protocol is the network protocol (tcp, udp, unix)
 	Protocol Protocol
 
-	// Address is the listening address
+	// Address is the listening address, or the socket path for unix
 	Address string
 
 	// Port is the listening port
@@ -256,11 +318,70 @@ type NetworkConfig struct {
 	// HeartbeatInterval is the heartbeat interval
 	HeartbeatInterval time.Duration
 
+	// Liveness unifies heartbeats and TCP keep-alive; its zero fields fall
+	// back to HeartbeatInterval, KeepAlive and KeepAliveInterval. Servers
+	// and clients only send heartbeats, and drop the peers that stop
+	// answering them, when Mode is LivenessAuto or LivenessHeartbeat.
+	Liveness LivenessPolicy
+
 	// ReconnectInterval is the auto-reconnect interval
 	ReconnectInterval time.Duration
 
 	// MaxReconnectAttempts is the maximum number of reconnect attempts
 	MaxReconnectAttempts int
+
+	// IPStatsCapacity caps how many remote IPs are tracked for statistics
+	IPStatsCapacity int
+
+	// FrameChecksum adds a CRC32-C checksum to every outgoing frame and
+	// closes the connection on an inbound frame without a valid one. It
+	// is a setting of the connection, not of each frame, so both ends
+	// must enable it.
+	FrameChecksum bool
+
+	// FrameTimestamps stamps every outgoing frame with its send time and
+	// syncs clocks with the peer to estimate one-way delay and jitter
+	FrameTimestamps bool
+
+	// ClockSyncInterval is how often a timestamping connection re-syncs
+	// its clock offset estimate with the peer
+	ClockSyncInterval time.Duration
+
+	// WriteCoalescing batches small outgoing frames into fewer writes;
+	// connections implementing WriteCoalescable can override it per class
+	WriteCoalescing CoalescingPolicy
+
+	// FallbackURL is the HTTP long-polling endpoint clients switch to when
+	// a TCP connection cannot be established (empty disables the fallback)
+	FallbackURL string
+
+	// FallbackPollTimeout is how long a long-poll waits for downstream frames
+	FallbackPollTimeout time.Duration
+
+	// FallbackSessionTimeout closes fallback sessions that stop polling
+	FallbackSessionTimeout time.Duration
+
+	// FallbackBufferSize caps the unacknowledged downstream frames kept per
+	// fallback session for gap recovery
+	FallbackBufferSize int
+
+	// Framing is the wire format of stream connections; the skynet
+	// framings let skynet gateserver and msgserver clients connect, and
+	// FramingJSON clients without a binary codec
+	Framing Framing
+
+	// ICEServers are the STUN and TURN servers of WebRTC peers
+	ICEServers []ICEServer
+
+	// DataChannelMode selects reliable or unreliable WebRTC data channels
+	DataChannelMode DataChannelMode
+
+	// SignalingTimeout bounds answering a WebRTC offer
+	SignalingTimeout time.Duration
+
+	// MTU configures path MTU discovery and fragmentation of UDP servers
+	// and clients (nil uses DefaultMTUConfig)
+	MTU *MTUConfig
 }
 
 // DefaultNetworkConfig returns a default network configuration
@@ -276,8 +397,18 @@ func DefaultNetworkConfig() *NetworkConfig {
 		MaxConnections:       1000,
 		BufferSize:           4096,
 		HeartbeatInterval:    30 * time.Second,
+		Liveness:             LivenessPolicy{Mode: LivenessKeepAlive, MissThreshold: 3},
 		ReconnectInterval:    5 * time.Second,
 		MaxReconnectAttempts: 3,
+		IPStatsCapacity:      DefaultIPStatsCapacity,
+		ClockSyncInterval:    10 * time.Second,
+
+		FallbackPollTimeout:    25 * time.Second,
+		FallbackSessionTimeout: 60 * time.Second,
+		FallbackBufferSize:     1024,
+
+		ICEServers:       []ICEServer{{URLs: []string{"stun:stun.l.google.com:19302"}}},
+		SignalingTimeout: 10 * time.Second,
 	}
 }
 
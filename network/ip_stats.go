@@ -0,0 +1,265 @@
+// Package network provides per-IP traffic accounting
+package network
+
+import (
+	"container/list"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultIPStatsCapacity is the default number of remote IPs tracked at once
+const DefaultIPStatsCapacity = 4096
+
+// IPStatsSortKey selects the counter used to rank IPs in a TopN query
+type IPStatsSortKey string
+
+const (
+	SortByConnections IPStatsSortKey = "connections"
+	SortByBytes       IPStatsSortKey = "bytes"
+	SortByMessages    IPStatsSortKey = "messages"
+	SortByErrors      IPStatsSortKey = "errors"
+	SortByRejected    IPStatsSortKey = "rejected"
+)
+
+// IPStatistics holds traffic counters aggregated for one remote IP
+type IPStatistics struct {
+	IP                string    `json:"ip"`
+	TotalConnections  int64     `json:"total_connections"`
+	ActiveConnections int64     `json:"active_connections"`
+	Rejected          int64     `json:"rejected"`
+	BytesIn           int64     `json:"bytes_in"`
+	BytesOut          int64     `json:"bytes_out"`
+	MessagesIn        int64     `json:"messages_in"`
+	Errors            int64     `json:"errors"`
+	FirstSeen         time.Time `json:"first_seen"`
+	LastSeen          time.Time `json:"last_seen"`
+}
+
+// value returns the counter selected by key
+func (s IPStatistics) value(key IPStatsSortKey) int64 {
+	switch key {
+	case SortByConnections:
+		return s.TotalConnections
+	case SortByMessages:
+		return s.MessagesIn
+	case SortByErrors:
+		return s.Errors
+	case SortByRejected:
+		return s.Rejected
+	default:
+		return s.BytesIn + s.BytesOut
+	}
+}
+
+// GlobalIPStatistics holds counters across all remote IPs, including evicted ones
+type GlobalIPStatistics struct {
+	TrackedIPs       int   `json:"tracked_ips"`
+	EvictedIPs       int64 `json:"evicted_ips"`
+	TotalConnections int64 `json:"total_connections"`
+	Rejected         int64 `json:"rejected"`
+	BytesIn          int64 `json:"bytes_in"`
+	BytesOut         int64 `json:"bytes_out"`
+	MessagesIn       int64 `json:"messages_in"`
+	Errors           int64 `json:"errors"`
+}
+
+// IPStatsTracker aggregates connection and traffic statistics by remote IP.
+// Memory is capped by a bounded LRU: once capacity is reached the least
+// recently active IP is evicted. Global counters keep evicted traffic.
+type IPStatsTracker struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	lru      *list.List // front = most recently active
+	global   GlobalIPStatistics
+}
+
+// NewIPStatsTracker creates a tracker holding at most capacity IPs
+func NewIPStatsTracker(capacity int) *IPStatsTracker {
+	if capacity <= 0 {
+		capacity = DefaultIPStatsCapacity
+	}
+
+	return &IPStatsTracker{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// RecordAccept records an accepted connection from addr
+func (t *IPStatsTracker) RecordAccept(addr net.Addr) {
+	t.update(addr, func(s *IPStatistics) {
+		s.TotalConnections++
+		s.ActiveConnections++
+		t.global.TotalConnections++
+	})
+}
+
+// RecordReject records a connection from addr rejected before being served
+func (t *IPStatsTracker) RecordReject(addr net.Addr) {
+	t.update(addr, func(s *IPStatistics) {
+		s.Rejected++
+		t.global.Rejected++
+	})
+}
+
+// RecordClose records that a connection from addr has closed
+func (t *IPStatsTracker) RecordClose(addr net.Addr, bytesOut int64) {
+	t.update(addr, func(s *IPStatistics) {
+		if s.ActiveConnections > 0 {
+			s.ActiveConnections--
+		}
+		s.BytesOut += bytesOut
+		t.global.BytesOut += bytesOut
+	})
+}
+
+// RecordMessage records an inbound message of size bytes from addr
+func (t *IPStatsTracker) RecordMessage(addr net.Addr, size int) {
+	t.update(addr, func(s *IPStatistics) {
+		s.MessagesIn++
+		s.BytesIn += int64(size)
+		t.global.MessagesIn++
+		t.global.BytesIn += int64(size)
+	})
+}
+
+// RecordError records a protocol or I/O error on a connection from addr
+func (t *IPStatsTracker) RecordError(addr net.Addr) {
+	t.update(addr, func(s *IPStatistics) {
+		s.Errors++
+		t.global.Errors++
+	})
+}
+
+// Get returns the statistics for a single IP
+func (t *IPStatsTracker) Get(ip string) (IPStatistics, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, exists := t.entries[ip]; exists {
+		return *elem.Value.(*IPStatistics), true
+	}
+	return IPStatistics{}, false
+}
+
+// TopN returns the n IPs with the highest value for key, highest first
+func (t *IPStatsTracker) TopN(n int, key IPStatsSortKey) []IPStatistics {
+	t.mu.Lock()
+	all := make([]IPStatistics, 0, len(t.entries))
+	for elem := t.lru.Front(); elem != nil; elem = elem.Next() {
+		all = append(all, *elem.Value.(*IPStatistics))
+	}
+	t.mu.Unlock()
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].value(key) > all[j].value(key)
+	})
+
+	if n > 0 && n < len(all) {
+		all = all[:n]
+	}
+	return all
+}
+
+// Global returns counters aggregated across all IPs
+func (t *IPStatsTracker) Global() GlobalIPStatistics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	global := t.global
+	global.TrackedIPs = len(t.entries)
+	return global
+}
+
+// Reset clears all per-IP and global counters
+func (t *IPStatsTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries = make(map[string]*list.Element)
+	t.lru.Init()
+	t.global = GlobalIPStatistics{}
+}
+
+// ServeHTTP exports the global counters and top talkers as JSON so the
+// tracker can be mounted on the monitor HTTP server. Query parameters:
+// n (default 10) and by (connections, bytes, messages, errors, rejected).
+func (t *IPStatsTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := 10
+	if v := r.URL.Query().Get("n"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			n = parsed
+		}
+	}
+
+	key := SortByBytes
+	if v := r.URL.Query().Get("by"); v != "" {
+		key = IPStatsSortKey(v)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Global GlobalIPStatistics `json:"global"`
+		SortBy IPStatsSortKey     `json:"sort_by"`
+		Top    []IPStatistics     `json:"top"`
+	}{
+		Global: t.Global(),
+		SortBy: key,
+		Top:    t.TopN(n, key),
+	})
+}
+
+// update applies fn to the entry for addr, creating and evicting as needed
+func (t *IPStatsTracker) update(addr net.Addr, fn func(s *IPStatistics)) {
+	ip := remoteIP(addr)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var stats *IPStatistics
+	if elem, exists := t.entries[ip]; exists {
+		t.lru.MoveToFront(elem)
+		stats = elem.Value.(*IPStatistics)
+	} else {
+		stats = &IPStatistics{IP: ip, FirstSeen: now}
+		t.entries[ip] = t.lru.PushFront(stats)
+
+		for t.lru.Len() > t.capacity {
+			oldest := t.lru.Back()
+			t.lru.Remove(oldest)
+			delete(t.entries, oldest.Value.(*IPStatistics).IP)
+			t.global.EvictedIPs++
+		}
+	}
+
+	stats.LastSeen = now
+	fn(stats)
+}
+
+// remoteIP extracts the host part of a network address
+func remoteIP(addr net.Addr) string {
+	if addr == nil {
+		return "unknown"
+	}
+
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP.String()
+	case *net.UDPAddr:
+		return a.IP.String()
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
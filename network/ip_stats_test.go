@@ -0,0 +1,70 @@
+// Package network provides tests for per-IP statistics
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPStatsTracker(t *testing.T) {
+	tracker := NewIPStatsTracker(10)
+
+	a := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}
+	a2 := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1001}
+	b := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 2000}
+
+	tracker.RecordAccept(a)
+	tracker.RecordAccept(a2)
+	tracker.RecordAccept(b)
+	tracker.RecordMessage(a, 100)
+	tracker.RecordMessage(b, 500)
+	tracker.RecordError(b)
+	tracker.RecordClose(a, 40)
+
+	stats, ok := tracker.Get("10.0.0.1")
+	if !ok {
+		t.Fatal("Expected stats for 10.0.0.1")
+	}
+	if stats.TotalConnections != 2 || stats.ActiveConnections != 1 {
+		t.Errorf("Expected 2 total / 1 active connections, got %d / %d",
+			stats.TotalConnections, stats.ActiveConnections)
+	}
+	if stats.BytesIn != 100 || stats.BytesOut != 40 {
+		t.Errorf("Expected 100 bytes in / 40 out, got %d / %d", stats.BytesIn, stats.BytesOut)
+	}
+
+	top := tracker.TopN(1, SortByConnections)
+	if len(top) != 1 || top[0].IP != "10.0.0.1" {
+		t.Errorf("Expected 10.0.0.1 as top by connections, got %v", top)
+	}
+
+	top = tracker.TopN(1, SortByErrors)
+	if len(top) != 1 || top[0].IP != "10.0.0.2" {
+		t.Errorf("Expected 10.0.0.2 as top by errors, got %v", top)
+	}
+
+	global := tracker.Global()
+	if global.TotalConnections != 3 || global.BytesIn != 600 || global.TrackedIPs != 2 {
+		t.Errorf("Unexpected global stats: %+v", global)
+	}
+}
+
+func TestIPStatsTrackerEviction(t *testing.T) {
+	tracker := NewIPStatsTracker(2)
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		tracker.RecordAccept(&net.TCPAddr{IP: net.ParseIP(ip)})
+	}
+
+	if _, ok := tracker.Get("10.0.0.1"); ok {
+		t.Error("Least recently active IP should be evicted")
+	}
+
+	global := tracker.Global()
+	if global.TrackedIPs != 2 || global.EvictedIPs != 1 {
+		t.Errorf("Expected 2 tracked / 1 evicted, got %d / %d", global.TrackedIPs, global.EvictedIPs)
+	}
+	if global.TotalConnections != 3 {
+		t.Errorf("Global counters should keep evicted traffic, got %d", global.TotalConnections)
+	}
+}
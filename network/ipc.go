@@ -0,0 +1,159 @@
+// Package network provides a same-host IPC transport over Unix domain sockets
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/najoast/sngo/core"
+)
+
+// ErrPeerCredentialsUnsupported is returned where the platform cannot
+// report the process on the other end of an IPC connection
+var ErrPeerCredentialsUnsupported = errors.New("peer credentials are not supported on this platform")
+
+// ipcSocketMode restricts who may connect to an IPC socket
+const ipcSocketMode = 0o660
+
+// PeerCredentials identifies the process on the other end of an IPC connection
+type PeerCredentials struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// PeerAuthorizer decides whether a local process may use an IPC server;
+// returning an error rejects the connection
+type PeerAuthorizer func(creds PeerCredentials) error
+
+// NewIPCServer creates a server listening on the Unix domain socket at
+// config.Address, so sidecar processes such as bots and tools can reach the
+// game process without a TCP port. On Windows the socket is an AF_UNIX
+// socket, available since Windows 10 1803. When authorize is set, each
+// connection is admitted only after the peer's credentials are checked.
+func NewIPCServer(config *NetworkConfig, authorize PeerAuthorizer) (Server, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	if config.Protocol != ProtocolUnix {
+		return nil, fmt.Errorf("invalid protocol for IPC server: %s", config.Protocol)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("IPC server needs a socket path")
+	}
+
+	server := newStreamServer(config)
+	server.listen = func(network, address string) (net.Listener, error) {
+		return listenIPC(address, authorize)
+	}
+	return server, nil
+}
+
+// NewIPCClient creates a client connecting to IPC servers by socket path
+func NewIPCClient(config *NetworkConfig) (Client, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	if config.Protocol != ProtocolUnix {
+		return nil, fmt.Errorf("invalid protocol for IPC client: %s", config.Protocol)
+	}
+	return newStreamClient(config), nil
+}
+
+// DefaultIPCConfig returns a configuration for an IPC socket at path
+func DefaultIPCConfig(path string) *NetworkConfig {
+	config := DefaultNetworkConfig()
+	config.Protocol = ProtocolUnix
+	config.Address = path
+	config.Port = 0
+	return config
+}
+
+// IPCPeerCredentials returns the credentials of the process on the other
+// end of an IPC connection
+func IPCPeerCredentials(conn Connection) (PeerCredentials, error) {
+	tc, ok := conn.(*tcpConnection)
+	if !ok {
+		return PeerCredentials{}, fmt.Errorf("connection %s is not a socket connection", conn.ID())
+	}
+	unixConn, ok := tc.conn.(*net.UnixConn)
+	if !ok {
+		return PeerCredentials{}, fmt.Errorf("connection %s is not an IPC connection", conn.ID())
+	}
+	return peerCredentials(unixConn)
+}
+
+// listenAddress returns the address a stream server listens on
+func listenAddress(config *NetworkConfig) string {
+	if config.Protocol == ProtocolUnix {
+		return config.Address
+	}
+	return fmt.Sprintf("%s:%d", config.Address, config.Port)
+}
+
+// protocolName returns the name a protocol is logged under
+func protocolName(protocol Protocol) string {
+	if protocol == ProtocolUnix {
+		return "IPC"
+	}
+	return strings.ToUpper(string(protocol))
+}
+
+// listenIPC listens on a Unix domain socket, replacing a stale socket file
+// left behind by a process that did not shut down cleanly
+func listenIPC(path string, authorize PeerAuthorizer) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("IPC socket %s is in use", path)
+		}
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Best effort: not every platform applies file modes to sockets
+	os.Chmod(path, ipcSocketMode)
+
+	if authorize == nil {
+		return listener, nil
+	}
+	return &ipcListener{Listener: listener, authorize: authorize}, nil
+}
+
+// ipcListener admits only the connections whose peer is authorized
+type ipcListener struct {
+	net.Listener
+	authorize PeerAuthorizer
+}
+
+// Accept returns the next authorized connection, closing rejected ones
+func (l *ipcListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		unixConn, ok := conn.(*net.UnixConn)
+		if !ok {
+			conn.Close()
+			continue
+		}
+		creds, err := peerCredentials(unixConn)
+		if err == nil {
+			err = l.authorize(creds)
+		}
+		if err != nil {
+			core.ReportError("network", "ipc", core.SeverityWarning, fmt.Errorf("rejected IPC connection: %w", err))
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+// Package network provides Linux peer credentials for IPC connections
+package network
+
+import (
+	"net"
+	"syscall"
+)
+
+// peerCredentials reads the peer's credentials with SO_PEERCRED
+func peerCredentials(conn *net.UnixConn) (PeerCredentials, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+
+	var ucred *syscall.Ucred
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, sockErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+	if sockErr != nil {
+		return PeerCredentials{}, sockErr
+	}
+	return PeerCredentials{PID: ucred.Pid, UID: ucred.Uid, GID: ucred.Gid}, nil
+}
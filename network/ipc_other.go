@@ -0,0 +1,11 @@
+//go:build !linux
+
+// Package network provides the peer credentials fallback for other platforms
+package network
+
+import "net"
+
+// peerCredentials is unsupported where SO_PEERCRED is not available
+func peerCredentials(conn *net.UnixConn) (PeerCredentials, error) {
+	return PeerCredentials{}, ErrPeerCredentialsUnsupported
+}
@@ -0,0 +1,142 @@
+// Package network provides tests for the IPC transport
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestIPCClientServer(t *testing.T) {
+	config := DefaultIPCConfig(filepath.Join(t.TempDir(), "game.sock"))
+
+	peers := make(chan PeerCredentials, 1)
+	server, err := NewIPCServer(config, func(creds PeerCredentials) error {
+		peers <- creds
+		return nil
+	})
+	if runtime.GOOS != "linux" {
+		// Without peer credentials every connection would be rejected
+		server, err = NewIPCServer(config, nil)
+	}
+	if err != nil {
+		t.Fatalf("Failed to create IPC server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start IPC server: %v", err)
+	}
+	defer server.Stop()
+
+	client, err := NewIPCClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create IPC client: %v", err)
+	}
+	conn, err := client.ConnectWithTimeout(config.Address, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	serverConn, err := server.AcceptConnection(ctx)
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+
+	if err := conn.SendMessage(NewMessage(MessageTypeData, []byte("hello"))); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	msg, err := serverConn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if err := ExpectFrame(msg, MessageTypeData, []byte("hello")); err != nil {
+		t.Error(err)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	creds, err := IPCPeerCredentials(serverConn)
+	if err != nil {
+		t.Fatalf("Failed to read peer credentials: %v", err)
+	}
+	if int(creds.PID) != os.Getpid() || int(creds.UID) != os.Getuid() {
+		t.Errorf("Unexpected peer credentials: %+v", creds)
+	}
+	if authorized := <-peers; authorized != creds {
+		t.Errorf("Authorizer saw %+v, expected %+v", authorized, creds)
+	}
+}
+
+func TestIPCServerRejectsUnauthorizedPeers(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials require SO_PEERCRED")
+	}
+
+	config := DefaultIPCConfig(filepath.Join(t.TempDir(), "game.sock"))
+	server, err := NewIPCServer(config, func(creds PeerCredentials) error {
+		return errors.New("sidecars only")
+	})
+	if err != nil {
+		t.Fatalf("Failed to create IPC server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start IPC server: %v", err)
+	}
+	defer server.Stop()
+
+	client, _ := NewIPCClient(config)
+	conn, err := client.ConnectWithTimeout(config.Address, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	// The server closes rejected connections right away
+	conn.SetReadTimeout(time.Second)
+	if _, err := conn.ReadMessage(); err == nil {
+		t.Error("Expected rejected connection to be closed")
+	}
+	if server.GetConnectionCount() != 0 {
+		t.Errorf("Expected no admitted connections, got %d", server.GetConnectionCount())
+	}
+}
+
+func TestIPCServerReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sock")
+	config := DefaultIPCConfig(path)
+
+	first, _ := NewIPCServer(config, nil)
+	if err := first.Start(); err != nil {
+		t.Fatalf("Failed to start IPC server: %v", err)
+	}
+
+	// A live socket is not taken over
+	second, _ := NewIPCServer(config, nil)
+	if err := second.Start(); err == nil {
+		second.Stop()
+		t.Fatal("Expected socket in use error")
+	}
+	first.Stop()
+
+	// Simulate a crash that left the socket file behind
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	third, _ := NewIPCServer(config, nil)
+	if err := third.Start(); err != nil {
+		t.Fatalf("Failed to replace stale socket: %v", err)
+	}
+	third.Stop()
+}
@@ -0,0 +1,280 @@
+// Package network provides LAN peer discovery for development clusters
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLANDiscoveryGroup is the multicast group LAN discovery announces on
+const DefaultLANDiscoveryGroup = "239.255.77.77:7947"
+
+// lanAnnounceMaxSize bounds the size of an announcement datagram
+const lanAnnounceMaxSize = 1024
+
+// ErrLANDiscoveryDisabled is returned when LAN discovery is used outside dev mode
+var ErrLANDiscoveryDisabled = errors.New("LAN discovery is only available in dev mode")
+
+// LANDiscoveryConfig configures LAN discovery. Announcements are neither
+// authenticated nor encrypted, so discovery refuses to run unless DevMode
+// is set.
+type LANDiscoveryConfig struct {
+	// DevMode must be set to enable discovery
+	DevMode bool
+
+	// Group is the multicast group (or broadcast/unicast address) to
+	// announce on and listen to
+	Group string
+
+	// ClusterName keeps clusters sharing a LAN apart
+	ClusterName string
+
+	// NodeID identifies this node; announcements carrying it are ignored
+	NodeID string
+
+	// Address is the cluster address peers should use as a seed
+	Address string
+
+	// Interval is the time between announcements
+	Interval time.Duration
+
+	// PeerTTL forgets peers that stop announcing
+	PeerTTL time.Duration
+}
+
+// LANPeer is a node discovered on the LAN
+type LANPeer struct {
+	NodeID   string    `json:"node_id"`
+	Address  string    `json:"address"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// lanAnnouncement is the datagram a node multicasts
+type lanAnnouncement struct {
+	Cluster string `json:"cluster"`
+	NodeID  string `json:"node_id"`
+	Address string `json:"address"`
+}
+
+// LANDiscovery announces the local node over UDP multicast and collects the
+// nodes of the same cluster announcing on the LAN, so development clusters
+// can be started without seed configuration.
+type LANDiscovery struct {
+	config LANDiscoveryConfig
+	group  *net.UDPAddr
+
+	mu      sync.RWMutex
+	peers   map[string]*LANPeer
+	onPeer  []func(LANPeer)
+	changed chan struct{}
+
+	listener *net.UDPConn
+	sender   *net.UDPConn
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewLANDiscovery creates a LAN discovery helper; it fails unless DevMode is set
+func NewLANDiscovery(config LANDiscoveryConfig) (*LANDiscovery, error) {
+	if !config.DevMode {
+		return nil, ErrLANDiscoveryDisabled
+	}
+	if config.NodeID == "" || config.Address == "" {
+		return nil, fmt.Errorf("LAN discovery needs a node ID and an address")
+	}
+	if config.Group == "" {
+		config.Group = DefaultLANDiscoveryGroup
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.PeerTTL <= 0 {
+		config.PeerTTL = 5 * config.Interval
+	}
+
+	group, err := net.ResolveUDPAddr("udp4", config.Group)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LAN discovery group %q: %w", config.Group, err)
+	}
+
+	return &LANDiscovery{
+		config:  config,
+		group:   group,
+		peers:   make(map[string]*LANPeer),
+		changed: make(chan struct{}),
+	}, nil
+}
+
+// Start joins the group and starts announcing and listening
+func (d *LANDiscovery) Start(ctx context.Context) error {
+	var err error
+	if d.group.IP.IsMulticast() {
+		d.listener, err = net.ListenMulticastUDP("udp4", nil, d.group)
+	} else {
+		d.listener, err = net.ListenUDP("udp4", d.group)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to listen for LAN announcements: %w", err)
+	}
+
+	d.sender, err = net.DialUDP("udp4", nil, d.group)
+	if err != nil {
+		d.listener.Close()
+		return fmt.Errorf("failed to open LAN announcement socket: %w", err)
+	}
+
+	ctx, d.cancel = context.WithCancel(ctx)
+	d.wg.Add(2)
+	go d.announceLoop(ctx)
+	go d.listenLoop()
+	return nil
+}
+
+// Stop stops announcing and listening
+func (d *LANDiscovery) Stop() error {
+	if d.cancel == nil {
+		return nil
+	}
+	d.cancel()
+	d.listener.Close()
+	d.sender.Close()
+	d.wg.Wait()
+	return nil
+}
+
+// OnPeer registers a callback run when a new peer is discovered, e.g. to
+// join it through the cluster manager
+func (d *LANDiscovery) OnPeer(callback func(LANPeer)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.onPeer = append(d.onPeer, callback)
+}
+
+// Peers returns the live peers, sorted by node ID
+func (d *LANDiscovery) Peers() []LANPeer {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	cutoff := time.Now().Add(-d.config.PeerTTL)
+	peers := make([]LANPeer, 0, len(d.peers))
+	for _, peer := range d.peers {
+		if peer.LastSeen.After(cutoff) {
+			peers = append(peers, *peer)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].NodeID < peers[j].NodeID })
+	return peers
+}
+
+// Seeds returns the addresses of the live peers, usable as a cluster seed list
+func (d *LANDiscovery) Seeds() []string {
+	peers := d.Peers()
+	seeds := make([]string, len(peers))
+	for i, peer := range peers {
+		seeds[i] = peer.Address
+	}
+	return seeds
+}
+
+// WaitForPeers blocks until at least n peers are known and returns their
+// seed addresses
+func (d *LANDiscovery) WaitForPeers(ctx context.Context, n int) ([]string, error) {
+	for {
+		d.mu.RLock()
+		changed := d.changed
+		d.mu.RUnlock()
+
+		if seeds := d.Seeds(); len(seeds) >= n {
+			return seeds, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return d.Seeds(), ctx.Err()
+		}
+	}
+}
+
+// announceLoop announces the local node every interval
+func (d *LANDiscovery) announceLoop(ctx context.Context) {
+	defer d.wg.Done()
+
+	data, _ := json.Marshal(lanAnnouncement{
+		Cluster: d.config.ClusterName,
+		NodeID:  d.config.NodeID,
+		Address: d.config.Address,
+	})
+
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Errors are transient (e.g. no route yet); the next tick retries
+		d.sender.Write(data)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// listenLoop records the announcements of other nodes of the cluster
+func (d *LANDiscovery) listenLoop() {
+	defer d.wg.Done()
+
+	buf := make([]byte, lanAnnounceMaxSize)
+	for {
+		n, _, err := d.listener.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		d.handleAnnouncement(buf[:n], time.Now())
+	}
+}
+
+// handleAnnouncement records a peer announcement
+func (d *LANDiscovery) handleAnnouncement(data []byte, now time.Time) {
+	var announcement lanAnnouncement
+	if err := json.Unmarshal(data, &announcement); err != nil {
+		return
+	}
+	if announcement.Cluster != d.config.ClusterName || announcement.NodeID == d.config.NodeID ||
+		announcement.NodeID == "" || announcement.Address == "" {
+		return
+	}
+
+	d.mu.Lock()
+	peer, exists := d.peers[announcement.NodeID]
+	isNew := !exists || now.Sub(peer.LastSeen) > d.config.PeerTTL || peer.Address != announcement.Address
+	if !exists {
+		peer = &LANPeer{NodeID: announcement.NodeID}
+		d.peers[announcement.NodeID] = peer
+	}
+	peer.Address = announcement.Address
+	peer.LastSeen = now
+
+	var callbacks []func(LANPeer)
+	if isNew {
+		callbacks = append(callbacks, d.onPeer...)
+		close(d.changed)
+		d.changed = make(chan struct{})
+	}
+	snapshot := *peer
+	d.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(snapshot)
+	}
+}
@@ -0,0 +1,93 @@
+// Package network provides tests for LAN discovery
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLANDiscoveryRequiresDevMode(t *testing.T) {
+	_, err := NewLANDiscovery(LANDiscoveryConfig{NodeID: "a", Address: "127.0.0.1:7946"})
+	if !errors.Is(err, ErrLANDiscoveryDisabled) {
+		t.Errorf("Expected ErrLANDiscoveryDisabled, got %v", err)
+	}
+}
+
+func TestLANDiscoveryPeers(t *testing.T) {
+	// A unicast group keeps the test independent of multicast routing
+	probe, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	group := probe.LocalAddr().String()
+	probe.Close()
+
+	discovery, err := NewLANDiscovery(LANDiscoveryConfig{
+		DevMode:     true,
+		Group:       group,
+		ClusterName: "dev",
+		NodeID:      "node-a",
+		Address:     "127.0.0.1:7946",
+		Interval:    20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create discovery: %v", err)
+	}
+
+	discovered := make(chan LANPeer, 4)
+	discovery.OnPeer(func(peer LANPeer) { discovered <- peer })
+
+	if err := discovery.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start discovery: %v", err)
+	}
+	defer discovery.Stop()
+
+	peer, err := net.Dial("udp4", group)
+	if err != nil {
+		t.Fatalf("Failed to dial group: %v", err)
+	}
+	defer peer.Close()
+
+	// Other clusters are ignored, peers of the same cluster are recorded
+	peer.Write([]byte(`{"cluster":"other","node_id":"node-x","address":"127.0.0.1:9000"}`))
+	peer.Write([]byte(`{"cluster":"dev","node_id":"node-b","address":"127.0.0.1:7947"}`))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	seeds, err := discovery.WaitForPeers(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to discover peer: %v", err)
+	}
+	if len(seeds) != 1 || seeds[0] != "127.0.0.1:7947" {
+		t.Errorf("Unexpected seeds: %v", seeds)
+	}
+
+	select {
+	case p := <-discovered:
+		if p.NodeID != "node-b" {
+			t.Errorf("Unexpected discovered peer: %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnPeer callback")
+	}
+
+	// The node's own announcements never show up as a peer
+	time.Sleep(50 * time.Millisecond)
+	for _, p := range discovery.Peers() {
+		if p.NodeID == "node-a" {
+			t.Error("Local node listed as a peer")
+		}
+	}
+
+	// Peers that stop announcing expire
+	discovery.mu.Lock()
+	discovery.config.PeerTTL = time.Millisecond
+	discovery.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	if peers := discovery.Peers(); len(peers) != 0 {
+		t.Errorf("Expected peers to expire, got %v", peers)
+	}
+}
@@ -0,0 +1,303 @@
+// Package network provides clock sync and one-way delay estimation
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the one-way delay histograms
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// clockSyncSamples is the number of recent sync exchanges the clock offset
+// is chosen from; the one with the lowest round trip wins, as queuing
+// delay on either leg skews the offset by up to half the extra delay
+const clockSyncSamples = 8
+
+// timeSyncReplySize is the payload of a time sync reply: the request's send
+// time and the responder's receive time
+const timeSyncReplySize = 16
+
+// LatencyHistogram counts one-way delay samples over one time window
+type LatencyHistogram struct {
+	Start  time.Time       `json:"start"`
+	Bounds []time.Duration `json:"bounds"`
+	Counts []int64         `json:"counts"` // len(Bounds)+1, the last one unbounded
+	Count  int64           `json:"count"`
+	Sum    time.Duration   `json:"sum"`
+	Min    time.Duration   `json:"min"`
+	Max    time.Duration   `json:"max"`
+}
+
+// Mean returns the mean delay of the window
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// observe adds a sample
+func (h *LatencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.Bounds) && d > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+	h.Count++
+	h.Sum += d
+}
+
+// LatencyStats summarizes the latency estimates of a connection
+type LatencyStats struct {
+	// Synced is true once a clock sync exchange completed
+	Synced bool `json:"synced"`
+
+	// ClockOffset is the peer clock minus the local clock
+	ClockOffset time.Duration `json:"clock_offset"`
+
+	// RoundTripTime of the exchange the offset was taken from
+	RoundTripTime time.Duration `json:"round_trip_time"`
+
+	// OneWayDelay is the smoothed peer-to-local delay
+	OneWayDelay time.Duration `json:"one_way_delay"`
+
+	// Jitter is the RFC 3550 interarrival jitter
+	Jitter time.Duration `json:"jitter"`
+
+	// Samples is the number of timestamped frames observed
+	Samples int64 `json:"samples"`
+}
+
+// clockSample is one time sync exchange
+type clockSample struct {
+	offset time.Duration
+	rtt    time.Duration
+}
+
+// LatencyTracker estimates the peer clock offset from time sync exchanges
+// and, from the send timestamps of inbound frames, the one-way delay and
+// jitter of the peer-to-local direction. Comparing both directions exposes
+// asymmetric routes that a round trip time alone hides
+type LatencyTracker struct {
+	mu sync.Mutex
+
+	syncInterval time.Duration
+	lastSyncSent time.Time
+	clock        []clockSample
+	offset       time.Duration
+	rtt          time.Duration
+	synced       bool
+
+	oneWay      time.Duration
+	jitter      time.Duration
+	lastTransit time.Duration
+	samples     int64
+
+	window     time.Duration
+	maxWindows int
+	histograms []LatencyHistogram
+}
+
+// NewLatencyTracker creates a tracker syncing clocks every syncInterval and
+// keeping maxWindows histograms of window each
+func NewLatencyTracker(syncInterval, window time.Duration, maxWindows int) *LatencyTracker {
+	if window <= 0 {
+		window = time.Minute
+	}
+	if maxWindows <= 0 {
+		maxWindows = 60
+	}
+	return &LatencyTracker{
+		syncInterval: syncInterval,
+		window:       window,
+		maxWindows:   maxWindows,
+	}
+}
+
+// ObserveSync records a time sync exchange: t1 request sent (local), t2
+// request received (peer), t3 reply sent (peer), t4 reply received (local)
+func (lt *LatencyTracker) ObserveSync(t1, t2, t3, t4 time.Time) {
+	sample := clockSample{
+		offset: (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		rtt:    t4.Sub(t1) - t3.Sub(t2),
+	}
+	if sample.rtt < 0 {
+		sample.rtt = 0
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.clock = append(lt.clock, sample)
+	if len(lt.clock) > clockSyncSamples {
+		lt.clock = lt.clock[len(lt.clock)-clockSyncSamples:]
+	}
+
+	best := lt.clock[0]
+	for _, s := range lt.clock[1:] {
+		if s.rtt < best.rtt {
+			best = s
+		}
+	}
+	lt.offset = best.offset
+	lt.rtt = best.rtt
+	lt.synced = true
+}
+
+// Observe records a frame sent at sentAt (peer clock) and received at
+// receivedAt (local clock)
+func (lt *LatencyTracker) Observe(sentAt, receivedAt time.Time) {
+	if sentAt.IsZero() {
+		return
+	}
+	transit := receivedAt.Sub(sentAt)
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	// Jitter only needs transit differences, in which the offset cancels
+	if lt.samples > 0 {
+		d := transit - lt.lastTransit
+		if d < 0 {
+			d = -d
+		}
+		lt.jitter += (d - lt.jitter) / 16
+	}
+	lt.lastTransit = transit
+	lt.samples++
+
+	if !lt.synced {
+		return
+	}
+
+	delay := transit + lt.offset
+	if delay < 0 {
+		delay = 0
+	}
+	if lt.oneWay == 0 {
+		lt.oneWay = delay
+	} else {
+		lt.oneWay += (delay - lt.oneWay) / 8
+	}
+	lt.currentHistogram(receivedAt).observe(delay)
+}
+
+// currentHistogram returns the histogram of the window containing now;
+// lt.mu must be held
+func (lt *LatencyTracker) currentHistogram(now time.Time) *LatencyHistogram {
+	start := now.Truncate(lt.window)
+	if n := len(lt.histograms); n > 0 && lt.histograms[n-1].Start.Equal(start) {
+		return &lt.histograms[n-1]
+	}
+
+	lt.histograms = append(lt.histograms, LatencyHistogram{
+		Start:  start,
+		Bounds: latencyBuckets,
+		Counts: make([]int64, len(latencyBuckets)+1),
+	})
+	if len(lt.histograms) > lt.maxWindows {
+		lt.histograms = append(lt.histograms[:0], lt.histograms[len(lt.histograms)-lt.maxWindows:]...)
+	}
+	return &lt.histograms[len(lt.histograms)-1]
+}
+
+// Stats returns the current estimates
+func (lt *LatencyTracker) Stats() LatencyStats {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	return LatencyStats{
+		Synced:        lt.synced,
+		ClockOffset:   lt.offset,
+		RoundTripTime: lt.rtt,
+		OneWayDelay:   lt.oneWay,
+		Jitter:        lt.jitter,
+		Samples:       lt.samples,
+	}
+}
+
+// Histograms returns the one-way delay histograms, oldest window first
+func (lt *LatencyTracker) Histograms() []LatencyHistogram {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	result := make([]LatencyHistogram, len(lt.histograms))
+	for i, h := range lt.histograms {
+		h.Counts = append([]int64(nil), h.Counts...)
+		result[i] = h
+	}
+	return result
+}
+
+// syncDue returns true, and records the attempt, if a time sync request
+// should be sent now
+func (lt *LatencyTracker) syncDue(now time.Time) bool {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if lt.syncInterval <= 0 || now.Sub(lt.lastSyncSent) < lt.syncInterval {
+		return false
+	}
+	lt.lastSyncSent = now
+	return true
+}
+
+// NewTimeSyncRequest creates a time sync request; its send timestamp is
+// the t1 of the exchange
+func NewTimeSyncRequest() *Message {
+	msg := NewMessage(MessageTypeTimeSync, nil)
+	msg.SetFlag(MessageFlagTimestamp)
+	return msg
+}
+
+// newTimeSyncReply answers a request received at receivedAt
+func newTimeSyncReply(request *Message, receivedAt time.Time) *Message {
+	data := make([]byte, timeSyncReplySize)
+	binary.BigEndian.PutUint64(data[0:8], uint64(request.SentAt.UnixNano()))
+	binary.BigEndian.PutUint64(data[8:16], uint64(receivedAt.UnixNano()))
+
+	reply := NewMessage(MessageTypeTimeSync, data)
+	reply.SetFlag(MessageFlagTimestamp)
+	return reply
+}
+
+// handleTimeSync answers a time sync request or feeds a reply to tracker;
+// it returns the reply to send, if any
+func handleTimeSync(tracker *LatencyTracker, msg *Message, receivedAt time.Time) (*Message, error) {
+	if msg.SentAt.IsZero() {
+		return nil, fmt.Errorf("time sync frame without timestamp")
+	}
+
+	if len(msg.Data) == 0 {
+		return newTimeSyncReply(msg, receivedAt), nil
+	}
+	if len(msg.Data) != timeSyncReplySize {
+		return nil, fmt.Errorf("invalid time sync reply size: %d", len(msg.Data))
+	}
+
+	t1 := time.Unix(0, int64(binary.BigEndian.Uint64(msg.Data[0:8])))
+	t2 := time.Unix(0, int64(binary.BigEndian.Uint64(msg.Data[8:16])))
+	tracker.ObserveSync(t1, t2, msg.SentAt, receivedAt)
+	return nil, nil
+}
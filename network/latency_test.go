@@ -0,0 +1,149 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	tracker := NewLatencyTracker(0, time.Minute, 2)
+	base := time.Unix(1700000000, 0)
+
+	// The peer clock runs 5s ahead; the outbound leg takes 10ms and the
+	// return leg 30ms
+	offset := 5 * time.Second
+	t1 := base
+	t2 := t1.Add(10 * time.Millisecond).Add(offset)
+	t3 := t2.Add(time.Millisecond)
+	t4 := t3.Add(-offset).Add(30 * time.Millisecond)
+	tracker.ObserveSync(t1, t2, t3, t4)
+
+	// A queued exchange with a larger round trip must not replace it
+	tracker.ObserveSync(t1, t2.Add(200*time.Millisecond), t3.Add(200*time.Millisecond), t4.Add(time.Second))
+
+	stats := tracker.Stats()
+	if !stats.Synced || stats.RoundTripTime != 40*time.Millisecond {
+		t.Fatalf("Expected the 40ms exchange to be kept, got %+v", stats)
+	}
+	// The NTP estimate splits the asymmetry evenly: 5s - 10ms
+	if stats.ClockOffset != offset-10*time.Millisecond {
+		t.Errorf("Unexpected clock offset %v", stats.ClockOffset)
+	}
+
+	// Frames stamped 20ms before their arrival in the estimated peer clock
+	estimated := stats.ClockOffset
+	recv := base.Add(time.Hour + 30*time.Second)
+	for i := 0; i < 10; i++ {
+		sent := recv.Add(estimated).Add(-20 * time.Millisecond)
+		tracker.Observe(sent, recv)
+		recv = recv.Add(time.Second)
+	}
+	stats = tracker.Stats()
+	if stats.OneWayDelay != 20*time.Millisecond || stats.Jitter != 0 || stats.Samples != 10 {
+		t.Errorf("Unexpected steady state estimates %+v", stats)
+	}
+
+	// Alternating transit times raise the jitter
+	for i := 0; i < 20; i++ {
+		extra := time.Duration(i%2) * 8 * time.Millisecond
+		tracker.Observe(recv.Add(estimated).Add(-20*time.Millisecond-extra), recv)
+		recv = recv.Add(time.Second)
+	}
+	if jitter := tracker.Stats().Jitter; jitter < 4*time.Millisecond || jitter > 8*time.Millisecond {
+		t.Errorf("Expected jitter between 4ms and 8ms, got %v", jitter)
+	}
+
+	// 30 seconds of samples span at least two one-minute windows, of which
+	// at most two are kept
+	histograms := tracker.Histograms()
+	if len(histograms) != 2 {
+		t.Fatalf("Expected 2 histogram windows, got %d", len(histograms))
+	}
+	total := int64(0)
+	for _, h := range histograms {
+		total += h.Count
+		if h.Min < 20*time.Millisecond || h.Max > 28*time.Millisecond {
+			t.Errorf("Unexpected histogram range %v..%v", h.Min, h.Max)
+		}
+		if len(h.Counts) != len(h.Bounds)+1 {
+			t.Errorf("Expected an unbounded bucket, got %d counts", len(h.Counts))
+		}
+	}
+	if total != 30 {
+		t.Errorf("Expected 30 histogram samples, got %d", total)
+	}
+}
+
+func TestConnectionTimestamps(t *testing.T) {
+	a, b := net.Pipe()
+	client := NewTCPConnection(a)
+	server := NewTCPConnection(b)
+	defer client.Close()
+	defer server.Close()
+
+	client.(LatencyTrackable).SetTimestamps(true, time.Millisecond)
+	server.(LatencyTrackable).SetTimestamps(true, time.Millisecond)
+
+	received := func(conn Connection, n int) <-chan []*Message {
+		done := make(chan []*Message, 1)
+		go func() {
+			var msgs []*Message
+			for len(msgs) < n {
+				msg, err := conn.ReadMessage()
+				if err != nil {
+					break
+				}
+				msgs = append(msgs, msg)
+			}
+			done <- msgs
+		}()
+		return done
+	}
+	fromClient := received(server, 20)
+	fromServer := received(client, 20)
+
+	for i := 0; i < 20; i++ {
+		client.SendMessage(NewMessage(MessageTypeData, []byte("ping")))
+		server.SendMessage(NewMessage(MessageTypeData, []byte("pong")))
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	for _, done := range []<-chan []*Message{fromClient, fromServer} {
+		select {
+		case msgs := <-done:
+			if len(msgs) != 20 {
+				t.Fatalf("Expected 20 application messages, got %d", len(msgs))
+			}
+			for _, msg := range msgs {
+				if msg.Type == MessageTypeTimeSync || msg.SentAt.IsZero() {
+					t.Fatalf("Expected stamped application frames only, got %+v", msg)
+				}
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out reading messages")
+		}
+	}
+
+	// Later frames complete the sync exchanges started by earlier ones
+	deadline := time.Now().Add(time.Second)
+	for !server.(LatencyTrackable).Latency().Stats().Synced && time.Now().Before(deadline) {
+		server.SendMessage(NewMessage(MessageTypeData, nil))
+		client.SendMessage(NewMessage(MessageTypeData, nil))
+		client.ReadMessage()
+		server.ReadMessage()
+	}
+
+	stats := server.GetStatistics()
+	latency := server.(LatencyTrackable).Latency().Stats()
+	if !latency.Synced || latency.Samples < 20 {
+		t.Fatalf("Expected synced estimates from stamped frames, got %+v", latency)
+	}
+	// Both ends share a clock, so the offset is within the round trip
+	if abs := stats.ClockOffset; abs < -stats.RoundTripTime || abs > stats.RoundTripTime {
+		t.Errorf("Offset %v exceeds round trip %v", stats.ClockOffset, stats.RoundTripTime)
+	}
+	if stats.OneWayDelay < 0 || stats.OneWayDelay > time.Second {
+		t.Errorf("Unexpected one-way delay %v", stats.OneWayDelay)
+	}
+}
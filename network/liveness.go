@@ -0,0 +1,255 @@
+// Package network provides the unified peer liveness policy
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPeerUnresponsive is reported for connections closed after their peer
+// missed too many heartbeats
+var ErrPeerUnresponsive = errors.New("peer missed heartbeats")
+
+// LivenessMode selects how dead peers are detected
+type LivenessMode int
+
+const (
+	// LivenessKeepAlive relies on TCP keep-alive only. It is the zero
+	// value, so servers and clients send heartbeats only when asked to.
+	LivenessKeepAlive LivenessMode = iota
+
+	// LivenessAuto prefers application heartbeats for peers that send them
+	// and falls back to TCP keep-alive for peers that never do
+	LivenessAuto
+
+	// LivenessHeartbeat relies on application heartbeats only; silent
+	// peers are disconnected
+	LivenessHeartbeat
+
+	// LivenessDisabled turns off both mechanisms
+	LivenessDisabled
+)
+
+// String returns the string representation of LivenessMode
+func (m LivenessMode) String() string {
+	switch m {
+	case LivenessKeepAlive:
+		return "keepalive"
+	case LivenessAuto:
+		return "auto"
+	case LivenessHeartbeat:
+		return "heartbeat"
+	case LivenessDisabled:
+		return "disabled"
+	default:
+		return "unknown"
+	}
+}
+
+// LivenessVerdict is the outcome of checking a connection
+type LivenessVerdict int
+
+const (
+	// LivenessAlive means the peer was heard from recently enough
+	LivenessAlive LivenessVerdict = iota
+
+	// LivenessSuspect means the peer missed at least one heartbeat
+	LivenessSuspect
+
+	// LivenessDead means the peer missed MissThreshold heartbeats
+	LivenessDead
+)
+
+// String returns the string representation of LivenessVerdict
+func (v LivenessVerdict) String() string {
+	switch v {
+	case LivenessAlive:
+		return "alive"
+	case LivenessSuspect:
+		return "suspect"
+	case LivenessDead:
+		return "dead"
+	default:
+		return "unknown"
+	}
+}
+
+// LivenessPolicy is the single liveness configuration applied by servers,
+// clients and the connection manager
+type LivenessPolicy struct {
+	// Mode selects the detection mechanisms
+	Mode LivenessMode
+
+	// HeartbeatInterval is how often heartbeats are sent and liveness is
+	// checked (0 disables heartbeats)
+	HeartbeatInterval time.Duration
+
+	// MissThreshold is how many heartbeat intervals of silence mark a
+	// peer dead
+	MissThreshold int
+
+	// KeepAliveInterval is the TCP keep-alive probe period (0 disables it)
+	KeepAliveInterval time.Duration
+}
+
+// DefaultLivenessPolicy returns the policy of connection managers, which
+// heartbeat once StartHeartbeat is called
+func DefaultLivenessPolicy() LivenessPolicy {
+	return LivenessPolicy{
+		Mode:              LivenessAuto,
+		HeartbeatInterval: 30 * time.Second,
+		MissThreshold:     3,
+		KeepAliveInterval: 60 * time.Second,
+	}
+}
+
+// LivenessPolicy returns the configured liveness policy. Zero fields of
+// config.Liveness fall back to the KeepAlive and HeartbeatInterval settings
+func (c *NetworkConfig) LivenessPolicy() LivenessPolicy {
+	policy := c.Liveness
+	if policy.HeartbeatInterval == 0 {
+		policy.HeartbeatInterval = c.HeartbeatInterval
+	}
+	if policy.KeepAliveInterval == 0 && c.KeepAlive {
+		policy.KeepAliveInterval = c.KeepAliveInterval
+	}
+	if policy.MissThreshold <= 0 {
+		policy.MissThreshold = DefaultLivenessPolicy().MissThreshold
+	}
+	return policy
+}
+
+// UsesHeartbeats returns true if the policy sends and checks heartbeats
+func (p LivenessPolicy) UsesHeartbeats() bool {
+	return (p.Mode == LivenessAuto || p.Mode == LivenessHeartbeat) && p.HeartbeatInterval > 0
+}
+
+// UsesKeepAlive returns true if the policy enables TCP keep-alive
+func (p LivenessPolicy) UsesKeepAlive() bool {
+	return (p.Mode == LivenessAuto || p.Mode == LivenessKeepAlive) && p.KeepAliveInterval > 0
+}
+
+// ApplyKeepAlive configures TCP keep-alive on a raw connection
+func (p LivenessPolicy) ApplyKeepAlive(conn net.Conn) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	tcpConn.SetKeepAlive(p.UsesKeepAlive())
+	if p.UsesKeepAlive() {
+		tcpConn.SetKeepAlivePeriod(p.KeepAliveInterval)
+	}
+}
+
+// Check decides whether the peer of conn is still alive at now
+func (p LivenessPolicy) Check(conn Connection, now time.Time) LivenessVerdict {
+	if !p.UsesHeartbeats() {
+		return LivenessAlive
+	}
+
+	lastRead, lastHeartbeat := conn.GetLastActivity(), time.Time{}
+	if observable, ok := conn.(LivenessObservable); ok {
+		lastRead, lastHeartbeat = observable.LastRead(), observable.LastHeartbeat()
+	}
+
+	// Peers that never sent a heartbeat are left to TCP keep-alive
+	if p.Mode == LivenessAuto && lastHeartbeat.IsZero() {
+		return LivenessAlive
+	}
+
+	missed := int(now.Sub(lastRead) / p.HeartbeatInterval)
+	switch {
+	case missed >= p.MissThreshold:
+		return LivenessDead
+	case missed >= 1:
+		return LivenessSuspect
+	default:
+		return LivenessAlive
+	}
+}
+
+// livenessMonitor sends heartbeats and closes dead connections on behalf
+// of a server, client or connection manager
+type livenessMonitor struct {
+	policy LivenessPolicy
+	conns  func() []Connection
+	onDead func(conn Connection)
+
+	deadPeers int64 // atomic
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startLivenessMonitor starts monitoring the connections returned by conns;
+// it returns nil if the policy does not use heartbeats
+func startLivenessMonitor(ctx context.Context, policy LivenessPolicy, conns func() []Connection, onDead func(conn Connection)) *livenessMonitor {
+	if !policy.UsesHeartbeats() {
+		return nil
+	}
+
+	m := &livenessMonitor{policy: policy, conns: conns, onDead: onDead}
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	m.wg.Add(1)
+	go m.run(ctx)
+	return m
+}
+
+// run checks every connection once per heartbeat interval
+func (m *livenessMonitor) run(ctx context.Context) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.policy.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.check(now)
+		}
+	}
+}
+
+// check closes dead connections and heartbeats the others
+func (m *livenessMonitor) check(now time.Time) {
+	for _, conn := range m.conns() {
+		if conn.State() != ConnectionStateConnected {
+			continue
+		}
+
+		if m.policy.Check(conn, now) == LivenessDead {
+			atomic.AddInt64(&m.deadPeers, 1)
+			conn.Close()
+			if m.onDead != nil {
+				m.onDead(conn)
+			}
+			continue
+		}
+
+		conn.SendMessage(NewHeartbeatMessage())
+	}
+}
+
+// DeadPeers returns how many connections were closed as dead
+func (m *livenessMonitor) DeadPeers() int64 {
+	if m == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&m.deadPeers)
+}
+
+// stop stops the monitor and waits for it to exit
+func (m *livenessMonitor) stop() {
+	if m == nil {
+		return
+	}
+	m.cancel()
+	m.wg.Wait()
+}
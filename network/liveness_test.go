@@ -0,0 +1,143 @@
+package network
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLivenessPolicy(t *testing.T) {
+	config := DefaultNetworkConfig()
+	policy := config.LivenessPolicy()
+	if policy.Mode != LivenessKeepAlive || policy.HeartbeatInterval != config.HeartbeatInterval ||
+		policy.KeepAliveInterval != config.KeepAliveInterval || policy.MissThreshold != 3 {
+		t.Errorf("Unexpected policy from defaults: %+v", policy)
+	}
+	if policy.UsesHeartbeats() || !policy.UsesKeepAlive() {
+		t.Error("Expected heartbeats opt-in and keep-alive on by default")
+	}
+	if (&NetworkConfig{}).LivenessPolicy().UsesHeartbeats() {
+		t.Error("Expected a zero configuration not to heartbeat")
+	}
+
+	config.Liveness.Mode = LivenessAuto
+	if policy := config.LivenessPolicy(); !policy.UsesHeartbeats() || !policy.UsesKeepAlive() {
+		t.Error("Expected the auto policy to use both mechanisms")
+	}
+
+	config.KeepAlive = false
+	config.Liveness.Mode = LivenessKeepAlive
+	if policy := config.LivenessPolicy(); policy.UsesKeepAlive() || policy.UsesHeartbeats() {
+		t.Errorf("Expected keep-alive disabled and no heartbeats, got %+v", policy)
+	}
+
+	raw, peer := net.Pipe()
+	defer raw.Close()
+	conn := NewTCPConnection(peer).(*tcpConnection)
+	defer conn.Close()
+
+	policy = LivenessPolicy{Mode: LivenessAuto, HeartbeatInterval: time.Second, MissThreshold: 3}
+	now := time.Now()
+	atomic.StoreInt64(&conn.lastRead, now.Add(-10*time.Second).UnixNano())
+
+	// Auto leaves peers that never sent a heartbeat to TCP keep-alive
+	if v := policy.Check(conn, now); v != LivenessAlive {
+		t.Errorf("Expected a silent non-heartbeating peer to be alive, got %s", v)
+	}
+
+	policy.Mode = LivenessHeartbeat
+	if v := policy.Check(conn, now); v != LivenessDead {
+		t.Errorf("Expected heartbeat mode to declare the silent peer dead, got %s", v)
+	}
+
+	policy.Mode = LivenessAuto
+	atomic.StoreInt64(&conn.lastHeartbeat, now.Add(-10*time.Second).UnixNano())
+	cases := []struct {
+		silence time.Duration
+		verdict LivenessVerdict
+	}{
+		{500 * time.Millisecond, LivenessAlive},
+		{1500 * time.Millisecond, LivenessSuspect},
+		{3 * time.Second, LivenessDead},
+	}
+	for _, c := range cases {
+		atomic.StoreInt64(&conn.lastRead, now.Add(-c.silence).UnixNano())
+		if v := policy.Check(conn, now); v != c.verdict {
+			t.Errorf("After %v of silence expected %s, got %s", c.silence, c.verdict, v)
+		}
+	}
+}
+
+func TestConnectionManagerLiveness(t *testing.T) {
+	manager := NewConnectionManager()
+	manager.SetLivenessPolicy(LivenessPolicy{Mode: LivenessAuto, MissThreshold: 3})
+
+	codec := NewBinaryMessageCodec()
+	heartbeat, _ := codec.Encode(NewHeartbeatMessage())
+
+	// silent stops heartbeating after one; legacy never heartbeats
+	var raws []net.Conn
+	conns := make(map[string]Connection)
+	for _, name := range []string{"silent", "legacy"} {
+		raw, peer := net.Pipe()
+		raws = append(raws, raw)
+		conn := NewTCPConnection(peer)
+		conns[name] = conn
+		manager.AddConnection(conn)
+
+		// Drain what the manager sends and read what the peer sends
+		go func() {
+			buf := make([]byte, 1024)
+			for {
+				if _, err := raw.Read(buf); err != nil {
+					return
+				}
+			}
+		}()
+		go func() {
+			for {
+				if _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	defer func() {
+		for _, raw := range raws {
+			raw.Close()
+		}
+	}()
+	raws[0].Write(heartbeat)
+
+	if err := manager.StartHeartbeat(20 * time.Millisecond); err != nil {
+		t.Fatalf("Failed to start heartbeat: %v", err)
+	}
+	defer manager.StopHeartbeat()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for manager.GetConnectionCount() == 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, exists := manager.GetConnection(conns["silent"].ID()); exists {
+		t.Error("Expected the peer that stopped heartbeating to be removed")
+	}
+	if _, exists := manager.GetConnection(conns["legacy"].ID()); !exists {
+		t.Error("Expected the peer that never heartbeated to be left to keep-alive")
+	}
+	if conns["silent"].State() != ConnectionStateClosed {
+		t.Error("Expected the dead connection to be closed")
+	}
+
+	stats := manager.GetStatistics()
+	if !stats.HeartbeatEnabled || stats.LivenessMode != LivenessAuto || stats.DeadPeers != 1 {
+		t.Errorf("Unexpected statistics: %+v", stats)
+	}
+
+	manager.SetLivenessPolicy(LivenessPolicy{Mode: LivenessKeepAlive})
+	manager.StopHeartbeat()
+	if err := manager.StartHeartbeat(time.Second); err == nil {
+		t.Error("Expected keep-alive mode to refuse heartbeats")
+	}
+}
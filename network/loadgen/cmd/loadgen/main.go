@@ -0,0 +1,115 @@
+// Command loadgen runs a load test against an SNGO server, e.g.
+//
+//	loadgen -addr localhost:8080 -clients 2000 -rate 5 -duration 1m -mix data:9,rpc:1
+//
+// Data steps send a payload of -size bytes; rpc steps send the -method
+// call. Both wait for a reply unless -noreply is set.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/najoast/sngo/network"
+	"github.com/najoast/sngo/network/loadgen"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "server address")
+	protocol := flag.String("protocol", "tcp", "client protocol (tcp or unix)")
+	clients := flag.Int("clients", 100, "number of simulated clients")
+	rate := flag.Float64("rate", 1, "messages per second per client")
+	duration := flag.Duration("duration", 30*time.Second, "how long messages are sent")
+	rampUp := flag.Duration("rampup", 5*time.Second, "period the client connects are spread over")
+	timeout := flag.Duration("timeout", 5*time.Second, "reply timeout")
+	mix := flag.String("mix", "data:1", "message mix as step:weight pairs (steps: data, rpc)")
+	size := flag.Int("size", 64, "payload bytes of data messages")
+	method := flag.String("method", "ping", "payload of rpc messages")
+	noReply := flag.Bool("noreply", false, "do not wait for replies")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	steps, err := parseMix(*mix, *size, *method, !*noReply)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+		os.Exit(2)
+	}
+
+	netConfig := network.DefaultNetworkConfig()
+	netConfig.Protocol = network.Protocol(*protocol)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := loadgen.Run(ctx, loadgen.Config{
+		Address:      *addr,
+		Network:      netConfig,
+		Clients:      *clients,
+		Rate:         *rate,
+		Duration:     *duration,
+		RampUp:       *rampUp,
+		ReplyTimeout: *timeout,
+		Mix:          steps,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+
+	if report.Connected < report.Clients || report.Total.Errors > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseMix parses "data:9,rpc:1" into steps
+func parseMix(mix string, size int, method string, reply bool) ([]loadgen.Step, error) {
+	payload := []byte(strings.Repeat("x", size))
+
+	var steps []loadgen.Step
+	for _, part := range strings.Split(mix, ",") {
+		name, weightStr, found := strings.Cut(strings.TrimSpace(part), ":")
+		weight := 1
+		if found {
+			w, err := strconv.Atoi(weightStr)
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight in %q", part)
+			}
+			weight = w
+		}
+
+		step := loadgen.Step{Name: name, Weight: weight, Reply: reply}
+		switch name {
+		case "data":
+			step.Build = func(client int, seq uint32) *network.Message {
+				msg := network.NewMessage(network.MessageTypeData, payload)
+				msg.Sequence = seq
+				return msg
+			}
+		case "rpc":
+			step.Build = func(client int, seq uint32) *network.Message {
+				msg := network.NewRPCMessage(fmt.Sprintf("loadgen-%d", client), "", []byte(method))
+				msg.Sequence = seq
+				return msg
+			}
+		default:
+			return nil, fmt.Errorf("unknown step %q", name)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
@@ -0,0 +1,323 @@
+// Package loadgen simulates many network clients against a server, e.g. a
+// gateway, and reports latency percentiles and error rates. It can be run
+// from tests with Run or from the command line with cmd/loadgen.
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/najoast/sngo/network"
+)
+
+// ErrReplyTimeout is recorded when a reply does not arrive in time
+var ErrReplyTimeout = errors.New("reply timed out")
+
+// Step is one kind of message in the scripted mix
+type Step struct {
+	// Name identifies the step in the report
+	Name string
+
+	// Weight is the share of the mix sent as this step
+	Weight int
+
+	// Build creates the message a client sends; seq counts the messages of
+	// the client
+	Build func(client int, seq uint32) *network.Message
+
+	// Reply waits for the next message from the server and measures the
+	// latency to it; without it only the send is measured
+	Reply bool
+}
+
+// Config describes a load run
+type Config struct {
+	// Address of the server
+	Address string
+
+	// Network configures the clients; nil means the TCP defaults
+	Network *network.NetworkConfig
+
+	// Clients is the number of simulated clients
+	Clients int
+
+	// Rate is the target messages per second of each client
+	Rate float64
+
+	// Duration is how long messages are sent, after the ramp-up
+	Duration time.Duration
+
+	// RampUp spreads the client connects over this period
+	RampUp time.Duration
+
+	// ConnectTimeout bounds each connect
+	ConnectTimeout time.Duration
+
+	// ReplyTimeout bounds the wait for a reply
+	ReplyTimeout time.Duration
+
+	// Authenticate runs once per client after connecting, e.g. a login
+	// exchange; an error fails the client
+	Authenticate func(ctx context.Context, s *Session) error
+
+	// Mix is the scripted message mix, picked at random by weight
+	Mix []Step
+
+	// MaxSamples caps the latency samples kept per step; a uniform sample
+	// is kept beyond it
+	MaxSamples int
+}
+
+// withDefaults fills the fields left at their zero value
+func (c Config) withDefaults() Config {
+	if c.Network == nil {
+		c.Network = network.DefaultNetworkConfig()
+	}
+	if c.Clients <= 0 {
+		c.Clients = 1
+	}
+	if c.Rate <= 0 {
+		c.Rate = 1
+	}
+	if c.ConnectTimeout <= 0 {
+		c.ConnectTimeout = 5 * time.Second
+	}
+	if c.ReplyTimeout <= 0 {
+		c.ReplyTimeout = 5 * time.Second
+	}
+	if c.MaxSamples <= 0 {
+		c.MaxSamples = 100000
+	}
+	return c
+}
+
+// validate checks a configuration
+func (c Config) validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("load run needs a server address")
+	}
+	if c.Duration <= 0 {
+		return fmt.Errorf("load run needs a duration")
+	}
+	if len(c.Mix) == 0 {
+		return fmt.Errorf("load run needs a message mix")
+	}
+	for _, step := range c.Mix {
+		if step.Build == nil || step.Weight <= 0 {
+			return fmt.Errorf("step %q needs a builder and a positive weight", step.Name)
+		}
+	}
+	return nil
+}
+
+// Session is the connection of one simulated client
+type Session struct {
+	// Client is the index of the simulated client
+	Client int
+
+	client  network.Client
+	replies chan *network.Message
+	timeout time.Duration
+}
+
+// Send sends a message without waiting for a reply
+func (s *Session) Send(msg *network.Message) error {
+	return s.client.SendMessage(msg)
+}
+
+// Request sends a message and waits for the next message from the server
+func (s *Session) Request(ctx context.Context, msg *network.Message) (*network.Message, error) {
+	// Drop replies to earlier requests that timed out
+	for len(s.replies) > 0 {
+		<-s.replies
+	}
+
+	if err := s.client.SendMessage(msg); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+
+	select {
+	case reply := <-s.replies:
+		return reply, nil
+	case <-timer.C:
+		return nil, ErrReplyTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// sessionHandler queues server messages for the session
+type sessionHandler struct {
+	replies chan *network.Message
+}
+
+func (h *sessionHandler) OnMessage(conn network.Connection, msg *network.Message) {
+	select {
+	case h.replies <- msg:
+	default:
+		// Unsolicited messages beyond the buffer are dropped
+	}
+}
+
+func (h *sessionHandler) OnError(conn network.Connection, err error) {}
+
+// run is the state shared by the clients of a load run
+type run struct {
+	config Config
+	steps  []*stepStats
+	total  int
+
+	connected     int64
+	connectErrors int64
+	authErrors    int64
+}
+
+// Run runs a load test until its duration has passed or ctx is done and
+// returns the report; it fails only when the configuration is invalid
+func Run(ctx context.Context, config Config) (*Report, error) {
+	config = config.withDefaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	r := &run{config: config}
+	for _, step := range config.Mix {
+		r.steps = append(r.steps, newStepStats(step, config.MaxSamples))
+		r.total += step.Weight
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.RampUp+config.Duration)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < config.Clients; i++ {
+		delay := time.Duration(0)
+		if config.Clients > 1 {
+			delay = config.RampUp * time.Duration(i) / time.Duration(config.Clients)
+		}
+
+		wg.Add(1)
+		go func(client int, delay time.Duration) {
+			defer wg.Done()
+			r.client(ctx, client, delay)
+		}(i, delay)
+	}
+	wg.Wait()
+
+	return r.report(time.Since(start)), nil
+}
+
+// client simulates one client
+func (r *run) client(ctx context.Context, index int, delay time.Duration) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(index)))
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return
+	}
+
+	netConfig := *r.config.Network
+	client, err := network.DefaultFactory.CreateClient(&netConfig)
+	if err != nil {
+		atomic.AddInt64(&r.connectErrors, 1)
+		return
+	}
+
+	session := &Session{
+		Client:  index,
+		client:  client,
+		replies: make(chan *network.Message, 64),
+		timeout: r.config.ReplyTimeout,
+	}
+	client.SetMessageHandler(&sessionHandler{replies: session.replies})
+
+	if _, err := client.ConnectWithTimeout(r.config.Address, r.config.ConnectTimeout); err != nil {
+		atomic.AddInt64(&r.connectErrors, 1)
+		return
+	}
+	defer client.Disconnect()
+	atomic.AddInt64(&r.connected, 1)
+
+	if r.config.Authenticate != nil {
+		if err := r.config.Authenticate(ctx, session); err != nil {
+			atomic.AddInt64(&r.authErrors, 1)
+			return
+		}
+	}
+
+	// Messages are paced on a fixed schedule and latency is measured from
+	// the scheduled send time, so a slow server is not hidden by clients
+	// sending less while they wait
+	interval := time.Duration(float64(time.Second) / r.config.Rate)
+	next := time.Now().Add(time.Duration(rng.Int63n(int64(interval) + 1)))
+	for seq := uint32(1); ; seq++ {
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		step := r.pick(rng)
+		msg := step.step.Build(index, seq)
+
+		var err error
+		if step.step.Reply {
+			_, err = session.Request(ctx, msg)
+		} else {
+			err = session.Send(msg)
+		}
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			// The run ended while waiting, which says nothing about the server
+			return
+		}
+		step.record(time.Since(next), err, rng)
+
+		next = next.Add(interval)
+	}
+}
+
+// pick chooses a step of the mix by weight
+func (r *run) pick(rng *rand.Rand) *stepStats {
+	n := rng.Intn(r.total)
+	for _, step := range r.steps {
+		if n < step.step.Weight {
+			return step
+		}
+		n -= step.step.Weight
+	}
+	return r.steps[len(r.steps)-1]
+}
+
+// report summarizes the run
+func (r *run) report(elapsed time.Duration) *Report {
+	report := &Report{
+		Elapsed:       elapsed,
+		Clients:       r.config.Clients,
+		Connected:     int(atomic.LoadInt64(&r.connected)),
+		ConnectErrors: atomic.LoadInt64(&r.connectErrors),
+		AuthErrors:    atomic.LoadInt64(&r.authErrors),
+	}
+
+	total := newStepStats(Step{Name: "total"}, r.config.MaxSamples)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, step := range r.steps {
+		report.Steps = append(report.Steps, step.report(r.config.Duration))
+		total.merge(step, rng)
+	}
+	report.Total = total.report(r.config.Duration)
+	return report
+}
@@ -0,0 +1,146 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/najoast/sngo/network"
+)
+
+// echoHandler replies to every message but "drop"
+type echoHandler struct{}
+
+func (h *echoHandler) OnMessage(conn network.Connection, msg *network.Message) {
+	if string(msg.Data) == "drop" {
+		return
+	}
+	conn.SendMessage(network.NewMessage(network.MessageTypeData, msg.Data))
+}
+
+func (h *echoHandler) OnError(conn network.Connection, err error) {}
+
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	config := network.DefaultNetworkConfig()
+	config.Address = "127.0.0.1"
+	config.Port = 0
+
+	server, err := network.NewTCPServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	server.SetMessageHandler(&echoHandler{})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+	return server.Listen().String()
+}
+
+func TestRunReportsLatencyAndErrors(t *testing.T) {
+	address := startEchoServer(t)
+
+	var logins int64
+	report, err := Run(context.Background(), Config{
+		Address:      address,
+		Clients:      50,
+		Rate:         50,
+		Duration:     500 * time.Millisecond,
+		RampUp:       100 * time.Millisecond,
+		ReplyTimeout: 100 * time.Millisecond,
+		Authenticate: func(ctx context.Context, s *Session) error {
+			reply, err := s.Request(ctx, network.NewMessage(network.MessageTypeData, []byte("login")))
+			if err == nil && string(reply.Data) != "login" {
+				err = errors.New("unexpected login reply")
+			}
+			if err == nil {
+				atomic.AddInt64(&logins, 1)
+			}
+			return err
+		},
+		Mix: []Step{
+			{Name: "echo", Weight: 9, Reply: true, Build: func(client int, seq uint32) *network.Message {
+				return network.NewMessage(network.MessageTypeData, []byte("hello"))
+			}},
+			{Name: "drop", Weight: 1, Reply: true, Build: func(client int, seq uint32) *network.Message {
+				return network.NewMessage(network.MessageTypeData, []byte("drop"))
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if report.Connected != 50 || report.ConnectErrors != 0 || report.AuthErrors != 0 || atomic.LoadInt64(&logins) != 50 {
+		t.Fatalf("Unexpected connects: %+v", report)
+	}
+	echo, drop := report.Steps[0], report.Steps[1]
+	if echo.Sent == 0 || echo.Errors != 0 || echo.P50 <= 0 || echo.P99 < echo.P50 || echo.Max < echo.P99 {
+		t.Errorf("Unexpected echo step: %+v", echo)
+	}
+	if drop.Sent == 0 || drop.ErrorRate != 1 {
+		t.Errorf("Expected every dropped message to time out: %+v", drop)
+	}
+	if report.Total.Sent != echo.Sent+drop.Sent {
+		t.Errorf("Unexpected totals: %+v", report.Total)
+	}
+
+	var out bytes.Buffer
+	report.WriteText(&out)
+	if !strings.Contains(out.String(), "50/50 clients connected") || !strings.Contains(out.String(), "echo") {
+		t.Errorf("Unexpected text report:\n%s", out.String())
+	}
+}
+
+func TestRunCountsConnectErrors(t *testing.T) {
+	report, err := Run(context.Background(), Config{
+		Address:        "127.0.0.1:1",
+		Clients:        3,
+		Duration:       50 * time.Millisecond,
+		ConnectTimeout: 50 * time.Millisecond,
+		Mix: []Step{{Name: "noop", Weight: 1, Build: func(int, uint32) *network.Message {
+			return network.NewMessage(network.MessageTypeData, nil)
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Connected != 0 || report.ConnectErrors != 3 {
+		t.Errorf("Unexpected connects: %+v", report)
+	}
+
+	if _, err := Run(context.Background(), Config{Address: "x", Duration: time.Second}); err == nil {
+		t.Error("Expected a run without a mix to be rejected")
+	}
+}
+
+func TestMergeWeightsReservoirsBySampleCount(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	busy := newStepStats(Step{Name: "move"}, 100)
+	for i := 0; i < 10000; i++ {
+		busy.record(time.Millisecond, nil, rng)
+	}
+	rare := newStepStats(Step{Name: "login"}, 100)
+	for i := 0; i < 100; i++ {
+		rare.record(time.Second, nil, rng)
+	}
+
+	// 99% of the results took a millisecond, though both reservoirs hold
+	// 100 samples
+	total := newStepStats(Step{Name: "total"}, 100)
+	total.merge(busy, rng)
+	total.merge(rare, rng)
+	report := total.report(time.Second)
+	if len(total.samples) != 100 {
+		t.Errorf("Expected the merged reservoir capped at 100 samples, got %d", len(total.samples))
+	}
+	if report.P90 != time.Millisecond || report.Max != time.Second {
+		t.Errorf("Expected P90 of the busy step and max of the rare one, got %+v", report)
+	}
+}
@@ -0,0 +1,180 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// StepReport summarizes the messages of one step
+type StepReport struct {
+	Name      string        `json:"name"`
+	Sent      int64         `json:"sent"`
+	Errors    int64         `json:"errors"`
+	ErrorRate float64       `json:"error_rate"`
+	PerSecond float64       `json:"per_second"`
+	Mean      time.Duration `json:"mean"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+}
+
+// Report summarizes a load run
+type Report struct {
+	Elapsed       time.Duration `json:"elapsed"`
+	Clients       int           `json:"clients"`
+	Connected     int           `json:"connected"`
+	ConnectErrors int64         `json:"connect_errors"`
+	AuthErrors    int64         `json:"auth_errors"`
+	Steps         []StepReport  `json:"steps"`
+	Total         StepReport    `json:"total"`
+}
+
+// WriteText writes the report as an aligned table
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "%d/%d clients connected in %s (%d connect errors, %d auth errors)\n",
+		r.Connected, r.Clients, r.Elapsed.Round(time.Millisecond), r.ConnectErrors, r.AuthErrors)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSENT\tMSG/S\tERRORS\tMEAN\tP50\tP90\tP99\tMAX")
+	for _, s := range append(r.Steps, r.Total) {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.2f%%\t%s\t%s\t%s\t%s\t%s\n",
+			s.Name, s.Sent, s.PerSecond, s.ErrorRate*100,
+			round(s.Mean), round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+	}
+	return tw.Flush()
+}
+
+// round keeps latencies readable
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+// stepStats collects the results of one step. Latencies are kept in a
+// reservoir so long runs use bounded memory.
+type stepStats struct {
+	step Step
+
+	mu      sync.Mutex
+	sent    int64
+	errors  int64
+	sum     time.Duration
+	max     time.Duration
+	seen    int64
+	samples []time.Duration
+	limit   int
+}
+
+func newStepStats(step Step, limit int) *stepStats {
+	return &stepStats{step: step, limit: limit}
+}
+
+// record adds the result of one message
+func (s *stepStats) record(latency time.Duration, err error, rng *rand.Rand) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent++
+	if err != nil {
+		s.errors++
+		return
+	}
+
+	s.sum += latency
+	if latency > s.max {
+		s.max = latency
+	}
+	s.seen++
+	if len(s.samples) < s.limit {
+		s.samples = append(s.samples, latency)
+	} else if i := rng.Int63n(s.seen); i < int64(s.limit) {
+		s.samples[i] = latency
+	}
+}
+
+// merge adds the results of another step, for the totals. A sample stands
+// for seen/len(samples) results of its reservoir, so the merged reservoir
+// keeps samples weighted by that share rather than concatenating both,
+// which would over-weight the reservoir that saw fewer results.
+func (s *stepStats) merge(other *stepStats, rng *rand.Rand) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+
+	// Weighted sampling without replacement: each sample draws the key
+	// u^(1/weight) and the largest keys are kept
+	type keyed struct {
+		key     float64
+		latency time.Duration
+	}
+	pool := make([]keyed, 0, len(s.samples)+len(other.samples))
+	for _, from := range []*stepStats{s, other} {
+		if len(from.samples) == 0 {
+			continue
+		}
+		weight := float64(from.seen) / float64(len(from.samples))
+		for _, latency := range from.samples {
+			pool = append(pool, keyed{key: math.Pow(rng.Float64(), 1/weight), latency: latency})
+		}
+	}
+	if len(pool) > s.limit {
+		sort.Slice(pool, func(i, j int) bool { return pool[i].key > pool[j].key })
+		pool = pool[:s.limit]
+	}
+	samples := make([]time.Duration, len(pool))
+	for i, sample := range pool {
+		samples[i] = sample.latency
+	}
+	s.samples = samples
+
+	s.sent += other.sent
+	s.errors += other.errors
+	s.sum += other.sum
+	s.seen += other.seen
+	if other.max > s.max {
+		s.max = other.max
+	}
+}
+
+// report computes the percentiles of the step
+func (s *stepStats) report(duration time.Duration) StepReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := StepReport{
+		Name:   s.step.Name,
+		Sent:   s.sent,
+		Errors: s.errors,
+		Max:    s.max,
+	}
+	if s.sent > 0 {
+		report.ErrorRate = float64(s.errors) / float64(s.sent)
+	}
+	if duration > 0 {
+		report.PerSecond = float64(s.sent) / duration.Seconds()
+	}
+	if s.seen > 0 {
+		report.Mean = s.sum / time.Duration(s.seen)
+	}
+
+	sorted := make([]time.Duration, len(s.samples))
+	copy(sorted, s.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	report.P50 = percentile(sorted, 0.50)
+	report.P90 = percentile(sorted, 0.90)
+	report.P99 = percentile(sorted, 0.99)
+	return report
+}
+
+// percentile returns the q-th quantile of sorted samples
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(q*float64(len(sorted)-1)+0.5)]
+}
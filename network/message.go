@@ -3,7 +3,9 @@ package network
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"time"
 )
 
@@ -16,12 +18,14 @@ const (
 	MessageTypeAck       MessageType = 2
 	MessageTypeError     MessageType = 3
 	MessageTypeClose     MessageType = 4
+	MessageTypeTimeSync  MessageType = 5
 
 	// User message types (100+)
 	MessageTypeUserStart MessageType = 100
 	MessageTypeRPC       MessageType = 101
 	MessageTypeData      MessageType = 102
 	MessageTypeBroadcast MessageType = 103
+	MessageTypeStream    MessageType = 104
 )
 
 // String returns the string representation of MessageType
@@ -35,12 +39,16 @@ func (mt MessageType) String() string {
 		return "error"
 	case MessageTypeClose:
 		return "close"
+	case MessageTypeTimeSync:
+		return "time_sync"
 	case MessageTypeRPC:
 		return "rpc"
 	case MessageTypeData:
 		return "data"
 	case MessageTypeBroadcast:
 		return "broadcast"
+	case MessageTypeStream:
+		return "stream"
 	default:
 		return fmt.Sprintf("unknown(%d)", mt)
 	}
@@ -56,6 +64,8 @@ const (
 	MessageFlagPriority   MessageFlag = 1 << 2
 	MessageFlagReliable   MessageFlag = 1 << 3
 	MessageFlagOrderedA   MessageFlag = 1 << 4
+	MessageFlagChecksum   MessageFlag = 1 << 5
+	MessageFlagTimestamp  MessageFlag = 1 << 6
 )
 
 // Message represents a network message with header and payload
@@ -74,6 +84,10 @@ type Message struct {
 	Timestamp time.Time `json:"timestamp"`
 	TTL       uint32    `json:"ttl,omitempty"`
 
+	// SentAt is the nanosecond send time carried by frames with
+	// MessageFlagTimestamp, in the sender's clock
+	SentAt time.Time `json:"sent_at,omitempty"`
+
 	// Payload
 	Data []byte `json:"data,omitempty"`
 
@@ -136,7 +150,21 @@ func (m *Message) HasFlag(flag MessageFlag) bool {
 
 // Size returns the total size of the message in bytes
 func (m *Message) Size() int {
-	return MessageHeaderSize + len(m.Data)
+	return MessageHeaderSize + FrameExtensionSize(m.Flags) + len(m.Data)
+}
+
+// FrameExtensionSize returns the size of the optional fields that follow
+// the header of a frame with the given flags: the checksum, then the send
+// timestamp
+func FrameExtensionSize(flags MessageFlag) int {
+	size := 0
+	if flags&MessageFlagChecksum != 0 {
+		size += ChecksumSize
+	}
+	if flags&MessageFlagTimestamp != 0 {
+		size += TimestampSize
+	}
+	return size
 }
 
 // IsExpired checks if the message has expired based on TTL
@@ -158,6 +186,7 @@ func (m *Message) Clone() *Message {
 		Destination:  m.Destination,
 		Timestamp:    m.Timestamp,
 		TTL:          m.TTL,
+		SentAt:       m.SentAt,
 		ConnectionID: m.ConnectionID,
 		UserData:     m.UserData,
 	}
@@ -180,8 +209,57 @@ const (
 
 	// MaxDataSize is the maximum allowed data payload size
 	MaxDataSize = MaxMessageSize - MessageHeaderSize
+
+	// ChecksumSize is the size of the CRC32-C that follows the header of
+	// frames carrying MessageFlagChecksum; it covers the header and
+	// everything after the checksum, extensions included
+	ChecksumSize = 4
+
+	// TimestampSize is the size of the send timestamp (Unix nanoseconds)
+	// carried by frames with MessageFlagTimestamp
+	TimestampSize = 8
 )
 
+// ErrChecksumMismatch is returned when a frame fails checksum validation
+var ErrChecksumMismatch = errors.New("frame checksum mismatch")
+
+// checksumTable is the CRC32-C (Castagnoli) table used for frame checksums
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// frameChecksum computes the checksum over the header of a frame and the
+// parts following its checksum, in order
+func frameChecksum(header []byte, rest ...[]byte) uint32 {
+	sum := crc32.Update(0, checksumTable, header)
+	for _, part := range rest {
+		sum = crc32.Update(sum, checksumTable, part)
+	}
+	return sum
+}
+
+// VerifyChecksum validates the checksum of a frame read as separate parts:
+// the header, the checksum, then the extensions and payload that follow it
+func VerifyChecksum(header, checksum []byte, rest ...[]byte) error {
+	if len(checksum) != ChecksumSize {
+		return fmt.Errorf("%w: missing checksum", ErrChecksumMismatch)
+	}
+
+	expected := binary.BigEndian.Uint32(checksum)
+	if actual := frameChecksum(header[:MessageHeaderSize], rest...); actual != expected {
+		return fmt.Errorf("%w: expected %08x, got %08x", ErrChecksumMismatch, expected, actual)
+	}
+	return nil
+}
+
+// putFrameTimestamp writes a send timestamp extension
+func putFrameTimestamp(buf []byte, t time.Time) {
+	binary.BigEndian.PutUint64(buf[:TimestampSize], uint64(t.UnixNano()))
+}
+
+// frameTimestamp reads a send timestamp extension
+func frameTimestamp(buf []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(buf[:TimestampSize])))
+}
+
 // MessageCodec handles message encoding and decoding
 type MessageCodec interface {
 	// Encode encodes a message to bytes
@@ -217,7 +295,12 @@ func (c *BinaryMessageCodec) Encode(msg *Message) ([]byte, error) {
 	}
 
 	// Calculate total size
-	totalSize := MessageHeaderSize + dataLen
+	checksumLen := 0
+	if msg.HasFlag(MessageFlagChecksum) {
+		checksumLen = ChecksumSize
+	}
+	extLen := FrameExtensionSize(msg.Flags)
+	totalSize := MessageHeaderSize + extLen + dataLen
 	buf := make([]byte, totalSize)
 
 	// Encode header
@@ -230,7 +313,20 @@ func (c *BinaryMessageCodec) Encode(msg *Message) ([]byte, error) {
 
 	// Copy data
 	if dataLen > 0 {
-		copy(buf[MessageHeaderSize:], msg.Data)
+		copy(buf[MessageHeaderSize+extLen:], msg.Data)
+	}
+
+	if msg.HasFlag(MessageFlagTimestamp) {
+		sentAt := msg.SentAt
+		if sentAt.IsZero() {
+			sentAt = time.Now()
+		}
+		putFrameTimestamp(buf[MessageHeaderSize+checksumLen:], sentAt)
+	}
+
+	if checksumLen > 0 {
+		sum := frameChecksum(buf[:MessageHeaderSize], buf[MessageHeaderSize+ChecksumSize:])
+		binary.BigEndian.PutUint32(buf[MessageHeaderSize:MessageHeaderSize+ChecksumSize], sum)
 	}
 
 	return buf, nil
@@ -258,15 +354,32 @@ func (c *BinaryMessageCodec) Decode(data []byte) (*Message, error) {
 		return nil, fmt.Errorf("message data too large: %d bytes (max %d)", dataLen, MaxDataSize)
 	}
 
-	if len(data) < MessageHeaderSize+int(dataLen) {
+	checksumLen := 0
+	if msg.HasFlag(MessageFlagChecksum) {
+		checksumLen = ChecksumSize
+	}
+	extLen := FrameExtensionSize(msg.Flags)
+
+	if len(data) < MessageHeaderSize+extLen+int(dataLen) {
 		return nil, fmt.Errorf("data too short for message: expected %d, got %d",
-			MessageHeaderSize+int(dataLen), len(data))
+			MessageHeaderSize+extLen+int(dataLen), len(data))
+	}
+
+	end := MessageHeaderSize + extLen + int(dataLen)
+	payload := data[MessageHeaderSize+extLen : end]
+	if checksumLen > 0 {
+		if err := VerifyChecksum(data, data[MessageHeaderSize:MessageHeaderSize+ChecksumSize], data[MessageHeaderSize+ChecksumSize:end]); err != nil {
+			return nil, err
+		}
+	}
+	if msg.HasFlag(MessageFlagTimestamp) {
+		msg.SentAt = frameTimestamp(data[MessageHeaderSize+checksumLen:])
 	}
 
 	// Copy data
 	if dataLen > 0 {
 		msg.Data = make([]byte, dataLen)
-		copy(msg.Data, data[MessageHeaderSize:MessageHeaderSize+int(dataLen)])
+		copy(msg.Data, payload)
 	}
 
 	return msg, nil
@@ -2,6 +2,9 @@
 package network
 
 import (
+	"errors"
+	"io"
+	"net"
 	"testing"
 	"time"
 )
@@ -304,6 +307,109 @@ func TestBinaryMessageCodec(t *testing.T) {
 	})
 }
 
+func TestFrameChecksum(t *testing.T) {
+	codec := NewBinaryMessageCodec()
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		msg := NewMessage(MessageTypeData, []byte("payload"))
+		msg.SetFlag(MessageFlagChecksum)
+
+		data, err := codec.Encode(msg)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		if len(data) != msg.Size() {
+			t.Errorf("Expected %d bytes, got %d", msg.Size(), len(data))
+		}
+
+		decoded, err := codec.Decode(data)
+		if err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if string(decoded.Data) != "payload" {
+			t.Errorf("Unexpected payload %q", decoded.Data)
+		}
+
+		data[len(data)-1] ^= 0xff
+		if _, err := codec.Decode(data); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+		}
+	})
+
+	t.Run("CoversTimestamp", func(t *testing.T) {
+		msg := NewMessage(MessageTypeData, []byte("payload"))
+		msg.SetFlag(MessageFlagChecksum | MessageFlagTimestamp)
+		msg.SentAt = time.Unix(0, 1700000000123456789)
+		data, _ := codec.Encode(msg)
+
+		data[MessageHeaderSize+ChecksumSize] ^= 0x01
+		if _, err := codec.Decode(data); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Expected an altered timestamp detected, got %v", err)
+		}
+	})
+
+	t.Run("DisconnectOnCorruptFrame", func(t *testing.T) {
+		frame := func(payload string, flags MessageFlag, corrupt bool) []byte {
+			msg := NewMessage(MessageTypeData, []byte(payload))
+			msg.SetFlag(flags)
+			data, _ := codec.Encode(msg)
+			if corrupt {
+				data[len(data)-1] ^= 0xff
+			}
+			return data
+		}
+
+		for _, tc := range []struct {
+			name  string
+			frame []byte
+		}{
+			{"mismatch", frame("bad", MessageFlagChecksum, true)},
+			{"unchecksummed", frame("bare", MessageFlagNone, false)},
+		} {
+			raw, peer := net.Pipe()
+			conn := NewTCPConnection(peer)
+			conn.(ChecksumConfigurable).SetChecksum(true)
+
+			go func() {
+				raw.Write(frame("good", MessageFlagChecksum, false))
+				raw.Write(tc.frame)
+			}()
+
+			msg, err := conn.ReadMessage()
+			if err != nil || string(msg.Data) != "good" {
+				t.Fatalf("%s: expected the good frame first, got %v / %v", tc.name, msg, err)
+			}
+			if _, err := conn.ReadMessage(); !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("%s: expected ErrChecksumMismatch, got %v", tc.name, err)
+			}
+			if conn.State() != ConnectionStateClosed {
+				t.Errorf("%s: expected the connection closed, got %s", tc.name, conn.State())
+			}
+			if got := conn.GetStatistics().CorruptFrames; got != 1 {
+				t.Errorf("%s: expected 1 corrupt frame, got %d", tc.name, got)
+			}
+			raw.Close()
+		}
+	})
+
+	t.Run("SendLeavesMessageUntouched", func(t *testing.T) {
+		raw, peer := net.Pipe()
+		defer raw.Close()
+		conn := NewTCPConnection(peer)
+		defer conn.Close()
+		conn.(ChecksumConfigurable).SetChecksum(true)
+
+		go io.Copy(io.Discard, raw)
+		msg := NewMessage(MessageTypeData, []byte("shared"))
+		if err := conn.SendMessage(msg); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		if msg.Flags != MessageFlagNone || msg.ConnectionID != "" {
+			t.Errorf("Expected the sent message untouched, got flags %d and connection %q", msg.Flags, msg.ConnectionID)
+		}
+	})
+}
+
 func TestMessageTypeString(t *testing.T) {
 	tests := []struct {
 		msgType  MessageType
@@ -0,0 +1,341 @@
+// Package network provides in-memory pipe transports for tests
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PipeNetwork is an in-memory network of named listeners backed by net.Pipe.
+// Servers and clients created on it exchange real SNGO frames without binding
+// ports, so protocol handlers and session logic can be tested in isolation.
+type PipeNetwork struct {
+	mu        sync.Mutex
+	listeners map[string]*pipeListener
+	clients   int64 // atomic, used to name client addresses
+}
+
+// NewPipeNetwork creates an empty in-memory network
+func NewPipeNetwork() *PipeNetwork {
+	return &PipeNetwork{
+		listeners: make(map[string]*pipeListener),
+	}
+}
+
+// Listen creates a listener for address on the in-memory network
+func (pn *PipeNetwork) Listen(network, address string) (net.Listener, error) {
+	pn.mu.Lock()
+	defer pn.mu.Unlock()
+
+	if _, exists := pn.listeners[address]; exists {
+		return nil, fmt.Errorf("pipe address %s already in use", address)
+	}
+
+	l := &pipeListener{
+		network: pn,
+		addr:    pipeAddr(address),
+		conns:   make(chan net.Conn),
+		done:    make(chan struct{}),
+	}
+	pn.listeners[address] = l
+	return l, nil
+}
+
+// Dial connects to the listener at address, waiting at most timeout for it
+// to accept (0 waits forever)
+func (pn *PipeNetwork) Dial(network, address string, timeout time.Duration) (net.Conn, error) {
+	pn.mu.Lock()
+	l, exists := pn.listeners[address]
+	pn.mu.Unlock()
+
+	if !exists {
+		return nil, fmt.Errorf("dial pipe %s: connection refused", address)
+	}
+
+	local := pipeAddr(fmt.Sprintf("pipe-client-%d", atomic.AddInt64(&pn.clients, 1)))
+	client, server := net.Pipe()
+	clientConn := &pipeConn{Conn: client, local: local, remote: l.addr}
+	serverConn := &pipeConn{Conn: server, local: l.addr, remote: local}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case l.conns <- serverConn:
+		return clientConn, nil
+	case <-l.done:
+		return nil, fmt.Errorf("dial pipe %s: connection refused", address)
+	case <-expired:
+		client.Close()
+		server.Close()
+		return nil, fmt.Errorf("dial pipe %s: timeout", address)
+	}
+}
+
+// DialRecorded dials address and records the frames exchanged on the connection
+func (pn *PipeNetwork) DialRecorded(address string) (*FrameRecorder, error) {
+	conn, err := pn.Dial(string(ProtocolTCP), address, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return NewFrameRecorder(conn), nil
+}
+
+// NewPipeServer creates a server listening on the in-memory network at
+// config.Address:config.Port
+func NewPipeServer(pn *PipeNetwork, config *NetworkConfig) (Server, error) {
+	server, err := NewTCPServer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	server.(*tcpServer).listen = pn.Listen
+	return server, nil
+}
+
+// NewPipeClient creates a client that dials on the in-memory network
+func NewPipeClient(pn *PipeNetwork, config *NetworkConfig) (Client, error) {
+	client, err := NewTCPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client.(*tcpClient).dial = pn.Dial
+	return client, nil
+}
+
+// NewPipeConnectionPair returns two connected Connections backed by net.Pipe
+func NewPipeConnectionPair() (Connection, Connection) {
+	a, b := net.Pipe()
+	addrA, addrB := pipeAddr("pipe-a"), pipeAddr("pipe-b")
+
+	return NewTCPConnection(&pipeConn{Conn: a, local: addrA, remote: addrB}),
+		NewTCPConnection(&pipeConn{Conn: b, local: addrB, remote: addrA})
+}
+
+// pipeAddr is the address of an in-memory endpoint
+type pipeAddr string
+
+// Network returns the address network name
+func (a pipeAddr) Network() string { return "pipe" }
+
+// String returns the address name
+func (a pipeAddr) String() string { return string(a) }
+
+// pipeConn reports meaningful addresses for a net.Pipe endpoint
+type pipeConn struct {
+	net.Conn
+	local  net.Addr
+	remote net.Addr
+}
+
+// LocalAddr returns the local endpoint address
+func (c *pipeConn) LocalAddr() net.Addr { return c.local }
+
+// RemoteAddr returns the remote endpoint address
+func (c *pipeConn) RemoteAddr() net.Addr { return c.remote }
+
+// pipeListener accepts connections dialed on a PipeNetwork
+type pipeListener struct {
+	network *PipeNetwork
+	addr    pipeAddr
+	conns   chan net.Conn
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Accept waits for the next dialed connection
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting and frees the address
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+
+		l.network.mu.Lock()
+		delete(l.network.listeners, string(l.addr))
+		l.network.mu.Unlock()
+	})
+	return nil
+}
+
+// Addr returns the listening address
+func (l *pipeListener) Addr() net.Addr {
+	return l.addr
+}
+
+// FrameRecorder wraps a raw connection and decodes the SNGO frames written
+// to and read from it, so tests can assert on the exact traffic
+type FrameRecorder struct {
+	net.Conn
+
+	mu       sync.Mutex
+	codec    *BinaryMessageCodec
+	sentBuf  []byte
+	recvBuf  []byte
+	sent     []*Message
+	received []*Message
+}
+
+// NewFrameRecorder starts recording frames on conn
+func NewFrameRecorder(conn net.Conn) *FrameRecorder {
+	return &FrameRecorder{
+		Conn:  conn,
+		codec: NewBinaryMessageCodec(),
+	}
+}
+
+// Write writes to the connection and records complete outgoing frames
+func (r *FrameRecorder) Write(p []byte) (int, error) {
+	n, err := r.Conn.Write(p)
+	r.record(p[:n], &r.sentBuf, &r.sent)
+	return n, err
+}
+
+// Read reads from the connection and records complete incoming frames
+func (r *FrameRecorder) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	r.record(p[:n], &r.recvBuf, &r.received)
+	return n, err
+}
+
+// WriteMessage encodes and writes msg as one frame
+func (r *FrameRecorder) WriteMessage(msg *Message) error {
+	data, err := r.codec.Encode(msg)
+	if err != nil {
+		return err
+	}
+	_, err = r.Write(data)
+	return err
+}
+
+// ReadMessage reads the next frame, failing after timeout (0 waits forever)
+func (r *FrameRecorder) ReadMessage(timeout time.Duration) (*Message, error) {
+	if timeout > 0 {
+		r.Conn.SetReadDeadline(time.Now().Add(timeout))
+		defer r.Conn.SetReadDeadline(time.Time{})
+	}
+	return ReadFrame(r)
+}
+
+// Sent returns the frames written so far
+func (r *FrameRecorder) Sent() []*Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Message(nil), r.sent...)
+}
+
+// Received returns the frames read so far
+func (r *FrameRecorder) Received() []*Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Message(nil), r.received...)
+}
+
+// record appends data to buf and moves every complete frame into frames
+func (r *FrameRecorder) record(data []byte, buf *[]byte, frames *[]*Message) {
+	if len(data) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	*buf = append(*buf, data...)
+	for len(*buf) >= MessageHeaderSize {
+		header, err := r.codec.DecodeHeader(*buf)
+		if err != nil {
+			*buf = nil
+			return
+		}
+		size := MessageHeaderSize + FrameExtensionSize(header.Flags) + cap(header.Data)
+		if len(*buf) < size {
+			return
+		}
+
+		msg, err := r.codec.Decode((*buf)[:size])
+		if err == nil {
+			*frames = append(*frames, msg)
+		}
+		*buf = append([]byte(nil), (*buf)[size:]...)
+	}
+}
+
+// ReadFrame reads exactly one SNGO frame from r
+func ReadFrame(r io.Reader) (*Message, error) {
+	codec := NewBinaryMessageCodec()
+
+	header := make([]byte, MessageHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read frame header: %w", err)
+	}
+
+	msg, err := codec.DecodeHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var checksum []byte
+	if msg.HasFlag(MessageFlagChecksum) {
+		checksum = make([]byte, ChecksumSize)
+		if _, err := io.ReadFull(r, checksum); err != nil {
+			return nil, fmt.Errorf("failed to read frame checksum: %w", err)
+		}
+	}
+
+	var stamp []byte
+	if msg.HasFlag(MessageFlagTimestamp) {
+		stamp = make([]byte, TimestampSize)
+		if _, err := io.ReadFull(r, stamp); err != nil {
+			return nil, fmt.Errorf("failed to read frame timestamp: %w", err)
+		}
+		msg.SentAt = frameTimestamp(stamp)
+	}
+
+	if size := cap(msg.Data); size > 0 {
+		msg.Data = make([]byte, size)
+		if _, err := io.ReadFull(r, msg.Data); err != nil {
+			return nil, fmt.Errorf("failed to read frame data: %w", err)
+		}
+	}
+
+	if checksum != nil {
+		if err := VerifyChecksum(header, checksum, stamp, msg.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
+
+// ExpectFrame returns an error describing how msg differs from the expected
+// type and payload, or nil if it matches
+func ExpectFrame(msg *Message, msgType MessageType, data []byte) error {
+	if msg == nil {
+		return fmt.Errorf("expected %s frame, got none", msgType)
+	}
+	if msg.Type != msgType {
+		return fmt.Errorf("expected %s frame, got %s", msgType, msg.Type)
+	}
+	if !bytes.Equal(msg.Data, data) {
+		return fmt.Errorf("%s frame payload mismatch: expected %q, got %q", msgType, data, msg.Data)
+	}
+	return nil
+}
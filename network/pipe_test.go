@@ -0,0 +1,127 @@
+// Package network provides tests for the in-memory pipe transport
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+// newEchoPipeServer starts a pipe server that echoes data frames
+func newEchoPipeServer(t *testing.T, pn *PipeNetwork) Server {
+	config := DefaultNetworkConfig()
+	config.Address = "echo"
+	config.Port = 1
+
+	server, err := NewPipeServer(pn, config)
+	if err != nil {
+		t.Fatalf("Failed to create pipe server: %v", err)
+	}
+
+	server.SetMessageHandler(&testMessageHandler{
+		onMessage: func(conn Connection, msg *Message) {
+			conn.SendMessage(NewMessage(MessageTypeData, msg.Data))
+		},
+	})
+
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start pipe server: %v", err)
+	}
+	return server
+}
+
+func TestPipeServerFrames(t *testing.T) {
+	pn := NewPipeNetwork()
+	server := newEchoPipeServer(t, pn)
+	defer server.Stop()
+
+	if server.Listen().String() != "echo:1" {
+		t.Errorf("Unexpected listen address %s", server.Listen())
+	}
+
+	rec, err := pn.DialRecorded("echo:1")
+	if err != nil {
+		t.Fatalf("Failed to dial pipe server: %v", err)
+	}
+	defer rec.Close()
+
+	if err := rec.WriteMessage(NewMessage(MessageTypeRPC, []byte("ping"))); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+
+	reply, err := rec.ReadMessage(time.Second)
+	if err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	if err := ExpectFrame(reply, MessageTypeData, []byte("ping")); err != nil {
+		t.Error(err)
+	}
+
+	sent, received := rec.Sent(), rec.Received()
+	if len(sent) != 1 || len(received) != 1 {
+		t.Fatalf("Expected 1 sent / 1 received frame, got %d / %d", len(sent), len(received))
+	}
+	if err := ExpectFrame(sent[0], MessageTypeRPC, []byte("ping")); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := pn.Dial("tcp", "missing:1", time.Second); err == nil {
+		t.Error("Expected dial to an unknown address to fail")
+	}
+}
+
+func TestPipeClient(t *testing.T) {
+	pn := NewPipeNetwork()
+	server := newEchoPipeServer(t, pn)
+	defer server.Stop()
+
+	client, err := NewPipeClient(pn, DefaultNetworkConfig())
+	if err != nil {
+		t.Fatalf("Failed to create pipe client: %v", err)
+	}
+
+	replies := make(chan *Message, 1)
+	client.SetMessageHandler(&testMessageHandler{
+		onMessage: func(conn Connection, msg *Message) {
+			replies <- msg
+		},
+	})
+
+	if _, err := client.Connect("echo:1"); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	if err := client.SendMessage(NewMessage(MessageTypeRPC, []byte("hello"))); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	select {
+	case msg := <-replies:
+		if err := ExpectFrame(msg, MessageTypeData, []byte("hello")); err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for echo")
+	}
+}
+
+func TestPipeConnectionPair(t *testing.T) {
+	a, b := NewPipeConnectionPair()
+	defer a.Close()
+	defer b.Close()
+
+	if err := a.SendMessage(NewMessage(MessageTypeData, []byte("x"))); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	msg, err := b.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if err := ExpectFrame(msg, MessageTypeData, []byte("x")); err != nil {
+		t.Error(err)
+	}
+	if b.RemoteAddr().String() != "pipe-a" {
+		t.Errorf("Unexpected remote address %s", b.RemoteAddr())
+	}
+}
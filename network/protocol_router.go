@@ -0,0 +1,167 @@
+// Package network provides first-frame protocol routing
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ProtocolHandler takes over a raw connection selected by a ProtocolRouter
+type ProtocolHandler interface {
+	// ServeConn serves the connection; the first peeked bytes are replayed
+	ServeConn(conn net.Conn)
+}
+
+// ProtocolHandlerFunc adapts a function to the ProtocolHandler interface
+type ProtocolHandlerFunc func(conn net.Conn)
+
+// ServeConn calls f(conn)
+func (f ProtocolHandlerFunc) ServeConn(conn net.Conn) {
+	f(conn)
+}
+
+// ProtocolMatcher reports whether the first bytes of a connection belong to a protocol
+type ProtocolMatcher func(first []byte) bool
+
+// ProtocolRoute binds a matcher to the stack that serves matching connections.
+// Exactly one of Handler (raw connection) or MessageHandler (framed SNGO
+// messages with their own handler) should be set.
+type ProtocolRoute struct {
+	Name           string
+	Match          ProtocolMatcher
+	Handler        ProtocolHandler
+	MessageHandler MessageHandler
+}
+
+// ProtocolRouter inspects the first inbound bytes of each connection and
+// dispatches it to the first matching route. Connections matching no route
+// are served by the server's default message handler.
+type ProtocolRouter struct {
+	mu          sync.RWMutex
+	routes      []ProtocolRoute
+	peekSize    int
+	peekTimeout time.Duration
+}
+
+// NewProtocolRouter creates a router that peeks up to peekSize bytes,
+// waiting at most peekTimeout for them to arrive
+func NewProtocolRouter(peekSize int, peekTimeout time.Duration) *ProtocolRouter {
+	if peekSize <= 0 {
+		peekSize = 8
+	}
+	if peekTimeout <= 0 {
+		peekTimeout = 5 * time.Second
+	}
+
+	return &ProtocolRouter{
+		peekSize:    peekSize,
+		peekTimeout: peekTimeout,
+	}
+}
+
+// AddRoute registers a route; routes are tried in registration order
+func (r *ProtocolRouter) AddRoute(route ProtocolRoute) error {
+	if route.Match == nil {
+		return fmt.Errorf("protocol route %q has no matcher", route.Name)
+	}
+	if (route.Handler == nil) == (route.MessageHandler == nil) {
+		return fmt.Errorf("protocol route %q needs exactly one of Handler or MessageHandler", route.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes = append(r.routes, route)
+	return nil
+}
+
+// Routes returns the names of the registered routes in match order
+func (r *ProtocolRouter) Routes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, len(r.routes))
+	for i, route := range r.routes {
+		names[i] = route.Name
+	}
+	return names
+}
+
+// Peek reads the first bytes of conn and returns the matching route (nil if
+// none matched) together with a connection that replays the peeked bytes
+func (r *ProtocolRouter) Peek(conn net.Conn) (*ProtocolRoute, net.Conn, error) {
+	conn.SetReadDeadline(time.Now().Add(r.peekTimeout))
+	buf := make([]byte, r.peekSize)
+	n, err := io.ReadAtLeast(conn, buf, 1)
+	conn.SetReadDeadline(time.Time{})
+
+	if err != nil {
+		return nil, conn, fmt.Errorf("failed to peek first frame: %w", err)
+	}
+
+	// Short clients may send fewer bytes than peekSize in their first write;
+	// keep reading until the buffer is full or the client pauses briefly.
+	for n < len(buf) {
+		conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		m, err := conn.Read(buf[n:])
+		n += m
+		if err != nil {
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	first := buf[:n]
+	wrapped := &peekedConn{Conn: conn, reader: io.MultiReader(bytes.NewReader(first), conn)}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := range r.routes {
+		if r.routes[i].Match(first) {
+			route := r.routes[i]
+			return &route, wrapped, nil
+		}
+	}
+
+	return nil, wrapped, nil
+}
+
+// peekedConn replays peeked bytes before reading from the underlying conn
+type peekedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+// Read reads the replayed bytes first, then the live connection
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// MatchPrefix matches connections whose first bytes equal prefix
+func MatchPrefix(prefix []byte) ProtocolMatcher {
+	return func(first []byte) bool {
+		return bytes.HasPrefix(first, prefix)
+	}
+}
+
+// MatchHTTP matches connections starting with a plain HTTP/1.x request line
+func MatchHTTP() ProtocolMatcher {
+	methods := [][]byte{
+		[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("HEAD "),
+		[]byte("DELETE "), []byte("OPTIONS "), []byte("PATCH "),
+	}
+
+	return func(first []byte) bool {
+		for _, method := range methods {
+			if bytes.HasPrefix(first, method) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,163 @@
+// Package network provides tests for first-frame protocol routing
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProtocolRouterPeek(t *testing.T) {
+	router := NewProtocolRouter(4, time.Second)
+	if err := router.AddRoute(ProtocolRoute{
+		Name:    "health",
+		Match:   MatchPrefix([]byte("PING")),
+		Handler: ProtocolHandlerFunc(func(conn net.Conn) {}),
+	}); err != nil {
+		t.Fatalf("Failed to add route: %v", err)
+	}
+
+	if err := router.AddRoute(ProtocolRoute{Name: "broken", Match: MatchHTTP()}); err == nil {
+		t.Error("Expected route without handler to be rejected")
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	go client.Write([]byte("PING\n"))
+
+	route, conn, err := router.Peek(server)
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if route == nil || route.Name != "health" {
+		t.Fatalf("Expected health route, got %v", route)
+	}
+
+	// The peeked bytes must be replayed to the handler
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "PING\n" {
+		t.Errorf("Expected replayed 'PING\\n', got %q (%v)", line, err)
+	}
+}
+
+func TestTCPServerProtocolRouting(t *testing.T) {
+	config := DefaultNetworkConfig()
+	config.Address = "127.0.0.1"
+	config.Port = 0
+
+	server, err := NewTCPServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	router := NewProtocolRouter(4, time.Second)
+	router.AddRoute(ProtocolRoute{
+		Name:  "http",
+		Match: MatchHTTP(),
+		Handler: ProtocolHandlerFunc(func(conn net.Conn) {
+			defer conn.Close()
+			bufio.NewReader(conn).ReadString('\n')
+			fmt.Fprint(conn, "HTTP/1.0 200 OK\r\n\r\n")
+		}),
+	})
+	server.(ProtocolRoutable).SetProtocolRouter(router)
+
+	received := make(chan *Message, 1)
+	server.SetMessageHandler(&testMessageHandler{onMessage: func(conn Connection, msg *Message) {
+		received <- msg
+	}})
+
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	addr := server.Listen().String()
+
+	// Auxiliary protocol on the shared port
+	httpConn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer httpConn.Close()
+
+	fmt.Fprint(httpConn, "GET /health HTTP/1.0\r\n\r\n")
+	httpConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	status, err := bufio.NewReader(httpConn).ReadString('\n')
+	if err != nil || status != "HTTP/1.0 200 OK\r\n" {
+		t.Errorf("Expected HTTP response, got %q (%v)", status, err)
+	}
+
+	// Default framed protocol still works
+	client, _ := NewTCPClient(DefaultNetworkConfig())
+	conn, err := client.Connect(addr)
+	if err != nil {
+		t.Fatalf("Failed to connect client: %v", err)
+	}
+	defer client.Disconnect()
+
+	if err := conn.SendMessage(NewMessage(MessageTypeData, []byte("game"))); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if string(msg.Data) != "game" {
+			t.Errorf("Expected 'game', got %q", msg.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for framed message")
+	}
+}
+
+func TestTCPServerStopClosesRawHandlers(t *testing.T) {
+	config := DefaultNetworkConfig()
+	config.Address = "127.0.0.1"
+	config.Port = 0
+
+	server, err := NewTCPServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	serving := make(chan struct{})
+	returned := make(chan struct{})
+	router := NewProtocolRouter(4, time.Second)
+	router.AddRoute(ProtocolRoute{
+		Name:  "hold",
+		Match: MatchPrefix([]byte("HOLD")),
+		Handler: ProtocolHandlerFunc(func(conn net.Conn) {
+			defer close(returned)
+			close(serving)
+			io.Copy(io.Discard, conn)
+		}),
+	})
+	server.(ProtocolRoutable).SetProtocolRouter(router)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", server.Listen().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "HOLD")
+	select {
+	case <-serving:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the raw handler")
+	}
+
+	// Stop closes the raw connection and waits for its handler
+	server.Stop()
+	select {
+	case <-returned:
+	default:
+		t.Error("Expected the raw handler returned once Stop returns")
+	}
+}
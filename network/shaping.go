@@ -0,0 +1,340 @@
+// Package network provides simulated bad network conditions for playtesting
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLossUnsupported is returned when packet loss is requested on a stream
+// connection, which cannot drop frames without breaking the stream
+var ErrLossUnsupported = errors.New("packet loss can only be simulated on datagram connections")
+
+// ErrShapingDisabled is returned when shaping is requested over the admin
+// API while ShapingConfig does not allow it
+var ErrShapingDisabled = errors.New("connection shaping is disabled")
+
+// ShapingConfig guards the shaping admin API. Enable it on playtest
+// servers only: it lets anyone reaching the admin port slow down or drop
+// any live connection
+type ShapingConfig struct {
+	// Enabled allows shaping to be applied and cleared
+	Enabled bool
+}
+
+// DefaultShapingConfig returns shaping disabled
+func DefaultShapingConfig() ShapingConfig {
+	return ShapingConfig{}
+}
+
+// shapingQueue is the number of outbound frames a shaped connection holds
+// back before Send blocks
+const shapingQueue = 1024
+
+// Shaping describes simulated network conditions on a connection. Latency,
+// jitter and the bandwidth cap apply to each direction separately, so a
+// round trip gains twice the latency.
+type Shaping struct {
+	// Latency is added to every frame
+	Latency time.Duration `json:"latency"`
+
+	// Jitter adds a random delay of up to Jitter to every frame; frames
+	// are never reordered
+	Jitter time.Duration `json:"jitter"`
+
+	// Bandwidth caps the bytes per second, 0 for no cap
+	Bandwidth int64 `json:"bandwidth"`
+
+	// Loss is the fraction of datagrams dropped, for datagram transports
+	Loss float64 `json:"loss"`
+}
+
+// Validate checks the shaping values
+func (s Shaping) Validate() error {
+	if s.Latency < 0 || s.Jitter < 0 || s.Bandwidth < 0 {
+		return fmt.Errorf("shaping values must not be negative")
+	}
+	if s.Loss < 0 || s.Loss > 1 {
+		return fmt.Errorf("loss must be between 0 and 1, got %g", s.Loss)
+	}
+	return nil
+}
+
+// IsZero reports whether the shaping leaves traffic untouched
+func (s Shaping) IsZero() bool {
+	return s == Shaping{}
+}
+
+// Shapeable is implemented by connections that can simulate bad network
+// conditions
+type Shapeable interface {
+	// SetShaping changes the simulated conditions; a zero Shaping stops
+	// delaying frames
+	SetShaping(shaping Shaping) error
+
+	// Shaping returns the simulated conditions
+	Shaping() Shaping
+}
+
+// delayLine computes when frames passing one direction of a shaped
+// connection are released
+type delayLine struct {
+	mu        sync.Mutex
+	busyUntil time.Time // when the link finishes sending the last frame
+	last      time.Time // release time of the last frame
+}
+
+// schedule returns when a frame of size bytes entering the line at now is
+// released under shaping
+func (d *delayLine) schedule(shaping Shaping, size int, now time.Time) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sent := now
+	if shaping.Bandwidth > 0 {
+		if d.busyUntil.After(sent) {
+			sent = d.busyUntil
+		}
+		sent = sent.Add(time.Duration(int64(size) * int64(time.Second) / shaping.Bandwidth))
+		d.busyUntil = sent
+	}
+
+	release := sent.Add(shaping.Latency)
+	if shaping.Jitter > 0 {
+		release = release.Add(time.Duration(rand.Int63n(int64(shaping.Jitter) + 1)))
+	}
+	if release.Before(d.last) {
+		release = d.last
+	}
+	d.last = release
+	return release
+}
+
+// shapedFrame is a frame held back until its release time
+type shapedFrame struct {
+	data    []byte
+	msg     *Message
+	err     error
+	release time.Time
+}
+
+// shaper holds back the frames of a shaped connection. Outbound frames are
+// queued by Send and written by a goroutine at their release time; inbound
+// frames are read ahead by a goroutine, started by the reader, and handed
+// to ReadMessage at theirs.
+type shaper struct {
+	settings atomic.Value // Shaping
+	out      delayLine
+	in       delayLine
+
+	outbound chan shapedFrame
+	inbound  chan shapedFrame
+	stop     chan struct{}
+	stopOnce sync.Once
+	reading  bool // only touched by the reader
+}
+
+// newShaper creates a shaper with the given conditions
+func newShaper(shaping Shaping) *shaper {
+	sh := &shaper{
+		outbound: make(chan shapedFrame, shapingQueue),
+		inbound:  make(chan shapedFrame, shapingQueue),
+		stop:     make(chan struct{}),
+	}
+	sh.settings.Store(shaping)
+	return sh
+}
+
+// shaping returns the current conditions
+func (sh *shaper) shaping() Shaping {
+	return sh.settings.Load().(Shaping)
+}
+
+// close stops the shaper goroutines
+func (sh *shaper) close() {
+	sh.stopOnce.Do(func() { close(sh.stop) })
+}
+
+// send queues an outbound frame
+func (sh *shaper) send(data []byte) error {
+	frame := shapedFrame{data: data, release: sh.out.schedule(sh.shaping(), len(data), time.Now())}
+	select {
+	case sh.outbound <- frame:
+		return nil
+	case <-sh.stop:
+		return fmt.Errorf("connection is closed")
+	}
+}
+
+// writeLoop writes outbound frames at their release time
+func (sh *shaper) writeLoop(write func(data []byte) error) {
+	for {
+		select {
+		case frame := <-sh.outbound:
+			if !sh.wait(frame.release) {
+				return
+			}
+			if err := write(frame.data); err != nil {
+				return
+			}
+		case <-sh.stop:
+			return
+		}
+	}
+}
+
+// read returns the next inbound frame at its release time, starting the
+// read-ahead goroutine on the first call
+func (sh *shaper) read(readFrame func() (*Message, int, error)) (*Message, error) {
+	if !sh.reading {
+		sh.reading = true
+		go sh.readLoop(readFrame)
+	}
+
+	select {
+	case frame := <-sh.inbound:
+		if frame.err == nil && !sh.wait(frame.release) {
+			return nil, fmt.Errorf("connection is closed")
+		}
+		return frame.msg, frame.err
+	case <-sh.stop:
+		return nil, fmt.Errorf("connection is closed")
+	}
+}
+
+// readLoop reads inbound frames ahead of the reader, stamping each with its
+// release time
+func (sh *shaper) readLoop(readFrame func() (*Message, int, error)) {
+	for {
+		msg, size, err := readFrame()
+		frame := shapedFrame{msg: msg, err: err}
+		if err == nil {
+			frame.release = sh.in.schedule(sh.shaping(), size, time.Now())
+		}
+		select {
+		case sh.inbound <- frame:
+		case <-sh.stop:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wait sleeps until release, returning false if the shaper stopped first
+func (sh *shaper) wait(release time.Time) bool {
+	delay := time.Until(release)
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-sh.stop:
+		return false
+	}
+}
+
+// ShapingRequest sets the simulated conditions of the connections matching
+// ConnectionID or, if it is empty, RemoteIP
+type ShapingRequest struct {
+	ConnectionID string `json:"connection_id,omitempty"`
+	RemoteIP     string `json:"remote_ip,omitempty"`
+	Shaping
+}
+
+// ShapedConnection reports the simulated conditions of a connection
+type ShapedConnection struct {
+	ConnectionID string  `json:"connection_id"`
+	RemoteAddr   string  `json:"remote_addr"`
+	Shaping      Shaping `json:"shaping"`
+}
+
+// matches reports whether a connection is selected by the request
+func (r ShapingRequest) matches(conn Connection) bool {
+	if r.ConnectionID != "" {
+		return conn.ID() == r.ConnectionID
+	}
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	return ok && addr.IP.String() == r.RemoteIP
+}
+
+// ShapingAdminHandler exposes connection shaping over HTTP for the admin
+// API, so QA can playtest against a real server under bad network
+// conditions. GET lists the shaped connections, POST applies a
+// ShapingRequest and DELETE ?id=tcp-1 (or ?ip=10.0.0.5) clears shaping.
+// Unless config is enabled, POST and DELETE are refused with 403.
+func ShapingAdminHandler(server Server, config ShapingConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.Enabled && (r.Method == http.MethodPost || r.Method == http.MethodDelete) {
+			http.Error(w, ErrShapingDisabled.Error(), http.StatusForbidden)
+			return
+		}
+
+		var req ShapingRequest
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			req.ConnectionID = r.URL.Query().Get("id")
+			req.RemoteIP = r.URL.Query().Get("ip")
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			if req.ConnectionID == "" && req.RemoteIP == "" {
+				http.Error(w, "connection_id or remote_ip is required", http.StatusBadRequest)
+				return
+			}
+			matched := 0
+			for _, conn := range server.GetActiveConnections() {
+				shapeable, ok := conn.(Shapeable)
+				if !ok || !req.matches(conn) {
+					continue
+				}
+				if err := shapeable.SetShaping(req.Shaping); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				matched++
+			}
+			if matched == 0 {
+				http.Error(w, "no matching connection", http.StatusNotFound)
+				return
+			}
+		}
+
+		shaped := []ShapedConnection{}
+		for _, conn := range server.GetActiveConnections() {
+			if shapeable, ok := conn.(Shapeable); ok && !shapeable.Shaping().IsZero() {
+				shaped = append(shaped, ShapedConnection{
+					ConnectionID: conn.ID(),
+					RemoteAddr:   fmt.Sprint(conn.RemoteAddr()),
+					Shaping:      shapeable.Shaping(),
+				})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Connections []ShapedConnection `json:"connections"`
+		}{
+			Connections: shaped,
+		})
+	})
+}
@@ -0,0 +1,170 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelayLineSchedule(t *testing.T) {
+	var line delayLine
+	now := time.Now()
+
+	// 1000 bytes at 10000 B/s take 100ms on the link, then the latency
+	shaping := Shaping{Latency: 50 * time.Millisecond, Bandwidth: 10000}
+	first := line.schedule(shaping, 1000, now)
+	second := line.schedule(shaping, 1000, now)
+	if got := first.Sub(now); got != 150*time.Millisecond {
+		t.Errorf("Expected the first frame after 150ms, got %v", got)
+	}
+	if got := second.Sub(now); got != 250*time.Millisecond {
+		t.Errorf("Expected the second frame to queue behind the first, got %v", got)
+	}
+
+	// Jitter never reorders frames
+	jittery := Shaping{Jitter: 100 * time.Millisecond}
+	last := time.Time{}
+	for i := 0; i < 100; i++ {
+		release := line.schedule(jittery, 10, now)
+		if release.Before(last) {
+			t.Fatal("Expected frames released in order")
+		}
+		last = release
+	}
+}
+
+// readTimes reads messages from conn, sending the time each one arrived
+func readTimes(conn Connection) <-chan time.Time {
+	arrivals := make(chan time.Time, 16)
+	go func() {
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			arrivals <- time.Now()
+		}
+	}()
+	return arrivals
+}
+
+func TestConnectionShaping(t *testing.T) {
+	a, b := NewPipeConnectionPair()
+	defer a.Close()
+	defer b.Close()
+	arrivals := readTimes(b)
+
+	const latency = 60 * time.Millisecond
+	if err := a.(Shapeable).SetShaping(Shaping{Latency: latency}); err != nil {
+		t.Fatalf("Failed to shape: %v", err)
+	}
+	if err := a.(Shapeable).SetShaping(Shaping{Loss: 0.1}); !errors.Is(err, ErrLossUnsupported) {
+		t.Errorf("Expected ErrLossUnsupported, got %v", err)
+	}
+	if err := a.(Shapeable).SetShaping(Shaping{Latency: -time.Second}); err == nil {
+		t.Error("Expected negative latency to be rejected")
+	}
+
+	sent := time.Now()
+	a.SendMessage(NewMessage(MessageTypeData, []byte("outbound")))
+	if delay := (<-arrivals).Sub(sent); delay < latency {
+		t.Errorf("Expected outbound frame delayed by %v, took %v", latency, delay)
+	}
+
+	// Shaping the receiver delays inbound frames too
+	b.(Shapeable).SetShaping(Shaping{Latency: latency})
+	sent = time.Now()
+	a.SendMessage(NewMessage(MessageTypeData, []byte("both")))
+	if delay := (<-arrivals).Sub(sent); delay < 2*latency {
+		t.Errorf("Expected frame delayed by %v each way, took %v", latency, delay)
+	}
+
+	a.(Shapeable).SetShaping(Shaping{})
+	b.(Shapeable).SetShaping(Shaping{})
+	sent = time.Now()
+	a.SendMessage(NewMessage(MessageTypeData, []byte("clear")))
+	if delay := (<-arrivals).Sub(sent); delay >= latency {
+		t.Errorf("Expected no delay once shaping is cleared, took %v", delay)
+	}
+}
+
+func TestShapingAdminHandler(t *testing.T) {
+	pn := NewPipeNetwork()
+	server := newEchoPipeServer(t, pn)
+	defer server.Stop()
+
+	client, err := NewPipeClient(pn, DefaultNetworkConfig())
+	if err != nil {
+		t.Fatalf("Failed to create pipe client: %v", err)
+	}
+	replies := make(chan *Message, 1)
+	client.SetMessageHandler(&testMessageHandler{
+		onMessage: func(conn Connection, msg *Message) { replies <- msg },
+	})
+	if _, err := client.Connect("echo:1"); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	deadline := time.Now().Add(time.Second)
+	for server.GetConnectionCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	id := server.GetActiveConnections()[0].ID()
+
+	handler := ShapingAdminHandler(server, DefaultShapingConfig())
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	const latency = 40 * time.Millisecond
+	body, _ := json.Marshal(ShapingRequest{ConnectionID: id, Shaping: Shaping{Latency: latency}})
+	if rec := serve(http.MethodPost, "/admin/shaping", string(body)); rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected shaping refused by default, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/admin/shaping", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected listing allowed while disabled, got %d", rec.Code)
+	}
+
+	handler = ShapingAdminHandler(server, ShapingConfig{Enabled: true})
+	if rec := serve(http.MethodPost, "/admin/shaping", string(body)); rec.Code != http.StatusOK {
+		t.Fatalf("Expected shaping applied, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodPost, "/admin/shaping", `{"connection_id":"tcp-0"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown connection rejected, got %d", rec.Code)
+	}
+
+	var listed struct {
+		Connections []ShapedConnection `json:"connections"`
+	}
+	json.NewDecoder(serve(http.MethodGet, "/admin/shaping", "").Body).Decode(&listed)
+	if len(listed.Connections) != 1 || listed.Connections[0].Shaping.Latency != latency {
+		t.Fatalf("Expected the shaped connection listed, got %+v", listed)
+	}
+
+	// The server delays the request on the way in and the echo on the way out
+	sent := time.Now()
+	client.SendMessage(NewMessage(MessageTypeRPC, bytes.Repeat([]byte("x"), 10)))
+	select {
+	case <-replies:
+		if rtt := time.Since(sent); rtt < 2*latency {
+			t.Errorf("Expected a round trip of at least %v, took %v", 2*latency, rtt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for echo")
+	}
+
+	if rec := serve(http.MethodDelete, "/admin/shaping?id="+id, ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected shaping cleared, got %d", rec.Code)
+	}
+	json.NewDecoder(serve(http.MethodGet, "/admin/shaping", "").Body).Decode(&listed)
+	if len(listed.Connections) != 0 {
+		t.Errorf("Expected no shaped connections, got %+v", listed.Connections)
+	}
+}
@@ -0,0 +1,296 @@
+// Package network provides framing compatible with skynet gateserver clients
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/najoast/sngo/crypt"
+)
+
+// Framing selects the wire format of the connections of a listener
+type Framing string
+
+const (
+	// FramingSNGO is the native frame format of BinaryMessageCodec
+	FramingSNGO Framing = ""
+
+	// FramingSkynetGate is the skynet gateserver format: each packet is a
+	// 2-byte big-endian length followed by the payload, with no header.
+	// Packets are read as MessageTypeData messages and only the Data of
+	// outgoing user messages is sent.
+	FramingSkynetGate Framing = "skynet_gate"
+
+	// FramingSkynetMsgServer is the skynet msgserver format: gateserver
+	// packets after a text handshake, with a 4-byte session trailing
+	// requests and an ok byte and session trailing responses. The session
+	// is carried in Message.SessionID, and error messages are sent as
+	// failed responses.
+	FramingSkynetMsgServer Framing = "skynet_msgserver"
+)
+
+// SkynetMaxPacketSize is the largest payload of a skynet packet
+const SkynetMaxPacketSize = 0xffff
+
+// skynetSessionSize is the size of the session trailing msgserver packets
+const skynetSessionSize = 4
+
+// HandshakeStatusBadRequest is the status of a malformed skynet handshake
+const HandshakeStatusBadRequest = 400
+
+// skynetStatusText are the messages skynet msgserver sends with its codes
+var skynetStatusText = map[int]string{
+	HandshakeStatusOK:           "OK",
+	HandshakeStatusBadRequest:   "Bad Request",
+	HandshakeStatusUnauthorized: "Unauthorized",
+	HandshakeStatusForbidden:    "Index Expired",
+	HandshakeStatusNotFound:     "User Not Found",
+}
+
+// ErrSkynetPacketTooLarge is returned for payloads over SkynetMaxPacketSize
+var ErrSkynetPacketTooLarge = errors.New("skynet packet too large")
+
+// FramingConfigurable is implemented by connections supporting other wire
+// formats than the native one
+type FramingConfigurable interface {
+	// SetFraming sets the wire format of the connection
+	SetFraming(framing Framing)
+}
+
+// configureFraming applies the framing of a listener to a connection
+func configureFraming(conn Connection, config *NetworkConfig) {
+	if c, ok := conn.(FramingConfigurable); ok && config.Framing != FramingSNGO {
+		c.SetFraming(config.Framing)
+	}
+}
+
+// EncodeSkynetPacket prefixes data with its 2-byte big-endian length
+func EncodeSkynetPacket(data []byte) ([]byte, error) {
+	if len(data) > SkynetMaxPacketSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrSkynetPacketTooLarge, len(data))
+	}
+	packet := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(packet, uint16(len(data)))
+	copy(packet[2:], data)
+	return packet, nil
+}
+
+// WriteSkynetPacket writes data as one skynet packet
+func WriteSkynetPacket(w io.Writer, data []byte) error {
+	packet, err := EncodeSkynetPacket(data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(packet)
+	return err
+}
+
+// ReadSkynetPacket reads the payload of one skynet packet
+func ReadSkynetPacket(r io.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// encodeSkynetFrame encodes msg in a skynet framing, returning nil for
+// messages the format has no place for, such as heartbeats
+func encodeSkynetFrame(framing Framing, msg *Message) ([]byte, error) {
+	if framing == FramingSkynetGate {
+		if msg.Type < MessageTypeUserStart {
+			return nil, nil
+		}
+		return EncodeSkynetPacket(msg.Data)
+	}
+
+	var ok byte = 1
+	switch {
+	case msg.Type == MessageTypeError:
+		ok = 0
+	case msg.Type < MessageTypeUserStart:
+		return nil, nil
+	}
+	data := make([]byte, len(msg.Data), len(msg.Data)+1+skynetSessionSize)
+	copy(data, msg.Data)
+	data = append(data, ok)
+	data = binary.BigEndian.AppendUint32(data, uint32(msg.SessionID))
+	return EncodeSkynetPacket(data)
+}
+
+// decodeSkynetFrame turns the payload of a skynet packet into a message
+func decodeSkynetFrame(framing Framing, data []byte) (*Message, error) {
+	msg := NewMessage(MessageTypeData, data)
+	if framing == FramingSkynetMsgServer {
+		if len(data) < skynetSessionSize {
+			return nil, fmt.Errorf("skynet request of %d bytes has no session", len(data))
+		}
+		split := len(data) - skynetSessionSize
+		msg.SessionID = uint64(binary.BigEndian.Uint32(data[split:]))
+		msg.Data = data[:split]
+	}
+	return msg, nil
+}
+
+// SkynetUser is the identity a skynet msgserver client presents, handed
+// out by the login server
+type SkynetUser struct {
+	UID    string
+	Server string
+	SubID  string
+}
+
+// String returns the username of the handshake:
+// base64(uid)@base64(server)#base64(subid)
+func (u SkynetUser) String() string {
+	return crypt.Base64Encode([]byte(u.UID)) + "@" +
+		crypt.Base64Encode([]byte(u.Server)) + "#" +
+		crypt.Base64Encode([]byte(u.SubID))
+}
+
+// ParseSkynetUser parses a handshake username
+func ParseSkynetUser(username string) (SkynetUser, error) {
+	uid, rest, ok1 := strings.Cut(username, "@")
+	server, subid, ok2 := strings.Cut(rest, "#")
+	if !ok1 || !ok2 {
+		return SkynetUser{}, fmt.Errorf("invalid skynet username %q", username)
+	}
+
+	var fields [3][]byte
+	for i, s := range []string{uid, server, subid} {
+		field, err := crypt.Base64Decode(s)
+		if err != nil {
+			return SkynetUser{}, fmt.Errorf("invalid skynet username %q: %w", username, err)
+		}
+		fields[i] = field
+	}
+	return SkynetUser{UID: string(fields[0]), Server: string(fields[1]), SubID: string(fields[2])}, nil
+}
+
+// SkynetHMAC signs a msgserver handshake exactly as skynet's
+// crypt.hmac_hash does: crypt.hmac64(crypt.hashkey("username:index"),
+// secret). secret is the 8-byte key the login server handed the client;
+// any other length yields nil, which matches no signature.
+func SkynetHMAC(secret []byte, user SkynetUser, index uint64) []byte {
+	if len(secret) != 8 {
+		return nil
+	}
+	return crypt.SkynetHMAC64(crypt.SkynetHashKey(fmt.Sprintf("%s:%d", user, index)), secret)
+}
+
+// SkynetAuthFunc checks the handshake of a skynet msgserver client. It
+// looks up the user, checks the index is newer than the last one used and
+// verifies hmac, e.g. against SkynetHMAC. It refuses the client with
+// RejectHandshake(HandshakeStatusNotFound, ...), HandshakeStatusForbidden
+// for an expired index, or any other error for 401 Unauthorized.
+type SkynetAuthFunc func(user SkynetUser, index uint64, hmac []byte) error
+
+// SkynetAuthenticatable is implemented by servers accepting skynet
+// msgserver clients
+type SkynetAuthenticatable interface {
+	// SetSkynetAuth sets the check of msgserver handshakes
+	SetSkynetAuth(auth SkynetAuthFunc)
+}
+
+// AcceptSkynetHandshake runs the server side of a skynet msgserver
+// handshake on conn, replying with the status skynet clients expect. It
+// returns the authenticated user or a HandshakeError.
+func AcceptSkynetHandshake(conn net.Conn, timeout time.Duration, auth SkynetAuthFunc) (SkynetUser, error) {
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	line, err := ReadSkynetPacket(conn)
+	if err != nil {
+		return SkynetUser{}, &HandshakeError{Stage: HandshakeStageResume, Err: err}
+	}
+
+	user, index, hmac, err := parseSkynetHandshake(string(line))
+	if err != nil {
+		return SkynetUser{}, replySkynetStatus(conn, HandshakeStatusBadRequest, err)
+	}
+	if auth == nil {
+		return SkynetUser{}, replySkynetStatus(conn, HandshakeStatusUnauthorized, errors.New("no skynet auth configured"))
+	}
+	if err := auth(user, index, hmac); err != nil {
+		code := HandshakeStatusUnauthorized
+		var hsErr *HandshakeError
+		if errors.As(err, &hsErr) && hsErr.Code != 0 {
+			code = hsErr.Code
+		}
+		return SkynetUser{}, replySkynetStatus(conn, code, err)
+	}
+
+	if err := writeSkynetStatus(conn, HandshakeStatusOK); err != nil {
+		return SkynetUser{}, &HandshakeError{Stage: HandshakeStageResume, Err: err}
+	}
+	return user, nil
+}
+
+// SkynetHandshake runs the client side of a skynet msgserver handshake,
+// returning a HandshakeError carrying the code if the server refuses
+func SkynetHandshake(conn net.Conn, user SkynetUser, index uint64, secret []byte) error {
+	hmac := SkynetHMAC(secret, user, index)
+	line := fmt.Sprintf("%s:%d:%s", user, index, crypt.Base64Encode(hmac))
+	if err := WriteSkynetPacket(conn, []byte(line)); err != nil {
+		return &HandshakeError{Stage: HandshakeStageResume, Err: err}
+	}
+
+	reply, err := ReadSkynetPacket(conn)
+	if err != nil {
+		return &HandshakeError{Stage: HandshakeStageResume, Err: err}
+	}
+	codeStr, message, _ := strings.Cut(string(reply), " ")
+	code, err := strconv.Atoi(codeStr)
+	if err != nil {
+		return &HandshakeError{Stage: HandshakeStageResume, Err: fmt.Errorf("invalid status %q", reply)}
+	}
+	if code != HandshakeStatusOK {
+		return &HandshakeError{Stage: HandshakeStageResume, Code: code, Message: message}
+	}
+	return nil
+}
+
+// parseSkynetHandshake parses "username:index:base64(hmac)"
+func parseSkynetHandshake(line string) (SkynetUser, uint64, []byte, error) {
+	parts := strings.Split(line, ":")
+	if len(parts) != 3 {
+		return SkynetUser{}, 0, nil, fmt.Errorf("invalid skynet handshake %q", line)
+	}
+
+	user, err := ParseSkynetUser(parts[0])
+	if err != nil {
+		return SkynetUser{}, 0, nil, err
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return SkynetUser{}, 0, nil, fmt.Errorf("invalid skynet handshake index: %w", err)
+	}
+	hmac, err := crypt.Base64Decode(parts[2])
+	if err != nil {
+		return SkynetUser{}, 0, nil, fmt.Errorf("invalid skynet handshake hmac: %w", err)
+	}
+	return user, index, hmac, nil
+}
+
+// writeSkynetStatus writes a "<code> <text>" status packet
+func writeSkynetStatus(conn net.Conn, code int) error {
+	return WriteSkynetPacket(conn, []byte(fmt.Sprintf("%d %s", code, skynetStatusText[code])))
+}
+
+// replySkynetStatus refuses a handshake with code and returns the error
+func replySkynetStatus(conn net.Conn, code int, err error) error {
+	writeSkynetStatus(conn, code)
+	return &HandshakeError{Stage: HandshakeStageResume, Code: code, Message: skynetStatusText[code], Err: err}
+}
@@ -0,0 +1,167 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/najoast/sngo/crypt"
+)
+
+// startSkynetServer starts a server with framing that echoes user
+// messages, failing requests whose payload is "fail"
+func startSkynetServer(t *testing.T, port int, framing Framing, auth SkynetAuthFunc) Server {
+	t.Helper()
+	config := DefaultNetworkConfig()
+	config.Port = port
+	config.Framing = framing
+
+	server, err := NewTCPServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	server.SetMessageHandler(&testMessageHandler{
+		onMessage: func(conn Connection, msg *Message) {
+			reply := NewMessage(MessageTypeData, append([]byte("echo: "), msg.Data...))
+			if string(msg.Data) == "fail" {
+				reply = NewErrorMessage("failed")
+			}
+			if user, ok := conn.GetUserData().(SkynetUser); ok {
+				reply.Data = append(reply.Data, " "+user.UID...)
+			}
+			reply.SessionID = msg.SessionID
+			conn.SendMessage(reply)
+		},
+	})
+	if auth != nil {
+		server.(SkynetAuthenticatable).SetSkynetAuth(auth)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+	return server
+}
+
+func dialSkynet(t *testing.T, server Server) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listen().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestSkynetPacket(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSkynetPacket(&buf, []byte("hello")); err != nil {
+		t.Fatalf("Failed to write packet: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{0, 5, 'h', 'e', 'l', 'l', 'o'}) {
+		t.Errorf("Unexpected packet % x", buf.Bytes())
+	}
+	data, err := ReadSkynetPacket(&buf)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Expected hello, got %q (%v)", data, err)
+	}
+
+	if _, err := EncodeSkynetPacket(make([]byte, SkynetMaxPacketSize+1)); !errors.Is(err, ErrSkynetPacketTooLarge) {
+		t.Errorf("Expected ErrSkynetPacketTooLarge, got %v", err)
+	}
+}
+
+func TestSkynetGateFraming(t *testing.T) {
+	server := startSkynetServer(t, 18087, FramingSkynetGate, nil)
+	conn := dialSkynet(t, server)
+
+	if err := WriteSkynetPacket(conn, []byte("hi")); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	reply, err := ReadSkynetPacket(conn)
+	if err != nil || string(reply) != "echo: hi" {
+		t.Errorf("Expected a bare echo packet, got %q (%v)", reply, err)
+	}
+}
+
+func TestSkynetHMAC(t *testing.T) {
+	// Signature a skynet client computes for this handshake, see the
+	// vectors of crypt.SkynetHMAC64
+	user := SkynetUser{UID: "alice", Server: "game", SubID: "2"}
+	if got := crypt.HexEncode(SkynetHMAC([]byte("secret!!"), user, 42)); got != "bbc6ca0585c6f76b" {
+		t.Errorf("Unexpected signature %s", got)
+	}
+	if SkynetHMAC([]byte("short"), user, 42) != nil {
+		t.Error("Expected no signature for a secret of the wrong length")
+	}
+}
+
+func TestSkynetMsgServer(t *testing.T) {
+	secret := []byte("12345678")
+	lastIndex := uint64(1)
+	auth := func(user SkynetUser, index uint64, hmac []byte) error {
+		switch {
+		case user.UID != "alice":
+			return RejectHandshake(HandshakeStatusNotFound, "no such user")
+		case index <= lastIndex:
+			return RejectHandshake(HandshakeStatusForbidden, "index expired")
+		case !bytes.Equal(hmac, SkynetHMAC(secret, user, index)):
+			return errors.New("bad signature")
+		}
+		lastIndex = index
+		return nil
+	}
+	server := startSkynetServer(t, 18088, FramingSkynetMsgServer, auth)
+	alice := SkynetUser{UID: "alice", Server: "game1", SubID: "7"}
+
+	refusals := []struct {
+		user   SkynetUser
+		index  uint64
+		secret []byte
+		code   int
+	}{
+		{SkynetUser{UID: "bob", Server: "game1", SubID: "1"}, 2, secret, HandshakeStatusNotFound},
+		{alice, 1, secret, HandshakeStatusForbidden},
+		{alice, 2, []byte("87654321"), HandshakeStatusUnauthorized},
+	}
+	for _, r := range refusals {
+		var hsErr *HandshakeError
+		err := SkynetHandshake(dialSkynet(t, server), r.user, r.index, r.secret)
+		if !errors.As(err, &hsErr) || hsErr.Code != r.code {
+			t.Errorf("Expected status %d for %+v, got %v", r.code, r, err)
+		}
+	}
+
+	conn := dialSkynet(t, server)
+	if err := SkynetHandshake(conn, alice, 2, secret); err != nil {
+		t.Fatalf("Failed to handshake: %v", err)
+	}
+
+	for _, req := range []struct {
+		data    string
+		session uint32
+		ok      byte
+		reply   string
+	}{
+		{"ping", 5, 1, "echo: ping alice"},
+		{"fail", 6, 0, "failed alice"},
+	} {
+		packet := binary.BigEndian.AppendUint32([]byte(req.data), req.session)
+		if err := WriteSkynetPacket(conn, packet); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+
+		resp, err := ReadSkynetPacket(conn)
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		n := len(resp) - 5
+		if n < 0 || string(resp[:n]) != req.reply || resp[n] != req.ok || binary.BigEndian.Uint32(resp[n+1:]) != req.session {
+			t.Errorf("Unexpected response % x to %q", resp, req.data)
+		}
+	}
+}
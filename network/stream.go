@@ -0,0 +1,380 @@
+// Package network provides byte streams over message connections
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// MaxStreamChunk is the largest payload a Stream sends per message
+const MaxStreamChunk = 32 * 1024
+
+// DefaultStreamBuffer is how many unread bytes a Stream buffers before the
+// connection stops reading
+const DefaultStreamBuffer = 1024 * 1024
+
+// ErrStreamExists is returned when opening a channel already in use
+var ErrStreamExists = errors.New("stream channel already open")
+
+// streamKey identifies a stream by connection and channel
+type streamKey struct {
+	conn    string
+	channel uint64
+}
+
+// StreamMux is a MessageHandler carrying byte streams over connections, so
+// libraries that want a plain stream (RPC stacks, SSH-like tools) can be
+// layered on SNGO transports. Each stream is a channel of a connection,
+// carried in the SessionID of MessageTypeStream messages; other messages
+// are passed to the next handler. A stream ends with an empty message.
+//
+// A stream whose reader falls behind by more than its buffer holds up the
+// connection until it catches up or is closed, pushing back on the sender.
+type StreamMux struct {
+	next MessageHandler
+
+	// BufferSize is the unread bytes buffered per stream
+	BufferSize int
+
+	mu      sync.Mutex
+	streams map[streamKey]*Stream
+	accept  chan *Stream
+}
+
+// NewStreamMux creates a stream multiplexer passing other messages to next,
+// which may be nil
+func NewStreamMux(next MessageHandler) *StreamMux {
+	return &StreamMux{
+		next:       next,
+		BufferSize: DefaultStreamBuffer,
+		streams:    make(map[streamKey]*Stream),
+		accept:     make(chan *Stream, 16),
+	}
+}
+
+// Open opens a stream on a channel of conn; the peer accepts it when the
+// first bytes arrive
+func (m *StreamMux) Open(conn Connection, channel uint64) (*Stream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := streamKey{conn: conn.ID(), channel: channel}
+	if _, exists := m.streams[key]; exists {
+		return nil, fmt.Errorf("channel %d of %s: %w", channel, conn.ID(), ErrStreamExists)
+	}
+	stream := newStream(m, conn, channel)
+	m.streams[key] = stream
+	return stream, nil
+}
+
+// Accept waits for a stream opened by a peer
+func (m *StreamMux) Accept(ctx context.Context) (*Stream, error) {
+	select {
+	case stream := <-m.accept:
+		return stream, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// OnMessage implements MessageHandler
+func (m *StreamMux) OnMessage(conn Connection, msg *Message) {
+	if msg.Type != MessageTypeStream {
+		if m.next != nil {
+			m.next.OnMessage(conn, msg)
+		}
+		return
+	}
+
+	key := streamKey{conn: conn.ID(), channel: msg.SessionID}
+	m.mu.Lock()
+	stream, exists := m.streams[key]
+	if !exists {
+		if len(msg.Data) == 0 {
+			m.mu.Unlock()
+			return // end of a stream we already closed
+		}
+		stream = newStream(m, conn, msg.SessionID)
+		m.streams[key] = stream
+	}
+	m.mu.Unlock()
+
+	if !exists {
+		select {
+		case m.accept <- stream:
+		default:
+			// Nobody accepts streams; refuse it
+			stream.Close()
+			return
+		}
+	}
+	stream.deliver(msg.Data)
+}
+
+// OnError implements MessageHandler, ending the streams of a connection
+// that failed
+func (m *StreamMux) OnError(conn Connection, err error) {
+	if m.next != nil {
+		m.next.OnError(conn, err)
+	}
+	if conn.State() == ConnectionStateConnected {
+		return // e.g. a corrupt frame that was skipped
+	}
+
+	m.mu.Lock()
+	var streams []*Stream
+	for key, stream := range m.streams {
+		if key.conn == conn.ID() {
+			streams = append(streams, stream)
+			delete(m.streams, key)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, stream := range streams {
+		stream.fail(io.ErrUnexpectedEOF)
+	}
+}
+
+// remove forgets a closed stream
+func (m *StreamMux) remove(s *Stream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := streamKey{conn: s.conn.ID(), channel: s.channel}
+	if m.streams[key] == s {
+		delete(m.streams, key)
+	}
+}
+
+// Stream is a byte stream over a channel of a Connection. It implements
+// net.Conn, including deadlines, so it can be handed to libraries
+// expecting an io.ReadWriteCloser or a net.Conn.
+type Stream struct {
+	mux     *StreamMux
+	conn    Connection
+	channel uint64
+
+	mu       sync.Mutex
+	chunks   [][]byte
+	buffered int
+	eof      bool
+	err      error
+	closed   bool
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	// signal wakes readers and delivery waiting for room
+	signal chan struct{}
+}
+
+// newStream creates a stream on a channel of conn
+func newStream(mux *StreamMux, conn Connection, channel uint64) *Stream {
+	return &Stream{mux: mux, conn: conn, channel: channel, signal: make(chan struct{}, 1)}
+}
+
+// Channel returns the channel of the stream
+func (s *Stream) Channel() uint64 {
+	return s.channel
+}
+
+// Read reads stream bytes, returning io.EOF once the peer closed the
+// stream and os.ErrDeadlineExceeded after the read deadline
+func (s *Stream) Read(p []byte) (int, error) {
+	for {
+		s.mu.Lock()
+		if len(s.chunks) > 0 {
+			n := copy(p, s.chunks[0])
+			if n == len(s.chunks[0]) {
+				s.chunks = s.chunks[1:]
+			} else {
+				s.chunks[0] = s.chunks[0][n:]
+			}
+			s.buffered -= n
+			s.mu.Unlock()
+			s.wake()
+			return n, nil
+		}
+		switch {
+		case s.closed:
+			s.mu.Unlock()
+			return 0, io.ErrClosedPipe
+		case s.err != nil:
+			err := s.err
+			s.mu.Unlock()
+			return 0, err
+		case s.eof:
+			s.mu.Unlock()
+			return 0, io.EOF
+		}
+		deadline := s.readDeadline
+		s.mu.Unlock()
+
+		if err := s.wait(deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Write sends p as one or more stream messages. The write deadline is
+// checked before sending; sends are buffered by the connection.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	closed, err, deadline := s.closed, s.err, s.writeDeadline
+	s.mu.Unlock()
+
+	switch {
+	case closed:
+		return 0, io.ErrClosedPipe
+	case err != nil:
+		return 0, err
+	case !deadline.IsZero() && !time.Now().Before(deadline):
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	written := 0
+	for written < len(p) {
+		end := written + MaxStreamChunk
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := s.send(append([]byte(nil), p[written:end]...)); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// Close ends the stream; the peer reads io.EOF
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	sendEOF := s.err == nil
+	s.mu.Unlock()
+
+	s.mux.remove(s)
+	s.wake()
+	if sendEOF {
+		return s.send(nil)
+	}
+	return nil
+}
+
+// LocalAddr returns the local address of the connection
+func (s *Stream) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the connection
+func (s *Stream) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines
+func (s *Stream) SetDeadline(t time.Time) error {
+	s.SetReadDeadline(t)
+	return s.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the deadline of pending and future reads; the zero
+// time means none
+func (s *Stream) SetReadDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.readDeadline = t
+	s.mu.Unlock()
+	s.wake()
+	return nil
+}
+
+// SetWriteDeadline sets the deadline of future writes; the zero time means
+// none
+func (s *Stream) SetWriteDeadline(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeDeadline = t
+	return nil
+}
+
+// send sends one stream message
+func (s *Stream) send(data []byte) error {
+	msg := NewMessage(MessageTypeStream, data)
+	msg.SessionID = s.channel
+	return s.conn.SendMessage(msg)
+}
+
+// deliver buffers bytes received from the peer, an empty chunk being the
+// end of the stream, waiting while the buffer is full
+func (s *Stream) deliver(data []byte) {
+	for {
+		s.mu.Lock()
+		if s.closed || s.err != nil {
+			s.mu.Unlock()
+			return
+		}
+		if len(data) == 0 {
+			s.eof = true
+			s.mu.Unlock()
+			s.wake()
+			return
+		}
+		if s.buffered == 0 || s.buffered+len(data) <= s.mux.BufferSize {
+			s.chunks = append(s.chunks, data)
+			s.buffered += len(data)
+			s.mu.Unlock()
+			s.wake()
+			return
+		}
+		s.mu.Unlock()
+		s.wait(time.Time{})
+	}
+}
+
+// fail ends the stream with err
+func (s *Stream) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.wake()
+}
+
+// wait blocks until the stream changes or deadline passes
+func (s *Stream) wait(deadline time.Time) error {
+	if deadline.IsZero() {
+		<-s.signal
+		return nil
+	}
+
+	d := time.Until(deadline)
+	if d <= 0 {
+		return os.ErrDeadlineExceeded
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-s.signal:
+		return nil
+	case <-timer.C:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+// wake signals a change to a waiting reader or delivery
+func (s *Stream) wake() {
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
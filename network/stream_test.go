@@ -0,0 +1,110 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// pumpStreams feeds the messages read from conn to mux
+func pumpStreams(conn Connection, mux *StreamMux) {
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			conn.Close()
+			mux.OnError(conn, err)
+			return
+		}
+		mux.OnMessage(conn, msg)
+	}
+}
+
+func streamPair(t *testing.T) (local *Stream, remoteMux *StreamMux) {
+	t.Helper()
+	a, b := NewPipeConnectionPair()
+	t.Cleanup(func() { a.Close(); b.Close() })
+
+	localMux := NewStreamMux(nil)
+	remoteMux = NewStreamMux(nil)
+	go pumpStreams(a, localMux)
+	go pumpStreams(b, remoteMux)
+
+	local, err := localMux.Open(a, 7)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	if _, err := localMux.Open(a, 7); !errors.Is(err, ErrStreamExists) {
+		t.Errorf("Expected ErrStreamExists, got %v", err)
+	}
+	return local, remoteMux
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	local, remoteMux := streamPair(t)
+	var _ net.Conn = local
+
+	payload := bytes.Repeat([]byte("0123456789"), MaxStreamChunk/4)
+	go func() {
+		local.Write(payload)
+		local.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	remote, err := remoteMux.Accept(ctx)
+	if err != nil {
+		t.Fatalf("Failed to accept stream: %v", err)
+	}
+	if remote.Channel() != 7 {
+		t.Errorf("Expected channel 7, got %d", remote.Channel())
+	}
+
+	got, err := io.ReadAll(remote)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("Expected %d bytes back, got %d", len(payload), len(got))
+	}
+	if _, err := local.Write([]byte("late")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Expected io.ErrClosedPipe writing a closed stream, got %v", err)
+	}
+}
+
+func TestStreamDeadlines(t *testing.T) {
+	local, _ := streamPair(t)
+
+	local.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	start := time.Now()
+	if _, err := local.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected os.ErrDeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Read deadline fired late, after %v", elapsed)
+	}
+
+	local.SetWriteDeadline(time.Now().Add(-time.Second))
+	if _, err := local.Write([]byte("x")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected os.ErrDeadlineExceeded writing past the deadline, got %v", err)
+	}
+}
+
+func TestStreamEndsWithConnection(t *testing.T) {
+	a, b := NewPipeConnectionPair()
+	defer b.Close()
+
+	mux := NewStreamMux(nil)
+	stream, _ := mux.Open(a, 1)
+	go pumpStreams(a, mux)
+	b.Close()
+
+	stream.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := stream.Read(make([]byte, 1)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF after the connection dropped, got %v", err)
+	}
+}
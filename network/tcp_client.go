@@ -8,6 +8,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/najoast/sngo/core"
 )
 
 // tcpClient implements the Client interface for TCP
@@ -38,11 +40,19 @@ type tcpClient struct {
 	// Target address
 	targetAddress string
 
+	// Heartbeats and dead peer detection, started with the first connection
+	liveness     *livenessMonitor
+	livenessOnce sync.Once
+
 	// Statistics
 	connectAttempts    int64
 	successfulConnects int64
 	totalMessages      int64
 	startTime          time.Time
+	usage              *ProtocolUsage
+
+	// dial opens the raw connection; replaced by in-memory clients
+	dial func(network, address string, timeout time.Duration) (net.Conn, error)
 }
 
 // NewTCPClient creates a new TCP client
@@ -56,18 +66,23 @@ func NewTCPClient(config *NetworkConfig) (Client, error) {
 		return nil, fmt.Errorf("invalid protocol for TCP client: %s", config.Protocol)
 	}
 
+	return newStreamClient(config), nil
+}
+
+// newStreamClient creates a client for a stream protocol (tcp or unix)
+func newStreamClient(config *NetworkConfig) *tcpClient {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	client := &tcpClient{
+	return &tcpClient{
 		config:               config,
 		ctx:                  ctx,
 		cancel:               cancel,
 		reconnectInterval:    config.ReconnectInterval,
 		maxReconnectAttempts: config.MaxReconnectAttempts,
 		startTime:            time.Now(),
+		usage:                NewProtocolUsage(),
+		dial:                 dialTCP,
 	}
-
-	return client, nil
 }
 
 // Connect connects to the remote server
@@ -89,24 +104,14 @@ func (tc *tcpClient) ConnectWithTimeout(address string, timeout time.Duration) (
 	// Increment attempt counter
 	atomic.AddInt64(&tc.connectAttempts, 1)
 
-	// Create dialer with timeout
-	dialer := &net.Dialer{
-		Timeout: timeout,
-	}
-
 	// Connect to remote server
-	conn, err := dialer.Dial(string(tc.config.Protocol), address)
+	conn, err := tc.dial(string(tc.config.Protocol), address, timeout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
 	}
 
-	// Configure TCP connection
-	if tcpConn, ok := conn.(*net.TCPConn); ok {
-		if tc.config.KeepAlive {
-			tcpConn.SetKeepAlive(true)
-			tcpConn.SetKeepAlivePeriod(tc.config.KeepAliveInterval)
-		}
-	}
+	// Configure TCP keep-alive
+	tc.config.LivenessPolicy().ApplyKeepAlive(conn)
 
 	// Create connection wrapper
 	connection := NewTCPConnection(conn)
@@ -114,6 +119,11 @@ func (tc *tcpClient) ConnectWithTimeout(address string, timeout time.Duration) (
 	// Configure timeouts
 	connection.SetReadTimeout(tc.config.ReadTimeout)
 	connection.SetWriteTimeout(tc.config.WriteTimeout)
+	configureFraming(connection, tc.config)
+	configureChecksum(connection, tc.config)
+	configureTimestamps(connection, tc.config)
+	configureCoalescing(connection, tc.config)
+	configureUsage(connection, tc.usage)
 
 	// Update state
 	tc.mu.Lock()
@@ -129,16 +139,51 @@ func (tc *tcpClient) ConnectWithTimeout(address string, timeout time.Duration) (
 		go tc.messageLoop()
 	}
 
+	// Closing a dead server connection lets auto-reconnect replace it
+	tc.livenessOnce.Do(func() {
+		monitor := startLivenessMonitor(tc.ctx, tc.config.LivenessPolicy(), tc.activeConnections, func(dead Connection) {
+			if tc.GetConnection() == dead {
+				atomic.StoreInt32(&tc.connected, 0)
+			}
+		})
+
+		tc.mu.Lock()
+		tc.liveness = monitor
+		tc.mu.Unlock()
+	})
+
 	// Start auto-reconnect monitoring
 	if tc.autoReconnect {
 		tc.wg.Add(1)
 		go tc.reconnectLoop()
 	}
 
-	fmt.Printf("TCP client connected to %s\n", address)
+	fmt.Printf("%s client connected to %s\n", protocolName(tc.config.Protocol), address)
 	return connection, nil
 }
 
+// configureChecksum applies the frame checksum settings to a connection
+func configureChecksum(conn Connection, config *NetworkConfig) {
+	if c, ok := conn.(ChecksumConfigurable); ok {
+		c.SetChecksum(config.FrameChecksum)
+	}
+}
+
+// configureTimestamps applies the frame timestamp settings to a connection
+func configureTimestamps(conn Connection, config *NetworkConfig) {
+	if c, ok := conn.(LatencyTrackable); ok {
+		c.SetTimestamps(config.FrameTimestamps, config.ClockSyncInterval)
+	}
+}
+
+// dialTCP dials a real network connection with a timeout
+func dialTCP(network, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+	return dialer.Dial(network, address)
+}
+
 // ConnectAsync connects asynchronously
 func (tc *tcpClient) ConnectAsync(address string) <-chan ConnectionResult {
 	resultChan := make(chan ConnectionResult, 1)
@@ -176,7 +221,7 @@ func (tc *tcpClient) Disconnect() error {
 		// Wait for goroutines to finish
 		tc.wg.Wait()
 
-		fmt.Println("TCP client disconnected")
+		fmt.Printf("%s client disconnected\n", protocolName(tc.config.Protocol))
 		return err
 	}
 
@@ -221,6 +266,7 @@ func (tc *tcpClient) IsConnected() bool {
 func (tc *tcpClient) GetStatistics() ClientStatistics {
 	tc.mu.RLock()
 	targetAddr := tc.targetAddress
+	liveness := tc.liveness
 	tc.mu.RUnlock()
 
 	var connStats ConnectionStatistics
@@ -241,6 +287,8 @@ func (tc *tcpClient) GetStatistics() ClientStatistics {
 		AutoReconnect:      tc.autoReconnect,
 		ReconnectInterval:  tc.reconnectInterval,
 		ConnectionStats:    connStats,
+		DeadPeers:          liveness.DeadPeers(),
+		Usage:              tc.usage.Snapshot(),
 	}
 }
 
@@ -261,6 +309,14 @@ func (tc *tcpClient) SendMessage(msg *Message) error {
 
 // Private methods
 
+// activeConnections returns the current connection, if any
+func (tc *tcpClient) activeConnections() []Connection {
+	if conn := tc.GetConnection(); conn != nil && tc.IsConnected() {
+		return []Connection{conn}
+	}
+	return nil
+}
+
 // messageLoop handles incoming messages
 func (tc *tcpClient) messageLoop() {
 	defer tc.wg.Done()
@@ -290,7 +346,6 @@ func (tc *tcpClient) messageLoop() {
 			if tc.msgHandler != nil {
 				tc.msgHandler.OnError(conn, err)
 			}
-
 			// Mark as disconnected
 			atomic.StoreInt32(&tc.connected, 0)
 			return
@@ -343,18 +398,20 @@ func (tc *tcpClient) attemptReconnect() {
 
 	// Check reconnect attempts limit
 	if tc.maxReconnectAttempts > 0 && tc.currentAttempt >= tc.maxReconnectAttempts {
-		fmt.Printf("Max reconnect attempts (%d) reached for %s\n", tc.maxReconnectAttempts, targetAddr)
+		core.ReportError("network", "reconnect", core.SeverityError,
+			fmt.Errorf("max reconnect attempts (%d) reached for %s", tc.maxReconnectAttempts, targetAddr))
 		return
 	}
 
 	tc.currentAttempt++
-	fmt.Printf("Attempting to reconnect to %s (attempt %d)\n", targetAddr, tc.currentAttempt)
 
 	_, err := tc.ConnectWithTimeout(targetAddr, 10*time.Second)
 	if err != nil {
-		fmt.Printf("Reconnect attempt %d failed: %v\n", tc.currentAttempt, err)
+		core.ReportError("network", "reconnect", core.SeverityWarning,
+			fmt.Errorf("reconnect attempt %d to %s failed: %w", tc.currentAttempt, targetAddr, err))
 	} else {
-		fmt.Printf("Reconnected successfully to %s\n", targetAddr)
+		core.ReportError("network", "reconnect", core.SeverityWarning,
+			fmt.Errorf("connection to %s lost and restored after %d attempts", targetAddr, tc.currentAttempt))
 		tc.currentAttempt = 0 // Reset attempt counter on success
 	}
 }
@@ -372,6 +429,10 @@ type ClientStatistics struct {
 	AutoReconnect      bool                 `json:"auto_reconnect"`
 	ReconnectInterval  time.Duration        `json:"reconnect_interval"`
 	ConnectionStats    ConnectionStatistics `json:"connection_stats"`
+	DeadPeers          int64                `json:"dead_peers"`
+
+	// Usage is the traffic per message type, most bytes first
+	Usage []MessageTypeUsage `json:"usage,omitempty"`
 }
 
 // String returns the string representation of client statistics
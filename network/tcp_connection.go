@@ -2,23 +2,28 @@
 package network
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/najoast/sngo/core"
 )
 
 // tcpConnection implements the Connection interface for TCP connections
 type tcpConnection struct {
-	id           string
-	conn         net.Conn
-	state        int32 // ConnectionState as atomic int32
-	userData     interface{}
-	readTimeout  time.Duration
-	writeTimeout time.Duration
-	lastActivity int64 // Unix timestamp as atomic int64
-	codec        MessageCodec
+	id            string
+	conn          net.Conn
+	state         int32 // ConnectionState as atomic int32
+	userData      interface{}
+	readTimeout   time.Duration
+	writeTimeout  time.Duration
+	lastActivity  int64 // Unix timestamp as atomic int64
+	lastRead      int64 // UnixNano of the last frame read, atomic
+	lastHeartbeat int64 // UnixNano of the last heartbeat read, atomic
+	codec         MessageCodec
 
 	// Synchronization
 	mu       sync.RWMutex
@@ -30,6 +35,28 @@ type tcpConnection struct {
 	bytesWritten int64
 	messagesRead int64
 	messagesSent int64
+
+	// Frame checksums
+	checksum      int32 // atomic flag, checksums required both ways
+	corruptFrames int64
+
+	// Frame timestamps
+	timestamps int32 // atomic flag, stamp outgoing frames with their send time
+	latency    *LatencyTracker
+
+	// Write coalescing
+	coalescing    atomic.Value // CoalescingPolicy
+	framesWritten int64
+	writes        int64
+
+	// Wire format, set before the connection is used
+	framing Framing
+
+	// Per message type counters, set before the connection is used
+	usage *ProtocolUsage
+
+	// Simulated network conditions, set at runtime
+	shaper atomic.Pointer[shaper]
 }
 
 // connectionIDCounter generates unique connection IDs
@@ -46,8 +73,10 @@ func NewTCPConnection(conn net.Conn) Connection {
 		readTimeout:  30 * time.Second,
 		writeTimeout: 30 * time.Second,
 		lastActivity: time.Now().Unix(),
+		lastRead:     time.Now().UnixNano(),
 		codec:        NewBinaryMessageCodec(),
 		sendChan:     make(chan []byte, 256), // Buffered channel for async sends
+		latency:      NewLatencyTracker(0, time.Minute, 60),
 	}
 
 	// Start the send goroutine
@@ -87,6 +116,10 @@ func (tc *tcpConnection) Send(data []byte) error {
 		return nil
 	}
 
+	if sh := tc.shaper.Load(); sh != nil {
+		return sh.send(data)
+	}
+
 	// Try to send through buffered channel (non-blocking)
 	select {
 	case tc.sendChan <- data:
@@ -107,19 +140,40 @@ func (tc *tcpConnection) SendMessage(msg *Message) error {
 		return fmt.Errorf("message is nil")
 	}
 
-	// Set connection ID
-	msg.ConnectionID = tc.id
+	// Encode from a copy: the caller's message may be shared, e.g. by a
+	// broadcast
+	frame := *msg
+	frame.ConnectionID = tc.id
+	if atomic.LoadInt32(&tc.checksum) != 0 {
+		frame.SetFlag(MessageFlagChecksum)
+	}
+	if atomic.LoadInt32(&tc.timestamps) != 0 {
+		frame.SetFlag(MessageFlagTimestamp)
+	}
+	if frame.HasFlag(MessageFlagTimestamp) {
+		frame.SentAt = time.Now()
+		if frame.Type != MessageTypeTimeSync && tc.latency.syncDue(frame.SentAt) {
+			tc.SendMessage(NewTimeSyncRequest())
+		}
+	}
+	msg = &frame
 
 	// Encode message
-	data, err := tc.codec.Encode(msg)
+	data, err := tc.encode(msg)
 	if err != nil {
 		return fmt.Errorf("failed to encode message: %w", err)
 	}
+	if data == nil {
+		return nil // Not representable in the framing
+	}
 
 	// Send encoded data
 	err = tc.Send(data)
 	if err == nil {
 		atomic.AddInt64(&tc.messagesSent, 1)
+		if tc.usage != nil {
+			tc.usage.RecordOutbound(msg.Type, len(data))
+		}
 	}
 
 	return err
@@ -136,6 +190,9 @@ func (tc *tcpConnection) Close() error {
 
 	// Close send channel
 	close(tc.sendChan)
+	if sh := tc.shaper.Load(); sh != nil {
+		sh.close()
+	}
 
 	// Close underlying connection
 	if tc.conn != nil {
@@ -184,8 +241,52 @@ func (tc *tcpConnection) SetUserData(data interface{}) {
 	tc.userData = data
 }
 
-// ReadMessage reads a message from the connection
+// ReadMessage reads a message from the connection; time sync frames are
+// answered or fed to the latency tracker and never returned
 func (tc *tcpConnection) ReadMessage() (*Message, error) {
+	for {
+		msg, err := tc.nextFrame()
+		if err != nil {
+			return nil, err
+		}
+		if msg.Type != MessageTypeTimeSync {
+			return msg, nil
+		}
+
+		reply, err := handleTimeSync(tc.latency, msg, time.Now())
+		if err != nil {
+			return nil, tc.corruptFrame(err)
+		}
+		if reply != nil {
+			if err := tc.SendMessage(reply); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// nextFrame returns the next frame, held back by the shaper if the
+// connection is shaped
+func (tc *tcpConnection) nextFrame() (*Message, error) {
+	readFrame := func() (*Message, int, error) {
+		before := atomic.LoadInt64(&tc.bytesRead)
+		msg, err := tc.readFrame()
+		return msg, int(atomic.LoadInt64(&tc.bytesRead) - before), err
+	}
+	if sh := tc.shaper.Load(); sh != nil {
+		return sh.read(readFrame)
+	}
+
+	// The connection may get shaped while the read is blocked
+	msg, size, err := readFrame()
+	if sh := tc.shaper.Load(); sh != nil && err == nil {
+		sh.wait(sh.in.schedule(sh.shaping(), size, time.Now()))
+	}
+	return msg, err
+}
+
+// readFrame reads a single frame from the connection
+func (tc *tcpConnection) readFrame() (*Message, error) {
 	if tc.isClosed() {
 		return nil, fmt.Errorf("connection %s is closed", tc.id)
 	}
@@ -202,6 +303,13 @@ func (tc *tcpConnection) ReadMessage() (*Message, error) {
 		}
 	}
 
+	if tc.framing == FramingJSON {
+		return tc.readJSONFrame()
+	}
+	if tc.framing != FramingSNGO {
+		return tc.readSkynetFrame()
+	}
+
 	// Read message header first
 	headerBuf := make([]byte, MessageHeaderSize)
 	_, err := tc.readFull(headerBuf)
@@ -215,6 +323,31 @@ func (tc *tcpConnection) ReadMessage() (*Message, error) {
 		return nil, fmt.Errorf("failed to decode message header: %w", err)
 	}
 
+	// A checksummed connection takes no frame without one: a flipped flag
+	// bit must not skip verification
+	if atomic.LoadInt32(&tc.checksum) != 0 && !header.HasFlag(MessageFlagChecksum) {
+		return nil, tc.corruptFrame(fmt.Errorf("%w: frame carries no checksum", ErrChecksumMismatch))
+	}
+
+	// Read checksum if the frame carries one
+	var checksumBuf []byte
+	if header.HasFlag(MessageFlagChecksum) {
+		checksumBuf = make([]byte, ChecksumSize)
+		if _, err := tc.readFull(checksumBuf); err != nil {
+			return nil, fmt.Errorf("failed to read message checksum: %w", err)
+		}
+	}
+
+	// Read the send timestamp if the frame carries one
+	var timestampBuf []byte
+	if header.HasFlag(MessageFlagTimestamp) {
+		timestampBuf = make([]byte, TimestampSize)
+		if _, err := tc.readFull(timestampBuf); err != nil {
+			return nil, fmt.Errorf("failed to read message timestamp: %w", err)
+		}
+		header.SentAt = frameTimestamp(timestampBuf)
+	}
+
 	// Read message data if any
 	if cap(header.Data) > 0 {
 		dataBuf := make([]byte, cap(header.Data))
@@ -225,9 +358,26 @@ func (tc *tcpConnection) ReadMessage() (*Message, error) {
 		header.Data = dataBuf
 	}
 
+	if checksumBuf != nil {
+		if err := VerifyChecksum(headerBuf, checksumBuf, timestampBuf, header.Data); err != nil {
+			return nil, tc.corruptFrame(err)
+		}
+	}
+
 	// Update statistics and activity
 	atomic.AddInt64(&tc.messagesRead, 1)
+	if tc.usage != nil {
+		tc.usage.RecordInbound(header.Type, header.Size())
+	}
 	tc.updateActivity()
+	now := time.Now()
+	atomic.StoreInt64(&tc.lastRead, now.UnixNano())
+	if header.Type == MessageTypeHeartbeat {
+		atomic.StoreInt64(&tc.lastHeartbeat, now.UnixNano())
+	}
+	if header.HasFlag(MessageFlagTimestamp) {
+		tc.latency.Observe(header.SentAt, time.Now())
+	}
 
 	// Set connection ID
 	header.ConnectionID = tc.id
@@ -235,23 +385,174 @@ func (tc *tcpConnection) ReadMessage() (*Message, error) {
 	return header, nil
 }
 
+// readSkynetFrame reads a single packet of a skynet framing
+func (tc *tcpConnection) readSkynetFrame() (*Message, error) {
+	var size [2]byte
+	if _, err := tc.readFull(size[:]); err != nil {
+		return nil, fmt.Errorf("failed to read packet size: %w", err)
+	}
+	data := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := tc.readFull(data); err != nil {
+		return nil, fmt.Errorf("failed to read packet data: %w", err)
+	}
+
+	msg, err := decodeSkynetFrame(tc.framing, data)
+	if err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt64(&tc.messagesRead, 1)
+	if tc.usage != nil {
+		tc.usage.RecordInbound(msg.Type, len(size)+len(data))
+	}
+	tc.updateActivity()
+	atomic.StoreInt64(&tc.lastRead, time.Now().UnixNano())
+	msg.ConnectionID = tc.id
+	return msg, nil
+}
+
+// encode encodes msg in the framing of the connection
+func (tc *tcpConnection) encode(msg *Message) ([]byte, error) {
+	if tc.framing == FramingJSON {
+		return encodeJSONFrame(msg)
+	}
+	if tc.framing != FramingSNGO {
+		return encodeSkynetFrame(tc.framing, msg)
+	}
+	return tc.codec.Encode(msg)
+}
+
+// SetFraming sets the wire format of the connection
+func (tc *tcpConnection) SetFraming(framing Framing) {
+	tc.framing = framing
+}
+
+// SetUsage implements UsageTrackable
+func (tc *tcpConnection) SetUsage(usage *ProtocolUsage) {
+	tc.usage = usage
+}
+
+// SetShaping simulates bad network conditions on the connection. Once
+// shaped, frames keep going through the shaper so none are reordered.
+func (tc *tcpConnection) SetShaping(shaping Shaping) error {
+	if err := shaping.Validate(); err != nil {
+		return err
+	}
+	if shaping.Loss > 0 {
+		return ErrLossUnsupported
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if sh := tc.shaper.Load(); sh != nil {
+		sh.settings.Store(shaping)
+		return nil
+	}
+	if shaping.IsZero() || tc.isClosed() {
+		return nil
+	}
+
+	sh := newShaper(shaping)
+	tc.shaper.Store(sh)
+	if tc.isClosed() {
+		sh.close()
+	}
+	go sh.writeLoop(func(data []byte) error {
+		err := tc.write(data, 1)
+		if err != nil {
+			tc.Close()
+		}
+		return err
+	})
+	return nil
+}
+
+// Shaping returns the simulated network conditions
+func (tc *tcpConnection) Shaping() Shaping {
+	if sh := tc.shaper.Load(); sh != nil {
+		return sh.shaping()
+	}
+	return Shaping{}
+}
+
 // GetStatistics returns connection statistics
 func (tc *tcpConnection) GetStatistics() ConnectionStatistics {
+	latency := tc.latency.Stats()
+	coalescing := tc.CoalescingStats()
 	return ConnectionStatistics{
-		ConnectionID: tc.id,
-		State:        tc.State(),
-		BytesRead:    atomic.LoadInt64(&tc.bytesRead),
-		BytesWritten: atomic.LoadInt64(&tc.bytesWritten),
-		MessagesRead: atomic.LoadInt64(&tc.messagesRead),
-		MessagesSent: atomic.LoadInt64(&tc.messagesSent),
-		LastActivity: tc.GetLastActivity(),
-		RemoteAddr:   tc.RemoteAddr().String(),
-		LocalAddr:    tc.LocalAddr().String(),
+		ConnectionID:   tc.id,
+		State:          tc.State(),
+		BytesRead:      atomic.LoadInt64(&tc.bytesRead),
+		BytesWritten:   atomic.LoadInt64(&tc.bytesWritten),
+		MessagesRead:   atomic.LoadInt64(&tc.messagesRead),
+		MessagesSent:   atomic.LoadInt64(&tc.messagesSent),
+		CorruptFrames:  atomic.LoadInt64(&tc.corruptFrames),
+		ClockOffset:    latency.ClockOffset,
+		RoundTripTime:  latency.RoundTripTime,
+		OneWayDelay:    latency.OneWayDelay,
+		Jitter:         latency.Jitter,
+		FramesPerWrite: coalescing.FramesPerWrite,
+		LastActivity:   tc.GetLastActivity(),
+		RemoteAddr:     tc.RemoteAddr().String(),
+		LocalAddr:      tc.LocalAddr().String(),
+	}
+}
+
+// SetChecksum checksums every outgoing frame and requires a valid
+// checksum on every inbound one; both ends must agree
+func (tc *tcpConnection) SetChecksum(enabled bool) {
+	flag := int32(0)
+	if enabled {
+		flag = 1
+	}
+	atomic.StoreInt32(&tc.checksum, flag)
+}
+
+// SetTimestamps enables send timestamps on outgoing frames and, when
+// syncInterval is positive, piggybacks a clock sync request on outgoing
+// traffic at most that often
+func (tc *tcpConnection) SetTimestamps(enabled bool, syncInterval time.Duration) {
+	flag := int32(0)
+	if enabled {
+		flag = 1
 	}
+	tc.latency.mu.Lock()
+	tc.latency.syncInterval = syncInterval
+	tc.latency.mu.Unlock()
+	atomic.StoreInt32(&tc.timestamps, flag)
+}
+
+// LastRead returns when the last frame was read
+func (tc *tcpConnection) LastRead() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&tc.lastRead))
+}
+
+// LastHeartbeat returns when the peer last sent a heartbeat
+func (tc *tcpConnection) LastHeartbeat() time.Time {
+	if nanos := atomic.LoadInt64(&tc.lastHeartbeat); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+// Latency returns the connection's latency tracker; the one-way delay
+// estimates the peer-to-local direction and needs the peer to stamp its
+// frames
+func (tc *tcpConnection) Latency() *LatencyTracker {
+	return tc.latency
 }
 
 // Private methods
 
+// corruptFrame counts a frame that failed validation and closes the
+// connection: its length may be corrupt too, so the next frame boundary
+// is unknown
+func (tc *tcpConnection) corruptFrame(err error) error {
+	atomic.AddInt64(&tc.corruptFrames, 1)
+	tc.Close()
+	return fmt.Errorf("connection %s closed on a corrupt frame: %w", tc.id, err)
+}
+
 // isClosed checks if the connection is closed
 func (tc *tcpConnection) isClosed() bool {
 	return atomic.LoadInt32(&tc.closed) != 0
@@ -262,17 +563,24 @@ func (tc *tcpConnection) sendLoop() {
 	defer func() {
 		if r := recover(); r != nil {
 			// Log the panic but don't crash the program
-			fmt.Printf("sendLoop panic in connection %s: %v\n", tc.id, r)
+			core.ReportError("network", "send", core.SeverityCritical, fmt.Errorf("sendLoop panic in connection %s: %v", tc.id, r))
 		}
 	}()
 
+	var buf []byte
 	for data := range tc.sendChan {
 		if tc.isClosed() {
 			break
 		}
 
-		err := tc.sendDirect(data)
-		if err != nil {
+		frames, open := 1, true
+		if policy := tc.coalescingPolicy(); policy.Enabled {
+			data, frames, open = tc.coalesce(data, policy, buf)
+			buf = data
+		}
+
+		err := tc.write(data, frames)
+		if err != nil || !open {
 			// Connection error, close the connection
 			tc.Close()
 			break
@@ -282,6 +590,11 @@ func (tc *tcpConnection) sendLoop() {
 
 // sendDirect sends data directly through the connection
 func (tc *tcpConnection) sendDirect(data []byte) error {
+	return tc.write(data, 1)
+}
+
+// write writes a batch of frames in a single call
+func (tc *tcpConnection) write(data []byte, frames int) error {
 	if tc.conn == nil {
 		return fmt.Errorf("connection is nil")
 	}
@@ -306,6 +619,8 @@ func (tc *tcpConnection) sendDirect(data []byte) error {
 
 	// Update statistics and activity
 	atomic.AddInt64(&tc.bytesWritten, int64(n))
+	atomic.AddInt64(&tc.framesWritten, int64(frames))
+	atomic.AddInt64(&tc.writes, 1)
 	tc.updateActivity()
 
 	return nil
@@ -332,15 +647,21 @@ func (tc *tcpConnection) updateActivity() {
 
 // ConnectionStatistics holds statistics for a connection
 type ConnectionStatistics struct {
-	ConnectionID string          `json:"connection_id"`
-	State        ConnectionState `json:"state"`
-	BytesRead    int64           `json:"bytes_read"`
-	BytesWritten int64           `json:"bytes_written"`
-	MessagesRead int64           `json:"messages_read"`
-	MessagesSent int64           `json:"messages_sent"`
-	LastActivity time.Time       `json:"last_activity"`
-	RemoteAddr   string          `json:"remote_addr"`
-	LocalAddr    string          `json:"local_addr"`
+	ConnectionID   string          `json:"connection_id"`
+	State          ConnectionState `json:"state"`
+	BytesRead      int64           `json:"bytes_read"`
+	BytesWritten   int64           `json:"bytes_written"`
+	MessagesRead   int64           `json:"messages_read"`
+	MessagesSent   int64           `json:"messages_sent"`
+	CorruptFrames  int64           `json:"corrupt_frames"`
+	ClockOffset    time.Duration   `json:"clock_offset"`
+	RoundTripTime  time.Duration   `json:"round_trip_time"`
+	OneWayDelay    time.Duration   `json:"one_way_delay"`
+	Jitter         time.Duration   `json:"jitter"`
+	FramesPerWrite float64         `json:"frames_per_write"`
+	LastActivity   time.Time       `json:"last_activity"`
+	RemoteAddr     string          `json:"remote_addr"`
+	LocalAddr      string          `json:"local_addr"`
 }
 
 // String returns the string representation of connection statistics
@@ -3,11 +3,15 @@ package network
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/najoast/sngo/core"
 )
 
 // tcpServer implements the Server interface for TCP
@@ -19,9 +23,13 @@ type tcpServer struct {
 	// Event handlers
 	connHandler ConnectionHandler
 	msgHandler  MessageHandler
+	protoRouter *ProtocolRouter
+	admission   *AdmissionController
+	skynetAuth  SkynetAuthFunc
 
 	// Connection management
 	connections    map[string]Connection
+	rawConns       map[net.Conn]struct{} // owned by raw protocol handlers
 	connectionsMu  sync.RWMutex
 	connectionChan chan Connection
 
@@ -35,6 +43,14 @@ type tcpServer struct {
 	currentConnections int64
 	totalMessages      int64
 	startTime          time.Time
+	ipStats            *IPStatsTracker
+	usage              *ProtocolUsage
+
+	// Heartbeats and dead peer detection
+	liveness *livenessMonitor
+
+	// listen creates the listener; replaced by in-memory servers
+	listen func(network, address string) (net.Listener, error)
 }
 
 // NewTCPServer creates a new TCP server
@@ -48,18 +64,25 @@ func NewTCPServer(config *NetworkConfig) (Server, error) {
 		return nil, fmt.Errorf("invalid protocol for TCP server: %s", config.Protocol)
 	}
 
+	return newStreamServer(config), nil
+}
+
+// newStreamServer creates a server for a stream protocol (tcp or unix)
+func newStreamServer(config *NetworkConfig) *tcpServer {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	server := &tcpServer{
+	return &tcpServer{
 		config:         config,
 		connections:    make(map[string]Connection),
+		rawConns:       make(map[net.Conn]struct{}),
 		connectionChan: make(chan Connection, 100),
 		ctx:            ctx,
 		cancel:         cancel,
 		startTime:      time.Now(),
+		ipStats:        NewIPStatsTracker(config.IPStatsCapacity),
+		usage:          NewProtocolUsage(),
+		listen:         net.Listen,
 	}
-
-	return server, nil
 }
 
 // Start starts the TCP server
@@ -69,8 +92,8 @@ func (ts *tcpServer) Start() error {
 	}
 
 	// Create listener
-	address := fmt.Sprintf("%s:%d", ts.config.Address, ts.config.Port)
-	listener, err := net.Listen(string(ts.config.Protocol), address)
+	address := listenAddress(ts.config)
+	listener, err := ts.listen(string(ts.config.Protocol), address)
 	if err != nil {
 		atomic.StoreInt32(&ts.running, 0)
 		return fmt.Errorf("failed to listen on %s: %w", address, err)
@@ -82,13 +105,16 @@ func (ts *tcpServer) Start() error {
 	ts.wg.Add(1)
 	go ts.acceptLoop()
 
+	// Closing a dead peer ends its handleConnection loop, which removes it
+	ts.liveness = startLivenessMonitor(ts.ctx, ts.config.LivenessPolicy(), ts.GetActiveConnections, nil)
+
 	// Start connection handler if set
 	if ts.connHandler != nil {
 		ts.wg.Add(1)
 		go ts.connectionHandlerLoop()
 	}
 
-	fmt.Printf("TCP server started on %s\n", address)
+	fmt.Printf("%s server started on %s\n", protocolName(ts.config.Protocol), address)
 	return nil
 }
 
@@ -106,7 +132,15 @@ func (ts *tcpServer) Stop() error {
 		ts.listener.Close()
 	}
 
+	// Raw handlers only return once their connection closes
+	ts.connectionsMu.Lock()
+	for conn := range ts.rawConns {
+		conn.Close()
+	}
+	ts.connectionsMu.Unlock()
+
 	// Wait for goroutines to finish first
+	ts.liveness.stop()
 	ts.wg.Wait()
 
 	// Then close connection channel
@@ -119,7 +153,7 @@ func (ts *tcpServer) Stop() error {
 	}
 	ts.connectionsMu.Unlock()
 
-	fmt.Println("TCP server stopped")
+	fmt.Printf("%s server stopped\n", protocolName(ts.config.Protocol))
 	return nil
 }
 
@@ -162,6 +196,23 @@ func (ts *tcpServer) SetMessageHandler(handler MessageHandler) {
 	ts.msgHandler = handler
 }
 
+// SetProtocolRouter routes each new connection by its first frame. It must
+// be set before Start; nil restores the single-protocol behavior.
+func (ts *tcpServer) SetProtocolRouter(router *ProtocolRouter) {
+	ts.protoRouter = router
+}
+
+// SetAdmissionController sets the controller admitting inbound messages
+func (ts *tcpServer) SetAdmissionController(controller *AdmissionController) {
+	ts.admission = controller
+}
+
+// SetSkynetAuth sets the check of skynet msgserver handshakes, run before
+// serving connections of a listener with FramingSkynetMsgServer
+func (ts *tcpServer) SetSkynetAuth(auth SkynetAuthFunc) {
+	ts.skynetAuth = auth
+}
+
 // GetActiveConnections returns all active connections
 func (ts *tcpServer) GetActiveConnections() []Connection {
 	ts.connectionsMu.RLock()
@@ -191,6 +242,8 @@ func (ts *tcpServer) GetStatistics() ServerStatistics {
 		TotalConnections:   atomic.LoadInt64(&ts.totalConnections),
 		CurrentConnections: atomic.LoadInt64(&ts.currentConnections),
 		TotalMessages:      atomic.LoadInt64(&ts.totalMessages),
+		DeadPeers:          ts.liveness.DeadPeers(),
+		Usage:              ts.usage.Snapshot(),
 	}
 }
 
@@ -256,7 +309,7 @@ func (ts *tcpServer) acceptLoop() {
 			case <-ts.ctx.Done():
 				return
 			default:
-				fmt.Printf("Failed to accept connection: %v\n", err)
+				core.ReportError("network", "accept", core.SeverityError, fmt.Errorf("failed to accept connection: %w", err))
 				continue
 			}
 		}
@@ -265,61 +318,144 @@ func (ts *tcpServer) acceptLoop() {
 		if ts.config.MaxConnections > 0 {
 			currentCount := atomic.LoadInt64(&ts.currentConnections)
 			if currentCount >= int64(ts.config.MaxConnections) {
-				fmt.Printf("Connection limit reached (%d), rejecting new connection from %s\n",
-					ts.config.MaxConnections, conn.RemoteAddr())
+				core.ReportError("network", "accept", core.SeverityWarning, fmt.Errorf("connection limit reached (%d), rejecting new connection from %s",
+					ts.config.MaxConnections, conn.RemoteAddr()))
+				ts.ipStats.RecordReject(conn.RemoteAddr())
 				conn.Close()
 				continue
 			}
 		}
 
-		// Configure TCP connection
-		if tcpConn, ok := conn.(*net.TCPConn); ok {
-			if ts.config.KeepAlive {
-				tcpConn.SetKeepAlive(true)
-				tcpConn.SetKeepAlivePeriod(ts.config.KeepAliveInterval)
-			}
-		}
-
-		// Create connection wrapper
-		connection := NewTCPConnection(conn)
-
-		// Configure timeouts
-		connection.SetReadTimeout(ts.config.ReadTimeout)
-		connection.SetWriteTimeout(ts.config.WriteTimeout)
-
-		// Add to connections map
-		ts.addConnection(connection)
+		// Configure TCP keep-alive
+		ts.config.LivenessPolicy().ApplyKeepAlive(conn)
 
-		// Start message handler for this connection
-		if ts.msgHandler != nil {
+		// Authenticate skynet msgserver clients without blocking the accept loop
+		if ts.config.Framing == FramingSkynetMsgServer {
 			ts.wg.Add(1)
-			go ts.handleConnection(connection)
+			go ts.acceptSkynetClient(conn)
+			continue
 		}
 
-		// Send to connection channel for external processing
-		// Check context again before sending
-		select {
-		case <-ts.ctx.Done():
-			connection.Close()
-			return
-		default:
+		// Route by first frame without blocking the accept loop
+		if ts.protoRouter != nil {
+			ts.wg.Add(1)
+			go ts.routeConnection(conn)
+			continue
 		}
 
-		select {
-		case ts.connectionChan <- connection:
-		case <-ts.ctx.Done():
-			connection.Close()
+		if !ts.serveConnection(conn, ts.msgHandler) {
 			return
-		default:
-			// Channel is full, handle directly if possible
-			if ts.connHandler != nil {
-				go ts.connHandler.OnConnect(connection)
-			}
 		}
+	}
+}
 
-		// Update statistics
-		atomic.AddInt64(&ts.totalConnections, 1)
+// routeConnection peeks the first frame and dispatches to the matching stack
+func (ts *tcpServer) routeConnection(conn net.Conn) {
+	defer ts.wg.Done()
+
+	route, peeked, err := ts.protoRouter.Peek(conn)
+	if err != nil {
+		ts.ipStats.RecordError(conn.RemoteAddr())
+		conn.Close()
+		return
 	}
+
+	switch {
+	case route == nil:
+		ts.serveConnection(peeked, ts.msgHandler)
+	case route.Handler != nil:
+		ts.serveRaw(peeked, route.Handler)
+	default:
+		ts.serveConnection(peeked, route.MessageHandler)
+	}
+}
+
+// serveRaw hands conn to a raw protocol handler, which owns it for its
+// whole lifetime; Stop closes it and waits for the handler
+func (ts *tcpServer) serveRaw(conn net.Conn, handler ProtocolHandler) {
+	ts.connectionsMu.Lock()
+	if atomic.LoadInt32(&ts.running) == 0 {
+		ts.connectionsMu.Unlock()
+		conn.Close()
+		return
+	}
+	ts.rawConns[conn] = struct{}{}
+	ts.connectionsMu.Unlock()
+
+	defer func() {
+		ts.connectionsMu.Lock()
+		delete(ts.rawConns, conn)
+		ts.connectionsMu.Unlock()
+	}()
+	handler.ServeConn(conn)
+}
+
+// acceptSkynetClient runs the msgserver handshake of a skynet client and
+// serves it, with the authenticated SkynetUser as its user data
+func (ts *tcpServer) acceptSkynetClient(conn net.Conn) {
+	defer ts.wg.Done()
+
+	user, err := AcceptSkynetHandshake(conn, ts.config.ReadTimeout, ts.skynetAuth)
+	if err != nil {
+		ts.ipStats.RecordReject(conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+	ts.serveConnection(conn, ts.msgHandler, user)
+}
+
+// serveConnection wraps an accepted connection and starts serving framed
+// messages with msgHandler. It returns false if the server is shutting down.
+func (ts *tcpServer) serveConnection(conn net.Conn, msgHandler MessageHandler, userData ...interface{}) bool {
+	// Create connection wrapper
+	connection := NewTCPConnection(conn)
+	if len(userData) > 0 {
+		connection.SetUserData(userData[0])
+	}
+
+	// Configure timeouts
+	connection.SetReadTimeout(ts.config.ReadTimeout)
+	connection.SetWriteTimeout(ts.config.WriteTimeout)
+	configureFraming(connection, ts.config)
+	configureChecksum(connection, ts.config)
+	configureTimestamps(connection, ts.config)
+	configureCoalescing(connection, ts.config)
+	configureUsage(connection, ts.usage)
+
+	// Add to connections map
+	ts.addConnection(connection)
+	ts.ipStats.RecordAccept(conn.RemoteAddr())
+
+	// Start message handler for this connection
+	if msgHandler != nil {
+		ts.wg.Add(1)
+		go ts.handleConnection(connection, msgHandler)
+	}
+
+	// Send to connection channel for external processing
+	// Check context again before sending
+	select {
+	case <-ts.ctx.Done():
+		connection.Close()
+		return false
+	default:
+	}
+
+	select {
+	case ts.connectionChan <- connection:
+	case <-ts.ctx.Done():
+		connection.Close()
+		return false
+	default:
+		// Channel is full, handle directly if possible
+		if ts.connHandler != nil {
+			go ts.connHandler.OnConnect(connection)
+		}
+	}
+
+	// Update statistics
+	atomic.AddInt64(&ts.totalConnections, 1)
+	return true
 }
 
 // connectionHandlerLoop processes connections from the channel
@@ -342,9 +478,12 @@ func (ts *tcpServer) connectionHandlerLoop() {
 }
 
 // handleConnection handles messages for a single connection
-func (ts *tcpServer) handleConnection(conn Connection) {
+func (ts *tcpServer) handleConnection(conn Connection, msgHandler MessageHandler) {
 	defer ts.wg.Done()
 	defer ts.removeConnection(conn.ID())
+	defer func() {
+		ts.ipStats.RecordClose(conn.RemoteAddr(), conn.GetStatistics().BytesWritten)
+	}()
 
 	// Notify connection handler
 	if ts.connHandler != nil {
@@ -365,15 +504,26 @@ func (ts *tcpServer) handleConnection(conn Connection) {
 		msg, err := conn.ReadMessage()
 		if err != nil {
 			// Connection error
+			if !errors.Is(err, io.EOF) {
+				ts.ipStats.RecordError(conn.RemoteAddr())
+			}
 			if ts.connHandler != nil {
 				ts.connHandler.OnError(conn, err)
 			}
 			return
 		}
 
+		ts.ipStats.RecordMessage(conn.RemoteAddr(), msg.Size())
+
+		// Refuse what admission control holds back, e.g. during warm-up
+		if ts.admission != nil && !ts.admission.Admit(msg) {
+			rejectMessage(conn, msg)
+			continue
+		}
+
 		// Process message
-		if ts.msgHandler != nil {
-			ts.msgHandler.OnMessage(conn, msg)
+		if msgHandler != nil {
+			msgHandler.OnMessage(conn, msg)
 		}
 
 		// Update statistics
@@ -401,6 +551,11 @@ func (ts *tcpServer) removeConnection(connID string) {
 	}
 }
 
+// IPStats returns the per-IP statistics tracker
+func (ts *tcpServer) IPStats() *IPStatsTracker {
+	return ts.ipStats
+}
+
 // ServerStatistics holds statistics for a server
 type ServerStatistics struct {
 	Address            string        `json:"address"`
@@ -411,6 +566,10 @@ type ServerStatistics struct {
 	TotalConnections   int64         `json:"total_connections"`
 	CurrentConnections int64         `json:"current_connections"`
 	TotalMessages      int64         `json:"total_messages"`
+	DeadPeers          int64         `json:"dead_peers"`
+
+	// Usage is the traffic per message type, most bytes first
+	Usage []MessageTypeUsage `json:"usage,omitempty"`
 }
 
 // String returns the string representation of server statistics
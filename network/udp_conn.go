@@ -0,0 +1,290 @@
+// Package network provides UDP servers and clients over MTU-aware datagrams
+package network
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// udpConnBacklog is the number of received messages buffered per peer;
+// further messages are dropped until the connection reads
+const udpConnBacklog = 256
+
+// NewUDPServer creates a server exchanging SNGO frames over UDP. Every
+// message is fragmented below the path MTU discovered for its peer, and
+// each peer address is served as a connection, created by its first
+// datagram. UDP has no close, so idle peers are dropped by the read
+// timeout and the liveness policy of config; lost datagrams lose whole
+// frames, never part of one.
+func NewUDPServer(config *NetworkConfig) (Server, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	if config.Protocol != ProtocolUDP {
+		return nil, fmt.Errorf("invalid protocol for UDP server: %s", config.Protocol)
+	}
+
+	server := newStreamServer(config)
+	server.listen = func(network, address string) (net.Listener, error) {
+		conn, err := ListenMTU(network, address, config.MTU)
+		if err != nil {
+			return nil, err
+		}
+		return newUDPListener(conn), nil
+	}
+	return server, nil
+}
+
+// NewUDPClient creates a client exchanging SNGO frames with UDP servers
+func NewUDPClient(config *NetworkConfig) (Client, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	if config.Protocol != ProtocolUDP {
+		return nil, fmt.Errorf("invalid protocol for UDP client: %s", config.Protocol)
+	}
+
+	client := newStreamClient(config)
+	client.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		return dialUDP(network, address, config.MTU)
+	}
+	return client, nil
+}
+
+// dialUDP opens a socket of its own for the peer at address
+func dialUDP(network, address string, config *MTUConfig) (net.Conn, error) {
+	remote, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	mtu, err := ListenMTU(network, ":0", config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := newUDPConn(mtu, remote, func() { mtu.Close() })
+	go func() {
+		for {
+			data, addr, err := mtu.ReadFrom()
+			if err != nil {
+				conn.Close()
+				return
+			}
+			if addr.String() == remote.String() {
+				conn.deliver(data)
+			}
+		}
+	}()
+	mtu.Probe(remote)
+	return conn, nil
+}
+
+// udpListener accepts a connection for each new peer of an MTUPacketConn
+type udpListener struct {
+	mtu     *MTUPacketConn
+	accepts chan net.Conn
+
+	mu    sync.Mutex
+	conns map[string]*udpConn
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// newUDPListener starts routing the messages of mtu to their peers
+func newUDPListener(mtu *MTUPacketConn) *udpListener {
+	l := &udpListener{
+		mtu:     mtu,
+		accepts: make(chan net.Conn),
+		conns:   make(map[string]*udpConn),
+		done:    make(chan struct{}),
+	}
+	go l.readLoop()
+	return l
+}
+
+// readLoop hands each message to the connection of its sender, accepting
+// a new connection for an unknown sender
+func (l *udpListener) readLoop() {
+	for {
+		data, addr, err := l.mtu.ReadFrom()
+		if err != nil {
+			l.Close()
+			return
+		}
+
+		key := addr.String()
+		l.mu.Lock()
+		conn, exists := l.conns[key]
+		if !exists {
+			conn = newUDPConn(l.mtu, addr, func() {
+				l.mu.Lock()
+				delete(l.conns, key)
+				l.mu.Unlock()
+			})
+			l.conns[key] = conn
+		}
+		l.mu.Unlock()
+
+		if !exists {
+			select {
+			case l.accepts <- conn:
+			case <-l.done:
+				return
+			}
+		}
+		conn.deliver(data)
+	}
+}
+
+// Accept waits for the first message of a new peer
+func (l *udpListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.accepts:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the socket and every connection
+func (l *udpListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		err = l.mtu.Close()
+
+		l.mu.Lock()
+		conns := make([]*udpConn, 0, len(l.conns))
+		for _, conn := range l.conns {
+			conns = append(conns, conn)
+		}
+		l.mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	return err
+}
+
+// Addr returns the listening address
+func (l *udpListener) Addr() net.Addr {
+	return l.mtu.LocalAddr()
+}
+
+// udpConn is the byte stream of the messages exchanged with one peer.
+// Each Write is sent as one message, and Read returns the messages
+// received in order, so whole frames written at once stay whole.
+type udpConn struct {
+	mtu      *MTUPacketConn
+	remote   net.Addr
+	incoming chan []byte
+	onClose  func()
+
+	readMu   sync.Mutex
+	buffered []byte
+
+	deadlineMu sync.Mutex
+	deadline   time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// newUDPConn creates the connection to remote; onClose runs once on Close
+func newUDPConn(mtu *MTUPacketConn, remote net.Addr, onClose func()) *udpConn {
+	return &udpConn{
+		mtu:      mtu,
+		remote:   remote,
+		incoming: make(chan []byte, udpConnBacklog),
+		onClose:  onClose,
+		done:     make(chan struct{}),
+	}
+}
+
+// deliver queues a received message, dropping it if the backlog is full
+func (c *udpConn) deliver(data []byte) {
+	select {
+	case c.incoming <- data:
+	default:
+	}
+}
+
+// Read reads from the current message, waiting for the next one when it
+// is consumed
+func (c *udpConn) Read(b []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if len(c.buffered) == 0 {
+		c.deadlineMu.Lock()
+		deadline := c.deadline
+		c.deadlineMu.Unlock()
+
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			timer := time.NewTimer(time.Until(deadline))
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		select {
+		case data := <-c.incoming:
+			c.buffered = data
+		case <-c.done:
+			return 0, io.EOF
+		case <-expired:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+
+	n := copy(b, c.buffered)
+	c.buffered = c.buffered[n:]
+	return n, nil
+}
+
+// Write sends b as one message
+func (c *udpConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	if err := c.mtu.WriteTo(b, c.remote); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close ends the connection; the peer is not told
+func (c *udpConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.onClose()
+	})
+	return nil
+}
+
+// LocalAddr returns the address of the socket
+func (c *udpConn) LocalAddr() net.Addr { return c.mtu.LocalAddr() }
+
+// RemoteAddr returns the address of the peer
+func (c *udpConn) RemoteAddr() net.Addr { return c.remote }
+
+// SetDeadline sets the read deadline; writes never block
+func (c *udpConn) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+
+// SetReadDeadline sets the deadline of the next Read
+func (c *udpConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	c.deadline = t
+	c.deadlineMu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline is a no-op: writes never block
+func (c *udpConn) SetWriteDeadline(t time.Time) error { return nil }
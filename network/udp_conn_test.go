@@ -0,0 +1,66 @@
+// Package network provides tests for the UDP transport
+package network
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUDPClientServer(t *testing.T) {
+	config := DefaultNetworkConfig()
+	config.Protocol = ProtocolUDP
+	config.Address = "127.0.0.1"
+	config.Port = 0
+
+	server, err := DefaultFactory.CreateServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create UDP server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start UDP server: %v", err)
+	}
+	defer server.Stop()
+
+	client, err := DefaultFactory.CreateClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create UDP client: %v", err)
+	}
+	conn, err := client.ConnectWithTimeout(server.Listen().String(), time.Second)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	// A frame larger than the path MTU arrives whole
+	payload := bytes.Repeat([]byte("x"), 5000)
+	if err := conn.SendMessage(NewMessage(MessageTypeData, payload)); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	serverConn, err := server.AcceptConnection(ctx)
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	msg, err := serverConn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if err := ExpectFrame(msg, MessageTypeData, payload); err != nil {
+		t.Error(err)
+	}
+
+	if err := serverConn.SendMessage(NewMessage(MessageTypeData, []byte("pong"))); err != nil {
+		t.Fatalf("Failed to reply: %v", err)
+	}
+	reply, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if err := ExpectFrame(reply, MessageTypeData, []byte("pong")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,610 @@
+// Package network provides MTU-aware UDP datagram transport
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// UDP datagram kinds
+const (
+	udpKindData     byte = 1
+	udpKindProbe    byte = 2
+	udpKindProbeAck byte = 3
+)
+
+// udpHeaderSize is the size of the header prepended to every datagram:
+// [kind u8][reserved u8][index u16][count u16][id u32]
+const udpHeaderSize = 10
+
+// MTU discovery errors
+var (
+	// ErrUDPMessageTooLarge is returned for messages above MaxMessageSize
+	ErrUDPMessageTooLarge = errors.New("udp message too large")
+
+	// ErrUDPClosed is returned once the packet connection is closed
+	ErrUDPClosed = errors.New("udp connection closed")
+)
+
+// MTUConfig configures path MTU discovery and fragmentation. Payload sizes
+// are UDP payload bytes per datagram, headers included
+type MTUConfig struct {
+	// MinPayload is the conservative size used before discovery finishes
+	// and after a black hole is detected. Peers must agree on it: messages
+	// fragmented into more pieces than MaxMessageSize takes at MinPayload
+	// are dropped
+	MinPayload int
+
+	// MaxPayload is the largest size discovery probes for
+	MaxPayload int
+
+	// ProbeTimeout is how long to wait for a probe to be acknowledged
+	ProbeTimeout time.Duration
+
+	// ProbeAttempts is the number of unanswered probes after which a size
+	// is considered not to fit the path
+	ProbeAttempts int
+
+	// ProbeGranularity stops the search once the bounds are this close
+	ProbeGranularity int
+
+	// ProbeInterval is how often a finished search is restarted to pick up
+	// a path that got larger
+	ProbeInterval time.Duration
+
+	// ConfirmInterval is how often the current size is re-probed to detect
+	// a path that got smaller (black hole detection)
+	ConfirmInterval time.Duration
+
+	// ReassemblyTimeout drops partially received messages
+	ReassemblyTimeout time.Duration
+
+	// MaxMessageSize bounds the size of a reassembled message
+	MaxMessageSize int
+
+	// MaxReassemblies bounds the partially received messages kept per
+	// peer; fragments of further messages are dropped
+	MaxReassemblies int
+}
+
+// DefaultMTUConfig returns the default MTU configuration: a 1232 byte floor
+// (safe for IPv6 without extension headers) and a 1472 byte ceiling
+// (Ethernet minus IPv4 and UDP headers)
+func DefaultMTUConfig() *MTUConfig {
+	return &MTUConfig{
+		MinPayload:        1232,
+		MaxPayload:        1472,
+		ProbeTimeout:      500 * time.Millisecond,
+		ProbeAttempts:     3,
+		ProbeGranularity:  16,
+		ProbeInterval:     10 * time.Minute,
+		ConfirmInterval:   30 * time.Second,
+		ReassemblyTimeout: 5 * time.Second,
+		MaxMessageSize:    1024 * 1024,
+		MaxReassemblies:   16,
+	}
+}
+
+// PathMTUInfo reports the discovery state of one peer
+type PathMTUInfo struct {
+	// PayloadSize is the datagram size currently used for the peer
+	PayloadSize int
+
+	// Searching is true while a larger size is being probed
+	Searching bool
+
+	// BlackHoles counts the times the path stopped carrying PayloadSize
+	BlackHoles int
+}
+
+// pathMTU is the discovery state of one peer
+type pathMTU struct {
+	addr   net.Addr
+	size   int // confirmed payload size
+	low    int // largest size known to fit
+	high   int // largest size that may fit
+	search bool
+
+	probeID      uint32
+	probeSize    int
+	probeSent    time.Time
+	probeAttempt int
+
+	nextSearch  time.Time
+	nextConfirm time.Time
+	blackHoles  int
+}
+
+// reassembly collects the fragments of one message
+type reassembly struct {
+	peer     string
+	parts    [][]byte
+	received int
+	size     int
+	started  time.Time
+}
+
+// udpMessage is a reassembled message waiting to be read
+type udpMessage struct {
+	data []byte
+	addr net.Addr
+}
+
+// MTUPacketConn sends messages of any size over a UDP socket, fragmenting
+// them below the path MTU discovered for each peer. Discovery probes each
+// peer with padded datagrams (the socket sets the don't-fragment bit where
+// the platform allows it), binary searches between MinPayload and
+// MaxPayload, and falls back to MinPayload when the current size stops
+// being acknowledged, so a shrinking path does not silently drop traffic
+type MTUPacketConn struct {
+	conn   net.PacketConn
+	config *MTUConfig
+
+	mu      sync.Mutex
+	paths   map[string]*pathMTU
+	pending map[string]*reassembly
+	peers   map[string]int // pending reassemblies per peer
+	nextID  uint32
+
+	incoming  chan udpMessage
+	closed    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// ListenMTU listens on a UDP address with path MTU discovery enabled
+func ListenMTU(network, address string, config *MTUConfig) (*MTUPacketConn, error) {
+	lc := net.ListenConfig{Control: setDontFragment}
+	conn, err := lc.ListenPacket(context.Background(), network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	return NewMTUPacketConn(conn, config), nil
+}
+
+// NewMTUPacketConn wraps conn; it takes ownership of reading from it
+func NewMTUPacketConn(conn net.PacketConn, config *MTUConfig) *MTUPacketConn {
+	if config == nil {
+		config = DefaultMTUConfig()
+	}
+
+	c := &MTUPacketConn{
+		conn:     conn,
+		config:   config,
+		paths:    make(map[string]*pathMTU),
+		pending:  make(map[string]*reassembly),
+		peers:    make(map[string]int),
+		incoming: make(chan udpMessage, 256),
+		closed:   make(chan struct{}),
+	}
+
+	c.wg.Add(2)
+	go c.readLoop()
+	go c.maintainLoop()
+	return c
+}
+
+// LocalAddr returns the local address
+func (c *MTUPacketConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// WriteTo sends msg to addr, fragmented to the peer's payload size
+func (c *MTUPacketConn) WriteTo(msg []byte, addr net.Addr) error {
+	if len(msg) > c.config.MaxMessageSize {
+		return ErrUDPMessageTooLarge
+	}
+
+	for {
+		size, id := c.prepareWrite(addr)
+		err := c.writeFragments(msg, addr, size, id)
+		if err == nil || !isMessageTooLong(err) {
+			return err
+		}
+
+		// The local stack already knows the path is smaller
+		if !c.tooBig(addr, size) {
+			return err
+		}
+	}
+}
+
+// ReadFrom returns the next reassembled message and its sender
+func (c *MTUPacketConn) ReadFrom() ([]byte, net.Addr, error) {
+	select {
+	case msg := <-c.incoming:
+		return msg.data, msg.addr, nil
+	case <-c.closed:
+		return nil, nil, ErrUDPClosed
+	}
+}
+
+// Probe starts path MTU discovery for addr; it is also started by the
+// first WriteTo to a peer
+func (c *MTUPacketConn) Probe(addr net.Addr) {
+	c.mu.Lock()
+	path := c.path(addr)
+	path.nextSearch = time.Time{}
+	c.mu.Unlock()
+
+	c.maintain(time.Now())
+}
+
+// PayloadSize returns the datagram size used for addr
+func (c *MTUPacketConn) PayloadSize(addr net.Addr) int {
+	return c.PathMTU(addr).PayloadSize
+}
+
+// PathMTU returns the discovery state of addr
+func (c *MTUPacketConn) PathMTU(addr net.Addr) PathMTUInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path, exists := c.paths[addr.String()]
+	if !exists {
+		return PathMTUInfo{PayloadSize: c.config.MinPayload}
+	}
+	return PathMTUInfo{PayloadSize: path.size, Searching: path.search, BlackHoles: path.blackHoles}
+}
+
+// Close closes the socket and stops discovery
+func (c *MTUPacketConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.conn.Close()
+		c.wg.Wait()
+	})
+	return err
+}
+
+// path returns the state of addr, creating it; c.mu must be held
+func (c *MTUPacketConn) path(addr net.Addr) *pathMTU {
+	key := addr.String()
+	path, exists := c.paths[key]
+	if !exists {
+		path = &pathMTU{
+			addr: addr,
+			size: c.config.MinPayload,
+			low:  c.config.MinPayload,
+			high: c.config.MaxPayload,
+		}
+		c.paths[key] = path
+	}
+	return path
+}
+
+// prepareWrite returns the payload size for addr and a message ID
+func (c *MTUPacketConn) prepareWrite(addr net.Addr) (int, uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	return c.path(addr).size, c.nextID
+}
+
+// tooBig lowers the size of addr after the stack rejected a datagram of
+// size; it returns false if the size cannot be lowered any further
+func (c *MTUPacketConn) tooBig(addr net.Addr, size int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(addr)
+	if size <= c.config.MinPayload {
+		return false
+	}
+	path.high = size - 1
+	path.low = c.config.MinPayload
+	path.size = c.config.MinPayload
+	path.search = true
+	return true
+}
+
+// writeFragments splits msg into datagrams of at most size bytes
+func (c *MTUPacketConn) writeFragments(msg []byte, addr net.Addr, size int, id uint32) error {
+	chunk := size - udpHeaderSize
+	count := (len(msg) + chunk - 1) / chunk
+	if count == 0 {
+		count = 1
+	}
+	if count > 0xFFFF {
+		return ErrUDPMessageTooLarge
+	}
+
+	datagram := make([]byte, size)
+	for index := 0; index < count; index++ {
+		start := index * chunk
+		end := start + chunk
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		putUDPHeader(datagram, udpKindData, index, count, id)
+		n := copy(datagram[udpHeaderSize:], msg[start:end])
+		if _, err := c.conn.WriteTo(datagram[:udpHeaderSize+n], addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putUDPHeader writes a datagram header
+func putUDPHeader(datagram []byte, kind byte, index, count int, id uint32) {
+	datagram[0] = kind
+	datagram[1] = 0
+	binary.BigEndian.PutUint16(datagram[2:4], uint16(index))
+	binary.BigEndian.PutUint16(datagram[4:6], uint16(count))
+	binary.BigEndian.PutUint32(datagram[6:10], id)
+}
+
+// readLoop reads datagrams, answers probes and reassembles messages
+func (c *MTUPacketConn) readLoop() {
+	defer c.wg.Done()
+
+	buffer := make([]byte, 64*1024)
+	for {
+		n, addr, err := c.conn.ReadFrom(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Errors such as ICMP port unreachable do not end the socket
+			continue
+		}
+		if n < udpHeaderSize {
+			continue
+		}
+
+		datagram := buffer[:n]
+		kind := datagram[0]
+		index := int(binary.BigEndian.Uint16(datagram[2:4]))
+		count := int(binary.BigEndian.Uint16(datagram[4:6]))
+		id := binary.BigEndian.Uint32(datagram[6:10])
+
+		switch kind {
+		case udpKindProbe:
+			ack := make([]byte, udpHeaderSize+2)
+			putUDPHeader(ack, udpKindProbeAck, 0, 1, id)
+			binary.BigEndian.PutUint16(ack[udpHeaderSize:], uint16(n))
+			c.conn.WriteTo(ack, addr)
+		case udpKindProbeAck:
+			if n >= udpHeaderSize+2 {
+				c.probeAcked(addr, id, int(binary.BigEndian.Uint16(datagram[udpHeaderSize:])))
+			}
+		case udpKindData:
+			if msg := c.reassemble(addr, id, index, count, datagram[udpHeaderSize:]); msg != nil {
+				select {
+				case c.incoming <- udpMessage{data: msg, addr: addr}:
+				case <-c.closed:
+					return
+				}
+			}
+		}
+	}
+}
+
+// reassemble stores a fragment and returns the message once complete. The
+// fragment count comes from an unauthenticated header, so it is bounded by
+// the number of MinPayload fragments MaxMessageSize takes, and each peer
+// has at most MaxReassemblies messages in progress.
+func (c *MTUPacketConn) reassemble(addr net.Addr, id uint32, index, count int, data []byte) []byte {
+	if count == 0 || index >= count || count > c.maxFragments() {
+		return nil
+	}
+	if count == 1 {
+		return append([]byte(nil), data...)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	peer := addr.String()
+	key := fmt.Sprintf("%s/%d", peer, id)
+	r, exists := c.pending[key]
+	if !exists {
+		if c.peers[peer] >= c.config.MaxReassemblies {
+			return nil
+		}
+		r = &reassembly{peer: peer, parts: make([][]byte, count), started: time.Now()}
+		c.pending[key] = r
+		c.peers[peer]++
+	}
+	if len(r.parts) != count || r.parts[index] != nil {
+		return nil
+	}
+
+	r.size += len(data)
+	if r.size > c.config.MaxMessageSize {
+		c.dropReassembly(key, r)
+		return nil
+	}
+	r.parts[index] = append([]byte(nil), data...)
+	r.received++
+	if r.received < count {
+		return nil
+	}
+
+	c.dropReassembly(key, r)
+	msg := make([]byte, 0, r.size)
+	for _, part := range r.parts {
+		msg = append(msg, part...)
+	}
+	return msg
+}
+
+// maxFragments returns the largest fragment count of a valid message
+func (c *MTUPacketConn) maxFragments() int {
+	chunk := c.config.MinPayload - udpHeaderSize
+	if chunk <= 0 {
+		return 1
+	}
+	return (c.config.MaxMessageSize + chunk - 1) / chunk
+}
+
+// dropReassembly forgets a pending message; c.mu must be held
+func (c *MTUPacketConn) dropReassembly(key string, r *reassembly) {
+	delete(c.pending, key)
+	if c.peers[r.peer]--; c.peers[r.peer] <= 0 {
+		delete(c.peers, r.peer)
+	}
+}
+
+// probeAcked records that the peer received a probe of size bytes
+func (c *MTUPacketConn) probeAcked(addr net.Addr, id uint32, size int) {
+	c.mu.Lock()
+	path, exists := c.paths[addr.String()]
+	if !exists || path.probeSize == 0 || path.probeID != id || size != path.probeSize {
+		c.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	path.probeSize = 0
+	path.probeAttempt = 0
+	if size > path.low {
+		path.low = size
+	}
+	if size > path.size {
+		path.size = size
+	}
+	path.nextConfirm = now.Add(c.config.ConfirmInterval)
+	c.mu.Unlock()
+
+	// Continue the search right away
+	c.maintain(now)
+}
+
+// maintainLoop drives probe timeouts, searches and confirmations
+func (c *MTUPacketConn) maintainLoop() {
+	defer c.wg.Done()
+
+	interval := c.config.ProbeTimeout / 2
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			c.maintain(now)
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// maintain advances the discovery of every peer and expires reassemblies
+func (c *MTUPacketConn) maintain(now time.Time) {
+	type probe struct {
+		addr net.Addr
+		id   uint32
+		size int
+	}
+	var probes []probe
+
+	c.mu.Lock()
+	for key, r := range c.pending {
+		if now.Sub(r.started) > c.config.ReassemblyTimeout {
+			c.dropReassembly(key, r)
+		}
+	}
+
+	for _, path := range c.paths {
+		size := c.nextProbe(path, now)
+		if size == 0 {
+			continue
+		}
+		if size != path.probeSize {
+			c.nextID++
+			path.probeID = c.nextID
+			path.probeSize = size
+			path.probeAttempt = 0
+		}
+		path.probeSent = now
+		path.probeAttempt++
+		probes = append(probes, probe{addr: path.addr, id: path.probeID, size: size})
+	}
+	c.mu.Unlock()
+
+	for _, p := range probes {
+		datagram := make([]byte, p.size)
+		putUDPHeader(datagram, udpKindProbe, 0, 1, p.id)
+		if _, err := c.conn.WriteTo(datagram, p.addr); err != nil && isMessageTooLong(err) {
+			// The local stack rejects the size, no need to wait for a timeout
+			c.mu.Lock()
+			if path, exists := c.paths[p.addr.String()]; exists && path.probeID == p.id {
+				path.probeAttempt = c.config.ProbeAttempts
+				path.probeSent = time.Time{}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// nextProbe returns the size to probe for path now, or 0 if no probe is
+// due; it also handles probe timeouts. c.mu must be held
+func (c *MTUPacketConn) nextProbe(path *pathMTU, now time.Time) int {
+	// A probe is outstanding
+	if path.probeSize != 0 {
+		if now.Sub(path.probeSent) < c.config.ProbeTimeout {
+			return 0
+		}
+		if path.probeAttempt < c.config.ProbeAttempts {
+			return path.probeSize
+		}
+
+		// The probed size does not fit the path
+		lost := path.probeSize
+		path.probeSize = 0
+		path.probeAttempt = 0
+		if lost <= path.size {
+			// Black hole: the confirmed size stopped getting through
+			path.blackHoles++
+			path.size = c.config.MinPayload
+			path.low = c.config.MinPayload
+			path.search = true
+			path.nextConfirm = time.Time{}
+		}
+		path.high = lost - 1
+		if path.high < path.low {
+			path.high = path.low
+		}
+	}
+
+	// Start a new search when due
+	if !path.search && !now.Before(path.nextSearch) {
+		path.search = true
+		path.low = path.size
+		path.high = c.config.MaxPayload
+	}
+
+	if path.search {
+		if path.high-path.low <= c.config.ProbeGranularity {
+			path.search = false
+			path.nextSearch = now.Add(c.config.ProbeInterval)
+			path.nextConfirm = now.Add(c.config.ConfirmInterval)
+			return 0
+		}
+		return (path.low + path.high + 1) / 2
+	}
+
+	// Confirm the current size is still carried by the path
+	if path.size > c.config.MinPayload && !now.Before(path.nextConfirm) {
+		return path.size
+	}
+	return 0
+}
+
+// isMessageTooLong returns true if the local stack rejected a datagram as
+// larger than the known path MTU
+func isMessageTooLong(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+// Package network provides the Linux don't-fragment socket option
+package network
+
+import (
+	"strings"
+	"syscall"
+)
+
+// setDontFragment sets the don't-fragment bit on outgoing datagrams without
+// capping them at the cached path MTU, so probes above it still go out
+func setDontFragment(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if !strings.HasSuffix(network, "6") {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE)
+		}
+		if !strings.HasSuffix(network, "4") {
+			// Fails on IPv4-only sockets, which the option above covers
+			if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_PROBE); err != nil && strings.HasSuffix(network, "6") {
+				sockErr = err
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
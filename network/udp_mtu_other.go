@@ -0,0 +1,12 @@
+//go:build !linux
+
+// Package network provides the don't-fragment fallback for other platforms
+package network
+
+import "syscall"
+
+// setDontFragment is a no-op where the socket option is not portable; the
+// probe timeouts still detect paths that drop large datagrams
+func setDontFragment(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package network provides tests for MTU-aware UDP transport
+package network
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// lossyPacketConn silently drops datagrams above a size limit, like a path
+// with a small MTU whose ICMP errors are filtered
+type lossyPacketConn struct {
+	net.PacketConn
+	limit int64
+}
+
+func (c *lossyPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if int64(len(p)) > atomic.LoadInt64(&c.limit) {
+		return len(p), nil
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func testMTUConfig() *MTUConfig {
+	config := DefaultMTUConfig()
+	config.MinPayload = 512
+	config.ProbeTimeout = 40 * time.Millisecond
+	config.ProbeAttempts = 2
+	config.ConfirmInterval = 100 * time.Millisecond
+	return config
+}
+
+// waitPathMTU waits until the path state of addr satisfies cond
+func waitPathMTU(t *testing.T, c *MTUPacketConn, addr net.Addr, cond func(PathMTUInfo) bool) PathMTUInfo {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info := c.PathMTU(addr)
+		if cond(info) {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for path MTU, last state %+v", info)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMTUDiscoveryAndBlackHole(t *testing.T) {
+	receiver, err := ListenMTU("udp4", "127.0.0.1:0", testMTUConfig())
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer receiver.Close()
+
+	socket, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	lossy := &lossyPacketConn{PacketConn: socket, limit: 1000}
+	sender := NewMTUPacketConn(lossy, testMTUConfig())
+	defer sender.Close()
+
+	peer := receiver.LocalAddr()
+	if size := sender.PayloadSize(peer); size != 512 {
+		t.Errorf("Expected conservative size before discovery, got %d", size)
+	}
+
+	// Discovery settles just below the path limit
+	sender.Probe(peer)
+	info := waitPathMTU(t, sender, peer, func(info PathMTUInfo) bool {
+		return !info.Searching && info.PayloadSize > 512
+	})
+	if info.PayloadSize > 1000 || info.PayloadSize < 1000-16 {
+		t.Errorf("Expected payload size just below 1000, got %d", info.PayloadSize)
+	}
+
+	// Messages larger than the path MTU are fragmented and reassembled
+	msg := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := sender.WriteTo(msg, peer); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	received, _, err := receiver.ReadFrom()
+	if err != nil || !bytes.Equal(received, msg) {
+		t.Fatalf("Message did not round trip (%d bytes, %v)", len(received), err)
+	}
+
+	// The path shrinks: confirmations fail and the sender falls back
+	atomic.StoreInt64(&lossy.limit, 700)
+	info = waitPathMTU(t, sender, peer, func(info PathMTUInfo) bool {
+		return info.BlackHoles == 1 && !info.Searching
+	})
+	if info.PayloadSize > 700 {
+		t.Errorf("Expected payload size at most 700 after black hole, got %d", info.PayloadSize)
+	}
+
+	if err := sender.WriteTo(msg, peer); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	received, _, err = receiver.ReadFrom()
+	if err != nil || !bytes.Equal(received, msg) {
+		t.Fatalf("Message did not round trip after fallback (%d bytes, %v)", len(received), err)
+	}
+}
+
+func TestMTUReassemblyBounds(t *testing.T) {
+	config := testMTUConfig()
+	config.MaxMessageSize = 64 * 1024
+	config.MaxReassemblies = 4
+	c, err := ListenMTU("udp4", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer c.Close()
+
+	peer := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9}
+	fragment := make([]byte, config.MinPayload-udpHeaderSize)
+
+	// A count no valid message needs is dropped before allocating
+	c.reassemble(peer, 1, 0, 0xFFFF, fragment)
+	c.mu.Lock()
+	pending := len(c.pending)
+	c.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("Expected an oversized fragment count dropped, got %d pending", pending)
+	}
+
+	// Each peer has a bounded number of messages in progress
+	for id := uint32(1); id <= 10; id++ {
+		c.reassemble(peer, id, 0, 2, fragment)
+	}
+	other := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10}
+	c.reassemble(other, 1, 0, 2, fragment)
+	c.mu.Lock()
+	pending = len(c.pending)
+	c.mu.Unlock()
+	if pending != 5 {
+		t.Errorf("Expected 4 reassemblies of the peer and 1 of another, got %d", pending)
+	}
+
+	if msg := c.reassemble(peer, 1, 1, 2, []byte("end")); len(msg) != len(fragment)+3 {
+		t.Errorf("Expected an admitted message completed, got %d bytes", len(msg))
+	}
+	c.reassemble(peer, 11, 0, 2, fragment)
+	c.mu.Lock()
+	peers := c.peers[peer.String()]
+	c.mu.Unlock()
+	if peers != 4 {
+		t.Errorf("Expected a completed message to free its slot, got %d pending", peers)
+	}
+}
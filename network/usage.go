@@ -0,0 +1,175 @@
+// Package network provides per message type traffic analytics
+package network
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// maxUsageTypes bounds the message types counted separately; peers choose
+// the types they send, so the rest are counted together as "other"
+const maxUsageTypes = 256
+
+// sizeBuckets are the upper bounds, in bytes, of the frame size histograms
+var sizeBuckets = []int64{64, 256, 1024, 4096, 16 * 1024, 64 * 1024, 256 * 1024, 1024 * 1024}
+
+// SizeHistogram counts frames by size
+type SizeHistogram struct {
+	Bounds []int64 `json:"bounds"`
+	Counts []int64 `json:"counts"` // len(Bounds)+1, the last one unbounded
+	Count  int64   `json:"count"`
+	Bytes  int64   `json:"bytes"`
+	Max    int64   `json:"max"`
+}
+
+// Mean returns the mean frame size
+func (h SizeHistogram) Mean() int64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Bytes / h.Count
+}
+
+// sizeCounter is a SizeHistogram updated with atomics
+type sizeCounter struct {
+	counts [9]int64 // len(sizeBuckets)+1
+	count  int64
+	bytes  int64
+	max    int64
+}
+
+// observe adds a frame of size bytes
+func (c *sizeCounter) observe(size int64) {
+	i := 0
+	for i < len(sizeBuckets) && size > sizeBuckets[i] {
+		i++
+	}
+	atomic.AddInt64(&c.counts[i], 1)
+	atomic.AddInt64(&c.count, 1)
+	atomic.AddInt64(&c.bytes, size)
+	for {
+		max := atomic.LoadInt64(&c.max)
+		if size <= max || atomic.CompareAndSwapInt64(&c.max, max, size) {
+			break
+		}
+	}
+}
+
+// histogram returns a copy of the counters
+func (c *sizeCounter) histogram() SizeHistogram {
+	h := SizeHistogram{
+		Bounds: sizeBuckets,
+		Counts: make([]int64, len(c.counts)),
+		Count:  atomic.LoadInt64(&c.count),
+		Bytes:  atomic.LoadInt64(&c.bytes),
+		Max:    atomic.LoadInt64(&c.max),
+	}
+	for i := range c.counts {
+		h.Counts[i] = atomic.LoadInt64(&c.counts[i])
+	}
+	return h
+}
+
+// MessageTypeUsage reports the traffic of one message type
+type MessageTypeUsage struct {
+	Type     MessageType   `json:"type"`
+	Name     string        `json:"name"`
+	Inbound  SizeHistogram `json:"inbound"`
+	Outbound SizeHistogram `json:"outbound"`
+}
+
+// TotalBytes returns the bytes of the type in both directions
+func (u MessageTypeUsage) TotalBytes() int64 {
+	return u.Inbound.Bytes + u.Outbound.Bytes
+}
+
+// typeUsage counts the frames of one message type
+type typeUsage struct {
+	inbound  sizeCounter
+	outbound sizeCounter
+}
+
+// ProtocolUsage counts frames and their sizes per message type, in and
+// out, so protocol owners can see which opcodes dominate bandwidth. One
+// is shared by all connections of a server or client.
+type ProtocolUsage struct {
+	mu     sync.RWMutex
+	byType map[MessageType]*typeUsage
+	other  typeUsage
+}
+
+// NewProtocolUsage creates empty usage counters
+func NewProtocolUsage() *ProtocolUsage {
+	return &ProtocolUsage{byType: make(map[MessageType]*typeUsage)}
+}
+
+// RecordInbound counts a received frame of size bytes
+func (pu *ProtocolUsage) RecordInbound(msgType MessageType, size int) {
+	pu.usage(msgType).inbound.observe(int64(size))
+}
+
+// RecordOutbound counts a sent frame of size bytes
+func (pu *ProtocolUsage) RecordOutbound(msgType MessageType, size int) {
+	pu.usage(msgType).outbound.observe(int64(size))
+}
+
+// Snapshot returns the usage per message type, most bytes first
+func (pu *ProtocolUsage) Snapshot() []MessageTypeUsage {
+	pu.mu.RLock()
+	usages := make([]MessageTypeUsage, 0, len(pu.byType))
+	for msgType, usage := range pu.byType {
+		usages = append(usages, MessageTypeUsage{
+			Type:     msgType,
+			Name:     msgType.String(),
+			Inbound:  usage.inbound.histogram(),
+			Outbound: usage.outbound.histogram(),
+		})
+	}
+	pu.mu.RUnlock()
+
+	other := MessageTypeUsage{
+		Name:     "other",
+		Inbound:  pu.other.inbound.histogram(),
+		Outbound: pu.other.outbound.histogram(),
+	}
+	if other.Inbound.Count+other.Outbound.Count > 0 {
+		usages = append(usages, other)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if a, b := usages[i].TotalBytes(), usages[j].TotalBytes(); a != b {
+			return a > b
+		}
+		return usages[i].Type < usages[j].Type
+	})
+	return usages
+}
+
+// usage returns the counters of a message type, creating them
+func (pu *ProtocolUsage) usage(msgType MessageType) *typeUsage {
+	pu.mu.RLock()
+	usage, exists := pu.byType[msgType]
+	pu.mu.RUnlock()
+	if exists {
+		return usage
+	}
+
+	pu.mu.Lock()
+	defer pu.mu.Unlock()
+	if usage, exists = pu.byType[msgType]; !exists {
+		if len(pu.byType) >= maxUsageTypes {
+			return &pu.other
+		}
+		usage = &typeUsage{}
+		pu.byType[msgType] = usage
+	}
+	return usage
+}
+
+// configureUsage makes a connection count its frames in usage
+func configureUsage(conn Connection, usage *ProtocolUsage) {
+	if tracked, ok := conn.(UsageTrackable); ok {
+		tracked.SetUsage(usage)
+	}
+}
@@ -0,0 +1,100 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestProtocolUsageHistogram(t *testing.T) {
+	usage := NewProtocolUsage()
+	usage.RecordInbound(MessageTypeRPC, 40)
+	usage.RecordInbound(MessageTypeRPC, 300)
+	usage.RecordOutbound(MessageTypeData, 2*1024*1024)
+
+	snapshot := usage.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Type != MessageTypeData || snapshot[1].Name != "rpc" {
+		t.Fatalf("Expected data before rpc by bytes, got %+v", snapshot)
+	}
+
+	rpc := snapshot[1].Inbound
+	if rpc.Count != 2 || rpc.Bytes != 340 || rpc.Max != 300 || rpc.Mean() != 170 {
+		t.Errorf("Unexpected rpc histogram %+v", rpc)
+	}
+	if rpc.Counts[0] != 1 || rpc.Counts[2] != 1 {
+		t.Errorf("Expected one frame up to 64 and one up to 1024 bytes, got %v", rpc.Counts)
+	}
+	if data := snapshot[0].Outbound; data.Counts[len(data.Counts)-1] != 1 {
+		t.Errorf("Expected the 2MB frame in the unbounded bucket, got %v", data.Counts)
+	}
+}
+
+func TestProtocolUsageBoundsTypes(t *testing.T) {
+	usage := NewProtocolUsage()
+	for i := 0; i < maxUsageTypes+10; i++ {
+		usage.RecordInbound(MessageType(1000+i), 1)
+	}
+
+	snapshot := usage.Snapshot()
+	if len(snapshot) != maxUsageTypes+1 {
+		t.Fatalf("Expected %d types plus other, got %d", maxUsageTypes, len(snapshot))
+	}
+	for _, u := range snapshot {
+		if u.Name == "other" && u.Inbound.Count != 10 {
+			t.Errorf("Expected 10 frames counted as other, got %d", u.Inbound.Count)
+		}
+	}
+}
+
+func TestServerClientUsage(t *testing.T) {
+	pn := NewPipeNetwork()
+	server := newEchoPipeServer(t, pn)
+	defer server.Stop()
+
+	client, err := NewPipeClient(pn, DefaultNetworkConfig())
+	if err != nil {
+		t.Fatalf("Failed to create pipe client: %v", err)
+	}
+	replies := make(chan *Message, 1)
+	client.SetMessageHandler(&testMessageHandler{
+		onMessage: func(conn Connection, msg *Message) { replies <- msg },
+	})
+	if _, err := client.Connect("echo:1"); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	payload := bytes.Repeat([]byte("x"), 500)
+	client.SendMessage(NewMessage(MessageTypeRPC, payload))
+	select {
+	case <-replies:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for echo")
+	}
+
+	frame := int64(MessageHeaderSize + len(payload))
+	find := func(usages []MessageTypeUsage, msgType MessageType) MessageTypeUsage {
+		for _, u := range usages {
+			if u.Type == msgType {
+				return u
+			}
+		}
+		return MessageTypeUsage{}
+	}
+
+	clientUsage := client.GetStatistics().Usage
+	if rpc := find(clientUsage, MessageTypeRPC); rpc.Outbound.Count != 1 || rpc.Outbound.Bytes != frame {
+		t.Errorf("Expected one outbound rpc frame of %d bytes on the client, got %+v", frame, rpc.Outbound)
+	}
+	if data := find(clientUsage, MessageTypeData); data.Inbound.Count != 1 {
+		t.Errorf("Expected one inbound data frame on the client, got %+v", data.Inbound)
+	}
+
+	serverUsage := server.GetStatistics().Usage
+	if rpc := find(serverUsage, MessageTypeRPC); rpc.Inbound.Count != 1 || rpc.Inbound.Bytes != frame {
+		t.Errorf("Expected one inbound rpc frame of %d bytes on the server, got %+v", frame, rpc.Inbound)
+	}
+	if data := find(serverUsage, MessageTypeData); data.Outbound.Count != 1 {
+		t.Errorf("Expected one outbound data frame on the server, got %+v", data.Outbound)
+	}
+}
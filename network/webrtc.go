@@ -0,0 +1,716 @@
+// Package network provides a WebRTC data channel transport for browser peers
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProtocolWebRTC is a browser peer connected over a WebRTC data channel
+const ProtocolWebRTC Protocol = "webrtc"
+
+// WebRTC transport errors
+var (
+	// ErrUnknownPeer is returned for signaling of a peer without an offer
+	ErrUnknownPeer = errors.New("unknown webrtc peer")
+
+	// ErrDataChannelNotOpen is returned when sending before the data
+	// channel of a peer opened
+	ErrDataChannelNotOpen = errors.New("webrtc data channel not open")
+)
+
+// ICEServer is a STUN or TURN server used to establish WebRTC connections
+// through NAT. TURN servers need a username and credential.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// Validate checks the URL schemes and TURN credentials
+func (s ICEServer) Validate() error {
+	if len(s.URLs) == 0 {
+		return fmt.Errorf("ice server has no urls")
+	}
+	for _, url := range s.URLs {
+		scheme, _, _ := strings.Cut(url, ":")
+		switch scheme {
+		case "stun", "stuns":
+		case "turn", "turns":
+			if s.Username == "" || s.Credential == "" {
+				return fmt.Errorf("turn server %s needs a username and credential", url)
+			}
+		default:
+			return fmt.Errorf("ice server url %q must use stun, stuns, turn or turns", url)
+		}
+	}
+	return nil
+}
+
+// DataChannelMode selects the delivery guarantees of a data channel
+type DataChannelMode int
+
+const (
+	// DataChannelReliable delivers every message in order, like TCP
+	DataChannelReliable DataChannelMode = iota
+
+	// DataChannelUnreliable delivers messages unordered and never
+	// retransmits them, like UDP; use it for state snapshots and input
+	// that are stale once late
+	DataChannelUnreliable
+)
+
+// String returns the string representation of DataChannelMode
+func (m DataChannelMode) String() string {
+	switch m {
+	case DataChannelReliable:
+		return "reliable"
+	case DataChannelUnreliable:
+		return "unreliable"
+	default:
+		return "unknown"
+	}
+}
+
+// DataChannelOptions are the SCTP parameters of a data channel in a mode
+type DataChannelOptions struct {
+	Ordered bool
+
+	// MaxRetransmits is the number of retransmissions of a lost message,
+	// -1 for no limit
+	MaxRetransmits int
+}
+
+// Options returns the data channel parameters of the mode
+func (m DataChannelMode) Options() DataChannelOptions {
+	if m == DataChannelUnreliable {
+		return DataChannelOptions{Ordered: false, MaxRetransmits: 0}
+	}
+	return DataChannelOptions{Ordered: true, MaxRetransmits: -1}
+}
+
+// SessionDescription is an SDP offer or answer
+type SessionDescription struct {
+	Type string `json:"type"`
+	SDP  string `json:"sdp"`
+}
+
+// ICECandidate is a trickled ICE candidate
+type ICECandidate struct {
+	Candidate     string `json:"candidate"`
+	SDPMid        string `json:"sdpMid,omitempty"`
+	SDPMLineIndex uint16 `json:"sdpMLineIndex"`
+}
+
+// PeerConfig is what a WebRTCStack needs to answer a peer
+type PeerConfig struct {
+	ICEServers []ICEServer
+	Mode       DataChannelMode
+	Options    DataChannelOptions
+}
+
+// PeerCallbacks are called by a WebRTCStack as a peer connection
+// progresses; they must not be called after OnClose
+type PeerCallbacks struct {
+	// OnCandidate receives a local ICE candidate to signal to the peer
+	OnCandidate func(candidate ICECandidate)
+
+	// OnOpen is called once the data channel opened
+	OnOpen func(channel DataChannel)
+
+	// OnMessage receives each message of the data channel
+	OnMessage func(data []byte)
+
+	// OnClose is called once the peer connection or data channel closed
+	OnClose func(err error)
+}
+
+// DataChannel is an open WebRTC data channel
+type DataChannel interface {
+	// Send sends one message
+	Send(data []byte) error
+
+	// Close closes the data channel and its peer connection
+	Close() error
+}
+
+// PeerConnection is a WebRTC peer connection being established or open
+type PeerConnection interface {
+	// AddCandidate adds a remote ICE candidate signalled by the peer
+	AddCandidate(candidate ICECandidate) error
+
+	// RemoteAddr returns the address of the selected candidate pair, nil
+	// before ICE completes
+	RemoteAddr() net.Addr
+
+	// Close closes the peer connection
+	Close() error
+}
+
+// WebRTCStack is the WebRTC implementation (ICE, DTLS and SCTP) the
+// transport runs on, typically an adapter over a WebRTC library such as
+// the contrib/pionsngo module over pion/webrtc. The transport only deals
+// with signaling, framing and the Connection interface.
+type WebRTCStack interface {
+	// Answer creates a peer connection for a browser's offer, which opens
+	// a data channel, and returns the answer to signal back
+	Answer(ctx context.Context, offer SessionDescription, config PeerConfig, callbacks PeerCallbacks) (PeerConnection, SessionDescription, error)
+}
+
+// Signaler is the signaling hook of the WebRTC transport: it carries the
+// local ICE candidates of a peer to the browser, over whatever channel the
+// application signals on, e.g. the WebSocket or HTTP session the offer
+// came in on.
+type Signaler interface {
+	SignalCandidate(peerID string, candidate ICECandidate) error
+}
+
+// SignalerFunc adapts a function to the Signaler interface
+type SignalerFunc func(peerID string, candidate ICECandidate) error
+
+// SignalCandidate calls f
+func (f SignalerFunc) SignalCandidate(peerID string, candidate ICECandidate) error {
+	return f(peerID, candidate)
+}
+
+// webrtcAddr is the address of a peer before ICE selected a candidate pair
+type webrtcAddr string
+
+// Network returns "webrtc"
+func (a webrtcAddr) Network() string { return string(ProtocolWebRTC) }
+
+// String returns the address
+func (a webrtcAddr) String() string { return string(a) }
+
+// WebRTCServer is a gateway Server for browsers connecting over WebRTC
+// data channels. The application receives offers and candidates on its
+// own signaling channel and hands them to HandleOffer and AddCandidate;
+// every message on a data channel is one SNGO frame.
+type WebRTCServer struct {
+	config   *NetworkConfig
+	stack    WebRTCStack
+	signaler Signaler
+	running  int32 // atomic flag
+
+	// Event handlers
+	connHandler ConnectionHandler
+	msgHandler  MessageHandler
+
+	// Peers keyed by the peer ID of the signaling channel
+	peers          map[string]*dataChannelConnection
+	peersMu        sync.RWMutex
+	connectionChan chan Connection
+
+	// Synchronization
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Statistics
+	totalConnections int64
+	totalMessages    int64
+	startTime        time.Time
+}
+
+// NewWebRTCServer creates a WebRTC gateway running on stack, signaling
+// local candidates through signaler. The ICE servers and data channel mode
+// come from config.
+func NewWebRTCServer(config *NetworkConfig, stack WebRTCStack, signaler Signaler) (*WebRTCServer, error) {
+	if config == nil {
+		config = DefaultNetworkConfig()
+		config.Protocol = ProtocolWebRTC
+	}
+	if config.Protocol != ProtocolWebRTC {
+		return nil, fmt.Errorf("invalid protocol for WebRTC server: %s", config.Protocol)
+	}
+	if stack == nil || signaler == nil {
+		return nil, fmt.Errorf("WebRTC server needs a stack and a signaler")
+	}
+	for _, server := range config.ICEServers {
+		if err := server.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebRTCServer{
+		config:         config,
+		stack:          stack,
+		signaler:       signaler,
+		peers:          make(map[string]*dataChannelConnection),
+		connectionChan: make(chan Connection, 100),
+		ctx:            ctx,
+		cancel:         cancel,
+		startTime:      time.Now(),
+	}, nil
+}
+
+// Start starts accepting offers
+func (ws *WebRTCServer) Start() error {
+	if !atomic.CompareAndSwapInt32(&ws.running, 0, 1) {
+		return fmt.Errorf("server is already running")
+	}
+	return nil
+}
+
+// Stop stops accepting offers and closes every peer
+func (ws *WebRTCServer) Stop() error {
+	ws.cancel()
+	atomic.StoreInt32(&ws.running, 0)
+
+	for _, conn := range ws.GetActiveConnections() {
+		conn.Close()
+	}
+	return nil
+}
+
+// Listen returns nil: peers arrive through signaling, not a listener
+func (ws *WebRTCServer) Listen() net.Addr {
+	return nil
+}
+
+// HandleOffer answers the offer of a browser peer. The peer becomes a
+// Connection once its data channel opens.
+func (ws *WebRTCServer) HandleOffer(ctx context.Context, peerID string, offer SessionDescription) (SessionDescription, error) {
+	if atomic.LoadInt32(&ws.running) == 0 {
+		return SessionDescription{}, fmt.Errorf("server is not running")
+	}
+	if max := ws.config.MaxConnections; max > 0 && ws.GetConnectionCount() >= max {
+		return SessionDescription{}, fmt.Errorf("too many webrtc peers")
+	}
+	if timeout := ws.config.SignalingTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	conn := newDataChannelConnection(ws, peerID)
+	ws.peersMu.Lock()
+	if _, exists := ws.peers[peerID]; exists {
+		ws.peersMu.Unlock()
+		return SessionDescription{}, fmt.Errorf("webrtc peer %s already connected", peerID)
+	}
+	ws.peers[peerID] = conn
+	ws.peersMu.Unlock()
+
+	mode := ws.config.DataChannelMode
+	peer, answer, err := ws.stack.Answer(ctx, offer, PeerConfig{
+		ICEServers: ws.config.ICEServers,
+		Mode:       mode,
+		Options:    mode.Options(),
+	}, conn.callbacks())
+	if err != nil {
+		ws.removePeer(peerID, conn)
+		return SessionDescription{}, fmt.Errorf("failed to answer webrtc offer: %w", err)
+	}
+	conn.setPeer(peer)
+	return answer, nil
+}
+
+// AddCandidate adds an ICE candidate the browser signalled for a peer
+func (ws *WebRTCServer) AddCandidate(peerID string, candidate ICECandidate) error {
+	ws.peersMu.RLock()
+	conn, exists := ws.peers[peerID]
+	ws.peersMu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownPeer, peerID)
+	}
+	return conn.addCandidate(candidate)
+}
+
+// AcceptConnection waits for and returns peers whose data channel opened
+func (ws *WebRTCServer) AcceptConnection(ctx context.Context) (Connection, error) {
+	select {
+	case conn := <-ws.connectionChan:
+		return conn, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-ws.ctx.Done():
+		return nil, fmt.Errorf("server is shutting down")
+	}
+}
+
+// SetConnectionHandler sets the handler for new peers
+func (ws *WebRTCServer) SetConnectionHandler(handler ConnectionHandler) {
+	ws.connHandler = handler
+}
+
+// SetMessageHandler sets the handler for incoming messages
+func (ws *WebRTCServer) SetMessageHandler(handler MessageHandler) {
+	ws.msgHandler = handler
+}
+
+// GetActiveConnections returns the peers whose data channel is open
+func (ws *WebRTCServer) GetActiveConnections() []Connection {
+	ws.peersMu.RLock()
+	defer ws.peersMu.RUnlock()
+
+	connections := make([]Connection, 0, len(ws.peers))
+	for _, conn := range ws.peers {
+		if conn.State() == ConnectionStateConnected {
+			connections = append(connections, conn)
+		}
+	}
+	return connections
+}
+
+// GetConnectionCount returns the number of peers, including those still
+// establishing their connection
+func (ws *WebRTCServer) GetConnectionCount() int {
+	ws.peersMu.RLock()
+	defer ws.peersMu.RUnlock()
+
+	return len(ws.peers)
+}
+
+// GetStatistics returns server statistics
+func (ws *WebRTCServer) GetStatistics() ServerStatistics {
+	return ServerStatistics{
+		Protocol:           string(ProtocolWebRTC),
+		Running:            atomic.LoadInt32(&ws.running) == 1,
+		StartTime:          ws.startTime,
+		Uptime:             time.Since(ws.startTime),
+		TotalConnections:   atomic.LoadInt64(&ws.totalConnections),
+		CurrentConnections: int64(len(ws.GetActiveConnections())),
+		TotalMessages:      atomic.LoadInt64(&ws.totalMessages),
+	}
+}
+
+// BroadcastMessage sends a message to every open peer
+func (ws *WebRTCServer) BroadcastMessage(msg *Message) error {
+	if msg == nil {
+		return fmt.Errorf("message is nil")
+	}
+
+	var failed []error
+	for _, conn := range ws.GetActiveConnections() {
+		if err := conn.SendMessage(msg); err != nil {
+			failed = append(failed, fmt.Errorf("failed to send to %s: %w", conn.ID(), err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("broadcast failed for %d connections: %v", len(failed), failed)
+	}
+	return nil
+}
+
+// removePeer forgets a peer if it is still conn
+func (ws *WebRTCServer) removePeer(peerID string, conn *dataChannelConnection) {
+	ws.peersMu.Lock()
+	defer ws.peersMu.Unlock()
+
+	if ws.peers[peerID] == conn {
+		delete(ws.peers, peerID)
+	}
+}
+
+// dataChannelConnection implements the Connection interface for one
+// browser peer
+type dataChannelConnection struct {
+	id     string
+	peerID string
+	server *WebRTCServer
+	codec  MessageCodec
+
+	// Peer connection and, once open, its data channel
+	peerMu  sync.RWMutex
+	peer    PeerConnection
+	channel DataChannel
+
+	state        int32 // ConnectionState as atomic int32
+	closed       int32 // atomic flag
+	done         chan struct{}
+	readTimeout  time.Duration
+	lastActivity int64 // Unix timestamp as atomic int64
+	userData     interface{}
+	mu           sync.RWMutex
+
+	// Messages for ReadMessage when the server has no message handler
+	inbox chan *Message
+
+	// Statistics
+	bytesRead    int64
+	bytesWritten int64
+	messagesRead int64
+	messagesSent int64
+}
+
+// newDataChannelConnection creates the connection of a peer being answered
+func newDataChannelConnection(server *WebRTCServer, peerID string) *dataChannelConnection {
+	return &dataChannelConnection{
+		id:           fmt.Sprintf("webrtc-%d", atomic.AddInt64(&connectionIDCounter, 1)),
+		peerID:       peerID,
+		server:       server,
+		codec:        NewBinaryMessageCodec(),
+		state:        int32(ConnectionStateDisconnected),
+		done:         make(chan struct{}),
+		readTimeout:  30 * time.Second,
+		lastActivity: time.Now().Unix(),
+		inbox:        make(chan *Message, 256),
+	}
+}
+
+// callbacks returns the callbacks the stack drives the connection with
+func (c *dataChannelConnection) callbacks() PeerCallbacks {
+	return PeerCallbacks{
+		OnCandidate: c.onCandidate,
+		OnOpen:      c.onOpen,
+		OnMessage:   c.onMessage,
+		OnClose:     c.onClose,
+	}
+}
+
+// setPeer records the peer connection the stack created
+func (c *dataChannelConnection) setPeer(peer PeerConnection) {
+	c.peerMu.Lock()
+	defer c.peerMu.Unlock()
+	c.peer = peer
+}
+
+// addCandidate passes a remote candidate to the peer connection
+func (c *dataChannelConnection) addCandidate(candidate ICECandidate) error {
+	c.peerMu.RLock()
+	peer := c.peer
+	c.peerMu.RUnlock()
+
+	if peer == nil {
+		return fmt.Errorf("%w: %s has no peer connection yet", ErrUnknownPeer, c.peerID)
+	}
+	return peer.AddCandidate(candidate)
+}
+
+// onCandidate signals a local candidate to the browser
+func (c *dataChannelConnection) onCandidate(candidate ICECandidate) {
+	if err := c.server.signaler.SignalCandidate(c.peerID, candidate); err != nil && c.server.connHandler != nil {
+		c.server.connHandler.OnError(c, fmt.Errorf("failed to signal ice candidate: %w", err))
+	}
+}
+
+// onOpen makes the peer a connection once its data channel opened
+func (c *dataChannelConnection) onOpen(channel DataChannel) {
+	c.peerMu.Lock()
+	c.channel = channel
+	c.peerMu.Unlock()
+
+	if !atomic.CompareAndSwapInt32(&c.state, int32(ConnectionStateDisconnected), int32(ConnectionStateConnected)) {
+		return
+	}
+	atomic.AddInt64(&c.server.totalConnections, 1)
+	if c.server.connHandler != nil {
+		c.server.connHandler.OnConnect(c)
+	}
+	select {
+	case c.server.connectionChan <- c:
+	default:
+		// Nobody is accepting; the peer is still tracked
+	}
+}
+
+// onMessage decodes a data channel message and hands it on
+func (c *dataChannelConnection) onMessage(data []byte) {
+	atomic.StoreInt64(&c.lastActivity, time.Now().Unix())
+	atomic.AddInt64(&c.bytesRead, int64(len(data)))
+
+	msg, err := c.codec.Decode(data)
+	if err != nil {
+		if c.server.msgHandler != nil {
+			c.server.msgHandler.OnError(c, fmt.Errorf("failed to decode data channel message: %w", err))
+		}
+		return
+	}
+	msg.ConnectionID = c.id
+	atomic.AddInt64(&c.messagesRead, 1)
+	atomic.AddInt64(&c.server.totalMessages, 1)
+
+	if c.server.msgHandler != nil {
+		c.server.msgHandler.OnMessage(c, msg)
+		return
+	}
+	select {
+	case c.inbox <- msg:
+	case <-c.done:
+	}
+}
+
+// onClose closes the connection once the stack reports the peer gone
+func (c *dataChannelConnection) onClose(err error) {
+	c.close(err)
+}
+
+// ID returns the connection ID
+func (c *dataChannelConnection) ID() string {
+	return c.id
+}
+
+// RemoteAddr returns the address of the selected ICE candidate pair, or
+// the peer ID until ICE completes
+func (c *dataChannelConnection) RemoteAddr() net.Addr {
+	c.peerMu.RLock()
+	peer := c.peer
+	c.peerMu.RUnlock()
+
+	if peer != nil {
+		if addr := peer.RemoteAddr(); addr != nil {
+			return addr
+		}
+	}
+	return webrtcAddr(c.peerID)
+}
+
+// LocalAddr returns the address of the gateway
+func (c *dataChannelConnection) LocalAddr() net.Addr {
+	return webrtcAddr("gateway")
+}
+
+// Send sends one encoded frame as a data channel message. In unreliable
+// mode the frame may be lost or overtaken by later frames.
+func (c *dataChannelConnection) Send(data []byte) error {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return fmt.Errorf("connection %s is closed", c.id)
+	}
+
+	c.peerMu.RLock()
+	channel := c.channel
+	c.peerMu.RUnlock()
+	if channel == nil {
+		return fmt.Errorf("connection %s: %w", c.id, ErrDataChannelNotOpen)
+	}
+
+	if err := channel.Send(data); err != nil {
+		return fmt.Errorf("failed to send on data channel: %w", err)
+	}
+	atomic.AddInt64(&c.bytesWritten, int64(len(data)))
+	atomic.StoreInt64(&c.lastActivity, time.Now().Unix())
+	return nil
+}
+
+// SendMessage encodes and sends a message
+func (c *dataChannelConnection) SendMessage(msg *Message) error {
+	if msg == nil {
+		return fmt.Errorf("message is nil")
+	}
+
+	msg.ConnectionID = c.id
+	data, err := c.codec.Encode(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	if err := c.Send(data); err != nil {
+		return err
+	}
+	atomic.AddInt64(&c.messagesSent, 1)
+	return nil
+}
+
+// Close closes the data channel and peer connection
+func (c *dataChannelConnection) Close() error {
+	c.close(nil)
+	return nil
+}
+
+// close closes the connection once, reporting err to the handler
+func (c *dataChannelConnection) close(err error) {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return
+	}
+	opened := atomic.SwapInt32(&c.state, int32(ConnectionStateClosed)) == int32(ConnectionStateConnected)
+	close(c.done)
+	c.server.removePeer(c.peerID, c)
+
+	c.peerMu.RLock()
+	peer, channel := c.peer, c.channel
+	c.peerMu.RUnlock()
+	if channel != nil {
+		channel.Close()
+	}
+	if peer != nil {
+		peer.Close()
+	}
+
+	if opened && c.server.connHandler != nil {
+		c.server.connHandler.OnDisconnect(c, err)
+	}
+}
+
+// State returns the current connection state; a peer is disconnected
+// until its data channel opens
+func (c *dataChannelConnection) State() ConnectionState {
+	return ConnectionState(atomic.LoadInt32(&c.state))
+}
+
+// SetReadTimeout sets the timeout of ReadMessage
+func (c *dataChannelConnection) SetReadTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readTimeout = timeout
+}
+
+// SetWriteTimeout has no effect: data channel sends never block
+func (c *dataChannelConnection) SetWriteTimeout(timeout time.Duration) {}
+
+// GetLastActivity returns the timestamp of last activity
+func (c *dataChannelConnection) GetLastActivity() time.Time {
+	return time.Unix(atomic.LoadInt64(&c.lastActivity), 0)
+}
+
+// GetUserData returns user-defined data
+func (c *dataChannelConnection) GetUserData() interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.userData
+}
+
+// SetUserData sets user-defined data
+func (c *dataChannelConnection) SetUserData(data interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.userData = data
+}
+
+// ReadMessage returns the next message when the server has no message
+// handler
+func (c *dataChannelConnection) ReadMessage() (*Message, error) {
+	c.mu.RLock()
+	timeout := c.readTimeout
+	c.mu.RUnlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case msg := <-c.inbox:
+		return msg, nil
+	case <-c.done:
+		return nil, io.EOF
+	case <-expired:
+		return nil, fmt.Errorf("read timeout on connection %s", c.id)
+	}
+}
+
+// GetStatistics returns connection statistics
+func (c *dataChannelConnection) GetStatistics() ConnectionStatistics {
+	return ConnectionStatistics{
+		ConnectionID: c.id,
+		State:        c.State(),
+		BytesRead:    atomic.LoadInt64(&c.bytesRead),
+		BytesWritten: atomic.LoadInt64(&c.bytesWritten),
+		MessagesRead: atomic.LoadInt64(&c.messagesRead),
+		MessagesSent: atomic.LoadInt64(&c.messagesSent),
+		LastActivity: c.GetLastActivity(),
+		RemoteAddr:   c.RemoteAddr().String(),
+		LocalAddr:    c.LocalAddr().String(),
+	}
+}
@@ -0,0 +1,167 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWebRTCStack answers offers with in-memory peers the test drives
+type fakeWebRTCStack struct {
+	mu     sync.Mutex
+	peers  map[string]*fakePeer
+	config PeerConfig
+}
+
+type fakePeer struct {
+	callbacks  PeerCallbacks
+	candidates []ICECandidate
+	sent       [][]byte
+	closed     bool
+	mu         sync.Mutex
+}
+
+func (p *fakePeer) AddCandidate(candidate ICECandidate) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.candidates = append(p.candidates, candidate)
+	return nil
+}
+
+func (p *fakePeer) RemoteAddr() net.Addr { return nil }
+
+func (p *fakePeer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+func (p *fakePeer) Send(data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, append([]byte(nil), data...))
+	return nil
+}
+
+func (s *fakeWebRTCStack) Answer(ctx context.Context, offer SessionDescription, config PeerConfig, callbacks PeerCallbacks) (PeerConnection, SessionDescription, error) {
+	if offer.Type != "offer" {
+		return nil, SessionDescription{}, errors.New("not an offer")
+	}
+	peer := &fakePeer{callbacks: callbacks}
+	s.mu.Lock()
+	s.peers[offer.SDP] = peer
+	s.config = config
+	s.mu.Unlock()
+	return peer, SessionDescription{Type: "answer", SDP: "answer-" + offer.SDP}, nil
+}
+
+func TestICEServerValidate(t *testing.T) {
+	valid := []ICEServer{
+		{URLs: []string{"stun:stun.example.com:3478"}},
+		{URLs: []string{"turns:turn.example.com:5349"}, Username: "u", Credential: "c"},
+	}
+	for _, server := range valid {
+		if err := server.Validate(); err != nil {
+			t.Errorf("Expected %v valid, got %v", server.URLs, err)
+		}
+	}
+	invalid := []ICEServer{
+		{},
+		{URLs: []string{"turn:turn.example.com"}},
+		{URLs: []string{"http://stun.example.com"}},
+	}
+	for _, server := range invalid {
+		if err := server.Validate(); err == nil {
+			t.Errorf("Expected %v invalid", server.URLs)
+		}
+	}
+}
+
+func TestWebRTCServer(t *testing.T) {
+	stack := &fakeWebRTCStack{peers: make(map[string]*fakePeer)}
+	signalled := make(chan ICECandidate, 1)
+	signaler := SignalerFunc(func(peerID string, candidate ICECandidate) error {
+		if peerID == "browser-1" {
+			signalled <- candidate
+		}
+		return nil
+	})
+
+	config := DefaultNetworkConfig()
+	config.Protocol = ProtocolWebRTC
+	config.DataChannelMode = DataChannelUnreliable
+	server, err := NewWebRTCServer(config, stack, signaler)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	ctx := context.Background()
+	answer, err := server.HandleOffer(ctx, "browser-1", SessionDescription{Type: "offer", SDP: "sdp-1"})
+	if err != nil || answer.SDP != "answer-sdp-1" {
+		t.Fatalf("Expected an answer, got %+v (%v)", answer, err)
+	}
+	if opts := stack.config.Options; opts.Ordered || opts.MaxRetransmits != 0 {
+		t.Errorf("Expected unordered unreliable channel options, got %+v", opts)
+	}
+	if err := server.AddCandidate("browser-2", ICECandidate{}); !errors.Is(err, ErrUnknownPeer) {
+		t.Errorf("Expected ErrUnknownPeer, got %v", err)
+	}
+	if err := server.AddCandidate("browser-1", ICECandidate{Candidate: "remote"}); err != nil {
+		t.Errorf("Failed to add candidate: %v", err)
+	}
+
+	peer := stack.peers["sdp-1"]
+	peer.callbacks.OnCandidate(ICECandidate{Candidate: "local"})
+	if c := <-signalled; c.Candidate != "local" {
+		t.Errorf("Expected the local candidate signalled, got %+v", c)
+	}
+	if n := len(server.GetActiveConnections()); n != 0 {
+		t.Errorf("Expected no active connection before the channel opens, got %d", n)
+	}
+
+	// The peer becomes a connection once its data channel opens
+	peer.callbacks.OnOpen(peer)
+	acceptCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	conn, err := server.AcceptConnection(acceptCtx)
+	if err != nil {
+		t.Fatalf("Failed to accept connection: %v", err)
+	}
+	if conn.State() != ConnectionStateConnected || conn.RemoteAddr().String() != "browser-1" {
+		t.Errorf("Unexpected connection %s at %s", conn.State(), conn.RemoteAddr())
+	}
+
+	// Each data channel message is one frame
+	codec := NewBinaryMessageCodec()
+	frame, _ := codec.Encode(NewMessage(MessageTypeData, []byte("move")))
+	peer.callbacks.OnMessage(frame)
+	msg, err := conn.ReadMessage()
+	if err != nil || string(msg.Data) != "move" {
+		t.Fatalf("Expected the move message, got %v (%v)", msg, err)
+	}
+	if err := conn.SendMessage(NewMessage(MessageTypeData, []byte("state"))); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	sent, err := codec.Decode(peer.sent[0])
+	if err != nil || string(sent.Data) != "state" {
+		t.Errorf("Expected the state frame sent, got %v (%v)", sent, err)
+	}
+
+	// The stack closing the peer closes the connection
+	peer.callbacks.OnClose(nil)
+	if _, err := conn.ReadMessage(); err != io.EOF {
+		t.Errorf("Expected EOF once closed, got %v", err)
+	}
+	if !peer.closed || server.GetConnectionCount() != 0 {
+		t.Errorf("Expected the peer closed and forgotten")
+	}
+}